@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+)
+
+const (
+	groupCommandGroups = "groups"
+	groupShortcuts     = "shortcuts"
+
+	// suggestionDistance is the maximum edit distance for "did you mean" suggestions
+	suggestionDistance = 2
+)
+
+// shortcuts are top-level commands that run a subcommand directly
+var shortcuts = map[string]string{
+	"explain": "cidr explain",
+	"lookup":  "dns lookup",
+}
+
+// subcommandAliases are extra names for subcommands within their group
+var subcommandAliases = map[string][]string{
+	"cidr expand": {"x"},
+	"dns reverse": {"ptr"},
+}
+
+// configureCommandDiscovery wires up aliases, shortcuts, and "did you mean"
+// suggestions. It must run after every command group has been added to root.
+func configureCommandDiscovery(root *cobra.Command) {
+	root.AddGroup(
+		&cobra.Group{ID: groupCommandGroups, Title: "Command Groups:"},
+		&cobra.Group{ID: groupShortcuts, Title: "Shortcuts:"},
+	)
+
+	root.SuggestionsMinimumDistance = suggestionDistance
+	for _, group := range root.Commands() {
+		if !group.HasSubCommands() || group.Runnable() {
+			continue
+		}
+		group.SuggestionsMinimumDistance = suggestionDistance
+		group.GroupID = groupCommandGroups
+		group.Args = cobra.ArbitraryArgs
+		group.RunE = runCommandGroup
+	}
+
+	for path, aliases := range subcommandAliases {
+		cmd := mustFindCommand(root, path)
+		cmd.Aliases = append(cmd.Aliases, aliases...)
+	}
+
+	for name, path := range shortcuts {
+		root.AddCommand(newShortcut(name, mustFindCommand(root, path)))
+	}
+}
+
+// runCommandGroup shows help for a bare group and suggests close matches for
+// unknown subcommands, which cobra only does for the root command
+func runCommandGroup(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return cmd.Help()
+	}
+	return unknownCommandError(cmd, args[0])
+}
+
+func unknownCommandError(cmd *cobra.Command, name string) error {
+	message := fmt.Sprintf("unknown command %q for %q", name, cmd.CommandPath())
+	if suggestions := cmd.SuggestionsFor(name); len(suggestions) > 0 {
+		message += "\n\nDid you mean this?\n\t" + strings.Join(suggestions, "\n\t")
+	}
+	return errcode.Errorf(errcode.CLIUnknownCommand, "%s", message)
+}
+
+// newShortcut builds a top-level command that shares the target's flags and behavior
+func newShortcut(name string, target *cobra.Command) *cobra.Command {
+	shortcut := &cobra.Command{
+		Use:         name + strings.TrimPrefix(target.Use, target.Name()),
+		Short:       fmt.Sprintf("%s (shortcut for '%s')", target.Short, target.CommandPath()),
+		Long:        target.Long,
+		Args:        target.Args,
+		Run:         target.Run,
+		RunE:        target.RunE,
+		Annotations: target.Annotations,
+		GroupID:     groupShortcuts,
+	}
+	shortcut.Flags().AddFlagSet(target.LocalNonPersistentFlags())
+	shortcut.Flags().AddFlagSet(target.InheritedFlags())
+	return shortcut
+}
+
+func mustFindCommand(root *cobra.Command, path string) *cobra.Command {
+	cmd, rest, err := root.Find(strings.Fields(path))
+	if err != nil || len(rest) > 0 || cmd == root {
+		panic(fmt.Sprintf("command discovery: %q is not a command", path))
+	}
+	return cmd
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/cmd/cidr"
+	"github.com/spf13/pflag"
+)
+
+func TestShortcutsShareTargetFlags(t *testing.T) {
+	for name, path := range shortcuts {
+		shortcut, _, err := rootCmd.Find([]string{name})
+		if err != nil || shortcut.Name() != name {
+			t.Fatalf("shortcut %q not registered: %v", name, err)
+		}
+		target := mustFindCommand(rootCmd, path)
+
+		target.LocalNonPersistentFlags().VisitAll(func(flag *pflag.Flag) {
+			if shortcut.Flags().Lookup(flag.Name) != flag {
+				t.Errorf("shortcut %q does not share flag --%s with %q", name, flag.Name, path)
+			}
+		})
+		if !strings.Contains(shortcut.Short, target.CommandPath()) {
+			t.Errorf("shortcut %q help should name its target, got %q", name, shortcut.Short)
+		}
+	}
+}
+
+func TestSubcommandAliases(t *testing.T) {
+	for path, aliases := range subcommandAliases {
+		want := mustFindCommand(rootCmd, path)
+		parent := strings.Fields(path)[0]
+		for _, alias := range aliases {
+			got, _, err := rootCmd.Find([]string{parent, alias})
+			if err != nil || got != want {
+				t.Errorf("alias %q did not resolve to %q", parent+" "+alias, path)
+			}
+		}
+	}
+}
+
+func TestUnknownSubcommandSuggestions(t *testing.T) {
+	err := runCommandGroup(cidr.CidrCmd, []string{"explian"})
+	if err == nil || !strings.Contains(err.Error(), "Did you mean this?\n\texplain") {
+		t.Fatalf("expected suggestion for explain, got %v", err)
+	}
+
+	err = runCommandGroup(cidr.CidrCmd, []string{"zzzzzz"})
+	if err == nil || strings.Contains(err.Error(), "Did you mean") {
+		t.Fatalf("expected plain unknown command error, got %v", err)
+	}
+
+	if suggestions := rootCmd.SuggestionsFor("lokup"); !slices.Contains(suggestions, "lookup") {
+		t.Fatalf("expected root suggestions to include lookup, got %v", suggestions)
+	}
+}
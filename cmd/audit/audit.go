@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/audit"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+)
+
+// AuditCmd represents the audit command
+var AuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect the local log of probing invocations",
+	Long: `Inspect the local audit log of commands that sent packets.
+
+Audit logging is opt-in. Enable it by setting audit-log in the config file or
+by passing --audit-log; every probing command (mtu discover, watch, and suggest,
+dns ptr-audit and delegation) then appends who ran it, when, against which
+targets, and how many packets it could send. Probing commands refuse to run if
+the entry cannot be written.`,
+}
+
+// showCmd represents the audit show command
+var showCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print audit log entries",
+	Long:  `Show prints entries from the audit log, oldest first.`,
+	Args:  cobra.NoArgs,
+	RunE:  runShow,
+}
+
+func init() {
+	AuditCmd.AddCommand(showCmd)
+
+	showCmd.Flags().StringP("format", "f", "table", "Output format (table, json)")
+	showCmd.Flags().Int("last", 0, "Only show the most recent N entries (0 = all)")
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	last, _ := cmd.Flags().GetInt("last")
+	if last < 0 {
+		return errcode.Errorf(errcode.CLIUsage, "--last must be non-negative")
+	}
+
+	entries, err := audit.ReadAll(audit.Path())
+	if err != nil {
+		return err
+	}
+	if last > 0 && len(entries) > last {
+		entries = entries[len(entries)-last:]
+	}
+
+	return outputEntries(cmd.OutOrStdout(), entries, format)
+}
+
+func outputEntries(w io.Writer, entries []audit.Entry, format string) error {
+	switch format {
+	case "json":
+		if entries == nil {
+			entries = []audit.Entry{}
+		}
+		output, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to generate JSON: %v", err)
+		}
+		_, _ = fmt.Fprintln(w, string(output))
+	case "table":
+		if len(entries) == 0 {
+			_, _ = fmt.Fprintln(w, "No audit entries")
+			return nil
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		defer func() { _ = tw.Flush() }()
+
+		_, _ = fmt.Fprintf(tw, "TIME\tUSER\tHOST\tCOMMAND\tTARGETS\tPACKETS\n")
+		_, _ = fmt.Fprintf(tw, "----\t----\t----\t-------\t-------\t-------\n")
+		for _, entry := range entries {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				entry.Time.Local().Format(time.RFC3339),
+				entryUser(entry),
+				entry.Host,
+				entryCommand(entry),
+				strings.Join(entry.Targets, ", "),
+				entryPackets(entry),
+			)
+		}
+	default:
+		return errcode.Errorf(errcode.CLIUnsupportedFormat, "unsupported output format: %s", format)
+	}
+	return nil
+}
+
+func entryUser(entry audit.Entry) string {
+	if entry.SudoUser != "" {
+		return fmt.Sprintf("%s (sudo %s)", entry.SudoUser, entry.User)
+	}
+	return entry.User
+}
+
+func entryCommand(entry audit.Entry) string {
+	if len(entry.Deprecated) > 0 {
+		return fmt.Sprintf("%s (deprecated %s)", entry.Command, strings.Join(entry.Deprecated, ", "))
+	}
+	return entry.Command
+}
+
+func entryPackets(entry audit.Entry) string {
+	switch {
+	case entry.Packets == 0:
+		return "-"
+	case entry.IntervalMS > 0:
+		return fmt.Sprintf("%d every %v", entry.Packets, time.Duration(entry.IntervalMS)*time.Millisecond)
+	default:
+		return fmt.Sprintf("%d", entry.Packets)
+	}
+}
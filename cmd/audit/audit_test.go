@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/audit"
+	"github.com/spf13/cobra"
+)
+
+func newShowCommand(out *bytes.Buffer) *cobra.Command {
+	cmd := &cobra.Command{Use: "show", RunE: runShow}
+	cmd.SetOut(out)
+	cmd.Flags().StringP("format", "f", "table", "Output format")
+	cmd.Flags().Int("last", 0, "Last entries")
+	return cmd
+}
+
+func TestRunShow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit.SetPath(path)
+	t.Cleanup(func() { audit.SetPath("") })
+
+	when := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, entry := range []audit.Entry{
+		{Time: when, User: "alice", Host: "bastion", Command: "cidrator mtu discover", Targets: []string{"192.0.2.1"}, Packets: 14},
+		{Time: when.Add(time.Minute), User: "root", SudoUser: "bob", Host: "bastion", Command: "cidrator mtu watch", Targets: []string{"192.0.2.2"}, Packets: 10, IntervalMS: 30000, Deprecated: []string{"--json"}},
+		{Time: when.Add(2 * time.Minute), User: "alice", Host: "bastion", Command: "cidrator dns delegation", Targets: []string{"example.com"}},
+	} {
+		if err := audit.Append(path, entry); err != nil {
+			t.Fatalf("Append returned error: %v", err)
+		}
+	}
+
+	var out bytes.Buffer
+	cmd := newShowCommand(&out)
+	cmd.SetArgs([]string{"--last", "2"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("audit show failed: %v", err)
+	}
+
+	output := out.String()
+	if strings.Contains(output, "cidrator mtu discover") {
+		t.Fatalf("expected --last 2 to drop the oldest entry, got:\n%s", output)
+	}
+	for _, fragment := range []string{"bob (sudo root)", "10 every 30s", "cidrator mtu watch (deprecated --json)", "cidrator dns delegation", "example.com"} {
+		if !strings.Contains(output, fragment) {
+			t.Fatalf("expected output to contain %q, got:\n%s", fragment, output)
+		}
+	}
+
+	out.Reset()
+	cmd.SetArgs([]string{"--last", "0", "--format", "json"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("audit show failed: %v", err)
+	}
+	if strings.Count(out.String(), `"command"`) != 3 {
+		t.Fatalf("expected 3 JSON entries, got:\n%s", out.String())
+	}
+}
+
+func TestRunShowRequiresConfiguredLog(t *testing.T) {
+	audit.SetPath("")
+
+	var out bytes.Buffer
+	cmd := newShowCommand(&out)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "no audit log configured") {
+		t.Fatalf("expected not configured error, got %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package bench
+
+import (
+	"github.com/euan-cowie/cidrator/cmd/mtu"
+	"github.com/spf13/cobra"
+)
+
+// BenchCmd represents the bench command
+var BenchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Benchmark the host cidrator runs on",
+	Long: `Measure the local stack so results can be read in context.
+
+The bench command group separates "the tool or host is slow" from "the
+network is slow" by timing what cidrator does locally before anything
+crosses the network.`,
+}
+
+func init() {
+	BenchCmd.AddCommand(mtu.SelfBenchCmd)
+}
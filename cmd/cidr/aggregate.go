@@ -0,0 +1,59 @@
+package cidr
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/spf13/cobra"
+)
+
+// aggregateCmd represents the aggregate command
+var aggregateCmd = &cobra.Command{
+	Use:   "aggregate [CIDR...]",
+	Short: "Merge CIDR ranges into the fewest covering prefixes",
+	Long: `Aggregate collapses a list of CIDRs and addresses into the fewest CIDR blocks
+that cover exactly the same addresses. Adjacent prefixes are merged and prefixes
+contained in others are dropped. IPv4 and IPv6 can be mixed; IPv4 blocks are
+printed first.
+
+With no arguments, or with -, prefixes are read from stdin one per line. Blank
+lines and # comments are ignored, so route table exports can be piped in after
+trimming them to the prefix column.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			prefixes []string
+			err      error
+		)
+		if len(args) == 0 || (len(args) == 1 && args[0] == "-") {
+			prefixes, err = aggregateReader(stdin)
+		} else {
+			prefixes, err = cidr.Aggregate(args)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to aggregate: %w", err)
+		}
+
+		for _, prefix := range prefixes {
+			fmt.Println(prefix)
+		}
+		return nil
+	},
+}
+
+// stdin is where aggregate reads prefixes when given none, replaced in tests
+var stdin io.Reader = os.Stdin
+
+// aggregateReader aggregates prefixes read one per line from r
+func aggregateReader(r io.Reader) ([]string, error) {
+	set, err := cidr.ReadSet(r)
+	if err != nil {
+		return nil, err
+	}
+	return set.Strings(), nil
+}
+
+func init() {
+	CidrCmd.AddCommand(aggregateCmd)
+}
@@ -0,0 +1,109 @@
+package cidr
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/prefixset"
+	"github.com/spf13/cobra"
+)
+
+// allocateCmd represents the allocate command
+var allocateCmd = &cobra.Command{
+	Use:   "allocate <SUPERNET>",
+	Short: "Find the next free subnets of a given size",
+	Long: `Allocate plans new subnets inside a supernet, avoiding every subnet already in
+use, and prints the next --count free subnets of --size in address order.
+
+Each --used value is a CIDR or address, a saved set as @name (see
+'cidrator set'), or a file listing one CIDR per line (# comments allowed;
+- reads stdin). Repeat --used to combine them.
+
+Strategies:
+  first-fit  take the lowest free subnets (default)
+  best-fit   take subnets from the smallest free blocks that fit first, keeping
+             large blocks whole for later allocations`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.Allocate.Validate(); err != nil {
+			return err
+		}
+		prefix, err := config.Allocate.Prefix()
+		if err != nil {
+			return err
+		}
+		used, err := readUsedSubnets(config.Allocate.Used)
+		if err != nil {
+			return err
+		}
+
+		supernet, err := cidrArg(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to allocate: %w", err)
+		}
+		subnets, err := cidr.Allocate(supernet, used, cidr.AllocationOptions{
+			Prefix:   prefix,
+			Count:    config.Allocate.Count,
+			Strategy: config.Allocate.Strategy,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to allocate: %w", err)
+		}
+
+		for _, subnet := range subnets {
+			fmt.Println(subnet)
+		}
+		return nil
+	},
+}
+
+// readUsedSubnets combines the --used values into one set
+func readUsedSubnets(values []string) (*cidr.Set, error) {
+	used := &cidr.Set{}
+	for _, value := range values {
+		set, err := readUsedValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("--used %s: %w", value, err)
+		}
+		used = used.Union(set)
+	}
+	return used, nil
+}
+
+// readUsedValue loads one --used value: @name, -, a CIDR, or a file
+func readUsedValue(value string) (*cidr.Set, error) {
+	if name, ok := strings.CutPrefix(value, "@"); ok {
+		return prefixset.Resolver(nil, ".")(name)
+	}
+	if value == "-" {
+		return cidr.ReadSet(stdin)
+	}
+	if set, err := cidr.ParseSet([]string{value}); err == nil {
+		return set, nil
+	}
+
+	file, err := os.Open(value)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errcode.Errorf(errcode.CLIUsage, "not a CIDR, address, or existing file")
+	}
+	if err != nil {
+		return nil, errcode.Wrap(errcode.CLIUsage, err)
+	}
+	defer func() { _ = file.Close() }()
+	return cidr.ReadSet(file)
+}
+
+func init() {
+	CidrCmd.AddCommand(allocateCmd)
+
+	allocateCmd.Flags().StringArrayVar(&config.Allocate.Used, "used", nil, "Subnet already in use: CIDR, @name, or file of CIDRs (- for stdin, repeatable)")
+	allocateCmd.Flags().StringVar(&config.Allocate.Size, "size", "", "Prefix length of each new subnet, e.g. /24")
+	allocateCmd.Flags().IntVar(&config.Allocate.Count, "count", 1, "Number of subnets to allocate")
+	allocateCmd.Flags().StringVar(&config.Allocate.Strategy, "strategy", cidr.StrategyFirstFit, "Allocation strategy (first-fit, best-fit)")
+	_ = allocateCmd.MarkFlagRequired("size")
+}
@@ -0,0 +1,47 @@
+package cidr
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// errCheckFalse is returned by --exit-code checks that came out false. The
+// command silences it, so the only trace is the exit status.
+var errCheckFalse = errors.New("check is false")
+
+// checkResult is the answer for one argument of contains or overlaps
+type checkResult struct {
+	Arg string
+	OK  bool
+}
+
+// addCheckFlags registers the scripting flags shared by contains and overlaps
+func addCheckFlags(cmd *cobra.Command, cfg *CheckConfig) {
+	cmd.Flags().BoolVarP(&cfg.ExitCode, "exit-code", "q", false, "Print nothing; exit 0 when true and 1 when false")
+	cmd.Flags().BoolVar(&cfg.Any, "any", false, "With several arguments, answer true when any check is true")
+	cmd.Flags().BoolVar(&cfg.All, "all", false, "With several arguments, answer true only when every check is true (the --exit-code default)")
+}
+
+// reportChecks prints the results, or with --exit-code turns the combined
+// answer into the exit status. Several results print one "ARG true|false"
+// line each unless --any or --all asks for a single answer.
+func reportChecks(cmd *cobra.Command, cfg *CheckConfig, results []checkResult) error {
+	answer := cfg.Combine(results)
+	switch {
+	case cfg.ExitCode:
+		if !answer {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return errCheckFalse
+		}
+	case len(results) == 1 || cfg.Any || cfg.All:
+		fmt.Println(answer)
+	default:
+		for _, result := range results {
+			fmt.Printf("%s %t\n", result.Arg, result.OK)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package cidr
 
 import (
+	"github.com/euan-cowie/cidrator/internal/target"
 	"github.com/spf13/cobra"
 )
 
@@ -11,5 +12,37 @@ var CidrCmd = &cobra.Command{
 	Long: `Inspect and manipulate IPv4 or IPv6 CIDR ranges.
 
 The cidr command group covers explanation, expansion, containment checks,
-counting, overlap detection, and subnet division.`,
+counting, overlap detection, subnet division, supernetting, prefix list
+comparison, random sampling, and classification of single addresses. The v6
+sub-group adds IPv6 nibble splitting and ip6.arpa reverse zones.
+
+Commands take whatever is pasted: a bare IP where a CIDR is expected is its
+/32 or /128, a START-END range is the CIDR it spans, and a hostname where an
+address is expected is resolved, through the resolver from the config file
+if one is set. --strict-input turns this off.`,
+}
+
+// cidrArg reads an argument where a CIDR is expected. Unless --strict-input
+// is set, a bare IP stands for its /32 or /128 and a range for the one block
+// it spans.
+func cidrArg(arg string) (string, error) {
+	prefix, err := target.Prefix(arg)
+	if err != nil {
+		return "", err
+	}
+	return prefix.String(), nil
+}
+
+// ipArg reads an argument where an address is expected. Unless
+// --strict-input is set, a hostname is resolved, and audited as cmd's
+// lookup, and a /32 or /128 stands for its address.
+func ipArg(cmd *cobra.Command, arg string) (string, error) {
+	if err := target.AuditLookup(cmd.CommandPath(), arg); err != nil {
+		return "", err
+	}
+	addr, err := target.Addr(cmd.Context(), arg)
+	if err != nil {
+		return "", err
+	}
+	return addr.String(), nil
 }
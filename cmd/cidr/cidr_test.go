@@ -5,9 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/prefixset"
+	"github.com/euan-cowie/cidrator/internal/store"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 )
@@ -67,6 +71,7 @@ func TestExplainCommand(t *testing.T) {
 	tests := []struct {
 		name      string
 		args      []string
+		stdin     string
 		expectErr bool
 		checkFunc func(t *testing.T, output string)
 	}{
@@ -99,6 +104,9 @@ func TestExplainCommand(t *testing.T) {
 				if result["is_ipv6"] != false {
 					t.Error("JSON should indicate IPv4")
 				}
+				if result["wildcard_mask"] != "0.0.0.255" || result["hex_netmask"] != "0xffffff00" {
+					t.Errorf("JSON should contain the wildcard and hex masks, got %v", result)
+				}
 			},
 		},
 		{
@@ -142,16 +150,162 @@ func TestExplainCommand(t *testing.T) {
 			expectErr: true,
 		},
 		{
-			name:      "Too many arguments",
+			name:      "Invalid second CIDR",
 			args:      []string{"explain", "192.168.1.0/24", "extra"},
 			expectErr: true,
 		},
+		{
+			name: "Multiple CIDRs table format",
+			args: []string{"explain", "192.168.1.0/24", "2001:db8::/64"},
+			checkFunc: func(t *testing.T, output string) {
+				if !strings.Contains(output, "CIDR: 192.168.1.0/24") || !strings.Contains(output, "CIDR: 2001:db8::/64") {
+					t.Errorf("Output should head each table with its CIDR, got:\n%s", output)
+				}
+			},
+		},
+		{
+			name: "Multiple CIDRs JSON format",
+			args: []string{"explain", "192.168.1.0/24", "10.0.0.0/8", "--format", "json"},
+			checkFunc: func(t *testing.T, output string) {
+				var result []map[string]interface{}
+				if err := json.Unmarshal([]byte(output), &result); err != nil {
+					t.Fatalf("Invalid JSON output: %v", err)
+				}
+				if len(result) != 2 || result[1]["cidr"] != "10.0.0.0/8" || result[1]["total_addresses"] != "16,777,216" {
+					t.Errorf("Unexpected JSON list: %v", result)
+				}
+			},
+		},
+		{
+			name:  "Stdin JSONL format",
+			args:  []string{"explain", "-", "--format", "jsonl"},
+			stdin: "192.168.1.0/24\n\n# lab\n2001:db8::/64 # v6\n",
+			checkFunc: func(t *testing.T, output string) {
+				lines := strings.Split(strings.TrimSpace(output), "\n")
+				if len(lines) != 2 {
+					t.Fatalf("Expected one record per CIDR, got:\n%s", output)
+				}
+				var record map[string]interface{}
+				if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+					t.Fatalf("Invalid JSONL record: %v", err)
+				}
+				if record["cidr"] != "2001:db8::/64" || record["is_ipv6"] != true {
+					t.Errorf("Unexpected record: %v", record)
+				}
+			},
+		},
+		{
+			name:      "Invalid CIDR on stdin",
+			args:      []string{"explain", "-"},
+			stdin:     "192.168.1.0/24\nbogus\n",
+			expectErr: true,
+		},
+		{
+			name: "Bare IP as a host CIDR",
+			args: []string{"explain", "192.0.2.1", "--format", "json"},
+			checkFunc: func(t *testing.T, output string) {
+				var result map[string]interface{}
+				if err := json.Unmarshal([]byte(output), &result); err != nil {
+					t.Fatalf("Invalid JSON output: %v", err)
+				}
+				if result["prefix_length"] != float64(32) || result["total_addresses"] != "1" {
+					t.Errorf("Expected a /32, got %v", result)
+				}
+			},
+		},
+		{
+			name:  "Range on stdin",
+			args:  []string{"explain", "-", "--format", "jsonl"},
+			stdin: "10.0.0.0-10.0.0.255\n",
+			checkFunc: func(t *testing.T, output string) {
+				var record map[string]interface{}
+				if err := json.Unmarshal([]byte(output), &record); err != nil {
+					t.Fatalf("Invalid JSONL record: %v", err)
+				}
+				if record["cidr"] != "10.0.0.0-10.0.0.255" || record["prefix_length"] != float64(24) {
+					t.Errorf("Expected the range as a /24, got %v", record)
+				}
+			},
+		},
+		{
+			name:      "Range of several blocks",
+			args:      []string{"explain", "10.0.0.0-10.0.0.2"},
+			expectErr: true,
+		},
+		{
+			name: "Compare table format",
+			args: []string{"explain", "10.0.0.0/23", "10.0.0.0/24", "--compare"},
+			checkFunc: func(t *testing.T, output string) {
+				lines := strings.Split(output, "\n")
+				if !strings.Contains(lines[0], "10.0.0.0/23") || !strings.Contains(lines[0], "10.0.0.0/24") {
+					t.Errorf("Header should name both CIDRs, got %q", lines[0])
+				}
+				for _, line := range lines {
+					if strings.Contains(line, "Base Address") && strings.HasPrefix(line, "*") {
+						t.Errorf("Matching base addresses should not be marked, got %q", line)
+					}
+					if strings.Contains(line, "Prefix Length") && !strings.HasPrefix(line, "*") {
+						t.Errorf("Differing prefix lengths should be marked, got %q", line)
+					}
+				}
+				if !strings.Contains(output, "* 11 fields differ") {
+					t.Errorf("Output should count the differences, got:\n%s", output)
+				}
+			},
+		},
+		{
+			name: "Compare JSON format",
+			args: []string{"explain", "10.0.0.0/24", "2001:db8::/64", "--compare", "--format", "json"},
+			checkFunc: func(t *testing.T, output string) {
+				var result struct {
+					A           map[string]interface{} `json:"a"`
+					B           map[string]interface{} `json:"b"`
+					Differences []string               `json:"differences"`
+				}
+				if err := json.Unmarshal([]byte(output), &result); err != nil {
+					t.Fatalf("Invalid JSON output: %v", err)
+				}
+				if result.A["cidr"] != "10.0.0.0/24" || result.B["cidr"] != "2001:db8::/64" {
+					t.Errorf("Unexpected pair: %v %v", result.A, result.B)
+				}
+				if len(result.Differences) != len(explainFields) || result.Differences[0] != "base_address" {
+					t.Errorf("Every field should differ, got %v", result.Differences)
+				}
+			},
+		},
+		{
+			name: "Compare identical CIDRs",
+			args: []string{"explain", "2001:db8::/48", "2001:db8::1/48", "--compare", "--format", "yaml"},
+			checkFunc: func(t *testing.T, output string) {
+				var result map[string]interface{}
+				if err := yaml.Unmarshal([]byte(output), &result); err != nil {
+					t.Fatalf("Invalid YAML output: %v", err)
+				}
+				if differences, ok := result["differences"].([]interface{}); !ok || len(differences) != 0 {
+					t.Errorf("Expected an empty differences list, got %v", result["differences"])
+				}
+			},
+		},
+		{
+			name:      "Compare needs two CIDRs",
+			args:      []string{"explain", "10.0.0.0/24", "--compare"},
+			expectErr: true,
+		},
+		{
+			name:      "Compare does not read stdin",
+			args:      []string{"explain", "10.0.0.0/24", "-", "--compare"},
+			stdin:     "10.0.0.0/23\n",
+			expectErr: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Reset the output format flag for each test
 			config.Explain.OutputFormat = "table"
+			originalStdin := stdin
+			t.Cleanup(func() { stdin = originalStdin })
+			stdin = strings.NewReader(tt.stdin)
 
 			// Capture stdout
 			oldStdout := os.Stdout
@@ -160,11 +314,12 @@ func TestExplainCommand(t *testing.T) {
 
 			// Create a new command instance for each test
 			cmd := &cobra.Command{
-				Use:  "explain <CIDR>",
-				Args: cobra.ExactArgs(1),
+				Use:  "explain <CIDR>...",
+				Args: explainCmd.Args,
 				RunE: explainCmd.RunE,
 			}
 			cmd.Flags().StringVarP(&config.Explain.OutputFormat, "format", "f", "table", "Output format")
+			cmd.Flags().BoolVar(&config.Explain.Compare, "compare", false, "Compare two CIDRs")
 
 			// Execute command
 			cmd.SetArgs(tt.args[1:]) // Remove "explain" from args
@@ -268,6 +423,59 @@ func TestExpandCommand(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "IPv4 JSON Lines output",
+			args: []string{"expand", "192.0.2.0/31", "--format", "jsonl"},
+			checkFunc: func(t *testing.T, output string) {
+				expected := `{"ip":"192.0.2.0","index":0,"ptr_name":"0.2.0.192.in-addr.arpa"}
+{"ip":"192.0.2.1","index":1,"ptr_name":"1.2.0.192.in-addr.arpa"}`
+				if strings.TrimSpace(output) != expected {
+					t.Errorf("Expected %s, got %s", expected, strings.TrimSpace(output))
+				}
+			},
+		},
+		{
+			name: "IPv6 CSV output with limit",
+			args: []string{"expand", "2001:db8::/126", "--format", "csv", "--limit", "2"},
+			checkFunc: func(t *testing.T, output string) {
+				expected := "ip,index,ptr_name\n" +
+					"2001:db8::,0,0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa\n" +
+					"2001:db8::1,1,1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"
+				if strings.TrimSpace(output) != expected {
+					t.Errorf("Expected %s, got %s", expected, strings.TrimSpace(output))
+				}
+			},
+		},
+		{
+			name: "Exclude reserved keeps indexes from the range start",
+			args: []string{"expand", "0.0.0.0/7", "--exclude-reserved", "--limit", "2", "--format", "jsonl"},
+			checkFunc: func(t *testing.T, output string) {
+				expected := `{"ip":"1.0.0.0","index":16777216,"ptr_name":"0.0.0.1.in-addr.arpa"}
+{"ip":"1.0.0.1","index":16777217,"ptr_name":"1.0.0.1.in-addr.arpa"}`
+				if strings.TrimSpace(output) != expected {
+					t.Errorf("Expected %s, got %s", expected, strings.TrimSpace(output))
+				}
+			},
+		},
+		{
+			name: "Exclude reserved of a reserved block",
+			args: []string{"expand", "127.0.0.0/30", "--exclude-reserved"},
+			checkFunc: func(t *testing.T, output string) {
+				if strings.TrimSpace(output) != "" {
+					t.Errorf("Expected no addresses, got %s", output)
+				}
+			},
+		},
+		{
+			name:      "One-line with structured format",
+			args:      []string{"expand", "192.0.2.0/31", "--format", "csv", "--one-line"},
+			expectErr: true,
+		},
+		{
+			name:      "Unsupported format",
+			args:      []string{"expand", "192.0.2.0/31", "--format", "xml"},
+			expectErr: true,
+		},
 		{
 			name:      "Invalid CIDR",
 			args:      []string{"expand", "invalid"},
@@ -285,6 +493,8 @@ func TestExpandCommand(t *testing.T) {
 			// Reset flags
 			config.Expand.Limit = 0
 			config.Expand.OneLine = false
+			config.Expand.OutputFormat = "text"
+			config.Expand.ExcludeReserved = false
 
 			// Capture stdout
 			oldStdout := os.Stdout
@@ -299,6 +509,8 @@ func TestExpandCommand(t *testing.T) {
 			}
 			cmd.Flags().IntVarP(&config.Expand.Limit, "limit", "l", 0, "Maximum number of IPs")
 			cmd.Flags().BoolVarP(&config.Expand.OneLine, "one-line", "o", false, "One line output")
+			cmd.Flags().StringVarP(&config.Expand.OutputFormat, "format", "f", "text", "Output format")
+			cmd.Flags().BoolVar(&config.Expand.ExcludeReserved, "exclude-reserved", false, "Skip reserved blocks")
 
 			// Execute
 			cmd.SetArgs(tt.args[1:])
@@ -333,6 +545,16 @@ func TestExpandCommand(t *testing.T) {
 }
 
 func TestContainsCommand(t *testing.T) {
+	store.Configure(store.BackendJSON, t.TempDir())
+	t.Cleanup(func() { store.Configure("", "") })
+	lab, err := cidr.ParseSet([]string{"10.1.0.0/16"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prefixset.Put("lab", lab); err != nil {
+		t.Fatal(err)
+	}
+
 	tests := []struct {
 		name      string
 		args      []string
@@ -369,27 +591,110 @@ func TestContainsCommand(t *testing.T) {
 			args:      []string{"contains", "192.168.1.0/24", "invalid"},
 			expectErr: true,
 		},
+		{
+			name:     "Saved set contains",
+			args:     []string{"contains", "@lab", "10.1.2.3"},
+			expected: "true",
+		},
+		{
+			name:     "Saved set does not contain",
+			args:     []string{"contains", "@lab", "10.2.0.1"},
+			expected: "false",
+		},
+		{
+			name:      "Unknown set",
+			args:      []string{"contains", "@nope", "10.1.2.3"},
+			expectErr: true,
+		},
+		{
+			name:      "Invalid IP for a set",
+			args:      []string{"contains", "@lab", "invalid"},
+			expectErr: true,
+		},
 		{
 			name:      "Not enough arguments",
 			args:      []string{"contains", "192.168.1.0/24"},
 			expectErr: true,
 		},
 		{
-			name:      "Too many arguments",
+			name:     "Several IPs",
+			args:     []string{"contains", "192.168.1.0/24", "192.168.1.1", "192.168.2.1"},
+			expected: "192.168.1.1 true\n192.168.2.1 false",
+		},
+		{
+			name:     "Several IPs with --all",
+			args:     []string{"contains", "--all", "192.168.1.0/24", "192.168.1.1", "192.168.2.1"},
+			expected: "false",
+		},
+		{
+			name:     "Several IPs with --any",
+			args:     []string{"contains", "--any", "@lab", "10.2.0.1", "10.1.0.1"},
+			expected: "true",
+		},
+		{
+			name:      "Invalid later IP",
 			args:      []string{"contains", "192.168.1.0/24", "192.168.1.1", "extra"},
 			expectErr: true,
 		},
+		{
+			name:      "Both --any and --all",
+			args:      []string{"contains", "--any", "--all", "192.168.1.0/24", "192.168.1.1"},
+			expectErr: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cmd := createTestCommand("contains <CIDR> <IP>", 2, containsCmd.RunE)
+			cmd := newCheckTestCommand(containsCmd, &config.Contains.CheckConfig)
 			output, err := captureCommandOutput(t, cmd, tt.args[1:])
 			assertTestResult(t, err, output, tt.expectErr, tt.expected)
 		})
 	}
 }
 
+// newCheckTestCommand copies contains or overlaps with its scripting flags
+func newCheckTestCommand(source *cobra.Command, cfg *CheckConfig) *cobra.Command {
+	cmd := &cobra.Command{Use: source.Use, Args: source.Args, RunE: source.RunE}
+	addCheckFlags(cmd, cfg)
+	return cmd
+}
+
+func TestCheckExitCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  *cobra.Command
+		cfg     *CheckConfig
+		args    []string
+		wantErr error
+	}{
+		{name: "contains true", source: containsCmd, cfg: &config.Contains.CheckConfig, args: []string{"-q", "10.0.0.0/8", "10.1.2.3"}},
+		{name: "contains false", source: containsCmd, cfg: &config.Contains.CheckConfig, args: []string{"--exit-code", "10.0.0.0/8", "192.0.2.1"}, wantErr: errCheckFalse},
+		{name: "contains all by default", source: containsCmd, cfg: &config.Contains.CheckConfig, args: []string{"-q", "10.0.0.0/8", "10.1.2.3", "192.0.2.1"}, wantErr: errCheckFalse},
+		{name: "contains any", source: containsCmd, cfg: &config.Contains.CheckConfig, args: []string{"-q", "--any", "10.0.0.0/8", "10.1.2.3", "192.0.2.1"}},
+		{name: "overlaps true", source: overlapsCmd, cfg: &config.Overlaps.CheckConfig, args: []string{"-q", "10.0.0.0/16", "10.0.4.0/22"}},
+		{name: "overlaps false", source: overlapsCmd, cfg: &config.Overlaps.CheckConfig, args: []string{"-q", "10.0.0.0/16", "10.1.0.0/16"}, wantErr: errCheckFalse},
+		{name: "overlaps any", source: overlapsCmd, cfg: &config.Overlaps.CheckConfig, args: []string{"-q", "--any", "10.0.0.0/16", "10.1.0.0/16", "10.0.0.0/8"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newCheckTestCommand(tt.source, tt.cfg)
+			output, err := captureCommandOutput(t, cmd, tt.args)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if output != "" {
+				t.Errorf("expected no output, got %q", output)
+			}
+			if tt.wantErr != nil && !cmd.SilenceErrors {
+				t.Error("expected a false check to silence its error")
+			}
+		})
+	}
+	config.Contains.CheckConfig = CheckConfig{}
+	config.Overlaps.CheckConfig = CheckConfig{}
+}
+
 func TestCountCommand(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -417,6 +722,16 @@ func TestCountCommand(t *testing.T) {
 			args:     []string{"count", "2001:db8::/127"},
 			expected: "2",
 		},
+		{
+			name:     "Exclude reserved",
+			args:     []string{"count", "0.0.0.0/7", "--exclude-reserved"},
+			expected: "16777216",
+		},
+		{
+			name:     "Exclude reserved from the IPv4 space",
+			args:     []string{"count", "0.0.0.0/0", "--exclude-reserved"},
+			expected: "3724475392",
+		},
 		{
 			name:      "Invalid CIDR",
 			args:      []string{"count", "invalid"},
@@ -432,6 +747,7 @@ func TestCountCommand(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			cmd := createTestCommand("count <CIDR>", 1, countCmd.RunE)
+			cmd.Flags().BoolVar(&config.Count.ExcludeReserved, "exclude-reserved", false, "Leave out reserved blocks")
 			output, err := captureCommandOutput(t, cmd, tt.args[1:])
 			assertTestResult(t, err, output, tt.expectErr, tt.expected)
 		})
@@ -480,11 +796,641 @@ func TestOverlapsCommand(t *testing.T) {
 			args:      []string{"overlaps", "192.168.1.0/24"},
 			expectErr: true,
 		},
+		{
+			name:     "Several CIDRs",
+			args:     []string{"overlaps", "10.0.0.0/16", "10.0.4.0/22", "10.1.0.0/16"},
+			expected: "10.0.4.0/22 true\n10.1.0.0/16 false",
+		},
+		{
+			name:     "Several CIDRs with --any",
+			args:     []string{"overlaps", "--any", "10.0.0.0/16", "10.0.4.0/22", "10.1.0.0/16"},
+			expected: "true",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := newCheckTestCommand(overlapsCmd, &config.Overlaps.CheckConfig)
+			output, err := captureCommandOutput(t, cmd, tt.args[1:])
+			assertTestResult(t, err, output, tt.expectErr, tt.expected)
+		})
+	}
+}
+
+func TestOverlapsFileCommand(t *testing.T) {
+	plan := filepath.Join(t.TempDir(), "plan.txt")
+	if err := os.WriteFile(plan, []byte("10.0.0.0/16 prod\n10.0.1.0/24 db # primary\n10.1.0.0/16\n10.1.0.0/16 copy\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		args      []string
+		input     string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name: "table",
+			args: []string{"overlaps", "--file", plan},
+			expected: "Prefixes: 4\nConflicts: 2\n\n" +
+				"OUTER                       RELATION   INNER\n" +
+				"-----                       --------   -----\n" +
+				"10.0.0.0/16 (prod, line 1)  contains   10.0.1.0/24 (db, line 2)\n" +
+				"10.1.0.0/16 (line 3)        duplicate  10.1.0.0/16 (copy, line 4)",
+		},
+		{
+			name:     "stdin without conflicts",
+			args:     []string{"overlaps", "--file", "-"},
+			input:    "10.0.0.0/24\n10.0.1.0/24\n",
+			expected: "Prefixes: 2\nConflicts: 0",
+		},
+		{
+			name:      "file with arguments",
+			args:      []string{"overlaps", "--file", plan, "10.0.0.0/8"},
+			expectErr: true,
+		},
+		{
+			name:      "invalid entry",
+			args:      []string{"overlaps", "--file", "-"},
+			input:     "10.0.0.0/16\nnot-a-cidr\n",
+			expectErr: true,
+		},
+		{
+			name:      "unsupported format",
+			args:      []string{"overlaps", "--file", plan, "--format", "xml"},
+			expectErr: true,
+		},
+		{
+			name:      "file with --exit-code",
+			args:      []string{"overlaps", "--file", plan, "--exit-code"},
+			expectErr: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cmd := createTestCommand("overlaps <CIDR1> <CIDR2>", 2, overlapsCmd.RunE)
+			originalStdin := stdin
+			t.Cleanup(func() { stdin = originalStdin })
+			stdin = strings.NewReader(tt.input)
+
+			cmd := &cobra.Command{
+				Use:  overlapsCmd.Use,
+				Args: overlapsCmd.Args,
+				RunE: overlapsCmd.RunE,
+			}
+			cmd.Flags().StringVar(&config.Overlaps.File, "file", "", "")
+			cmd.Flags().StringVarP(&config.Overlaps.OutputFormat, "format", "f", "table", "")
+			addCheckFlags(cmd, &config.Overlaps.CheckConfig)
+			output, err := captureCommandOutput(t, cmd, tt.args[1:])
+			assertTestResult(t, err, output, tt.expectErr, tt.expected)
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		cmd := &cobra.Command{Use: overlapsCmd.Use, Args: overlapsCmd.Args, RunE: overlapsCmd.RunE}
+		cmd.Flags().StringVar(&config.Overlaps.File, "file", "", "")
+		cmd.Flags().StringVarP(&config.Overlaps.OutputFormat, "format", "f", "table", "")
+		addCheckFlags(cmd, &config.Overlaps.CheckConfig)
+		output, err := captureCommandOutput(t, cmd, []string{"--file", plan, "--format", "json"})
+		if err != nil {
+			t.Fatalf("overlaps --format json failed: %v", err)
+		}
+		var report cidr.ConflictReport
+		if err := json.Unmarshal([]byte(output), &report); err != nil {
+			t.Fatalf("invalid JSON %q: %v", output, err)
+		}
+		if report.Prefixes != 4 || len(report.Conflicts) != 2 || report.Conflicts[1].Relation != cidr.RelationDuplicate ||
+			report.Conflicts[0].Inner.Label != "db" {
+			t.Fatalf("unexpected report: %+v", report)
+		}
+	})
+	config.Overlaps.File = ""
+}
+
+func TestCompareCommand(t *testing.T) {
+	dir := t.TempDir()
+	oldList, newList := filepath.Join(dir, "old.txt"), filepath.Join(dir, "new.txt")
+	if err := os.WriteFile(oldList, []byte("10.0.0.0/23\n10.1.0.0/24 # retired\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(newList, []byte("10.0.0.0/24\n10.0.1.0/24\n10.2.0.0/24\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		args      []string
+		input     string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "text",
+			args:     []string{oldList, newList},
+			expected: "- 10.1.0.0/24\n+ 10.2.0.0/24\n~ 10.0.0.0/23 => 10.0.0.0/24 10.0.1.0/24",
+		},
+		{
+			name:     "old from stdin",
+			args:     []string{"-", newList},
+			input:    "10.0.0.0/24\n10.0.1.0/24\n10.2.0.0/24\n",
+			expected: "",
+		},
+		{
+			name:      "exit code when lists differ",
+			args:      []string{oldList, newList, "--exit-code"},
+			expectErr: true,
+		},
+		{
+			name:      "both from stdin",
+			args:      []string{"-", "-"},
+			expectErr: true,
+		},
+		{
+			name:      "missing file",
+			args:      []string{oldList, filepath.Join(dir, "missing.txt")},
+			expectErr: true,
+		},
+		{
+			name:      "invalid entry",
+			args:      []string{"-", newList},
+			input:     "bogus\n",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalStdin := stdin
+			t.Cleanup(func() { stdin = originalStdin })
+			stdin = strings.NewReader(tt.input)
+
+			cmd := &cobra.Command{Use: compareCmd.Use, Args: compareCmd.Args, RunE: compareCmd.RunE}
+			cmd.Flags().StringVarP(&config.Compare.OutputFormat, "format", "f", "text", "")
+			cmd.Flags().BoolVar(&config.Compare.ExitCode, "exit-code", false, "")
+			output, err := captureCommandOutput(t, cmd, tt.args)
+			assertTestResult(t, err, output, tt.expectErr, tt.expected)
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		cmd := &cobra.Command{Use: compareCmd.Use, Args: compareCmd.Args, RunE: compareCmd.RunE}
+		cmd.Flags().StringVarP(&config.Compare.OutputFormat, "format", "f", "text", "")
+		cmd.Flags().BoolVar(&config.Compare.ExitCode, "exit-code", false, "")
+		output, err := captureCommandOutput(t, cmd, []string{oldList, newList, "--format", "json"})
+		if err != nil {
+			t.Fatalf("compare --format json failed: %v", err)
+		}
+		var diff cidr.ListDiff
+		if err := json.Unmarshal([]byte(output), &diff); err != nil {
+			t.Fatalf("invalid JSON %q: %v", output, err)
+		}
+		if len(diff.Unchanged) != 1 || diff.Unchanged[0] != "10.0.0.0/23" || len(diff.Reaggregated) != 1 {
+			t.Fatalf("unexpected diff: %+v", diff)
+		}
+	})
+}
+
+func TestEvalCommand(t *testing.T) {
+	store.Configure(store.BackendJSON, t.TempDir())
+	t.Cleanup(func() { store.Configure("", "") })
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "corp.txt"), []byte("10.0.0.0/8 # corp\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lab := filepath.Join(dir, "lab-ranges.list")
+	if err := os.WriteFile(lab, []byte("10.1.0.0/16\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		args      []string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "literal expression",
+			args:     []string{"eval", "10.0.0.0/24 - 10.0.0.0/25 - 10.0.0.128/26"},
+			expected: "10.0.0.192/26",
+		},
+		{
+			name:     "named sets from --sets-dir and --set",
+			args:     []string{"eval", "@corp & @lab", "--sets-dir", dir, "--set", "lab=" + lab},
+			expected: "10.1.0.0/16",
+		},
+		{
+			name:      "unknown set",
+			args:      []string{"eval", "@cloud", "--sets-dir", dir},
+			expectErr: true,
+		},
+		{
+			name:      "malformed --set",
+			args:      []string{"eval", "@corp", "--set", "corp"},
+			expectErr: true,
+		},
+		{
+			name:      "syntax error",
+			args:      []string{"eval", "10.0.0.0/8 &"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Eval.Sets = nil
+			config.Eval.SetsDir = "."
+
+			cmd := createTestCommand("eval <EXPRESSION>", 1, evalCmd.RunE)
+			cmd.Flags().StringArrayVar(&config.Eval.Sets, "set", nil, "")
+			cmd.Flags().StringVar(&config.Eval.SetsDir, "sets-dir", ".", "")
+			output, err := captureCommandOutput(t, cmd, tt.args[1:])
+			assertTestResult(t, err, output, tt.expectErr, tt.expected)
+		})
+	}
+}
+
+func TestSubtractCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "multiple exclusions",
+			args:     []string{"subtract", "10.0.0.0/22", "10.0.1.0/24", "10.0.2.128/25"},
+			expected: "10.0.0.0/24\n10.0.2.0/25\n10.0.3.0/24",
+		},
+		{
+			name:     "IPv6",
+			args:     []string{"subtract", "2001:db8::/47", "2001:db8:1::/48"},
+			expected: "2001:db8::/48",
+		},
+		{
+			name:      "invalid exclusion",
+			args:      []string{"subtract", "10.0.0.0/8", "10.1.0.0/99"},
+			expectErr: true,
+		},
+		{
+			name:      "no exclusions",
+			args:      []string{"subtract", "10.0.0.0/8"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{
+				Use:  "subtract <BASE> <EXCLUDE...>",
+				Args: cobra.MinimumNArgs(2),
+				RunE: subtractCmd.RunE,
+			}
+			output, err := captureCommandOutput(t, cmd, tt.args[1:])
+			assertTestResult(t, err, output, tt.expectErr, tt.expected)
+		})
+	}
+}
+
+func TestRangeCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "start and end",
+			args:     []string{"range", "192.168.1.10", "192.168.1.17"},
+			expected: "192.168.1.10/31\n192.168.1.12/30\n192.168.1.16/31",
+		},
+		{
+			name:     "firewall export form",
+			args:     []string{"range", "10.0.0.0-10.0.3.255"},
+			expected: "10.0.0.0/22",
+		},
+		{
+			name:     "IPv6",
+			args:     []string{"range", "2001:db8::", "2001:db8::ff"},
+			expected: "2001:db8::/120",
+		},
+		{
+			name:     "to-range",
+			args:     []string{"range", "--to-range", "10.0.0.0/22"},
+			expected: "10.0.0.0-10.0.3.255",
+		},
+		{
+			name:      "to-range with two arguments",
+			args:      []string{"range", "--to-range", "10.0.0.0/22", "10.0.4.0/22"},
+			expectErr: true,
+		},
+		{
+			name:      "single address without a dash",
+			args:      []string{"range", "10.0.0.1"},
+			expectErr: true,
+		},
+		{
+			name:      "reversed range",
+			args:      []string{"range", "10.0.0.9", "10.0.0.1"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Range.ToRange = false
+
+			cmd := &cobra.Command{
+				Use:  "range <START> <END>",
+				Args: cobra.RangeArgs(1, 2),
+				RunE: rangeCmd.RunE,
+			}
+			cmd.Flags().BoolVar(&config.Range.ToRange, "to-range", false, "")
+			output, err := captureCommandOutput(t, cmd, tt.args[1:])
+			assertTestResult(t, err, output, tt.expectErr, tt.expected)
+		})
+	}
+}
+
+func TestAllocateCommand(t *testing.T) {
+	usedFile := filepath.Join(t.TempDir(), "used.txt")
+	if err := os.WriteFile(usedFile, []byte("# allocated\n10.0.0.0/24\n10.0.2.0/24\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		args      []string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "used file",
+			args:     []string{"allocate", "10.0.0.0/16", "--used", usedFile, "--size", "/24", "--count", "3"},
+			expected: "10.0.1.0/24\n10.0.3.0/24\n10.0.4.0/24",
+		},
+		{
+			name:     "repeated used CIDRs and bare size",
+			args:     []string{"allocate", "10.0.0.0/22", "--used", "10.0.0.0/23", "--used", "10.0.2.0/25", "--size", "25"},
+			expected: "10.0.2.128/25",
+		},
+		{
+			name:     "best-fit",
+			args:     []string{"allocate", "10.0.0.0/22", "--used", "10.0.2.0/25", "--used", "10.0.3.0/24", "--size", "/26", "--strategy", "best-fit"},
+			expected: "10.0.2.128/26",
+		},
+		{
+			name:      "exhausted",
+			args:      []string{"allocate", "10.0.0.0/23", "--used", usedFile, "--size", "/24", "--count", "2"},
+			expectErr: true,
+		},
+		{
+			name:      "missing used file",
+			args:      []string{"allocate", "10.0.0.0/16", "--used", "missing.txt", "--size", "/24"},
+			expectErr: true,
+		},
+		{
+			name:      "invalid size",
+			args:      []string{"allocate", "10.0.0.0/16", "--size", "big"},
+			expectErr: true,
+		},
+		{
+			name:      "zero count",
+			args:      []string{"allocate", "10.0.0.0/16", "--size", "/24", "--count", "0"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{
+				Use:  "allocate <SUPERNET>",
+				Args: cobra.ExactArgs(1),
+				RunE: allocateCmd.RunE,
+			}
+			cmd.Flags().StringArrayVar(&config.Allocate.Used, "used", nil, "")
+			cmd.Flags().StringVar(&config.Allocate.Size, "size", "", "")
+			cmd.Flags().IntVar(&config.Allocate.Count, "count", 1, "")
+			cmd.Flags().StringVar(&config.Allocate.Strategy, "strategy", cidr.StrategyFirstFit, "")
+			output, err := captureCommandOutput(t, cmd, tt.args[1:])
+			assertTestResult(t, err, output, tt.expectErr, tt.expected)
+		})
+	}
+}
+
+func TestInfoCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		contains  []string
+		expectErr bool
+	}{
+		{
+			name: "CGN address table",
+			args: []string{"info", "100.64.12.1"},
+			contains: []string{
+				"Class        cgn: Shared address space (carrier-grade NAT) (100.64.0.0/10, RFC 6598)",
+				"Global       false",
+				"Reverse Zone 12.64.100.in-addr.arpa",
+				"Hex          0x64400c01",
+			},
+		},
+		{
+			name:     "6to4 address JSON",
+			args:     []string{"info", "2002:c000:204::1", "--format", "json"},
+			contains: []string{`"name": "6to4"`, `"embedded_ipv4": "192.0.2.4"`, `"global": true`},
+		},
+		{
+			name:     "ordinary unicast YAML",
+			args:     []string{"info", "8.8.8.8", "--format", "yaml"},
+			contains: []string{"classes: []", "global: true", "reverse_name: 8.8.8.8.in-addr.arpa"},
+		},
+		{
+			name:      "invalid address",
+			args:      []string{"info", "10.0.0.0/8"},
+			expectErr: true,
+		},
+		{
+			name:      "unsupported format",
+			args:      []string{"info", "10.0.0.1", "--format", "xml"},
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := createTestCommand("info <IP>", 1, infoCmd.RunE)
+			cmd.Flags().StringVarP(&config.Info.OutputFormat, "format", "f", "table", "")
+			output, err := captureCommandOutput(t, cmd, tt.args[1:])
+			assertTestResult(t, err, output, tt.expectErr, "")
+			for _, want := range tt.contains {
+				if !strings.Contains(output, want) {
+					t.Errorf("output missing %q:\n%s", want, output)
+				}
+			}
+		})
+	}
+}
+
+func TestRandomCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		lines     int
+		expectErr bool
+	}{
+		{name: "seeded addresses", args: []string{"10.0.0.0/8", "--count", "100", "--seed", "42"}, lines: 100},
+		{name: "usable addresses", args: []string{"192.168.1.0/30", "-n", "2", "--usable"}, lines: 2},
+		{name: "subnets", args: []string{"2001:db8::/32", "--size", "/48", "-n", "3"}, lines: 3},
+		{name: "too many samples", args: []string{"192.168.1.0/30", "-n", "3", "--usable"}, expectErr: true},
+		{name: "usable with size", args: []string{"10.0.0.0/16", "--size", "24", "--usable"}, expectErr: true},
+		{name: "zero count", args: []string{"10.0.0.0/16", "--count", "0"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := captureCommandOutput(t, newRandomTestCommand(), tt.args)
+			assertTestResult(t, err, output, tt.expectErr, "")
+			if tt.expectErr {
+				return
+			}
+			if lines := strings.Fields(output); len(lines) != tt.lines {
+				t.Errorf("expected %d samples, got %q", tt.lines, output)
+			}
+			if tt.name == "usable addresses" && (strings.Contains(output, "192.168.1.0\n") || strings.Contains(output, "192.168.1.3")) {
+				t.Errorf("--usable printed the network or broadcast address: %q", output)
+			}
+		})
+	}
+
+	// The same seed prints the same sample
+	first, _ := captureCommandOutput(t, newRandomTestCommand(), []string{"10.0.0.0/8", "-n", "5", "--seed", "7"})
+	second, _ := captureCommandOutput(t, newRandomTestCommand(), []string{"10.0.0.0/8", "-n", "5", "--seed", "7"})
+	if first == "" || first != second {
+		t.Errorf("--seed 7 printed %q then %q", first, second)
+	}
+}
+
+func newRandomTestCommand() *cobra.Command {
+	cmd := createTestCommand("random <CIDR>", 1, randomCmd.RunE)
+	cmd.Flags().IntVarP(&config.Random.Count, "count", "n", 1, "")
+	cmd.Flags().Uint64Var(&config.Random.Seed, "seed", 0, "")
+	cmd.Flags().StringVar(&config.Random.Size, "size", "", "")
+	cmd.Flags().BoolVar(&config.Random.Usable, "usable", false, "")
+	return cmd
+}
+
+func TestSupernetCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		expected  string
+		expectErr bool
+	}{
+		{name: "gap between inputs", args: []string{"10.1.0.0/24", "10.1.3.0/24"}, expected: "10.1.0.0/22"},
+		{name: "per family", args: []string{"2001:db8:1::/48", "192.168.1.0/24", "2001:db8:2::/48"}, expected: "192.168.1.0/24\n2001:db8::/46"},
+		{name: "within max waste", args: []string{"10.1.0.0/24", "10.1.3.0/24", "--max-waste", "50"}, expected: "10.1.0.0/22"},
+		{name: "over max waste", args: []string{"10.1.0.0/24", "10.1.3.0/24", "--max-waste", "25"}, expectErr: true},
+		{name: "invalid max waste", args: []string{"10.1.0.0/24", "--max-waste", "101"}, expectErr: true},
+		{name: "invalid prefix", args: []string{"10.1.0.0/40"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{Use: "supernet <CIDR...>", Args: supernetCmd.Args, RunE: supernetCmd.RunE}
+			cmd.Flags().Float64Var(&config.Supernet.MaxWaste, "max-waste", 100, "")
+			output, err := captureCommandOutput(t, cmd, tt.args)
+			assertTestResult(t, err, output, tt.expectErr, tt.expected)
+		})
+	}
+}
+
+func TestV6Commands(t *testing.T) {
+	tests := []struct {
+		name      string
+		source    *cobra.Command
+		args      []string
+		expected  string
+		expectErr bool
+	}{
+		{name: "split unaligned", source: splitByNibbleCmd, args: []string{"2001:db8:4::/47"}, expected: "2001:db8:4::/48\n2001:db8:5::/48"},
+		{
+			name:     "split with zones",
+			source:   splitByNibbleCmd,
+			args:     []string{"2001:db8:1:10::/60", "--prefix", "60", "--zones"},
+			expected: "2001:db8:1:10::/60 1.0.0.1.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+		},
+		{name: "split unaligned prefix", source: splitByNibbleCmd, args: []string{"2001:db8::/32", "--prefix", "34"}, expectErr: true},
+		{name: "split ipv4", source: splitByNibbleCmd, args: []string{"10.0.0.0/8"}, expectErr: true},
+		{name: "reverse zones", source: reverseZonesCmd, args: []string{"2001:db8::/32", "2001:db8:4::/47"}, expected: "8.b.d.0.1.0.0.2.ip6.arpa\n4.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa\n5.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"},
+		{name: "reverse zones invalid", source: reverseZonesCmd, args: []string{"2001:db8::/129"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{Use: tt.source.Use, Args: tt.source.Args, RunE: tt.source.RunE}
+			cmd.Flags().IntVarP(&config.V6.Prefix, "prefix", "p", 0, "")
+			cmd.Flags().BoolVar(&config.V6.Zones, "zones", false, "")
+			output, err := captureCommandOutput(t, cmd, tt.args)
+			assertTestResult(t, err, output, tt.expectErr, tt.expected)
+		})
+	}
+}
+
+func TestAggregateCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		stdin     string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "adjacent and contained prefixes",
+			args:     []string{"aggregate", "10.0.1.0/24", "10.0.0.0/24", "10.0.0.128/25"},
+			expected: "10.0.0.0/23",
+		},
+		{
+			name:     "mixed families",
+			args:     []string{"aggregate", "2001:db8:8000::/33", "192.168.0.0/24", "2001:db8::/33"},
+			expected: "192.168.0.0/24\n2001:db8::/32",
+		},
+		{
+			name:     "stdin",
+			args:     []string{"aggregate"},
+			stdin:    "# routes\n10.0.0.0/25\n\n10.0.0.128/25 # second half\n",
+			expected: "10.0.0.0/24",
+		},
+		{
+			name:     "dash reads stdin",
+			args:     []string{"aggregate", "-"},
+			stdin:    "10.0.0.0/24\n10.0.1.0/24\n",
+			expected: "10.0.0.0/23",
+		},
+		{
+			name:      "invalid prefix",
+			args:      []string{"aggregate", "10.0.0.0/24", "10.0.0.0/40"},
+			expectErr: true,
+		},
+		{
+			name:      "invalid stdin line",
+			args:      []string{"aggregate"},
+			stdin:     "10.0.0.0/24\nbogus\n",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldStdin := stdin
+			stdin = strings.NewReader(tt.stdin)
+			t.Cleanup(func() { stdin = oldStdin })
+
+			cmd := &cobra.Command{
+				Use:  "aggregate [CIDR...]",
+				RunE: aggregateCmd.RunE,
+			}
 			output, err := captureCommandOutput(t, cmd, tt.args[1:])
 			assertTestResult(t, err, output, tt.expectErr, tt.expected)
 		})
@@ -549,6 +1495,34 @@ func TestDivideCommand(t *testing.T) {
 			args:      []string{"divide", "192.168.1.0/24"},
 			expectErr: true,
 		},
+		{
+			name: "IPv4 /16 into /24s",
+			args: []string{"divide", "10.0.0.0/16", "--prefix", "24"},
+			checkFunc: func(t *testing.T, output string) {
+				lines := strings.Split(strings.TrimSpace(output), "\n")
+				if len(lines) != 256 {
+					t.Errorf("Expected 256 subnets, got %d", len(lines))
+				}
+				if lines[0] != "10.0.0.0/24" || lines[255] != "10.0.255.0/24" {
+					t.Errorf("Expected 10.0.0.0/24..10.0.255.0/24, got %s..%s", lines[0], lines[255])
+				}
+			},
+		},
+		{
+			name:      "Both N and --prefix",
+			args:      []string{"divide", "10.0.0.0/16", "4", "--prefix", "24"},
+			expectErr: true,
+		},
+		{
+			name:      "Prefix shorter than the network",
+			args:      []string{"divide", "10.0.0.0/16", "-p", "8"},
+			expectErr: true,
+		},
+		{
+			name:      "Negative prefix",
+			args:      []string{"divide", "10.0.0.0/16", "-p", "-1"},
+			expectErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -559,11 +1533,13 @@ func TestDivideCommand(t *testing.T) {
 			os.Stdout = w
 
 			// Create command
+			config.Divide.Prefix = 0
 			cmd := &cobra.Command{
-				Use:  "divide <CIDR> <N>",
-				Args: cobra.ExactArgs(2),
+				Use:  "divide <CIDR> [N]",
+				Args: cobra.RangeArgs(1, 2),
 				RunE: divideCmd.RunE,
 			}
+			cmd.Flags().IntVarP(&config.Divide.Prefix, "prefix", "p", 0, "")
 
 			// Execute
 			cmd.SetArgs(tt.args[1:])
@@ -612,14 +1588,17 @@ func TestCidrCmdStructure(t *testing.T) {
 	}
 
 	// Test that all subcommands are registered
-	subcommands := []string{"explain", "expand", "contains", "count", "overlaps", "divide"}
+	subcommands := []string{"explain", "expand", "contains", "count", "overlaps", "divide", "info", "random"}
 	for _, subcmd := range subcommands {
 		found := false
 		for _, cmd := range CidrCmd.Commands() {
 			if cmd.Use == subcmd+" <CIDR>" ||
-				cmd.Use == subcmd+" <CIDR> <IP>" ||
-				cmd.Use == subcmd+" <CIDR1> <CIDR2>" ||
-				cmd.Use == subcmd+" <CIDR> <N>" {
+				cmd.Use == subcmd+" <CIDR>..." ||
+				cmd.Use == subcmd+" <CIDR> <IP>..." ||
+				cmd.Use == subcmd+" <CIDR> <CIDR>... | --file <FILE>" ||
+				cmd.Use == subcmd+" <CIDR> <N>" ||
+				cmd.Use == subcmd+" <CIDR> [N]" ||
+				cmd.Use == subcmd+" <IP>" {
 				found = true
 				break
 			}
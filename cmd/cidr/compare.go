@@ -0,0 +1,105 @@
+package cidr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:   "compare <OLD> <NEW>",
+	Short: "Compare the address space of two prefix lists",
+	Long: `Compare diffs two prefix lists by the addresses they cover rather than by
+their lines. Each file holds one CIDR or address per line; blank lines and #
+comments are ignored, and - reads one of the two from stdin.
+
+The text output marks the space only NEW covers with +, the space only OLD
+covers with -, and prefixes rewritten without changing what they cover, such as
+a /23 split into two /24s, with ~. Lists that cover the same space written the
+same way print nothing. JSON and YAML also list the unchanged space.
+
+--exit-code exits 1 when the lists differ, for CI checks on prefix lists kept
+in Git.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.Compare
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+		if args[0] == "-" && args[1] == "-" {
+			return errcode.Errorf(errcode.CLIUsage, "only one of OLD and NEW can be read from stdin")
+		}
+
+		lists := make([]io.Reader, 2)
+		for i, path := range args {
+			if path == "-" {
+				lists[i] = stdin
+				continue
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				return errcode.Wrap(errcode.CLIUsage, err)
+			}
+			defer func() { _ = file.Close() }()
+			lists[i] = file
+		}
+
+		diff, err := cidr.CompareLists(lists[0], lists[1])
+		if err != nil {
+			return fmt.Errorf("failed to compare %s and %s: %w", args[0], args[1], err)
+		}
+
+		switch cfg.OutputFormat {
+		case "json":
+			output, err := json.MarshalIndent(diff, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to generate JSON: %v", err)
+			}
+			fmt.Println(string(output))
+		case "yaml":
+			output, err := yaml.Marshal(diff)
+			if err != nil {
+				return fmt.Errorf("failed to generate YAML: %v", err)
+			}
+			fmt.Print(string(output))
+		default:
+			printListDiff(diff)
+		}
+
+		if cfg.ExitCode && diff.HasChanges() {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return errCheckFalse
+		}
+		return nil
+	},
+}
+
+// printListDiff prints removed, added, then reaggregated space, one prefix
+// or rewrite per line
+func printListDiff(diff *cidr.ListDiff) {
+	for _, prefix := range diff.Removed {
+		fmt.Printf("- %s\n", prefix)
+	}
+	for _, prefix := range diff.Added {
+		fmt.Printf("+ %s\n", prefix)
+	}
+	for _, r := range diff.Reaggregated {
+		fmt.Printf("~ %s => %s\n", strings.Join(r.Old, " "), strings.Join(r.New, " "))
+	}
+}
+
+func init() {
+	CidrCmd.AddCommand(compareCmd)
+
+	compareCmd.Flags().StringVarP(&config.Compare.OutputFormat, "format", "f", "text", "Output format (text, json, yaml)")
+	compareCmd.Flags().BoolVar(&config.Compare.ExitCode, "exit-code", false, "Exit 1 when the lists differ")
+}
@@ -1,39 +1,243 @@
 package cidr
 
 import (
-	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/errcode"
 )
 
 // ExplainConfig holds configuration for the explain command
 type ExplainConfig struct {
 	OutputFormat string
+	Compare      bool
 }
 
 // Validate checks if the explain configuration is valid
 func (c *ExplainConfig) Validate() error {
-	validFormats := []string{"table", "json", "yaml"}
-	for _, format := range validFormats {
-		if c.OutputFormat == format {
+	return validateFormat(c.OutputFormat, "table", "json", "yaml", "jsonl")
+}
+
+// validateFormat checks format against the formats a command supports
+func validateFormat(format string, validFormats ...string) error {
+	for _, valid := range validFormats {
+		if format == valid {
 			return nil
 		}
 	}
-	return fmt.Errorf("invalid format '%s': supported formats are %v", c.OutputFormat, validFormats)
+	return errcode.Errorf(errcode.CLIUnsupportedFormat, "invalid format '%s': supported formats are %v", format, validFormats)
 }
 
 // ExpandConfig holds configuration for the expand command
 type ExpandConfig struct {
-	Limit   int
-	OneLine bool
+	Limit           int
+	OneLine         bool
+	OutputFormat    string // text, jsonl, or csv
+	ExcludeReserved bool   // Skip reserved special-purpose blocks
 }
 
 // Validate checks if the expand configuration is valid
 func (c *ExpandConfig) Validate() error {
 	if c.Limit < 0 {
-		return fmt.Errorf("limit must be non-negative, got %d", c.Limit)
+		return errcode.Errorf(errcode.CLIUsage, "limit must be non-negative, got %d", c.Limit)
+	}
+	validFormats := []string{"text", "jsonl", "csv"}
+	if !slices.Contains(validFormats, c.OutputFormat) {
+		return errcode.Errorf(errcode.CLIUnsupportedFormat, "invalid format '%s': supported formats are %v", c.OutputFormat, validFormats)
+	}
+	if c.OneLine && c.OutputFormat != "text" {
+		return errcode.Errorf(errcode.CLIUsage, "--one-line only applies to text output")
+	}
+	return nil
+}
+
+// CountConfig holds configuration for the count command
+type CountConfig struct {
+	ExcludeReserved bool // Leave reserved special-purpose blocks out of the count
+}
+
+// DivideConfig holds configuration for the divide command
+type DivideConfig struct {
+	Prefix int // Target prefix length (0 = divide into N parts)
+}
+
+// Validate checks if the divide configuration is valid
+func (c *DivideConfig) Validate() error {
+	if c.Prefix < 0 {
+		return errcode.Errorf(errcode.CLIUsage, "prefix must be non-negative, got %d", c.Prefix)
+	}
+	return nil
+}
+
+// V6Config holds configuration for the v6 commands
+type V6Config struct {
+	Prefix int  // Split-by-nibble target prefix length (0 = next nibble boundary)
+	Zones  bool // Print each subnet's reverse zone
+}
+
+// Validate checks if the v6 configuration is valid
+func (c *V6Config) Validate() error {
+	if c.Prefix < 0 {
+		return errcode.Errorf(errcode.CLIUsage, "prefix must be non-negative, got %d", c.Prefix)
+	}
+	return nil
+}
+
+// RangeConfig holds configuration for the range command
+type RangeConfig struct {
+	ToRange bool // Print the first and last address of a CIDR instead
+}
+
+// CheckConfig holds the scripting flags shared by contains and overlaps
+type CheckConfig struct {
+	ExitCode bool // Print nothing; exit 0 when true and 1 when false
+	Any      bool // Several checks combine to true when any is true
+	All      bool // Several checks combine to true only when all are true
+}
+
+// Validate checks if the check configuration is valid
+func (c *CheckConfig) Validate() error {
+	if c.Any && c.All {
+		return errcode.Errorf(errcode.CLIUsage, "--any and --all are mutually exclusive")
+	}
+	return nil
+}
+
+// Combine reduces several results to one answer: any with --any, otherwise all
+func (c *CheckConfig) Combine(results []checkResult) bool {
+	if c.Any {
+		return slices.ContainsFunc(results, func(r checkResult) bool { return r.OK })
+	}
+	return !slices.ContainsFunc(results, func(r checkResult) bool { return !r.OK })
+}
+
+// ContainsConfig holds configuration for the contains command
+type ContainsConfig struct {
+	CheckConfig
+}
+
+// OverlapsConfig holds configuration for the overlaps command
+type OverlapsConfig struct {
+	CheckConfig
+	File         string // Address plan to check pairwise (- for stdin)
+	OutputFormat string
+}
+
+// Validate checks if the overlaps configuration is valid
+func (c *OverlapsConfig) Validate() error {
+	if c.File != "" && (c.ExitCode || c.Any || c.All) {
+		return errcode.Errorf(errcode.CLIUsage, "--exit-code, --any, and --all do not apply to --file")
+	}
+	return validateFormat(c.OutputFormat, "table", "json", "yaml")
+}
+
+// CompareConfig holds configuration for the compare command
+type CompareConfig struct {
+	OutputFormat string
+	ExitCode     bool // Exit 1 when the lists differ
+}
+
+// Validate checks if the compare configuration is valid
+func (c *CompareConfig) Validate() error {
+	return validateFormat(c.OutputFormat, "text", "json", "yaml")
+}
+
+// InfoConfig holds configuration for the info command
+type InfoConfig struct {
+	OutputFormat string
+}
+
+// Validate checks if the info configuration is valid
+func (c *InfoConfig) Validate() error {
+	return validateFormat(c.OutputFormat, "table", "json", "yaml")
+}
+
+// AllocateConfig holds configuration for the allocate command
+type AllocateConfig struct {
+	Used     []string // CIDRs, @names, or files of subnets already in use
+	Size     string   // Prefix length of each subnet, with or without a leading /
+	Count    int
+	Strategy string
+}
+
+// Validate checks if the allocate configuration is valid
+func (c *AllocateConfig) Validate() error {
+	if c.Count < 1 {
+		return errcode.Errorf(errcode.CLIUsage, "count must be at least 1, got %d", c.Count)
 	}
 	return nil
 }
 
+// Prefix parses --size into a prefix length
+func (c *AllocateConfig) Prefix() (int, error) {
+	prefix, err := strconv.Atoi(strings.TrimPrefix(c.Size, "/"))
+	if err != nil || prefix < 0 {
+		return 0, errcode.Errorf(errcode.CLIUsage, "invalid --size %q: expected a prefix length such as /24", c.Size)
+	}
+	return prefix, nil
+}
+
+// RandomConfig holds configuration for the random command
+type RandomConfig struct {
+	Count  int
+	Seed   uint64 // Used only when --seed is given
+	Size   string // Prefix length of sampled subnets (empty = sample addresses)
+	Usable bool
+}
+
+// Validate checks if the random configuration is valid
+func (c *RandomConfig) Validate() error {
+	if c.Count < 1 {
+		return errcode.Errorf(errcode.CLIUsage, "count must be at least 1, got %d", c.Count)
+	}
+	if c.Usable && c.Size != "" {
+		return errcode.Errorf(errcode.CLIUsage, "--usable only applies to address sampling, not --size")
+	}
+	return nil
+}
+
+// Prefix parses --size into a prefix length, 0 when sampling addresses
+func (c *RandomConfig) Prefix() (int, error) {
+	if c.Size == "" {
+		return 0, nil
+	}
+	return (&AllocateConfig{Size: c.Size}).Prefix()
+}
+
+// SupernetConfig holds configuration for the supernet command
+type SupernetConfig struct {
+	MaxWaste float64 // Percentage of a supernet no input may leave unused
+}
+
+// Validate checks if the supernet configuration is valid
+func (c *SupernetConfig) Validate() error {
+	if c.MaxWaste < 0 || c.MaxWaste > 100 {
+		return errcode.Errorf(errcode.CLIUsage, "--max-waste must be a percentage between 0 and 100, got %g", c.MaxWaste)
+	}
+	return nil
+}
+
+// EvalConfig holds configuration for the eval command
+type EvalConfig struct {
+	Sets    []string // name=path pairs from --set
+	SetsDir string   // Where @name falls back to name.txt or name
+}
+
+// SetFiles parses the --set pairs into a name to path map
+func (c *EvalConfig) SetFiles() (map[string]string, error) {
+	files := make(map[string]string, len(c.Sets))
+	for _, pair := range c.Sets {
+		name, path, ok := strings.Cut(pair, "=")
+		if !ok || name == "" || path == "" {
+			return nil, errcode.Errorf(errcode.CLIUsage, "invalid --set %q: expected name=path", pair)
+		}
+		files[strings.TrimPrefix(name, "@")] = path
+	}
+	return files, nil
+}
+
 // CommandConfig holds common configuration across all CIDR commands
 type CommandConfig struct {
 	Debug   bool
@@ -42,9 +246,21 @@ type CommandConfig struct {
 
 // GlobalConfig combines all command configurations
 type GlobalConfig struct {
-	Command *CommandConfig
-	Explain *ExplainConfig
-	Expand  *ExpandConfig
+	Command  *CommandConfig
+	Explain  *ExplainConfig
+	Expand   *ExpandConfig
+	Count    *CountConfig
+	Divide   *DivideConfig
+	Range    *RangeConfig
+	Contains *ContainsConfig
+	Overlaps *OverlapsConfig
+	Compare  *CompareConfig
+	Info     *InfoConfig
+	Allocate *AllocateConfig
+	Random   *RandomConfig
+	Supernet *SupernetConfig
+	Eval     *EvalConfig
+	V6       *V6Config
 }
 
 // NewGlobalConfig creates a new global configuration with defaults
@@ -58,8 +274,40 @@ func NewGlobalConfig() *GlobalConfig {
 			OutputFormat: "table",
 		},
 		Expand: &ExpandConfig{
-			Limit:   0,
-			OneLine: false,
+			Limit:        0,
+			OneLine:      false,
+			OutputFormat: "text",
+		},
+		Count: &CountConfig{},
+		Divide: &DivideConfig{
+			Prefix: 0,
+		},
+		Range: &RangeConfig{
+			ToRange: false,
+		},
+		Contains: &ContainsConfig{},
+		Overlaps: &OverlapsConfig{
+			OutputFormat: "table",
+		},
+		Compare: &CompareConfig{
+			OutputFormat: "text",
+		},
+		Info: &InfoConfig{
+			OutputFormat: "table",
+		},
+		Allocate: &AllocateConfig{
+			Count:    1,
+			Strategy: cidr.StrategyFirstFit,
+		},
+		Random: &RandomConfig{
+			Count: 1,
+		},
+		Supernet: &SupernetConfig{
+			MaxWaste: 100,
+		},
+		Eval: &EvalConfig{
+			SetsDir: ".",
 		},
+		V6: &V6Config{},
 	}
 }
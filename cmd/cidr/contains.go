@@ -2,37 +2,80 @@ package cidr
 
 import (
 	"fmt"
+	"net/netip"
+	"strings"
 
 	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/prefixset"
 	"github.com/spf13/cobra"
 )
 
 // containsCmd represents the contains command
 var containsCmd = &cobra.Command{
-	Use:   "contains <CIDR> <IP>",
+	Use:   "contains <CIDR> <IP>...",
 	Short: "Check if an IP address is contained within a CIDR range",
 	Long: `Contains checks whether a given IP address falls within the specified CIDR range.
 
-Examples:
-  cidrator cidr contains 10.0.0.0/16 10.0.14.5
-  cidrator cidr contains 2001:db8:1234:1a00::/106 2001:db8:1234:1a00::1
+The IPs may be hostnames, which are resolved first. A CIDR written as @name refers to a saved prefix set (see 'cidrator set') or
+to name.txt in the current directory.
 
-Returns 'true' if the IP is within the range, 'false' otherwise.`,
-	Args: cobra.ExactArgs(2),
+Returns 'true' if the IP is within the range, 'false' otherwise. Given several
+IPs, contains prints "IP true|false" for each, or one answer with --all (every
+IP is in range) or --any (at least one is).
+
+For shell conditionals, --exit-code (-q) prints nothing and exits 0 when the
+answer is true and 1 when it is false; several IPs must all be in range
+unless --any is given. Invalid input also exits 1, with an error on stderr.
+
+  if cidrator cidr contains -q 10.0.0.0/8 "$ip"; then echo internal; fi
+  cidrator cidr contains --exit-code --any @corp 10.1.2.3 192.0.2.7`,
+	Args: cobra.MinimumNArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cidrStr := args[0]
-		ipStr := args[1]
+		cfg := config.Contains
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
 
-		contains, err := cidr.Contains(cidrStr, ipStr)
-		if err != nil {
-			return fmt.Errorf("failed to check containment: %v", err)
+		var contains func(ipStr string) (bool, error)
+		if name, ok := strings.CutPrefix(args[0], "@"); ok {
+			set, err := prefixset.Resolver(nil, ".")(name)
+			if err != nil {
+				return fmt.Errorf("failed to check containment: %w", err)
+			}
+			contains = func(ipStr string) (bool, error) {
+				addr, err := netip.ParseAddr(ipStr)
+				if err != nil {
+					return false, cidr.NewValidationError("ip", ipStr, cidr.ErrInvalidIP)
+				}
+				return set.Contains(addr), nil
+			}
+		} else {
+			network, err := cidrArg(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to check containment: %w", err)
+			}
+			contains = func(ipStr string) (bool, error) {
+				return cidr.Contains(network, ipStr)
+			}
 		}
 
-		fmt.Println(contains)
-		return nil
+		results := make([]checkResult, 0, len(args)-1)
+		for _, arg := range args[1:] {
+			ipStr, err := ipArg(cmd, arg)
+			if err != nil {
+				return fmt.Errorf("failed to check containment: %w", err)
+			}
+			ok, err := contains(ipStr)
+			if err != nil {
+				return fmt.Errorf("failed to check containment: %w", err)
+			}
+			results = append(results, checkResult{Arg: arg, OK: ok})
+		}
+		return reportChecks(cmd, &cfg.CheckConfig, results)
 	},
 }
 
 func init() {
 	CidrCmd.AddCommand(containsCmd)
+	addCheckFlags(containsCmd, &config.Contains.CheckConfig)
 }
@@ -13,19 +13,22 @@ var countCmd = &cobra.Command{
 	Short: "Count the total number of addresses in a CIDR range",
 	Long: `Count returns the total number of IP addresses available in the specified CIDR range.
 
-Examples:
-  cidrator cidr count 10.0.0.0/16
-  cidrator cidr count 2001:db8:1234:1a00::/106
-  cidrator cidr count 172.16.18.0/31
-
-This includes all addresses (network, broadcast, and host addresses for IPv4).`,
+This includes all addresses (network, broadcast, and host addresses for IPv4).
+--exclude-reserved leaves out the reserved special-purpose blocks that cannot
+be assigned to hosts, the same ones expand --exclude-reserved skips.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cidrStr := args[0]
+		cidrStr, err := cidrArg(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to count addresses: %w", err)
+		}
 
 		count, err := cidr.Count(cidrStr)
+		if config.Count.ExcludeReserved {
+			count, err = cidr.CountAssignable(cidrStr)
+		}
 		if err != nil {
-			return fmt.Errorf("failed to count addresses: %v", err)
+			return fmt.Errorf("failed to count addresses: %w", err)
 		}
 
 		fmt.Println(count.String())
@@ -35,4 +38,6 @@ This includes all addresses (network, broadcast, and host addresses for IPv4).`,
 
 func init() {
 	CidrCmd.AddCommand(countCmd)
+
+	countCmd.Flags().BoolVar(&config.Count.ExcludeReserved, "exclude-reserved", false, "Leave out reserved special-purpose blocks such as loopback, link-local, and multicast")
 }
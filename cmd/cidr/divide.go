@@ -1,38 +1,55 @@
 package cidr
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 
 	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/errcode"
 	"github.com/spf13/cobra"
 )
 
 // divideCmd represents the divide command
 var divideCmd = &cobra.Command{
-	Use:   "divide <CIDR> <N>",
+	Use:   "divide <CIDR> [N]",
 	Short: "Divide a CIDR range into N smaller subnets",
-	Long: `Divide splits a CIDR range into the specified number of smaller, equally-sized subnets.
-
-Examples:
-  cidrator cidr divide 10.0.0.0/16 4
-  cidrator cidr divide 2001:db8:1111:2222:1::/80 8
-  cidrator cidr divide 192.168.0.0/24 2
+	Long: `Divide splits a CIDR range into the specified number of smaller, equally-sized subnets,
+or with --prefix into every subnet of that prefix length.
 
 The command calculates the appropriate subnet mask and returns the list of subnets.
-Note: N must be a power of 2 or the subnets will not utilize the full address space.`,
-	Args: cobra.ExactArgs(2),
+Note: N must be a power of 2 or the subnets will not utilize the full address space.
+With --prefix the subnets are streamed as they are generated, so even very large
+divisions start printing at once and can be stopped at any point.`,
+	Args: cobra.RangeArgs(1, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cidrStr := args[0]
+		if err := config.Divide.Validate(); err != nil {
+			return err
+		}
+
+		cidrStr, err := cidrArg(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to divide CIDR: %w", err)
+		}
+		if config.Divide.Prefix != 0 {
+			if len(args) == 2 {
+				return errcode.Errorf(errcode.CLIUsage, "give either N or --prefix, not both")
+			}
+			return streamDividedSubnets(cmd.Context(), cidrStr, cidr.DivisionOptions{Prefix: config.Divide.Prefix})
+		}
+		if len(args) < 2 {
+			return errcode.Errorf(errcode.CLIUsage, "missing N: give the number of parts or --prefix")
+		}
+
 		nStr := args[1]
 
 		n, err := strconv.Atoi(nStr)
 		if err != nil {
-			return fmt.Errorf("invalid number of parts: %v", err)
+			return errcode.Errorf(errcode.CIDRInvalidParts, "invalid number of parts: %v", err)
 		}
 
 		if n <= 0 {
-			return fmt.Errorf("number of parts must be greater than 0")
+			return errcode.Errorf(errcode.CIDRInvalidParts, "number of parts must be greater than 0")
 		}
 
 		opts := cidr.DivisionOptions{
@@ -41,7 +58,7 @@ Note: N must be a power of 2 or the subnets will not utilize the full address sp
 
 		subnets, err := cidr.Divide(cidrStr, opts)
 		if err != nil {
-			return fmt.Errorf("failed to divide CIDR: %v", err)
+			return fmt.Errorf("failed to divide CIDR: %w", err)
 		}
 
 		for _, subnet := range subnets {
@@ -52,6 +69,19 @@ Note: N must be a power of 2 or the subnets will not utilize the full address sp
 	},
 }
 
+// streamDividedSubnets prints each subnet as soon as it is generated
+func streamDividedSubnets(ctx context.Context, cidrStr string, opts cidr.DivisionOptions) error {
+	for result := range cidr.DivideStream(ctx, cidrStr, opts) {
+		if result.Err != nil {
+			return fmt.Errorf("failed to divide CIDR: %w", result.Err)
+		}
+		fmt.Println(result.Subnet)
+	}
+	return nil
+}
+
 func init() {
 	CidrCmd.AddCommand(divideCmd)
+
+	divideCmd.Flags().IntVarP(&config.Divide.Prefix, "prefix", "p", 0, "Divide into every subnet of this prefix length instead of N parts")
 }
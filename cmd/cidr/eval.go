@@ -0,0 +1,53 @@
+package cidr
+
+import (
+	"fmt"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/prefixset"
+	"github.com/spf13/cobra"
+)
+
+// evalCmd represents the eval command
+var evalCmd = &cobra.Command{
+	Use:   "eval <EXPRESSION>",
+	Short: "Evaluate set arithmetic over CIDR ranges",
+	Long: `Eval combines CIDR ranges with set operators and prints the result as the
+fewest CIDR blocks that cover it exactly.
+
+Operators, from tightest to loosest binding:
+  ~A       complement (every other address of the families in the expression)
+  A & B    intersection
+  A | B    union
+  A - B    difference
+
+Parentheses group, and | and - apply left to right. Operands are CIDRs, bare
+addresses, and named sets: @corp reads the file given by --set corp=PATH, else
+the set saved with 'cidrator set create corp', else corp.txt or corp in
+--sets-dir. Set files list one CIDR or address per line; blank lines and #
+comments are ignored.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		files, err := config.Eval.SetFiles()
+		if err != nil {
+			return err
+		}
+
+		result, err := cidr.Eval(args[0], prefixset.Resolver(files, config.Eval.SetsDir))
+		if err != nil {
+			return fmt.Errorf("failed to evaluate expression: %w", err)
+		}
+
+		for _, prefix := range result.Strings() {
+			fmt.Println(prefix)
+		}
+		return nil
+	},
+}
+
+func init() {
+	CidrCmd.AddCommand(evalCmd)
+
+	evalCmd.Flags().StringArrayVar(&config.Eval.Sets, "set", nil, "Load @NAME from a file (NAME=PATH, repeatable)")
+	evalCmd.Flags().StringVar(&config.Eval.SetsDir, "sets-dir", ".", "Directory searched for NAME.txt or NAME when @NAME has no --set")
+}
@@ -2,7 +2,13 @@ package cidr
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/euan-cowie/cidrator/internal/cidr"
 	"github.com/spf13/cobra"
@@ -14,38 +20,66 @@ var expandCmd = &cobra.Command{
 	Short: "List all IP addresses in a CIDR range",
 	Long: `Expand lists all individual IP addresses contained within the specified CIDR range.
 
-Examples:
-  cidrator cidr expand 192.168.1.0/30
-  cidrator cidr expand 10.0.0.0/29 --limit 10
-  cidrator cidr expand 192.168.1.0/28 --one-line
+--exclude-reserved skips addresses no network can assign to hosts: this
+network (0.0.0.0/8), loopback, link-local, multicast, documentation, and the
+other reserved special-purpose blocks. Private and CGN space is kept. Each
+address keeps its index from the start of the range.
 
 Use --limit to restrict output for large ranges.
-Streaming output uses constant memory regardless of range size.`,
+Streaming output uses constant memory regardless of range size.
+
+Output formats:
+- text (default): One IP per line
+- jsonl: One JSON object per line with ip, index, and ptr_name
+- csv: The same fields as CSV, after a header row`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if err := config.Expand.Validate(); err != nil {
 			return err
 		}
 
-		cidrStr := args[0]
+		cidrStr, err := cidrArg(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to expand CIDR: %w", err)
+		}
 		opts := cidr.ExpansionOptions{
-			Limit: config.Expand.Limit,
+			Limit:           config.Expand.Limit,
+			ExcludeReserved: config.Expand.ExcludeReserved,
 		}
 
 		return streamExpandedIPs(cmd.Context(), cidrStr, opts, config.Expand)
 	},
 }
 
+// expandRecord is one address in jsonl and csv output
+type expandRecord struct {
+	IP      string `json:"ip"`
+	Index   uint64 `json:"index"`
+	PTRName string `json:"ptr_name"`
+}
+
+func newExpandRecord(result cidr.ExpandResult) expandRecord {
+	ptrName := strings.TrimSuffix(cidr.ReverseName(netip.MustParseAddr(result.IP)), ".")
+	return expandRecord{IP: result.IP, Index: result.Index, PTRName: ptrName}
+}
+
 // streamExpandedIPs streams and outputs the expanded IP list
 func streamExpandedIPs(ctx context.Context, cidrStr string, opts cidr.ExpansionOptions, cfg *ExpandConfig) error {
 	results := cidr.Expand(ctx, cidrStr, opts)
 
+	switch cfg.OutputFormat {
+	case "jsonl":
+		return streamExpandedJSONL(results)
+	case "csv":
+		return streamExpandedCSV(results)
+	}
+
 	if cfg.OneLine {
 		// Stream one-line output directly to stdout (constant memory)
 		first := true
 		for result := range results {
 			if result.Err != nil {
-				return fmt.Errorf("failed to expand CIDR: %v", result.Err)
+				return fmt.Errorf("failed to expand CIDR: %w", result.Err)
 			}
 			if !first {
 				fmt.Print(", ")
@@ -60,17 +94,57 @@ func streamExpandedIPs(ctx context.Context, cidrStr string, opts cidr.ExpansionO
 	// Stream directly to stdout for constant memory
 	for result := range results {
 		if result.Err != nil {
-			return fmt.Errorf("failed to expand CIDR: %v", result.Err)
+			return fmt.Errorf("failed to expand CIDR: %w", result.Err)
 		}
 		fmt.Println(result.IP)
 	}
 	return nil
 }
 
+// streamExpandedJSONL writes one JSON object per address
+func streamExpandedJSONL(results <-chan cidr.ExpandResult) error {
+	encoder := json.NewEncoder(os.Stdout)
+	for result := range results {
+		if result.Err != nil {
+			return fmt.Errorf("failed to expand CIDR: %w", result.Err)
+		}
+		if err := encoder.Encode(newExpandRecord(result)); err != nil {
+			return fmt.Errorf("failed to generate JSON: %v", err)
+		}
+	}
+	return nil
+}
+
+// streamExpandedCSV writes a header row and one row per address. The writer
+// buffers a few KB at a time, so memory stays constant.
+func streamExpandedCSV(results <-chan cidr.ExpandResult) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write([]string{"ip", "index", "ptr_name"}); err != nil {
+		return fmt.Errorf("failed to generate CSV: %v", err)
+	}
+	for result := range results {
+		if result.Err != nil {
+			w.Flush()
+			return fmt.Errorf("failed to expand CIDR: %w", result.Err)
+		}
+		record := newExpandRecord(result)
+		if err := w.Write([]string{record.IP, strconv.FormatUint(record.Index, 10), record.PTRName}); err != nil {
+			return fmt.Errorf("failed to generate CSV: %v", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to generate CSV: %v", err)
+	}
+	return nil
+}
+
 func init() {
 	CidrCmd.AddCommand(expandCmd)
 
 	// Add flags
 	expandCmd.Flags().IntVarP(&config.Expand.Limit, "limit", "l", 0, "Maximum number of IPs to expand (0 = no limit)")
 	expandCmd.Flags().BoolVarP(&config.Expand.OneLine, "one-line", "o", false, "Output all IPs on one line, comma-separated")
+	expandCmd.Flags().StringVarP(&config.Expand.OutputFormat, "format", "f", "text", "Output format (text, jsonl, csv)")
+	expandCmd.Flags().BoolVar(&config.Expand.ExcludeReserved, "exclude-reserved", false, "Skip reserved special-purpose blocks such as loopback, link-local, and multicast")
 }
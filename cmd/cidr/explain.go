@@ -1,49 +1,161 @@
 package cidr
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/errcode"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 var config = NewGlobalConfig()
 
 // explainCmd represents the explain command
 var explainCmd = &cobra.Command{
-	Use:   "explain <CIDR>",
-	Short: "Explain and show detailed information about a CIDR range",
+	Use:   "explain <CIDR>...",
+	Short: "Explain and show detailed information about CIDR ranges",
 	Long: `Explain shows comprehensive information about a CIDR range including:
 - Base and broadcast addresses
 - Usable address range
 - Number of total and usable addresses
-- Network mask and host mask
+- Network mask, host mask, Cisco wildcard mask, and hex netmask
+- Base address in binary, split where the prefix ends
 - Prefix length and host bits
 
-Works with both IPv4 and IPv6 CIDR ranges.
+Works with both IPv4 and IPv6 CIDR ranges. Several CIDRs can be given, and -
+reads more from stdin one per line, skipping blank lines and # comments.
+
+--compare takes exactly two CIDRs and shows every field side by side, marking
+the rows that differ with *, which is handy for checking a reconfigured subnet
+against its design. JSON and YAML give the pair as a and b with the keys of
+the differing fields in differences.
 
 Output formats:
-- table (default): Human-readable table format
-- json: JSON format for programmatic use
-- yaml: YAML format for configuration files`,
-	Args: cobra.ExactArgs(1),
+- table (default): Human-readable table format, one table per CIDR
+- json: JSON format for programmatic use (an array for several CIDRs or stdin)
+- yaml: YAML format for configuration files (a list for several CIDRs or stdin)
+- jsonl: One JSON record per line with the input cidr, for batch audits`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if err := config.Explain.Validate(); err != nil {
 			return err
 		}
+		if config.Explain.Compare {
+			return explainCompare(args, config.Explain)
+		}
 
-		cidrStr := args[0]
-		info, err := cidr.ParseCIDR(cidrStr)
-		if err != nil {
-			return fmt.Errorf("failed to parse CIDR: %v", err)
+		// A single CIDR argument keeps its single-object JSON and YAML output
+		if len(args) == 1 && args[0] != "-" {
+			info, err := parseCIDRArg(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to parse CIDR: %w", err)
+			}
+			if config.Explain.OutputFormat == "jsonl" {
+				return printExplainJSONL(args[0], info)
+			}
+			return generateOutput(info, config.Explain)
 		}
 
-		return generateOutput(info, config.Explain)
+		return explainEach(args, stdin, config.Explain)
 	},
 }
 
+// explainRecord is one CIDR in list and jsonl output
+type explainRecord struct {
+	CIDR                    string `json:"cidr" yaml:"cidr"`
+	*cidr.NetworkInfoOutput `yaml:",inline"`
+}
+
+// explainEach explains every argument, expanding - to the CIDRs read from r.
+// Table and jsonl output is printed as each CIDR is parsed; json and yaml
+// are collected into one list.
+func explainEach(args []string, r io.Reader, cfg *ExplainConfig) error {
+	var records []explainRecord
+	explain := func(input string) error {
+		info, err := parseCIDRArg(input)
+		if err != nil {
+			return err
+		}
+		switch cfg.OutputFormat {
+		case "jsonl":
+			return printExplainJSONL(input, info)
+		case "table":
+			if len(records) > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("CIDR: %s\n", input)
+			printTableFormat(info)
+		}
+		records = append(records, explainRecord{CIDR: input, NetworkInfoOutput: info.ToOutput()})
+		return nil
+	}
+
+	for _, arg := range args {
+		if arg != "-" {
+			if err := explain(arg); err != nil {
+				return fmt.Errorf("failed to parse CIDR: %w", err)
+			}
+			continue
+		}
+		scanner := bufio.NewScanner(r)
+		for line := 1; scanner.Scan(); line++ {
+			entry, _, _ := strings.Cut(scanner.Text(), "#")
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			if err := explain(entry); err != nil {
+				return errcode.Wrap(errcode.Of(err), fmt.Errorf("failed to parse CIDR on stdin line %d: %w", line, err))
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read stdin: %w", err)
+		}
+	}
+
+	switch cfg.OutputFormat {
+	case "json":
+		output, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to generate JSON: %v", err)
+		}
+		fmt.Println(string(output))
+	case "yaml":
+		output, err := yaml.Marshal(records)
+		if err != nil {
+			return fmt.Errorf("failed to generate YAML: %v", err)
+		}
+		fmt.Print(string(output))
+	}
+	return nil
+}
+
+// parseCIDRArg parses a CIDR argument, or a bare IP or range standing for one
+func parseCIDRArg(arg string) (*cidr.NetworkInfo, error) {
+	network, err := cidrArg(arg)
+	if err != nil {
+		return nil, err
+	}
+	return cidr.ParseCIDR(network)
+}
+
+// printExplainJSONL prints one compact JSON record
+func printExplainJSONL(input string, info *cidr.NetworkInfo) error {
+	output, err := json.Marshal(explainRecord{CIDR: input, NetworkInfoOutput: info.ToOutput()})
+	if err != nil {
+		return fmt.Errorf("failed to generate JSON: %v", err)
+	}
+	fmt.Println(string(output))
+	return nil
+}
+
 // generateOutput produces output in the specified format
 func generateOutput(info *cidr.NetworkInfo, cfg *ExplainConfig) error {
 	switch cfg.OutputFormat {
@@ -62,7 +174,7 @@ func generateOutput(info *cidr.NetworkInfo, cfg *ExplainConfig) error {
 	case "table":
 		printTableFormat(info)
 	default:
-		return fmt.Errorf("unsupported output format: %s", cfg.OutputFormat)
+		return errcode.Errorf(errcode.CLIUnsupportedFormat, "unsupported output format: %s", cfg.OutputFormat)
 	}
 	return nil
 }
@@ -83,7 +195,10 @@ func printTableFormat(info *cidr.NetworkInfo) {
 
 	if !info.IsIPv6 {
 		_, _ = fmt.Fprintf(w, "Host Mask\t%s\n", info.HostMask)
+		_, _ = fmt.Fprintf(w, "Wildcard Mask\t%s\n", info.WildcardMask)
 	}
+	_, _ = fmt.Fprintf(w, "Hex Netmask\t%s\n", info.HexNetmask)
+	_, _ = fmt.Fprintf(w, "Binary\t%s\n", info.BinaryPrefix)
 
 	_, _ = fmt.Fprintf(w, "Prefix Length\t/%d\n", info.PrefixLength)
 	_, _ = fmt.Fprintf(w, "Host Bits\t%d\n", info.HostBits)
@@ -135,5 +250,6 @@ func init() {
 	CidrCmd.AddCommand(explainCmd)
 
 	// Add output format flag
-	explainCmd.Flags().StringVarP(&config.Explain.OutputFormat, "format", "f", "table", "Output format (table, json, yaml)")
+	explainCmd.Flags().StringVarP(&config.Explain.OutputFormat, "format", "f", "table", "Output format (table, json, yaml, jsonl)")
+	explainCmd.Flags().BoolVar(&config.Explain.Compare, "compare", false, "Compare two CIDRs side by side, marking the fields that differ")
 }
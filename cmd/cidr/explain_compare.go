@@ -0,0 +1,133 @@
+package cidr
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"gopkg.in/yaml.v3"
+)
+
+// explainField is one compared explain field, labelled as in the explain
+// table and keyed as in its JSON
+type explainField struct {
+	Label string
+	Key   string
+	Value func(*cidr.NetworkInfoOutput) string
+}
+
+var explainFields = []explainField{
+	{"Base Address", "base_address", func(o *cidr.NetworkInfoOutput) string { return o.BaseAddress }},
+	{"First Usable", "first_usable", func(o *cidr.NetworkInfoOutput) string { return o.FirstUsable }},
+	{"Last Usable", "last_usable", func(o *cidr.NetworkInfoOutput) string { return o.LastUsable }},
+	{"Broadcast Address", "broadcast_address", func(o *cidr.NetworkInfoOutput) string { return o.BroadcastAddr }},
+	{"Total Addresses", "total_addresses", func(o *cidr.NetworkInfoOutput) string { return o.TotalAddresses }},
+	{"Usable Addresses", "usable_addresses", func(o *cidr.NetworkInfoOutput) string { return o.UsableAddresses }},
+	{"Network Mask", "netmask", func(o *cidr.NetworkInfoOutput) string { return o.Netmask }},
+	{"Host Mask", "host_mask", func(o *cidr.NetworkInfoOutput) string { return o.HostMask }},
+	{"Wildcard Mask", "wildcard_mask", func(o *cidr.NetworkInfoOutput) string { return o.WildcardMask }},
+	{"Hex Netmask", "hex_netmask", func(o *cidr.NetworkInfoOutput) string { return o.HexNetmask }},
+	{"Binary", "binary_prefix", func(o *cidr.NetworkInfoOutput) string { return o.BinaryPrefix }},
+	{"Prefix Length", "prefix_length", func(o *cidr.NetworkInfoOutput) string { return "/" + strconv.Itoa(o.PrefixLength) }},
+	{"Host Bits", "host_bits", func(o *cidr.NetworkInfoOutput) string { return strconv.Itoa(o.HostBits) }},
+	{"IPv6", "is_ipv6", func(o *cidr.NetworkInfoOutput) string { return strconv.FormatBool(o.IsIPv6) }},
+}
+
+// explainComparison is the JSON and YAML pair form of explain --compare
+type explainComparison struct {
+	A           explainRecord `json:"a" yaml:"a"`
+	B           explainRecord `json:"b" yaml:"b"`
+	Differences []string      `json:"differences" yaml:"differences"` // Keys of the fields that differ
+}
+
+// explainCompare explains two CIDRs side by side, marking the fields that differ
+func explainCompare(args []string, cfg *ExplainConfig) error {
+	if len(args) != 2 || args[0] == "-" || args[1] == "-" {
+		return errcode.Errorf(errcode.CLIUsage, "--compare takes exactly two CIDRs")
+	}
+
+	comparison := explainComparison{Differences: []string{}}
+	for i, record := range []*explainRecord{&comparison.A, &comparison.B} {
+		info, err := parseCIDRArg(args[i])
+		if err != nil {
+			return fmt.Errorf("failed to parse CIDR: %w", err)
+		}
+		*record = explainRecord{CIDR: args[i], NetworkInfoOutput: info.ToOutput()}
+	}
+	for _, field := range explainFields {
+		if field.Value(comparison.A.NetworkInfoOutput) != field.Value(comparison.B.NetworkInfoOutput) {
+			comparison.Differences = append(comparison.Differences, field.Key)
+		}
+	}
+
+	switch cfg.OutputFormat {
+	case "json", "jsonl":
+		var output []byte
+		var err error
+		if cfg.OutputFormat == "json" {
+			output, err = json.MarshalIndent(comparison, "", "  ")
+		} else {
+			output, err = json.Marshal(comparison)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to generate JSON: %v", err)
+		}
+		fmt.Println(string(output))
+	case "yaml":
+		output, err := yaml.Marshal(comparison)
+		if err != nil {
+			return fmt.Errorf("failed to generate YAML: %v", err)
+		}
+		fmt.Print(string(output))
+	default:
+		printCompareTable(comparison)
+	}
+	return nil
+}
+
+// printCompareTable prints a row per field, starting with * where the two
+// CIDRs differ. Fields one family lacks, such as IPv6 broadcast, show as -.
+func printCompareTable(comparison explainComparison) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	_, _ = fmt.Fprintf(w, "  Property\t%s\t%s\n", comparison.A.CIDR, comparison.B.CIDR)
+	_, _ = fmt.Fprintf(w, "  --------\t%s\t%s\n", dashes(comparison.A.CIDR), dashes(comparison.B.CIDR))
+	differs := 0
+	for _, field := range explainFields {
+		a, b := field.Value(comparison.A.NetworkInfoOutput), field.Value(comparison.B.NetworkInfoOutput)
+		if a == "" && b == "" {
+			continue
+		}
+		marker := " "
+		if a != b {
+			marker = "*"
+			differs++
+		}
+		_, _ = fmt.Fprintf(w, "%s %s\t%s\t%s\n", marker, field.Label, orDash(a), orDash(b))
+	}
+	_ = w.Flush()
+
+	if differs == 0 {
+		fmt.Println("\nAll fields match")
+		return
+	}
+	fmt.Printf("\n* %d fields differ\n", differs)
+}
+
+func dashes(s string) string {
+	b := make([]byte, len(s))
+	for i := range b {
+		b[i] = '-'
+	}
+	return string(b)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,97 @@
+package cidr
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+)
+
+// infoCmd represents the info command
+var infoCmd = &cobra.Command{
+	Use:   "info <IP>",
+	Short: "Classify a single IP address",
+	Long: `Info reports what kind of address an IP is and how to write it:
+- Special-purpose blocks it belongs to (private, loopback, link-local,
+  multicast, documentation, CGN, 6to4, Teredo, and so on) with their RFCs
+- Whether it is globally reachable
+- The IPv4 address embedded in IPv4-mapped, NAT64, 6to4, and Teredo addresses
+- Its reverse DNS name and enclosing reverse zone (/24 or /64)
+- Decimal, hex, and binary forms
+
+Output formats:
+- table (default): Human-readable table format
+- json: JSON format for programmatic use
+- yaml: YAML format for configuration files`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.Info.Validate(); err != nil {
+			return err
+		}
+
+		ip, err := ipArg(cmd, args[0])
+		if err != nil {
+			return fmt.Errorf("failed to parse IP: %w", err)
+		}
+		info, err := cidr.Classify(ip)
+		if err != nil {
+			return fmt.Errorf("failed to parse IP: %w", err)
+		}
+
+		switch config.Info.OutputFormat {
+		case "json":
+			output, err := info.ToJSON()
+			if err != nil {
+				return fmt.Errorf("failed to generate JSON: %v", err)
+			}
+			fmt.Println(output)
+		case "yaml":
+			output, err := info.ToYAML()
+			if err != nil {
+				return fmt.Errorf("failed to generate YAML: %v", err)
+			}
+			fmt.Print(output)
+		case "table":
+			printAddressInfo(info)
+		default:
+			return errcode.Errorf(errcode.CLIUnsupportedFormat, "unsupported output format: %s", config.Info.OutputFormat)
+		}
+		return nil
+	},
+}
+
+func printAddressInfo(info *cidr.AddressInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	defer func() { _ = w.Flush() }()
+
+	_, _ = fmt.Fprintf(w, "Property\tValue\n")
+	_, _ = fmt.Fprintf(w, "--------\t-----\n")
+	_, _ = fmt.Fprintf(w, "Address\t%s\n", info.IP)
+	_, _ = fmt.Fprintf(w, "Version\tIPv%d\n", info.Version)
+
+	if len(info.Classes) == 0 {
+		_, _ = fmt.Fprintf(w, "Class\tunicast\n")
+	}
+	for _, class := range info.Classes {
+		_, _ = fmt.Fprintf(w, "Class\t%s: %s (%s, %s)\n", class.Name, class.Description, class.Range, class.RFC)
+	}
+
+	_, _ = fmt.Fprintf(w, "Global\t%t\n", info.Global)
+	if info.EmbeddedIPv4 != "" {
+		_, _ = fmt.Fprintf(w, "Embedded IPv4\t%s\n", info.EmbeddedIPv4)
+	}
+	_, _ = fmt.Fprintf(w, "Reverse Name\t%s\n", info.ReverseName)
+	_, _ = fmt.Fprintf(w, "Reverse Zone\t%s\n", info.ReverseZone)
+	_, _ = fmt.Fprintf(w, "Decimal\t%s\n", info.Decimal)
+	_, _ = fmt.Fprintf(w, "Hex\t%s\n", info.Hex)
+	_, _ = fmt.Fprintf(w, "Binary\t%s\n", info.Binary)
+}
+
+func init() {
+	CidrCmd.AddCommand(infoCmd)
+
+	infoCmd.Flags().StringVarP(&config.Info.OutputFormat, "format", "f", "table", "Output format (table, json, yaml)")
+}
@@ -2,38 +2,148 @@ package cidr
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
 
 	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/errcode"
 	"github.com/spf13/cobra"
 )
 
 // overlapsCmd represents the overlaps command
 var overlapsCmd = &cobra.Command{
-	Use:   "overlaps <CIDR1> <CIDR2>",
-	Short: "Check if two CIDR ranges overlap",
+	Use:   "overlaps <CIDR> <CIDR>... | --file <FILE>",
+	Short: "Check if CIDR ranges overlap",
 	Long: `Overlaps checks whether two CIDR ranges have any IP addresses in common.
 
-Examples:
-  cidrator cidr overlaps 10.0.0.0/16 10.0.14.0/22
-  cidrator cidr overlaps 2001:db8:1111:2222:1::/80 2001:db8:1111:2222:1:1::/96
-  cidrator cidr overlaps 192.168.1.0/24 10.0.0.0/8
+Returns 'true' if the ranges overlap, 'false' otherwise. Given more than two
+CIDRs, overlaps checks the first against each of the rest and prints
+"CIDR true|false" for each, or one answer with --all (the first overlaps every
+other) or --any (it overlaps at least one).
 
-Returns 'true' if the ranges overlap, 'false' otherwise.`,
-	Args: cobra.ExactArgs(2),
+For shell conditionals, --exit-code (-q) prints nothing and exits 0 when the
+answer is true and 1 when it is false; with several CIDRs every one must
+overlap unless --any is given. Invalid input also exits 1, with an error on
+stderr.
+
+  cidrator cidr overlaps -q --any 10.20.0.0/16 10.0.0.0/16 10.20.4.0/22 && echo "10.20.0.0/16 is taken"
+
+With --file, overlaps checks a whole address plan instead and reports every
+overlapping pair. The file lists one CIDR or address per line, optionally
+followed by a label such as a VPC or subnet name; # starts a comment and
+--file - reads stdin. Two CIDRs can only overlap by one containing the other,
+so each pair is reported as contains or, for the same range listed twice,
+duplicate.
+
+  cidrator cidr overlaps --file vpc-plan.txt
+  terraform output -json subnets | jq -r '.[]' | cidrator cidr overlaps --file - --format json`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if config.Overlaps.File != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(2)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cidr1 := args[0]
-		cidr2 := args[1]
+		if config.Overlaps.File != "" {
+			return runOverlapsFile(config.Overlaps)
+		}
 
-		overlaps, err := cidr.Overlaps(cidr1, cidr2)
-		if err != nil {
-			return fmt.Errorf("failed to check overlap: %v", err)
+		cfg := config.Overlaps
+		if err := cfg.Validate(); err != nil {
+			return err
 		}
 
-		fmt.Println(overlaps)
-		return nil
+		first, err := cidrArg(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to check overlap: %w", err)
+		}
+		results := make([]checkResult, 0, len(args)-1)
+		for _, other := range args[1:] {
+			prefix, err := cidrArg(other)
+			if err != nil {
+				return fmt.Errorf("failed to check overlap: %w", err)
+			}
+			overlaps, err := cidr.Overlaps(first, prefix)
+			if err != nil {
+				return fmt.Errorf("failed to check overlap: %w", err)
+			}
+			results = append(results, checkResult{Arg: other, OK: overlaps})
+		}
+		return reportChecks(cmd, &cfg.CheckConfig, results)
 	},
 }
 
+// runOverlapsFile reports every overlapping pair in an address plan
+func runOverlapsFile(cfg *OverlapsConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	var r io.Reader = stdin
+	if cfg.File != "-" {
+		file, err := os.Open(cfg.File)
+		if err != nil {
+			return errcode.Wrap(errcode.CLIUsage, err)
+		}
+		defer func() { _ = file.Close() }()
+		r = file
+	}
+
+	entries, err := cidr.ReadPlan(r)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", cfg.File, err)
+	}
+	report := cidr.FindConflicts(entries)
+
+	switch cfg.OutputFormat {
+	case "json":
+		output, err := report.ToJSON()
+		if err != nil {
+			return fmt.Errorf("failed to generate JSON: %v", err)
+		}
+		fmt.Println(output)
+	case "yaml":
+		output, err := report.ToYAML()
+		if err != nil {
+			return fmt.Errorf("failed to generate YAML: %v", err)
+		}
+		fmt.Print(output)
+	default:
+		printConflictTable(report)
+	}
+	return nil
+}
+
+func printConflictTable(report *cidr.ConflictReport) {
+	fmt.Printf("Prefixes: %d\n", report.Prefixes)
+	fmt.Printf("Conflicts: %d\n", len(report.Conflicts))
+	if len(report.Conflicts) == 0 {
+		return
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(w, "OUTER\tRELATION\tINNER\n")
+	_, _ = fmt.Fprintf(w, "-----\t--------\t-----\n")
+	for _, conflict := range report.Conflicts {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", describePlanEntry(conflict.Outer), conflict.Relation, describePlanEntry(conflict.Inner))
+	}
+	_ = w.Flush()
+}
+
+// describePlanEntry formats a prefix with where it came from
+func describePlanEntry(entry cidr.PlanEntry) string {
+	if entry.Label != "" {
+		return fmt.Sprintf("%s (%s, line %d)", entry.Prefix, entry.Label, entry.Line)
+	}
+	return fmt.Sprintf("%s (line %d)", entry.Prefix, entry.Line)
+}
+
 func init() {
 	CidrCmd.AddCommand(overlapsCmd)
+
+	overlapsCmd.Flags().StringVar(&config.Overlaps.File, "file", "", "Check every pair in an address plan, one CIDR per line (- for stdin)")
+	overlapsCmd.Flags().StringVarP(&config.Overlaps.OutputFormat, "format", "f", "table", "Output format for --file (table, json, yaml)")
+	addCheckFlags(overlapsCmd, &config.Overlaps.CheckConfig)
 }
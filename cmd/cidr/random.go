@@ -0,0 +1,64 @@
+package cidr
+
+import (
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/spf13/cobra"
+)
+
+// randomCmd represents the random command
+var randomCmd = &cobra.Command{
+	Use:   "random <CIDR>",
+	Short: "Sample random addresses or subnets from a CIDR range",
+	Long: `Random prints --count unique addresses drawn uniformly from a CIDR range, one
+per line, for generating test data. IPv4 and IPv6 are both supported.
+
+--seed makes the sample repeatable: the same range, options, and seed always
+print the same addresses in the same order. --usable skips the network and
+broadcast addresses of IPv4 ranges larger than /31. --size samples subnets of
+that prefix length instead of addresses.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.Random.Validate(); err != nil {
+			return err
+		}
+		prefix, err := config.Random.Prefix()
+		if err != nil {
+			return err
+		}
+
+		opts := cidr.RandomOptions{
+			Count:  config.Random.Count,
+			Prefix: prefix,
+			Usable: config.Random.Usable,
+		}
+		if cmd.Flags().Changed("seed") {
+			opts.Rand = rand.New(rand.NewPCG(config.Random.Seed, 0))
+		}
+
+		cidrStr, err := cidrArg(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to sample: %w", err)
+		}
+		samples, err := cidr.Random(cidrStr, opts)
+		if err != nil {
+			return fmt.Errorf("failed to sample: %w", err)
+		}
+
+		for _, sample := range samples {
+			fmt.Println(sample)
+		}
+		return nil
+	},
+}
+
+func init() {
+	CidrCmd.AddCommand(randomCmd)
+
+	randomCmd.Flags().IntVarP(&config.Random.Count, "count", "n", 1, "Number of unique addresses or subnets to print")
+	randomCmd.Flags().Uint64Var(&config.Random.Seed, "seed", 0, "Seed for a repeatable sample (default: random)")
+	randomCmd.Flags().StringVar(&config.Random.Size, "size", "", "Sample subnets of this prefix length instead of addresses, e.g. /24")
+	randomCmd.Flags().BoolVar(&config.Random.Usable, "usable", false, "Exclude the network and broadcast addresses")
+}
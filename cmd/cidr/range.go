@@ -0,0 +1,61 @@
+package cidr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+)
+
+// rangeCmd represents the range command
+var rangeCmd = &cobra.Command{
+	Use:   "range <START> <END>",
+	Short: "Convert an IP address range to CIDR blocks",
+	Long: `Range prints the fewest CIDR blocks that cover every address from START to END
+inclusive. The range can also be given as one START-END argument, the form
+firewall exports commonly use.
+
+With --to-range, the single argument is a CIDR and its first and last
+addresses are printed as START-END instead.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if config.Range.ToRange {
+			if len(args) != 1 {
+				return errcode.Errorf(errcode.CLIUsage, "--to-range takes a single CIDR")
+			}
+			first, last, err := cidr.CIDRToRange(args[0])
+			if err != nil {
+				return fmt.Errorf("failed to convert CIDR: %w", err)
+			}
+			fmt.Printf("%s-%s\n", first, last)
+			return nil
+		}
+
+		start, end := "", ""
+		if len(args) == 2 {
+			start, end = args[0], args[1]
+		} else {
+			var ok bool
+			if start, end, ok = strings.Cut(args[0], "-"); !ok {
+				return errcode.Errorf(errcode.CLIUsage, "expected START END or START-END, got %q", args[0])
+			}
+		}
+
+		prefixes, err := cidr.RangeToCIDRs(start, end)
+		if err != nil {
+			return fmt.Errorf("failed to convert range: %w", err)
+		}
+		for _, prefix := range prefixes {
+			fmt.Println(prefix)
+		}
+		return nil
+	},
+}
+
+func init() {
+	CidrCmd.AddCommand(rangeCmd)
+
+	rangeCmd.Flags().BoolVar(&config.Range.ToRange, "to-range", false, "Print the first and last address of a CIDR instead")
+}
@@ -0,0 +1,35 @@
+package cidr
+
+import (
+	"fmt"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/spf13/cobra"
+)
+
+// subtractCmd represents the subtract command
+var subtractCmd = &cobra.Command{
+	Use:   "subtract <BASE> <EXCLUDE...>",
+	Short: "Remove CIDR ranges from a base range",
+	Long: `Subtract removes one or more CIDR ranges or addresses from a base range and
+prints the fewest CIDR blocks that cover the remaining space, in address order.
+
+Exclusions may overlap each other or extend past the base range. Both IPv4 and
+IPv6 are supported; exclusions of the other address family remove nothing.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		remaining, err := cidr.Subtract(args[0], args[1:])
+		if err != nil {
+			return fmt.Errorf("failed to subtract: %w", err)
+		}
+
+		for _, prefix := range remaining {
+			fmt.Println(prefix)
+		}
+		return nil
+	},
+}
+
+func init() {
+	CidrCmd.AddCommand(subtractCmd)
+}
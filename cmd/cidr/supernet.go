@@ -0,0 +1,52 @@
+package cidr
+
+import (
+	"fmt"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+)
+
+// supernetCmd represents the supernet command
+var supernetCmd = &cobra.Command{
+	Use:   "supernet <CIDR...>",
+	Short: "Find the smallest prefix covering every range",
+	Long: `Supernet prints the smallest single CIDR block that contains every given CIDR
+and address. Unlike aggregate, which covers the inputs exactly with as many
+blocks as it takes, supernet always prints one block, covering any gaps
+between the inputs too. IPv4 and IPv6 inputs each get their own supernet,
+IPv4 first.
+
+--max-waste refuses to print a supernet when more than that percentage of
+its addresses lie outside every input, such as when summarizing two distant
+/24s would advertise a /8.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.Supernet.Validate(); err != nil {
+			return err
+		}
+
+		supernets, err := cidr.Supernet(args)
+		if err != nil {
+			return fmt.Errorf("failed to find supernet: %w", err)
+		}
+		for _, supernet := range supernets {
+			if waste := supernet.Waste(); waste > config.Supernet.MaxWaste {
+				return errcode.Errorf(errcode.CIDRTooLarge, "supernet %s is %s%% unused (%s of %s addresses), over --max-waste %g%%",
+					supernet.Prefix, supernet.FormatWaste(), cidr.FormatBigInt(supernet.Unused()), cidr.FormatBigInt(supernet.Total), config.Supernet.MaxWaste)
+			}
+		}
+
+		for _, supernet := range supernets {
+			fmt.Println(supernet.Prefix)
+		}
+		return nil
+	},
+}
+
+func init() {
+	CidrCmd.AddCommand(supernetCmd)
+
+	supernetCmd.Flags().Float64Var(&config.Supernet.MaxWaste, "max-waste", 100, "Refuse a supernet with more than this percentage of its addresses outside the inputs")
+}
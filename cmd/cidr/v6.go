@@ -0,0 +1,90 @@
+package cidr
+
+import (
+	"fmt"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/spf13/cobra"
+)
+
+// v6Cmd groups the IPv6-specific helpers
+var v6Cmd = &cobra.Command{
+	Use:   "v6",
+	Short: "IPv6 nibble splitting and reverse zones",
+	Long: `IPv6 helpers for planning delegations.
+
+Reverse DNS for IPv6 lives under ip6.arpa, one label per 4-bit nibble, so
+prefixes can only be delegated, and reverse zones only cut, at lengths that
+are a multiple of 4: /32, /36, /40, /44, /48, and so on.`,
+}
+
+// splitByNibbleCmd represents the v6 split-by-nibble command
+var splitByNibbleCmd = &cobra.Command{
+	Use:   "split-by-nibble <CIDR>",
+	Short: "Split an IPv6 prefix along nibble boundaries",
+	Long: `Split-by-nibble prints every subnet of an IPv6 prefix at a nibble-aligned
+length, the units it can be delegated in. By default it splits at the next
+boundary: a prefix that is not nibble-aligned is rounded up to one, so a /46
+gives four /48s, and an aligned prefix is split by one nibble, so a /48 gives
+sixteen /52s. --prefix picks the length instead, and --zones prints each
+subnet's ip6.arpa zone beside it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.V6.Validate(); err != nil {
+			return err
+		}
+
+		network, err := cidrArg(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to split prefix: %w", err)
+		}
+		subnets, err := cidr.SplitByNibble(network, config.V6.Prefix)
+		if err != nil {
+			return fmt.Errorf("failed to split prefix: %w", err)
+		}
+		for _, subnet := range subnets {
+			if config.V6.Zones {
+				fmt.Printf("%s %s\n", subnet, cidr.ReverseZone(subnet))
+				continue
+			}
+			fmt.Println(subnet)
+		}
+		return nil
+	},
+}
+
+// reverseZonesCmd represents the v6 reverse-zones command
+var reverseZonesCmd = &cobra.Command{
+	Use:   "reverse-zones <CIDR>...",
+	Short: "Print the ip6.arpa zones for IPv6 prefixes",
+	Long: `Reverse-zones prints the ip6.arpa reverse DNS zones that hold the PTR records
+of each IPv6 prefix: the prefix's own zone when it is nibble-aligned,
+otherwise one zone per subnet at the next nibble boundary, since a /47 has
+no zone of its own and is served as two /48 zones.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, arg := range args {
+			network, err := cidrArg(arg)
+			if err != nil {
+				return fmt.Errorf("failed to find reverse zones: %w", err)
+			}
+			zones, err := cidr.ReverseZones(network)
+			if err != nil {
+				return fmt.Errorf("failed to find reverse zones: %w", err)
+			}
+			for _, zone := range zones {
+				fmt.Println(zone)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	CidrCmd.AddCommand(v6Cmd)
+	v6Cmd.AddCommand(splitByNibbleCmd)
+	v6Cmd.AddCommand(reverseZonesCmd)
+
+	splitByNibbleCmd.Flags().IntVarP(&config.V6.Prefix, "prefix", "p", 0, "Split into subnets of this nibble-aligned prefix length (default: the next nibble boundary)")
+	splitByNibbleCmd.Flags().BoolVar(&config.V6.Zones, "zones", false, "Print each subnet's ip6.arpa reverse zone beside it")
+}
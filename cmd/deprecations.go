@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	auditlog "github.com/euan-cowie/cidrator/internal/audit"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+)
+
+// deprecatedFlag is a flag kept working under its old name until Removal so
+// scripts have time to move to Replacement
+type deprecatedFlag struct {
+	Command     string // Path below root; a persistent flag covers its subcommands
+	Flag        string
+	Replacement string
+	Since       string // Release month the flag was deprecated, YYYY-MM
+	Removal     string // Earliest release month it may be removed, YYYY-MM
+}
+
+// deprecatedFlags lists every deprecated flag. Keep the table in
+// docs/DEPRECATIONS.md in step with it.
+var deprecatedFlags = []deprecatedFlag{
+	{Command: "mtu", Flag: "json", Replacement: "--format json", Since: "2026-10", Removal: "2027-04"},
+	{Command: "bench self", Flag: "json", Replacement: "--format json", Since: "2026-10", Removal: "2027-04"},
+}
+
+// warningEnvelope is the JSON shape of a deprecation warning when JSON output
+// was requested
+type warningEnvelope struct {
+	Warning warningDetail `json:"warning"`
+}
+
+type warningDetail struct {
+	Code        errcode.Code `json:"code"`
+	Message     string       `json:"message"`
+	Flag        string       `json:"flag"`
+	Replacement string       `json:"replacement"`
+	Removal     string       `json:"removal"`
+}
+
+// configureDeprecations marks each deprecated flag in its help text. The
+// flags stay visible so existing users can still look them up.
+func configureDeprecations(root *cobra.Command) {
+	for _, deprecated := range deprecatedFlags {
+		cmd := mustFindCommand(root, deprecated.Command)
+		flag := cmd.Flags().Lookup(deprecated.Flag)
+		if flag == nil {
+			flag = cmd.PersistentFlags().Lookup(deprecated.Flag)
+		}
+		if flag == nil {
+			panic(fmt.Sprintf("deprecations: %s has no --%s flag", deprecated.Command, deprecated.Flag))
+		}
+		flag.Usage += fmt.Sprintf(" (deprecated, removed after %s: use %s)", deprecated.Removal, deprecated.Replacement)
+	}
+}
+
+// warnDeprecated prints one warning line on stderr for each deprecated flag
+// cmd was given and records their use in the audit log. The command still
+// runs; an audit log that cannot be written only adds a warning.
+func warnDeprecated(cmd *cobra.Command) {
+	path := strings.TrimSpace(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()))
+	var used []string
+	for _, deprecated := range deprecatedFlags {
+		if path != deprecated.Command && !strings.HasPrefix(path, deprecated.Command+" ") {
+			continue
+		}
+		if flag := cmd.Flags().Lookup(deprecated.Flag); flag == nil || !flag.Changed {
+			continue
+		}
+		writeDeprecationWarning(cmd.ErrOrStderr(), wantsJSON(cmd), deprecated)
+		used = append(used, "--"+deprecated.Flag)
+	}
+	if len(used) == 0 {
+		return
+	}
+
+	entry := auditlog.Entry{Command: cmd.CommandPath(), Deprecated: used}
+	if err := auditlog.Record(entry); err != nil {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %v\n", err)
+	}
+}
+
+// writeDeprecationWarning prints a single-line warning, as JSON when the
+// command's output is JSON so stderr stays machine-readable
+func writeDeprecationWarning(w io.Writer, asJSON bool, deprecated deprecatedFlag) {
+	message := fmt.Sprintf("--%s is deprecated since %s and will be removed after %s; use %s",
+		deprecated.Flag, deprecated.Since, deprecated.Removal, deprecated.Replacement)
+
+	if asJSON {
+		envelope := warningEnvelope{Warning: warningDetail{
+			Code:        errcode.CLIDeprecated,
+			Message:     message,
+			Flag:        "--" + deprecated.Flag,
+			Replacement: deprecated.Replacement,
+			Removal:     deprecated.Removal,
+		}}
+		if encoded, err := json.Marshal(envelope); err == nil {
+			_, _ = fmt.Fprintln(w, string(encoded))
+			return
+		}
+	}
+	_, _ = fmt.Fprintf(w, "Warning [%s]: %s\n", errcode.CLIDeprecated, message)
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	auditlog "github.com/euan-cowie/cidrator/internal/audit"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// runWithDeprecations runs args against a fresh "group run" whose persistent
+// --json flag is deprecated, returning what was written to stderr
+func runWithDeprecations(t *testing.T, args ...string) string {
+	t.Helper()
+	original := deprecatedFlags
+	t.Cleanup(func() { deprecatedFlags = original })
+	deprecatedFlags = []deprecatedFlag{{Command: "group", Flag: "json", Replacement: "--format json", Since: "2026-10", Removal: "2027-04"}}
+
+	root := &cobra.Command{
+		Use:               "cidrator",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { warnDeprecated(cmd); return nil },
+	}
+	group := &cobra.Command{Use: "group"}
+	group.PersistentFlags().Bool("json", false, "Same as --format json")
+	group.PersistentFlags().String("format", "table", "")
+	group.AddCommand(&cobra.Command{Use: "run", RunE: func(cmd *cobra.Command, args []string) error { return nil }})
+	root.AddCommand(group)
+	configureDeprecations(root)
+	if usage := group.PersistentFlags().Lookup("json").Usage; !strings.Contains(usage, "deprecated, removed after 2027-04: use --format json") {
+		t.Errorf("usage = %q, want the removal date and replacement", usage)
+	}
+
+	var stderr bytes.Buffer
+	root.SetErr(&stderr)
+	root.SetArgs(args)
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	return stderr.String()
+}
+
+func TestWarnDeprecated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	// Execute runs initConfig, which takes the audit log path from viper
+	viper.Set("audit-log", path)
+	t.Cleanup(func() {
+		viper.Set("audit-log", "")
+		auditlog.SetPath("")
+	})
+
+	if stderr := runWithDeprecations(t, "group", "run"); stderr != "" {
+		t.Fatalf("unexpected warning without the flag: %q", stderr)
+	}
+
+	stderr := runWithDeprecations(t, "group", "run", "--json=false", "--format", "yaml")
+	want := "Warning [CLI010]: --json is deprecated since 2026-10 and will be removed after 2027-04; use --format json\n"
+	if stderr != want {
+		t.Fatalf("stderr = %q, want %q", stderr, want)
+	}
+
+	// With JSON output the warning is one JSON line too
+	stderr = runWithDeprecations(t, "group", "run", "--json")
+	var envelope warningEnvelope
+	if strings.Count(stderr, "\n") != 1 || json.Unmarshal([]byte(stderr), &envelope) != nil ||
+		envelope.Warning.Code != errcode.CLIDeprecated || envelope.Warning.Flag != "--json" || envelope.Warning.Removal != "2027-04" {
+		t.Fatalf("stderr = %q, want a JSON warning", stderr)
+	}
+
+	entries, err := auditlog.ReadAll(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[1].Command != "cidrator group run" || !slices.Equal(entries[1].Deprecated, []string{"--json"}) {
+		t.Fatalf("audit entries = %+v, want one per run with the flag", entries)
+	}
+}
+
+func TestDeprecatedFlagsExist(t *testing.T) {
+	for _, deprecated := range deprecatedFlags {
+		cmd := mustFindCommand(rootCmd, deprecated.Command)
+		if cmd.Flags().Lookup(deprecated.Flag) == nil && cmd.PersistentFlags().Lookup(deprecated.Flag) == nil {
+			t.Errorf("%s has no --%s flag", deprecated.Command, deprecated.Flag)
+		}
+		if deprecated.Removal <= deprecated.Since {
+			t.Errorf("%s --%s: removal %s is not after %s", deprecated.Command, deprecated.Flag, deprecated.Removal, deprecated.Since)
+		}
+	}
+}
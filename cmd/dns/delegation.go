@@ -0,0 +1,166 @@
+package dns
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/audit"
+	"github.com/euan-cowie/cidrator/internal/dns"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/units"
+	"github.com/spf13/cobra"
+)
+
+var dnsCheckDelegation = dns.CheckDelegation
+
+// delegationCmd represents the dns delegation command
+var delegationCmd = &cobra.Command{
+	Use:   "delegation <domain>",
+	Short: "Check parent/child NS and glue consistency for a zone",
+	Long: `Delegation compares the NS set published by the parent zone with the NS set
+served by the zone itself, verifies glue A/AAAA records against authoritative
+answers, and measures each nameserver's reachability and response time.
+
+Nameservers that respond without the authoritative flag are flagged as lame.
+
+--dump-wire keeps every raw response message for later analysis of resolver
+quirks such as case randomization, EDNS handling, or padding. JSON and YAML
+output embed them base64-encoded; --dump-wire-dir writes each one to its own
+.bin file. Only the delegation check uses the raw DNS client, so it is the only
+command with these flags.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDelegation,
+}
+
+func init() {
+	DNSCmd.AddCommand(delegationCmd)
+
+	delegationCmd.Flags().StringP("format", "f", "table", "Output format (table, json, yaml)")
+	units.Duration(delegationCmd.Flags(), "timeout", 5*time.Second, "Per-query timeout")
+	delegationCmd.Flags().Bool("dump-wire", false, "Include raw response messages (base64) in the output")
+	delegationCmd.Flags().String("dump-wire-dir", "", "Write raw response messages to .bin files in this directory")
+}
+
+func runDelegation(cmd *cobra.Command, args []string) error {
+	domain := args[0]
+
+	format, _ := cmd.Flags().GetString("format")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	dumpWire, _ := cmd.Flags().GetBool("dump-wire")
+	dumpWireDir, _ := cmd.Flags().GetString("dump-wire-dir")
+
+	// The number of queries depends on the referral, so only the target is logged
+	if err := audit.Record(audit.Entry{Command: cmd.CommandPath(), Targets: []string{domain}, Protocol: "dns"}); err != nil {
+		return err
+	}
+
+	result, err := dnsCheckDelegation(domain, dns.DelegationOptions{
+		Timeout:     timeout,
+		CaptureWire: dumpWire || dumpWireDir != "",
+		CheckServer: func(server string) error { return checkServers(cmd, server) },
+	})
+	if err != nil {
+		return err
+	}
+
+	if dumpWireDir != "" {
+		paths, err := dns.WriteWireResponses(dumpWireDir, result.WireResponses)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %d raw responses to %s\n", len(paths), dumpWireDir)
+		if !dumpWire {
+			result.WireResponses = nil
+		}
+	}
+
+	return outputDelegationResult(cmd.OutOrStdout(), result, format)
+}
+
+func outputDelegationResult(w io.Writer, result *dns.DelegationResult, format string) error {
+	switch format {
+	case "json":
+		output, err := result.ToJSON()
+		if err != nil {
+			return fmt.Errorf("failed to generate JSON: %v", err)
+		}
+		_, _ = fmt.Fprintln(w, output)
+	case "yaml":
+		output, err := result.ToYAML()
+		if err != nil {
+			return fmt.Errorf("failed to generate YAML: %v", err)
+		}
+		_, _ = fmt.Fprint(w, output)
+	case "table":
+		outputDelegationTable(w, result)
+	default:
+		return errcode.Errorf(errcode.CLIUnsupportedFormat, "unsupported output format: %s", format)
+	}
+	return nil
+}
+
+func outputDelegationTable(w io.Writer, result *dns.DelegationResult) {
+	_, _ = fmt.Fprintf(w, "Domain: %s\n", result.Domain)
+	_, _ = fmt.Fprintf(w, "Parent Zone: %s (via %s)\n", result.ParentZone, result.ParentServer)
+	_, _ = fmt.Fprintf(w, "Parent NS: %s\n", strings.Join(result.ParentNS, ", "))
+	_, _ = fmt.Fprintf(w, "Child NS: %s\n", strings.Join(result.ChildNS, ", "))
+	_, _ = fmt.Fprintf(w, "Query Time: %v\n\n", result.QueryTime.Round(time.Millisecond))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "NAMESERVER\tGLUE\tRESPONSE\tSTATUS\n")
+	_, _ = fmt.Fprintf(tw, "----------\t----\t--------\t------\n")
+	for _, ns := range result.Nameservers {
+		glue := "-"
+		if len(ns.Glue) > 0 {
+			glue = "ok"
+			if !ns.GlueMatches {
+				glue = "mismatch"
+			}
+		}
+
+		response := "-"
+		status := "unreachable"
+		switch {
+		case ns.Reachable && ns.Lame:
+			response = ns.ResponseTime.Round(time.Millisecond).String()
+			status = "lame"
+		case ns.Reachable:
+			response = ns.ResponseTime.Round(time.Millisecond).String()
+			status = "ok"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", ns.Host, glue, response, status)
+	}
+	_ = tw.Flush()
+
+	_, _ = fmt.Fprintln(w)
+	if result.Consistent() {
+		_, _ = fmt.Fprintln(w, "Delegation is consistent.")
+	} else {
+		_, _ = fmt.Fprintln(w, "Issues:")
+		for _, issue := range result.Issues {
+			_, _ = fmt.Fprintf(w, "  - %s\n", issue)
+		}
+	}
+
+	if len(result.WireResponses) > 0 {
+		_, _ = fmt.Fprintln(w)
+		outputWireTable(w, result.WireResponses)
+	}
+}
+
+func outputWireTable(w io.Writer, responses []dns.WireResponse) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer func() { _ = tw.Flush() }()
+
+	_, _ = fmt.Fprintf(tw, "SERVER\tQUERY\tTRANSPORT\tSIZE\tRAW (BASE64)\n")
+	_, _ = fmt.Fprintf(tw, "------\t-----\t---------\t----\t------------\n")
+	for _, resp := range responses {
+		_, _ = fmt.Fprintf(tw, "%s\t%s %s\t%s\t%d\t%s\n",
+			resp.Server, resp.Name, strings.TrimPrefix(resp.Type, "Type"), resp.Transport, len(resp.Raw),
+			base64.StdEncoding.EncodeToString(resp.Raw))
+	}
+}
@@ -1,6 +1,7 @@
 package dns
 
 import (
+	"github.com/euan-cowie/cidrator/internal/policy"
 	"github.com/spf13/cobra"
 )
 
@@ -11,5 +12,21 @@ var DNSCmd = &cobra.Command{
 	Long: `Forward and reverse DNS lookup tools.
 
 Use this command group to query common record types, direct queries to a
-specific resolver, and inspect PTR records for IPv4 or IPv6 addresses.`,
+specific resolver, inspect PTR records for IPv4 or IPv6 addresses, audit
+reverse DNS coverage for a CIDR range, check that a zone's delegation is
+consistent between parent and child, and watch a name for answer changes.`,
+}
+
+// checkServers refuses to query servers in networks forbidden by policy, or
+// in documentation ranges without --allow-doc-ranges. Every command that
+// sends queries calls it first; an empty server stands for the system
+// resolver and is not checked.
+func checkServers(cmd *cobra.Command, servers ...string) error {
+	var hosts []string
+	for _, server := range servers {
+		if server != "" {
+			hosts = append(hosts, server)
+		}
+	}
+	return policy.CheckTargets(cmd.Context(), cmd.Flags(), hosts...)
 }
@@ -2,12 +2,20 @@ package dns
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/euan-cowie/cidrator/internal/audit"
 	internaldns "github.com/euan-cowie/cidrator/internal/dns"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/notify"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 )
@@ -19,6 +27,7 @@ func newLookupTestCommand(out *bytes.Buffer) *cobra.Command {
 	cmd.Flags().StringP("format", "f", "table", "Output format")
 	cmd.Flags().StringP("server", "s", "", "DNS server")
 	cmd.Flags().Duration("timeout", 5*time.Second, "Query timeout")
+	cmd.Flags().Bool("fail-on-error", false, "Fail if any record type failed")
 	return cmd
 }
 
@@ -166,6 +175,108 @@ func TestRunLookupUsesFlagsAndWriter(t *testing.T) {
 	}
 }
 
+func TestQueriesRefuseDocumentationServers(t *testing.T) {
+	original := dnsLookup
+	t.Cleanup(func() { dnsLookup = original })
+	called := false
+	dnsLookup = func(domain string, opts internaldns.LookupOptions) (*internaldns.DNSResult, error) {
+		called = true
+		return &internaldns.DNSResult{Domain: domain, QueryType: opts.RecordType}, nil
+	}
+
+	var out bytes.Buffer
+	cmd := newLookupTestCommand(&out)
+	cmd.SetArgs([]string{"example.com", "--server", "192.0.2.53"})
+	if err := cmd.Execute(); errcode.Of(err) != errcode.CLIDocumentationRange {
+		t.Fatalf("expected CLI011 for a documentation-range server, got %v", err)
+	}
+	if called {
+		t.Fatal("the query was sent despite the refused server")
+	}
+
+	cmd = newLookupTestCommand(&out)
+	cmd.Flags().Bool("allow-doc-ranges", false, "Allow documentation ranges")
+	cmd.SetArgs([]string{"example.com", "--server", "192.0.2.53", "--allow-doc-ranges"})
+	if err := cmd.Execute(); err != nil || !called {
+		t.Fatalf("expected --allow-doc-ranges to permit the query, got %v", err)
+	}
+
+	originalDelegation := dnsCheckDelegation
+	t.Cleanup(func() { dnsCheckDelegation = originalDelegation })
+	var checkServer func(string) error
+	dnsCheckDelegation = func(domain string, opts internaldns.DelegationOptions) (*internaldns.DelegationResult, error) {
+		checkServer = opts.CheckServer
+		return &internaldns.DelegationResult{Domain: domain}, nil
+	}
+	delegation := &cobra.Command{Use: "delegation <domain>", RunE: runDelegation}
+	delegation.SetOut(&out)
+	delegation.Flags().StringP("format", "f", "table", "Output format")
+	delegation.SetArgs([]string{"example.com"})
+	if err := delegation.Execute(); err != nil {
+		t.Fatalf("delegation command failed: %v", err)
+	}
+	if checkServer == nil || checkServer("198.51.100.1:53") == nil || checkServer("9.9.9.9:53") != nil {
+		t.Fatal("expected delegation to refuse documentation-range nameservers only")
+	}
+}
+
+func TestRunLookupFailOnError(t *testing.T) {
+	original := dnsLookup
+	t.Cleanup(func() { dnsLookup = original })
+
+	dnsLookup = func(domain string, opts internaldns.LookupOptions) (*internaldns.DNSResult, error) {
+		return &internaldns.DNSResult{
+			Domain:    domain,
+			QueryType: opts.RecordType,
+			Records:   []internaldns.DNSRecord{{Type: "A", Value: "192.0.2.10"}},
+			Statuses: []internaldns.TypeStatus{
+				{Type: "A", Status: internaldns.LookupStatusOK, Records: 1},
+				{Type: "MX", Status: internaldns.LookupStatusNXDomain},
+				{Type: "TXT", Status: internaldns.LookupStatusTimeout, Error: "i/o timeout"},
+			},
+		}, nil
+	}
+
+	t.Run("default reports statuses without failing", func(t *testing.T) {
+		var out bytes.Buffer
+		cmd := newLookupTestCommand(&out)
+		cmd.SetArgs([]string{"example.com", "--type", "ALL"})
+
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("lookup command failed: %v", err)
+		}
+		for _, want := range []string{"STATUS", "MX    nxdomain", "TXT   timeout"} {
+			if !strings.Contains(out.String(), want) {
+				t.Fatalf("expected %q in output, got %q", want, out.String())
+			}
+		}
+	})
+
+	t.Run("fail-on-error", func(t *testing.T) {
+		var out bytes.Buffer
+		cmd := newLookupTestCommand(&out)
+		cmd.SetArgs([]string{"example.com", "--type", "ALL", "--format", "json", "--fail-on-error"})
+
+		err := cmd.Execute()
+		if err == nil || !strings.Contains(err.Error(), "1 of 3 record types failed for example.com: TXT (timeout)") {
+			t.Fatalf("expected partial failure error, got %v", err)
+		}
+		if errcode.Of(err) != errcode.DNSTimeout {
+			t.Fatalf("unexpected error code: %s", errcode.Of(err))
+		}
+
+		var payload struct {
+			Statuses []internaldns.TypeStatus `json:"statuses"`
+		}
+		if err := json.Unmarshal(out.Bytes(), &payload); err != nil {
+			t.Fatalf("expected result to be written before failing: %v", err)
+		}
+		if len(payload.Statuses) != 3 {
+			t.Fatalf("unexpected statuses: %+v", payload.Statuses)
+		}
+	})
+}
+
 func TestOutputReverseResult(t *testing.T) {
 	result := &internaldns.ReverseResult{
 		IP:        "192.0.2.10",
@@ -260,3 +371,583 @@ func TestRunReverseUsesFlagsAndWriter(t *testing.T) {
 		t.Fatalf("expected reverse command output to contain hostname, got %q", out.String())
 	}
 }
+
+func TestLookupAndReverseRecordAudit(t *testing.T) {
+	originalLookup, originalReverse := dnsLookup, dnsReverseLookup
+	t.Cleanup(func() { dnsLookup, dnsReverseLookup = originalLookup, originalReverse })
+	dnsLookup = func(domain string, opts internaldns.LookupOptions) (*internaldns.DNSResult, error) {
+		return &internaldns.DNSResult{Domain: domain, QueryType: opts.RecordType}, nil
+	}
+	dnsReverseLookup = func(ip string, timeout time.Duration) (*internaldns.ReverseResult, error) {
+		return &internaldns.ReverseResult{IP: ip}, nil
+	}
+
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	audit.SetPath(auditPath)
+	t.Cleanup(func() { audit.SetPath("") })
+
+	var out bytes.Buffer
+	lookup := newLookupTestCommand(&out)
+	lookup.Flags().Bool("ttl-warnings", false, "Warn about TTLs")
+	lookup.Flags().Duration("low-ttl", internaldns.DefaultLowTTL, "Low TTL threshold")
+	lookup.SetArgs([]string{"example.com", "--type", "all", "--server", "9.9.9.9", "--ttl-warnings"})
+	if err := lookup.Execute(); err != nil {
+		t.Fatalf("lookup command failed: %v", err)
+	}
+	reverse := newReverseTestCommand(&out)
+	reverse.SetArgs([]string{"9.9.9.9"})
+	if err := reverse.Execute(); err != nil {
+		t.Fatalf("reverse command failed: %v", err)
+	}
+
+	entries, err := audit.ReadAll(auditPath)
+	if err != nil {
+		t.Fatalf("ReadAll returned error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected two audit entries, got %+v", entries)
+	}
+	if got := entries[0]; got.Command != "lookup" || strings.Join(got.Targets, ",") != "example.com,9.9.9.9" || got.Protocol != "dns" || got.Packets != 12 {
+		t.Fatalf("unexpected lookup entry: %+v", got)
+	}
+	if got := entries[1]; got.Command != "reverse" || strings.Join(got.Targets, ",") != "9.9.9.9" || got.Packets != 1 {
+		t.Fatalf("unexpected reverse entry: %+v", got)
+	}
+}
+
+func TestOutputDelegationResult(t *testing.T) {
+	result := &internaldns.DelegationResult{
+		Domain:       "example.com",
+		ParentZone:   "com",
+		ParentServer: "a.gtld-servers.net",
+		ParentNS:     []string{"ns1.example.com", "ns2.example.com"},
+		ChildNS:      []string{"ns1.example.com"},
+		Nameservers: []internaldns.NameserverCheck{
+			{Host: "ns1.example.com", Glue: []string{"192.0.2.53"}, GlueMatches: true, Reachable: true, ResponseTime: 12 * time.Millisecond},
+			{Host: "ns2.example.com", GlueMatches: true, Reachable: true, Lame: true, ResponseTime: 30 * time.Millisecond},
+		},
+		Issues: []string{"lame delegation: ns2.example.com does not answer authoritatively for example.com"},
+	}
+
+	t.Run("table", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := outputDelegationResult(&out, result, "table"); err != nil {
+			t.Fatalf("outputDelegationResult returned error: %v", err)
+		}
+
+		output := out.String()
+		expected := []string{"Parent Zone: com (via a.gtld-servers.net)", "ns2.example.com", "lame", "Issues:"}
+		for _, fragment := range expected {
+			if !strings.Contains(output, fragment) {
+				t.Fatalf("expected delegation table output to contain %q, got %q", fragment, output)
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := outputDelegationResult(&out, result, "json"); err != nil {
+			t.Fatalf("outputDelegationResult returned error: %v", err)
+		}
+
+		var payload struct {
+			Consistent  bool `json:"consistent"`
+			Nameservers []struct {
+				Host           string `json:"host"`
+				Lame           bool   `json:"lame"`
+				ResponseTimeMS int64  `json:"response_time_ms"`
+			} `json:"nameservers"`
+		}
+		if err := json.Unmarshal(out.Bytes(), &payload); err != nil {
+			t.Fatalf("invalid JSON output: %v", err)
+		}
+		if payload.Consistent || len(payload.Nameservers) != 2 || !payload.Nameservers[1].Lame || payload.Nameservers[0].ResponseTimeMS != 12 {
+			t.Fatalf("unexpected JSON payload: %+v", payload)
+		}
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		var out bytes.Buffer
+		err := outputDelegationResult(&out, result, "xml")
+		if err == nil || !strings.Contains(err.Error(), "unsupported output format") {
+			t.Fatalf("expected unsupported format error, got %v", err)
+		}
+	})
+}
+
+func TestRunDelegationUsesFlagsAndWriter(t *testing.T) {
+	original := dnsCheckDelegation
+	t.Cleanup(func() { dnsCheckDelegation = original })
+
+	var gotDomain string
+	var gotOpts internaldns.DelegationOptions
+	dnsCheckDelegation = func(domain string, opts internaldns.DelegationOptions) (*internaldns.DelegationResult, error) {
+		gotDomain = domain
+		gotOpts = opts
+		return &internaldns.DelegationResult{Domain: domain, ParentZone: "com"}, nil
+	}
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{Use: "delegation <domain>", RunE: runDelegation}
+	cmd.SetOut(&out)
+	cmd.Flags().StringP("format", "f", "table", "Output format")
+	cmd.Flags().Duration("timeout", 5*time.Second, "Query timeout")
+	cmd.SetArgs([]string{"example.com", "--format", "yaml", "--timeout", "2s"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("delegation command failed: %v", err)
+	}
+	if gotDomain != "example.com" || gotOpts.Timeout != 2*time.Second {
+		t.Fatalf("unexpected delegation inputs: domain=%q opts=%+v", gotDomain, gotOpts)
+	}
+	if !strings.Contains(out.String(), "consistent: true") {
+		t.Fatalf("expected YAML output, got %q", out.String())
+	}
+}
+
+func TestRunDelegationDumpWire(t *testing.T) {
+	original := dnsCheckDelegation
+	t.Cleanup(func() { dnsCheckDelegation = original })
+
+	var gotOpts internaldns.DelegationOptions
+	dnsCheckDelegation = func(domain string, opts internaldns.DelegationOptions) (*internaldns.DelegationResult, error) {
+		gotOpts = opts
+		return &internaldns.DelegationResult{
+			Domain:     domain,
+			ParentZone: "com",
+			WireResponses: []internaldns.WireResponse{
+				{Server: "192.0.2.53:53", Name: "example.com.", Type: "TypeNS", Transport: "udp", Raw: []byte{0x12, 0x34}},
+			},
+		}, nil
+	}
+
+	dir := filepath.Join(t.TempDir(), "wire")
+	var out, errOut bytes.Buffer
+	cmd := &cobra.Command{Use: "delegation <domain>", RunE: runDelegation}
+	cmd.SetOut(&out)
+	cmd.SetErr(&errOut)
+	cmd.Flags().StringP("format", "f", "table", "Output format")
+	cmd.Flags().Duration("timeout", 5*time.Second, "Query timeout")
+	cmd.Flags().Bool("dump-wire", false, "Include raw responses")
+	cmd.Flags().String("dump-wire-dir", "", "Write raw responses")
+	cmd.SetArgs([]string{"example.com", "--format", "json", "--dump-wire-dir", dir})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("delegation command failed: %v", err)
+	}
+	if !gotOpts.CaptureWire {
+		t.Fatal("expected --dump-wire-dir to enable wire capture")
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, "001-example.com-NS-192.0.2.53_53-udp.bin"))
+	if err != nil {
+		t.Fatalf("expected wire dump file: %v", err)
+	}
+	if !bytes.Equal(raw, []byte{0x12, 0x34}) {
+		t.Fatalf("unexpected wire dump contents: %x", raw)
+	}
+	if strings.Contains(out.String(), "wire_responses") {
+		t.Fatalf("expected raw responses only on disk without --dump-wire, got %q", out.String())
+	}
+	if !strings.Contains(errOut.String(), "Wrote 1 raw responses") {
+		t.Fatalf("expected a note about written files, got %q", errOut.String())
+	}
+}
+
+func TestOutputPTRAuditTable(t *testing.T) {
+	result := &internaldns.PTRAuditResult{
+		CIDR:         "192.0.2.0/30",
+		ExpectDomain: "example.net",
+		Entries: []internaldns.PTRAuditEntry{
+			{IP: "192.0.2.0", Hostnames: []string{"a.example.net"}, HasPTR: true, ForwardConfirmed: true, DomainMatch: true},
+			{IP: "192.0.2.1", Hostnames: []string{"b.other.test"}, HasPTR: true, ForwardConfirmed: false},
+			{IP: "192.0.2.2", Hostnames: []string{}},
+		},
+		Summary: internaldns.PTRAuditSummary{
+			Total: 3, WithPTR: 2, MissingPTR: 1, FCrDNSFailed: 1, DomainMismatch: 1,
+			Gaps:    []string{"192.0.2.2"},
+			Latency: internaldns.LatencyStats{Count: 3, P50MS: 12, P90MS: 20, P99MS: 20, MaxMS: 20},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := outputPTRAuditResult(&out, result, "table", false); err != nil {
+		t.Fatalf("outputPTRAuditResult returned error: %v", err)
+	}
+
+	output := out.String()
+	for _, fragment := range []string{"Missing PTR: 1", "Latency: p50 12.0ms", "Gaps:", "192.0.2.2", "b.other.test", "FCrDNS failed, domain mismatch"} {
+		if !strings.Contains(output, fragment) {
+			t.Fatalf("expected ptr-audit table output to contain %q, got %q", fragment, output)
+		}
+	}
+	if strings.Contains(output, "a.example.net") {
+		t.Fatalf("expected passing addresses to be hidden without --all, got %q", output)
+	}
+
+	out.Reset()
+	if err := outputPTRAuditResult(&out, result, "table", true); err != nil {
+		t.Fatalf("outputPTRAuditResult returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "a.example.net") {
+		t.Fatalf("expected --all to list passing addresses, got %q", out.String())
+	}
+}
+
+func TestRunPTRAuditUsesFlags(t *testing.T) {
+	original := dnsAuditPTR
+	t.Cleanup(func() { dnsAuditPTR = original })
+
+	var gotCIDR string
+	var gotOpts internaldns.PTRAuditOptions
+	var gotDeadline bool
+	dnsAuditPTR = func(ctx context.Context, cidr string, opts internaldns.PTRAuditOptions) (*internaldns.PTRAuditResult, error) {
+		gotCIDR = cidr
+		gotOpts = opts
+		_, gotDeadline = ctx.Deadline()
+		return &internaldns.PTRAuditResult{CIDR: cidr}, nil
+	}
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{Use: "ptr-audit <CIDR>", RunE: runPTRAudit}
+	cmd.SetOut(&out)
+	cmd.Flags().StringP("format", "f", "table", "Output format")
+	cmd.Flags().String("expect-domain", "", "Expected domain")
+	cmd.Flags().Duration("timeout", 5*time.Second, "Query timeout")
+	cmd.Flags().Int("concurrency", 16, "Concurrency")
+	cmd.Flags().Int("max-addresses", 65536, "Max addresses")
+	cmd.Flags().Bool("all", false, "Show all")
+	cmd.Flags().DurationSlice("latency-buckets", internaldns.DefaultLatencyBuckets, "Latency buckets")
+	cmd.Flags().Duration("deadline", 0, "Deadline")
+	cmd.Flags().String("resume", "", "Resume token")
+	cmd.Flags().StringSlice("server", nil, "Servers")
+	cmd.Flags().Float64("qps", 0, "QPS")
+	cmd.Flags().Int("server-concurrency", 0, "Per-server concurrency")
+	cmd.Flags().Int("retries", 2, "Retries")
+	cmd.SetArgs([]string{"203.0.113.0/24", "--expect-domain", "example.net", "--concurrency", "4", "--format", "json"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("ptr-audit command failed: %v", err)
+	}
+	if gotCIDR != "203.0.113.0/24" || gotOpts.ExpectDomain != "example.net" || gotOpts.Concurrency != 4 || gotOpts.MaxAddresses != 65536 {
+		t.Fatalf("unexpected ptr-audit inputs: cidr=%q opts=%+v", gotCIDR, gotOpts)
+	}
+	if !strings.Contains(out.String(), `"cidr": "203.0.113.0/24"`) {
+		t.Fatalf("expected JSON output, got %q", out.String())
+	}
+
+	cmd.SetArgs([]string{"203.0.113.0/24", "--latency-buckets", "10ms,50ms,1s"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("ptr-audit command failed: %v", err)
+	}
+	if len(gotOpts.LatencyBuckets) != 3 || gotOpts.LatencyBuckets[2] != time.Second {
+		t.Fatalf("unexpected latency buckets: %v", gotOpts.LatencyBuckets)
+	}
+	if gotDeadline {
+		t.Fatal("expected no deadline without --deadline")
+	}
+
+	cmd.SetArgs([]string{"203.0.113.0/24", "--deadline", "1m", "--resume", "203.0.113.40"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("ptr-audit command failed: %v", err)
+	}
+	if !gotDeadline || gotOpts.Resume != "203.0.113.40" {
+		t.Fatalf("expected --deadline and --resume to reach the audit: deadline=%v opts=%+v", gotDeadline, gotOpts)
+	}
+	if len(gotOpts.Servers) != 0 || gotOpts.Throttle.Retries != 2 {
+		t.Fatalf("expected the system resolver without --server: %+v", gotOpts)
+	}
+
+	cmd.SetArgs([]string{"203.0.113.0/24", "--resume", "", "--server", "1.1.1.1", "--server", "8.8.8.8", "--qps", "25", "--server-concurrency", "4", "--retries", "1"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("ptr-audit command failed: %v", err)
+	}
+	throttle := gotOpts.Throttle
+	if len(gotOpts.Servers) != 2 || gotOpts.Servers[1] != "8.8.8.8" || throttle.QPS != 25 || throttle.Concurrency != 4 || throttle.Retries != 1 {
+		t.Fatalf("expected server flags to reach the audit: %+v", gotOpts)
+	}
+
+	cmd.SetArgs([]string{"203.0.113.0/24", "--concurrency", "0"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected invalid concurrency error")
+	}
+
+	cmd.SetArgs([]string{"203.0.113.0/24", "--concurrency", "4", "--latency-buckets", "50ms,10ms"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "strictly ascending") {
+		t.Fatalf("expected bucket ordering error, got %v", err)
+	}
+
+	cmd.SetArgs([]string{"203.0.113.0/24", "--latency-buckets", "10ms", "--qps", "-1"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--qps") {
+		t.Fatalf("expected invalid --qps error, got %v", err)
+	}
+}
+
+func TestOutputPTRAuditResultInterrupted(t *testing.T) {
+	result := &internaldns.PTRAuditResult{
+		CIDR:        "192.0.2.0/24",
+		Entries:     []internaldns.PTRAuditEntry{{IP: "192.0.2.0"}, {IP: "192.0.2.1"}},
+		Interrupted: true,
+		Remaining:   254,
+		ResumeToken: "192.0.2.2",
+	}
+
+	var out bytes.Buffer
+	if err := outputPTRAuditResult(&out, result, "table", false); err != nil {
+		t.Fatalf("outputPTRAuditResult returned error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Interrupted: 2 of 256 addresses audited; resume with --resume 192.0.2.2") {
+		t.Fatalf("expected interruption notice, got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := outputPTRAuditResult(&out, result, "json", false); err != nil {
+		t.Fatalf("outputPTRAuditResult returned error: %v", err)
+	}
+	var doc struct {
+		Interrupted bool   `json:"interrupted"`
+		Completed   int    `json:"completed"`
+		Remaining   int    `json:"remaining"`
+		ResumeToken string `json:"resume_token"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("interrupted JSON does not parse: %v\n%s", err, out.String())
+	}
+	if !doc.Interrupted || doc.Completed != 2 || doc.Remaining != 254 || doc.ResumeToken != "192.0.2.2" {
+		t.Fatalf("unexpected interrupted document: %+v", doc)
+	}
+}
+
+func TestOutputPTRAuditServerStats(t *testing.T) {
+	result := &internaldns.PTRAuditResult{
+		CIDR: "192.0.2.0/30",
+		Summary: internaldns.PTRAuditSummary{
+			Total: 4,
+			Servers: []internaldns.ServerStats{
+				{Server: "1.1.1.1:53", Queries: 10, Answered: 6, ServFail: 3, Refused: 1, Backoffs: 4, BackoffMS: 1500, MeanMS: 12.5},
+				{Server: "8.8.8.8:53", Queries: 6, Answered: 6, MeanMS: 20},
+			},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := outputPTRAuditResult(&out, result, "table", false); err != nil {
+		t.Fatalf("outputPTRAuditResult returned error: %v", err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	var throttled, clean string
+	for _, line := range lines {
+		switch {
+		case strings.Contains(line, "1.1.1.1:53"):
+			throttled = line
+		case strings.Contains(line, "8.8.8.8:53"):
+			clean = line
+		}
+	}
+	if !strings.Contains(out.String(), "Servers:") || !strings.HasSuffix(throttled, "throttled") ||
+		!strings.Contains(throttled, "4x 1.5s") || strings.Contains(clean, "throttled") {
+		t.Fatalf("unexpected server section:\n%s", out.String())
+	}
+}
+
+func TestRunPTRAuditDryRun(t *testing.T) {
+	original := dnsAuditPTR
+	t.Cleanup(func() { dnsAuditPTR = original })
+	dnsAuditPTR = func(ctx context.Context, cidr string, opts internaldns.PTRAuditOptions) (*internaldns.PTRAuditResult, error) {
+		t.Fatal("dry run must not run the audit")
+		return nil, nil
+	}
+
+	var out bytes.Buffer
+	cmd := &cobra.Command{Use: "ptr-audit <CIDR>", RunE: runPTRAudit}
+	cmd.SetOut(&out)
+	cmd.Flags().StringP("format", "f", "table", "Output format")
+	cmd.Flags().String("expect-domain", "", "Expected domain")
+	cmd.Flags().Duration("timeout", 5*time.Second, "Query timeout")
+	cmd.Flags().Int("concurrency", 16, "Concurrency")
+	cmd.Flags().Int("max-addresses", 65536, "Max addresses")
+	cmd.Flags().Bool("all", false, "Show all")
+	cmd.Flags().DurationSlice("latency-buckets", internaldns.DefaultLatencyBuckets, "Latency buckets")
+	cmd.Flags().Bool("dry-run", false, "Dry run")
+	cmd.SetArgs([]string{"203.0.113.0/28", "--dry-run"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("ptr-audit dry run failed: %v", err)
+	}
+	for _, fragment := range []string{
+		"Dry run: no queries will be sent",
+		"Targets: 16",
+		"  - 203.0.113.9",
+		"  ... and 6 more",
+		"PTR Queries: 16",
+		"Estimated Duration: up to 5s",
+	} {
+		if !strings.Contains(out.String(), fragment) {
+			t.Fatalf("expected output to contain %q, got:\n%s", fragment, out.String())
+		}
+	}
+}
+
+func newWatchTestCommand(out *bytes.Buffer) *cobra.Command {
+	cmd := &cobra.Command{Use: "watch <domain>", RunE: runWatch}
+	cmd.SetOut(out)
+	cmd.SetErr(out)
+	cmd.Flags().StringP("type", "t", "A", "DNS record type")
+	cmd.Flags().StringP("format", "f", "table", "Output format")
+	cmd.Flags().StringP("server", "s", "", "DNS server")
+	cmd.Flags().Duration("timeout", 5*time.Second, "Query timeout")
+	cmd.Flags().Duration("interval", 30*time.Second, "Interval between queries")
+	cmd.Flags().Int("count", 0, "Stop after this many queries")
+	cmd.Flags().String("webhook", "", "Webhook URL")
+	cmd.Flags().Bool("syslog", false, "Log to syslog")
+	cmd.Flags().Bool("ttl-warnings", false, "Warn about TTLs")
+	cmd.Flags().Duration("low-ttl", internaldns.DefaultLowTTL, "Low TTL threshold")
+	cmd.Flags().Bool("allow-doc-ranges", false, "Allow documentation ranges")
+	return cmd
+}
+
+// stubObservations makes dnsObserve return answers in turn, one per poll
+func stubObservations(t *testing.T, answers ...[]string) *internaldns.WatchOptions {
+	t.Helper()
+	original := dnsObserve
+	t.Cleanup(func() { dnsObserve = original })
+
+	var got internaldns.WatchOptions
+	poll := 0
+	dnsObserve = func(ctx context.Context, domain string, opts internaldns.WatchOptions) (*internaldns.Observation, error) {
+		got = opts
+		current := answers[min(poll, len(answers)-1)]
+		poll++
+		if current == nil {
+			return nil, internaldns.NewDNSError("watch", domain, context.DeadlineExceeded)
+		}
+		return &internaldns.Observation{
+			Time:         time.Date(2026, 1, 2, 3, 4, poll, 0, time.UTC),
+			Domain:       domain,
+			RecordType:   strings.ToUpper(opts.RecordType),
+			Server:       "192.0.2.53:53",
+			Status:       internaldns.LookupStatusOK,
+			Answers:      current,
+			TTLFloor:     60,
+			Latency:      5 * time.Millisecond,
+			LatencyClass: internaldns.LatencyFast,
+		}, nil
+	}
+	return &got
+}
+
+func TestRunWatchJSONMarksChanges(t *testing.T) {
+	opts := stubObservations(t, []string{"192.0.2.1"}, []string{"192.0.2.1"}, []string{"192.0.2.2"})
+
+	var out bytes.Buffer
+	cmd := newWatchTestCommand(&out)
+	cmd.SetArgs([]string{"example.com", "--type", "aaaa", "--server", "192.0.2.53", "--allow-doc-ranges", "--format", "json", "--interval", "1ms", "--count", "3"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("watch returned error: %v", err)
+	}
+	if opts.RecordType != "aaaa" || opts.Server != "192.0.2.53" {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected one JSON line per poll, got %q", out.String())
+	}
+	var last struct {
+		Changed bool     `json:"changed"`
+		Changes []string `json:"changes"`
+		Added   []string `json:"added"`
+		Removed []string `json:"removed"`
+		Answers []string `json:"answers"`
+	}
+	if err := json.Unmarshal([]byte(lines[2]), &last); err != nil {
+		t.Fatal(err)
+	}
+	if !last.Changed || last.Changes[0] != "answers" || last.Added[0] != "192.0.2.2" || last.Removed[0] != "192.0.2.1" {
+		t.Errorf("unexpected change line: %s", lines[2])
+	}
+	if strings.Contains(lines[1], `"changed":true`) {
+		t.Errorf("an unchanged poll was marked changed: %s", lines[1])
+	}
+}
+
+func TestRunWatchAlertsWebhookOnChange(t *testing.T) {
+	stubObservations(t, []string{"192.0.2.1"}, nil, []string{"192.0.2.9"})
+
+	var events []notify.Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event notify.Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("invalid webhook body: %v", err)
+		}
+		events = append(events, event)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	cmd := newWatchTestCommand(&out)
+	cmd.SetArgs([]string{"example.com", "--server", "192.0.2.53", "--allow-doc-ranges", "--interval", "1ms", "--count", "3", "--webhook", server.URL})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("watch returned error: %v", err)
+	}
+
+	output := out.String()
+	if !strings.Contains(output, "Error [DNS005]") {
+		t.Errorf("expected the timed out poll to be reported, got %q", output)
+	}
+	if !strings.Contains(output, "! 192.0.2.9") || !strings.Contains(output, "+192.0.2.9 -192.0.2.1") {
+		t.Errorf("expected the change to be marked, got %q", output)
+	}
+	if len(events) != 1 || events[0].Source != "dns watch" || events[0].Target != "example.com A" {
+		t.Fatalf("expected one webhook alert, got %+v", events)
+	}
+}
+
+func TestRunWatchTTLWarnings(t *testing.T) {
+	stubObservations(t, []string{"192.0.2.1"}, []string{"192.0.2.1"})
+
+	var events []notify.Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event notify.Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("invalid webhook body: %v", err)
+		}
+		events = append(events, event)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	cmd := newWatchTestCommand(&out)
+	cmd.SetArgs([]string{"example.com", "--server", "192.0.2.53", "--allow-doc-ranges", "--format", "json", "--interval", "1ms", "--count", "2",
+		"--ttl-warnings", "--low-ttl", "90s", "--webhook", server.URL})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("watch returned error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], `"warnings":[{"kind":"low-ttl","ttl":60,"message":"ttl 60s is under 1m30s"}]`) {
+		t.Fatalf("expected a low-ttl warning on every poll, got %q", out.String())
+	}
+	if len(events) != 1 || events[0].Summary != "ttl 60s is under 1m30s" {
+		t.Fatalf("expected one alert when the warning was first raised, got %+v", events)
+	}
+}
+
+func TestRunWatchRejectsBadFlags(t *testing.T) {
+	stubObservations(t, []string{"192.0.2.1"})
+
+	for _, args := range [][]string{
+		{"example.com", "--type", "ALL"},
+		{"example.com", "--format", "yaml"},
+		{"example.com", "--interval", "0s"},
+		{"example.com", "--ttl-warnings", "--low-ttl", "0s"},
+		{"example.com", "--webhook", "ftp://hooks.example.com"},
+	} {
+		var out bytes.Buffer
+		cmd := newWatchTestCommand(&out)
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err == nil {
+			t.Errorf("expected %v to fail", args)
+		}
+	}
+}
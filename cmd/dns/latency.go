@@ -0,0 +1,36 @@
+package dns
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/dns"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// validateLatencyBuckets rejects histogram bounds that are not positive and strictly ascending
+func validateLatencyBuckets(buckets []time.Duration) error {
+	for i, bound := range buckets {
+		if bound <= 0 {
+			return errcode.Errorf(errcode.CLIUsage, "--latency-buckets values must be positive, got %v", bound)
+		}
+		if i > 0 && bound <= buckets[i-1] {
+			return errcode.Errorf(errcode.CLIUsage, "--latency-buckets must be strictly ascending, got %v after %v", bound, buckets[i-1])
+		}
+	}
+	return nil
+}
+
+// outputLatencyLine prints a one-line percentile summary for table output
+func outputLatencyLine(w io.Writer, stats dns.LatencyStats) {
+	if stats.Count == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "Latency: p50 %.1fms, p90 %.1fms, p99 %.1fms, max %.1fms",
+		stats.P50MS, stats.P90MS, stats.P99MS, stats.MaxMS)
+	if stats.Timeouts > 0 {
+		_, _ = fmt.Fprintf(w, " (%d timeouts)", stats.Timeouts)
+	}
+	_, _ = fmt.Fprintln(w)
+}
@@ -7,7 +7,10 @@ import (
 	"text/tabwriter"
 	"time"
 
+	"github.com/euan-cowie/cidrator/internal/audit"
 	"github.com/euan-cowie/cidrator/internal/dns"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/units"
 	"github.com/spf13/cobra"
 )
 
@@ -21,12 +24,17 @@ var lookupCmd = &cobra.Command{
 
 Supports multiple record types: A, AAAA, MX, TXT, CNAME, NS, and ALL.
 
-Examples:
-  cidrator dns lookup example.com
-  cidrator dns lookup example.com --type MX
-  cidrator dns lookup example.com --type AAAA --format json
-  cidrator dns lookup example.com --type ALL
-  cidrator dns lookup example.com --server 8.8.8.8`,
+ALL queries every type and reports a status for each one (ok, nxdomain,
+timeout, or servfail), so an empty section can be told apart from a failed
+query. By default a failed type does not fail the command; pass
+--fail-on-error to exit non-zero when any type timed out or failed.
+
+--ttl-warnings sends one more query per record type straight to --server (or
+the first nameserver in /etc/resolv.conf) to read TTLs, and warns when a TTL
+is under --low-ttl (default 30s), which often means a failover is planned or
+under way. A caching resolver counts TTLs down, so a low TTL from one can
+also be a copy about to expire; point --server at one of the zone's
+nameservers for exact TTLs.`,
 	Args: cobra.ExactArgs(1),
 	RunE: runLookup,
 }
@@ -38,7 +46,10 @@ func init() {
 	lookupCmd.Flags().StringP("type", "t", "A", "DNS record type (A, AAAA, MX, TXT, CNAME, NS, ALL)")
 	lookupCmd.Flags().StringP("format", "f", "table", "Output format (table, json, yaml)")
 	lookupCmd.Flags().StringP("server", "s", "", "DNS server to query (e.g., 8.8.8.8)")
-	lookupCmd.Flags().DurationP("timeout", "", 5*time.Second, "Query timeout")
+	units.Duration(lookupCmd.Flags(), "timeout", 5*time.Second, "Query timeout")
+	lookupCmd.Flags().Bool("fail-on-error", false, "With --type ALL, exit non-zero if any record type timed out or failed")
+	lookupCmd.Flags().Bool("ttl-warnings", false, "Query TTLs and warn about unusually low ones")
+	units.Duration(lookupCmd.Flags(), "low-ttl", dns.DefaultLowTTL, "With --ttl-warnings, flag TTLs under this")
 }
 
 func runLookup(cmd *cobra.Command, args []string) error {
@@ -49,12 +60,38 @@ func runLookup(cmd *cobra.Command, args []string) error {
 	format, _ := cmd.Flags().GetString("format")
 	server, _ := cmd.Flags().GetString("server")
 	timeout, _ := cmd.Flags().GetDuration("timeout")
+	failOnError, _ := cmd.Flags().GetBool("fail-on-error")
+	checkTTL, _ := cmd.Flags().GetBool("ttl-warnings")
+	lowTTL, _ := cmd.Flags().GetDuration("low-ttl")
+
+	if checkTTL && lowTTL <= 0 {
+		return errcode.Errorf(errcode.CLIUsage, "--low-ttl must be positive")
+	}
+
+	if err := checkServers(cmd, server); err != nil {
+		return err
+	}
 
 	// Create lookup options
 	opts := dns.LookupOptions{
 		RecordType: strings.ToUpper(recordType),
 		Server:     server,
 		Timeout:    timeout,
+		CheckTTL:   checkTTL,
+		LowTTL:     lowTTL,
+	}
+
+	targets := []string{domain}
+	if server != "" {
+		targets = append(targets, server)
+	}
+	if err := audit.Record(audit.Entry{
+		Command:  cmd.CommandPath(),
+		Targets:  targets,
+		Protocol: "dns",
+		Packets:  dns.LookupQueries(opts),
+	}); err != nil {
+		return err
 	}
 
 	// Perform lookup
@@ -64,7 +101,34 @@ func runLookup(cmd *cobra.Command, args []string) error {
 	}
 
 	// Output result
-	return outputLookupResult(cmd.OutOrStdout(), result, format)
+	if err := outputLookupResult(cmd.OutOrStdout(), result, format); err != nil {
+		return err
+	}
+
+	if err := partialLookupError(result); failOnError && err != nil {
+		cmd.SilenceUsage = true
+		return err
+	}
+	return nil
+}
+
+// partialLookupError summarizes the record types an ALL lookup could not answer
+func partialLookupError(result *dns.DNSResult) error {
+	failed := result.FailedTypes()
+	if len(failed) == 0 {
+		return nil
+	}
+
+	code := errcode.DNSQueryFailed
+	parts := make([]string, len(failed))
+	for i, status := range failed {
+		parts[i] = fmt.Sprintf("%s (%s)", status.Type, status.Status)
+		if status.Status == dns.LookupStatusTimeout {
+			code = errcode.DNSTimeout
+		}
+	}
+	return errcode.Errorf(code, "%d of %d record types failed for %s: %s",
+		len(failed), len(result.Statuses), result.Domain, strings.Join(parts, ", "))
 }
 
 func outputLookupResult(w io.Writer, result *dns.DNSResult, format string) error {
@@ -84,7 +148,7 @@ func outputLookupResult(w io.Writer, result *dns.DNSResult, format string) error
 	case "table":
 		outputLookupTable(w, result)
 	default:
-		return fmt.Errorf("unsupported output format: %s", format)
+		return errcode.Errorf(errcode.CLIUnsupportedFormat, "unsupported output format: %s", format)
 	}
 	return nil
 }
@@ -99,15 +163,34 @@ func outputLookupTable(w io.Writer, result *dns.DNSResult) {
 
 	if len(result.Records) == 0 {
 		_, _ = fmt.Fprintln(w, "No records found.")
-		return
+	} else {
+		outputRecordsTable(w, result.Records)
+	}
+
+	if len(result.Statuses) > 0 {
+		_, _ = fmt.Fprintln(w)
+		outputStatusTable(w, result.Statuses)
+	}
+
+	if len(result.TTLWarnings) > 0 {
+		_, _ = fmt.Fprintln(w)
+		for _, warning := range result.TTLWarnings {
+			if warning.Type != "" {
+				_, _ = fmt.Fprintf(w, "Warning (%s): %s\n", warning.Type, warning.Message)
+			} else {
+				_, _ = fmt.Fprintf(w, "Warning: %s\n", warning.Message)
+			}
+		}
 	}
+}
 
+func outputRecordsTable(w io.Writer, records []dns.DNSRecord) {
 	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
 	defer func() { _ = tw.Flush() }()
 
 	// Check if any MX records exist (to show priority column)
 	hasMX := false
-	for _, r := range result.Records {
+	for _, r := range records {
 		if r.Type == "MX" {
 			hasMX = true
 			break
@@ -117,7 +200,7 @@ func outputLookupTable(w io.Writer, result *dns.DNSResult) {
 	if hasMX {
 		_, _ = fmt.Fprintf(tw, "TYPE\tPRIORITY\tVALUE\n")
 		_, _ = fmt.Fprintf(tw, "----\t--------\t-----\n")
-		for _, r := range result.Records {
+		for _, r := range records {
 			if r.Type == "MX" {
 				_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\n", r.Type, r.Priority, r.Value)
 			} else {
@@ -127,8 +210,19 @@ func outputLookupTable(w io.Writer, result *dns.DNSResult) {
 	} else {
 		_, _ = fmt.Fprintf(tw, "TYPE\tVALUE\n")
 		_, _ = fmt.Fprintf(tw, "----\t-----\n")
-		for _, r := range result.Records {
+		for _, r := range records {
 			_, _ = fmt.Fprintf(tw, "%s\t%s\n", r.Type, r.Value)
 		}
 	}
 }
+
+func outputStatusTable(w io.Writer, statuses []dns.TypeStatus) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer func() { _ = tw.Flush() }()
+
+	_, _ = fmt.Fprintf(tw, "TYPE\tSTATUS\tRECORDS\n")
+	_, _ = fmt.Fprintf(tw, "----\t------\t-------\n")
+	for _, status := range statuses {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\n", status.Type, status.Status, status.Records)
+	}
+}
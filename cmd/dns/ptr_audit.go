@@ -0,0 +1,341 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/audit"
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/dns"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/policy"
+	"github.com/euan-cowie/cidrator/internal/units"
+	"github.com/spf13/cobra"
+)
+
+var dnsAuditPTR = dns.AuditPTR
+var dnsPlanPTRAudit = dns.PlanPTRAudit
+
+// ptrAuditCmd represents the dns ptr-audit command
+var ptrAuditCmd = &cobra.Command{
+	Use:   "ptr-audit <CIDR>",
+	Short: "Audit reverse DNS coverage and FCrDNS for a CIDR range",
+	Long: `PTR-audit checks every address in a CIDR range for a PTR record, verifies
+that each PTR hostname forward-confirms back to the same address (FCrDNS),
+and summarizes the gaps.
+
+With --expect-domain, PTR hostnames must also sit under the given domain.
+
+Queries go to the system resolver by default. With --server, they are
+spread across the given resolvers instead, each capped by --qps and
+--server-concurrency. A resolver that answers SERVFAIL or REFUSED, or stops
+answering, is paused with a jittered exponential backoff while the failures
+continue, and the query is retried (--retries) on the next resolver. The
+summary then shows per-server counts, so you can see who throttled the run.
+Setting --qps or --server-concurrency without --server applies the caps to
+the first nameserver in /etc/resolv.conf.
+
+Ctrl+C or --deadline stops the audit early without losing it: the report
+covers every address finished so far, is marked interrupted, and gives the
+counts of completed and remaining addresses and a resume token. Pass the
+token to --resume to audit the rest of the range.`,
+	Args:        cobra.ExactArgs(1),
+	RunE:        runPTRAudit,
+	Annotations: map[string]string{"dry-run": "supported"},
+}
+
+func init() {
+	DNSCmd.AddCommand(ptrAuditCmd)
+
+	ptrAuditCmd.Flags().StringP("format", "f", "table", "Output format (table, json, yaml)")
+	ptrAuditCmd.Flags().String("expect-domain", "", "Domain every PTR hostname should belong to")
+	units.Duration(ptrAuditCmd.Flags(), "timeout", 5*time.Second, "Per-address query timeout")
+	ptrAuditCmd.Flags().Int("concurrency", 16, "Number of addresses audited in parallel")
+	ptrAuditCmd.Flags().Int("max-addresses", 65536, "Refuse ranges with more addresses than this (0 = no limit)")
+	ptrAuditCmd.Flags().Bool("all", false, "List passing addresses in table output as well as problems")
+	ptrAuditCmd.Flags().DurationSlice("latency-buckets", dns.DefaultLatencyBuckets, "Latency histogram bucket upper bounds for the summary")
+	units.Duration(ptrAuditCmd.Flags(), "deadline", 0, "Stop and report what was audited after this long (0 = no limit)")
+	ptrAuditCmd.Flags().String("resume", "", "Resume token from an interrupted audit of the same range")
+	ptrAuditCmd.Flags().StringSliceP("server", "s", nil, "Resolver to spread queries across (repeatable; default: system resolver)")
+	ptrAuditCmd.Flags().Float64("qps", 0, "Queries per second per server (0 = no cap)")
+	ptrAuditCmd.Flags().Int("server-concurrency", 0, "Queries in flight per server (0 = no cap)")
+	ptrAuditCmd.Flags().Int("retries", 2, "Retries of a query after SERVFAIL, REFUSED, or a timeout, each on the next server")
+}
+
+func runPTRAudit(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	expectDomain, _ := cmd.Flags().GetString("expect-domain")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	maxAddresses, _ := cmd.Flags().GetInt("max-addresses")
+	showAll, _ := cmd.Flags().GetBool("all")
+	buckets, _ := cmd.Flags().GetDurationSlice("latency-buckets")
+	deadline, _ := cmd.Flags().GetDuration("deadline")
+	resume, _ := cmd.Flags().GetString("resume")
+	servers, _ := cmd.Flags().GetStringSlice("server")
+	qps, _ := cmd.Flags().GetFloat64("qps")
+	serverConcurrency, _ := cmd.Flags().GetInt("server-concurrency")
+	retries, _ := cmd.Flags().GetInt("retries")
+
+	if concurrency <= 0 {
+		return errcode.Errorf(errcode.CLIUsage, "--concurrency must be positive")
+	}
+	if maxAddresses < 0 {
+		return errcode.Errorf(errcode.CLIUsage, "--max-addresses must be non-negative")
+	}
+	if deadline < 0 {
+		return errcode.Errorf(errcode.CLIUsage, "--deadline must be non-negative")
+	}
+	if qps < 0 || serverConcurrency < 0 || retries < 0 {
+		return errcode.Errorf(errcode.CLIUsage, "--qps, --server-concurrency, and --retries must be non-negative")
+	}
+	if len(servers) == 0 && (qps > 0 || serverConcurrency > 0) {
+		server, err := dns.SystemNameserver()
+		if err != nil {
+			return err
+		}
+		servers = []string{server}
+	}
+	if err := validateLatencyBuckets(buckets); err != nil {
+		return err
+	}
+
+	opts := dns.PTRAuditOptions{
+		ExpectDomain:   expectDomain,
+		Timeout:        timeout,
+		Concurrency:    concurrency,
+		MaxAddresses:   maxAddresses,
+		LatencyBuckets: buckets,
+		Resume:         resume,
+		Servers:        servers,
+		Throttle: dns.ThrottleOptions{
+			Concurrency: serverConcurrency,
+			QPS:         qps,
+			Retries:     retries,
+		},
+	}
+
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		plan, err := dnsPlanPTRAudit(args[0], opts)
+		if err != nil {
+			return err
+		}
+		return outputPTRAuditPlan(cmd.OutOrStdout(), plan, format)
+	}
+
+	if err := policy.CheckPrefix(args[0]); err != nil {
+		return err
+	}
+	if err := checkServers(cmd, opts.Servers...); err != nil {
+		return err
+	}
+	if err := recordPTRAudit(cmd, args[0]); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+	}
+
+	result, err := dnsAuditPTR(ctx, args[0], opts)
+	if err != nil {
+		return err
+	}
+
+	return outputPTRAuditResult(cmd.OutOrStdout(), result, format, showAll)
+}
+
+func outputPTRAuditResult(w io.Writer, result *dns.PTRAuditResult, format string, showAll bool) error {
+	switch format {
+	case "json":
+		output, err := result.ToJSON()
+		if err != nil {
+			return fmt.Errorf("failed to generate JSON: %v", err)
+		}
+		_, _ = fmt.Fprintln(w, output)
+	case "yaml":
+		output, err := result.ToYAML()
+		if err != nil {
+			return fmt.Errorf("failed to generate YAML: %v", err)
+		}
+		_, _ = fmt.Fprint(w, output)
+	case "table":
+		outputPTRAuditTable(w, result, showAll)
+	default:
+		return errcode.Errorf(errcode.CLIUnsupportedFormat, "unsupported output format: %s", format)
+	}
+	return nil
+}
+
+func outputPTRAuditTable(w io.Writer, result *dns.PTRAuditResult, showAll bool) {
+	summary := result.Summary
+
+	_, _ = fmt.Fprintf(w, "CIDR: %s\n", result.CIDR)
+	if result.ExpectDomain != "" {
+		_, _ = fmt.Fprintf(w, "Expected Domain: %s\n", result.ExpectDomain)
+	}
+	_, _ = fmt.Fprintf(w, "Query Time: %v\n", result.QueryTime.Round(time.Millisecond))
+	if result.Interrupted {
+		_, _ = fmt.Fprintf(w, "Interrupted: %d of %d addresses audited; resume with --resume %s\n",
+			len(result.Entries), len(result.Entries)+result.Remaining, result.ResumeToken)
+	}
+	_, _ = fmt.Fprintln(w)
+
+	_, _ = fmt.Fprintf(w, "Addresses: %d\n", summary.Total)
+	_, _ = fmt.Fprintf(w, "With PTR: %d\n", summary.WithPTR)
+	_, _ = fmt.Fprintf(w, "Missing PTR: %d\n", summary.MissingPTR)
+	_, _ = fmt.Fprintf(w, "FCrDNS Failed: %d\n", summary.FCrDNSFailed)
+	if result.ExpectDomain != "" {
+		_, _ = fmt.Fprintf(w, "Domain Mismatch: %d\n", summary.DomainMismatch)
+	}
+	if summary.Errors > 0 {
+		_, _ = fmt.Fprintf(w, "Errors: %d\n", summary.Errors)
+	}
+	outputLatencyLine(w, summary.Latency)
+	outputServerStats(w, summary.Servers)
+
+	if len(summary.Gaps) > 0 {
+		_, _ = fmt.Fprintln(w, "\nGaps:")
+		for _, gap := range summary.Gaps {
+			_, _ = fmt.Fprintf(w, "  - %s\n", gap)
+		}
+	}
+
+	var rows []dns.PTRAuditEntry
+	for _, entry := range result.Entries {
+		if showAll || (entry.HasPTR && !entry.OK()) || entry.Error != "" {
+			rows = append(rows, entry)
+		}
+	}
+	if len(rows) == 0 {
+		return
+	}
+
+	_, _ = fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer func() { _ = tw.Flush() }()
+
+	_, _ = fmt.Fprintf(tw, "IP\tPTR\tSTATUS\n")
+	_, _ = fmt.Fprintf(tw, "--\t---\t------\n")
+	for _, entry := range rows {
+		ptr := strings.Join(entry.Hostnames, ", ")
+		if ptr == "" {
+			ptr = "-"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", entry.IP, ptr, ptrAuditStatus(entry))
+	}
+}
+
+// outputServerStats lists per-server counts from a throttled run
+func outputServerStats(w io.Writer, servers []dns.ServerStats) {
+	if len(servers) == 0 {
+		return
+	}
+
+	_, _ = fmt.Fprintln(w, "\nServers:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "  SERVER\tQUERIES\tANSWERED\tSERVFAIL\tREFUSED\tTIMEOUTS\tERRORS\tBACKOFF\tMEAN\n")
+	for _, server := range servers {
+		note := ""
+		if server.Throttled() {
+			note = "  throttled"
+		}
+		_, _ = fmt.Fprintf(tw, "  %s\t%d\t%d\t%d\t%d\t%d\t%d\t%dx %v\t%.1fms%s\n",
+			server.Server, server.Queries, server.Answered, server.ServFail, server.Refused, server.Timeouts,
+			server.Errors, server.Backoffs, time.Duration(server.BackoffMS*float64(time.Millisecond)).Round(time.Millisecond), server.MeanMS, note)
+	}
+	_ = tw.Flush()
+}
+
+// recordPTRAudit logs the invocation with one planned PTR query per address
+func recordPTRAudit(cmd *cobra.Command, cidrStr string) error {
+	if !audit.Enabled() {
+		return nil
+	}
+
+	entry := audit.Entry{Command: cmd.CommandPath(), Targets: []string{cidrStr}, Protocol: "dns"}
+	if count, err := cidr.Count(cidrStr); err == nil && count.IsInt64() && count.Int64() <= math.MaxInt32 {
+		entry.Packets = int(count.Int64())
+	}
+	return audit.Record(entry)
+}
+
+// dryRunTargetPreview caps the number of targets listed in table output
+const dryRunTargetPreview = 10
+
+func outputPTRAuditPlan(w io.Writer, plan *dns.PTRAuditPlan, format string) error {
+	switch format {
+	case "json":
+		output, err := plan.ToJSON()
+		if err != nil {
+			return fmt.Errorf("failed to generate JSON: %v", err)
+		}
+		_, _ = fmt.Fprintln(w, output)
+	case "yaml":
+		output, err := plan.ToYAML()
+		if err != nil {
+			return fmt.Errorf("failed to generate YAML: %v", err)
+		}
+		_, _ = fmt.Fprint(w, output)
+	case "table":
+		_, _ = fmt.Fprintln(w, "Dry run: no queries will be sent")
+		_, _ = fmt.Fprintf(w, "CIDR: %s\n", plan.CIDR)
+		if plan.ExpectDomain != "" {
+			_, _ = fmt.Fprintf(w, "Expected Domain: %s\n", plan.ExpectDomain)
+		}
+		_, _ = fmt.Fprintf(w, "Targets: %d\n", len(plan.Targets))
+		for i, target := range plan.Targets {
+			if i == dryRunTargetPreview {
+				_, _ = fmt.Fprintf(w, "  ... and %d more\n", len(plan.Targets)-i)
+				break
+			}
+			_, _ = fmt.Fprintf(w, "  - %s\n", target)
+		}
+		_, _ = fmt.Fprintf(w, "PTR Queries: %d (plus one forward lookup per PTR hostname found)\n", plan.PTRQueries)
+		_, _ = fmt.Fprintf(w, "Concurrency: %d\n", plan.Concurrency)
+		if len(plan.Servers) > 0 {
+			_, _ = fmt.Fprintf(w, "Servers: %s", strings.Join(plan.Servers, ", "))
+			if plan.QPS > 0 {
+				_, _ = fmt.Fprintf(w, " (at most %g queries/s each)", plan.QPS)
+			}
+			_, _ = fmt.Fprintln(w)
+		}
+		_, _ = fmt.Fprintf(w, "Estimated Duration: up to %v\n", plan.EstimatedDuration)
+	default:
+		return errcode.Errorf(errcode.CLIUnsupportedFormat, "unsupported output format: %s", format)
+	}
+	return nil
+}
+
+func ptrAuditStatus(entry dns.PTRAuditEntry) string {
+	if entry.Error != "" {
+		return "error: " + entry.Error
+	}
+	if !entry.HasPTR {
+		return "missing PTR"
+	}
+
+	var problems []string
+	if !entry.ForwardConfirmed {
+		problems = append(problems, "FCrDNS failed")
+	}
+	if !entry.DomainMatch {
+		problems = append(problems, "domain mismatch")
+	}
+	if len(problems) == 0 {
+		return "ok"
+	}
+	return strings.Join(problems, ", ")
+}
@@ -5,7 +5,12 @@ import (
 	"io"
 	"time"
 
+	"github.com/euan-cowie/cidrator/internal/audit"
 	"github.com/euan-cowie/cidrator/internal/dns"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/policy"
+	"github.com/euan-cowie/cidrator/internal/target"
+	"github.com/euan-cowie/cidrator/internal/units"
 	"github.com/spf13/cobra"
 )
 
@@ -18,11 +23,7 @@ var reverseCmd = &cobra.Command{
 	Long: `Reverse performs reverse DNS lookups for IP addresses.
 
 Returns the hostnames associated with the given IP address via PTR records.
-
-Examples:
-  cidrator dns reverse 8.8.8.8
-  cidrator dns reverse 2001:4860:4860::8888
-  cidrator dns reverse 8.8.8.8 --format json`,
+A hostname is resolved to its address first unless --strict-input is set.`,
 	Args: cobra.ExactArgs(1),
 	RunE: runReverse,
 }
@@ -32,16 +33,36 @@ func init() {
 
 	// Add flags for reverse lookup
 	reverseCmd.Flags().StringP("format", "f", "table", "Output format (table, json, yaml)")
-	reverseCmd.Flags().DurationP("timeout", "", 5*time.Second, "Query timeout")
+	units.Duration(reverseCmd.Flags(), "timeout", 5*time.Second, "Query timeout")
 }
 
 func runReverse(cmd *cobra.Command, args []string) error {
 	ip := args[0]
+	if kind, _ := target.Classify(ip); kind == target.KindHostname || kind == target.KindPrefix {
+		if err := target.AuditLookup(cmd.CommandPath(), ip); err != nil {
+			return err
+		}
+		addr, err := target.Addr(cmd.Context(), ip)
+		if err != nil {
+			return err
+		}
+		ip = addr.String()
+	}
 
 	// Get flags
 	format, _ := cmd.Flags().GetString("format")
 	timeout, _ := cmd.Flags().GetDuration("timeout")
 
+	// The query goes to the system resolver, so only the address looked up
+	// is checked against the networks policy forbids
+	if err := policy.CheckPrefix(ip); err != nil {
+		return err
+	}
+
+	if err := audit.Record(audit.Entry{Command: cmd.CommandPath(), Targets: []string{ip}, Protocol: "dns", Packets: 1}); err != nil {
+		return err
+	}
+
 	// Perform reverse lookup
 	result, err := dnsReverseLookup(ip, timeout)
 	if err != nil {
@@ -69,7 +90,7 @@ func outputReverseResult(w io.Writer, result *dns.ReverseResult, format string)
 	case "table":
 		outputReverseTable(w, result)
 	default:
-		return fmt.Errorf("unsupported output format: %s", format)
+		return errcode.Errorf(errcode.CLIUnsupportedFormat, "unsupported output format: %s", format)
 	}
 	return nil
 }
@@ -0,0 +1,359 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/audit"
+	"github.com/euan-cowie/cidrator/internal/dns"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/notify"
+	"github.com/euan-cowie/cidrator/internal/units"
+	"github.com/spf13/cobra"
+)
+
+var dnsObserve = dns.Observe
+
+// watchCmd represents the dns watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch <domain>",
+	Short: "Re-query a name every interval and alert on change",
+	Long: `Watch queries a name repeatedly and alerts when the answer set, the TTL
+floor, or the resolution latency class changes. Useful during migrations and
+failover tests to see exactly when a resolver starts handing out new answers.
+
+Each poll sends one recursive query to --server, or to the first nameserver in
+/etc/resolv.conf. The answer set is the sorted record values of the queried
+type; NXDOMAIN and SERVFAIL count as answers, so a name disappearing alerts
+too. The TTL floor is the lowest TTL in the answer section. Caching resolvers
+count TTLs down between polls, so TTL changes are only alerted when the
+answers are authoritative: point --server at one of the zone's nameservers to
+watch for TTL changes. Latency classes are fast (under 20ms), normal (under
+100ms), slow (under 500ms), and very-slow.
+
+--ttl-warnings also flags TTLs under --low-ttl (default 30s) and authoritative
+TTLs that drop to half or less between polls, both common signs of an
+unplanned failover. Behind a caching resolver the TTL checked is the one seen
+when the resolver last refreshed its copy, and drops are not checked.
+Warnings are printed with each poll, and a warning that was not present on
+the previous poll is alerted like a change.
+
+Every poll is printed; changes are marked with ! and also sent to --webhook
+(a JSON POST), the local syslog with --syslog, and the notifiers of the alerts
+section of the config file. --format json prints one JSON object per poll
+(NDJSON).`,
+	Args: cobra.ExactArgs(1),
+	RunE: runWatch,
+}
+
+func init() {
+	DNSCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().StringP("type", "t", "A", "DNS record type (A, AAAA, MX, TXT, CNAME, NS)")
+	watchCmd.Flags().StringP("format", "f", "table", "Output format (table, json)")
+	watchCmd.Flags().StringP("server", "s", "", "DNS server to query (default: first nameserver in /etc/resolv.conf)")
+	units.Duration(watchCmd.Flags(), "timeout", 5*time.Second, "Query timeout")
+	units.Duration(watchCmd.Flags(), "interval", 30*time.Second, "Interval between queries")
+	watchCmd.Flags().Int("count", 0, "Stop after this many queries (0 = until interrupted)")
+	watchCmd.Flags().String("webhook", "", "POST a JSON alert to this URL on every change")
+	watchCmd.Flags().Bool("syslog", false, "Also log changes to the local syslog")
+	watchCmd.Flags().Bool("ttl-warnings", false, "Warn about unusually low TTLs and sudden TTL drops")
+	units.Duration(watchCmd.Flags(), "low-ttl", dns.DefaultLowTTL, "With --ttl-warnings, flag TTLs under this")
+}
+
+// watchAlert is the Details of a dns watch alert event
+type watchAlert struct {
+	Changes  []string              `json:"changes"`
+	Added    []string              `json:"added,omitempty"`
+	Removed  []string              `json:"removed,omitempty"`
+	Warnings []dns.TTLWarning      `json:"warnings,omitempty"`
+	Previous *watchObservationJSON `json:"previous,omitempty"` // Unset on the first poll
+	Current  watchObservationJSON  `json:"current"`
+}
+
+type watchObservationJSON struct {
+	Status        string   `json:"status"`
+	Answers       []string `json:"answers"`
+	TTLFloor      uint32   `json:"ttl_floor"`
+	Authoritative bool     `json:"authoritative"`
+	LatencyMS     float64  `json:"latency_ms"`
+	LatencyClass  string   `json:"latency_class"`
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	domain := args[0]
+
+	recordType, _ := cmd.Flags().GetString("type")
+	format, _ := cmd.Flags().GetString("format")
+	server, _ := cmd.Flags().GetString("server")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	interval, _ := cmd.Flags().GetDuration("interval")
+	count, _ := cmd.Flags().GetInt("count")
+	checkTTL, _ := cmd.Flags().GetBool("ttl-warnings")
+	lowTTL, _ := cmd.Flags().GetDuration("low-ttl")
+
+	if format != "table" && format != "json" {
+		return errcode.Errorf(errcode.CLIUnsupportedFormat, "unsupported output format: %s", format)
+	}
+	if err := dns.CheckWatchType(recordType); err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return errcode.Errorf(errcode.CLIUsage, "--interval must be positive")
+	}
+	if count < 0 {
+		return errcode.Errorf(errcode.CLIUsage, "--count must be non-negative")
+	}
+	if checkTTL && lowTTL <= 0 {
+		return errcode.Errorf(errcode.CLIUsage, "--low-ttl must be positive")
+	}
+
+	if err := checkServers(cmd, server); err != nil {
+		return err
+	}
+
+	notifiers, closeNotifiers, err := notify.OpenFlags(cmd.Flags())
+	if err != nil {
+		return err
+	}
+	defer closeNotifiers()
+
+	if err := audit.Record(audit.Entry{
+		Command:    cmd.CommandPath(),
+		Targets:    []string{domain},
+		Protocol:   "dns",
+		Packets:    1,
+		IntervalMS: interval.Milliseconds(),
+	}); err != nil {
+		return err
+	}
+
+	opts := dns.WatchOptions{RecordType: recordType, Server: server, Timeout: timeout}
+	w := cmd.OutOrStdout()
+	if format == "table" {
+		_, _ = fmt.Fprintf(w, "Watching %s %s every %v...\n", strings.ToUpper(recordType), domain, interval)
+		_, _ = fmt.Fprintf(w, "Press Ctrl+C to stop\n\n")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var last *dns.Observation
+	var lastWarnings []dns.TTLWarning
+	monitor := dns.TTLMonitor{Low: lowTTL}
+	for polls := 1; ; polls++ {
+		queryCtx, cancel := context.WithTimeout(ctx, timeout)
+		obs, err := dnsObserve(queryCtx, domain, opts)
+		cancel()
+
+		switch {
+		case errcode.Of(err) == errcode.CLIUsage:
+			// Bad --type or no resolver: every later poll would fail the same way
+			return err
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil
+			}
+			if outErr := outputWatchError(w, format, time.Now(), domain, err); outErr != nil {
+				return outErr
+			}
+		default:
+			change := dns.CompareObservations(last, obs)
+			var warnings []dns.TTLWarning
+			if checkTTL {
+				warnings = monitor.Check(obs)
+			}
+			if outErr := outputWatchObservation(w, format, last, obs, change, warnings); outErr != nil {
+				return outErr
+			}
+			raised := newTTLWarnings(lastWarnings, warnings)
+			if (change.Changed() || len(raised) > 0) && len(notifiers) > 0 {
+				event := newWatchEvent(last, obs, change, warnings, raised)
+				if notifyErr := notifiers.Notify(ctx, event); notifyErr != nil {
+					// A flaky webhook should not end the watch
+					_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Warning: alert delivery failed: %v\n", notifyErr)
+				}
+			}
+			last = obs
+			lastWarnings = warnings
+		}
+
+		if count > 0 && polls >= count {
+			return nil
+		}
+		if !sleepWatchInterval(ctx, interval) {
+			return nil
+		}
+	}
+}
+
+// sleepWatchInterval waits for the next poll and reports false if ctx ended first
+func sleepWatchInterval(ctx context.Context, interval time.Duration) bool {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// newTTLWarnings returns the warnings whose kind was not raised on the previous poll
+func newTTLWarnings(prev, cur []dns.TTLWarning) []dns.TTLWarning {
+	var raised []dns.TTLWarning
+	for _, warning := range cur {
+		if !slices.ContainsFunc(prev, func(p dns.TTLWarning) bool { return p.Kind == warning.Kind }) {
+			raised = append(raised, warning)
+		}
+	}
+	return raised
+}
+
+// newWatchEvent builds the alert for a change or newly raised TTL warnings.
+// The first poll has no previous observation to compare.
+func newWatchEvent(prev, cur *dns.Observation, change dns.ObservationChange, warnings, raised []dns.TTLWarning) notify.Event {
+	summary := describeWatchChange(prev, cur, change)
+	for _, warning := range raised {
+		if summary != "" {
+			summary += ", "
+		}
+		summary += warning.Message
+	}
+
+	details := watchAlert{
+		Changes:  change.Kinds,
+		Added:    change.Added,
+		Removed:  change.Removed,
+		Warnings: warnings,
+		Current:  newWatchObservationJSON(cur),
+	}
+	if prev != nil {
+		previous := newWatchObservationJSON(prev)
+		details.Previous = &previous
+	}
+	return notify.Event{
+		Time:    cur.Time,
+		Source:  "dns watch",
+		Target:  fmt.Sprintf("%s %s", cur.Domain, cur.RecordType),
+		Summary: summary,
+		Details: details,
+	}
+}
+
+func newWatchObservationJSON(obs *dns.Observation) watchObservationJSON {
+	return watchObservationJSON{
+		Status:        obs.Status,
+		Answers:       obs.Answers,
+		TTLFloor:      obs.TTLFloor,
+		Authoritative: obs.Authoritative,
+		LatencyMS:     float64(obs.Latency.Microseconds()) / 1000,
+		LatencyClass:  obs.LatencyClass,
+	}
+}
+
+// describeWatchChange summarizes a change in one line
+func describeWatchChange(prev, cur *dns.Observation, change dns.ObservationChange) string {
+	var parts []string
+	for _, kind := range change.Kinds {
+		switch kind {
+		case dns.ChangeAnswers:
+			part := "answers changed"
+			if prev.Status != cur.Status {
+				part = fmt.Sprintf("status %s -> %s", prev.Status, cur.Status)
+			}
+			var diff []string
+			for _, answer := range change.Added {
+				diff = append(diff, "+"+answer)
+			}
+			for _, answer := range change.Removed {
+				diff = append(diff, "-"+answer)
+			}
+			if len(diff) > 0 {
+				part += " (" + strings.Join(diff, " ") + ")"
+			}
+			parts = append(parts, part)
+		case dns.ChangeTTL:
+			parts = append(parts, fmt.Sprintf("ttl %d -> %d", prev.TTLFloor, cur.TTLFloor))
+		case dns.ChangeLatency:
+			parts = append(parts, fmt.Sprintf("latency %s -> %s", prev.LatencyClass, cur.LatencyClass))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func outputWatchObservation(w io.Writer, format string, prev, obs *dns.Observation, change dns.ObservationChange, warnings []dns.TTLWarning) error {
+	if format == "json" {
+		return json.NewEncoder(w).Encode(struct {
+			Timestamp string `json:"timestamp"`
+			Domain    string `json:"domain"`
+			Type      string `json:"type"`
+			Server    string `json:"server"`
+			watchObservationJSON
+			Changed bool     `json:"changed"`
+			Changes []string `json:"changes,omitempty"`
+			Added   []string `json:"added,omitempty"`
+			Removed []string `json:"removed,omitempty"`
+
+			Warnings []dns.TTLWarning `json:"warnings,omitempty"`
+		}{
+			Timestamp:            obs.Time.Format(time.RFC3339),
+			Domain:               obs.Domain,
+			Type:                 obs.RecordType,
+			Server:               obs.Server,
+			watchObservationJSON: newWatchObservationJSON(obs),
+			Changed:              change.Changed(),
+			Changes:              change.Kinds,
+			Added:                change.Added,
+			Removed:              change.Removed,
+			Warnings:             warnings,
+		})
+	}
+
+	symbol := " "
+	if change.Changed() {
+		symbol = "!"
+	}
+	answers := strings.Join(obs.Answers, ", ")
+	if obs.Status != dns.LookupStatusOK {
+		answers = strings.ToUpper(obs.Status)
+	} else if answers == "" {
+		answers = "no records"
+	}
+	_, _ = fmt.Fprintf(w, "[%s]%s %s  ttl %d  %v (%s)", obs.Time.Format("15:04:05"), symbol,
+		answers, obs.TTLFloor, obs.Latency.Round(time.Millisecond), obs.LatencyClass)
+	if change.Changed() {
+		_, _ = fmt.Fprintf(w, " ← %s", describeWatchChange(prev, obs, change))
+	}
+	for _, warning := range warnings {
+		_, _ = fmt.Fprintf(w, "  warning: %s", warning.Message)
+	}
+	_, _ = fmt.Fprintln(w)
+	return nil
+}
+
+func outputWatchError(w io.Writer, format string, timestamp time.Time, domain string, err error) error {
+	if format == "json" {
+		return json.NewEncoder(w).Encode(struct {
+			Timestamp string `json:"timestamp"`
+			Domain    string `json:"domain"`
+			Code      string `json:"code"`
+			Error     string `json:"error"`
+		}{
+			Timestamp: timestamp.Format(time.RFC3339),
+			Domain:    domain,
+			Code:      string(errcode.Of(err)),
+			Error:     err.Error(),
+		})
+	}
+	_, _ = fmt.Fprintf(w, "[%s] Error [%s]: %v\n", timestamp.Format("15:04:05"), errcode.Of(err), err)
+	return nil
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+)
+
+// cobraUsagePrefixes identify argument and flag errors raised by cobra itself
+var cobraUsagePrefixes = []string{
+	"accepts ",
+	"requires at least",
+	"requires at most",
+	"received ",
+	"unknown flag",
+	"unknown shorthand flag",
+	"invalid argument",
+	"flag needs an argument",
+	"bad flag syntax",
+}
+
+// errorEnvelope is the JSON shape of a failed command when JSON output was requested
+type errorEnvelope struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code    errcode.Code `json:"code"`
+	Message string       `json:"message"`
+}
+
+// classifyError returns the error's code, recognizing cobra's own usage errors
+func classifyError(err error) errcode.Code {
+	if code := errcode.Of(err); code != errcode.Unknown {
+		return code
+	}
+
+	message := err.Error()
+	if strings.HasPrefix(message, "unknown command") {
+		return errcode.CLIUnknownCommand
+	}
+	for _, prefix := range cobraUsagePrefixes {
+		if strings.HasPrefix(message, prefix) {
+			return errcode.CLIUsage
+		}
+	}
+	return errcode.Unknown
+}
+
+// reportError prints err with its code, followed by usage as cobra would. When
+// the failed command was asked for JSON output it prints a JSON envelope instead.
+// Subcommands that set SilenceErrors or SilenceUsage keep those preferences;
+// root sets both only to hand printing over to Execute.
+func reportError(w io.Writer, cmd *cobra.Command, err error) {
+	if cmd.HasParent() && cmd.SilenceErrors {
+		return
+	}
+	code := classifyError(err)
+
+	if wantsJSON(cmd) {
+		envelope := errorEnvelope{Error: errorDetail{Code: code, Message: err.Error()}}
+		if encoded, marshalErr := json.Marshal(envelope); marshalErr == nil {
+			_, _ = fmt.Fprintln(w, string(encoded))
+			return
+		}
+	}
+
+	_, _ = fmt.Fprintf(w, "Error [%s]: %v\n", code, err)
+	if !cmd.HasParent() || !cmd.SilenceUsage {
+		_, _ = fmt.Fprintln(w, cmd.UsageString())
+	}
+}
+
+// wantsJSON reports whether the command was run with --json, --format json, or
+// --format jsonl
+func wantsJSON(cmd *cobra.Command) bool {
+	if jsonOutput, err := cmd.Flags().GetBool("json"); err == nil && jsonOutput {
+		return true
+	}
+	format, err := cmd.Flags().GetString("format")
+	return err == nil && (format == "json" || format == "jsonl")
+}
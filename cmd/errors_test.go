@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want errcode.Code
+	}{
+		{errcode.Errorf(errcode.CIDRInvalid, "invalid CIDR format"), errcode.CIDRInvalid},
+		{errors.New(`unknown command "bogus" for "cidrator"`), errcode.CLIUnknownCommand},
+		{errors.New("accepts 1 arg(s), received 0"), errcode.CLIUsage},
+		{errors.New("unknown flag: --bogus"), errcode.CLIUsage},
+		{errors.New("something else"), errcode.Unknown},
+	}
+
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.want {
+			t.Errorf("classifyError(%q) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestReportErrorText(t *testing.T) {
+	cmd := &cobra.Command{Use: "cidrator"}
+	var out bytes.Buffer
+
+	reportError(&out, cmd, errcode.Errorf(errcode.CIDRInvalid, "invalid CIDR format"))
+
+	if !strings.HasPrefix(out.String(), "Error [CIDR001]: invalid CIDR format\n") {
+		t.Fatalf("unexpected error output: %q", out.String())
+	}
+	if !strings.Contains(out.String(), "Usage:") {
+		t.Fatalf("expected usage after the error: %q", out.String())
+	}
+}
+
+func TestReportErrorJSONEnvelope(t *testing.T) {
+	cmd := &cobra.Command{Use: "cidrator"}
+	cmd.Flags().String("format", "table", "")
+	if err := cmd.Flags().Set("format", "json"); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+
+	reportError(&out, cmd, errcode.Errorf(errcode.DNSEmptyDomain, "domain cannot be empty"))
+
+	var envelope errorEnvelope
+	if err := json.Unmarshal(out.Bytes(), &envelope); err != nil {
+		t.Fatalf("expected JSON envelope, got %q: %v", out.String(), err)
+	}
+	if envelope.Error.Code != errcode.DNSEmptyDomain || envelope.Error.Message != "domain cannot be empty" {
+		t.Fatalf("unexpected envelope: %+v", envelope)
+	}
+}
@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+)
+
+// commandExample is one copy-pasteable line shown by --help and --examples
+type commandExample struct {
+	Command string // As typed in a shell, starting with cidrator or feeding it
+	Offline bool   // Needs no network, files, or saved sets, so TestExamples runs it
+}
+
+// commandExamples holds the examples of every command, keyed by its path
+// below root. Each one is parsed by the tests so a renamed flag or command
+// breaks the build, and the Offline ones are run with --offline.
+var commandExamples = map[string][]commandExample{
+	"audit show": {
+		{Command: "cidrator audit show --audit-log /var/log/cidrator/audit.log"},
+		{Command: "cidrator audit show --last 20"},
+		{Command: "cidrator audit show --format json"},
+	},
+	"bench self": {
+		{Command: "cidrator bench self"},
+		{Command: "cidrator bench self --pps 5000 --duration 2s"},
+		{Command: "cidrator bench self --dns-server 1.1.1.1 --format json"},
+	},
+	"cidr aggregate": {
+		{Command: "cidrator cidr aggregate 10.0.0.0/24 10.0.1.0/24 10.0.1.128/25", Offline: true},
+		{Command: "cidrator cidr aggregate 2001:db8::/33 2001:db8:8000::/33", Offline: true},
+		{Command: "awk '{print $1}' routes.txt | cidrator cidr aggregate"},
+	},
+	"cidr allocate": {
+		{Command: "cidrator cidr allocate 10.0.0.0/16 --used used.txt --size /24 --count 3"},
+		{Command: "cidrator cidr allocate 10.0.0.0/16 --used 10.0.0.0/24 --used 10.0.4.0/22 --size 23", Offline: true},
+		{Command: "cidrator cidr allocate 10.0.0.0/16 --used @vpc --size /26 --strategy best-fit"},
+	},
+	"cidr compare": {
+		{Command: "cidrator cidr compare old.txt new.txt"},
+		{Command: "git show HEAD~1:prefixes.txt | cidrator cidr compare - prefixes.txt"},
+		{Command: "cidrator cidr compare old.txt new.txt --format json"},
+	},
+	"cidr contains": {
+		{Command: "cidrator cidr contains 10.0.0.0/16 10.0.14.5", Offline: true},
+		{Command: "cidrator cidr contains 2001:db8:1234:1a00::/106 2001:db8:1234:1a00::1", Offline: true},
+		{Command: "cidrator cidr contains @corp 10.1.2.3"},
+	},
+	"cidr count": {
+		{Command: "cidrator cidr count 10.0.0.0/16", Offline: true},
+		{Command: "cidrator cidr count 2001:db8:1234:1a00::/106", Offline: true},
+		{Command: "cidrator cidr count 172.16.18.0/31", Offline: true},
+		{Command: "cidrator cidr count 0.0.0.0/0 --exclude-reserved", Offline: true},
+	},
+	"cidr divide": {
+		{Command: "cidrator cidr divide 10.0.0.0/16 4", Offline: true},
+		{Command: "cidrator cidr divide 2001:db8:1111:2222:1::/80 8", Offline: true},
+		{Command: "cidrator cidr divide 192.168.0.0/24 2", Offline: true},
+		{Command: "cidrator cidr divide 10.0.0.0/16 --prefix 24", Offline: true},
+		{Command: "cidrator cidr divide 2001:db8::/32 --prefix 64 | head"},
+	},
+	"cidr eval": {
+		{Command: "cidrator cidr eval '10.0.0.0/8 & (192.168.0.0/16 | 10.1.0.0/16) - 10.1.2.0/24'", Offline: true},
+		{Command: "cidrator cidr eval '~(10.0.0.0/8 | 172.16.0.0/12 | 192.168.0.0/16)'", Offline: true},
+		{Command: "cidrator cidr eval '@corp & @cloud' --set corp=corp.txt --set cloud=cloud.txt"},
+	},
+	"cidr expand": {
+		{Command: "cidrator cidr expand 192.168.1.0/30", Offline: true},
+		{Command: "cidrator cidr expand 10.0.0.0/29 --limit 10", Offline: true},
+		{Command: "cidrator cidr expand 192.168.1.0/28 --one-line", Offline: true},
+		{Command: "cidrator cidr expand 10.0.0.0/24 --format jsonl", Offline: true},
+		{Command: "cidrator cidr expand 2001:db8::/120 --format csv --limit 16", Offline: true},
+		{Command: "cidrator cidr expand 169.254.0.0/15 --exclude-reserved", Offline: true},
+	},
+	"cidr explain": {
+		{Command: "cidrator cidr explain 10.0.0.0/16", Offline: true},
+		{Command: "cidrator cidr explain 10.0.0.0/16 2001:db8::/48 --format json", Offline: true},
+		{Command: "cat prefixes.txt | cidrator cidr explain - --format jsonl"},
+		{Command: "cidrator cidr explain 10.0.0.0/23 10.0.2.0/24 --compare", Offline: true},
+	},
+	"cidr info": {
+		{Command: "cidrator cidr info 10.1.2.3", Offline: true},
+		{Command: "cidrator cidr info 2002:c000:204::1", Offline: true},
+		{Command: "cidrator cidr info 64:ff9b::c000:221 --format json", Offline: true},
+	},
+	"cidr overlaps": {
+		{Command: "cidrator cidr overlaps 10.0.0.0/16 10.0.14.0/22", Offline: true},
+		{Command: "cidrator cidr overlaps 2001:db8:1111:2222:1::/80 2001:db8:1111:2222:1:1::/96", Offline: true},
+		{Command: "cidrator cidr overlaps 192.168.1.0/24 10.0.0.0/8", Offline: true},
+	},
+	"cidr random": {
+		{Command: "cidrator cidr random 10.0.0.0/8 --count 100 --seed 42", Offline: true},
+		{Command: "cidrator cidr random 192.168.1.0/24 --count 10 --usable", Offline: true},
+		{Command: "cidrator cidr random 2001:db8::/32 --count 5", Offline: true},
+		{Command: "cidrator cidr random 10.0.0.0/16 --size /24 --count 4", Offline: true},
+	},
+	"cidr range": {
+		{Command: "cidrator cidr range 192.168.1.10 192.168.2.55", Offline: true},
+		{Command: "cidrator cidr range 192.168.1.10-192.168.2.55", Offline: true},
+		{Command: "cidrator cidr range 2001:db8::1 2001:db8::ff", Offline: true},
+		{Command: "cidrator cidr range --to-range 10.0.0.0/22", Offline: true},
+	},
+	"cidr subtract": {
+		{Command: "cidrator cidr subtract 10.0.0.0/8 10.1.0.0/16 10.2.3.0/24", Offline: true},
+		{Command: "cidrator cidr subtract 192.168.0.0/24 192.168.0.1", Offline: true},
+		{Command: "cidrator cidr subtract 2001:db8::/32 2001:db8:ffff::/48", Offline: true},
+	},
+	"cidr supernet": {
+		{Command: "cidrator cidr supernet 10.1.0.0/24 10.1.3.0/24", Offline: true},
+		{Command: "cidrator cidr supernet 10.1.0.0/24 10.1.3.0/24 --max-waste 50", Offline: true},
+		{Command: "cidrator cidr supernet 192.168.1.0/24 2001:db8:1::/48 2001:db8:2::/48", Offline: true},
+	},
+	"cidr v6 reverse-zones": {
+		{Command: "cidrator cidr v6 reverse-zones 2001:db8::/32", Offline: true},
+		{Command: "cidrator cidr v6 reverse-zones 2001:db8:4::/47 2001:db8:abcd:12::/64", Offline: true},
+	},
+	"cidr v6 split-by-nibble": {
+		{Command: "cidrator cidr v6 split-by-nibble 2001:db8::/32", Offline: true},
+		{Command: "cidrator cidr v6 split-by-nibble 2001:db8:4::/46", Offline: true},
+		{Command: "cidrator cidr v6 split-by-nibble 2001:db8:1::/48 --prefix 56 --zones", Offline: true},
+	},
+	"dns delegation": {
+		{Command: "cidrator dns delegation example.com"},
+		{Command: "cidrator dns delegation example.com --format json"},
+		{Command: "cidrator dns delegation example.com --timeout 2s"},
+		{Command: "cidrator dns delegation example.com --format json --dump-wire"},
+		{Command: "cidrator dns delegation example.com --dump-wire-dir ./wire"},
+	},
+	"dns lookup": {
+		{Command: "cidrator dns lookup example.com"},
+		{Command: "cidrator dns lookup example.com --type MX"},
+		{Command: "cidrator dns lookup example.com --type AAAA --format json"},
+		{Command: "cidrator dns lookup example.com --type ALL"},
+		{Command: "cidrator dns lookup example.com --type ALL --fail-on-error"},
+		{Command: "cidrator dns lookup example.com --server 8.8.8.8"},
+		{Command: "cidrator dns lookup example.com --server ns1.example.com --ttl-warnings"},
+	},
+	"dns ptr-audit": {
+		{Command: "cidrator dns ptr-audit 203.0.113.0/24"},
+		{Command: "cidrator dns ptr-audit 203.0.113.0/24 --expect-domain example.net"},
+		{Command: "cidrator dns ptr-audit 2001:db8::/120 --format json --all"},
+		{Command: "cidrator dns ptr-audit 203.0.113.0/24 --dry-run", Offline: true},
+		{Command: "cidrator dns ptr-audit 10.0.0.0/16 --deadline 10m --format json"},
+		{Command: "cidrator dns ptr-audit 10.0.0.0/16 --resume 10.0.142.7"},
+		{Command: "cidrator dns ptr-audit 10.0.0.0/16 --server 1.1.1.1 --server 8.8.8.8 --qps 50"},
+	},
+	"dns reverse": {
+		{Command: "cidrator dns reverse 8.8.8.8"},
+		{Command: "cidrator dns reverse 2001:4860:4860::8888"},
+		{Command: "cidrator dns reverse 8.8.8.8 --format json"},
+		{Command: "cidrator dns reverse dns.google"},
+	},
+	"dns watch": {
+		{Command: "cidrator dns watch example.com --type A --interval 30s"},
+		{Command: "cidrator dns watch example.com --server ns1.example.com --format json"},
+		{Command: "cidrator dns watch api.example.com --webhook https://hooks.example.com/dns --syslog"},
+		{Command: "cidrator dns watch api.example.com --server ns1.example.com --ttl-warnings --low-ttl 60s"},
+	},
+	"fw wireguard-config": {
+		{Command: "cidrator fw wireguard-config --endpoint vpn.example.com --address 10.8.0.2/24"},
+		{Command: "cidrator fw wireguard-config --endpoint vpn.example.com:51821 --address 10.8.0.2/24 --address fd00:8::2/64 --proto icmp"},
+		{Command: "cidrator fw wireguard-config --endpoint 203.0.113.10 --address 10.8.0.2/32 --allowed-ips 0.0.0.0/0 --dns 10.8.0.1 --pmtu 1492", Offline: true},
+	},
+	"mtu blackhole": {
+		{Command: "cidrator mtu blackhole vpn.example.com"},
+		{Command: "cidrator mtu blackhole vpn.example.com --plp-port 7 --format json"},
+		{Command: "cidrator mtu blackhole pppoe-gw.example.com --port 22 --max 1492"},
+	},
+	"mtu clamp": {
+		{Command: "cidrator mtu clamp vpn.example.com"},
+		{Command: "cidrator mtu clamp pppoe-gw.example.com --port 22 --proto tcp"},
+		{Command: "cidrator mtu clamp example.com --pmtu 1492 --format json"},
+	},
+	"mtu compare": {
+		{Command: "cidrator mtu discover example.com --hops --format json > before.json"},
+		{Command: "cidrator mtu discover example.com --hops --format json > after.json"},
+		{Command: "cidrator mtu compare before.json after.json"},
+		{Command: "cidrator mtu compare before.json after.json --format json"},
+	},
+	"mtu discover": {
+		{Command: "cidrator mtu discover 8.8.8.8"},
+		{Command: "cidrator mtu discover 2001:4860:4860::8888 --6"},
+		{Command: "cidrator mtu discover example.com --proto tcp --format json"},
+		{Command: "cidrator mtu discover example.com --proto all"},
+		{Command: "cidrator mtu discover example.com --proto tls"},
+		{Command: "cidrator mtu discover 198.51.100.7 --proto tcp --dry-run", Offline: true},
+	},
+	"mtu interfaces": {
+		{Command: "cidrator mtu interfaces", Offline: true},
+		{Command: "cidrator mtu interfaces --format json", Offline: true},
+		{Command: "cidrator mtu interfaces --all", Offline: true},
+		{Command: "cidrator mtu interfaces --watch --webhook https://hooks.example.com/mtu"},
+	},
+	"mtu peer": {
+		{Command: "cidrator mtu peer"},
+		{Command: "cidrator mtu peer --proto udp --listen 0.0.0.0 --allow-remote"},
+		{Command: "cidrator mtu discover branch-office.example.com --proto udp --port 4821"},
+	},
+	"mtu suggest": {
+		{Command: "cidrator mtu suggest example.com --proto tcp"},
+		{Command: "cidrator mtu suggest 8.8.8.8 --proto tcp --format json"},
+		{Command: "cidrator mtu suggest --pmtu 1420", Offline: true},
+	},
+	"mtu watch": {
+		{Command: "cidrator mtu watch example.com --interval 10s"},
+		{Command: "cidrator mtu watch 8.8.8.8 --interval 30s --mss-only"},
+		{Command: "cidrator mtu watch vpn.example.com --interval 1m --for 8h"},
+		{Command: "cidrator mtu watch 10.0.0.1 10.0.0.2 --count 10 --format json"},
+		{Command: "cidrator mtu watch 10.0.0.1 10.0.0.2 10.0.0.3 --interval 1m --format json"},
+		{Command: "cidrator mtu watch api.example.com cdn.example.com --proxy socks5://proxy.corp:1080"},
+		{Command: "cidrator mtu watch vpn.example.com --listen :9123"},
+		{Command: "cidrator mtu watch vpn.example.com --webhook https://hooks.example.com/pmtu --syslog"},
+		{Command: "cidrator mtu watch 10.0.0.1 10.0.0.2 --html-report incident.html --html-every 5m"},
+		{Command: "cidrator mtu watch 10.0.0.1 10.0.0.2 --interval 30s --export parquet:soak.parquet"},
+		{Command: "cidrator mtu watch --targets-from prometheus-http-sd:http://sd.internal/edge"},
+		{Command: "cidrator mtu watch --targets-from consul:service=web,tag=edge --targets-refresh 5m"},
+		{Command: "cidrator mtu watch --targets-from file:edge.txt --format json --events"},
+	},
+	"self-update": {
+		{Command: "cidrator self-update"},
+		{Command: "cidrator self-update --check"},
+		{Command: "cidrator self-update --channel edge"},
+		{Command: "cidrator self-update --public-key ./cidrator.pub"},
+	},
+	"set add": {
+		{Command: "cidrator set add lab 10.22.0.0/16"},
+		{Command: "cidrator set add corp --from new-offices.txt"},
+	},
+	"set create": {
+		{Command: "cidrator set create corp --from corp.txt"},
+		{Command: "cidrator set create lab 10.20.0.0/16 10.21.0.0/16", Offline: true},
+		{Command: "cidrator set create corp --from corp.txt --force"},
+	},
+	"set delete": {
+		{Command: "cidrator set delete lab"},
+	},
+	"set list": {
+		{Command: "cidrator set list", Offline: true},
+		{Command: "cidrator set list --format json", Offline: true},
+	},
+	"set show": {
+		{Command: "cidrator set show corp"},
+	},
+	"version": {
+		{Command: "cidrator version", Offline: true},
+		{Command: "cidrator version --capabilities"},
+	},
+	"view": {
+		{Command: "cidrator mtu discover example.com --hops --format json > hops.json"},
+		{Command: "cidrator view hops.json"},
+		{Command: "cidrator view soak.csv fleet.jsonl --port 8080"},
+		{Command: "cidrator dns ptr-audit 10.0.0.0/24 --format json | cidrator view -"},
+	},
+}
+
+// errExamplesShown stops a command once --examples has printed its examples;
+// Execute treats it as success, as cobra does for --help
+var errExamplesShown = errors.New("examples shown")
+
+// configureExamples adds the examples to each command's help and lets
+// --examples print them without the arguments and flags the command would
+// otherwise require. It must run after shortcuts are created so they get them too.
+func configureExamples(root *cobra.Command) {
+	for path := range commandExamples {
+		mustFindCommand(root, path)
+	}
+	forEachCommand(root, func(cmd *cobra.Command) {
+		examples := examplesFor(commandPath(cmd))
+		if len(examples) == 0 {
+			return
+		}
+		// A group's help already lists its subcommands, so only commands
+		// with examples of their own show them there
+		if _, own := commandExamples[commandPath(cmd)]; own {
+			lines := make([]string, 0, len(examples))
+			for _, example := range examples {
+				lines = append(lines, "  "+example.Command)
+			}
+			cmd.Example = strings.Join(lines, "\n")
+		}
+
+		args := cmd.Args
+		cmd.Args = func(cmd *cobra.Command, positional []string) error {
+			if examplesRequested(cmd) || args == nil {
+				return nil
+			}
+			return args(cmd, positional)
+		}
+	})
+}
+
+// forEachCommand calls fn for cmd and every command below it
+func forEachCommand(cmd *cobra.Command, fn func(*cobra.Command)) {
+	fn(cmd)
+	for _, sub := range cmd.Commands() {
+		forEachCommand(sub, fn)
+	}
+}
+
+// examplesFor returns the examples of the command at path, or of every
+// command below it for a group
+func examplesFor(path string) []commandExample {
+	if path == "" {
+		return nil
+	}
+	if examples, ok := commandExamples[path]; ok {
+		return examples
+	}
+	var examples []commandExample
+	for _, command := range slices.Sorted(maps.Keys(commandExamples)) {
+		if covers(path, command) {
+			examples = append(examples, commandExamples[command]...)
+		}
+	}
+	return examples
+}
+
+func examplesRequested(cmd *cobra.Command) bool {
+	requested, _ := cmd.Flags().GetBool("examples")
+	return requested
+}
+
+// showExamples prints cmd's examples one per line, ready to paste
+func showExamples(cmd *cobra.Command) error {
+	examples := examplesFor(commandPath(cmd))
+	if len(examples) == 0 {
+		return errcode.Errorf(errcode.CLIUsage, "%s has no examples; see --help", cmd.CommandPath())
+	}
+	for _, example := range examples {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), example.Command)
+	}
+	return errExamplesShown
+}
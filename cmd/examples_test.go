@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/offline"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// shellWords splits an example into words, grouping quoted text as a shell
+// does
+func shellWords(t *testing.T, line string) []string {
+	t.Helper()
+	var words []string
+	var word strings.Builder
+	var quote rune
+	inWord := false
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		t.Fatalf("unterminated quote in %q", line)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// exampleInvocations returns the argument lists of the cidrator commands in
+// an example, dropping redirects and the other commands of a pipeline
+func exampleInvocations(t *testing.T, line string) [][]string {
+	t.Helper()
+	words := shellWords(t, line)
+	var invocations [][]string
+	for segment := range strings.SplitSeq(strings.Join(words, "\x00"), "\x00|\x00") {
+		args := strings.Split(segment, "\x00")
+		if i := slices.Index(args, ">"); i >= 0 {
+			args = args[:i]
+		}
+		if args[0] == "cidrator" {
+			invocations = append(invocations, args[1:])
+		}
+	}
+	return invocations
+}
+
+// resetFlags puts back the defaults of every flag an example changed, since
+// the command tree is shared by every test
+func resetFlags(cmd *cobra.Command) {
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if !flag.Changed {
+			return
+		}
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			defaults := strings.Trim(flag.DefValue, "[]")
+			_ = slice.Replace(strings.FieldsFunc(defaults, func(r rune) bool { return r == ',' }))
+		} else {
+			_ = flag.Value.Set(flag.DefValue)
+		}
+		flag.Changed = false
+	})
+}
+
+func TestExamplesParse(t *testing.T) {
+	for path, examples := range commandExamples {
+		for _, example := range examples {
+			invocations := exampleInvocations(t, example.Command)
+			if len(invocations) == 0 {
+				t.Errorf("%s: %q runs no cidrator command", path, example.Command)
+			}
+			for _, args := range invocations {
+				cmd, rest, err := rootCmd.Find(args)
+				if err != nil {
+					t.Errorf("%s: %q: %v", path, example.Command, err)
+					continue
+				}
+				if err := cmd.ParseFlags(rest); err != nil {
+					t.Errorf("%s: %q: %v", path, example.Command, err)
+				}
+				resetFlags(cmd)
+			}
+		}
+	}
+}
+
+func TestOfflineExamples(t *testing.T) {
+	// Execute runs initConfig, which takes these from viper
+	viper.Set("offline", true)
+	viper.Set("state-dir", t.TempDir())
+	stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := os.Stdout
+	os.Stdout = stdout
+	t.Cleanup(func() {
+		os.Stdout = original
+		_ = stdout.Close()
+		viper.Set("offline", false)
+		viper.Set("state-dir", "")
+		offline.Configure(false)
+		rootCmd.SetArgs(nil)
+	})
+
+	for path, examples := range commandExamples {
+		for _, example := range examples {
+			if !example.Offline {
+				continue
+			}
+			words := shellWords(t, example.Command)
+			if words[0] != "cidrator" || slices.Contains(words, "|") || slices.Contains(words, ">") {
+				t.Errorf("%s: %q is marked offline but is not a single cidrator command", path, example.Command)
+				continue
+			}
+			rootCmd.SetArgs(words[1:])
+			cmd, err := rootCmd.ExecuteC()
+			if err != nil {
+				t.Errorf("%s: %q: %v", path, example.Command, err)
+			}
+			resetFlags(cmd)
+		}
+	}
+}
+
+func TestExamplesFlag(t *testing.T) {
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	t.Cleanup(func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetArgs(nil)
+	})
+
+	for _, tt := range []struct {
+		args []string
+		want []commandExample
+	}{
+		{[]string{"cidr", "divide", "--examples"}, commandExamples["cidr divide"]},
+		{[]string{"explain", "--examples"}, commandExamples["cidr explain"]},
+		// Required flags and arguments are not needed to see examples
+		{[]string{"fw", "wireguard-config", "--examples"}, commandExamples["fw wireguard-config"]},
+		// A group shows the examples of all of its commands
+		{[]string{"set", "--examples"}, slices.Concat(commandExamples["set add"], commandExamples["set create"],
+			commandExamples["set delete"], commandExamples["set list"], commandExamples["set show"])},
+	} {
+		out.Reset()
+		rootCmd.SetArgs(tt.args)
+		cmd, err := rootCmd.ExecuteC()
+		if !errors.Is(err, errExamplesShown) {
+			t.Fatalf("%v: error = %v, want the examples shown", tt.args, err)
+		}
+		resetFlags(cmd)
+		var want strings.Builder
+		for _, example := range tt.want {
+			want.WriteString(example.Command + "\n")
+		}
+		if out.String() != want.String() {
+			t.Errorf("%v printed:\n%s\nwant:\n%s", tt.args, out.String(), want.String())
+		}
+	}
+
+	divide := mustFindCommand(rootCmd, "cidr divide")
+	if !strings.Contains(divide.UsageString(), "Examples:\n  cidrator cidr divide 10.0.0.0/16 4\n") {
+		t.Errorf("help does not show the examples:\n%s", divide.UsageString())
+	}
+}
+
+func TestEveryCommandHasExamples(t *testing.T) {
+	forEachCommand(rootCmd, func(cmd *cobra.Command) {
+		path := commandPath(cmd)
+		if cmd.HasSubCommands() || !cmd.Runnable() || cmd.Hidden || path == "help" || covers("completion", path) {
+			return
+		}
+		if len(examplesFor(path)) == 0 {
+			t.Errorf("%s has no examples", cmd.CommandPath())
+		}
+	})
+}
@@ -0,0 +1,20 @@
+package fw
+
+import (
+	"github.com/euan-cowie/cidrator/cmd/mtu"
+	"github.com/spf13/cobra"
+)
+
+// FwCmd represents the fw command
+var FwCmd = &cobra.Command{
+	Use:   "fw",
+	Short: "Generate tunnel and firewall configuration from measurements",
+	Long: `Turn network measurements into deployable configuration.
+
+The fw command group generates config files whose sizes come from probing the
+real path, such as a WireGuard config with an MTU measured to its endpoint.`,
+}
+
+func init() {
+	FwCmd.AddCommand(mtu.WireGuardConfigCmd)
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/ndjson"
+	"github.com/spf13/cobra"
+)
+
+// How a command takes the values --from ndjson reads
+const (
+	inputEach = "each" // Run once per value, with it as the only argument
+	inputAll  = "all"  // Run once, with every value as an argument
+)
+
+// inputFormatNDJSON is the only --from format
+const inputFormatNDJSON = "ndjson"
+
+// ndjsonInputs are the commands that can read their arguments from another
+// cidrator command's JSON output with --from ndjson
+var ndjsonInputs = map[string]string{
+	"cidr aggregate": inputAll,
+	"cidr count":     inputEach,
+	"cidr explain":   inputAll,
+	"cidr info":      inputEach,
+	"dns lookup":     inputEach,
+	"dns ptr-audit":  inputEach,
+	"dns reverse":    inputEach,
+	"mtu discover":   inputEach,
+	"mtu suggest":    inputEach,
+	"mtu watch":      inputAll,
+}
+
+// configureNDJSONInput adds --from and --field to the commands in
+// ndjsonInputs. It must run before shortcuts are created so they share them.
+func configureNDJSONInput(root *cobra.Command) {
+	for path, mode := range ndjsonInputs {
+		cmd := mustFindCommand(root, path)
+		cmd.Flags().String("from", "", "Read the arguments from stdin in this format instead (ndjson), such as another command's --format jsonl output")
+		cmd.Flags().String("field", "", fmt.Sprintf("With --from, the field holding each argument; dotted names reach nested fields and comma-separated names are tried in order (default: %s)", strings.Join(ndjson.DefaultFields, ",")))
+
+		args, run := cmd.Args, cmd.RunE
+		cmd.Args = func(cmd *cobra.Command, positional []string) error {
+			if from, _ := cmd.Flags().GetString("from"); from != "" {
+				if len(positional) > 0 {
+					return errcode.Errorf(errcode.CLIUsage, "--from %s reads the arguments from stdin; give none on the command line", from)
+				}
+				return nil
+			}
+			if args == nil {
+				return nil
+			}
+			return args(cmd, positional)
+		}
+		cmd.RunE = func(cmd *cobra.Command, positional []string) error {
+			from, _ := cmd.Flags().GetString("from")
+			if from == "" {
+				return run(cmd, positional)
+			}
+			return runFromNDJSON(cmd, from, mode, run)
+		}
+	}
+}
+
+// runFromNDJSON runs a command on the values read from stdin
+func runFromNDJSON(cmd *cobra.Command, from, mode string, run func(*cobra.Command, []string) error) error {
+	if from != inputFormatNDJSON {
+		return errcode.Errorf(errcode.CLIUsage, "unsupported --from %q: expected %s", from, inputFormatNDJSON)
+	}
+	field, _ := cmd.Flags().GetString("field")
+
+	var values []string
+	for value, err := range ndjson.Values(cmd.InOrStdin(), field) {
+		if err != nil {
+			return fmt.Errorf("failed to read stdin: %w", err)
+		}
+		if mode == inputAll {
+			values = append(values, value)
+			continue
+		}
+		if err := run(cmd, []string{value}); err != nil {
+			return errcode.Wrap(errcode.Of(err), fmt.Errorf("%s: %w", value, err))
+		}
+	}
+	if mode == inputAll {
+		return run(cmd, values)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+)
+
+// runWithNDJSONInput runs args against a fresh "group each" and "group all"
+// wired for --from ndjson, returning the arguments each run received
+func runWithNDJSONInput(t *testing.T, stdin string, args ...string) ([][]string, error) {
+	t.Helper()
+	original := ndjsonInputs
+	t.Cleanup(func() { ndjsonInputs = original })
+	ndjsonInputs = map[string]string{"group each": inputEach, "group all": inputAll}
+
+	var calls [][]string
+	record := func(cmd *cobra.Command, args []string) error {
+		calls = append(calls, args)
+		if slices.Contains(args, "fail") {
+			return errcode.Errorf(errcode.CIDRInvalid, "invalid")
+		}
+		return nil
+	}
+	root := &cobra.Command{Use: "cidrator", SilenceErrors: true, SilenceUsage: true}
+	group := &cobra.Command{Use: "group"}
+	group.AddCommand(
+		&cobra.Command{Use: "each <target>", Args: cobra.ExactArgs(1), RunE: record},
+		&cobra.Command{Use: "all <target>...", Args: cobra.MinimumNArgs(1), RunE: record},
+	)
+	root.AddCommand(group)
+	configureNDJSONInput(root)
+
+	root.SetIn(strings.NewReader(stdin))
+	root.SetArgs(args)
+	err := root.Execute()
+	return calls, err
+}
+
+func TestNDJSONInput(t *testing.T) {
+	input := `{"ip":"192.0.2.1"}` + "\n" + `{"ip":"192.0.2.2","target":"b.example"}` + "\n"
+
+	calls, err := runWithNDJSONInput(t, input, "group", "each", "--from", "ndjson", "--field", "ip")
+	if err != nil || !slices.EqualFunc(calls, [][]string{{"192.0.2.1"}, {"192.0.2.2"}}, slices.Equal) {
+		t.Fatalf("each = %v, %v", calls, err)
+	}
+	calls, err = runWithNDJSONInput(t, input, "group", "all", "--from", "ndjson")
+	if err != nil || !slices.EqualFunc(calls, [][]string{{"192.0.2.1", "b.example"}}, slices.Equal) {
+		t.Fatalf("all = %v, %v", calls, err)
+	}
+
+	// Without --from the command's own argument rules apply
+	if calls, err = runWithNDJSONInput(t, "", "group", "each", "192.0.2.9"); err != nil || len(calls) != 1 {
+		t.Fatalf("positional run = %v, %v", calls, err)
+	}
+	if _, err = runWithNDJSONInput(t, "", "group", "each"); err == nil {
+		t.Fatal("expected the command's argument check without --from")
+	}
+
+	// A failing value stops the run and keeps its code
+	calls, err = runWithNDJSONInput(t, `{"ip":"fail"}`+"\n"+`{"ip":"192.0.2.2"}`, "group", "each", "--from", "ndjson")
+	if errcode.Of(err) != errcode.CIDRInvalid || !strings.HasPrefix(err.Error(), "fail: ") || len(calls) != 1 {
+		t.Fatalf("failing value = %v, %v", calls, err)
+	}
+
+	for _, args := range [][]string{
+		{"group", "each", "192.0.2.9", "--from", "ndjson"},
+		{"group", "each", "--from", "csv"},
+	} {
+		if _, err := runWithNDJSONInput(t, input, args...); errcode.Of(err) != errcode.CLIUsage {
+			t.Errorf("%v: expected CLI002, got %v", args, err)
+		}
+	}
+}
+
+func TestNDJSONInputCommandsExist(t *testing.T) {
+	for path := range ndjsonInputs {
+		cmd := mustFindCommand(rootCmd, path)
+		if cmd.Flags().Lookup("from") == nil || cmd.Flags().Lookup("field") == nil {
+			t.Errorf("%s is missing --from or --field", path)
+		}
+	}
+	if lookup, _, _ := rootCmd.Find([]string{"lookup"}); lookup.Flags().Lookup("from") == nil {
+		t.Error("the lookup shortcut does not share --from")
+	}
+}
@@ -0,0 +1,64 @@
+package mtu
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/audit"
+	"github.com/euan-cowie/cidrator/internal/policy"
+	"github.com/spf13/cobra"
+)
+
+// checkProbeTargets refuses plans whose targets are in networks forbidden by
+// policy, or in documentation ranges without --allow-doc-ranges. Every
+// probing command calls it before sending anything, whether or not the
+// audit log is enabled.
+func checkProbeTargets(cmd *cobra.Command, plans ...dryRunPlan) error {
+	return policy.CheckTargets(cmd.Context(), cmd.Flags(), planTargets(plans)...)
+}
+
+// recordProbeAudit logs the invocation before any probe is sent, using the same
+// plans --dry-run prints so the packet count is the upper bound. Cross-protocol
+// checks and fleet watches pass one plan per protocol or target and are logged
+// as a single entry.
+func recordProbeAudit(cmd *cobra.Command, plans ...dryRunPlan) error {
+	entry := audit.Entry{Command: cmd.CommandPath(), Targets: planTargets(plans)}
+	protocols := make([]string, 0, len(plans))
+	for _, plan := range plans {
+		if !slices.Contains(protocols, plan.Protocol) {
+			protocols = append(protocols, plan.Protocol)
+		}
+		entry.Packets += plan.MaxPackets
+		entry.IntervalMS = plan.IntervalMS
+	}
+	entry.Protocol = strings.Join(protocols, ",")
+	return audit.Record(entry)
+}
+
+// recordTargetSources logs the service discovery requests of a fleet watch
+// before the first one. They repeat every refresh, so the entry counts the
+// requests per refresh.
+func recordTargetSources(cmd *cobra.Command, fleet *fleetTargets) error {
+	endpoints := fleet.sources.Endpoints()
+	if len(endpoints) == 0 {
+		return nil
+	}
+	return audit.Record(audit.Entry{
+		Command:    cmd.CommandPath(),
+		Targets:    endpoints,
+		Protocol:   "http",
+		Packets:    len(endpoints),
+		IntervalMS: fleet.refresh.Milliseconds(),
+	})
+}
+
+// planTargets returns the distinct targets of plans in order
+func planTargets(plans []dryRunPlan) []string {
+	var targets []string
+	for _, plan := range plans {
+		if !slices.Contains(targets, plan.Target) {
+			targets = append(targets, plan.Target)
+		}
+	}
+	return targets
+}
@@ -0,0 +1,431 @@
+package mtu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+)
+
+// Verdicts of mtu blackhole
+const (
+	verdictHealthy      = "healthy"          // Large packets arrive, or routers say they are too big
+	verdictICMPFiltered = "icmp-filtered"    // Even small echoes go unanswered
+	verdictBlackHole    = "pmtud-black-hole" // Small echoes are answered, large ones vanish without Fragmentation Needed
+	verdictInconclusive = "inconclusive"     // The ICMP checks could not run
+)
+
+// Check names, in the order they run
+const (
+	checkTCPLargeMSS = "tcp-large-mss"
+	checkICMPSmall   = "icmp-small"
+	checkICMPLargeDF = "icmp-large-df"
+	checkICMPSearch  = "icmp-search"
+	checkPLPMTUD     = "plpmtud"
+)
+
+// Check statuses
+const (
+	checkPass    = "pass"
+	checkFail    = "fail"
+	checkSkipped = "skipped"
+)
+
+// defaultBlackholeLargeSize is the large probe without --max: what an
+// Ethernet path carries end to end
+const defaultBlackholeLargeSize = 1500
+
+// blackholeLargeAttempts is how many times the large probe is sent before
+// its silence counts as a black hole rather than loss
+const blackholeLargeAttempts = 3
+
+// blackholeICMPProber sends the single ICMP probes; MTUDiscoverer in
+// production
+type blackholeICMPProber interface {
+	probe(ctx context.Context, size int) *ProbeResult
+	Close() error
+}
+
+var newBlackholeICMPProber = func(opts discoveryOptions) (blackholeICMPProber, error) {
+	return newMTUDiscoverer(opts)
+}
+
+var blackholeCanProbeICMP = canProbeICMP
+var blackholeMTUDiscovery = performMTUDiscovery
+
+var blackholePLPMTUD = func(ctx context.Context, opts discoveryOptions) (*MTUResult, error) {
+	prober := NewPLPMTUDProber(opts.Destination, opts.IPv6, PLPMTUDOptions{PLPPort: opts.PLPPort, BaseTimeout: opts.Timeout})
+	return prober.DiscoverPMTUWithPLPMTUD(ctx, opts.MinMTU, opts.MaxMTU)
+}
+
+// blackholeCmd represents the blackhole command
+var blackholeCmd = &cobra.Command{
+	Use:   "blackhole <destination>",
+	Short: "Diagnose PMTUD black holes and ICMP filtering on the path",
+	Long: `Blackhole runs a fixed set of checks against the destination and prints a
+verdict with the MSS to clamp to:
+
+• tcp-large-mss: a TCP handshake (--port, default 443) advertising the full
+  local MSS, to read the MSS the connection settles on.
+• icmp-small: an echo at --min, to see whether ICMP gets through at all.
+• icmp-large-df: an echo at 1500 bytes (or --max) with DF set, sent up to
+  three times. It should be answered, or draw a Fragmentation Needed error.
+• icmp-search: when the large echo vanishes, an ICMP search for the largest
+  size that is answered.
+• plpmtud: a PLPMTUD search against a UDP echo service, which needs no ICMP
+  errors. It runs with --plpmtud or --plp-port.
+
+The verdict is one of:
+
+• healthy: large packets arrive, or routers report them too big, so PMTUD
+  works.
+• icmp-filtered: small echoes go unanswered, so ICMP cannot be tested.
+  PLPMTUD still measures the path when it runs.
+• pmtud-black-hole: small echoes are answered but large ones vanish without
+  Fragmentation Needed. TCP connections stall once they send full-size
+  segments unless the MSS is clamped to the PMTU found.
+• inconclusive: the ICMP checks need root, CAP_NET_RAW, or an OS that
+  allows unprivileged ICMP sockets.
+
+The ICMP checks honor --min, --max, --timeout, --retries, and --pps.`,
+	Args:        cobra.ExactArgs(1),
+	RunE:        runBlackhole,
+	Annotations: dryRunAnnotations,
+}
+
+// BlackholeCheck is the result of one check in the matrix
+type BlackholeCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"` // pass, fail, or skipped
+	Size    int    `json:"size,omitempty"`
+	Outcome string `json:"outcome,omitempty"` // How a probe failed, such as no-response or icmp-frag-needed(mtu=1400)
+	Detail  string `json:"detail"`
+}
+
+// BlackholeReport is the verdict of mtu blackhole
+type BlackholeReport struct {
+	Target                string           `json:"target"`
+	Verdict               string           `json:"verdict"` // healthy, icmp-filtered, pmtud-black-hole, or inconclusive
+	Summary               string           `json:"summary"`
+	PMTU                  int              `json:"pmtu,omitempty"`           // Largest size known to arrive (0 = unknown)
+	NegotiatedMSS         int              `json:"negotiated_mss,omitempty"` // From tcp-large-mss
+	SuggestedMSS          int              `json:"suggested_mss,omitempty"`  // MSS to clamp to for the PMTU
+	SuggestedMSSTimestamp int              `json:"suggested_mss_timestamps,omitempty"`
+	Checks                []BlackholeCheck `json:"checks"`
+	Hints                 []string         `json:"hints,omitempty"`
+}
+
+func runBlackhole(cmd *cobra.Command, args []string) error {
+	format, err := readOutputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("proto") {
+		return errcode.Errorf(errcode.CLIUsage, "mtu blackhole picks the protocol of each check; --proto is not supported")
+	}
+	opts, err := readDiscoveryOptions(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	if opts.HopsMode {
+		return errcode.Errorf(errcode.CLIUsage, "--hops is only supported by mtu discover")
+	}
+	if opts, err = selectAddressFamily(opts, !opts.DryRun); err != nil {
+		return err
+	}
+
+	large := defaultBlackholeLargeSize
+	if cmd.Flags().Changed("max") {
+		large = opts.MaxMTU
+	}
+	if large <= opts.MinMTU {
+		return errcode.Errorf(errcode.CLIUsage, "--max (%d) must be above --min (%d)", large, opts.MinMTU)
+	}
+	port := defaultClampPort
+	if opts.Port > 0 {
+		port = opts.Port
+	}
+	runPLPMTUD := opts.PLPMTUD || cmd.Flags().Changed("plp-port")
+
+	icmpOpts, plpOpts := blackholeOptions(opts, large)
+	plans := []dryRunPlan{newClampHandshakePlan(opts, port), newBlackholeICMPPlan(icmpOpts)}
+	if runPLPMTUD {
+		plans = append(plans, newDryRunPlan(plpOpts))
+	}
+	if opts.DryRun {
+		return outputDryRunPlans(plans, format)
+	}
+	if err := checkProbeTargets(cmd, plans...); err != nil {
+		return err
+	}
+	if err := recordProbeAudit(cmd, plans...); err != nil {
+		return err
+	}
+
+	report := &BlackholeReport{Target: opts.Destination}
+	runBlackholeChecks(report, opts, icmpOpts, port)
+	if runPLPMTUD {
+		report.Checks = append(report.Checks, runPLPMTUDCheck(plpOpts))
+	} else {
+		report.Checks = append(report.Checks, BlackholeCheck{Name: checkPLPMTUD, Status: checkSkipped, Detail: "needs a UDP echo service; give --plp-port"})
+	}
+	report.classify(opts.IPv6, large)
+
+	if format.structured() {
+		return format.write(report)
+	}
+	return outputBlackholeTable(report)
+}
+
+// blackholeOptions derives the options of the ICMP checks, which search up to
+// the large size, and of the PLPMTUD check
+func blackholeOptions(opts discoveryOptions, large int) (discoveryOptions, discoveryOptions) {
+	icmpOpts := opts
+	icmpOpts.Protocol = "icmp"
+	icmpOpts.MaxMTU = large
+	icmpOpts.Step = 0
+	icmpOpts.PLPMTUD = false
+	icmpOpts.TrainCount = 0
+
+	plpOpts := icmpOpts
+	plpOpts.Protocol = "udp"
+	plpOpts.Port = opts.PLPPort
+	plpOpts.PLPMTUD = true
+	return icmpOpts, plpOpts
+}
+
+// newBlackholeICMPPlan describes the ICMP checks: the search, plus the small
+// echo and each attempt of the large one
+func newBlackholeICMPPlan(opts discoveryOptions) dryRunPlan {
+	plan := newDryRunPlan(opts)
+	plan.Mode = "blackhole"
+	extra := 1 + blackholeLargeAttempts
+	plan.MaxProbes += extra
+	plan.MaxPackets += extra
+	plan.MaxBytes += opts.MinMTU + blackholeLargeAttempts*opts.MaxMTU
+	plan.EstimatedDurationMS += int64(extra) * opts.Timeout.Milliseconds()
+	return plan
+}
+
+// runBlackholeChecks runs the TCP and ICMP checks. The handshake goes first,
+// before the ICMP probes can teach the kernel a lower path MTU.
+func runBlackholeChecks(report *BlackholeReport, opts, icmpOpts discoveryOptions, port int) {
+	ctx, cancel := newDiscoveryContext(icmpOpts)
+	defer cancel()
+
+	tcp := BlackholeCheck{Name: checkTCPLargeMSS}
+	if result, err := clampHandshake(ctx, opts, port); err != nil {
+		tcp.Status, tcp.Detail = checkFail, err.Error()
+	} else {
+		report.NegotiatedMSS = result.NegotiatedMSS
+		tcp.Status = checkPass
+		tcp.Detail = fmt.Sprintf("port %d negotiated MSS %d", port, result.NegotiatedMSS)
+	}
+	report.Checks = append(report.Checks, tcp)
+
+	if !blackholeCanProbeICMP(opts.IPv6) {
+		reason := "needs root, CAP_NET_RAW, or unprivileged ICMP sockets"
+		if !rawICMPSupported {
+			reason = "needs unprivileged ICMP sockets, since raw ICMP is not in minimal builds"
+			if !datagramICMPSupported {
+				reason = "not included in this minimal build"
+			}
+		}
+		for _, name := range []string{checkICMPSmall, checkICMPLargeDF, checkICMPSearch} {
+			report.Checks = append(report.Checks, BlackholeCheck{Name: name, Status: checkSkipped, Detail: reason})
+		}
+		return
+	}
+
+	small, large, err := probeBlackholeICMP(ctx, icmpOpts)
+	if err != nil {
+		report.Checks = append(report.Checks, BlackholeCheck{Name: checkICMPSmall, Status: checkSkipped, Detail: err.Error()})
+		return
+	}
+	report.Checks = append(report.Checks, small)
+	if small.Status != checkPass {
+		return
+	}
+	report.Checks = append(report.Checks, large)
+	if large.Outcome != string(OutcomeNoResponse) {
+		return
+	}
+
+	search := BlackholeCheck{Name: checkICMPSearch}
+	if result, err := blackholeMTUDiscovery(ctx, icmpOpts); err != nil {
+		search.Status, search.Detail = checkFail, err.Error()
+	} else {
+		search.Status, search.Size = checkPass, result.PMTU
+		search.Detail = fmt.Sprintf("largest echo answered is %d bytes", result.PMTU)
+	}
+	report.Checks = append(report.Checks, search)
+}
+
+// probeBlackholeICMP sends the small echo and, if it is answered, the large
+// one until it is answered or draws an error. The prober is closed before the
+// search opens its own socket.
+func probeBlackholeICMP(ctx context.Context, opts discoveryOptions) (BlackholeCheck, BlackholeCheck, error) {
+	prober, err := newBlackholeICMPProber(opts)
+	if err != nil {
+		return BlackholeCheck{}, BlackholeCheck{}, err
+	}
+	defer func() { _ = prober.Close() }()
+
+	small := probeCheck(checkICMPSmall, prober.probe(ctx, opts.MinMTU))
+	if small.Status != checkPass {
+		return small, BlackholeCheck{}, nil
+	}
+	var large BlackholeCheck
+	for range blackholeLargeAttempts {
+		large = probeCheck(checkICMPLargeDF, prober.probe(ctx, opts.MaxMTU))
+		if large.Outcome != string(OutcomeNoResponse) || ctx.Err() != nil {
+			break
+		}
+	}
+	return small, large, nil
+}
+
+// probeCheck reports one ICMP probe as a check
+func probeCheck(name string, result *ProbeResult) BlackholeCheck {
+	check := BlackholeCheck{Name: name, Size: result.Size}
+	switch {
+	case result.Success:
+		check.Status = checkPass
+		check.Detail = fmt.Sprintf("%d-byte echo answered in %.1f ms", result.Size, float64(result.RTT.Microseconds())/1000)
+	case isTooBig(result.ICMPErr):
+		check.Status, check.Outcome = checkFail, (&ProbeResult{Outcome: OutcomeFragNeeded, ICMPErr: result.ICMPErr}).Describe()
+		check.Detail = fmt.Sprintf("%d-byte echo drew Fragmentation Needed", result.Size)
+	case errors.Is(result.Error, syscall.EMSGSIZE):
+		check.Status, check.Outcome = checkFail, string(OutcomeFragNeeded)
+		check.Detail = fmt.Sprintf("%d bytes exceeds the local path MTU", result.Size)
+	case result.ICMPErr != nil:
+		check.Status, check.Detail = checkFail, result.ICMPErr.Message
+	default:
+		check.Status, check.Outcome = checkFail, string(OutcomeNoResponse)
+		check.Detail = fmt.Sprintf("%d-byte echo went unanswered", result.Size)
+	}
+	return check
+}
+
+// isTooBig reports whether err is Fragmentation Needed (ICMP type 3 code 4)
+// or Packet Too Big (ICMPv6 type 2)
+func isTooBig(err *ICMPError) bool {
+	return err != nil && (err.Type == 3 && err.Code == 4 || err.Type == 2 || err.MTU > 0)
+}
+
+// runPLPMTUDCheck measures the path without relying on ICMP errors
+func runPLPMTUDCheck(opts discoveryOptions) BlackholeCheck {
+	ctx, cancel := newDiscoveryContext(opts)
+	defer cancel()
+
+	check := BlackholeCheck{Name: checkPLPMTUD}
+	result, err := blackholePLPMTUD(ctx, opts)
+	if err != nil {
+		check.Status, check.Detail = checkFail, err.Error()
+		return check
+	}
+	check.Status, check.Size = checkPass, result.PMTU
+	check.Detail = fmt.Sprintf("UDP port %d confirmed PLPMTU %d", opts.PLPPort, result.PMTU)
+	return check
+}
+
+// check returns the named check, or an empty one if it did not run
+func (r *BlackholeReport) check(name string) BlackholeCheck {
+	for _, check := range r.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	return BlackholeCheck{Name: name}
+}
+
+// classify turns the checks into a verdict, a PMTU, and the MSS to clamp to
+func (r *BlackholeReport) classify(ipv6 bool, large int) {
+	small, largeDF, search, plp := r.check(checkICMPSmall), r.check(checkICMPLargeDF), r.check(checkICMPSearch), r.check(checkPLPMTUD)
+	if plp.Status == checkPass {
+		r.PMTU = plp.Size
+	}
+
+	icmpError := "ICMP Fragmentation Needed (type 3 code 4)"
+	if ipv6 {
+		icmpError = "ICMPv6 Packet Too Big (type 2)"
+	}
+	switch {
+	case small.Status == checkFail:
+		r.Verdict, r.Summary = verdictICMPFiltered, "ICMP filtered"
+		r.Hints = append(r.Hints, fmt.Sprintf("Echo is filtered on the path; if %s is filtered too, PMTUD cannot work", icmpError))
+		if r.PMTU == 0 {
+			r.Hints = append(r.Hints, "Give --plp-port of a UDP echo service to measure the path without ICMP")
+		}
+	case small.Status != checkPass:
+		r.Verdict, r.Summary = verdictInconclusive, "inconclusive"
+		r.Hints = append(r.Hints, "Run as root or with CAP_NET_RAW for the ICMP checks, or allow unprivileged ICMP with net.ipv4.ping_group_range")
+	case largeDF.Status == checkPass:
+		r.Verdict, r.Summary = verdictHealthy, "healthy"
+		r.PMTU = max(r.PMTU, largeDF.Size)
+	case largeDF.Outcome != string(OutcomeNoResponse):
+		r.Verdict, r.Summary = verdictHealthy, "healthy"
+		if search.Status != checkPass && r.PMTU == 0 {
+			r.Hints = append(r.Hints, fmt.Sprintf("%d bytes is too big for the path, which says so; run mtu discover for the exact PMTU", large))
+		}
+	default:
+		r.Verdict = verdictBlackHole
+		if search.Status == checkPass {
+			r.PMTU = search.Size
+		}
+		if r.PMTU > 0 {
+			r.Summary = fmt.Sprintf("PMTUD black hole at ~%d bytes", r.PMTU)
+		} else {
+			r.Summary = fmt.Sprintf("PMTUD black hole below %d bytes", large)
+		}
+		r.Hints = append(r.Hints, fmt.Sprintf("Allow %s back from routers on the path", icmpError))
+	}
+
+	if r.PMTU == 0 {
+		return
+	}
+	r.SuggestedMSS = tcpMSSForMTU(r.PMTU, ipv6)
+	r.SuggestedMSSTimestamp = r.SuggestedMSS - tcpTimestampOptionBytes
+	if r.Verdict == verdictHealthy {
+		return
+	}
+	tables := "iptables"
+	if ipv6 {
+		tables = "ip6tables"
+	}
+	if r.NegotiatedMSS > r.SuggestedMSS {
+		r.Hints = append(r.Hints, fmt.Sprintf("TCP negotiated MSS %d, %d bytes more than the path carries; full-size segments will stall", r.NegotiatedMSS, r.NegotiatedMSS-r.SuggestedMSS))
+	}
+	r.Hints = append(r.Hints,
+		fmt.Sprintf("Clamp the MSS to %d: %s -t mangle -A FORWARD -p tcp --tcp-flags SYN,RST SYN -j TCPMSS --set-mss %d", r.SuggestedMSS, tables, r.SuggestedMSS),
+		fmt.Sprintf("Or set the tunnel or WAN interface MTU to %d", r.PMTU),
+	)
+}
+
+func outputBlackholeTable(r *BlackholeReport) error {
+	fmt.Printf("Target: %s\n", r.Target)
+	fmt.Printf("%-15s %-8s %-6s %s\n", "CHECK", "STATUS", "SIZE", "DETAIL")
+	for _, check := range r.Checks {
+		size := "-"
+		if check.Size > 0 {
+			size = fmt.Sprint(check.Size)
+		}
+		detail := check.Detail
+		if check.Outcome != "" {
+			detail = fmt.Sprintf("%s [%s]", detail, check.Outcome)
+		}
+		fmt.Printf("%-15s %-8s %-6s %s\n", check.Name, check.Status, size, detail)
+	}
+
+	fmt.Printf("\nVerdict: %s\n", r.Summary)
+	if r.SuggestedMSS > 0 {
+		fmt.Printf("Suggested MSS clamp: %d (%d with timestamps) for PMTU %d\n", r.SuggestedMSS, r.SuggestedMSSTimestamp, r.PMTU)
+	}
+	for _, hint := range r.Hints {
+		fmt.Printf("• %s\n", hint)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package mtu
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blackholeProber answers echoes up to mtu, and drops larger ones silently
+// unless tooBig is set
+type blackholeProber struct {
+	mtu    int
+	tooBig bool
+	sizes  []int
+}
+
+func (p *blackholeProber) probe(ctx context.Context, size int) *ProbeResult {
+	p.sizes = append(p.sizes, size)
+	switch {
+	case size <= p.mtu:
+		return &ProbeResult{Size: size, Success: true, RTT: time.Millisecond}
+	case p.tooBig:
+		return &ProbeResult{Size: size, ICMPErr: &ICMPError{Type: 3, Code: 4, MTU: p.mtu}}
+	default:
+		return &ProbeResult{Size: size, Error: os.ErrDeadlineExceeded}
+	}
+}
+
+func (p *blackholeProber) Close() error { return nil }
+
+func TestBlackholeReportClassify(t *testing.T) {
+	pass := func(name string, size int) BlackholeCheck {
+		return BlackholeCheck{Name: name, Status: checkPass, Size: size}
+	}
+	fail := func(name, outcome string) BlackholeCheck {
+		return BlackholeCheck{Name: name, Status: checkFail, Outcome: outcome}
+	}
+	skipped := func(name string) BlackholeCheck { return BlackholeCheck{Name: name, Status: checkSkipped} }
+
+	tests := []struct {
+		name    string
+		checks  []BlackholeCheck
+		ipv6    bool
+		verdict string
+		summary string
+		pmtu    int
+		mss     int
+	}{
+		{"answered", []BlackholeCheck{pass(checkICMPSmall, 576), pass(checkICMPLargeDF, 1500)}, false, verdictHealthy, "healthy", 1500, 1460},
+		{"frag needed", []BlackholeCheck{pass(checkICMPSmall, 576), fail(checkICMPLargeDF, "icmp-frag-needed(mtu=1400)")}, false, verdictHealthy, "healthy", 0, 0},
+		{"black hole", []BlackholeCheck{pass(checkICMPSmall, 1280), fail(checkICMPLargeDF, string(OutcomeNoResponse)), pass(checkICMPSearch, 1420)}, true, verdictBlackHole, "PMTUD black hole at ~1420 bytes", 1420, 1360},
+		{"black hole, search failed", []BlackholeCheck{pass(checkICMPSmall, 576), fail(checkICMPLargeDF, string(OutcomeNoResponse)), fail(checkICMPSearch, "")}, false, verdictBlackHole, "PMTUD black hole below 1500 bytes", 0, 0},
+		{"filtered", []BlackholeCheck{fail(checkICMPSmall, string(OutcomeNoResponse)), pass(checkPLPMTUD, 1492)}, false, verdictICMPFiltered, "ICMP filtered", 1492, 1452},
+		{"unprivileged", []BlackholeCheck{skipped(checkICMPSmall), skipped(checkPLPMTUD)}, false, verdictInconclusive, "inconclusive", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &BlackholeReport{Checks: tt.checks}
+			report.classify(tt.ipv6, 1500)
+			if report.Verdict != tt.verdict || report.Summary != tt.summary || report.PMTU != tt.pmtu || report.SuggestedMSS != tt.mss {
+				t.Fatalf("got %s %q, PMTU %d, MSS %d; want %s %q, PMTU %d, MSS %d",
+					report.Verdict, report.Summary, report.PMTU, report.SuggestedMSS, tt.verdict, tt.summary, tt.pmtu, tt.mss)
+			}
+		})
+	}
+}
+
+func TestRunBlackhole(t *testing.T) {
+	originalDial, originalRead := dialClamp, readClampMSS
+	originalProber, originalCanProbe, originalDiscovery := newBlackholeICMPProber, blackholeCanProbeICMP, blackholeMTUDiscovery
+	t.Cleanup(func() {
+		dialClamp, readClampMSS = originalDial, originalRead
+		newBlackholeICMPProber, blackholeCanProbeICMP, blackholeMTUDiscovery = originalProber, originalCanProbe, originalDiscovery
+	})
+
+	dialClamp = func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+		local, remote := net.Pipe()
+		_ = remote.Close()
+		return clampConn{Conn: local, addr: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 443}}, nil
+	}
+	readClampMSS = func(conn net.Conn) (int, bool, error) { return 1460, false, nil }
+	blackholeCanProbeICMP = func(bool) bool { return true }
+
+	// A tunnel carries 1400 bytes and its router never reports Fragmentation Needed
+	prober := &blackholeProber{mtu: 1400}
+	newBlackholeICMPProber = func(opts discoveryOptions) (blackholeICMPProber, error) { return prober, nil }
+	blackholeMTUDiscovery = func(ctx context.Context, opts discoveryOptions) (*MTUResult, error) {
+		if opts.Protocol != "icmp" || opts.MaxMTU != 1500 {
+			t.Errorf("search over %s up to %d, want icmp up to 1500", opts.Protocol, opts.MaxMTU)
+		}
+		return &MTUResult{PMTU: prober.mtu}, nil
+	}
+
+	cmd := newDiscoveryOptionsCommand()
+	mustSetFlag(t, cmd, "allow-doc-ranges", "true")
+	out, err := captureStdout(t, func() error { return runBlackhole(cmd, []string{"192.0.2.1"}) })
+	if err != nil {
+		t.Fatalf("runBlackhole returned error: %v", err)
+	}
+	if got := len(prober.sizes); got != 1+blackholeLargeAttempts || prober.sizes[0] != 576 || prober.sizes[1] != 1500 {
+		t.Fatalf("probed sizes %v, want 576 then 1500 %d times", prober.sizes, blackholeLargeAttempts)
+	}
+	for _, want := range []string{
+		"tcp-large-mss   pass     -      port 443 negotiated MSS 1460",
+		"icmp-large-df   fail     1500   1500-byte echo went unanswered [no-response]",
+		"plpmtud         skipped",
+		"Verdict: PMTUD black hole at ~1400 bytes",
+		"Suggested MSS clamp: 1360 (1348 with timestamps) for PMTU 1400",
+		"TCP negotiated MSS 1460, 100 bytes more than the path carries",
+		"--set-mss 1360",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	// Routers that report the size keep PMTUD working, so no search is needed
+	prober = &blackholeProber{mtu: 1400, tooBig: true}
+	blackholeMTUDiscovery = func(ctx context.Context, opts discoveryOptions) (*MTUResult, error) {
+		t.Fatal("searched although the large echo drew Fragmentation Needed")
+		return nil, nil
+	}
+	cmd = newDiscoveryOptionsCommand()
+	mustSetFlag(t, cmd, "allow-doc-ranges", "true")
+	mustSetFlag(t, cmd, "json", "true")
+	out, err = captureStdout(t, func() error { return runBlackhole(cmd, []string{"192.0.2.1"}) })
+	if err != nil {
+		t.Fatalf("runBlackhole returned error: %v", err)
+	}
+	var report BlackholeReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out, err)
+	}
+	if report.Verdict != verdictHealthy || report.check(checkICMPLargeDF).Outcome != "icmp-frag-needed(mtu=1400)" {
+		t.Fatalf("verdict %s, large check %+v; want healthy after Fragmentation Needed", report.Verdict, report.check(checkICMPLargeDF))
+	}
+}
@@ -0,0 +1,112 @@
+package mtu
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/family"
+)
+
+// errRawICMPUnavailable is returned when an ICMP-only mode is requested from a minimal build
+var errRawICMPUnavailable = errcode.Wrap(errcode.MTURawICMPDisabled, errors.New("raw ICMP probing is disabled in minimal builds; use --proto tcp or --proto udp"))
+
+// Capability reports whether an MTU subsystem can run in this build and environment
+type Capability struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// platformSocketFeatures lists the probe socket options the OS implements;
+// each socket_options_<os>.go declares its own as socketFeatures
+type platformSocketFeatures struct {
+	DontFragment  bool // DF on probe sockets; without it probes fragment and every size passes
+	SetMSS        bool // TCP_MAXSEG before connecting, so TCP probes leave as one segment
+	ReadMSS       bool // The MSS a connection negotiated
+	TCPTimestamps bool // Whether timestamps take 12 bytes of each segment
+	PathMTU       bool // The path MTU the kernel cached from Fragmentation Needed errors
+}
+
+// socketCapabilities reports the socket options of features, naming what
+// probing loses without each one
+func socketCapabilities(features platformSocketFeatures, goos string) []Capability {
+	unsupported := func(name string, available bool, impact string) Capability {
+		capability := Capability{Name: name, Available: available}
+		if !available {
+			capability.Reason = fmt.Sprintf("not supported on %s; %s", goos, impact)
+		}
+		return capability
+	}
+	return []Capability{
+		unsupported("dont-fragment", features.DontFragment, "probes may be fragmented, so discovery overestimates the PMTU"),
+		unsupported("tcp-set-mss", features.SetMSS, "TCP probes cannot be sized"),
+		unsupported("tcp-read-mss", features.ReadMSS, "mtu clamp cannot run and TCP probes are not checked against the negotiated MSS"),
+		unsupported("tcp-timestamp", features.TCPTimestamps, "TCP probes assume no timestamps option"),
+		unsupported("path-mtu", features.PathMTU, "UDP probes rejected locally do not report the kernel's path MTU"),
+	}
+}
+
+// Capabilities checks each MTU subsystem against the build profile and, for
+// raw sockets, the current privileges in the preferred IP version. Opening a
+// socket sends no packets.
+func Capabilities() []Capability {
+	network := "ip4:icmp"
+	if family.PreferIPv6() {
+		network = "ip6:ipv6-icmp"
+	}
+
+	rawICMP := Capability{Name: "icmp", Available: rawICMPSupported}
+	if !rawICMPSupported {
+		rawICMP.Reason = "disabled in minimal build"
+	} else if conn, err := listenDiscoverPacket(network, ""); err != nil {
+		rawICMP.Available = false
+		rawICMP.Reason = fmt.Sprintf("cannot open raw ICMP socket (needs root or CAP_NET_RAW): %v", err)
+	} else {
+		_ = conn.Close()
+	}
+
+	hops := rawICMP
+	hops.Name = "hop-by-hop"
+	ptb := rawICMP
+	ptb.Name = "ptb-listener"
+
+	// Echo alone still works over an unprivileged datagram socket, which
+	// minimal builds keep
+	icmpProbe := rawICMP
+	if datagramICMPSupported && !rawICMP.Available {
+		if conn, err := listenICMPDatagram(family.PreferIPv6()); err == nil {
+			_ = conn.Close()
+			icmpProbe.Available = true
+			icmpProbe.Reason = "unprivileged datagram socket; Fragmentation Needed errors are not seen"
+		} else if !rawICMPSupported {
+			icmpProbe.Reason = fmt.Sprintf("raw ICMP disabled in minimal build, and no unprivileged ICMP socket: %v", err)
+		}
+	}
+
+	tcp := Capability{Name: "tcp", Available: socketFeatures.SetMSS}
+	if !tcp.Available {
+		tcp.Reason = fmt.Sprintf("TCP_MAXSEG cannot be set on %s", runtime.GOOS)
+	}
+
+	capabilities := []Capability{
+		icmpProbe,
+		hops,
+		ptb,
+		tcp,
+		{Name: "udp", Available: true},
+		{Name: "tls", Available: true},
+		{Name: "plpmtud", Available: true},
+		{Name: "peer", Available: true},
+	}
+	return append(capabilities, socketCapabilities(socketFeatures, runtime.GOOS)...)
+}
+
+// warnUnsupportedDontFragment warns once a discovery starts on a platform
+// that cannot set DF, where its results cannot be trusted
+func (d *MTUDiscoverer) warnUnsupportedDontFragment() {
+	if !socketFeatures.DontFragment {
+		d.warningf("Don't Fragment cannot be set on %s, so probes may be fragmented and the PMTU overestimated", runtime.GOOS)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !minimal
+
+package mtu
+
+// rawICMPSupported reports whether this build includes the raw ICMP subsystems
+// (ICMP probing, hop-by-hop discovery, and the PTB listener)
+const rawICMPSupported = true
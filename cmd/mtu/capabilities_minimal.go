@@ -0,0 +1,7 @@
+//go:build minimal
+
+package mtu
+
+// rawICMPSupported is false in minimal builds, which target unprivileged
+// scratch containers where raw sockets are never available
+const rawICMPSupported = false
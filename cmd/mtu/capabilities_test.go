@@ -0,0 +1,145 @@
+package mtu
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestCapabilitiesReportsRawSocketFailure(t *testing.T) {
+	originalListen, originalDatagram := listenDiscoverPacket, listenICMPDatagram
+	t.Cleanup(func() { listenDiscoverPacket, listenICMPDatagram = originalListen, originalDatagram })
+
+	listenDiscoverPacket = func(network, address string) (net.PacketConn, error) {
+		return nil, errors.New("operation not permitted")
+	}
+	listenICMPDatagram = func(ipv6 bool) (*net.UDPConn, error) {
+		return nil, errors.New("permission denied")
+	}
+
+	byName := make(map[string]Capability)
+	for _, capability := range Capabilities() {
+		byName[capability.Name] = capability
+	}
+
+	for _, name := range []string{"icmp", "hop-by-hop", "ptb-listener"} {
+		capability := byName[name]
+		if capability.Available {
+			t.Fatalf("expected %s to be unavailable without raw sockets", name)
+		}
+		wantReason := "CAP_NET_RAW"
+		if !rawICMPSupported {
+			wantReason = "minimal build"
+		}
+		if !strings.Contains(capability.Reason, wantReason) {
+			t.Fatalf("unexpected %s reason: %q", name, capability.Reason)
+		}
+	}
+	for _, name := range []string{"udp", "tls", "peer"} {
+		if !byName[name].Available {
+			t.Fatalf("expected %s to be available", name)
+		}
+	}
+	if byName["tcp"].Available != socketFeatures.SetMSS {
+		t.Fatalf("tcp available = %v, want it to follow TCP_MAXSEG support", byName["tcp"].Available)
+	}
+	if byName["dont-fragment"].Available != socketFeatures.DontFragment {
+		t.Fatalf("dont-fragment available = %v, want %v", byName["dont-fragment"].Available, socketFeatures.DontFragment)
+	}
+}
+
+// TestCapabilitiesReportsUnprivilegedICMP also runs under -tags minimal,
+// where the datagram socket is the only way to send echo probes
+func TestCapabilitiesReportsUnprivilegedICMP(t *testing.T) {
+	if !datagramICMPSupported {
+		t.Skip("unprivileged ICMP sockets need Linux or macOS")
+	}
+	originalListen, originalDatagram := listenDiscoverPacket, listenICMPDatagram
+	t.Cleanup(func() { listenDiscoverPacket, listenICMPDatagram = originalListen, originalDatagram })
+
+	listenDiscoverPacket = func(network, address string) (net.PacketConn, error) {
+		return nil, errors.New("operation not permitted")
+	}
+	listenICMPDatagram = func(ipv6 bool) (*net.UDPConn, error) {
+		return net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	}
+
+	byName := make(map[string]Capability)
+	for _, capability := range Capabilities() {
+		byName[capability.Name] = capability
+	}
+	if icmp := byName["icmp"]; !icmp.Available || !strings.Contains(icmp.Reason, "unprivileged") {
+		t.Fatalf("expected icmp over an unprivileged socket, got %+v", icmp)
+	}
+	for _, name := range []string{"hop-by-hop", "ptb-listener"} {
+		if byName[name].Available {
+			t.Fatalf("expected %s to still need a raw socket", name)
+		}
+	}
+	if !rawICMPSupported && !strings.Contains(byName["hop-by-hop"].Reason, "minimal build") {
+		t.Fatalf("unexpected hop-by-hop reason in a minimal build: %q", byName["hop-by-hop"].Reason)
+	}
+	if !canProbeICMP(false) {
+		t.Fatal("expected echo probes to be possible without a raw socket")
+	}
+}
+
+func TestSocketCapabilities(t *testing.T) {
+	capabilities := socketCapabilities(platformSocketFeatures{DontFragment: true, ReadMSS: true, PathMTU: true}, "windows")
+
+	var names []string
+	for _, capability := range capabilities {
+		names = append(names, capability.Name)
+		if capability.Available != (capability.Reason == "") {
+			t.Fatalf("%s: available %v with reason %q", capability.Name, capability.Available, capability.Reason)
+		}
+	}
+	if got := strings.Join(names, " "); got != "dont-fragment tcp-set-mss tcp-read-mss tcp-timestamp path-mtu" {
+		t.Fatalf("unexpected capabilities %q", got)
+	}
+	if reason := capabilities[1].Reason; !strings.Contains(reason, "windows") || !strings.Contains(reason, "TCP probes") {
+		t.Fatalf("unexpected tcp-set-mss reason %q", reason)
+	}
+}
+
+func TestReadDiscoveryOptionsRespectsBuildProfile(t *testing.T) {
+	cmd := newDiscoveryOptionsCommand()
+	opts, err := readDiscoveryOptions(cmd, "example.com")
+	if err != nil {
+		t.Fatalf("readDiscoveryOptions returned error: %v", err)
+	}
+	wantProtocol := "icmp"
+	if !rawICMPSupported {
+		wantProtocol = "tcp"
+	}
+	if opts.Protocol != wantProtocol {
+		t.Fatalf("unexpected default protocol %q for this build", opts.Protocol)
+	}
+
+	cmd = newDiscoveryOptionsCommand()
+	mustSetFlag(t, cmd, "proto", "icmp")
+	_, err = readDiscoveryOptions(cmd, "example.com")
+	if (rawICMPSupported || datagramICMPSupported) && err != nil {
+		t.Fatalf("explicit ICMP should be accepted where echo probes can be sent: %v", err)
+	}
+	if !rawICMPSupported && !datagramICMPSupported && !errors.Is(err, errRawICMPUnavailable) {
+		t.Fatalf("expected errRawICMPUnavailable in minimal builds without datagram ICMP, got %v", err)
+	}
+}
+
+func TestCapabilitiesUsePreferredFamily(t *testing.T) {
+	originalListen := listenDiscoverPacket
+	t.Cleanup(func() { listenDiscoverPacket = originalListen })
+	preferFamily(t, "6")
+
+	var networks []string
+	listenDiscoverPacket = func(network, address string) (net.PacketConn, error) {
+		networks = append(networks, network)
+		return nil, errors.New("operation not permitted")
+	}
+	Capabilities()
+	if rawICMPSupported && (len(networks) != 1 || networks[0] != "ip6:ipv6-icmp") {
+		t.Fatalf("expected one IPv6 raw socket check, got %v", networks)
+	}
+}
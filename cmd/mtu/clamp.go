@@ -0,0 +1,246 @@
+package mtu
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/units"
+	"github.com/spf13/cobra"
+)
+
+// How the negotiated MSS compares with what the PMTU allows
+const (
+	clampClamped   = "clamped"     // Below the PMTU's MSS: something rewrote the SYN-ACK, or the server advertises less
+	clampNone      = "not-clamped" // Exactly what the PMTU allows
+	clampAbovePMTU = "above-pmtu"  // More than the PMTU carries: nothing on the path clamps, so TCP relies on PMTUD
+)
+
+// defaultClampPort is where clamp connects without --port
+const defaultClampPort = 443
+
+var clampMTUDiscovery = performMTUDiscovery
+
+// dialClamp opens the connection whose handshake is inspected
+var dialClamp = func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	return dialer.DialContext(ctx, network, address)
+}
+
+// readClampMSS returns the send MSS the kernel settled on for conn, which
+// excludes TCP options, and whether timestamps take 12 bytes of each segment
+var readClampMSS = func(conn net.Conn) (int, bool, error) {
+	mss, err := getTCPMSS(conn)
+	if err != nil {
+		return 0, false, err
+	}
+	timestamps, err := tcpTimestampsEnabled(conn)
+	return mss, timestamps, err
+}
+
+// clampCmd represents the clamp command
+var clampCmd = &cobra.Command{
+	Use:   "clamp <destination>",
+	Short: "Detect TCP MSS clamping on the path",
+	Long: `Clamp opens a TCP connection to the destination (--port, default 443) without
+lowering its own MSS, so the SYN advertises all the local interface allows,
+and reads the MSS the handshake settled on. It then discovers the Path-MTU and
+compares the two:
+
+• clamped: the MSS is below PMTU - 40 (IPv4) or - 60 (IPv6). A middlebox
+  rewrote the MSS option, as PPPoE and VPN gateways do, or the server
+  advertises less than its link allows.
+• not-clamped: the MSS is exactly what the PMTU allows.
+• above-pmtu: the MSS is larger than the PMTU carries. Nothing on the path
+  clamps it, so full-size segments depend on PMTUD and black-hole where
+  ICMP is filtered.
+
+The handshake runs before discovery, since the kernel also lowers the MSS to
+a path MTU it has learned from Fragmentation Needed errors; a PMTU learned by
+an earlier command can still hide a clamp. Discovery honors --proto, --min,
+--max, and --timeout. --pmtu compares with a known PMTU instead, and then only
+the handshake is sent.`,
+	Args:        cobra.ExactArgs(1),
+	RunE:        runClamp,
+	Annotations: dryRunAnnotations,
+}
+
+func init() {
+	units.Size(clampCmd.Flags(), "pmtu", 0, "Path MTU to compare with instead of discovering it; only the handshake is sent")
+}
+
+// ClampResult compares the MSS a TCP handshake negotiated with the PMTU
+type ClampResult struct {
+	Target        string `json:"target"`
+	Addr          string `json:"addr"`
+	Port          int    `json:"port"`
+	PMTU          int    `json:"pmtu"`
+	PMTUSource    string `json:"pmtu_source"`        // measured or assumed
+	Protocol      string `json:"protocol,omitempty"` // Discovery protocol, when measured
+	ExpectedMSS   int    `json:"expected_mss"`       // What the PMTU allows
+	NegotiatedMSS int    `json:"negotiated_mss"`     // The MSS the handshake settled on
+	SegmentMSS    int    `json:"segment_mss"`        // NegotiatedMSS less the timestamps option
+	Timestamps    bool   `json:"timestamps"`
+	Status        string `json:"status"` // clamped, not-clamped, or above-pmtu
+}
+
+func runClamp(cmd *cobra.Command, args []string) error {
+	format, err := readOutputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	opts, err := readDiscoveryOptions(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	if opts.HopsMode {
+		return errcode.Errorf(errcode.CLIUsage, "--hops is only supported by mtu discover")
+	}
+	if opts.Protocol == protocolAll {
+		return errcode.Errorf(errcode.CLIUsage, "--proto all is only supported by mtu discover")
+	}
+	pmtu, _ := cmd.Flags().GetInt("pmtu")
+	assumed := pmtu != 0 || cmd.Flags().Changed("pmtu")
+	if assumed && (pmtu < minSuggestionPMTU || pmtu > maxSuggestionPMTU) {
+		return errcode.Errorf(errcode.CLIUsage, "--pmtu must be between %d and %d", minSuggestionPMTU, maxSuggestionPMTU)
+	}
+	if opts, err = selectAddressFamily(opts, !opts.DryRun); err != nil {
+		return err
+	}
+
+	port := defaultClampPort
+	if opts.Port > 0 {
+		port = opts.Port
+	}
+	plans := []dryRunPlan{newClampHandshakePlan(opts, port)}
+	if !assumed {
+		plans = append(plans, newDryRunPlan(opts))
+	}
+	if opts.DryRun {
+		return outputDryRunPlans(plans, format)
+	}
+	if err := checkProbeTargets(cmd, plans...); err != nil {
+		return err
+	}
+	if err := recordProbeAudit(cmd, plans...); err != nil {
+		return err
+	}
+
+	ctx, cancel := newDiscoveryContext(opts)
+	defer cancel()
+
+	result, err := clampHandshake(ctx, opts, port)
+	if err != nil {
+		return err
+	}
+	result.PMTU, result.PMTUSource = pmtu, pmtuAssumed
+	if !assumed {
+		discovered, err := clampMTUDiscovery(ctx, opts)
+		if err != nil {
+			return withDiscoveryErrorCode(fmt.Errorf("MTU discovery failed (give the PMTU with --pmtu to skip it): %w", err), opts.Protocol)
+		}
+		result.PMTU, result.PMTUSource, result.Protocol = discovered.PMTU, pmtuMeasured, opts.Protocol
+	}
+	result.classify(opts.IPv6)
+
+	if format.structured() {
+		return format.write(result)
+	}
+	return outputClampTable(result)
+}
+
+// newClampHandshakePlan describes the one connection clamp opens: SYN, ACK,
+// and FIN, each a bare header
+func newClampHandshakePlan(opts discoveryOptions, port int) dryRunPlan {
+	header := tcpPacketOverhead(opts.IPv6)
+	return dryRunPlan{
+		Target:              opts.Destination,
+		Protocol:            "tcp",
+		Port:                port,
+		Mode:                "handshake",
+		MinSize:             header,
+		MaxSize:             header,
+		MaxProbes:           1,
+		MaxPackets:          3,
+		MaxBytes:            3 * header,
+		EstimatedDurationMS: opts.Timeout.Milliseconds(),
+	}
+}
+
+// clampHandshake connects to the destination and reads the negotiated MSS
+func clampHandshake(ctx context.Context, opts discoveryOptions, port int) (*ClampResult, error) {
+	network := "tcp4"
+	if opts.IPv6 {
+		network = "tcp6"
+	}
+	host, err := resolveProbeHost(opts.Destination, opts.IPv6)
+	if err != nil {
+		return nil, errcode.Wrap(errcode.MTUResolveFailed, fmt.Errorf("failed to resolve %s: %w", opts.Destination, err))
+	}
+	conn, err := dialClamp(ctx, network, net.JoinHostPort(host, strconv.Itoa(port)), opts.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("TCP handshake with %s port %d failed: %w", opts.Destination, port, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	mss, timestamps, err := readClampMSS(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the negotiated MSS: %w", err)
+	}
+	if mss <= 0 {
+		return nil, errcode.Errorf(errcode.MTUUnsupportedProtocol, "reading the negotiated MSS is not supported on this platform")
+	}
+
+	result := &ClampResult{
+		Target:     opts.Destination,
+		Port:       port,
+		SegmentMSS: mss,
+		Timestamps: timestamps,
+	}
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		result.Addr = addr.IP.String()
+	}
+	result.NegotiatedMSS = mss
+	if timestamps {
+		result.NegotiatedMSS += tcpTimestampOptionBytes
+	}
+	return result, nil
+}
+
+// classify compares the negotiated MSS with what r.PMTU allows
+func (r *ClampResult) classify(ipv6 bool) {
+	r.ExpectedMSS = tcpMSSForMTU(r.PMTU, ipv6)
+	switch {
+	case r.NegotiatedMSS < r.ExpectedMSS:
+		r.Status = clampClamped
+	case r.NegotiatedMSS > r.ExpectedMSS:
+		r.Status = clampAbovePMTU
+	default:
+		r.Status = clampNone
+	}
+}
+
+func outputClampTable(r *ClampResult) error {
+	fmt.Printf("Target: %s (%s port %d)\n", r.Target, r.Addr, r.Port)
+	pmtu := suggestionPMTU{PMTU: r.PMTU, Source: r.PMTUSource, Protocol: r.Protocol}
+	fmt.Printf("Path MTU: %d (%s)\n", r.PMTU, pmtu.describe())
+	fmt.Printf("Expected MSS: %d\n", r.ExpectedMSS)
+	if r.Timestamps {
+		fmt.Printf("Negotiated MSS: %d (%d per segment with timestamps)\n", r.NegotiatedMSS, r.SegmentMSS)
+	} else {
+		fmt.Printf("Negotiated MSS: %d\n", r.NegotiatedMSS)
+	}
+
+	switch r.Status {
+	case clampClamped:
+		fmt.Printf("Result: clamped to %d, %d bytes below what the PMTU allows\n", r.NegotiatedMSS, r.ExpectedMSS-r.NegotiatedMSS)
+	case clampAbovePMTU:
+		fmt.Printf("Result: not clamped, and %d bytes above what the PMTU carries; full-size segments rely on PMTUD\n", r.NegotiatedMSS-r.ExpectedMSS)
+	default:
+		fmt.Println("Result: not clamped; the MSS matches the PMTU")
+	}
+	return nil
+}
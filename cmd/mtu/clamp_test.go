@@ -0,0 +1,112 @@
+package mtu
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/units"
+	"github.com/spf13/cobra"
+)
+
+// clampConn is a connection to addr that carries nothing
+type clampConn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func (c clampConn) RemoteAddr() net.Addr { return c.addr }
+
+func TestClampResultClassify(t *testing.T) {
+	tests := []struct {
+		pmtu, mss int
+		ipv6      bool
+		want      string
+	}{
+		{1500, 1460, false, clampNone},
+		{1500, 1452, false, clampClamped},
+		{1492, 1460, false, clampAbovePMTU},
+		{1500, 1440, true, clampNone},
+		{1400, 1440, true, clampAbovePMTU},
+	}
+	for _, tt := range tests {
+		result := &ClampResult{PMTU: tt.pmtu, NegotiatedMSS: tt.mss}
+		result.classify(tt.ipv6)
+		if result.Status != tt.want {
+			t.Errorf("PMTU %d, MSS %d (IPv6 %v): status %q, want %q", tt.pmtu, tt.mss, tt.ipv6, result.Status, tt.want)
+		}
+	}
+}
+
+func TestRunClamp(t *testing.T) {
+	originalDial, originalRead, originalDiscovery := dialClamp, readClampMSS, clampMTUDiscovery
+	t.Cleanup(func() { dialClamp, readClampMSS, clampMTUDiscovery = originalDial, originalRead, originalDiscovery })
+
+	var dialed string
+	dialClamp = func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+		dialed = network + " " + address
+		local, remote := net.Pipe()
+		_ = remote.Close()
+		return clampConn{Conn: local, addr: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 443}}, nil
+	}
+	// A PPPoE gateway rewrote the MSS to 1452; timestamps take 12 bytes
+	readClampMSS = func(conn net.Conn) (int, bool, error) { return 1440, true, nil }
+	probed := false
+	clampMTUDiscovery = func(ctx context.Context, opts discoveryOptions) (*MTUResult, error) {
+		probed = true
+		return &MTUResult{Target: opts.Destination, PMTU: 1500}, nil
+	}
+
+	newCmd := func() *cobra.Command {
+		cmd := newDiscoveryOptionsCommand()
+		units.Size(cmd.Flags(), "pmtu", 0, "")
+		mustSetFlag(t, cmd, "allow-doc-ranges", "true")
+		return cmd
+	}
+
+	out, err := captureStdout(t, func() error { return runClamp(newCmd(), []string{"192.0.2.1"}) })
+	if err != nil {
+		t.Fatalf("runClamp returned error: %v", err)
+	}
+	if dialed != "tcp4 192.0.2.1:443" || !probed {
+		t.Fatalf("dialed %q (probed %v), want port 443 before discovery", dialed, probed)
+	}
+	// Minimal builds discover over TCP unless --proto says otherwise
+	wantProtocol := "icmp"
+	if !rawICMPSupported {
+		wantProtocol = "tcp"
+	}
+	for _, want := range []string{
+		"Path MTU: 1500 (measured over " + wantProtocol + ")",
+		"Negotiated MSS: 1452 (1440 per segment with timestamps)",
+		"Result: clamped to 1452, 8 bytes below what the PMTU allows",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	probed = false
+	cmd := newCmd()
+	mustSetFlag(t, cmd, "pmtu", "1492")
+	mustSetFlag(t, cmd, "port", "22")
+	mustSetFlag(t, cmd, "json", "true")
+	out, err = captureStdout(t, func() error { return runClamp(cmd, []string{"192.0.2.1"}) })
+	if err != nil || probed || dialed != "tcp4 192.0.2.1:22" {
+		t.Fatalf("--pmtu clamp = %v (probed %v, dialed %q)", err, probed, dialed)
+	}
+	for _, want := range []string{`"pmtu_source": "assumed"`, `"expected_mss": 1452`, `"status": "not-clamped"`, `"addr": "192.0.2.1"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("JSON missing %s:\n%s", want, out)
+		}
+	}
+
+	readClampMSS = func(conn net.Conn) (int, bool, error) { return 0, false, nil }
+	cmd = newCmd()
+	mustSetFlag(t, cmd, "pmtu", "1500")
+	if err := runClamp(cmd, []string{"192.0.2.1"}); err == nil || !strings.Contains(err.Error(), "not supported on this platform") {
+		t.Fatalf("expected an unsupported platform error, got %v", err)
+	}
+}
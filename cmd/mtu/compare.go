@@ -0,0 +1,410 @@
+package mtu
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+)
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:   "compare <before.json> <after.json>",
+	Short: "Diff two saved discovery results",
+	Long: `Compare reads two results saved with --format json, for example before and after a
+change window, and reports what moved: the Path-MTU and MSS delta, hops whose
+address changed, appeared, or disappeared, per-hop RTT deltas, and hops that
+started or stopped timing out.
+
+Both plain discovery results and hop-by-hop (--hops) results are accepted. Hop
+details are only compared when both files contain them.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runCompare,
+}
+
+// savedResult is the common shape of MTUResult and hop-by-hop JSON output
+type savedResult struct {
+	Target   string
+	Protocol string
+	PMTU     int
+	MSS      int
+	Hops     []savedHop // nil unless the file holds a hop-by-hop result
+}
+
+type savedHop struct {
+	Hop     int     `json:"hop"`
+	Addr    string  `json:"addr,omitempty"`
+	MTU     int     `json:"mtu,omitempty"`
+	RTT     float64 `json:"rtt"`
+	Timeout bool    `json:"timeout,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// intDelta is a before/after pair of a numeric field
+type intDelta struct {
+	Before int `json:"before"`
+	After  int `json:"after"`
+	Delta  int `json:"delta"`
+}
+
+// hopDiff describes how one hop differs between the two results
+type hopDiff struct {
+	Hop            int      `json:"hop"`
+	Change         string   `json:"change"` // unchanged, changed, added, or removed
+	BeforeAddr     string   `json:"before_addr,omitempty"`
+	AfterAddr      string   `json:"after_addr,omitempty"`
+	BeforeRTTMS    *float64 `json:"before_rtt_ms,omitempty"`
+	AfterRTTMS     *float64 `json:"after_rtt_ms,omitempty"`
+	RTTDeltaMS     *float64 `json:"rtt_delta_ms,omitempty"`
+	BeforeMTU      int      `json:"before_mtu,omitempty"`
+	AfterMTU       int      `json:"after_mtu,omitempty"`
+	AddrChanged    bool     `json:"addr_changed,omitempty"`
+	MTUChanged     bool     `json:"mtu_changed,omitempty"`
+	NewTimeout     bool     `json:"new_timeout,omitempty"`
+	TimeoutCleared bool     `json:"timeout_cleared,omitempty"`
+}
+
+// resultComparison is the diff of two saved results
+type resultComparison struct {
+	Before          string    `json:"before"`
+	After           string    `json:"after"`
+	Target          string    `json:"target"`
+	AfterTarget     string    `json:"after_target,omitempty"` // Set only when the targets differ
+	Protocol        string    `json:"protocol"`
+	AfterProtocol   string    `json:"after_protocol,omitempty"` // Set only when the protocols differ
+	PMTU            intDelta  `json:"pmtu"`
+	MSS             *intDelta `json:"mss,omitempty"`
+	Hops            []hopDiff `json:"hops,omitempty"`
+	AddrChanges     int       `json:"addr_changes"`
+	NewTimeouts     []int     `json:"new_timeouts"`
+	ClearedTimeouts []int     `json:"cleared_timeouts"`
+	Changed         bool      `json:"changed"`
+}
+
+func runCompare(cmd *cobra.Command, args []string) error {
+	before, err := readSavedResult(args[0])
+	if err != nil {
+		return err
+	}
+	after, err := readSavedResult(args[1])
+	if err != nil {
+		return err
+	}
+
+	comparison := compareResults(before, after)
+	comparison.Before = args[0]
+	comparison.After = args[1]
+
+	format, err := readOutputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	if format.structured() {
+		return format.write(comparison)
+	}
+	return outputComparisonTable(comparison)
+}
+
+// readSavedResult loads a result written by mtu discover --json, with or without --hops
+func readSavedResult(path string) (*savedResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errcode.Errorf(errcode.CLIUsage, "failed to read result: %w", err)
+	}
+
+	var raw struct {
+		Target    string          `json:"target"`
+		Protocol  string          `json:"protocol"`
+		PMTU      *int            `json:"pmtu"`
+		FinalPMTU *int            `json:"final_pmtu"`
+		MSS       int             `json:"mss"`
+		Hops      json.RawMessage `json:"hops"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, errcode.Errorf(errcode.CLIUsage, "%s is not a saved mtu result: %w", path, err)
+	}
+
+	result := &savedResult{Target: raw.Target, Protocol: raw.Protocol, MSS: raw.MSS}
+	switch {
+	case raw.FinalPMTU != nil:
+		result.PMTU = *raw.FinalPMTU
+		result.Hops = []savedHop{}
+		if len(raw.Hops) > 0 && !bytes.Equal(raw.Hops, []byte("null")) {
+			if err := json.Unmarshal(raw.Hops, &result.Hops); err != nil {
+				return nil, errcode.Errorf(errcode.CLIUsage, "%s has malformed hops: %w", path, err)
+			}
+		}
+	case raw.PMTU != nil:
+		result.PMTU = *raw.PMTU
+	default:
+		return nil, errcode.Errorf(errcode.CLIUsage, "%s is not a saved mtu result: no pmtu or final_pmtu field", path)
+	}
+	return result, nil
+}
+
+// compareResults diffs before against after
+func compareResults(before, after *savedResult) *resultComparison {
+	comparison := &resultComparison{
+		Target:          before.Target,
+		Protocol:        before.Protocol,
+		PMTU:            newIntDelta(before.PMTU, after.PMTU),
+		NewTimeouts:     []int{},
+		ClearedTimeouts: []int{},
+	}
+	if after.Target != before.Target {
+		comparison.AfterTarget = after.Target
+	}
+	if after.Protocol != before.Protocol {
+		comparison.AfterProtocol = after.Protocol
+	}
+	if before.MSS > 0 || after.MSS > 0 {
+		mss := newIntDelta(before.MSS, after.MSS)
+		comparison.MSS = &mss
+	}
+	comparison.Changed = comparison.PMTU.Delta != 0
+
+	if before.Hops == nil || after.Hops == nil {
+		return comparison
+	}
+
+	beforeHops := hopsByNumber(before.Hops)
+	afterHops := hopsByNumber(after.Hops)
+	for _, n := range mergedHopNumbers(before.Hops, after.Hops) {
+		diff := compareHop(n, beforeHops[n], afterHops[n])
+		if diff.AddrChanged {
+			comparison.AddrChanges++
+		}
+		if diff.NewTimeout {
+			comparison.NewTimeouts = append(comparison.NewTimeouts, n)
+		}
+		if diff.TimeoutCleared {
+			comparison.ClearedTimeouts = append(comparison.ClearedTimeouts, n)
+		}
+		if diff.Change != "unchanged" {
+			comparison.Changed = true
+		}
+		comparison.Hops = append(comparison.Hops, diff)
+	}
+	return comparison
+}
+
+func compareHop(n int, before, after *savedHop) hopDiff {
+	diff := hopDiff{Hop: n}
+	switch {
+	case before == nil:
+		diff.Change = "added"
+		diff.AfterAddr, diff.AfterMTU = after.Addr, after.MTU
+		diff.AfterRTTMS = hopRTT(after)
+		return diff
+	case after == nil:
+		diff.Change = "removed"
+		diff.BeforeAddr, diff.BeforeMTU = before.Addr, before.MTU
+		diff.BeforeRTTMS = hopRTT(before)
+		return diff
+	}
+
+	diff.BeforeAddr, diff.AfterAddr = before.Addr, after.Addr
+	diff.BeforeMTU, diff.AfterMTU = before.MTU, after.MTU
+	diff.BeforeRTTMS, diff.AfterRTTMS = hopRTT(before), hopRTT(after)
+	if diff.BeforeRTTMS != nil && diff.AfterRTTMS != nil {
+		delta := *diff.AfterRTTMS - *diff.BeforeRTTMS
+		diff.RTTDeltaMS = &delta
+	}
+
+	diff.AddrChanged = before.Addr != "" && after.Addr != "" && before.Addr != after.Addr
+	diff.MTUChanged = before.MTU > 0 && after.MTU > 0 && before.MTU != after.MTU
+	diff.NewTimeout = !before.Timeout && after.Timeout
+	diff.TimeoutCleared = before.Timeout && !after.Timeout
+
+	diff.Change = "unchanged"
+	if diff.AddrChanged || diff.MTUChanged || diff.NewTimeout || diff.TimeoutCleared {
+		diff.Change = "changed"
+	}
+	return diff
+}
+
+// hopRTT returns the hop's RTT in milliseconds, or nil when the hop did not answer
+func hopRTT(hop *savedHop) *float64 {
+	if hop.Timeout || hop.Error != "" {
+		return nil
+	}
+	rtt := hop.RTT
+	return &rtt
+}
+
+func newIntDelta(before, after int) intDelta {
+	return intDelta{Before: before, After: after, Delta: after - before}
+}
+
+func hopsByNumber(hops []savedHop) map[int]*savedHop {
+	byNumber := make(map[int]*savedHop, len(hops))
+	for i := range hops {
+		byNumber[hops[i].Hop] = &hops[i]
+	}
+	return byNumber
+}
+
+// mergedHopNumbers returns every hop number present in either list, in ascending order
+func mergedHopNumbers(before, after []savedHop) []int {
+	maxHop := 0
+	present := make(map[int]bool)
+	for _, hops := range [][]savedHop{before, after} {
+		for _, hop := range hops {
+			present[hop.Hop] = true
+			maxHop = max(maxHop, hop.Hop)
+		}
+	}
+
+	numbers := make([]int, 0, len(present))
+	for n := 1; n <= maxHop; n++ {
+		if present[n] {
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers
+}
+
+// outputComparisonTable prints the comparison in a form suitable for pasting into a change ticket
+func outputComparisonTable(c *resultComparison) error {
+	fmt.Printf("Comparing %s -> %s\n", c.Before, c.After)
+	if c.AfterTarget != "" {
+		fmt.Printf("Target: %s -> %s (targets differ)\n", c.Target, c.AfterTarget)
+	} else {
+		fmt.Printf("Target: %s\n", c.Target)
+	}
+	if c.AfterProtocol != "" {
+		fmt.Printf("Protocol: %s -> %s (protocols differ)\n", c.Protocol, c.AfterProtocol)
+	} else {
+		fmt.Printf("Protocol: %s\n", c.Protocol)
+	}
+	fmt.Printf("Path MTU: %s\n", formatIntDelta(c.PMTU, "bytes"))
+	if c.MSS != nil {
+		fmt.Printf("TCP MSS: %s\n", formatIntDelta(*c.MSS, "bytes"))
+	}
+
+	if len(c.Hops) > 0 {
+		fmt.Printf("\n%-4s %-15s %-15s %-10s %-10s %-10s %s\n", "Hop", "Before", "After", "RTT before", "RTT after", "RTT delta", "Change")
+		fmt.Printf("%-4s %-15s %-15s %-10s %-10s %-10s %s\n", "---", "---------------", "---------------", "----------", "----------", "----------", "------")
+		for _, hop := range c.Hops {
+			fmt.Printf("%-4d %-15s %-15s %-10s %-10s %-10s %s\n",
+				hop.Hop,
+				hopAddrLabel(hop.BeforeAddr, hop.Change == "added"),
+				hopAddrLabel(hop.AfterAddr, hop.Change == "removed"),
+				formatRTT(hop.BeforeRTTMS),
+				formatRTT(hop.AfterRTTMS),
+				formatRTTDelta(hop.RTTDeltaMS),
+				hopChangeLabel(hop))
+		}
+	}
+
+	fmt.Printf("\nSummary: %s\n", comparisonSummary(c))
+	return nil
+}
+
+func formatIntDelta(d intDelta, unit string) string {
+	if d.Delta == 0 {
+		return fmt.Sprintf("%d %s (unchanged)", d.After, unit)
+	}
+	return fmt.Sprintf("%d -> %d %s (%+d)", d.Before, d.After, unit, d.Delta)
+}
+
+func hopAddrLabel(addr string, absent bool) string {
+	switch {
+	case absent:
+		return "-"
+	case addr == "":
+		return "*"
+	default:
+		return addr
+	}
+}
+
+func formatRTT(rtt *float64) string {
+	if rtt == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2fms", *rtt)
+}
+
+func formatRTTDelta(delta *float64) string {
+	if delta == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%+.2fms", *delta)
+}
+
+func hopChangeLabel(hop hopDiff) string {
+	if hop.Change != "changed" {
+		return hop.Change
+	}
+
+	var parts []string
+	if hop.AddrChanged {
+		parts = append(parts, "address changed")
+	}
+	if hop.MTUChanged {
+		parts = append(parts, fmt.Sprintf("mtu %d -> %d", hop.BeforeMTU, hop.AfterMTU))
+	}
+	if hop.NewTimeout {
+		parts = append(parts, "new timeout")
+	}
+	if hop.TimeoutCleared {
+		parts = append(parts, "timeout cleared")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func comparisonSummary(c *resultComparison) string {
+	if !c.Changed {
+		return "no changes"
+	}
+
+	var parts []string
+	switch {
+	case c.PMTU.Delta < 0:
+		parts = append(parts, fmt.Sprintf("path MTU decreased by %d bytes", -c.PMTU.Delta))
+	case c.PMTU.Delta > 0:
+		parts = append(parts, fmt.Sprintf("path MTU increased by %d bytes", c.PMTU.Delta))
+	}
+	if c.AddrChanges > 0 {
+		parts = append(parts, fmt.Sprintf("%d hop address(es) changed", c.AddrChanges))
+	}
+
+	added, removed := 0, 0
+	for _, hop := range c.Hops {
+		switch hop.Change {
+		case "added":
+			added++
+		case "removed":
+			removed++
+		}
+	}
+	if added > 0 {
+		parts = append(parts, fmt.Sprintf("%d hop(s) added", added))
+	}
+	if removed > 0 {
+		parts = append(parts, fmt.Sprintf("%d hop(s) removed", removed))
+	}
+	if len(c.NewTimeouts) > 0 {
+		parts = append(parts, fmt.Sprintf("new timeouts at hop %s", joinInts(c.NewTimeouts)))
+	}
+	if len(c.ClearedTimeouts) > 0 {
+		parts = append(parts, fmt.Sprintf("timeouts cleared at hop %s", joinInts(c.ClearedTimeouts)))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "per-hop MTU changed")
+	}
+	return strings.Join(parts, "; ")
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%d", v)
+	}
+	return strings.Join(parts, ", ")
+}
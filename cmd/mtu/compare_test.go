@@ -0,0 +1,179 @@
+package mtu
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+const compareBeforeJSON = `{
+  "target": "example.com",
+  "protocol": "icmp",
+  "max_probe_size": 9216,
+  "final_pmtu": 1500,
+  "hops": [
+    {"hop": 1, "addr": "10.0.0.1", "mtu": 1500, "rtt": 1.2},
+    {"hop": 2, "addr": "10.0.1.1", "mtu": 1500, "rtt": 5.0},
+    {"hop": 3, "rtt": 2000, "timeout": true},
+    {"hop": 4, "addr": "192.0.2.10", "mtu": 1500, "rtt": 20.5}
+  ],
+  "elapsed_ms": 4000
+}`
+
+const compareAfterJSON = `{
+  "target": "example.com",
+  "protocol": "icmp",
+  "max_probe_size": 9216,
+  "final_pmtu": 1420,
+  "hops": [
+    {"hop": 1, "addr": "10.0.0.1", "mtu": 1500, "rtt": 1.5},
+    {"hop": 2, "addr": "10.0.2.1", "mtu": 1420, "rtt": 9.0},
+    {"hop": 3, "addr": "10.0.3.1", "mtu": 1420, "rtt": 12.0},
+    {"hop": 4, "rtt": 2000, "timeout": true},
+    {"hop": 5, "addr": "192.0.2.10", "mtu": 1420, "rtt": 24.0}
+  ],
+  "elapsed_ms": 5000
+}`
+
+func writeCompareFile(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadSavedResult(t *testing.T) {
+	t.Run("hop-by-hop result", func(t *testing.T) {
+		result, err := readSavedResult(writeCompareFile(t, "hops.json", compareBeforeJSON))
+		if err != nil {
+			t.Fatalf("readSavedResult returned error: %v", err)
+		}
+		if result.PMTU != 1500 || len(result.Hops) != 4 || !result.Hops[2].Timeout {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("plain discovery result", func(t *testing.T) {
+		result, err := readSavedResult(writeCompareFile(t, "plain.json", `{"target":"example.com","protocol":"tcp","pmtu":1400,"mss":1360,"hops":9,"elapsed_ms":12}`))
+		if err != nil {
+			t.Fatalf("readSavedResult returned error: %v", err)
+		}
+		if result.PMTU != 1400 || result.MSS != 1360 || result.Hops != nil {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("rejects unrelated JSON", func(t *testing.T) {
+		_, err := readSavedResult(writeCompareFile(t, "other.json", `{"domain":"example.com"}`))
+		if err == nil || errcode.Of(err) != errcode.CLIUsage {
+			t.Fatalf("expected usage error, got %v", err)
+		}
+	})
+}
+
+func TestCompareResults(t *testing.T) {
+	before, err := readSavedResult(writeCompareFile(t, "before.json", compareBeforeJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := readSavedResult(writeCompareFile(t, "after.json", compareAfterJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := compareResults(before, after)
+
+	if !c.Changed || c.PMTU.Delta != -80 {
+		t.Fatalf("unexpected PMTU delta: %+v", c.PMTU)
+	}
+	if len(c.Hops) != 5 {
+		t.Fatalf("expected 5 hop diffs, got %+v", c.Hops)
+	}
+
+	hop1 := c.Hops[0]
+	if hop1.Change != "unchanged" || hop1.RTTDeltaMS == nil || *hop1.RTTDeltaMS < 0.29 || *hop1.RTTDeltaMS > 0.31 {
+		t.Fatalf("unexpected hop 1 diff: %+v", hop1)
+	}
+	if hop2 := c.Hops[1]; !hop2.AddrChanged || !hop2.MTUChanged {
+		t.Fatalf("unexpected hop 2 diff: %+v", hop2)
+	}
+	if hop3 := c.Hops[2]; !hop3.TimeoutCleared || hop3.RTTDeltaMS != nil {
+		t.Fatalf("unexpected hop 3 diff: %+v", hop3)
+	}
+	if hop5 := c.Hops[4]; hop5.Change != "added" || hop5.AfterAddr != "192.0.2.10" {
+		t.Fatalf("unexpected hop 5 diff: %+v", hop5)
+	}
+	if len(c.NewTimeouts) != 1 || c.NewTimeouts[0] != 4 || len(c.ClearedTimeouts) != 1 || c.ClearedTimeouts[0] != 3 {
+		t.Fatalf("unexpected timeout changes: new=%v cleared=%v", c.NewTimeouts, c.ClearedTimeouts)
+	}
+	if c.AddrChanges != 1 {
+		t.Fatalf("expected 1 address change, got %d", c.AddrChanges)
+	}
+}
+
+func TestCompareResultsIdentical(t *testing.T) {
+	before, err := readSavedResult(writeCompareFile(t, "before.json", compareBeforeJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := compareResults(before, before)
+	if c.Changed || comparisonSummary(c) != "no changes" {
+		t.Fatalf("expected no changes, got %+v", c)
+	}
+}
+
+func TestRunCompare(t *testing.T) {
+	beforePath := writeCompareFile(t, "before.json", compareBeforeJSON)
+	afterPath := writeCompareFile(t, "after.json", compareAfterJSON)
+
+	t.Run("table", func(t *testing.T) {
+		cmd := newDiscoveryOptionsCommand()
+		output, err := captureStdout(t, func() error {
+			return runCompare(cmd, []string{beforePath, afterPath})
+		})
+		if err != nil {
+			t.Fatalf("runCompare returned error: %v", err)
+		}
+		for _, fragment := range []string{
+			"Path MTU: 1500 -> 1420 bytes (-80)",
+			"address changed, mtu 1500 -> 1420",
+			"+0.30ms",
+			"Summary: path MTU decreased by 80 bytes; 1 hop address(es) changed; 1 hop(s) added; new timeouts at hop 4; timeouts cleared at hop 3",
+		} {
+			if !strings.Contains(output, fragment) {
+				t.Fatalf("expected %q in output, got:\n%s", fragment, output)
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		cmd := newDiscoveryOptionsCommand()
+		mustSetFlag(t, cmd, "json", "true")
+		output, err := captureStdout(t, func() error {
+			return runCompare(cmd, []string{beforePath, afterPath})
+		})
+		if err != nil {
+			t.Fatalf("runCompare returned error: %v", err)
+		}
+
+		var payload struct {
+			PMTU        intDelta  `json:"pmtu"`
+			Hops        []hopDiff `json:"hops"`
+			NewTimeouts []int     `json:"new_timeouts"`
+			Changed     bool      `json:"changed"`
+		}
+		if err := json.Unmarshal([]byte(output), &payload); err != nil {
+			t.Fatalf("invalid JSON output: %v\n%s", err, output)
+		}
+		if payload.PMTU.Delta != -80 || len(payload.Hops) != 5 || !payload.Changed || len(payload.NewTimeouts) != 1 {
+			t.Fatalf("unexpected payload: %+v", payload)
+		}
+	})
+}
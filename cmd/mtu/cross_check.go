@@ -0,0 +1,189 @@
+package mtu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// protocolAll is the --proto value that runs every probe protocol side by side
+const protocolAll = "all"
+
+var crossCheckDiscovery = performMTUDiscovery
+
+// crossCheckProtocols lists the protocols --proto all runs, in report order.
+// Minimal builds have no raw ICMP and compare UDP with TCP only.
+func crossCheckProtocols() []string {
+	if rawICMPSupported {
+		return []string{"icmp", "udp", "tcp"}
+	}
+	return []string{"udp", "tcp"}
+}
+
+// protocolResult is one protocol's outcome in a cross-check
+type protocolResult struct {
+	Protocol  string       `json:"protocol"`
+	PMTU      int          `json:"pmtu,omitempty"`
+	MSS       int          `json:"mss,omitempty"`
+	ElapsedMS int          `json:"elapsed_ms,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	ErrorCode errcode.Code `json:"error_code,omitempty"`
+}
+
+// crossCheckResult reports all protocols together with a consistency verdict
+type crossCheckResult struct {
+	Target     string           `json:"target"`
+	Results    []protocolResult `json:"results"`
+	Consistent bool             `json:"consistent"`
+	Verdict    string           `json:"verdict"`
+	Warnings   []string         `json:"warnings"` // Each prefixed with its protocol
+}
+
+// crossCheckOptions returns the per-protocol options. The --pps budget is
+// split between protocols so the check is no more aggressive than one run.
+func crossCheckOptions(opts discoveryOptions) []discoveryOptions {
+	protocols := crossCheckProtocols()
+	perProtocol := make([]discoveryOptions, 0, len(protocols))
+	for _, protocol := range protocols {
+		protoOpts := opts
+		protoOpts.Protocol = protocol
+		if opts.PacketsPerSecond > 0 {
+			protoOpts.PacketsPerSecond = max(1, opts.PacketsPerSecond/len(protocols))
+		}
+		perProtocol = append(perProtocol, protoOpts)
+	}
+	return perProtocol
+}
+
+// runCrossCheck discovers the PMTU with every protocol concurrently. It only
+// returns an error when no protocol produced a result.
+func runCrossCheck(ctx context.Context, opts discoveryOptions) (*crossCheckResult, error) {
+	perProtocol := crossCheckOptions(opts)
+	results := make([]protocolResult, len(perProtocol))
+	errs := make([]error, len(perProtocol))
+	warnings := make([][]string, len(perProtocol))
+
+	var wg sync.WaitGroup
+	for i, protoOpts := range perProtocol {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = protocolResult{Protocol: protoOpts.Protocol}
+
+			result, err := crossCheckDiscovery(ctx, protoOpts)
+			if err != nil {
+				errs[i] = withDiscoveryErrorCode(fmt.Errorf("%s: %w", protoOpts.Protocol, err), protoOpts.Protocol)
+				results[i].Error = err.Error()
+				results[i].ErrorCode = errcode.Of(errs[i])
+				return
+			}
+			results[i].PMTU = result.PMTU
+			results[i].MSS = result.MSS
+			results[i].ElapsedMS = result.ElapsedMS
+			for _, warning := range result.Warnings {
+				warnings[i] = append(warnings[i], protoOpts.Protocol+": "+warning)
+			}
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed == len(errs) {
+		// Keep a shared cause's code (e.g. every protocol timed out); mixed causes
+		// mean nothing got through
+		code := errcode.Of(errs[0])
+		for _, err := range errs[1:] {
+			if errcode.Of(err) != code {
+				code = errcode.MTUNoWorkingSize
+			}
+		}
+		return nil, errcode.Wrap(code, fmt.Errorf("MTU discovery failed with every protocol: %w", errors.Join(errs...)))
+	}
+
+	check := &crossCheckResult{Target: opts.Destination, Results: results, Warnings: slices.Concat(warnings...)}
+	if check.Warnings == nil {
+		check.Warnings = []string{}
+	}
+	check.Consistent, check.Verdict = crossCheckVerdict(results)
+	return check, nil
+}
+
+// crossCheckVerdict explains whether the protocols agree. Disagreement is the
+// diagnostic: a protocol that fails or sees a smaller PMTU than the others is
+// being filtered or handled differently along the path.
+func crossCheckVerdict(results []protocolResult) (bool, string) {
+	var failed []string
+	byPMTU := make(map[int][]string)
+	for _, r := range results {
+		if r.Error != "" {
+			failed = append(failed, r.Protocol)
+			continue
+		}
+		byPMTU[r.PMTU] = append(byPMTU[r.PMTU], r.Protocol)
+	}
+
+	pmtus := make([]int, 0, len(byPMTU))
+	for pmtu := range byPMTU {
+		pmtus = append(pmtus, pmtu)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(pmtus)))
+
+	switch {
+	case len(failed) == 0 && len(pmtus) == 1:
+		return true, fmt.Sprintf("consistent: all protocols agree on PMTU %d", pmtus[0])
+	case len(failed) > 0 && len(pmtus) == 1:
+		return false, fmt.Sprintf("inconsistent: %s failed while %s found PMTU %d, so %s is likely filtered on this path",
+			strings.Join(failed, ", "), strings.Join(byPMTU[pmtus[0]], ", "), pmtus[0], strings.Join(failed, " and "))
+	default:
+		parts := make([]string, 0, len(pmtus))
+		for _, pmtu := range pmtus {
+			parts = append(parts, fmt.Sprintf("%s=%d", strings.Join(byPMTU[pmtu], "/"), pmtu))
+		}
+		verdict := fmt.Sprintf("inconsistent: PMTU differs by protocol (%s); something on the path treats protocols differently, such as MSS clamping or policy routing",
+			strings.Join(parts, ", "))
+		if len(failed) > 0 {
+			verdict += fmt.Sprintf("; %s failed", strings.Join(failed, ", "))
+		}
+		return false, verdict
+	}
+}
+
+// newCrossCheckContext budgets for the slowest protocol, since they run concurrently
+func newCrossCheckContext(opts discoveryOptions) (context.Context, context.CancelFunc) {
+	var budget time.Duration
+	for _, protoOpts := range crossCheckOptions(opts) {
+		budget = max(budget, discoveryTimeoutBudget(protoOpts))
+	}
+	return context.WithTimeout(context.Background(), budget)
+}
+
+func outputCrossCheckStructured(result *crossCheckResult, format outputFormat) error {
+	return format.write(result)
+}
+
+func outputCrossCheckTable(result *crossCheckResult) error {
+	fmt.Printf("Target: %s\n\n", result.Target)
+	fmt.Printf("%-8s %-6s %-6s %s\n", "Protocol", "PMTU", "MSS", "Status")
+	fmt.Printf("%-8s %-6s %-6s %s\n", "--------", "-----", "-----", "------")
+	for _, r := range result.Results {
+		if r.Error != "" {
+			fmt.Printf("%-8s %-6s %-6s [%s] %s\n", r.Protocol, "-", "-", r.ErrorCode, r.Error)
+			continue
+		}
+		fmt.Printf("%-8s %-6d %-6d ok (%dms)\n", r.Protocol, r.PMTU, r.MSS, r.ElapsedMS)
+	}
+	fmt.Printf("\nVerdict: %s\n", result.Verdict)
+	return nil
+}
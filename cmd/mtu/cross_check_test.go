@@ -0,0 +1,164 @@
+package mtu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/audit"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+func stubCrossCheckDiscovery(t *testing.T, fn func(opts discoveryOptions) (*MTUResult, error)) {
+	t.Helper()
+	original := crossCheckDiscovery
+	t.Cleanup(func() { crossCheckDiscovery = original })
+	crossCheckDiscovery = func(_ context.Context, opts discoveryOptions) (*MTUResult, error) {
+		return fn(opts)
+	}
+}
+
+func TestCrossCheckVerdict(t *testing.T) {
+	tests := []struct {
+		name       string
+		results    []protocolResult
+		consistent bool
+		contains   string
+	}{
+		{
+			name: "all agree",
+			results: []protocolResult{
+				{Protocol: "icmp", PMTU: 1500}, {Protocol: "udp", PMTU: 1500}, {Protocol: "tcp", PMTU: 1500},
+			},
+			consistent: true,
+			contains:   "all protocols agree on PMTU 1500",
+		},
+		{
+			name: "one protocol filtered",
+			results: []protocolResult{
+				{Protocol: "icmp", Error: "no working MTU found"}, {Protocol: "udp", PMTU: 1500}, {Protocol: "tcp", PMTU: 1500},
+			},
+			contains: "icmp failed while udp, tcp found PMTU 1500, so icmp is likely filtered",
+		},
+		{
+			name: "protocols disagree",
+			results: []protocolResult{
+				{Protocol: "icmp", PMTU: 1500}, {Protocol: "udp", PMTU: 1500}, {Protocol: "tcp", PMTU: 1400},
+			},
+			contains: "PMTU differs by protocol (icmp/udp=1500, tcp=1400)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consistent, verdict := crossCheckVerdict(tt.results)
+			if consistent != tt.consistent || !strings.Contains(verdict, tt.contains) {
+				t.Fatalf("crossCheckVerdict() = %v, %q; want %v containing %q", consistent, verdict, tt.consistent, tt.contains)
+			}
+		})
+	}
+}
+
+func TestCrossCheckOptionsSharePPS(t *testing.T) {
+	opts := discoveryOptions{Destination: "192.0.2.1", Protocol: protocolAll, PacketsPerSecond: 10}
+
+	perProtocol := crossCheckOptions(opts)
+	if len(perProtocol) != len(crossCheckProtocols()) {
+		t.Fatalf("expected one option set per protocol, got %d", len(perProtocol))
+	}
+	total := 0
+	for i, protoOpts := range perProtocol {
+		if protoOpts.Protocol != crossCheckProtocols()[i] {
+			t.Fatalf("unexpected protocol order: %+v", perProtocol)
+		}
+		total += protoOpts.PacketsPerSecond
+	}
+	if total > 10 {
+		t.Fatalf("combined pps %d exceeds --pps 10", total)
+	}
+}
+
+func TestRunDiscoverCrossCheck(t *testing.T) {
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	audit.SetPath(auditPath)
+	t.Cleanup(func() { audit.SetPath("") })
+
+	stubCrossCheckDiscovery(t, func(opts discoveryOptions) (*MTUResult, error) {
+		if opts.Protocol == "udp" {
+			return nil, fmt.Errorf("%w in range 576-1500", errNoWorkingMTU)
+		}
+		result := &MTUResult{Target: opts.Destination, Protocol: opts.Protocol, PMTU: 1500, MSS: 1460, Warnings: []string{}}
+		if opts.Protocol == "tcp" {
+			result.Warnings = append(result.Warnings, "failed to set DF flag via socket options")
+		}
+		return result, nil
+	})
+
+	cmd := newDiscoveryOptionsCommand()
+	cmd.Flags().Bool("dry-run", false, "")
+	mustSetFlag(t, cmd, "proto", "all")
+	mustSetFlag(t, cmd, "json", "true")
+	mustSetFlag(t, cmd, "allow-doc-ranges", "true")
+
+	output, err := captureStdout(t, func() error {
+		return runDiscover(cmd, []string{"192.0.2.1"})
+	})
+	if err != nil {
+		t.Fatalf("runDiscover returned error: %v", err)
+	}
+
+	var result crossCheckResult
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, output)
+	}
+	if result.Consistent || !strings.Contains(result.Verdict, "udp failed") {
+		t.Fatalf("unexpected verdict: %+v", result)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0] != "tcp: failed to set DF flag via socket options" {
+		t.Fatalf("expected the tcp warning labelled with its protocol, got %q", result.Warnings)
+	}
+	for _, r := range result.Results {
+		if r.Protocol == "udp" && r.ErrorCode != errcode.MTUNoWorkingSize {
+			t.Fatalf("expected udp failure to carry MTU010, got %+v", r)
+		}
+	}
+
+	entries, err := audit.ReadAll(auditPath)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected a single audit entry, got %v (%v)", entries, err)
+	}
+	if entries[0].Protocol != strings.Join(crossCheckProtocols(), ",") {
+		t.Fatalf("unexpected audited protocol: %q", entries[0].Protocol)
+	}
+}
+
+func TestRunCrossCheckAllFailed(t *testing.T) {
+	stubCrossCheckDiscovery(t, func(opts discoveryOptions) (*MTUResult, error) {
+		return nil, context.DeadlineExceeded
+	})
+
+	_, err := runCrossCheck(context.Background(), discoveryOptions{Destination: "192.0.2.1", Protocol: protocolAll})
+	if err == nil {
+		t.Fatal("expected error when every protocol fails")
+	}
+	if errcode.Of(err) != errcode.MTUTimeout {
+		t.Fatalf("expected shared timeout code, got %s", errcode.Of(err))
+	}
+}
+
+func TestProtoAllRejectedOutsideDiscover(t *testing.T) {
+	suggestCmd := newDiscoveryOptionsCommand()
+	mustSetFlag(t, suggestCmd, "proto", "all")
+	if err := runSuggest(suggestCmd, []string{"example.com"}); err == nil || !strings.Contains(err.Error(), "--proto all is only supported by mtu discover") {
+		t.Fatalf("expected suggest to reject --proto all, got %v", err)
+	}
+
+	watchCmd := newDiscoveryOptionsCommand()
+	mustSetFlag(t, watchCmd, "proto", "all")
+	if err := runWatch(watchCmd, []string{"example.com"}); err == nil || !strings.Contains(err.Error(), "--proto all is only supported by mtu discover") {
+		t.Fatalf("expected watch to reject --proto all, got %v", err)
+	}
+}
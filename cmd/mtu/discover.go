@@ -3,8 +3,11 @@ package mtu
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/units"
 	"github.com/spf13/cobra"
 )
 
@@ -15,12 +18,72 @@ var discoverCmd = &cobra.Command{
 	Long: `Discover performs Path-MTU discovery using binary search to find the largest
 packet size that can reach the destination without fragmentation.
 
-Examples:
-  cidrator mtu discover 8.8.8.8
-  cidrator mtu discover 2001:4860:4860::8888 --6
-  cidrator mtu discover example.com --proto tcp --json`,
-	Args: cobra.ExactArgs(1),
-	RunE: runDiscover,
+--proto all runs ICMP, UDP, and TCP discovery concurrently and reports the
+results side by side with a consistency verdict. A protocol that fails or finds
+a smaller PMTU than the others points to protocol-specific filtering. The --pps
+budget is shared between the protocols.
+
+--proto tls probes any HTTPS server without privileges: each probe sends a
+ClientHello padded to exactly the probe size over a connection whose
+TCP_MAXSEG is set to match, and a ServerHello back confirms the size:
+  cidrator mtu discover example.com --proto tls
+
+--train N follows ICMP discovery with N sequence-numbered echo probes sent
+--train-interval apart and reports loss, RTT, RFC 3550 jitter, and RFC 4737
+reordering alongside the PMTU. --pps still applies, so raise it for trains
+faster than 10 packets per second:
+  cidrator mtu discover voip-gw.example.com --train 100 --pps 50
+
+--parallel N sends N candidate sizes at once in each search round, spread
+evenly over the remaining range, instead of one size per round. The replies
+are matched to their probes by ICMP sequence number, or by socket for UDP and
+TCP. A round then waits out at most one timeout however many sizes fail, so
+lossy paths or black holes take a few rounds rather than tens of seconds. The
+probes still go out at --pps:
+  cidrator mtu discover vpn.example.com --parallel 4 --exhaustive
+
+--retries N resends a size that gets no answer at all up to N more times,
+backing off between tries, before the search counts it as too big. An ICMP
+Fragmentation Needed error is final. The result then carries a confidence
+score: the loss rate seen at sizes that got through, and the chance that no
+size was ruled out by loss alone. Each unanswered size costs up to N more
+timeouts, so pair it with --parallel on slow paths:
+  cidrator mtu discover lossy-link.example.com --retries 2
+
+--reuse-conn holds one TCP connection open and varies the segment size over
+it, instead of opening a connection per size, which is faster and does not
+look like a SYN scan to an IDS. The connection announces the MSS of --max and
+caps TCP_MAXSEG at each probe's size. A lost size leaves data queued on the
+connection, so the next size reconnects, and a peer that does not echo each
+probe back byte for byte (anything but 'cidrator mtu peer') drops the search
+back to a connection per size after its first answer:
+  cidrator mtu discover peer.example.com --proto tcp --port 4821 --reuse-conn
+
+--hops walks the path one TTL at a time and measures the MTU to each hop.
+--proto udp sends tracepath-style datagrams to high ports, and --proto tcp
+sends SYNs to --port (default 443), which pass firewalls that drop echo; SYNs
+find the hops but not their MTUs. Every mode needs a raw ICMP socket:
+  cidrator mtu discover example.com --hops --proto udp
+
+--enrich annotates --hops output with each hop's reverse DNS name and origin
+AS (from Team Cymru's IP to ASN service, over DNS), and groups contiguous hops
+by AS in the table so handoffs between networks stand out. Lookups are best
+effort; a hop whose lookup fails is shown without them:
+  cidrator mtu discover example.com --hops --enrich`,
+	Args:        cobra.ExactArgs(1),
+	RunE:        runDiscover,
+	Annotations: dryRunAnnotations,
+}
+
+func init() {
+	discoverCmd.Flags().Int("train", 0, fmt.Sprintf("Send a train of N echo probes after discovery to measure jitter and reordering (max %d)", maxTrainPackets))
+	units.Duration(discoverCmd.Flags(), "train-interval", defaultTrainInterval, "Gap between train probes")
+	units.Size(discoverCmd.Flags(), "train-size", 0, "Train probe size in bytes (0 = discovered PMTU)")
+	discoverCmd.Flags().Int("parallel", 1, fmt.Sprintf("Sizes to probe at once in each search round (1 = serial binary search, max %d)", maxParallelProbes))
+	discoverCmd.Flags().Int("retries", 0, fmt.Sprintf("Resend a size that gets no answer up to N times before counting it as too big, and report a confidence score (max %d)", maxProbeRetries))
+	discoverCmd.Flags().Bool("reuse-conn", false, "With --proto tcp, probe every size over one connection and reconnect only after a size is lost")
+	discoverCmd.Flags().Bool("enrich", false, "With --hops, add reverse DNS and origin ASN to each hop")
+	discoverCmd.Flags().Bool("verbose", false, "With --plpmtud, print each PLPMTUD state transition to stderr as it happens")
 }
 
 func runDiscover(cmd *cobra.Command, args []string) error {
@@ -29,14 +92,37 @@ func runDiscover(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	jsonOutput, _ := cmd.Flags().GetBool("json")
+	format, err := readOutputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	enrichOutput, _ := cmd.Flags().GetBool("enrich")
+	opts.TraceStates, _ = cmd.Flags().GetBool("verbose")
+	if enrichOutput && !opts.HopsMode {
+		return errcode.Errorf(errcode.CLIUsage, "--enrich requires --hops")
+	}
 
-	// Hop-by-hop discovery only supports ICMP
-	if opts.HopsMode && opts.Protocol != "icmp" {
-		return fmt.Errorf("hop-by-hop discovery only supports ICMP protocol")
+	// Hop-by-hop discovery needs probes whose TTL can be set
+	if opts.HopsMode && (opts.Protocol == "tls" || opts.Protocol == protocolAll) {
+		return errcode.Errorf(errcode.MTUUnsupportedProtocol, "hop-by-hop discovery supports ICMP, UDP, and TCP, not --proto %s", opts.Protocol)
+	}
+
+	if opts.Protocol == protocolAll {
+		return runDiscoverCrossCheck(cmd, opts, format)
+	}
+
+	plan := newDryRunPlan(opts)
+	if opts.DryRun {
+		return outputDryRun(plan, format)
+	}
+	if err := checkProbeTargets(cmd, plan); err != nil {
+		return err
+	}
+	if err := recordProbeAudit(cmd, plan); err != nil {
+		return err
 	}
 
-	if !opts.Quiet && !jsonOutput {
+	if !opts.Quiet && !format.structured() {
 		if opts.HopsMode {
 			fmt.Printf("Hop-by-hop MTU discovery to %s...\n", opts.Destination)
 			fmt.Printf("Protocol: %s, Max probe size: %d, Max hops: %d, Timeout: %v\n", opts.Protocol, opts.MaxMTU, opts.MaxHops, opts.Timeout)
@@ -55,12 +141,16 @@ func runDiscover(cmd *cobra.Command, args []string) error {
 
 	// Perform discovery based on mode
 	if opts.HopsMode {
-		discoverer, err := newMTUDiscoverer(opts)
+		opts, err := selectAddressFamily(opts, true)
 		if err != nil {
 			return err
 		}
-		if !jsonOutput && !opts.Quiet {
-			discoverer.SetProgressWriter(os.Stdout)
+		if !format.structured() && !opts.Quiet {
+			opts.ProgressOut = os.Stdout
+		}
+		discoverer, err := newMTUDiscoverer(opts)
+		if err != nil {
+			return err
 		}
 		defer func() {
 			if closeErr := discoverer.Close(); closeErr != nil && !opts.Quiet {
@@ -71,27 +161,68 @@ func runDiscover(cmd *cobra.Command, args []string) error {
 		// Hop-by-hop discovery
 		hopResult, err := discoverer.DiscoverHopByHopMTU(ctx, opts.MaxHops, opts.MaxMTU)
 		if err != nil {
-			return fmt.Errorf("hop-by-hop MTU discovery failed: %w", err)
+			return withDiscoveryErrorCode(fmt.Errorf("hop-by-hop MTU discovery failed: %w", err), opts.Protocol)
+		}
+		if enrichOutput {
+			enrichHops(hopResult.Hops)
 		}
+		hopResult.SourceAddr, hopResult.EgressInterface = discoverer.egress("")
+		hopResult.Warnings = discoverer.Warnings()
 
 		// Output hop-by-hop result
-		if jsonOutput {
-			return outputHopJSON(hopResult)
+		if format.structured() {
+			return outputHopStructured(hopResult, format)
 		}
 		return outputHopTable(hopResult)
 	}
 
 	result, err := performMTUDiscovery(ctx, opts)
 	if err != nil {
-		return fmt.Errorf("MTU discovery failed: %w", err)
+		return withDiscoveryErrorCode(fmt.Errorf("MTU discovery failed: %w", err), opts.Protocol)
 	}
 
-	if jsonOutput {
-		return outputJSON(result)
+	if format.structured() {
+		return outputStructured(result, format)
 	}
 	return outputTable(result)
 }
 
+func runDiscoverCrossCheck(cmd *cobra.Command, opts discoveryOptions, format outputFormat) error {
+	perProtocol := crossCheckOptions(opts)
+	plans := make([]dryRunPlan, 0, len(perProtocol))
+	for _, protoOpts := range perProtocol {
+		plans = append(plans, newDryRunPlan(protoOpts))
+	}
+
+	if opts.DryRun {
+		return outputDryRunPlans(plans, format)
+	}
+	if err := checkProbeTargets(cmd, plans...); err != nil {
+		return err
+	}
+	if err := recordProbeAudit(cmd, plans...); err != nil {
+		return err
+	}
+
+	if !opts.Quiet && !format.structured() {
+		fmt.Printf("Cross-checking MTU to %s over %s...\n", opts.Destination, strings.Join(crossCheckProtocols(), ", "))
+		fmt.Printf("Range: %d-%d, Timeout: %v\n", opts.MinMTU, opts.MaxMTU, opts.Timeout)
+	}
+
+	ctx, cancel := newCrossCheckContext(opts)
+	defer cancel()
+
+	result, err := runCrossCheck(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	if format.structured() {
+		return outputCrossCheckStructured(result, format)
+	}
+	return outputCrossCheckTable(result)
+}
+
 // MTUResult represents the result of MTU discovery
 type MTUResult struct {
 	Target    string `json:"target"`
@@ -100,41 +231,89 @@ type MTUResult struct {
 	MSS       int    `json:"mss"`
 	Hops      int    `json:"hops"`
 	ElapsedMS int    `json:"elapsed_ms"`
+
+	RTTMS           float64             `json:"rtt_ms,omitempty"`     // Round trip of the probe at the discovered PMTU
+	RTTMinMS        float64             `json:"rtt_min_ms,omitempty"` // Over every answered probe
+	RTTAvgMS        float64             `json:"rtt_avg_ms,omitempty"`
+	RTTMaxMS        float64             `json:"rtt_max_ms,omitempty"`
+	History         []ProbeSizeStats    `json:"probe_history,omitempty"`    // Every probe sent, by size
+	SourceAddr      string              `json:"source_addr,omitempty"`      // Local address the probes left from
+	EgressInterface string              `json:"egress_interface,omitempty"` // Interface holding SourceAddr
+	Train           *TrainResult        `json:"train,omitempty"`            // Set when --train is used
+	Confidence      *ProbeConfidence    `json:"confidence,omitempty"`       // Set when --retries is used
+	PLPMTUD         []PLPMTUDTransition `json:"plpmtud,omitempty"`          // State transitions when --plpmtud fell back to PLPMTUD
+	Warnings        []string            `json:"warnings"`                   // What degraded the measurement; also printed to stderr
 }
 
-func outputJSON(result *MTUResult) error {
-	return writePrettyJSON(result)
+func outputStructured(result *MTUResult, format outputFormat) error {
+	if result.Warnings == nil {
+		result.Warnings = []string{}
+	}
+	return format.write(result)
 }
 
 func outputTable(result *MTUResult) error {
 	fmt.Printf("Target: %s\n", result.Target)
 	fmt.Printf("Protocol: %s\n", result.Protocol)
+	if result.SourceAddr != "" {
+		fmt.Printf("Source: %s\n", formatSource(result.SourceAddr, result.EgressInterface))
+	}
 	fmt.Printf("Path MTU: %d\n", result.PMTU)
 	fmt.Printf("TCP MSS: %d\n", result.MSS)
 	fmt.Printf("Hops: %d\n", result.Hops)
 	fmt.Printf("Elapsed: %dms\n", result.ElapsedMS)
+	if result.RTTMS > 0 {
+		fmt.Printf("RTT: %.2fms\n", result.RTTMS)
+	}
+	if result.RTTMaxMS > 0 {
+		fmt.Printf("RTT min/avg/max: %.2f/%.2f/%.2f ms\n", result.RTTMinMS, result.RTTAvgMS, result.RTTMaxMS)
+	}
+	if c := result.Confidence; c != nil {
+		fmt.Printf("Confidence: %.1f%% (%.1f%% probe loss, %d resent)\n", 100*c.Score, 100*c.LossRate, c.Resent)
+	}
+	if len(result.PLPMTUD) > 0 {
+		states := make([]string, 0, len(result.PLPMTUD))
+		for _, transition := range result.PLPMTUD {
+			states = append(states, transition.To)
+		}
+		fmt.Printf("PLPMTUD states: %s\n", strings.Join(states, " -> "))
+	}
+	if len(result.History) > 0 {
+		outputProbeHistoryTable(result.History)
+	}
+	if result.Train != nil {
+		outputTrainTable(result.Train)
+	}
 	return nil
 }
 
-// outputHopJSON outputs hop-by-hop discovery results in JSON format
-func outputHopJSON(result *HopMTUResult) error {
-	type hopJSON struct {
+// outputHopStructured outputs hop-by-hop discovery results as JSON or YAML
+func outputHopStructured(result *HopMTUResult, format outputFormat) error {
+	type hopOutput struct {
 		Hop     int     `json:"hop"`
 		Addr    string  `json:"addr,omitempty"`
 		MTU     int     `json:"mtu,omitempty"`
 		RTT     float64 `json:"rtt"`
 		Timeout bool    `json:"timeout,omitempty"`
 		Error   string  `json:"error,omitempty"`
+
+		Name     string `json:"name,omitempty"`
+		ASN      uint32 `json:"asn,omitempty"`
+		ASHolder string `json:"as_holder,omitempty"`
 	}
 
-	hops := make([]hopJSON, 0, len(result.Hops))
+	hops := make([]hopOutput, 0, len(result.Hops))
 	for _, hop := range result.Hops {
-		entry := hopJSON{
+		entry := hopOutput{
 			Hop:     hop.Hop,
 			MTU:     hop.MTU,
 			RTT:     float64(hop.RTT) / float64(time.Millisecond),
 			Timeout: hop.Timeout,
 			Error:   hop.Error,
+
+			Name:     hop.Name,
+			ASN:      hop.ASN,
+			ASHolder: hop.ASHolder,
 		}
 		if hop.Addr != nil {
 			entry.Addr = hop.Addr.String()
@@ -142,13 +321,14 @@ func outputHopJSON(result *HopMTUResult) error {
 		hops = append(hops, entry)
 	}
 
-	return writePrettyJSON(struct {
-		Target       string    `json:"target"`
-		Protocol     string    `json:"protocol"`
-		MaxProbeSize int       `json:"max_probe_size"`
-		FinalPMTU    int       `json:"final_pmtu"`
-		Hops         []hopJSON `json:"hops"`
-		ElapsedMS    int       `json:"elapsed_ms"`
+	return format.write(struct {
+		Target       string      `json:"target"`
+		Protocol     string      `json:"protocol"`
+		MaxProbeSize int         `json:"max_probe_size"`
+		FinalPMTU    int         `json:"final_pmtu"`
+		Hops         []hopOutput `json:"hops"`
+		ElapsedMS    int         `json:"elapsed_ms"`
+		Warnings     []string    `json:"warnings"`
 	}{
 		Target:       result.Target,
 		Protocol:     result.Protocol,
@@ -156,6 +336,7 @@ func outputHopJSON(result *HopMTUResult) error {
 		FinalPMTU:    result.FinalPMTU,
 		Hops:         hops,
 		ElapsedMS:    result.ElapsedMS,
+		Warnings:     append([]string{}, result.Warnings...),
 	})
 }
 
@@ -164,6 +345,9 @@ func outputHopTable(result *HopMTUResult) error {
 	fmt.Printf("\nHop-by-hop MTU Discovery Results:\n")
 	fmt.Printf("Target: %s\n", result.Target)
 	fmt.Printf("Protocol: %s\n", result.Protocol)
+	if result.SourceAddr != "" {
+		fmt.Printf("Source: %s\n", formatSource(result.SourceAddr, result.EgressInterface))
+	}
 	fmt.Printf("Max probe size: %d bytes\n", result.MaxProbeSize)
 	if result.FinalPMTU > 0 {
 		fmt.Printf("Final PMTU: %d bytes\n", result.FinalPMTU)
@@ -171,11 +355,29 @@ func outputHopTable(result *HopMTUResult) error {
 	fmt.Printf("Total time: %dms\n\n", result.ElapsedMS)
 
 	// Print table header
-	fmt.Printf("%-4s %-15s %-6s %-10s %s\n", "Hop", "Address", "MTU", "RTT", "Status")
-	fmt.Printf("%-4s %-15s %-6s %-10s %s\n", "---", "---------------", "-----", "----------", "------")
+	enriched := hopsEnriched(result.Hops)
+	if enriched {
+		fmt.Printf("%-4s %-15s %-6s %-10s %-8s %s\n", "Hop", "Address", "MTU", "RTT", "Status", "Name")
+		fmt.Printf("%-4s %-15s %-6s %-10s %-8s %s\n", "---", "---------------", "-----", "----------", "------", "----")
+	} else {
+		fmt.Printf("%-4s %-15s %-6s %-10s %s\n", "Hop", "Address", "MTU", "RTT", "Status")
+		fmt.Printf("%-4s %-15s %-6s %-10s %s\n", "---", "---------------", "-----", "----------", "------")
+	}
+
+	var groups []hopGroup
+	if enriched {
+		groups = groupHopsByASN(result.Hops)
+	}
 
 	// Print each hop
-	for _, hop := range result.Hops {
+	for i, hop := range result.Hops {
+		// AS group header
+		for _, group := range groups {
+			if group.Start == i {
+				fmt.Printf("[%s]\n", group.label())
+			}
+		}
+
 		// Hop number
 		fmt.Printf("%-4d ", hop.Hop)
 
@@ -209,7 +411,19 @@ func outputHopTable(result *HopMTUResult) error {
 		} else if hop.Addr != nil {
 			status = "ok"
 		}
-		fmt.Printf("%s\n", status)
+		if enriched {
+			fmt.Printf("%-8s %s\n", status, hop.Name)
+		} else {
+			fmt.Printf("%s\n", status)
+		}
+	}
+
+	// Summarize where the path crosses from one network into the next
+	if len(groups) > 1 {
+		fmt.Printf("\nHandoffs:\n")
+		for i := 1; i < len(groups); i++ {
+			fmt.Printf("  %s → %s at hop %d\n", groups[i-1].label(), groups[i].label(), groups[i].FirstHop)
+		}
 	}
 
 	return nil
@@ -3,11 +3,12 @@ package mtu
 import (
 	"context"
 	"fmt"
-	"io"
 	"net"
-	"os"
+	"net/netip"
 	"time"
 
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/policy"
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
 	"golang.org/x/net/ipv6"
@@ -20,6 +21,31 @@ type ProbeResult struct {
 	RTT     time.Duration
 	Error   error
 	ICMPErr *ICMPError
+	Outcome ProbeOutcome // Why a probe failed, for probers that can tell; empty otherwise
+}
+
+// ProbeOutcome classifies a failed probe
+type ProbeOutcome string
+
+const (
+	// OutcomeNoResponse means nothing came back before the timeout: the
+	// packet was dropped on the way, or the peer did not answer
+	OutcomeNoResponse ProbeOutcome = "no-response"
+	// OutcomeFragNeeded means a router or the local stack reported the
+	// packet too big for the path
+	OutcomeFragNeeded ProbeOutcome = "icmp-frag-needed"
+	// OutcomePortUnreachable means the packet arrived but nothing listens on
+	// the target port
+	OutcomePortUnreachable ProbeOutcome = "port-unreachable"
+)
+
+// Describe names the outcome of a failed probe, with the next-hop MTU when a
+// Fragmentation Needed error carried one, such as "icmp-frag-needed(mtu=1400)"
+func (r *ProbeResult) Describe() string {
+	if r.Outcome == OutcomeFragNeeded && r.ICMPErr != nil && r.ICMPErr.MTU > 0 {
+		return fmt.Sprintf("%s(mtu=%d)", r.Outcome, r.ICMPErr.MTU)
+	}
+	return string(r.Outcome)
 }
 
 // ICMPError contains details about ICMP error messages
@@ -38,6 +64,11 @@ type HopInfo struct {
 	RTT     time.Duration `json:"rtt"`
 	Timeout bool          `json:"timeout,omitempty"`
 	Error   string        `json:"error,omitempty"`
+
+	// Set by --enrich
+	Name     string `json:"name,omitempty"`      // Reverse DNS name
+	ASN      uint32 `json:"asn,omitempty"`       // Origin AS of the hop address
+	ASHolder string `json:"as_holder,omitempty"` // Holder of ASN
 }
 
 // HopMTUResult represents the result of hop-by-hop MTU discovery
@@ -48,6 +79,10 @@ type HopMTUResult struct {
 	FinalPMTU    int        `json:"final_pmtu"`
 	Hops         []*HopInfo `json:"hops"`
 	ElapsedMS    int        `json:"elapsed_ms"`
+	Warnings     []string   `json:"warnings"`
+
+	SourceAddr      string `json:"source_addr,omitempty"`      // Local address the probes left from
+	EgressInterface string `json:"egress_interface,omitempty"` // Interface holding SourceAddr
 }
 
 type hopPacketConn interface {
@@ -117,7 +152,44 @@ func (p *ipv6HopPacketConn) ReadFrom(buf []byte) (int, net.Addr, error) {
 	return readIPv6HopPacket(p.conn, buf)
 }
 
-var lookupIPAddrs = net.LookupIP
+// lookupIPAddrs resolves every hostname a probe is sent to; tests replace it
+var lookupIPAddrs = lookupCheckedIP
+
+// lookupCheckedIP returns the addresses policy checked host against when it
+// did, so the probe goes where the check looked, and resolves host otherwise
+func lookupCheckedIP(host string) ([]net.IP, error) {
+	addrs, ok := policy.Resolved(host)
+	if !ok {
+		return net.LookupIP(host)
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.AsSlice()
+	}
+	return ips, nil
+}
+
+// resolveProbeHost returns host when it is an address literal, and otherwise
+// the first address of the requested version it resolves to through
+// lookupIPAddrs
+func resolveProbeHost(host string, ipv6 bool) (string, error) {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return host, nil
+	}
+	addrs, err := lookupIPAddrs(host)
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		if (addr.To4() == nil) == ipv6 {
+			return addr.String(), nil
+		}
+	}
+	return "", fmt.Errorf("no %s address found for %s", ipVersion(ipv6), host)
+}
 
 var listenDiscoverPacket = net.ListenPacket
 
@@ -139,10 +211,18 @@ type MTUDiscoverer struct {
 	conn         net.PacketConn
 	targetAddr   net.Addr
 	security     *SecurityConfig
-	icmpListener *ICMPListener // Optional ICMP listener for fail-fast detection
-	progressOut  io.Writer
-	warningOut   io.Writer
+	icmpListener FragmentationSource // Optional ICMP error source for fail-fast detection
+	sourcePorts  *SourcePortSelector // Source ports for TCP and UDP probes (nil = kernel chooses)
+	commonFirst  bool                // Try the common PMTUs before binary search
+	parallel     int                 // Sizes in flight per search round (0 or 1 = serial)
+	retries      int                 // Resends of an unanswered size
+	reuseConn    bool                // Send TCP probes over one held connection
+	udpICMP      bool                // Classify UDP probe failures with the shared ICMP listener
+	datagram     bool                // Echo goes over an unprivileged datagram ICMP socket
+	progress     ProgressFunc
+	warnings     []string // Degraded conditions seen so far, for structured output
 	hopFactory   func(net.PacketConn, bool) (hopPacketConn, error)
+	hopTimeouts  *hopTimeoutEstimator // Per-hop adaptive timeouts, created on first hop probe
 }
 
 // NewMTUDiscoverer creates a new MTU discovery instance
@@ -155,7 +235,6 @@ func NewMTUDiscoverer(target string, ipv6 bool, protocol string, port int, timeo
 		timeout:    timeout,
 		ttl:        ttl,
 		security:   NewSecurityConfig(10), // Default 10 pps
-		warningOut: os.Stderr,
 		hopFactory: defaultHopPacketConnFactory,
 	}
 
@@ -163,7 +242,7 @@ func NewMTUDiscoverer(target string, ipv6 bool, protocol string, port int, timeo
 	if protocol == "icmp" {
 		// Resolve target address
 		if err := d.resolveTarget(); err != nil {
-			return nil, fmt.Errorf("failed to resolve target: %w", err)
+			return nil, errcode.Wrap(errcode.MTUResolveFailed, fmt.Errorf("failed to resolve target: %w", err))
 		}
 
 		// Setup network connection
@@ -224,9 +303,21 @@ func (d *MTUDiscoverer) setupConnection() error {
 		network = "ip4:icmp"
 	}
 
-	conn, err := listenDiscoverPacket(network, "")
+	var conn net.PacketConn
+	err := errRawICMPUnavailable
+	reason := "not in minimal builds"
+	if rawICMPSupported {
+		conn, err = listenDiscoverPacket(network, "")
+		reason = "needs root or CAP_NET_RAW"
+	}
 	if err != nil {
-		return err
+		datagram, datagramErr := listenICMPDatagram(d.ipv6)
+		if datagramErr != nil {
+			return fmt.Errorf("%w (unprivileged ICMP socket unavailable too: %v)", err, datagramErr)
+		}
+		d.warningf("raw ICMP socket unavailable (%s), using an unprivileged one: Fragmentation Needed errors are not seen, so too-big sizes wait for the timeout", reason)
+		conn = icmpDatagramConn{UDPConn: datagram}
+		d.datagram = true
 	}
 
 	d.conn = conn
@@ -234,7 +325,7 @@ func (d *MTUDiscoverer) setupConnection() error {
 	// Set DF flag for MTU discovery using proper socket options
 	if err := d.setDontFragmentSocket(); err != nil {
 		// Don't fail completely, but warn user
-		d.warningf("Warning: Failed to set DF flag via socket options: %v\n", err)
+		d.warningf("failed to set DF flag via socket options: %v", err)
 	}
 
 	return nil
@@ -248,30 +339,76 @@ func (d *MTUDiscoverer) Close() error {
 	return nil
 }
 
-func (d *MTUDiscoverer) progressf(format string, args ...any) {
-	if d.progressOut == nil {
-		return
+func (d *MTUDiscoverer) emit(event ProgressEvent) {
+	if d.progress != nil {
+		d.progress(event)
 	}
-	_, _ = fmt.Fprintf(d.progressOut, format, args...)
 }
 
+// warningf records something that degraded the measurement and reports it as
+// a progress event
 func (d *MTUDiscoverer) warningf(format string, args ...any) {
-	out := d.warningOut
-	if out == nil {
-		out = os.Stderr
-	}
-	_, _ = fmt.Fprintf(out, format, args...)
+	message := fmt.Sprintf(format, args...)
+	d.warnings = append(d.warnings, message)
+	d.emit(ProgressEvent{Kind: ProgressWarning, Message: message})
 }
 
-// SetICMPListener attaches an ICMP listener for fail-fast fragmentation error detection.
-// When set, the discoverer can receive ICMP "Fragmentation Needed" errors asynchronously
-// instead of relying solely on probe timeouts.
-func (d *MTUDiscoverer) SetICMPListener(listener *ICMPListener) {
+// Warnings returns the warnings recorded so far, never nil so JSON output
+// always carries the array
+func (d *MTUDiscoverer) Warnings() []string {
+	return append([]string{}, d.warnings...)
+}
+
+// SetICMPListener attaches an ICMP listener or shared subscription for fail-fast
+// fragmentation error detection. When set, the discoverer can receive ICMP
+// "Fragmentation Needed" errors asynchronously instead of relying solely on
+// probe timeouts.
+func (d *MTUDiscoverer) SetICMPListener(listener FragmentationSource) {
 	d.icmpListener = listener
 }
 
-func (d *MTUDiscoverer) SetProgressWriter(w io.Writer) {
-	d.progressOut = w
+// SetSourcePorts controls which local port TCP and UDP probes are sent from
+func (d *MTUDiscoverer) SetSourcePorts(ports *SourcePortSelector) {
+	d.sourcePorts = ports
+}
+
+// SetCommonMTUFirst makes binary searches try the common PMTUs first
+func (d *MTUDiscoverer) SetCommonMTUFirst(enabled bool) {
+	d.commonFirst = enabled
+}
+
+// SetParallel makes searches probe n sizes at once per round
+func (d *MTUDiscoverer) SetParallel(n int) {
+	d.parallel = n
+}
+
+// SetRetries makes searches resend a size that goes unanswered up to n times
+// before counting it as too big
+func (d *MTUDiscoverer) SetRetries(n int) {
+	d.retries = n
+}
+
+// SetReuseConnection makes TCP searches send every size over one held
+// connection, reconnecting only when a size is lost
+func (d *MTUDiscoverer) SetReuseConnection(enabled bool) {
+	d.reuseConn = enabled
+}
+
+// SetUDPICMPListener makes UDP discovery subscribe to the shared ICMP
+// listener, so a probe that draws Fragmentation Needed fails as soon as the
+// error arrives and reports the next-hop MTU. Opening the listener needs a
+// raw socket.
+func (d *MTUDiscoverer) SetUDPICMPListener(enabled bool) {
+	d.udpICMP = enabled
+}
+
+// SetProgress sends progress events to fn as the discovery runs. Warnings
+// recorded before, such as while opening the probe socket, are sent first.
+func (d *MTUDiscoverer) SetProgress(fn ProgressFunc) {
+	d.progress = fn
+	for _, message := range d.warnings {
+		d.emit(ProgressEvent{Kind: ProgressWarning, Message: message})
+	}
 }
 
 // DiscoverPMTU performs binary search to find the Path-MTU using the specified protocol
@@ -283,8 +420,10 @@ func (d *MTUDiscoverer) DiscoverPMTU(ctx context.Context, minMTU, maxMTU int) (*
 		return d.discoverTCP(ctx, minMTU, maxMTU)
 	case "udp":
 		return d.discoverUDP(ctx, minMTU, maxMTU)
+	case "tls":
+		return d.discoverTLS(ctx, minMTU, maxMTU)
 	default:
-		return nil, fmt.Errorf("unsupported protocol: %s", d.protocol)
+		return nil, errcode.Errorf(errcode.MTUUnsupportedProtocol, "unsupported protocol: %s", d.protocol)
 	}
 }
 
@@ -300,6 +439,7 @@ func (d *MTUDiscoverer) DiscoverPMTULinear(ctx context.Context, minMTU, maxMTU,
 	// For non-ICMP protocols, create the appropriate prober
 	var tcpProber *TCPProber
 	var udpProber *UDPProber
+	var tlsProber *TLSProber
 	var err error
 
 	switch d.protocol {
@@ -308,27 +448,38 @@ func (d *MTUDiscoverer) DiscoverPMTULinear(ctx context.Context, minMTU, maxMTU,
 		if err != nil {
 			return nil, fmt.Errorf("failed to create TCP prober: %w", err)
 		}
+		tcpProber.SetSourcePorts(d.sourcePorts)
 	case "udp":
 		udpProber, err = NewUDPProber(d.target, d.ipv6, d.port, d.timeout)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create UDP prober: %w", err)
 		}
+		udpProber.SetSourcePorts(d.sourcePorts)
+		defer func() { _ = udpProber.Close() }()
+	case "tls":
+		tlsProber, err = NewTLSProber(d.target, d.ipv6, d.port, d.timeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create TLS prober: %w", err)
+		}
+		tlsProber.SetSourcePorts(d.sourcePorts)
 	case "icmp":
 		// ICMP uses d.conn which is already set up
 		if d.conn == nil {
 			if err := d.resolveTarget(); err != nil {
-				return nil, fmt.Errorf("failed to resolve target: %w", err)
+				return nil, errcode.Wrap(errcode.MTUResolveFailed, fmt.Errorf("failed to resolve target: %w", err))
 			}
 			if err := d.setupConnection(); err != nil {
 				return nil, fmt.Errorf("failed to setup connection: %w", err)
 			}
 		}
 	default:
-		return nil, fmt.Errorf("unsupported protocol: %s", d.protocol)
+		return nil, errcode.Errorf(errcode.MTUUnsupportedProtocol, "unsupported protocol: %s", d.protocol)
 	}
 
 	lastWorking := 0
+	var lastRTT time.Duration
 	probeCount := 0
+	var history probeHistory
 
 	// Linear sweep from min to max
 	for size := minMTU; size <= maxMTU; size += step {
@@ -338,22 +489,23 @@ func (d *MTUDiscoverer) DiscoverPMTULinear(ctx context.Context, minMTU, maxMTU,
 		default:
 		}
 
-		var success bool
+		var result *ProbeResult
 		switch d.protocol {
 		case "icmp":
-			result := d.probe(ctx, size)
-			success = result.Success
+			result = d.probe(ctx, size)
 		case "tcp":
-			result := tcpProber.ProbeTCP(ctx, size)
-			success = result.Success
+			result = tcpProber.ProbeTCP(ctx, size)
 		case "udp":
-			result := udpProber.ProbeUDP(ctx, size)
-			success = result.Success
+			result = udpProber.ProbeUDP(ctx, size)
+		case "tls":
+			result = tlsProber.ProbeTLS(ctx, size)
 		}
 		probeCount++
+		history.add(size, result)
 
-		if success {
+		if result.Success {
 			lastWorking = size
+			lastRTT = result.RTT
 		} else {
 			// First failure - stop and use last working size
 			break
@@ -361,25 +513,48 @@ func (d *MTUDiscoverer) DiscoverPMTULinear(ctx context.Context, minMTU, maxMTU,
 	}
 
 	if lastWorking == 0 {
-		return nil, fmt.Errorf("no working MTU found in range %d-%d", minMTU, maxMTU)
+		return nil, fmt.Errorf("%w in range %d-%d", errNoWorkingMTU, minMTU, maxMTU)
 	}
 
 	elapsed := time.Since(start)
+	search := history.finish(pmtuSearch{})
+
+	// ICMP probes share an unconnected raw socket, so performMTUDiscovery
+	// looks its source up instead
+	var sourceAddr string
+	switch {
+	case tcpProber != nil:
+		sourceAddr = tcpProber.source.String()
+	case udpProber != nil:
+		sourceAddr = udpProber.source.String()
+	case tlsProber != nil:
+		sourceAddr = tlsProber.source.String()
+	}
 
 	return &MTUResult{
-		Target:    d.target,
-		Protocol:  d.protocol,
-		PMTU:      lastWorking,
-		MSS:       tcpMSSForMTU(lastWorking, d.ipv6),
-		Hops:      probeCount,
-		ElapsedMS: int(elapsed.Milliseconds()),
+		Target:     d.target,
+		Protocol:   d.protocol,
+		PMTU:       lastWorking,
+		MSS:        tcpMSSForMTU(lastWorking, d.ipv6),
+		Hops:       probeCount,
+		ElapsedMS:  int(elapsed.Milliseconds()),
+		RTTMS:      durationMS(lastRTT),
+		RTTMinMS:   durationMS(search.RTTMin),
+		RTTAvgMS:   durationMS(search.RTTAvg),
+		RTTMaxMS:   durationMS(search.RTTMax),
+		History:    search.History,
+		SourceAddr: sourceAddr,
 	}, nil
 }
 
-// DiscoverHopByHopMTU performs hop-by-hop MTU discovery using TTL variation
+// DiscoverHopByHopMTU performs hop-by-hop MTU discovery using TTL variation.
+// ICMP and UDP probes search each hop's MTU; TCP probes are SYNs, which only
+// find the hops, and the destination's PMTU comes from a TCP search.
 func (d *MTUDiscoverer) DiscoverHopByHopMTU(ctx context.Context, maxTTL int, maxProbeSize int) (*HopMTUResult, error) {
-	if d.protocol != "icmp" {
-		return nil, fmt.Errorf("hop-by-hop discovery only supported for ICMP protocol")
+	switch d.protocol {
+	case "icmp", "udp", "tcp":
+	default:
+		return nil, errcode.Errorf(errcode.MTUUnsupportedProtocol, "hop-by-hop discovery supports ICMP, UDP, and TCP, not %s", d.protocol)
 	}
 
 	start := time.Now()
@@ -387,12 +562,15 @@ func (d *MTUDiscoverer) DiscoverHopByHopMTU(ctx context.Context, maxTTL int, max
 	// Use standard connection
 	if d.conn == nil {
 		if err := d.resolveTarget(); err != nil {
-			return nil, fmt.Errorf("failed to resolve target: %w", err)
+			return nil, errcode.Wrap(errcode.MTUResolveFailed, fmt.Errorf("failed to resolve target: %w", err))
 		}
 		if err := d.setupConnection(); err != nil {
 			return nil, fmt.Errorf("failed to setup connection: %w", err)
 		}
 	}
+	if d.datagram {
+		return nil, errcode.Errorf(errcode.MTUPermissionDenied, "hop-by-hop discovery needs a raw ICMP socket (root or CAP_NET_RAW) to see Time Exceeded")
+	}
 
 	var hops []*HopInfo
 	finalPMTU := 0
@@ -410,13 +588,15 @@ func (d *MTUDiscoverer) DiscoverHopByHopMTU(ctx context.Context, maxTTL int, max
 
 		// If we get a response, discover the maximum MTU to this hop
 		if hop.Addr != nil && hop.Error == "" {
-			// Discover MTU to this specific hop
-			hopMTU := d.discoverMTUToHop(ctx, ttl, 576, 1600)
-			if hopMTU > 0 {
-				hop.MTU = hopMTU
-				d.progressf("Hop %d: %s (Path MTU to this hop: %d bytes)\n", ttl, hop.Addr, hopMTU)
+			if d.protocol == "tcp" {
+				d.emit(ProgressEvent{Kind: ProgressHop, Hop: ttl, Addr: hop.Addr, Message: "SYN probes do not measure hop MTUs"})
 			} else {
-				d.progressf("Hop %d: %s (MTU discovery failed)\n", ttl, hop.Addr)
+				// Discover MTU to this specific hop
+				hopMTU := d.discoverMTUToHop(ctx, ttl, 576, 1600)
+				if hopMTU > 0 {
+					hop.MTU = hopMTU
+				}
+				d.emit(ProgressEvent{Kind: ProgressHop, Hop: ttl, Addr: hop.Addr, MTU: hopMTU})
 			}
 		}
 
@@ -425,11 +605,10 @@ func (d *MTUDiscoverer) DiscoverHopByHopMTU(ctx context.Context, maxTTL int, max
 		// Check if we've reached the destination
 		if d.isDestinationReached(hop) {
 			// For the final hop (destination), use regular PMTU discovery for more accurate results
-			result, err := d.DiscoverPMTU(ctx, 576, maxProbeSize)
-			if err == nil {
-				finalPMTU = result.PMTU
-				hop.MTU = result.PMTU
-				d.progressf("Reached destination at hop %d with PMTU: %d bytes\n", ttl, result.PMTU)
+			if pmtu := d.destinationPMTU(ctx, ttl, maxProbeSize); pmtu > 0 {
+				finalPMTU = pmtu
+				hop.MTU = pmtu
+				d.emit(ProgressEvent{Kind: ProgressDestination, Hop: ttl, Addr: hop.Addr, MTU: pmtu})
 			}
 			break
 		}
@@ -447,10 +626,9 @@ func (d *MTUDiscoverer) DiscoverHopByHopMTU(ctx context.Context, maxTTL int, max
 					consecutiveTimeouts = 0
 					if d.isDestinationReached(extraHop) {
 						// Reached destination after timeouts
-						result, err := d.DiscoverPMTU(ctx, 576, maxProbeSize)
-						if err == nil {
-							finalPMTU = result.PMTU
-							extraHop.MTU = result.PMTU
+						if pmtu := d.destinationPMTU(ctx, i, maxProbeSize); pmtu > 0 {
+							finalPMTU = pmtu
+							extraHop.MTU = pmtu
 						}
 						ttl = i // Update ttl for the loop exit
 						break
@@ -466,8 +644,9 @@ func (d *MTUDiscoverer) DiscoverHopByHopMTU(ctx context.Context, maxTTL int, max
 
 	elapsed := time.Since(start)
 
-	// Use the actual path MTU as discovered by regular PMTU discovery
-	if finalPMTU == 0 {
+	// Use the actual path MTU as discovered by regular PMTU discovery. UDP
+	// hop probes go to closed ports, which a UDP search cannot measure.
+	if finalPMTU == 0 && d.protocol != "udp" {
 		result, err := d.DiscoverPMTU(ctx, 576, maxProbeSize)
 		if err == nil {
 			finalPMTU = result.PMTU
@@ -488,51 +667,27 @@ func (d *MTUDiscoverer) DiscoverHopByHopMTU(ctx context.Context, maxTTL int, max
 func (d *MTUDiscoverer) discoverICMP(ctx context.Context, minMTU, maxMTU int) (*MTUResult, error) {
 	start := time.Now()
 
-	// Binary search for maximum working MTU
-	low := minMTU
-	high := maxMTU
-	lastWorking := 0
-	hops := 0
-
-	for low <= high {
-		mid := (low + high) / 2
-
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-		}
-
-		result := d.probe(ctx, mid)
-		hops++
-
-		if result.Success {
-			lastWorking = mid
-			low = mid + 1
-		} else {
-			// Check if it's an ICMP "Packet Too Big" or "Fragmentation Needed"
-			if result.ICMPErr != nil && d.isFragmentationError(result.ICMPErr) {
-				high = mid - 1
-			} else {
-				// Timeout or other error - try smaller size
-				high = mid - 1
-			}
-		}
-	}
-
-	if lastWorking == 0 {
-		return nil, fmt.Errorf("no working MTU found in range %d-%d", minMTU, maxMTU)
+	plan := searchPlan{CommonFirst: d.commonFirst, Parallel: d.parallel, Retries: d.retries}
+	search, err := searchPMTU(ctx, minMTU, maxMTU, d.probe, d.burstProbes, plan)
+	if err != nil {
+		return nil, err
 	}
 
 	elapsed := time.Since(start)
 
 	return &MTUResult{
-		Target:    d.target,
-		Protocol:  d.protocol,
-		PMTU:      lastWorking,
-		MSS:       tcpMSSForMTU(lastWorking, d.ipv6),
-		Hops:      hops,
-		ElapsedMS: int(elapsed.Milliseconds()),
+		Target:     d.target,
+		Protocol:   d.protocol,
+		PMTU:       search.PMTU,
+		MSS:        tcpMSSForMTU(search.PMTU, d.ipv6),
+		Hops:       search.Probes,
+		ElapsedMS:  int(elapsed.Milliseconds()),
+		RTTMS:      durationMS(search.RTT),
+		Confidence: search.Confidence,
+		RTTMinMS:   durationMS(search.RTTMin),
+		RTTAvgMS:   durationMS(search.RTTAvg),
+		RTTMaxMS:   durationMS(search.RTTMax),
+		History:    search.History,
 	}, nil
 }
 
@@ -542,16 +697,49 @@ func (d *MTUDiscoverer) discoverTCP(ctx context.Context, minMTU, maxMTU int) (*M
 	if err != nil {
 		return nil, err
 	}
+	prober.SetSourcePorts(d.sourcePorts)
+	prober.SetCommonMTUFirst(d.commonFirst)
+	prober.SetParallel(d.parallel)
+	prober.SetRetries(d.retries)
+	prober.SetReuseConnection(d.reuseConn)
 
 	return prober.DiscoverPMTUTCP(ctx, minMTU, maxMTU)
 }
 
+// discoverTLS performs TLS-based MTU discovery
+func (d *MTUDiscoverer) discoverTLS(ctx context.Context, minMTU, maxMTU int) (*MTUResult, error) {
+	prober, err := NewTLSProber(d.target, d.ipv6, d.port, d.timeout)
+	if err != nil {
+		return nil, err
+	}
+	prober.SetSourcePorts(d.sourcePorts)
+	prober.SetCommonMTUFirst(d.commonFirst)
+	prober.SetParallel(d.parallel)
+	prober.SetRetries(d.retries)
+
+	return prober.DiscoverPMTUTLS(ctx, minMTU, maxMTU)
+}
+
 // discoverUDP performs UDP-based MTU discovery
 func (d *MTUDiscoverer) discoverUDP(ctx context.Context, minMTU, maxMTU int) (*MTUResult, error) {
 	prober, err := NewUDPProber(d.target, d.ipv6, d.port, d.timeout)
 	if err != nil {
 		return nil, err
 	}
+	prober.SetSourcePorts(d.sourcePorts)
+	prober.SetCommonMTUFirst(d.commonFirst)
+	prober.SetParallel(d.parallel)
+	prober.SetRetries(d.retries)
+
+	if d.udpICMP {
+		subscription, err := SubscribeICMPErrors(prober.targetAddr.IP)
+		if err != nil {
+			d.warningf("ICMP listener unavailable, so UDP probes only see errors the kernel reports on the socket: %v", err)
+		} else {
+			prober.SetICMPListener(subscription)
+			defer func() { _ = subscription.Close() }()
+		}
+	}
 
 	return prober.DiscoverPMTUUDP(ctx, minMTU, maxMTU)
 }
@@ -606,8 +794,14 @@ func (d *MTUDiscoverer) probe(ctx context.Context, size int) *ProbeResult {
 	readChan := make(chan readResult, 1)
 	go func() {
 		response := make([]byte, 1500)
-		n, addr, err := d.conn.ReadFrom(response)
-		readChan <- readResult{n: n, addr: addr, err: err, response: response}
+		for {
+			n, addr, err := d.conn.ReadFrom(response)
+			if err == nil && d.isEchoRequest(response[:n]) {
+				continue // A loopback destination delivers our own request too
+			}
+			readChan <- readResult{n: n, addr: addr, err: err, response: response}
+			return
+		}
 	}()
 
 	// Wait for: socket read, async ICMP error (fail-fast), or context cancellation
@@ -663,8 +857,26 @@ func (d *MTUDiscoverer) probe(ctx context.Context, size int) *ProbeResult {
 	return &ProbeResult{Size: size, Success: icmpErr == nil, RTT: rtt, ICMPErr: icmpErr}
 }
 
+// destinationPMTU measures the whole path once the destination answered at ttl
+func (d *MTUDiscoverer) destinationPMTU(ctx context.Context, ttl int, maxProbeSize int) int {
+	if d.protocol == "udp" {
+		// The destination answers hop probes with Port Unreachable, so the
+		// search runs over them rather than over a UDP echo
+		return d.discoverMTUToHop(ctx, ttl, 576, maxProbeSize)
+	}
+	result, err := d.DiscoverPMTU(ctx, 576, maxProbeSize)
+	if err != nil {
+		return 0
+	}
+	return result.PMTU
+}
+
 // probeHop sends a single probe with specified TTL for hop-by-hop discovery
 func (d *MTUDiscoverer) probeHop(ctx context.Context, ttl int, size int) *HopInfo {
+	if d.protocol != "icmp" {
+		return d.probeTransportHop(ctx, ttl, size)
+	}
+
 	start := time.Now()
 
 	// Apply rate limiting
@@ -699,7 +911,12 @@ func (d *MTUDiscoverer) probeHop(ctx context.Context, ttl int, size int) *HopInf
 		return hop
 	}
 
+	if d.hopTimeouts == nil {
+		d.hopTimeouts = newHopTimeoutEstimator(d.timeout)
+	}
+
 	// Send packet
+	sent := time.Now()
 	_, err = d.conn.WriteTo(packet, d.targetAddr)
 	if err != nil {
 		hop.Error = fmt.Sprintf("failed to send packet: %v", err)
@@ -708,7 +925,7 @@ func (d *MTUDiscoverer) probeHop(ctx context.Context, ttl int, size int) *HopInf
 	}
 
 	// Set read deadline
-	deadline := time.Now().Add(d.timeout)
+	deadline := sent.Add(d.hopTimeouts.Timeout(ttl))
 	if err := d.conn.SetReadDeadline(deadline); err != nil {
 		hop.Error = fmt.Sprintf("failed to set read deadline: %v", err)
 		hop.RTT = time.Since(start)
@@ -723,6 +940,7 @@ func (d *MTUDiscoverer) probeHop(ctx context.Context, ttl int, size int) *HopInf
 	n, addr, err = pconn.ReadFrom(response)
 
 	hop.RTT = time.Since(start)
+	roundTrip := time.Since(sent)
 
 	if err != nil {
 		// Check if it's a timeout
@@ -750,6 +968,7 @@ func (d *MTUDiscoverer) probeHop(ctx context.Context, ttl int, size int) *HopInf
 		if (d.ipv6 && icmpErr.Type == int(ipv6.ICMPTypeTimeExceeded)) ||
 			(!d.ipv6 && icmpErr.Type == int(ipv4.ICMPTypeTimeExceeded)) {
 			// This is normal - router responded with TTL exceeded
+			d.hopTimeouts.Observe(ttl, roundTrip)
 			return hop
 		}
 
@@ -765,6 +984,7 @@ func (d *MTUDiscoverer) probeHop(ctx context.Context, ttl int, size int) *HopInf
 	}
 
 	// If we get here, we got an echo reply, meaning we reached the destination
+	d.hopTimeouts.Observe(ttl, roundTrip)
 	return hop
 }
 
@@ -811,20 +1031,19 @@ func (d *MTUDiscoverer) isDestinationReached(hop *HopInfo) bool {
 		return false
 	}
 
-	// Get the target IP to compare
-	var targetIP net.IP
-	if ipAddr, ok := d.targetAddr.(*net.IPAddr); ok {
-		targetIP = ipAddr.IP
-	} else {
-		return false
-	}
-
 	// Compare IPs
-	return hop.Addr.Equal(targetIP)
+	targetIP := d.targetIP()
+	return targetIP != nil && hop.Addr.Equal(targetIP)
 }
 
 // createICMPPacket creates an ICMP Echo Request packet (DF flag set via socket options)
 func (d *MTUDiscoverer) createICMPPacket(packetSize int) ([]byte, error) {
+	return d.createEchoPacket(packetSize, d.security.Randomizer.GenerateRandomID(), d.security.Randomizer.GenerateRandomSeq())
+}
+
+// createEchoPacket builds an echo request of packetSize bytes on the wire with
+// the given identifier and sequence number
+func (d *MTUDiscoverer) createEchoPacket(packetSize, id, seq int) ([]byte, error) {
 	// Calculate payload size (subtract IP header + ICMP header)
 	// packetSize is the target MTU size; we must subtract full header overhead
 	ipHeaderSize := 20
@@ -839,27 +1058,18 @@ func (d *MTUDiscoverer) createICMPPacket(packetSize int) ([]byte, error) {
 	// Create payload with security randomization
 	payload := d.security.Randomizer.GenerateRandomPayload(dataSize)
 
-	var msg *icmp.Message
+	var msgType icmp.Type = ipv4.ICMPTypeEcho
 	if d.ipv6 {
-		msg = &icmp.Message{
-			Type: ipv6.ICMPTypeEchoRequest,
-			Code: 0,
-			Body: &icmp.Echo{
-				ID:   d.security.Randomizer.GenerateRandomID(),
-				Seq:  d.security.Randomizer.GenerateRandomSeq(),
-				Data: payload,
-			},
-		}
-	} else {
-		msg = &icmp.Message{
-			Type: ipv4.ICMPTypeEcho,
-			Code: 0,
-			Body: &icmp.Echo{
-				ID:   d.security.Randomizer.GenerateRandomID(),
-				Seq:  d.security.Randomizer.GenerateRandomSeq(),
-				Data: payload,
-			},
-		}
+		msgType = ipv6.ICMPTypeEchoRequest
+	}
+	msg := &icmp.Message{
+		Type: msgType,
+		Code: 0,
+		Body: &icmp.Echo{
+			ID:   id,
+			Seq:  seq,
+			Data: payload,
+		},
 	}
 
 	return msg.Marshal(nil)
@@ -943,6 +1153,15 @@ func (d *MTUDiscoverer) parseICMPResponse(data []byte, addr net.Addr) *ICMPError
 	}
 }
 
+// isEchoRequest reports whether data is an echo request, which the raw socket
+// sees when the probe goes out over loopback
+func (d *MTUDiscoverer) isEchoRequest(data []byte) bool {
+	if d.ipv6 {
+		return len(data) > 0 && data[0] == byte(ipv6.ICMPTypeEchoRequest)
+	}
+	return len(data) > 0 && data[0] == byte(ipv4.ICMPTypeEcho)
+}
+
 // parseICMPResponseWithMTU parses ICMP response to get MTU information
 func (d *MTUDiscoverer) parseICMPResponseWithMTU(data []byte, addr net.Addr) *ICMPError {
 	var proto int
@@ -1074,6 +1293,8 @@ func (d *MTUDiscoverer) setIPv4DontFragment() error {
 	switch conn := d.conn.(type) {
 	case *net.IPConn:
 		return setIPv4DontFragment(conn)
+	case icmpDatagramConn:
+		return setIPv4DontFragment(conn.UDPConn)
 	default:
 		return fmt.Errorf("unsupported connection type: %T", conn)
 	}
@@ -1084,6 +1305,8 @@ func (d *MTUDiscoverer) setIPv6DontFragment() error {
 	switch conn := d.conn.(type) {
 	case *net.IPConn:
 		return setIPv6DontFragment(conn)
+	case icmpDatagramConn:
+		return setIPv6DontFragment(conn.UDPConn)
 	default:
 		return fmt.Errorf("unsupported connection type: %T", conn)
 	}
@@ -3,15 +3,22 @@ package mtu
 import (
 	"context"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"slices"
 	"time"
 
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/family"
 	"github.com/spf13/cobra"
 )
 
 type discoveryOptions struct {
 	Destination      string
-	IPv6             bool
+	Family           addressFamily // The IP version --4, --6, or --prefer asks for
+	IPv6             bool          // The IP version chosen for Destination; see selectAddressFamily
+	DefaultMinMTU    bool          // --min not given, so MinMTU follows the chosen IP version
 	Protocol         string
 	MinMTU           int
 	MaxMTU           int
@@ -20,23 +27,126 @@ type discoveryOptions struct {
 	TTL              int
 	Quiet            bool
 	PacketsPerSecond int
+	Limiter          *RateLimiter // Shared by concurrent discoveries; nil gives each its own at PacketsPerSecond
 	HopsMode         bool
 	MaxHops          int
 	Port             int
 	PLPMTUD          bool
 	PLPPort          int
+	DryRun           bool
+	TrainCount       int           // Echo probes in the post-discovery packet train (0 = none)
+	TrainInterval    time.Duration // Gap between train probes
+	TrainSize        int           // Train probe size (0 = discovered PMTU)
+	SourcePortMode   string        // random, fixed, or sequential; see source_port.go
+	SourcePort       int           // Fixed port, or where sequential mode starts (0 = default)
+	CommonFirst      bool          // Try the common PMTUs before binary search (off with --exhaustive)
+	Parallel         int           // Sizes in flight per search round (1 = serial binary search)
+	Retries          int           // Resends of a size that goes unanswered (0 = none)
+	ReuseConn        bool          // Send TCP probes over one held connection (discover --reuse-conn)
+	TraceStates      bool          // Print PLPMTUD state transitions to stderr (discover --verbose)
+	ProgressOut      io.Writer     // Where hop and PLPMTUD progress is printed (nil = only warnings, to stderr)
 }
 
-func readDiscoveryOptions(cmd *cobra.Command, destination string) (discoveryOptions, error) {
+// addressFamily is the IP version requested for a destination
+type addressFamily struct {
+	IPv6   bool // IPv6 rather than IPv4
+	Forced bool // --4 or --6: fail rather than fall back to the other version
+}
+
+// String names the flag that requested the family
+func (f addressFamily) String() string {
+	version := "4"
+	if f.IPv6 {
+		version = "6"
+	}
+	if f.Forced {
+		return "--" + version
+	}
+	return "--prefer " + version
+}
+
+// readAddressFamily reads --4, --6, and --prefer. Without any of them the
+// global prefer-family setting decides, which by default is IPv4 unless the
+// host has no IPv4 connectivity.
+func readAddressFamily(cmd *cobra.Command) (addressFamily, error) {
 	forceIPv4, _ := cmd.Flags().GetBool("4")
 	forceIPv6, _ := cmd.Flags().GetBool("6")
-	if forceIPv4 && forceIPv6 {
-		return discoveryOptions{}, fmt.Errorf("--4 and --6 are mutually exclusive")
+	prefer, _ := cmd.Flags().GetString("prefer")
+	switch {
+	case forceIPv4 && forceIPv6:
+		return addressFamily{}, errcode.Errorf(errcode.CLIUsage, "--4 and --6 are mutually exclusive")
+	case (forceIPv4 || forceIPv6) && cmd.Flags().Changed("prefer"):
+		return addressFamily{}, errcode.Errorf(errcode.CLIUsage, "--prefer cannot be combined with --4 or --6")
+	case forceIPv4 || forceIPv6:
+		return addressFamily{IPv6: forceIPv6, Forced: true}, nil
+	}
+	switch prefer {
+	case "":
+		return addressFamily{IPv6: family.PreferIPv6()}, nil
+	case "4":
+		return addressFamily{}, nil
+	case "6":
+		return addressFamily{IPv6: true}, nil
+	}
+	return addressFamily{}, errcode.Errorf(errcode.CLIUsage, "invalid --prefer %q: expected 4 or 6", prefer)
+}
+
+// selectAddressFamily chooses the IP version to probe opts.Destination over.
+// An address literal decides it outright; with resolve, a hostname gets the
+// requested version if it has an address in it and otherwise, unless the
+// version was forced, the other one. A hostname that does not resolve keeps
+// the requested version and fails when the prober resolves it.
+func selectAddressFamily(opts discoveryOptions, resolve bool) (discoveryOptions, error) {
+	ipv6 := opts.Family.IPv6
+	if ip := net.ParseIP(opts.Destination); ip != nil {
+		literal := ip.To4() == nil
+		if opts.Family.Forced && literal != ipv6 {
+			return opts, errcode.Errorf(errcode.MTUResolveFailed, "%s is an %s address, but %s was given", opts.Destination, ipVersion(literal), opts.Family)
+		}
+		ipv6 = literal
+	} else if resolve && opts.Destination != "" {
+		addrs, err := lookupIPAddrs(opts.Destination)
+		if err == nil && len(addrs) > 0 && !slices.ContainsFunc(addrs, func(ip net.IP) bool { return (ip.To4() == nil) == ipv6 }) {
+			if opts.Family.Forced {
+				return opts, errcode.Errorf(errcode.MTUResolveFailed, "%s has no %s address (%s was given)", opts.Destination, ipVersion(ipv6), opts.Family)
+			}
+			ipv6 = !ipv6
+		}
+	}
+
+	opts.IPv6 = ipv6
+	if opts.DefaultMinMTU {
+		opts.MinMTU = defaultMinMTU(ipv6)
+	}
+	return opts, nil
+}
+
+func ipVersion(ipv6 bool) string {
+	if ipv6 {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+func readDiscoveryOptions(cmd *cobra.Command, destination string) (discoveryOptions, error) {
+	family, err := readAddressFamily(cmd)
+	if err != nil {
+		return discoveryOptions{}, err
 	}
 
 	protocol, _ := cmd.Flags().GetString("proto")
-	if !isSupportedProbeProtocol(protocol) {
-		return discoveryOptions{}, fmt.Errorf("unsupported protocol: %s", protocol)
+	if !isSupportedProbeProtocol(protocol) && protocol != protocolAll {
+		return discoveryOptions{}, errcode.Errorf(errcode.MTUUnsupportedProtocol, "unsupported protocol: %s", protocol)
+	}
+	if protocol == "icmp" && !rawICMPSupported {
+		switch {
+		case !cmd.Flags().Changed("proto"):
+			// Minimal builds fall back to the unprivileged TCP prober by
+			// default; an explicit --proto icmp uses a datagram socket
+			protocol = "tcp"
+		case !datagramICMPSupported:
+			return discoveryOptions{}, errRawICMPUnavailable
+		}
 	}
 
 	timeout, _ := cmd.Flags().GetDuration("timeout")
@@ -44,11 +154,7 @@ func readDiscoveryOptions(cmd *cobra.Command, destination string) (discoveryOpti
 		timeout = 2 * time.Second
 	}
 
-	ipv6 := forceIPv6
 	minMTU, _ := cmd.Flags().GetInt("min")
-	if minMTU == 0 {
-		minMTU = defaultMinMTU(ipv6)
-	}
 
 	maxMTU, _ := cmd.Flags().GetInt("max")
 	step, _ := cmd.Flags().GetInt("step")
@@ -60,10 +166,42 @@ func readDiscoveryOptions(cmd *cobra.Command, destination string) (discoveryOpti
 	port, _ := cmd.Flags().GetInt("port")
 	plpmtud, _ := cmd.Flags().GetBool("plpmtud")
 	plpPort, _ := cmd.Flags().GetInt("plp-port")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	trainCount, _ := cmd.Flags().GetInt("train")
+	trainInterval, _ := cmd.Flags().GetDuration("train-interval")
+	trainSize, _ := cmd.Flags().GetInt("train-size")
+	srcPort, _ := cmd.Flags().GetInt("src-port")
+	srcPortMode, _ := cmd.Flags().GetString("src-port-mode")
+	exhaustive, _ := cmd.Flags().GetBool("exhaustive")
+	parallel, err := cmd.Flags().GetInt("parallel")
+	if err != nil {
+		// Only mtu discover has --parallel
+		parallel = 1
+	}
+	// Only mtu discover has --retries; elsewhere it reads as 0
+	retries, _ := cmd.Flags().GetInt("retries")
+	reuseConn, _ := cmd.Flags().GetBool("reuse-conn")
+	switch {
+	case srcPort != 0 && !cmd.Flags().Changed("src-port-mode"):
+		// --src-port on its own pins every probe to that port
+		srcPortMode = SourcePortFixed
+	case srcPortMode == "":
+		srcPortMode = SourcePortRandom
+	}
+	if trainCount > 0 && trainInterval == 0 {
+		trainInterval = defaultTrainInterval
+	}
+	if rate, _ := cmd.Flags().GetFloat64("rate"); rate > 0 {
+		if cmd.Flags().Changed("pps") {
+			return discoveryOptions{}, errcode.Errorf(errcode.CLIUsage, "--rate and --pps cannot be used together")
+		}
+		pps = ratePPS(rate, maxMTU)
+	}
 
 	opts := discoveryOptions{
 		Destination:      destination,
-		IPv6:             ipv6,
+		Family:           family,
+		DefaultMinMTU:    minMTU == 0,
 		Protocol:         protocol,
 		MinMTU:           minMTU,
 		MaxMTU:           maxMTU,
@@ -77,33 +215,101 @@ func readDiscoveryOptions(cmd *cobra.Command, destination string) (discoveryOpti
 		Port:             port,
 		PLPMTUD:          plpmtud,
 		PLPPort:          plpPort,
+		DryRun:           dryRun,
+		TrainCount:       trainCount,
+		TrainInterval:    trainInterval,
+		TrainSize:        trainSize,
+		SourcePortMode:   srcPortMode,
+		SourcePort:       srcPort,
+		CommonFirst:      !exhaustive,
+		Parallel:         parallel,
+		Retries:          retries,
+		ReuseConn:        reuseConn,
+	}
+	// Only a literal settles the version here; hostnames are resolved just
+	// before probing
+	if opts, err = selectAddressFamily(opts, false); err != nil {
+		return discoveryOptions{}, err
 	}
 
 	if opts.MinMTU > opts.MaxMTU {
-		return discoveryOptions{}, fmt.Errorf("minimum MTU %d exceeds maximum %d", opts.MinMTU, opts.MaxMTU)
+		return discoveryOptions{}, errcode.Errorf(errcode.CLIUsage, "minimum MTU %d exceeds maximum %d", opts.MinMTU, opts.MaxMTU)
 	}
 	if opts.Step < 0 {
-		return discoveryOptions{}, fmt.Errorf("--step must be non-negative")
+		return discoveryOptions{}, errcode.Errorf(errcode.CLIUsage, "--step must be non-negative")
+	}
+	if opts.Parallel < 1 || opts.Parallel > maxParallelProbes {
+		return discoveryOptions{}, errcode.Errorf(errcode.CLIUsage, "--parallel must be between 1 and %d", maxParallelProbes)
+	}
+	if opts.Parallel > 1 && opts.Step > 0 {
+		return discoveryOptions{}, errcode.Errorf(errcode.CLIUsage, "--parallel speeds up binary search and cannot be combined with --step")
+	}
+	if opts.Retries < 0 || opts.Retries > maxProbeRetries {
+		return discoveryOptions{}, errcode.Errorf(errcode.CLIUsage, "--retries must be between 0 and %d", maxProbeRetries)
+	}
+	if opts.Retries > 0 && (opts.Step > 0 || opts.HopsMode) {
+		return discoveryOptions{}, errcode.Errorf(errcode.CLIUsage, "--retries applies to the PMTU search and cannot be combined with --step or --hops")
+	}
+	if opts.ReuseConn {
+		if opts.Protocol != "tcp" && opts.Protocol != protocolAll {
+			return discoveryOptions{}, errcode.Errorf(errcode.MTUUnsupportedProtocol, "--reuse-conn only applies to TCP probes")
+		}
+		if opts.Parallel > 1 || opts.Step > 0 {
+			return discoveryOptions{}, errcode.Errorf(errcode.CLIUsage, "--reuse-conn probes one size at a time over a binary search and cannot be combined with --parallel or --step")
+		}
 	}
 	if opts.TTL <= 0 {
-		return discoveryOptions{}, fmt.Errorf("--ttl must be positive")
+		return discoveryOptions{}, errcode.Errorf(errcode.CLIUsage, "--ttl must be positive")
 	}
 	if opts.PacketsPerSecond < 0 {
-		return discoveryOptions{}, fmt.Errorf("--pps must be non-negative")
+		return discoveryOptions{}, errcode.Errorf(errcode.CLIUsage, "--pps must be non-negative")
+	}
+	if opts.HopsMode && !rawICMPSupported {
+		return discoveryOptions{}, errRawICMPUnavailable
 	}
 	if opts.HopsMode && opts.MaxHops <= 0 {
-		return discoveryOptions{}, fmt.Errorf("--max-hops must be positive")
+		return discoveryOptions{}, errcode.Errorf(errcode.CLIUsage, "--max-hops must be positive")
 	}
 	if opts.Port < 0 {
-		return discoveryOptions{}, fmt.Errorf("--port must be non-negative")
+		return discoveryOptions{}, errcode.Errorf(errcode.CLIUsage, "--port must be non-negative")
 	}
 	if opts.PLPPort < 0 {
-		return discoveryOptions{}, fmt.Errorf("--plp-port must be non-negative")
+		return discoveryOptions{}, errcode.Errorf(errcode.CLIUsage, "--plp-port must be non-negative")
+	}
+	if opts.TrainCount < 0 || opts.TrainCount > maxTrainPackets {
+		return discoveryOptions{}, errcode.Errorf(errcode.CLIUsage, "--train must be between 0 and %d", maxTrainPackets)
+	}
+	if opts.TrainCount > 0 {
+		if opts.Protocol != "icmp" {
+			return discoveryOptions{}, errcode.Errorf(errcode.MTUUnsupportedProtocol, "packet trains only support ICMP protocol")
+		}
+		if opts.HopsMode {
+			return discoveryOptions{}, errcode.Errorf(errcode.CLIUsage, "--train cannot be combined with --hops")
+		}
+		if opts.TrainInterval < 0 {
+			return discoveryOptions{}, errcode.Errorf(errcode.CLIUsage, "--train-interval must be positive")
+		}
+		if opts.TrainSize != 0 && (opts.TrainSize < defaultMinMTU(opts.IPv6) || opts.TrainSize > opts.MaxMTU) {
+			return discoveryOptions{}, errcode.Errorf(errcode.CLIUsage, "--train-size must be 0 or between %d and %d", defaultMinMTU(opts.IPv6), opts.MaxMTU)
+		}
+	}
+
+	if _, err := NewSourcePortSelector(opts.SourcePortMode, opts.SourcePort); err != nil {
+		return discoveryOptions{}, err
+	}
+	if opts.SourcePortMode != SourcePortRandom && opts.Protocol == "icmp" && !opts.PLPMTUD {
+		return discoveryOptions{}, errcode.Errorf(errcode.MTUUnsupportedProtocol, "source port selection only applies to TCP and UDP probes")
 	}
 
 	return opts, nil
 }
 
+// ratePPS converts a --rate in bits per second to probes per second, sized
+// for the largest probe so the bandwidth is never exceeded
+func ratePPS(rate float64, maxMTU int) int {
+	return max(1, int(rate/float64(8*max(maxMTU, 1))))
+}
+
 func defaultMinMTU(ipv6 bool) int {
 	if ipv6 {
 		return 1280
@@ -113,7 +319,7 @@ func defaultMinMTU(ipv6 bool) int {
 
 func isSupportedProbeProtocol(protocol string) bool {
 	switch protocol {
-	case "icmp", "tcp", "udp":
+	case "icmp", "tcp", "udp", "tls":
 		return true
 	default:
 		return false
@@ -133,11 +339,33 @@ func newMTUDiscoverer(opts discoveryOptions) (*MTUDiscoverer, error) {
 		return nil, fmt.Errorf("failed to create discoverer: %w", err)
 	}
 
-	discoverer.security.RateLimiter = NewRateLimiter(opts.PacketsPerSecond)
+	discoverer.security.RateLimiter = opts.Limiter
+	if discoverer.security.RateLimiter == nil {
+		discoverer.security.RateLimiter = NewRateLimiter(opts.PacketsPerSecond)
+	}
+
+	// Validated by readDiscoveryOptions
+	sourcePorts, err := NewSourcePortSelector(opts.SourcePortMode, opts.SourcePort)
+	if err != nil {
+		return nil, err
+	}
+	discoverer.SetSourcePorts(sourcePorts)
+	discoverer.SetCommonMTUFirst(opts.CommonFirst)
+	discoverer.SetParallel(opts.Parallel)
+	discoverer.SetRetries(opts.Retries)
+	discoverer.SetReuseConnection(opts.ReuseConn)
+	discoverer.SetProgress(progressPrinter(opts.ProgressOut, os.Stderr))
 	return discoverer, nil
 }
 
 func performMTUDiscovery(ctx context.Context, opts discoveryOptions) (*MTUResult, error) {
+	opts, err := selectAddressFamily(opts, true)
+	if err != nil {
+		return nil, err
+	}
+	if opts.TraceStates {
+		opts.ProgressOut = os.Stderr
+	}
 	discoverer, err := newMTUDiscoverer(opts)
 	if err != nil {
 		return nil, err
@@ -147,34 +375,70 @@ func performMTUDiscovery(ctx context.Context, opts discoveryOptions) (*MTUResult
 			fmt.Fprintf(os.Stderr, "Warning: failed to close discoverer: %v\n", closeErr)
 		}
 	}()
+	discoverer.warnUnsupportedDontFragment()
 
-	if opts.Protocol == "icmp" {
-		icmpListener, icmpErr := NewICMPListener()
-		if icmpErr == nil {
-			discoverer.SetICMPListener(icmpListener)
-			icmpListener.Start(ctx)
+	if target, ok := discoverer.targetAddr.(*net.IPAddr); ok && opts.Protocol == "icmp" {
+		subscription, icmpErr := SubscribeICMPErrors(target.IP)
+		if icmpErr != nil {
+			discoverer.warningf("ICMP listener unavailable, so Fragmentation Needed errors are only seen on the probe socket: %v", icmpErr)
+		} else {
+			discoverer.SetICMPListener(subscription)
 			defer func() {
-				if closeErr := icmpListener.Close(); closeErr != nil && !opts.Quiet {
+				if closeErr := subscription.Close(); closeErr != nil && !opts.Quiet {
 					fmt.Fprintf(os.Stderr, "Warning: failed to close ICMP listener: %v\n", closeErr)
 				}
 			}()
 		}
 	}
 
+	if opts.Protocol == "udp" && canListenICMP(opts.IPv6) {
+		discoverer.SetUDPICMPListener(true)
+	}
+
+	var result *MTUResult
 	switch {
 	case opts.Step > 0:
-		return discoverer.DiscoverPMTULinear(ctx, opts.MinMTU, opts.MaxMTU, opts.Step)
+		result, err = discoverer.DiscoverPMTULinear(ctx, opts.MinMTU, opts.MaxMTU, opts.Step)
 	case opts.PLPMTUD:
-		return discoverer.WithPLPMTUDFallback(ctx, opts.MinMTU, opts.MaxMTU, opts.PLPPort)
+		result, err = discoverer.WithPLPMTUDFallback(ctx, opts.MinMTU, opts.MaxMTU, opts.PLPPort)
 	default:
-		return discoverer.DiscoverPMTU(ctx, opts.MinMTU, opts.MaxMTU)
+		result, err = discoverer.DiscoverPMTU(ctx, opts.MinMTU, opts.MaxMTU)
+	}
+	if err != nil {
+		return result, err
+	}
+	if c := result.Confidence; c != nil && c.Score < lowConfidenceScore {
+		discoverer.warningf("%.1f%% of probes at sizes that fit were lost, so PMTU %d may be an underestimate (confidence %.1f%%); raise --retries",
+			100*c.LossRate, result.PMTU, 100*c.Score)
+	}
+	result.SourceAddr, result.EgressInterface = discoverer.egress(result.SourceAddr)
+	result.Warnings = discoverer.Warnings()
+	if opts.TrainCount == 0 {
+		return result, nil
+	}
+
+	// The train measures jitter and reordering at the discovered size unless told otherwise
+	trainSize := opts.TrainSize
+	if trainSize == 0 {
+		trainSize = result.PMTU
 	}
+	result.Train, err = discoverer.SendTrain(ctx, opts.TrainCount, trainSize, opts.TrainInterval)
+	if err != nil {
+		return nil, fmt.Errorf("packet train failed: %w", err)
+	}
+	return result, nil
 }
 
 func newDiscoveryContext(opts discoveryOptions) (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), discoveryTimeoutBudget(opts))
 }
 
+// newCycleContext bounds one watch cycle's discovery like newDiscoveryContext,
+// and also ends it when the watch stops
+func newCycleContext(parent context.Context, opts discoveryOptions) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, discoveryTimeoutBudget(opts))
+}
+
 func discoveryTimeoutBudget(opts discoveryOptions) time.Duration {
 	estimated := estimatedDiscoveryDuration(opts) + 5*time.Second
 	if estimated < 60*time.Second {
@@ -185,10 +449,27 @@ func discoveryTimeoutBudget(opts discoveryOptions) time.Duration {
 
 func estimatedDiscoveryDuration(opts discoveryOptions) time.Duration {
 	estimated := time.Duration(estimatedDiscoveryProbes(opts)) * discoveryProbeDurationBudget(opts)
+	if opts.Parallel > 1 && opts.Step == 0 {
+		// Each search round, and each resend of its unanswered sizes, waits
+		// out one timeout for all of its probes, which are paced out at
+		// --pps first
+		span := opts.MaxMTU - opts.MinMTU + 1
+		tries := 1 + opts.Retries
+		probes, rounds := searchProbes(span, opts.Parallel)*tries, searchRounds(span, opts.Parallel)*tries
+		estimated -= time.Duration(probes) * discoveryProbeDurationBudget(opts)
+		estimated += time.Duration(rounds) * opts.Timeout
+		if opts.PacketsPerSecond > 0 {
+			estimated += time.Duration(probes) * time.Second / time.Duration(opts.PacketsPerSecond)
+		}
+	}
+	if opts.Retries > 0 && opts.Step == 0 {
+		// Every size may also wait out the backoff between its resends
+		estimated += time.Duration(estimatedSearchProbes(opts)) * retryBackoff(opts.Retries)
+	}
 	if opts.PLPMTUD {
-		estimated += estimatedPLPMTUDPauseBudget(opts)
+		estimated += estimatedPLPMTUDPauseBudget()
 	}
-	return estimated
+	return estimated + estimatedTrainDuration(opts)
 }
 
 func discoveryProbeDurationBudget(opts discoveryOptions) time.Duration {
@@ -202,13 +483,30 @@ func discoveryProbeDurationBudget(opts discoveryOptions) time.Duration {
 	return budget
 }
 
-func estimatedPLPMTUDPauseBudget(opts discoveryOptions) time.Duration {
-	stepSize := 64
-	sweepSteps := ((opts.MaxMTU - opts.MinMTU) / stepSize) + 1
-	if sweepSteps <= 1 {
+func estimatedPLPMTUDPauseBudget() time.Duration {
+	// SEARCH_COMPLETE waits once per pass before its black-hole check
+	return (1 + plpMaxBlackHoles) * plpConfirmTimer
+}
+
+// estimatedPLPMTUDProbes bounds the probes of a PLPMTUD run: MAX_PROBES for
+// each of BASE, MIN_PLPMTU in ERROR, every search step, and the black-hole
+// check, for the first pass and each restart after a black hole
+func estimatedPLPMTUDProbes(opts discoveryOptions) int {
+	perPass := 3 + positiveIntBitLen(opts.MaxMTU-opts.MinMTU+1)
+	return (1 + plpMaxBlackHoles) * perPass * plpMaxProbes
+}
+
+// commonMTUProbes bounds the fast-path probes a search starting at minMTU
+// sends before binary search: one per common PMTU in range plus two to confirm
+func commonMTUProbes(opts discoveryOptions, minMTU int) int {
+	if !opts.CommonFirst {
+		return 0
+	}
+	candidates := len(commonMTUCandidates(minMTU, opts.MaxMTU))
+	if candidates == 0 {
 		return 0
 	}
-	return time.Duration(sweepSteps-1) * 100 * time.Millisecond
+	return candidates + 2
 }
 
 func positiveIntBitLen(n int) int {
@@ -225,6 +523,50 @@ func positiveIntBitLen(n int) int {
 	return length
 }
 
+// searchProbes bounds the probes a search over span sizes sends with
+// parallel sizes in flight per round
+func searchProbes(span, parallel int) int {
+	if parallel <= 1 {
+		return positiveIntBitLen(span)
+	}
+	probes := 0
+	for span > 0 {
+		if span <= parallel {
+			return probes + span
+		}
+		probes += parallel
+		span /= parallel + 1
+	}
+	return probes
+}
+
+// searchRounds bounds the rounds a search over span sizes takes with
+// parallel sizes in flight per round
+func searchRounds(span, parallel int) int {
+	if parallel <= 1 {
+		return positiveIntBitLen(span)
+	}
+	rounds := 0
+	for span > 0 {
+		rounds++
+		if span <= parallel {
+			break
+		}
+		span /= parallel + 1
+	}
+	return rounds
+}
+
+// estimatedSearchProbes bounds the sizes a PMTU search probes, counting
+// each once however often it is resent
+func estimatedSearchProbes(opts discoveryOptions) int {
+	probes := searchProbes(opts.MaxMTU-opts.MinMTU+1, opts.Parallel)
+	if probes < 1 {
+		probes = 1
+	}
+	return probes + commonMTUProbes(opts, opts.MinMTU)
+}
+
 func estimatedDiscoveryProbes(opts discoveryOptions) int {
 	if opts.Step > 0 {
 		probes := ((opts.MaxMTU - opts.MinMTU) / opts.Step) + 1
@@ -234,21 +576,31 @@ func estimatedDiscoveryProbes(opts discoveryOptions) int {
 		return probes
 	}
 
-	probes := positiveIntBitLen(opts.MaxMTU - opts.MinMTU + 1)
-	if probes < 1 {
-		probes = 1
-	}
+	// Each size may be resent --retries times
+	probes := estimatedSearchProbes(opts) * (1 + opts.Retries)
 
 	if opts.PLPMTUD {
-		stepSize := 64
-		plpSweepProbes := (((opts.MaxMTU - opts.MinMTU) / stepSize) + 1) * 3
-		plpRefinementProbes := positiveIntBitLen(stepSize) * 3
-		plpProbes := plpSweepProbes + plpRefinementProbes
-		if plpProbes < 3 {
-			plpProbes = 3
-		}
-		probes += plpProbes
+		probes += estimatedPLPMTUDProbes(opts)
 	}
 
 	return probes
 }
+
+// canListenICMP reports whether a raw ICMP socket opens, so the shared
+// listener can start without warning an unprivileged user. Opening a socket
+// sends no packets.
+func canListenICMP(ipv6 bool) bool {
+	if !rawICMPSupported {
+		return false
+	}
+	network := "ip4:icmp"
+	if ipv6 {
+		network = "ip6:ipv6-icmp"
+	}
+	conn, err := listenDiscoverPacket(network, "")
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
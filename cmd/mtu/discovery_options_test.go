@@ -2,10 +2,15 @@ package mtu
 
 import (
 	"context"
+	"errors"
+	"net"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/family"
+	"github.com/euan-cowie/cidrator/internal/units"
 	"github.com/spf13/cobra"
 )
 
@@ -14,20 +19,29 @@ func newDiscoveryOptionsCommand() *cobra.Command {
 	flags := cmd.Flags()
 	flags.Bool("4", false, "")
 	flags.Bool("6", false, "")
+	flags.String("prefer", "", "")
 	flags.Bool("json", false, "")
 	flags.String("proto", "icmp", "")
-	flags.Int("min", 0, "")
-	flags.Int("max", 9216, "")
+	units.Size(flags, "min", 0, "")
+	units.Size(flags, "max", 9216, "")
 	flags.Int("step", 0, "")
-	flags.Duration("timeout", 0, "")
+	flags.Bool("exhaustive", false, "")
+	flags.Int("parallel", 1, "")
+	flags.Int("retries", 0, "")
+	flags.Bool("reuse-conn", false, "")
+	units.Duration(flags, "timeout", 0, "")
 	flags.Int("ttl", 64, "")
 	flags.Bool("quiet", false, "")
 	flags.Int("pps", 10, "")
+	units.Rate(flags, "rate", 0, "")
 	flags.Bool("hops", false, "")
 	flags.Int("max-hops", 30, "")
 	flags.Int("port", 0, "")
 	flags.Bool("plpmtud", false, "")
 	flags.Int("plp-port", 443, "")
+	flags.Int("src-port", 0, "")
+	flags.String("src-port-mode", SourcePortRandom, "")
+	flags.Bool("allow-doc-ranges", false, "")
 	return cmd
 }
 
@@ -50,7 +64,11 @@ func TestReadDiscoveryOptions(t *testing.T) {
 		if opts.Destination != "example.com" {
 			t.Fatalf("unexpected destination: %q", opts.Destination)
 		}
-		if opts.Protocol != "icmp" {
+		wantProtocol := "icmp"
+		if !rawICMPSupported {
+			wantProtocol = "tcp"
+		}
+		if opts.Protocol != wantProtocol {
 			t.Fatalf("unexpected protocol: %q", opts.Protocol)
 		}
 		if opts.Timeout != 2*time.Second {
@@ -62,6 +80,22 @@ func TestReadDiscoveryOptions(t *testing.T) {
 		if opts.MaxHops != 30 || opts.PacketsPerSecond != 10 || opts.PLPPort != 443 {
 			t.Fatalf("unexpected default option set: %+v", opts)
 		}
+		if !opts.CommonFirst {
+			t.Fatal("expected the common-MTU fast path by default")
+		}
+	})
+
+	t.Run("exhaustive disables the common-MTU fast path", func(t *testing.T) {
+		cmd := newDiscoveryOptionsCommand()
+		mustSetFlag(t, cmd, "exhaustive", "true")
+
+		opts, err := readDiscoveryOptions(cmd, "example.com")
+		if err != nil {
+			t.Fatalf("readDiscoveryOptions returned error: %v", err)
+		}
+		if opts.CommonFirst {
+			t.Fatal("expected --exhaustive to skip the fast path")
+		}
 	})
 
 	t.Run("uses ipv6 minimum by default", func(t *testing.T) {
@@ -80,16 +114,62 @@ func TestReadDiscoveryOptions(t *testing.T) {
 		}
 	})
 
+	t.Run("accepts sizes and durations with units", func(t *testing.T) {
+		cmd := newDiscoveryOptionsCommand()
+		mustSetFlag(t, cmd, "min", "1.2k")
+		mustSetFlag(t, cmd, "max", "9k")
+		mustSetFlag(t, cmd, "timeout", "1500ms")
+
+		opts, err := readDiscoveryOptions(cmd, "example.com")
+		if err != nil {
+			t.Fatalf("readDiscoveryOptions returned error: %v", err)
+		}
+		if opts.MinMTU != 1200 || opts.MaxMTU != 9000 || opts.Timeout != 1500*time.Millisecond {
+			t.Fatalf("unexpected min %d, max %d, timeout %v", opts.MinMTU, opts.MaxMTU, opts.Timeout)
+		}
+	})
+
+	t.Run("rate sets probes per second from the largest probe", func(t *testing.T) {
+		cmd := newDiscoveryOptionsCommand()
+		mustSetFlag(t, cmd, "max", "1500")
+		mustSetFlag(t, cmd, "rate", "2mbps")
+
+		opts, err := readDiscoveryOptions(cmd, "example.com")
+		if err != nil {
+			t.Fatalf("readDiscoveryOptions returned error: %v", err)
+		}
+		// 2,000,000 bits/s over 12,000-bit probes
+		if opts.PacketsPerSecond != 166 {
+			t.Fatalf("expected 166 pps, got %d", opts.PacketsPerSecond)
+		}
+	})
+
 	tests := []struct {
-		name    string
-		flags   map[string]string
-		wantErr string
+		name         string
+		flags        map[string]string
+		wantErr      string
+		needsRawICMP bool
 	}{
+		{
+			name:    "rate with pps",
+			flags:   map[string]string{"rate": "1mbps", "pps": "20"},
+			wantErr: "--rate and --pps cannot be used together",
+		},
 		{
 			name:    "mutually exclusive address families",
 			flags:   map[string]string{"4": "true", "6": "true"},
 			wantErr: "--4 and --6 are mutually exclusive",
 		},
+		{
+			name:    "prefer with a forced address family",
+			flags:   map[string]string{"6": "true", "prefer": "6"},
+			wantErr: "--prefer cannot be combined with --4 or --6",
+		},
+		{
+			name:    "unknown preferred address family",
+			flags:   map[string]string{"prefer": "ipv6"},
+			wantErr: `invalid --prefer "ipv6"`,
+		},
 		{
 			name:    "unsupported protocol",
 			flags:   map[string]string{"proto": "sctp"},
@@ -105,6 +185,36 @@ func TestReadDiscoveryOptions(t *testing.T) {
 			flags:   map[string]string{"step": "-1"},
 			wantErr: "--step must be non-negative",
 		},
+		{
+			name:    "parallel out of range",
+			flags:   map[string]string{"parallel": "17"},
+			wantErr: "--parallel must be between 1 and 16",
+		},
+		{
+			name:    "parallel with step",
+			flags:   map[string]string{"parallel": "4", "step": "10"},
+			wantErr: "cannot be combined with --step",
+		},
+		{
+			name:    "too many retries",
+			flags:   map[string]string{"retries": "6"},
+			wantErr: "--retries must be between 0 and 5",
+		},
+		{
+			name:    "retries with hops",
+			flags:   map[string]string{"retries": "2", "hops": "true"},
+			wantErr: "cannot be combined with --step or --hops",
+		},
+		{
+			name:    "reuse-conn with udp",
+			flags:   map[string]string{"reuse-conn": "true", "proto": "udp"},
+			wantErr: "--reuse-conn only applies to TCP probes",
+		},
+		{
+			name:    "reuse-conn with parallel",
+			flags:   map[string]string{"reuse-conn": "true", "proto": "tcp", "parallel": "4"},
+			wantErr: "cannot be combined with --parallel or --step",
+		},
 		{
 			name:    "non-positive ttl",
 			flags:   map[string]string{"ttl": "0"},
@@ -116,9 +226,10 @@ func TestReadDiscoveryOptions(t *testing.T) {
 			wantErr: "--pps must be non-negative",
 		},
 		{
-			name:    "invalid max hops",
-			flags:   map[string]string{"hops": "true", "max-hops": "0"},
-			wantErr: "--max-hops must be positive",
+			name:         "invalid max hops",
+			flags:        map[string]string{"hops": "true", "max-hops": "0"},
+			wantErr:      "--max-hops must be positive",
+			needsRawICMP: true,
 		},
 		{
 			name:    "negative port",
@@ -134,6 +245,9 @@ func TestReadDiscoveryOptions(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			if tt.needsRawICMP && !rawICMPSupported {
+				t.Skip("minimal builds reject --hops before validating it")
+			}
 			cmd := newDiscoveryOptionsCommand()
 			for name, value := range tt.flags {
 				mustSetFlag(t, cmd, name, value)
@@ -150,6 +264,85 @@ func TestReadDiscoveryOptions(t *testing.T) {
 	}
 }
 
+func TestSelectAddressFamily(t *testing.T) {
+	originalLookup := lookupIPAddrs
+	t.Cleanup(func() { lookupIPAddrs = originalLookup })
+	lookupIPAddrs = func(host string) ([]net.IP, error) {
+		switch host {
+		case "mixed.example":
+			return []net.IP{net.ParseIP("2001:db8::10"), net.ParseIP("192.0.2.10")}, nil
+		case "ipv6-only.example":
+			return []net.IP{net.ParseIP("2001:db8::20")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	tests := []selectAddressFamilyTest{
+		{"192.0.2.1", nil, false, false},
+		{"2001:db8::1", nil, true, false}, // a literal needs no --6
+		{"2001:db8::1", map[string]string{"4": "true"}, false, true},
+		{"192.0.2.1", map[string]string{"prefer": "6"}, false, false},
+		{"mixed.example", nil, false, false},
+		{"mixed.example", map[string]string{"prefer": "6"}, true, false},
+		{"ipv6-only.example", nil, true, false}, // falls back from the preferred IPv4
+		{"ipv6-only.example", map[string]string{"6": "true"}, true, false},
+		{"ipv6-only.example", map[string]string{"4": "true"}, false, true},
+		{"missing.example", map[string]string{"prefer": "6"}, true, false}, // left to the prober
+	}
+	runSelectAddressFamilyTests(t, tests)
+
+	// As on an IPv6-only host, where prefer-family auto picks IPv6
+	preferFamily(t, family.IPv6)
+	runSelectAddressFamilyTests(t, []selectAddressFamilyTest{
+		{"mixed.example", nil, true, false},
+		{"mixed.example", map[string]string{"prefer": "4"}, false, false},
+		{"192.0.2.1", nil, false, false},
+		{"ipv6-only.example", nil, true, false},
+	})
+}
+
+type selectAddressFamilyTest struct {
+	destination string
+	flags       map[string]string
+	wantIPv6    bool
+	wantErr     bool
+}
+
+func runSelectAddressFamilyTests(t *testing.T, tests []selectAddressFamilyTest) {
+	t.Helper()
+	for _, tt := range tests {
+		cmd := newDiscoveryOptionsCommand()
+		for name, value := range tt.flags {
+			mustSetFlag(t, cmd, name, value)
+		}
+		opts, err := readDiscoveryOptions(cmd, tt.destination)
+		if err == nil {
+			opts, err = selectAddressFamily(opts, true)
+		}
+		if tt.wantErr {
+			if errcode.Of(err) != errcode.MTUResolveFailed {
+				t.Errorf("%s %v: expected MTU004, got %v", tt.destination, tt.flags, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %v: unexpected error %v", tt.destination, tt.flags, err)
+			continue
+		}
+		if opts.IPv6 != tt.wantIPv6 || opts.MinMTU != defaultMinMTU(tt.wantIPv6) {
+			t.Errorf("%s %v: IPv6 = %v with minimum %d, want %v", tt.destination, tt.flags, opts.IPv6, opts.MinMTU, tt.wantIPv6)
+		}
+	}
+
+	// An explicit --min is kept whichever version is chosen
+	cmd := newDiscoveryOptionsCommand()
+	mustSetFlag(t, cmd, "min", "1400")
+	opts, _ := readDiscoveryOptions(cmd, "ipv6-only.example")
+	if opts, _ = selectAddressFamily(opts, true); !opts.IPv6 || opts.MinMTU != 1400 {
+		t.Fatalf("IPv6 = %v with minimum %d, want IPv6 with 1400", opts.IPv6, opts.MinMTU)
+	}
+}
+
 func TestDiscoveryOptionHelpers(t *testing.T) {
 	if defaultMinMTU(false) != 576 {
 		t.Fatalf("unexpected IPv4 minimum MTU: %d", defaultMinMTU(false))
@@ -187,13 +380,8 @@ func TestNewDiscoveryContextUsesBudget(t *testing.T) {
 }
 
 func TestEstimatedPLPMTUDPauseBudget(t *testing.T) {
-	if budget := estimatedPLPMTUDPauseBudget(discoveryOptions{MinMTU: 1400, MaxMTU: 1400}); budget != 0 {
-		t.Fatalf("expected no pause budget for a single PLPMTUD probe, got %v", budget)
-	}
-
-	budget := estimatedPLPMTUDPauseBudget(discoveryOptions{MinMTU: 576, MaxMTU: 704})
-	if budget != 200*time.Millisecond {
-		t.Fatalf("unexpected PLPMTUD pause budget: %v", budget)
+	if budget := estimatedPLPMTUDPauseBudget(); budget != 2*plpConfirmTimer {
+		t.Fatalf("expected one confirmation wait per pass, got %v", budget)
 	}
 }
 
@@ -235,15 +423,35 @@ func TestEstimatedDiscoveryProbes(t *testing.T) {
 			opts: discoveryOptions{MinMTU: 1400, MaxMTU: 1400},
 			want: 1,
 		},
+		{
+			name: "common-mtu fast path adds candidates and confirmations",
+			opts: discoveryOptions{MinMTU: 576, MaxMTU: 1500, CommonFirst: true},
+			want: positiveIntBitLen(1500-576+1) + 7 + 2,
+		},
+		{
+			name: "fast path with no candidates in range",
+			opts: discoveryOptions{MinMTU: 576, MaxMTU: 704, CommonFirst: true},
+			want: 8,
+		},
+		{
+			name: "parallel rounds",
+			opts: discoveryOptions{MinMTU: 576, MaxMTU: 1500, Parallel: 4},
+			want: 4 + 4 + 4 + 4 + 1,
+		},
+		{
+			name: "retries resend every search probe",
+			opts: discoveryOptions{MinMTU: 576, MaxMTU: 1500, CommonFirst: true, Retries: 2},
+			want: (positiveIntBitLen(1500-576+1) + 7 + 2) * 3,
+		},
 		{
 			name: "linear sweep",
 			opts: discoveryOptions{MinMTU: 1300, MaxMTU: 1450, Step: 20},
 			want: 8,
 		},
 		{
-			name: "plpmtud adds sweep and refinement probes",
+			name: "plpmtud adds the state machine's probes",
 			opts: discoveryOptions{MinMTU: 576, MaxMTU: 704, PLPMTUD: true},
-			want: 38,
+			want: 8 + 2*(3+8)*3,
 		},
 	}
 
@@ -275,18 +483,31 @@ func TestNewMTUDiscovererUsesRateLimit(t *testing.T) {
 	if discoverer.security.RateLimiter.packetsPerSecond != 7 {
 		t.Fatalf("unexpected rate limit: %d", discoverer.security.RateLimiter.packetsPerSecond)
 	}
+
+	shared := NewRateLimiter(7)
+	discoverer, err = newMTUDiscoverer(discoveryOptions{Destination: "127.0.0.1", Protocol: "tcp", Timeout: time.Second, TTL: 64, Limiter: shared})
+	if err != nil {
+		t.Fatalf("newMTUDiscoverer returned error: %v", err)
+	}
+	defer func() { _ = discoverer.Close() }()
+	if discoverer.security.RateLimiter != shared {
+		t.Fatal("expected the shared limiter to be used")
+	}
 }
 
 func TestCommandEntryPointsRejectInvalidHopsModes(t *testing.T) {
+	if !rawICMPSupported {
+		t.Skip("minimal builds reject --hops before the commands check it")
+	}
 	discoverCmd := newDiscoveryOptionsCommand()
 	mustSetFlag(t, discoverCmd, "hops", "true")
-	mustSetFlag(t, discoverCmd, "proto", "tcp")
+	mustSetFlag(t, discoverCmd, "proto", "tls")
 
 	err := runDiscover(discoverCmd, []string{"example.com"})
 	if err == nil {
-		t.Fatal("expected discover command to reject hop mode for non-ICMP")
+		t.Fatal("expected discover command to reject hop mode for TLS")
 	}
-	if !strings.Contains(err.Error(), "hop-by-hop discovery only supports ICMP protocol") {
+	if !strings.Contains(err.Error(), "hop-by-hop discovery supports ICMP, UDP, and TCP, not --proto tls") {
 		t.Fatalf("unexpected discover error: %v", err)
 	}
 
@@ -337,6 +558,9 @@ func TestRunSuggestProbeDefaults(t *testing.T) {
 	})
 
 	t.Run("explicit proto is preserved", func(t *testing.T) {
+		if !rawICMPSupported && !datagramICMPSupported {
+			t.Skip("ICMP is compiled out of minimal builds on this platform")
+		}
 		var gotOpts discoveryOptions
 		suggestMTUDiscovery = func(ctx context.Context, opts discoveryOptions) (*MTUResult, error) {
 			gotOpts = opts
@@ -357,6 +581,53 @@ func TestRunSuggestProbeDefaults(t *testing.T) {
 	})
 }
 
+func TestRunSuggestPMTUSource(t *testing.T) {
+	original := suggestMTUDiscovery
+	t.Cleanup(func() { suggestMTUDiscovery = original })
+	probed := false
+	suggestMTUDiscovery = func(ctx context.Context, opts discoveryOptions) (*MTUResult, error) {
+		probed = true
+		return &MTUResult{Target: opts.Destination, Protocol: opts.Protocol, PMTU: 1400}, nil
+	}
+
+	newCmd := func() *cobra.Command {
+		cmd := newDiscoveryOptionsCommand()
+		units.Size(cmd.Flags(), "pmtu", 0, "")
+		mustSetFlag(t, cmd, "allow-doc-ranges", "true")
+		return cmd
+	}
+
+	out, err := captureStdout(t, func() error { return runSuggest(newCmd(), []string{"192.0.2.1"}) })
+	if err != nil || !strings.Contains(out, "Suggestions for 192.0.2.1 (PMTU: 1400, measured over tcp):") {
+		t.Fatalf("measured suggestion = %q, %v", out, err)
+	}
+
+	probed = false
+	cmd := newCmd()
+	mustSetFlag(t, cmd, "pmtu", "1420")
+	mustSetFlag(t, cmd, "json", "true")
+	out, err = captureStdout(t, func() error { return runSuggest(cmd, nil) })
+	if err != nil || probed || !strings.Contains(out, `"pmtu_source": "assumed"`) || !strings.Contains(out, `"tcp_mss_ipv4": 1380`) || strings.Contains(out, `"target"`) {
+		t.Fatalf("--pmtu suggestion = %q, %v (probed %v)", out, err, probed)
+	}
+
+	if err := runSuggest(newCmd(), nil); errcode.Of(err) != errcode.CLIUsage {
+		t.Fatalf("expected CLI002 without a destination or --pmtu, got %v", err)
+	}
+	cmd = newCmd()
+	mustSetFlag(t, cmd, "pmtu", "100")
+	if err := runSuggest(cmd, nil); errcode.Of(err) != errcode.CLIUsage {
+		t.Fatalf("expected CLI002 for a --pmtu below 576, got %v", err)
+	}
+
+	probed = false
+	cmd = newCmd()
+	mustSetFlag(t, cmd, "allow-doc-ranges", "false")
+	if err := runSuggest(cmd, []string{"192.0.2.1"}); errcode.Of(err) != errcode.CLIDocumentationRange || probed {
+		t.Fatalf("expected CLI011 before probing a documentation address, got %v (probed %v)", err, probed)
+	}
+}
+
 func TestPerformMTUDiscoveryRejectsUnsupportedProtocol(t *testing.T) {
 	_, err := performMTUDiscovery(context.Background(), discoveryOptions{
 		Destination: "example.com",
@@ -1,11 +1,14 @@
 package mtu
 
 import (
+	"context"
 	"errors"
 	"net"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
 )
 
 func TestResolveTargetWithInjectedLookup(t *testing.T) {
@@ -76,6 +79,22 @@ func TestResolveTargetWithInjectedLookup(t *testing.T) {
 			t.Fatalf("expected lookup error, got %v", err)
 		}
 	})
+
+	// TCP and UDP probers dial the address the lookup returned, the one
+	// policy checked, rather than resolving the name again
+	t.Run("tcp and udp probers dial the looked-up address", func(t *testing.T) {
+		tcp, err := NewTCPProber("mixed.example", true, 443, time.Second)
+		if err != nil || tcp.targetAddr.String() != "[2001:db8::10]:443" {
+			t.Fatalf("NewTCPProber = %v, %v, want [2001:db8::10]:443", tcp, err)
+		}
+		udp, err := NewUDPProber("mixed.example", false, 0, time.Second)
+		if err != nil || udp.targetAddr.String() != "192.0.2.10:53" {
+			t.Fatalf("NewUDPProber = %v, %v, want 192.0.2.10:53", udp, err)
+		}
+		if _, err := NewTCPProber("lookup-error.example", false, 443, time.Second); errcode.Of(err) != errcode.MTUResolveFailed {
+			t.Fatalf("expected MTU resolve failure, got %v", err)
+		}
+	})
 }
 
 func TestResolveTargetHelpersUseInjectedLookup(t *testing.T) {
@@ -111,9 +130,11 @@ func TestResolveTargetHelpersUseInjectedLookup(t *testing.T) {
 func TestNewMTUDiscovererUsesInjectedPacketListener(t *testing.T) {
 	originalLookup := lookupIPAddrs
 	originalListen := listenDiscoverPacket
+	originalDatagram := listenICMPDatagram
 	t.Cleanup(func() {
 		lookupIPAddrs = originalLookup
 		listenDiscoverPacket = originalListen
+		listenICMPDatagram = originalDatagram
 	})
 
 	lookupIPAddrs = func(host string) ([]net.IP, error) {
@@ -124,13 +145,45 @@ func TestNewMTUDiscovererUsesInjectedPacketListener(t *testing.T) {
 		listenDiscoverPacket = func(network, address string) (net.PacketConn, error) {
 			return nil, errors.New("listen failed")
 		}
+		listenICMPDatagram = func(ipv6 bool) (*net.UDPConn, error) {
+			return nil, errors.New("ping sockets disabled")
+		}
 
+		// Minimal builds never open a raw socket and go straight to the datagram one
+		wantErr := "failed to setup connection: listen failed"
+		if !rawICMPSupported {
+			wantErr = "failed to setup connection: raw ICMP probing is disabled in minimal builds"
+		}
 		discoverer, err := NewMTUDiscoverer("listener.example", false, "icmp", 0, time.Second, 64)
-		if err == nil || !strings.Contains(err.Error(), "failed to setup connection: listen failed") {
+		if err == nil || !strings.Contains(err.Error(), wantErr) || !strings.Contains(err.Error(), "ping sockets disabled") {
 			t.Fatalf("expected listen failure, got discoverer=%+v err=%v", discoverer, err)
 		}
 	})
 
+	t.Run("icmp setup falls back to an unprivileged socket", func(t *testing.T) {
+		listenDiscoverPacket = func(network, address string) (net.PacketConn, error) {
+			return nil, errors.New("operation not permitted")
+		}
+		listenICMPDatagram = func(ipv6 bool) (*net.UDPConn, error) {
+			return net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		}
+
+		discoverer, err := NewMTUDiscoverer("listener.example", false, "icmp", 0, time.Second, 64)
+		if err != nil {
+			t.Fatalf("unexpected fallback error: %v", err)
+		}
+		t.Cleanup(func() { _ = discoverer.Close() })
+		if _, ok := discoverer.conn.(icmpDatagramConn); !ok || !discoverer.datagram {
+			t.Fatalf("expected a datagram ICMP connection, got %T", discoverer.conn)
+		}
+		if warnings := discoverer.Warnings(); len(warnings) != 1 || !strings.Contains(warnings[0], "unprivileged") {
+			t.Fatalf("expected a downgrade warning, got %v", warnings)
+		}
+		if _, err := discoverer.DiscoverHopByHopMTU(context.Background(), 3, 1500); errcode.Of(err) != errcode.MTUPermissionDenied {
+			t.Fatalf("expected hop-by-hop to need a raw socket, got %v", err)
+		}
+	})
+
 	t.Run("tcp discoverer bypasses raw socket setup", func(t *testing.T) {
 		listenDiscoverPacket = func(network, address string) (net.PacketConn, error) {
 			t.Fatal("listenDiscoverPacket should not be called for TCP discoverers")
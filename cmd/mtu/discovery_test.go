@@ -69,7 +69,7 @@ func TestNewMTUDiscoverer(t *testing.T) {
 
 			if err != nil {
 				// Handle ICMP permission errors gracefully for non-root users
-				if tt.protocol == "icmp" && strings.Contains(err.Error(), "operation not permitted") {
+				if tt.protocol == "icmp" && (strings.Contains(err.Error(), "operation not permitted") || strings.Contains(err.Error(), "permission denied")) {
 					t.Skipf("ICMP requires elevated privileges: %v", err)
 					return
 				}
@@ -200,7 +200,7 @@ func TestProtocolSupport(t *testing.T) {
 			discoverer, err := NewMTUDiscoverer("localhost", false, protocol, 0, 2*time.Second, 64)
 			if err != nil {
 				// Handle ICMP permission errors gracefully for non-root users
-				if protocol == "icmp" && strings.Contains(err.Error(), "operation not permitted") {
+				if protocol == "icmp" && (strings.Contains(err.Error(), "operation not permitted") || strings.Contains(err.Error(), "permission denied")) {
 					t.Skipf("ICMP requires elevated privileges: %v", err)
 					return
 				}
@@ -355,6 +355,29 @@ func TestProbeResult(t *testing.T) {
 	}
 }
 
+// TestIsEchoRequest tests that probes skip their own request on loopback
+func TestIsEchoRequest(t *testing.T) {
+	tests := []struct {
+		ipv6 bool
+		data []byte
+		want bool
+	}{
+		{false, []byte{8, 0}, true},   // Echo request
+		{false, []byte{0, 0}, false},  // Echo reply
+		{false, []byte{3, 4}, false},  // Fragmentation Needed
+		{true, []byte{128, 0}, true},  // Echo request
+		{true, []byte{129, 0}, false}, // Echo reply
+		{true, []byte{8, 0}, false},   // Not an ICMPv6 type
+		{false, nil, false},
+	}
+	for _, tt := range tests {
+		d := &MTUDiscoverer{ipv6: tt.ipv6}
+		if got := d.isEchoRequest(tt.data); got != tt.want {
+			t.Errorf("isEchoRequest(%v) with IPv6 %v = %v, want %v", tt.data, tt.ipv6, got, tt.want)
+		}
+	}
+}
+
 // TestMTURange tests MTU range validation
 func TestMTURange(t *testing.T) {
 	tests := []struct {
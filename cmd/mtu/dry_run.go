@@ -0,0 +1,210 @@
+package mtu
+
+import (
+	"fmt"
+	"time"
+)
+
+// dryRunAnnotations marks a command as honoring the global --dry-run flag
+var dryRunAnnotations = map[string]string{"dry-run": "supported"}
+
+// dryRunPlan describes the traffic a probing command would generate. Counts are
+// upper bounds: adaptive searches usually stop early.
+type dryRunPlan struct {
+	Target              string `json:"target"`
+	Protocol            string `json:"protocol"`
+	Port                int    `json:"port,omitempty"`
+	SourcePorts         string `json:"source_ports,omitempty"` // Set when TCP/UDP source ports are pinned or sequential
+	Mode                string `json:"mode"`
+	MinSize             int    `json:"min_size"`
+	MaxSize             int    `json:"max_size"`
+	Sizes               []int  `json:"sizes,omitempty"`            // Exact probe sizes for linear sweeps
+	CommonSizes         []int  `json:"common_sizes,omitempty"`     // Common PMTUs tried before binary search
+	Parallel            int    `json:"parallel,omitempty"`         // Sizes in flight per search round, when more than one
+	Retries             int    `json:"retries,omitempty"`          // Resends of an unanswered size, counted in max_probes
+	ReuseConnection     bool   `json:"reuse_connection,omitempty"` // TCP probes share one connection until a size is lost
+	MaxProbes           int    `json:"max_probes"`
+	MaxPackets          int    `json:"max_packets"`
+	MaxBytes            int    `json:"max_bytes"`
+	PacketsPerSecond    int    `json:"pps"`
+	EstimatedDurationMS int64  `json:"estimated_duration_ms"`
+	IntervalMS          int64  `json:"interval_ms,omitempty"`   // Set for watch; estimates are per cycle
+	TrainPackets        int    `json:"train_packets,omitempty"` // Jitter/reordering probes sent after discovery; included in the totals
+}
+
+func newDryRunPlan(opts discoveryOptions) dryRunPlan {
+	plan := dryRunPlan{
+		Target:           opts.Destination,
+		Protocol:         opts.Protocol,
+		Port:             dryRunPort(opts),
+		SourcePorts:      dryRunSourcePorts(opts),
+		MinSize:          opts.MinMTU,
+		MaxSize:          opts.MaxMTU,
+		PacketsPerSecond: opts.PacketsPerSecond,
+	}
+
+	var duration time.Duration
+	switch {
+	case opts.HopsMode:
+		// Each hop gets an identification probe and a binary search over
+		// 576-1600, then the destination gets a regular search up to --max.
+		// TCP hop probes are SYNs, so hops get only the identification probe.
+		plan.Mode = "hop-by-hop"
+		plan.MinSize = 576
+		perHop := 1 + positiveIntBitLen(1600-576+1)
+		if opts.Protocol == "tcp" {
+			perHop = 1
+		}
+		plan.MaxProbes = opts.MaxHops*perHop + positiveIntBitLen(opts.MaxMTU-576+1) + commonMTUProbes(opts, 576)
+		duration = time.Duration(plan.MaxProbes) * discoveryProbeDurationBudget(opts)
+	case opts.Step > 0:
+		plan.Mode = "linear"
+		for size := opts.MinMTU; size <= opts.MaxMTU; size += opts.Step {
+			plan.Sizes = append(plan.Sizes, size)
+		}
+		plan.MaxProbes = len(plan.Sizes)
+		duration = estimatedDiscoveryDuration(opts)
+	case opts.PLPMTUD:
+		plan.Mode = "binary+plpmtud"
+		plan.MaxProbes = estimatedDiscoveryProbes(opts)
+		duration = estimatedDiscoveryDuration(opts)
+	default:
+		plan.Mode = "binary"
+		plan.MaxProbes = estimatedDiscoveryProbes(opts)
+		duration = estimatedDiscoveryDuration(opts)
+	}
+	if opts.CommonFirst && opts.Step == 0 && !opts.HopsMode {
+		plan.CommonSizes = commonMTUCandidates(opts.MinMTU, opts.MaxMTU)
+	}
+	if opts.Parallel > 1 && opts.Step == 0 && !opts.HopsMode {
+		plan.Parallel = opts.Parallel
+	}
+	plan.Retries = opts.Retries
+	plan.ReuseConnection = opts.ReuseConn
+	plan.EstimatedDurationMS = duration.Milliseconds()
+
+	controlPackets := 0
+	if opts.Protocol == "tcp" || opts.Protocol == "tls" {
+		// Every TCP or TLS probe is a fresh connection: SYN, ACK, data or
+		// ClientHello, FIN. With --reuse-conn only lost sizes reconnect,
+		// but every size may be lost.
+		controlPackets = 3
+	}
+	plan.MaxPackets = plan.MaxProbes * (1 + controlPackets)
+
+	if plan.Sizes != nil {
+		for _, size := range plan.Sizes {
+			plan.MaxBytes += size
+		}
+	} else {
+		plan.MaxBytes = plan.MaxProbes * plan.MaxSize
+	}
+	plan.MaxBytes += plan.MaxProbes * controlPackets * tcpPacketOverhead(opts.IPv6)
+
+	if opts.TrainCount > 0 {
+		trainSize := opts.TrainSize
+		if trainSize == 0 {
+			trainSize = opts.MaxMTU
+		}
+		plan.TrainPackets = opts.TrainCount
+		plan.MaxPackets += opts.TrainCount
+		plan.MaxBytes += opts.TrainCount * trainSize
+	}
+
+	return plan
+}
+
+// dryRunPort reports the destination port a probe would use, or 0 for ICMP
+func dryRunPort(opts discoveryOptions) int {
+	if opts.Port > 0 {
+		return opts.Port
+	}
+	switch opts.Protocol {
+	case "tcp", "tls":
+		return 443
+	case "udp":
+		return 53
+	default:
+		return 0
+	}
+}
+
+// dryRunSourcePorts describes non-default source port selection for TCP and
+// UDP probes, including the UDP leg of a PLPMTUD fallback
+func dryRunSourcePorts(opts discoveryOptions) string {
+	if opts.Protocol == "icmp" && !opts.PLPMTUD {
+		return ""
+	}
+	ports, err := NewSourcePortSelector(opts.SourcePortMode, opts.SourcePort)
+	if err != nil || ports == nil {
+		return ""
+	}
+	return ports.String()
+}
+
+// outputDryRunPlans prints one plan per protocol for --proto all, or one per
+// target for a fleet watch
+func outputDryRunPlans(plans []dryRunPlan, format outputFormat) error {
+	if format.structured() {
+		return format.write(plans)
+	}
+	for i, plan := range plans {
+		if i > 0 {
+			fmt.Println()
+		}
+		if err := outputDryRun(plan, formatTable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func outputDryRun(plan dryRunPlan, format outputFormat) error {
+	if format.structured() {
+		return format.write(plan)
+	}
+
+	fmt.Printf("Dry run: no packets will be sent\n")
+	fmt.Printf("Target: %s\n", plan.Target)
+	if plan.Port > 0 {
+		fmt.Printf("Protocol: %s (port %d)\n", plan.Protocol, plan.Port)
+	} else {
+		fmt.Printf("Protocol: %s\n", plan.Protocol)
+	}
+	if plan.SourcePorts != "" {
+		fmt.Printf("Source ports: %s\n", plan.SourcePorts)
+	}
+	if plan.Parallel > 1 {
+		fmt.Printf("Mode: %s, %d sizes per round\n", plan.Mode, plan.Parallel)
+	} else {
+		fmt.Printf("Mode: %s\n", plan.Mode)
+	}
+	if plan.Sizes != nil {
+		fmt.Printf("Probe sizes: %v bytes\n", plan.Sizes)
+	} else {
+		fmt.Printf("Probe sizes: %d-%d bytes\n", plan.MinSize, plan.MaxSize)
+	}
+	if len(plan.CommonSizes) > 0 {
+		fmt.Printf("Common sizes tried first: %v bytes\n", plan.CommonSizes)
+	}
+	fmt.Printf("Max probes: %d\n", plan.MaxProbes)
+	if plan.Retries > 0 {
+		fmt.Printf("Retries: up to %d per unanswered size\n", plan.Retries)
+	}
+	if plan.ReuseConnection {
+		fmt.Println("Connections: one held across sizes, reopened after a lost size")
+	}
+	if plan.TrainPackets > 0 {
+		fmt.Printf("Packet train: %d probes after discovery\n", plan.TrainPackets)
+	}
+	fmt.Printf("Max packets: %d\n", plan.MaxPackets)
+	fmt.Printf("Max bytes: %d\n", plan.MaxBytes)
+	if plan.PacketsPerSecond > 0 {
+		fmt.Printf("Rate limit: %d pps\n", plan.PacketsPerSecond)
+	}
+	fmt.Printf("Estimated duration: up to %v\n", time.Duration(plan.EstimatedDurationMS)*time.Millisecond)
+	if plan.IntervalMS > 0 {
+		fmt.Printf("Repeats every %v until interrupted; estimates are per cycle\n", time.Duration(plan.IntervalMS)*time.Millisecond)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package mtu
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/audit"
+)
+
+func TestNewDryRunPlan(t *testing.T) {
+	t.Run("linear sweep lists exact sizes", func(t *testing.T) {
+		plan := newDryRunPlan(discoveryOptions{
+			Destination:      "example.com",
+			Protocol:         "udp",
+			MinMTU:           1400,
+			MaxMTU:           1500,
+			Step:             40,
+			Timeout:          time.Second,
+			PacketsPerSecond: 10,
+		})
+
+		if plan.Mode != "linear" || !slices.Equal(plan.Sizes, []int{1400, 1440, 1480}) {
+			t.Fatalf("unexpected linear plan: %+v", plan)
+		}
+		if plan.Port != 53 || plan.MaxProbes != 3 || plan.MaxPackets != 3 || plan.MaxBytes != 4320 {
+			t.Fatalf("unexpected linear estimates: %+v", plan)
+		}
+		if plan.EstimatedDurationMS != 3000 {
+			t.Fatalf("unexpected duration: %dms", plan.EstimatedDurationMS)
+		}
+	})
+
+	t.Run("tcp probes include handshake packets", func(t *testing.T) {
+		plan := newDryRunPlan(discoveryOptions{
+			Destination: "example.com",
+			Protocol:    "tcp",
+			MinMTU:      576,
+			MaxMTU:      1500,
+			Timeout:     2 * time.Second,
+		})
+
+		probes := positiveIntBitLen(1500 - 576 + 1)
+		if plan.Mode != "binary" || plan.Port != 443 || plan.MaxProbes != probes {
+			t.Fatalf("unexpected binary plan: %+v", plan)
+		}
+		if plan.MaxPackets != probes*4 || plan.MaxBytes != probes*1500+probes*3*40 {
+			t.Fatalf("unexpected tcp estimates: %+v", plan)
+		}
+	})
+
+	t.Run("lists common sizes tried first", func(t *testing.T) {
+		plan := newDryRunPlan(discoveryOptions{
+			Destination: "example.com",
+			Protocol:    "icmp",
+			MinMTU:      1280,
+			MaxMTU:      1450,
+			Timeout:     time.Second,
+			CommonFirst: true,
+		})
+
+		if !slices.Equal(plan.CommonSizes, []int{1380, 1400, 1436}) {
+			t.Fatalf("unexpected common sizes: %v", plan.CommonSizes)
+		}
+		if plan.MaxProbes != positiveIntBitLen(1450-1280+1)+3+2 {
+			t.Fatalf("fast-path probes missing from estimate: %+v", plan)
+		}
+	})
+
+	t.Run("parallel rounds wait one timeout each", func(t *testing.T) {
+		plan := newDryRunPlan(discoveryOptions{
+			Destination: "192.0.2.1",
+			Protocol:    "icmp",
+			MinMTU:      576,
+			MaxMTU:      1500,
+			Timeout:     time.Second,
+			Parallel:    4,
+		})
+
+		if plan.Parallel != 4 || plan.MaxProbes != 17 || plan.EstimatedDurationMS != 5000 {
+			t.Fatalf("unexpected parallel plan: %+v", plan)
+		}
+	})
+
+	t.Run("icmp duration is bounded by pacing", func(t *testing.T) {
+		plan := newDryRunPlan(discoveryOptions{
+			Destination:      "192.0.2.1",
+			Protocol:         "icmp",
+			MinMTU:           1500,
+			MaxMTU:           1500,
+			Timeout:          100 * time.Millisecond,
+			PacketsPerSecond: 2,
+		})
+
+		if plan.Port != 0 || plan.MaxProbes != 1 || plan.EstimatedDurationMS != 500 {
+			t.Fatalf("unexpected icmp plan: %+v", plan)
+		}
+	})
+
+	t.Run("hop-by-hop scales with max hops", func(t *testing.T) {
+		plan := newDryRunPlan(discoveryOptions{
+			Destination: "192.0.2.1",
+			Protocol:    "icmp",
+			MinMTU:      576,
+			MaxMTU:      1500,
+			Timeout:     time.Second,
+			HopsMode:    true,
+			MaxHops:     2,
+		})
+
+		want := 2*(1+positiveIntBitLen(1025)) + positiveIntBitLen(1500-576+1)
+		if plan.Mode != "hop-by-hop" || plan.MaxProbes != want {
+			t.Fatalf("unexpected hop plan: got %d probes, want %d (%+v)", plan.MaxProbes, want, plan)
+		}
+	})
+}
+
+func TestRunDiscoverDryRunSendsNothing(t *testing.T) {
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	audit.SetPath(auditPath)
+	t.Cleanup(func() { audit.SetPath("") })
+
+	cmd := newDiscoveryOptionsCommand()
+	cmd.Flags().Bool("dry-run", false, "")
+	mustSetFlag(t, cmd, "dry-run", "true")
+	mustSetFlag(t, cmd, "json", "true")
+	mustSetFlag(t, cmd, "proto", "udp")
+	mustSetFlag(t, cmd, "max", "1500")
+
+	output, err := captureStdout(t, func() error {
+		return runDiscover(cmd, []string{"192.0.2.1"})
+	})
+	if err != nil {
+		t.Fatalf("runDiscover returned error: %v", err)
+	}
+
+	if !strings.Contains(output, `"mode": "binary"`) || !strings.Contains(output, `"target": "192.0.2.1"`) {
+		t.Fatalf("unexpected dry-run output: %s", output)
+	}
+	if _, err := os.Stat(auditPath); !os.IsNotExist(err) {
+		t.Fatalf("dry run should not write an audit entry, stat err = %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package mtu
+
+import (
+	"net"
+	"sync"
+)
+
+// probeSource remembers the local address the probe sockets left from. The
+// probes of one discovery all follow the same route, so the last one is as
+// good as any.
+type probeSource struct {
+	mu   sync.Mutex
+	addr net.IP
+}
+
+// note records the local end of a connected probe socket
+func (s *probeSource) note(local net.Addr) {
+	var ip net.IP
+	switch addr := local.(type) {
+	case *net.TCPAddr:
+		ip = addr.IP
+	case *net.UDPAddr:
+		ip = addr.IP
+	}
+	if ip == nil || ip.IsUnspecified() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addr = ip
+}
+
+// String returns the recorded address, or "" before any probe connected
+func (s *probeSource) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.addr == nil {
+		return ""
+	}
+	return s.addr.String()
+}
+
+// egress returns the recorded address and the interface holding it, both
+// empty before any probe connected
+func (s *probeSource) egress() (string, string) {
+	addr := s.String()
+	if addr == "" {
+		return "", ""
+	}
+	return addr, interfaceWithAddr(net.ParseIP(addr))
+}
+
+// lookupSourceAddr asks the routing table which local address reaches
+// target. Connecting a UDP socket picks the route without sending anything.
+// Replaced in tests.
+var lookupSourceAddr = func(target string, ipv6 bool) (net.IP, error) {
+	network := "udp4"
+	if ipv6 {
+		network = "udp6"
+	}
+	conn, err := net.Dial(network, net.JoinHostPort(target, "9"))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// interfaceWithAddr names the interface that holds addr, or "" if none
+// does. Replaced in tests.
+var interfaceWithAddr = func(addr net.IP) string {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.Equal(addr) {
+				return iface.Name
+			}
+		}
+	}
+	return ""
+}
+
+// egress returns the local address and interface probes to the target leave
+// from. sourceAddr is the local address of a probe socket when the prober
+// connected one; otherwise, as for raw ICMP sockets, the routing table is
+// asked. Both are empty when neither is known.
+func (d *MTUDiscoverer) egress(sourceAddr string) (string, string) {
+	ip := net.ParseIP(sourceAddr)
+	if ip == nil {
+		var err error
+		if ip, err = lookupSourceAddr(d.target, d.ipv6); err != nil {
+			return "", ""
+		}
+	}
+	return ip.String(), interfaceWithAddr(ip)
+}
+
+// formatSource describes where the probes left from for table output
+func formatSource(addr, iface string) string {
+	if iface == "" {
+		return addr
+	}
+	return addr + " (" + iface + ")"
+}
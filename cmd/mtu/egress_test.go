@@ -0,0 +1,88 @@
+package mtu
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestProbeSourceNote(t *testing.T) {
+	var source probeSource
+	if got := source.String(); got != "" {
+		t.Fatalf("String() before any probe = %q, want empty", got)
+	}
+
+	source.note(&net.TCPAddr{IP: net.IPv4zero, Port: 40000})
+	if got := source.String(); got != "" {
+		t.Fatalf("unspecified address recorded as %q", got)
+	}
+	source.note(&net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 40000})
+	if got := source.String(); got != "2001:db8::1" {
+		t.Fatalf("String() = %q, want 2001:db8::1", got)
+	}
+}
+
+func TestMTUDiscovererEgress(t *testing.T) {
+	origLookup, origInterface := lookupSourceAddr, interfaceWithAddr
+	defer func() { lookupSourceAddr, interfaceWithAddr = origLookup, origInterface }()
+	interfaceWithAddr = func(addr net.IP) string {
+		if addr.Equal(net.ParseIP("192.0.2.10")) {
+			return "eth0"
+		}
+		return ""
+	}
+
+	discoverer := &MTUDiscoverer{target: "198.51.100.1"}
+
+	lookupSourceAddr = func(string, bool) (net.IP, error) {
+		t.Fatal("routing table consulted although the probe socket had an address")
+		return nil, nil
+	}
+	if addr, iface := discoverer.egress("192.0.2.10"); addr != "192.0.2.10" || iface != "eth0" {
+		t.Fatalf("egress from probe socket = %q, %q", addr, iface)
+	}
+
+	lookupSourceAddr = func(target string, ipv6 bool) (net.IP, error) {
+		if target != "198.51.100.1" || ipv6 {
+			t.Fatalf("unexpected lookup for %s (ipv6=%v)", target, ipv6)
+		}
+		return net.ParseIP("192.0.2.10"), nil
+	}
+	if addr, iface := discoverer.egress(""); addr != "192.0.2.10" || iface != "eth0" {
+		t.Fatalf("egress from route lookup = %q, %q", addr, iface)
+	}
+
+	lookupSourceAddr = func(string, bool) (net.IP, error) {
+		return nil, errors.New("network is unreachable")
+	}
+	if addr, iface := discoverer.egress(""); addr != "" || iface != "" {
+		t.Fatalf("egress without a route = %q, %q, want empty", addr, iface)
+	}
+}
+
+func TestUDPDiscoveryReportsSourceAddr(t *testing.T) {
+	conn, shutdown := startLocalUDPPeer(t, 1400)
+	defer shutdown()
+
+	prober, err := NewUDPProber("127.0.0.1", false, conn.LocalAddr().(*net.UDPAddr).Port, 150*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewUDPProber returned error: %v", err)
+	}
+	result, err := prober.DiscoverPMTUUDP(context.Background(), 1300, 1450)
+	if err != nil {
+		t.Fatalf("DiscoverPMTUUDP returned error: %v", err)
+	}
+	if result.SourceAddr != "127.0.0.1" {
+		t.Fatalf("SourceAddr = %q, want 127.0.0.1", result.SourceAddr)
+	}
+
+	loopback, ok := loopbackInterface()
+	if !ok {
+		t.Skip("no loopback interface")
+	}
+	if got := interfaceWithAddr(net.ParseIP(result.SourceAddr)); got != loopback.Name {
+		t.Fatalf("interfaceWithAddr(%s) = %q, want %q", result.SourceAddr, got, loopback.Name)
+	}
+}
@@ -0,0 +1,38 @@
+package mtu
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// errNoWorkingMTU is returned when no probe size in the search range got through
+var errNoWorkingMTU = errors.New("no working MTU found")
+
+// errTCPMSSUnsupported is returned on platforms that cannot cap a TCP
+// connection's segment size, which TCP probes rely on
+var errTCPMSSUnsupported = errors.New("setting TCP_MAXSEG is not supported on this platform")
+
+// withDiscoveryErrorCode attaches the most specific error code to a failed
+// discovery. An empty search over ICMP means nothing answered at any size,
+// which in practice is a firewall dropping ICMP.
+func withDiscoveryErrorCode(err error, protocol string) error {
+	if errcode.Of(err) != errcode.Unknown {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, os.ErrPermission):
+		return errcode.Wrap(errcode.MTUPermissionDenied, err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return errcode.Wrap(errcode.MTUTimeout, err)
+	case errors.Is(err, errNoWorkingMTU) && protocol == "icmp":
+		return errcode.Wrap(errcode.MTUICMPFiltered, err)
+	case errors.Is(err, errNoWorkingMTU):
+		return errcode.Wrap(errcode.MTUNoWorkingSize, err)
+	default:
+		return err
+	}
+}
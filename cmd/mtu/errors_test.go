@@ -0,0 +1,45 @@
+package mtu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+func TestWithDiscoveryErrorCode(t *testing.T) {
+	noWorking := fmt.Errorf("%w in range %d-%d", errNoWorkingMTU, 576, 1500)
+
+	tests := []struct {
+		name     string
+		err      error
+		protocol string
+		want     errcode.Code
+	}{
+		{"icmp silence means filtered", noWorking, "icmp", errcode.MTUICMPFiltered},
+		{"tcp silence", noWorking, "tcp", errcode.MTUNoWorkingSize},
+		{"permission", fmt.Errorf("listen: %w", os.ErrPermission), "icmp", errcode.MTUPermissionDenied},
+		{"deadline", fmt.Errorf("probe: %w", context.DeadlineExceeded), "udp", errcode.MTUTimeout},
+		{"existing code kept", errcode.Wrap(errcode.MTUResolveFailed, noWorking), "icmp", errcode.MTUResolveFailed},
+		{"unclassified", errors.New("boom"), "icmp", errcode.Unknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := withDiscoveryErrorCode(tt.err, tt.protocol)
+			if got := errcode.Of(err); got != tt.want {
+				t.Fatalf("code = %s, want %s", got, tt.want)
+			}
+			if err.Error() != tt.err.Error() {
+				t.Fatalf("message changed: %q", err.Error())
+			}
+		})
+	}
+
+	if withDiscoveryErrorCode(nil, "icmp") != nil {
+		t.Fatal("expected nil for nil error")
+	}
+}
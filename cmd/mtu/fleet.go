@@ -0,0 +1,610 @@
+package mtu
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/notify"
+	"github.com/euan-cowie/cidrator/internal/proxy"
+	"github.com/euan-cowie/cidrator/internal/targets"
+	"github.com/spf13/cobra"
+)
+
+// Health classes assigned to each watched target every cycle
+const (
+	healthHealthy     = "healthy"      // Discovery succeeded at the best PMTU seen so far
+	healthDegraded    = "degraded"     // Discovery succeeded below the best PMTU seen so far
+	healthICMPBlocked = "icmp-blocked" // ICMP discovery failed but the host accepts TCP connections
+	healthDown        = "down"         // Nothing got through
+)
+
+// healthClasses is the fixed order classes are reported in
+var healthClasses = []string{healthHealthy, healthDegraded, healthICMPBlocked, healthDown}
+
+// maxFleetTransitions bounds the recently-transitioned list
+const maxFleetTransitions = 20
+
+// defaultFleetConcurrency is how many fleet probes may overlap by default
+const defaultFleetConcurrency = 8
+
+var fleetDiscovery = performMTUDiscovery
+
+// fleetReachable tells icmp-blocked apart from down with a single TCP connect,
+// made through the --proxy dialer when one is configured
+var fleetReachable = func(ctx context.Context, opts discoveryOptions, dialer *proxy.Dialer) (proxy.Timing, error) {
+	port := opts.Port
+	if port == 0 {
+		port = 443
+	}
+	conn, timing, err := dialer.DialContext(ctx, net.JoinHostPort(opts.Destination, strconv.Itoa(port)))
+	if err != nil {
+		return timing, err
+	}
+	_ = conn.Close()
+	return timing, nil
+}
+
+// fleetTargetStatus is the latest classification of one watched target
+type fleetTargetStatus struct {
+	Target    string       `json:"target"`
+	Health    string       `json:"health"`
+	PMTU      int          `json:"pmtu,omitempty"`
+	MSS       int          `json:"mss,omitempty"`
+	BestPMTU  int          `json:"best_pmtu,omitempty"`
+	RTTMS     float64      `json:"rtt_ms,omitempty"`
+	Since     string       `json:"since"` // When the target entered its current class
+	ErrorCode errcode.Code `json:"error_code,omitempty"`
+	Error     string       `json:"error,omitempty"`
+
+	// Set when ICMP failed and the TCP reachability check ran
+	ConnectMS      float64 `json:"connect_ms,omitempty"`
+	ProxyConnectMS float64 `json:"proxy_connect_ms,omitempty"` // Part of ConnectMS spent reaching the proxy
+	ConnectError   string  `json:"connect_error,omitempty"`
+
+	since time.Time
+}
+
+// fleetTransition records a target moving between health classes
+type fleetTransition struct {
+	Timestamp string `json:"timestamp"`
+	Target    string `json:"target"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+}
+
+// Changes reported by fleetEvent
+const (
+	fleetChangeInitial = "initial" // First result for the target
+	fleetChangeHealth  = "health"  // The target moved to another health class
+	fleetChangePMTU    = "pmtu"    // The PMTU changed within the same class
+)
+
+// fleetEvent is one target's change, printed as its own record with --events
+type fleetEvent struct {
+	Timestamp      string       `json:"timestamp"`
+	Target         string       `json:"target"`
+	Change         string       `json:"change"`
+	Health         string       `json:"health"`
+	PreviousHealth string       `json:"previous_health,omitempty"`
+	PMTU           int          `json:"pmtu,omitempty"`
+	PreviousPMTU   int          `json:"previous_pmtu,omitempty"`
+	MSS            int          `json:"mss,omitempty"`
+	ErrorCode      errcode.Code `json:"error_code,omitempty"`
+	Error          string       `json:"error,omitempty"`
+}
+
+// fleetMonitor tracks the health class of every target across cycles
+type fleetMonitor struct {
+	targets     []*fleetTargetStatus
+	transitions []fleetTransition
+	dialer      *proxy.Dialer
+	concurrency int // Probes in flight at once; below 1 means one
+
+	mu     sync.Mutex   // Guards the fields below and target updates during a cycle
+	events []fleetEvent // Changes not yet taken by takeEvents
+}
+
+func newFleetMonitor(destinations []string, dialer *proxy.Dialer) *fleetMonitor {
+	monitor := &fleetMonitor{dialer: dialer}
+	for _, destination := range destinations {
+		monitor.targets = append(monitor.targets, &fleetTargetStatus{Target: destination})
+	}
+	return monitor
+}
+
+// setTargets replaces the watched destinations, keeping the history of those
+// that remain, and reports which joined and which left
+func (m *fleetMonitor) setTargets(destinations []string) (added, removed []string) {
+	existing := make(map[string]*fleetTargetStatus, len(m.targets))
+	for _, status := range m.targets {
+		existing[status.Target] = status
+	}
+
+	next := make([]*fleetTargetStatus, 0, len(destinations))
+	for _, destination := range destinations {
+		status, ok := existing[destination]
+		if !ok {
+			status = &fleetTargetStatus{Target: destination}
+			added = append(added, destination)
+		}
+		delete(existing, destination)
+		next = append(next, status)
+	}
+	for _, status := range m.targets {
+		if _, gone := existing[status.Target]; gone {
+			removed = append(removed, status.Target)
+		}
+	}
+	m.targets = next
+	return added, removed
+}
+
+// fleetTargets is the destination list of a fleet watch: the command-line
+// destinations followed by whatever the --targets-from sources list
+type fleetTargets struct {
+	static  []string
+	sources targets.Sources
+	refresh time.Duration // Ask the sources again this often
+	fetched time.Time
+}
+
+// list returns the current destinations, asking the sources when there are any
+func (f *fleetTargets) list(ctx context.Context, now time.Time) ([]string, error) {
+	f.fetched = now
+	discovered, err := f.sources.Targets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return targets.Hosts(append(slices.Clone(f.static), discovered...)), nil
+}
+
+// due reports whether the sources should be asked again
+func (f *fleetTargets) due(now time.Time) bool {
+	return len(f.sources) > 0 && now.Sub(f.fetched) >= f.refresh
+}
+
+// fleetOptions gives each destination the options parsed from the command line
+func fleetOptions(base discoveryOptions, destinations []string) []discoveryOptions {
+	perTarget := make([]discoveryOptions, len(destinations))
+	for i, destination := range destinations {
+		perTarget[i] = base
+		perTarget[i].Destination = destination
+		// A literal of the wrong version is reported when it is probed, as
+		// the target's failure rather than the fleet's
+		if opts, err := selectAddressFamily(perTarget[i], false); err == nil {
+			perTarget[i] = opts
+		}
+	}
+	return perTarget
+}
+
+func fleetPlans(perTarget []discoveryOptions, interval time.Duration) []dryRunPlan {
+	plans := make([]dryRunPlan, 0, len(perTarget))
+	for _, opts := range perTarget {
+		plan := newDryRunPlan(opts)
+		plan.IntervalMS = interval.Milliseconds()
+		plans = append(plans, plan)
+	}
+	return plans
+}
+
+// classifyTarget assigns a health class from one discovery attempt. reachable is
+// only consulted when ICMP discovery failed.
+func classifyTarget(protocol string, result *MTUResult, err error, bestPMTU int, reachable func() bool) string {
+	switch {
+	case err == nil && result.PMTU < bestPMTU:
+		return healthDegraded
+	case err == nil:
+		return healthHealthy
+	case protocol == "icmp" && reachable():
+		return healthICMPBlocked
+	default:
+		return healthDown
+	}
+}
+
+// update records the outcome for the target at index i and reports any transition.
+// The first classification of a target is not a transition.
+func (m *fleetMonitor) update(i int, health string, result *MTUResult, err error, now time.Time) (fleetTransition, bool) {
+	status := m.targets[i]
+	previous := status.Health
+
+	status.PMTU, status.MSS, status.RTTMS, status.Error, status.ErrorCode = 0, 0, 0, "", ""
+	if err != nil {
+		status.Error = err.Error()
+		status.ErrorCode = errcode.Of(err)
+	} else {
+		status.PMTU = result.PMTU
+		status.MSS = result.MSS
+		status.RTTMS = result.RTTMS
+		status.BestPMTU = max(status.BestPMTU, result.PMTU)
+	}
+
+	if previous == health {
+		return fleetTransition{}, false
+	}
+	status.Health = health
+	status.since = now
+	status.Since = now.Format(time.RFC3339)
+	if previous == "" {
+		return fleetTransition{}, false
+	}
+
+	transition := fleetTransition{Timestamp: now.Format(time.RFC3339), Target: status.Target, From: previous, To: health}
+	m.transitions = append(m.transitions, transition)
+	if len(m.transitions) > maxFleetTransitions {
+		m.transitions = m.transitions[len(m.transitions)-maxFleetTransitions:]
+	}
+	return transition, true
+}
+
+// newFleetEvent compares a target's status before and after a probe and
+// reports what changed, if anything
+func newFleetEvent(previous fleetTargetStatus, status *fleetTargetStatus, now time.Time) (fleetEvent, bool) {
+	event := fleetEvent{
+		Timestamp:      now.Format(time.RFC3339),
+		Target:         status.Target,
+		Health:         status.Health,
+		PreviousHealth: previous.Health,
+		PMTU:           status.PMTU,
+		PreviousPMTU:   previous.PMTU,
+		MSS:            status.MSS,
+		ErrorCode:      status.ErrorCode,
+		Error:          status.Error,
+	}
+	switch {
+	case previous.Health == "":
+		event.Change = fleetChangeInitial
+	case previous.Health != status.Health:
+		event.Change = fleetChangeHealth
+	case previous.PMTU > 0 && status.PMTU > 0 && previous.PMTU != status.PMTU:
+		event.Change = fleetChangePMTU
+	default:
+		return fleetEvent{}, false
+	}
+	return event, true
+}
+
+// takeEvents returns the changes recorded since the last call
+func (m *fleetMonitor) takeEvents() []fleetEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	events := m.events
+	m.events = nil
+	return events
+}
+
+// recordConnect stores the outcome of the TCP reachability check, clearing it
+// on cycles where the check did not run
+func (s *fleetTargetStatus) recordConnect(checked bool, timing proxy.Timing, err error) {
+	s.ConnectMS, s.ProxyConnectMS, s.ConnectError = 0, 0, ""
+	if !checked {
+		return
+	}
+	s.ConnectMS = durationMS(timing.Total)
+	s.ProxyConnectMS = durationMS(timing.ProxyConnect)
+	if err != nil {
+		s.ConnectError = err.Error()
+	}
+}
+
+// runFleetCycle probes every target once, each in its slot of schedule counted
+// from start. A probe that outlasts its slot overlaps the next ones, up to
+// m.concurrency at a time; the targets share one rate limiter, so --pps holds
+// for the whole fleet. It returns the transitions this cycle caused, and false
+// if ctx ended before every target was probed.
+func (m *fleetMonitor) runFleetCycle(ctx context.Context, perTarget []discoveryOptions, schedule probeSchedule, start time.Time) ([]fleetTransition, bool) {
+	var changed []fleetTransition
+	var wg sync.WaitGroup
+	inFlight := make(chan struct{}, max(m.concurrency, 1))
+	complete := true
+	for _, slot := range schedule.slots {
+		if !schedule.wait(ctx, start, slot) {
+			complete = false
+			break
+		}
+		select {
+		case inFlight <- struct{}{}:
+		case <-ctx.Done():
+			complete = false
+		}
+		if !complete {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, opts discoveryOptions) {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+			transition, ok := m.probe(ctx, i, opts)
+			if ok {
+				m.mu.Lock()
+				changed = append(changed, transition)
+				m.mu.Unlock()
+			}
+		}(slot.index, perTarget[slot.index])
+	}
+	wg.Wait()
+	return changed, complete
+}
+
+// probe runs one target's discovery and records the outcome, unless the
+// watch stopped while it ran
+func (m *fleetMonitor) probe(watchCtx context.Context, i int, opts discoveryOptions) (fleetTransition, bool) {
+	ctx, cancel := newCycleContext(watchCtx, opts)
+	defer cancel()
+	result, err := fleetDiscovery(ctx, opts)
+	err = withDiscoveryErrorCode(err, opts.Protocol)
+
+	var timing proxy.Timing
+	var connectErr error
+	checked := false
+	health := classifyTarget(opts.Protocol, result, err, m.targets[i].BestPMTU, func() bool {
+		checked = true
+		timing, connectErr = fleetReachable(ctx, opts, m.dialer)
+		return connectErr == nil
+	})
+	if watchCtx.Err() != nil {
+		return fleetTransition{}, false
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	previous := *m.targets[i]
+	now := time.Now()
+	transition, ok := m.update(i, health, result, err, now)
+	m.targets[i].recordConnect(checked, timing, connectErr)
+	if event, changed := newFleetEvent(previous, m.targets[i], now); changed {
+		m.events = append(m.events, event)
+	}
+	return transition, ok
+}
+
+// status returns the latest status of target
+func (m *fleetMonitor) status(target string) *fleetTargetStatus {
+	for _, status := range m.targets {
+		if status.Target == target {
+			return status
+		}
+	}
+	return &fleetTargetStatus{Target: target}
+}
+
+// counts returns the number of targets in each health class
+func (m *fleetMonitor) counts() map[string]int {
+	counts := make(map[string]int, len(healthClasses))
+	for _, class := range healthClasses {
+		counts[class] = 0
+	}
+	for _, status := range m.targets {
+		counts[status.Health]++
+	}
+	return counts
+}
+
+// runFleetWatch is mtu watch with more than one destination or a --targets-from
+// source. It never exits on a PMTU drop; drops show up as targets moving to
+// degraded.
+func runFleetWatch(cmd *cobra.Command, base discoveryOptions, fleet *fleetTargets, interval time.Duration, limits watchLimits, dialer *proxy.Dialer, report *htmlReport, exp *watchExport, metrics *watchMetrics, notifiers notify.Notifiers, format outputFormat) error {
+	var log io.Writer
+	if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+		log = cmd.ErrOrStderr()
+	}
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	events, _ := cmd.Flags().GetBool("events")
+	base.Limiter = NewRateLimiter(base.PacketsPerSecond)
+
+	if err := recordTargetSources(cmd, fleet); err != nil {
+		return err
+	}
+	destinations, err := fleet.list(context.Background(), time.Now())
+	if err != nil {
+		return err
+	}
+	perTarget := fleetOptions(base, destinations)
+	plans := fleetPlans(perTarget, interval)
+
+	if base.DryRun {
+		return outputDryRunPlans(plans, format)
+	}
+	if err := checkProbeTargets(cmd, plans...); err != nil {
+		return err
+	}
+	if err := recordProbeAudit(cmd, plans...); err != nil {
+		return err
+	}
+	report.AddTargets(destinations...)
+	if err := exp.open(); err != nil {
+		return err
+	}
+	defer func() { _ = exp.Close() }()
+	if err := metrics.open(); err != nil {
+		return err
+	}
+	defer func() { _ = metrics.Close() }()
+
+	if !format.structured() {
+		fmt.Printf("Watching MTU to %d targets every %v...\n", len(perTarget), interval)
+		if len(fleet.sources) > 0 {
+			fmt.Printf("Targets from %s, refreshed every %v\n", fleet.sources, fleet.refresh)
+		}
+		if via := dialer.Redacted(); via != "" {
+			fmt.Printf("TCP reachability checks go through %s\n", via)
+		}
+		fmt.Printf("Press Ctrl+C to stop\n\n")
+	}
+
+	summary := newWatchSummary(time.Now())
+	watchCtx, stop := watchContext(limits)
+	defer stop()
+
+	monitor := newFleetMonitor(destinations, dialer)
+	monitor.concurrency = concurrency
+	schedule := newProbeSchedule(destinations, interval, log)
+	schedule.print()
+	for {
+		start := time.Now()
+		if now := time.Now(); fleet.due(now) {
+			next, err := fleet.list(watchCtx, now)
+			if err != nil {
+				if outputErr := outputTargetRefreshError(now, fleet, monitor, err, format); outputErr != nil {
+					return outputErr
+				}
+			} else {
+				added, removed := monitor.setTargets(next)
+				perTarget = fleetOptions(base, next)
+				schedule = newProbeSchedule(next, interval, log)
+				if len(added) > 0 || len(removed) > 0 {
+					schedule.print()
+				}
+				if len(added) > 0 {
+					addedPlans := fleetPlans(fleetOptions(base, added), interval)
+					if err := checkProbeTargets(cmd, addedPlans...); err != nil {
+						return err
+					}
+					if err := recordProbeAudit(cmd, addedPlans...); err != nil {
+						return err
+					}
+					report.AddTargets(added...)
+				}
+				if !format.structured() {
+					outputTargetChanges(now, added, removed)
+				}
+			}
+		}
+
+		changed, ok := monitor.runFleetCycle(watchCtx, perTarget, schedule, start)
+		if !ok {
+			return endWatch(summary, watchStopReason(watchCtx), format, report, exp)
+		}
+
+		timestamp := time.Now()
+		for _, status := range monitor.targets {
+			sample := watchSample{Time: timestamp, Status: status.Health, PMTU: status.PMTU, MSS: status.MSS, RTTMS: status.RTTMS, Error: status.Error, Code: status.ErrorCode}
+			summary.Add(status.Target, sample)
+			report.Add(status.Target, sample)
+			metrics.Add(status.Target, sample)
+			if err := exp.Add(status.Target, sample); err != nil {
+				return err
+			}
+		}
+		if err := report.MaybeWrite(timestamp); err != nil {
+			return err
+		}
+
+		switch {
+		case events:
+			for _, event := range monitor.takeEvents() {
+				if err := format.writeRecord(event); err != nil {
+					return err
+				}
+			}
+		case format.structured():
+			if err := outputFleetSummaryStructured(timestamp, monitor, format); err != nil {
+				return err
+			}
+		default:
+			outputFleetSummary(timestamp, monitor, changed)
+		}
+		for _, transition := range changed {
+			notifyWatch(watchCtx, cmd, notifiers, newFleetTransitionEvent(timestamp, transition, monitor.status(transition.Target)))
+		}
+
+		summary.cycles++
+		if limits.reached(summary.cycles) {
+			return endWatch(summary, watchStoppedCount, format, report, exp)
+		}
+		// The next cycle starts an interval after this one did, so each target
+		// keeps its phase however long the probes took
+		if !sleepInterval(watchCtx, interval-time.Since(start)) {
+			return endWatch(summary, watchStopReason(watchCtx), format, report, exp)
+		}
+	}
+}
+
+// outputTargetRefreshError reports a failed --targets-from refresh; the watch
+// carries on with the targets it already has
+func outputTargetRefreshError(timestamp time.Time, fleet *fleetTargets, monitor *fleetMonitor, err error, format outputFormat) error {
+	if format.structured() {
+		return outputWatchError(timestamp, fleet.sources.String(), err, format)
+	}
+	fmt.Printf("[%s] Target refresh failed [%s]: %v; keeping %d targets\n",
+		timestamp.Format("15:04:05"), errcode.Of(err), err, len(monitor.targets))
+	return nil
+}
+
+// outputTargetChanges lists the targets a --targets-from refresh added and removed
+func outputTargetChanges(timestamp time.Time, added, removed []string) {
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	fmt.Printf("[%s] Targets: %d added, %d removed\n", timestamp.Format("15:04:05"), len(added), len(removed))
+	for _, target := range added {
+		fmt.Printf("  + %s\n", target)
+	}
+	for _, target := range removed {
+		fmt.Printf("  - %s\n", target)
+	}
+}
+
+func outputFleetSummary(timestamp time.Time, monitor *fleetMonitor, changed []fleetTransition) {
+	counts := monitor.counts()
+	parts := make([]string, 0, len(healthClasses))
+	for _, class := range healthClasses {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[class], class))
+	}
+	fmt.Printf("[%s] Fleet: %s\n", timestamp.Format("15:04:05"), strings.Join(parts, ", "))
+
+	for _, transition := range changed {
+		fmt.Printf("  ! %s: %s -> %s\n", transition.Target, transition.From, transition.To)
+	}
+	for _, status := range monitor.targets {
+		if status.Health == healthHealthy {
+			continue
+		}
+		detail := fmt.Sprintf("since %s", status.since.Format("15:04:05"))
+		switch {
+		case status.Health == healthDegraded:
+			detail = fmt.Sprintf("PMTU %d (best %d), %s", status.PMTU, status.BestPMTU, detail)
+		case status.Health == healthICMPBlocked:
+			detail = fmt.Sprintf("[%s] %s; TCP connect %s, %s", status.ErrorCode, status.Error, connectSummary(status), detail)
+		case status.Error != "":
+			detail = fmt.Sprintf("[%s] %s, %s", status.ErrorCode, status.Error, detail)
+		}
+		fmt.Printf("    %-12s %s: %s\n", status.Health, status.Target, detail)
+	}
+}
+
+// connectSummary formats the reachability check, breaking out the proxy leg
+func connectSummary(status *fleetTargetStatus) string {
+	if status.ProxyConnectMS > 0 {
+		return fmt.Sprintf("%.1fms (proxy %.1fms)", status.ConnectMS, status.ProxyConnectMS)
+	}
+	return fmt.Sprintf("%.1fms", status.ConnectMS)
+}
+
+func outputFleetSummaryStructured(timestamp time.Time, monitor *fleetMonitor, format outputFormat) error {
+	transitions := monitor.transitions
+	if transitions == nil {
+		transitions = []fleetTransition{}
+	}
+	return format.writeRecord(struct {
+		Timestamp   string               `json:"timestamp"`
+		Counts      map[string]int       `json:"counts"`
+		Targets     []*fleetTargetStatus `json:"targets"`
+		Transitions []fleetTransition    `json:"recent_transitions"`
+	}{
+		Timestamp:   timestamp.Format(time.RFC3339),
+		Counts:      monitor.counts(),
+		Targets:     monitor.targets,
+		Transitions: transitions,
+	})
+}
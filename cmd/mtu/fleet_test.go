@@ -0,0 +1,399 @@
+package mtu
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/audit"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/proxy"
+	"github.com/spf13/cobra"
+)
+
+func stubFleet(t *testing.T, discover func(opts discoveryOptions) (*MTUResult, error), reachable func(opts discoveryOptions) (proxy.Timing, error)) {
+	t.Helper()
+	originalDiscovery, originalReachable := fleetDiscovery, fleetReachable
+	t.Cleanup(func() { fleetDiscovery, fleetReachable = originalDiscovery, originalReachable })
+	fleetDiscovery = func(_ context.Context, opts discoveryOptions) (*MTUResult, error) {
+		return discover(opts)
+	}
+	fleetReachable = func(_ context.Context, opts discoveryOptions, _ *proxy.Dialer) (proxy.Timing, error) {
+		return reachable(opts)
+	}
+}
+
+func TestClassifyTarget(t *testing.T) {
+	reachable := func() bool { return true }
+	unreachable := func() bool { return false }
+	failed := errors.New("no working MTU found")
+
+	tests := []struct {
+		name      string
+		protocol  string
+		result    *MTUResult
+		err       error
+		bestPMTU  int
+		reachable func() bool
+		want      string
+	}{
+		{name: "first success", protocol: "icmp", result: &MTUResult{PMTU: 1500}, want: healthHealthy},
+		{name: "at best", protocol: "icmp", result: &MTUResult{PMTU: 1500}, bestPMTU: 1500, want: healthHealthy},
+		{name: "below best", protocol: "udp", result: &MTUResult{PMTU: 1400}, bestPMTU: 1500, want: healthDegraded},
+		{name: "icmp filtered", protocol: "icmp", err: failed, reachable: reachable, want: healthICMPBlocked},
+		{name: "icmp and tcp fail", protocol: "icmp", err: failed, reachable: unreachable, want: healthDown},
+		{name: "udp failure is down", protocol: "udp", err: failed, reachable: reachable, want: healthDown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyTarget(tt.protocol, tt.result, tt.err, tt.bestPMTU, tt.reachable); got != tt.want {
+				t.Fatalf("classifyTarget() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFleetMonitorTracksTransitions(t *testing.T) {
+	pmtu := map[string]int{"192.0.2.1": 1500, "192.0.2.2": 1500, "192.0.2.3": 0}
+	stubFleet(t, func(opts discoveryOptions) (*MTUResult, error) {
+		if pmtu[opts.Destination] == 0 {
+			return nil, errNoWorkingMTU
+		}
+		return &MTUResult{Target: opts.Destination, PMTU: pmtu[opts.Destination]}, nil
+	}, func(opts discoveryOptions) (proxy.Timing, error) {
+		if opts.Destination != "192.0.2.3" {
+			return proxy.Timing{}, errors.New("connection refused")
+		}
+		return proxy.Timing{ProxyConnect: 2 * time.Millisecond, Tunnel: 8 * time.Millisecond, Total: 10 * time.Millisecond}, nil
+	})
+
+	perTarget := []discoveryOptions{
+		{Destination: "192.0.2.1", Protocol: "icmp", Timeout: time.Second},
+		{Destination: "192.0.2.2", Protocol: "icmp", Timeout: time.Second},
+		{Destination: "192.0.2.3", Protocol: "icmp", Timeout: time.Second},
+	}
+	destinations := []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}
+	monitor := newFleetMonitor(destinations, &proxy.Dialer{})
+	schedule := newProbeSchedule(destinations, 0, nil)
+	ctx := context.Background()
+
+	if changed, _ := monitor.runFleetCycle(ctx, perTarget, schedule, time.Now()); len(changed) != 0 {
+		t.Fatalf("first cycle should not report transitions, got %+v", changed)
+	}
+	counts := monitor.counts()
+	if counts[healthHealthy] != 2 || counts[healthICMPBlocked] != 1 {
+		t.Fatalf("unexpected first-cycle counts: %v", counts)
+	}
+	if monitor.targets[2].ErrorCode != errcode.MTUICMPFiltered {
+		t.Fatalf("expected icmp-blocked target to carry MTU014, got %+v", monitor.targets[2])
+	}
+	if status := monitor.targets[2]; status.ConnectMS != 10 || status.ProxyConnectMS != 2 || status.ConnectError != "" {
+		t.Fatalf("expected proxied connect timing on icmp-blocked target, got %+v", status)
+	}
+	if status := monitor.targets[0]; status.ConnectMS != 0 {
+		t.Fatalf("reachability check should not run for healthy targets, got %+v", status)
+	}
+
+	pmtu["192.0.2.2"] = 1400
+	changed, _ := monitor.runFleetCycle(ctx, perTarget, schedule, time.Now())
+	if len(changed) != 1 || changed[0].Target != "192.0.2.2" || changed[0].From != healthHealthy || changed[0].To != healthDegraded {
+		t.Fatalf("expected 192.0.2.2 to degrade, got %+v", changed)
+	}
+	if status := monitor.targets[1]; status.PMTU != 1400 || status.BestPMTU != 1500 {
+		t.Fatalf("unexpected degraded status: %+v", status)
+	}
+
+	pmtu["192.0.2.2"] = 1500
+	changed, _ = monitor.runFleetCycle(ctx, perTarget, schedule, time.Now())
+	if len(changed) != 1 || changed[0].To != healthHealthy {
+		t.Fatalf("expected 192.0.2.2 to recover, got %+v", changed)
+	}
+	if len(monitor.transitions) != 2 {
+		t.Fatalf("expected two recent transitions, got %+v", monitor.transitions)
+	}
+}
+
+func TestFleetCycleRunsProbesConcurrently(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	stubFleet(t, func(opts discoveryOptions) (*MTUResult, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return &MTUResult{Target: opts.Destination, PMTU: 1500}, nil
+	}, func(discoveryOptions) (proxy.Timing, error) { return proxy.Timing{}, nil })
+
+	destinations := []string{"192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4", "192.0.2.5", "192.0.2.6"}
+	var perTarget []discoveryOptions
+	for _, destination := range destinations {
+		perTarget = append(perTarget, discoveryOptions{Destination: destination, Protocol: "icmp", Timeout: time.Second})
+	}
+	monitor := newFleetMonitor(destinations, &proxy.Dialer{})
+	monitor.concurrency = 3
+	if _, ok := monitor.runFleetCycle(context.Background(), perTarget, newProbeSchedule(destinations, 0, nil), time.Now()); !ok {
+		t.Fatal("cycle did not complete")
+	}
+	if got := peak.Load(); got != 3 {
+		t.Errorf("peak probes in flight = %d, want 3", got)
+	}
+	if counts := monitor.counts(); counts[healthHealthy] != len(destinations) {
+		t.Errorf("every target should be probed once, got %v", counts)
+	}
+}
+
+func TestFleetMonitorEvents(t *testing.T) {
+	pmtu := map[string]int{"192.0.2.1": 1400, "192.0.2.2": 1500}
+	stubFleet(t, func(opts discoveryOptions) (*MTUResult, error) {
+		if pmtu[opts.Destination] == 0 {
+			return nil, errNoWorkingMTU
+		}
+		return &MTUResult{Target: opts.Destination, PMTU: pmtu[opts.Destination], MSS: pmtu[opts.Destination] - 40}, nil
+	}, func(discoveryOptions) (proxy.Timing, error) { return proxy.Timing{}, errors.New("refused") })
+
+	destinations := []string{"192.0.2.1", "192.0.2.2"}
+	perTarget := fleetOptions(discoveryOptions{Protocol: "udp", Timeout: time.Second}, destinations)
+	monitor := newFleetMonitor(destinations, &proxy.Dialer{})
+	schedule := newProbeSchedule(destinations, 0, nil)
+	cycle := func() []fleetEvent {
+		monitor.runFleetCycle(context.Background(), perTarget, schedule, time.Now())
+		events := monitor.takeEvents()
+		slices.SortFunc(events, func(a, b fleetEvent) int { return strings.Compare(a.Target, b.Target) })
+		return events
+	}
+
+	events := cycle()
+	if len(events) != 2 || events[0].Change != fleetChangeInitial || events[0].PMTU != 1400 || events[0].MSS != 1360 {
+		t.Fatalf("first cycle events = %+v, want one initial event per target", events)
+	}
+	if events := cycle(); len(events) != 0 {
+		t.Fatalf("an unchanged cycle should have no events, got %+v", events)
+	}
+
+	// 192.0.2.1 improves and stays healthy; 192.0.2.2 goes down
+	pmtu["192.0.2.1"], pmtu["192.0.2.2"] = 1500, 0
+	events = cycle()
+	if len(events) != 2 {
+		t.Fatalf("expected two events, got %+v", events)
+	}
+	if e := events[0]; e.Change != fleetChangePMTU || e.PreviousPMTU != 1400 || e.PMTU != 1500 || e.Health != healthHealthy {
+		t.Errorf("unexpected pmtu event %+v", e)
+	}
+	if e := events[1]; e.Change != fleetChangeHealth || e.PreviousHealth != healthHealthy || e.Health != healthDown || e.PreviousPMTU != 1500 || e.Error == "" {
+		t.Errorf("unexpected health event %+v", e)
+	}
+}
+
+func TestFleetMonitorBoundsTransitions(t *testing.T) {
+	monitor := newFleetMonitor([]string{"192.0.2.1"}, &proxy.Dialer{})
+	now := time.Now()
+	monitor.update(0, healthHealthy, &MTUResult{PMTU: 1500}, nil, now)
+	for i := 0; i < maxFleetTransitions+5; i++ {
+		health := healthDown
+		if i%2 == 1 {
+			health = healthHealthy
+		}
+		monitor.update(0, health, &MTUResult{PMTU: 1500}, nil, now)
+	}
+	if len(monitor.transitions) != maxFleetTransitions {
+		t.Fatalf("expected %d transitions, got %d", maxFleetTransitions, len(monitor.transitions))
+	}
+}
+
+func TestOutputFleetSummaryJSON(t *testing.T) {
+	monitor := newFleetMonitor([]string{"192.0.2.1", "192.0.2.2"}, &proxy.Dialer{})
+	now := time.Now()
+	monitor.update(0, healthHealthy, &MTUResult{PMTU: 1500}, nil, now)
+	monitor.update(1, healthDown, nil, errcode.Errorf(errcode.MTUTimeout, "timed out"), now)
+
+	output, err := captureStdout(t, func() error {
+		return outputFleetSummaryStructured(now, monitor, formatJSON)
+	})
+	if err != nil {
+		t.Fatalf("outputFleetSummaryStructured returned error: %v", err)
+	}
+
+	var summary struct {
+		Counts      map[string]int      `json:"counts"`
+		Targets     []fleetTargetStatus `json:"targets"`
+		Transitions []fleetTransition   `json:"recent_transitions"`
+	}
+	if err := json.Unmarshal([]byte(output), &summary); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, output)
+	}
+	if summary.Counts[healthHealthy] != 1 || summary.Counts[healthDown] != 1 || summary.Counts[healthDegraded] != 0 {
+		t.Fatalf("unexpected counts: %v", summary.Counts)
+	}
+	if summary.Targets[1].ErrorCode != errcode.MTUTimeout || summary.Transitions == nil {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+}
+
+func TestRunWatchFleetDryRun(t *testing.T) {
+	cmd := newDiscoveryOptionsCommand()
+	cmd.Flags().Duration("interval", 10*time.Second, "")
+	cmd.Flags().Bool("mss-only", false, "")
+	cmd.Flags().Bool("dry-run", false, "")
+	mustSetFlag(t, cmd, "dry-run", "true")
+	mustSetFlag(t, cmd, "json", "true")
+
+	output, err := captureStdout(t, func() error {
+		return runWatch(cmd, []string{"192.0.2.1", "192.0.2.2"})
+	})
+	if err != nil {
+		t.Fatalf("runWatch returned error: %v", err)
+	}
+
+	var plans []dryRunPlan
+	if err := json.Unmarshal([]byte(output), &plans); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, output)
+	}
+	if len(plans) != 2 || plans[1].Target != "192.0.2.2" || plans[1].IntervalMS != 10000 {
+		t.Fatalf("unexpected fleet plans: %+v", plans)
+	}
+}
+
+func TestRunWatchProxyFlag(t *testing.T) {
+	newCmd := func(t *testing.T, proxyURL string) *cobra.Command {
+		cmd := newDiscoveryOptionsCommand()
+		cmd.Flags().Duration("interval", 10*time.Second, "")
+		cmd.Flags().Bool("mss-only", false, "")
+		cmd.Flags().Bool("dry-run", false, "")
+		cmd.Flags().String("proxy", "", "")
+		mustSetFlag(t, cmd, "dry-run", "true")
+		mustSetFlag(t, cmd, "proxy", proxyURL)
+		return cmd
+	}
+
+	err := runWatch(newCmd(t, "socks5://127.0.0.1:1080"), []string{"192.0.2.1"})
+	if err == nil || errcode.Of(err) != errcode.CLIUsage || !strings.Contains(err.Error(), "fleet mode") {
+		t.Fatalf("expected single-target watch to reject --proxy, got %v", err)
+	}
+
+	err = runWatch(newCmd(t, "ftp://127.0.0.1:21"), []string{"192.0.2.1", "192.0.2.2"})
+	if err == nil || !strings.Contains(err.Error(), "unsupported proxy scheme") {
+		t.Fatalf("expected invalid proxy to be rejected, got %v", err)
+	}
+
+	if _, err := captureStdout(t, func() error {
+		return runWatch(newCmd(t, "http://127.0.0.1:3128"), []string{"192.0.2.1", "192.0.2.2"})
+	}); err != nil {
+		t.Fatalf("fleet dry run with --proxy returned error: %v", err)
+	}
+}
+
+func TestRunWatchFleetFlags(t *testing.T) {
+	for _, tt := range []struct {
+		args  []string
+		flags map[string]string
+	}{
+		{[]string{"192.0.2.1"}, map[string]string{"events": "true", "json": "true"}},
+		{[]string{"192.0.2.1", "192.0.2.2"}, map[string]string{"events": "true"}},
+		{[]string{"192.0.2.1", "192.0.2.2"}, map[string]string{"concurrency": "0"}},
+	} {
+		cmd := newDiscoveryOptionsCommand()
+		cmd.Flags().Duration("interval", 10*time.Second, "")
+		cmd.Flags().Bool("dry-run", false, "")
+		cmd.Flags().Bool("events", false, "")
+		cmd.Flags().Int("concurrency", defaultFleetConcurrency, "")
+		mustSetFlag(t, cmd, "dry-run", "true")
+		for name, value := range tt.flags {
+			mustSetFlag(t, cmd, name, value)
+		}
+		if err := runWatch(cmd, tt.args); errcode.Of(err) != errcode.CLIUsage {
+			t.Errorf("runWatch %v with %v = %v, want CLI002", tt.args, tt.flags, err)
+		}
+	}
+}
+
+func TestFleetMonitorSetTargets(t *testing.T) {
+	monitor := newFleetMonitor([]string{"192.0.2.1", "192.0.2.2"}, &proxy.Dialer{})
+	monitor.update(0, healthHealthy, &MTUResult{PMTU: 1500}, nil, time.Now())
+
+	added, removed := monitor.setTargets([]string{"192.0.2.3", "192.0.2.1"})
+	if strings.Join(added, ",") != "192.0.2.3" || strings.Join(removed, ",") != "192.0.2.2" {
+		t.Fatalf("setTargets added %v, removed %v", added, removed)
+	}
+	if len(monitor.targets) != 2 || monitor.targets[0].Target != "192.0.2.3" || monitor.targets[1].BestPMTU != 1500 {
+		t.Fatalf("expected 192.0.2.1 to keep its history, got %+v", monitor.targets)
+	}
+}
+
+func TestRunWatchTargetsFrom(t *testing.T) {
+	hosts := `["192.0.2.10:9100", "192.0.2.11:9100"]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"targets": ` + hosts + `}]`))
+	}))
+	defer server.Close()
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	audit.SetPath(auditPath)
+	t.Cleanup(func() { audit.SetPath("") })
+
+	newCmd := func(t *testing.T, flags map[string]string) *cobra.Command {
+		cmd := newDiscoveryOptionsCommand()
+		cmd.Flags().Duration("interval", 10*time.Second, "")
+		cmd.Flags().Bool("mss-only", false, "")
+		cmd.Flags().Bool("dry-run", false, "")
+		cmd.Flags().StringArray("targets-from", nil, "")
+		cmd.Flags().Duration("targets-refresh", time.Minute, "")
+		mustSetFlag(t, cmd, "dry-run", "true")
+		mustSetFlag(t, cmd, "json", "true")
+		for name, value := range flags {
+			mustSetFlag(t, cmd, name, value)
+		}
+		return cmd
+	}
+
+	output, err := captureStdout(t, func() error {
+		return runWatch(newCmd(t, map[string]string{"targets-from": "prometheus-http-sd:" + server.URL}), []string{"192.0.2.10"})
+	})
+	if err != nil {
+		t.Fatalf("runWatch returned error: %v", err)
+	}
+	var plans []dryRunPlan
+	if err := json.Unmarshal([]byte(output), &plans); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, output)
+	}
+	if len(plans) != 2 || plans[0].Target != "192.0.2.10" || plans[1].Target != "192.0.2.11" {
+		t.Fatalf("expected the command-line target plus discovered hosts, got %+v", plans)
+	}
+	// A dry run sends no probes, but the discovery request is real traffic
+	entries, err := audit.ReadAll(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Protocol != "http" || entries[0].IntervalMS != time.Minute.Milliseconds() ||
+		len(entries[0].Targets) != 1 || entries[0].Targets[0] != server.URL {
+		t.Fatalf("expected the discovery request to be audited, got %+v", entries)
+	}
+
+	hosts = `"not a list"`
+	if _, err = captureStdout(t, func() error {
+		return runWatch(newCmd(t, map[string]string{"targets-from": "prometheus-http-sd:" + server.URL}), nil)
+	}); errcode.Of(err) != errcode.CLITargetDiscovery {
+		t.Fatalf("expected CLI007 for a bad discovery response, got %v", err)
+	}
+
+	for _, flags := range []map[string]string{
+		{"targets-from": "zookeeper:/web"},
+		{"targets-refresh": "5m"},
+		{"targets-from": "consul:service=web", "targets-refresh": "0s"},
+	} {
+		if err := runWatch(newCmd(t, flags), nil); errcode.Of(err) != errcode.CLIUsage {
+			t.Errorf("runWatch with %v = %v, want CLI002", flags, err)
+		}
+	}
+}
@@ -180,7 +180,7 @@ func TestHopDiscoveryHelpers(t *testing.T) {
 		discoverer.targetAddr = &net.IPAddr{IP: targetIP}
 
 		var progress bytes.Buffer
-		discoverer.SetProgressWriter(&progress)
+		discoverer.SetProgress(progressPrinter(&progress, &progress))
 
 		result, err := discoverer.DiscoverHopByHopMTU(context.Background(), 4, 1600)
 		if err != nil {
@@ -0,0 +1,93 @@
+package mtu
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/enrich"
+)
+
+// hopEnrichTimeout bounds the reverse DNS and ASN lookups for a whole path
+const hopEnrichTimeout = 10 * time.Second
+
+// enrichLookup annotates one hop address; replaced in tests
+var enrichLookup = (&enrich.Enricher{}).Lookup
+
+// enrichHops fills in the reverse DNS name and origin AS of every hop that
+// answered, looking the hops up concurrently
+func enrichHops(hops []*HopInfo) {
+	ctx, cancel := context.WithTimeout(context.Background(), hopEnrichTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, hop := range hops {
+		addr, ok := netip.AddrFromSlice(hop.Addr)
+		if !ok {
+			continue
+		}
+		addr = addr.Unmap()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			info := enrichLookup(ctx, addr)
+			hop.Name, hop.ASN, hop.ASHolder = info.Name, info.ASN, info.Holder
+		}()
+	}
+	wg.Wait()
+}
+
+// hopGroup is a run of contiguous hops in one AS. Hops that did not answer
+// stay in the group they follow.
+type hopGroup struct {
+	ASN      uint32
+	Holder   string
+	Start    int // Index of the group's first hop
+	FirstHop int // Hop number the group starts at
+	answered bool
+}
+
+func (g hopGroup) label() string {
+	switch {
+	case g.ASN == 0:
+		return "no ASN (private or unannounced)"
+	case g.Holder == "":
+		return fmt.Sprintf("AS%d", g.ASN)
+	default:
+		return fmt.Sprintf("AS%d %s", g.ASN, g.Holder)
+	}
+}
+
+// groupHopsByASN splits a path into runs of hops in the same AS
+func groupHopsByASN(hops []*HopInfo) []hopGroup {
+	var groups []hopGroup
+	for i, hop := range hops {
+		if len(groups) == 0 {
+			groups = append(groups, hopGroup{Start: i, FirstHop: hop.Hop})
+		}
+		if hop.Addr == nil {
+			continue
+		}
+		group := &groups[len(groups)-1]
+		switch {
+		case !group.answered:
+			// Leading timeouts take the AS of the first hop that answers
+			group.ASN, group.Holder, group.answered = hop.ASN, hop.ASHolder, true
+		case hop.ASN != group.ASN:
+			groups = append(groups, hopGroup{ASN: hop.ASN, Holder: hop.ASHolder, Start: i, FirstHop: hop.Hop, answered: true})
+		}
+	}
+	return groups
+}
+
+// hopsEnriched reports whether any hop carries enrichment
+func hopsEnriched(hops []*HopInfo) bool {
+	for _, hop := range hops {
+		if hop.Name != "" || hop.ASN != 0 {
+			return true
+		}
+	}
+	return false
+}
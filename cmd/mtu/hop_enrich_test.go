@@ -0,0 +1,93 @@
+package mtu
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/enrich"
+)
+
+func TestEnrichHops(t *testing.T) {
+	original := enrichLookup
+	t.Cleanup(func() { enrichLookup = original })
+	enrichLookup = func(ctx context.Context, addr netip.Addr) enrich.Info {
+		if addr.String() != "154.54.1.1" {
+			t.Errorf("unexpected lookup for %s", addr)
+		}
+		return enrich.Info{Name: "be2001.cogentco.com", ASN: 174, Holder: "Cogent Communications"}
+	}
+
+	hops := []*HopInfo{{Hop: 1, Addr: net.ParseIP("154.54.1.1")}, {Hop: 2, Timeout: true}}
+	enrichHops(hops)
+	if hops[0].Name != "be2001.cogentco.com" || hops[0].ASN != 174 || hops[0].ASHolder != "Cogent Communications" {
+		t.Fatalf("hop not enriched: %+v", hops[0])
+	}
+	if hops[1].ASN != 0 || hops[1].Name != "" {
+		t.Fatalf("a hop without an address was enriched: %+v", hops[1])
+	}
+}
+
+func TestGroupHopsByASN(t *testing.T) {
+	hops := []*HopInfo{
+		{Hop: 1, Timeout: true},
+		{Hop: 2, Addr: net.ParseIP("10.0.0.1")},
+		{Hop: 3, Addr: net.ParseIP("154.54.1.1"), ASN: 174, ASHolder: "Cogent Communications"},
+		{Hop: 4, Timeout: true},
+		{Hop: 5, Addr: net.ParseIP("154.54.1.2"), ASN: 174, ASHolder: "Cogent Communications"},
+		{Hop: 6, Addr: net.ParseIP("4.69.1.1"), ASN: 3356},
+	}
+
+	groups := groupHopsByASN(hops)
+	want := []struct {
+		asn      uint32
+		start    int
+		firstHop int
+		label    string
+	}{
+		{0, 0, 1, "no ASN (private or unannounced)"},
+		{174, 2, 3, "AS174 Cogent Communications"},
+		{3356, 5, 6, "AS3356"},
+	}
+	if len(groups) != len(want) {
+		t.Fatalf("got %d groups, want %d: %+v", len(groups), len(want), groups)
+	}
+	for i, w := range want {
+		if groups[i].ASN != w.asn || groups[i].Start != w.start || groups[i].FirstHop != w.firstHop || groups[i].label() != w.label {
+			t.Errorf("group %d = %+v (%s), want %+v", i, groups[i], groups[i].label(), w)
+		}
+	}
+}
+
+func TestOutputHopTableEnriched(t *testing.T) {
+	result := &HopMTUResult{
+		Target:   "example.com",
+		Protocol: "icmp",
+		Hops: []*HopInfo{
+			{Hop: 1, Addr: net.ParseIP("154.54.1.1"), RTT: 5 * time.Millisecond, Name: "be2001.cogentco.com", ASN: 174, ASHolder: "Cogent Communications"},
+			{Hop: 2, Addr: net.ParseIP("4.69.1.1"), RTT: 9 * time.Millisecond, Name: "ae1.lumen.net", ASN: 3356, ASHolder: "Lumen"},
+		},
+	}
+
+	output, err := captureStdout(t, func() error {
+		return outputHopTable(result)
+	})
+	if err != nil {
+		t.Fatalf("outputHopTable returned error: %v", err)
+	}
+
+	for _, expected := range []string{
+		"Name",
+		"[AS174 Cogent Communications]",
+		"be2001.cogentco.com",
+		"[AS3356 Lumen]",
+		"AS174 Cogent Communications → AS3356 Lumen at hop 2",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected enriched hop table to contain %q, got %q", expected, output)
+		}
+	}
+}
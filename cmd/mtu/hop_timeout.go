@@ -0,0 +1,75 @@
+package mtu
+
+import "time"
+
+// Hop probes adapt their read timeout to each hop's observed round-trip time
+// using the RFC 6298 estimator, so close hops stop waiting out the full
+// --timeout while distant satellite or intercontinental hops keep enough
+// headroom. --timeout remains the ceiling and is used until a hop has answered.
+const (
+	rttSmoothingGain   = 0.125 // Weight of a new sample in the smoothed RTT
+	rttVariationGain   = 0.25  // Weight of a new sample in the RTT variation
+	hopTimeoutRTTScale = 2     // Timeout is this many smoothed RTTs...
+	hopTimeoutVarScale = 4     // ...plus this many RTT variations for jitter
+
+	// minHopTimeout keeps sub-millisecond LAN hops from timing out on scheduler noise
+	minHopTimeout = 50 * time.Millisecond
+)
+
+// rttEstimate is the smoothed RTT state for one hop
+type rttEstimate struct {
+	srtt   time.Duration
+	rttvar time.Duration
+}
+
+// hopTimeoutEstimator tracks an RTT estimate per TTL and derives probe timeouts
+type hopTimeoutEstimator struct {
+	ceiling   time.Duration
+	estimates map[int]*rttEstimate
+}
+
+func newHopTimeoutEstimator(ceiling time.Duration) *hopTimeoutEstimator {
+	return &hopTimeoutEstimator{
+		ceiling:   ceiling,
+		estimates: make(map[int]*rttEstimate),
+	}
+}
+
+// Observe folds a measured round trip to the hop at ttl into its estimate
+func (e *hopTimeoutEstimator) Observe(ttl int, rtt time.Duration) {
+	if rtt <= 0 {
+		return
+	}
+
+	est, ok := e.estimates[ttl]
+	if !ok {
+		e.estimates[ttl] = &rttEstimate{srtt: rtt, rttvar: rtt / 2}
+		return
+	}
+
+	deviation := est.srtt - rtt
+	if deviation < 0 {
+		deviation = -deviation
+	}
+	est.rttvar = time.Duration((1-rttVariationGain)*float64(est.rttvar) + rttVariationGain*float64(deviation))
+	est.srtt = time.Duration((1-rttSmoothingGain)*float64(est.srtt) + rttSmoothingGain*float64(rtt))
+}
+
+// Timeout returns how long to wait for a reply from the hop at ttl. Timeouts
+// are not fed back as samples: during size searches they usually mean the
+// probe was too large, not that the hop got slower.
+func (e *hopTimeoutEstimator) Timeout(ttl int) time.Duration {
+	est, ok := e.estimates[ttl]
+	if !ok {
+		return e.ceiling
+	}
+
+	timeout := hopTimeoutRTTScale*est.srtt + hopTimeoutVarScale*est.rttvar
+	if timeout < minHopTimeout {
+		timeout = minHopTimeout
+	}
+	if timeout > e.ceiling {
+		timeout = e.ceiling
+	}
+	return timeout
+}
@@ -0,0 +1,106 @@
+package mtu
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestHopTimeoutEstimator(t *testing.T) {
+	t.Run("unseen hops use the ceiling", func(t *testing.T) {
+		estimator := newHopTimeoutEstimator(2 * time.Second)
+		if got := estimator.Timeout(5); got != 2*time.Second {
+			t.Fatalf("Timeout() = %v, want ceiling", got)
+		}
+	})
+
+	t.Run("first sample sets srtt and half-rtt variation", func(t *testing.T) {
+		estimator := newHopTimeoutEstimator(2 * time.Second)
+		estimator.Observe(3, 100*time.Millisecond)
+
+		// 2×100ms + 4×50ms
+		if got := estimator.Timeout(3); got != 400*time.Millisecond {
+			t.Fatalf("Timeout() = %v, want 400ms", got)
+		}
+		if got := estimator.Timeout(4); got != 2*time.Second {
+			t.Fatalf("other hops should be unaffected, got %v", got)
+		}
+	})
+
+	t.Run("stable samples tighten the timeout", func(t *testing.T) {
+		estimator := newHopTimeoutEstimator(2 * time.Second)
+		for range 20 {
+			estimator.Observe(1, 40*time.Millisecond)
+		}
+		got := estimator.Timeout(1)
+		if got < 80*time.Millisecond || got > 100*time.Millisecond {
+			t.Fatalf("Timeout() = %v, want close to 2×40ms once variation decays", got)
+		}
+	})
+
+	t.Run("clamped to floor and ceiling", func(t *testing.T) {
+		estimator := newHopTimeoutEstimator(time.Second)
+		estimator.Observe(1, 100*time.Microsecond)
+		estimator.Observe(30, 900*time.Millisecond)
+
+		if got := estimator.Timeout(1); got != minHopTimeout {
+			t.Fatalf("fast hop Timeout() = %v, want %v", got, minHopTimeout)
+		}
+		if got := estimator.Timeout(30); got != time.Second {
+			t.Fatalf("slow hop Timeout() = %v, want ceiling", got)
+		}
+	})
+
+	t.Run("non-positive samples are ignored", func(t *testing.T) {
+		estimator := newHopTimeoutEstimator(time.Second)
+		estimator.Observe(1, 0)
+		if got := estimator.Timeout(1); got != time.Second {
+			t.Fatalf("Timeout() = %v, want ceiling", got)
+		}
+	})
+}
+
+func TestProbeHopAdaptsTimeout(t *testing.T) {
+	routerIP := net.ParseIP("10.10.0.1")
+	conn := &fakePacketConn{}
+	hopConn := &fakeHopPacketConn{
+		packetConn: conn,
+		readFor: func(ttl, size int) fakePacketResponse {
+			if size > 1400 {
+				return fakePacketResponse{err: timeoutNetError{message: "i/o timeout"}}
+			}
+			return fakePacketResponse{
+				data: mustMarshalICMP(t, &icmp.Message{
+					Type: ipv4.ICMPTypeTimeExceeded,
+					Code: 0,
+					Body: &icmp.TimeExceeded{},
+				}),
+				addr: &net.IPAddr{IP: routerIP},
+			}
+		},
+	}
+	discoverer := newHopDiscovererForTest(conn, hopConn)
+	discoverer.timeout = 5 * time.Second
+
+	before := time.Now()
+	discoverer.probeHop(context.Background(), 1, 1000)
+	if wait := conn.lastReadDeadline.Sub(before); wait < 4*time.Second {
+		t.Fatalf("first probe to a hop should wait up to --timeout, waited %v", wait)
+	}
+
+	before = time.Now()
+	if hop := discoverer.probeHop(context.Background(), 1, 1500); !hop.Timeout {
+		t.Fatalf("expected oversized probe to time out: %+v", hop)
+	}
+	if wait := conn.lastReadDeadline.Sub(before); wait > time.Second {
+		t.Fatalf("answered hop should use an adaptive timeout, waited %v", wait)
+	}
+
+	if got := discoverer.hopTimeouts.Timeout(1); got != minHopTimeout {
+		t.Fatalf("timeouts must not feed the estimate, got %v", got)
+	}
+}
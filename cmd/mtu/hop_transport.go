@@ -0,0 +1,360 @@
+package mtu
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	// tracerouteBasePort is the first of the high UDP ports traceroute and
+	// tracepath probe, one port per TTL, where nothing is expected to listen
+	tracerouteBasePort = 33434
+
+	// defaultHopTCPPort is where TCP hop probes go without --port
+	defaultHopTCPPort = 443
+)
+
+// hopProbeAnswer is what the probe's own socket heard back
+type hopProbeAnswer struct {
+	reached bool  // The destination answered the probe itself
+	err     error // The probe could not be sent
+}
+
+// hopTransportProbe is a UDP or TCP hop probe in flight. Its socket waits for
+// the destination's answer while the raw ICMP socket waits for the routers'.
+type hopTransportProbe struct {
+	protocol int // IP protocol the probe went out over: 6 for TCP, 17 for UDP
+	srcPort  int // 0 when the kernel picks it during the TCP handshake
+	dstPort  int
+	answer   chan hopProbeAnswer // Sent once, when the probe's socket is done
+	wg       sync.WaitGroup
+	conn     net.Conn // Closed to cut the wait short, nil for TCP
+}
+
+// matches reports whether an ICMP error quotes this probe
+func (p *hopTransportProbe) matches(quoted *FragmentationError, target net.IP) bool {
+	if quoted.OriginalProtocol != p.protocol || !quoted.OriginalDst.Equal(target) || quoted.OriginalDstPort != p.dstPort {
+		return false
+	}
+	return p.srcPort == 0 || quoted.OriginalSrcPort == p.srcPort
+}
+
+// stop cancels the probe and waits for its goroutine, which would otherwise
+// move the read deadline of a later probe
+func (p *hopTransportProbe) stop(cancel context.CancelFunc) {
+	cancel()
+	if p.conn != nil {
+		_ = p.conn.Close()
+	}
+	p.wg.Wait()
+}
+
+// probeTransportHop sends one UDP or TCP probe with the given TTL, the way
+// tracepath and tcptraceroute do, and reads the answer from the raw ICMP
+// socket: Time Exceeded names the hop, and the destination answers with Port
+// Unreachable, a UDP reply, a SYN-ACK, or a RST. UDP probes are size bytes
+// with DF set; TCP probes are SYNs and carry no size.
+func (d *MTUDiscoverer) probeTransportHop(ctx context.Context, ttl int, size int) *HopInfo {
+	start := time.Now()
+
+	// Apply rate limiting
+	d.security.RateLimiter.Wait()
+
+	hop := &HopInfo{
+		Hop: ttl,
+	}
+
+	if d.hopTimeouts == nil {
+		d.hopTimeouts = newHopTimeoutEstimator(d.timeout)
+	}
+
+	// The deadline is set before the probe goes out, so that the probe's
+	// socket can cut the read short when the destination answers it
+	sent := time.Now()
+	deadline := sent.Add(d.hopTimeouts.Timeout(ttl))
+	if err := d.conn.SetReadDeadline(deadline); err != nil {
+		hop.Error = fmt.Sprintf("failed to set read deadline: %v", err)
+		hop.RTT = time.Since(start)
+		return hop
+	}
+
+	probeCtx, cancel := context.WithDeadline(ctx, deadline)
+	var probe *hopTransportProbe
+	var err error
+	if d.protocol == "tcp" {
+		probe, err = d.startTCPHopProbe(probeCtx, ttl)
+	} else {
+		probe, err = d.startUDPHopProbe(probeCtx, ttl, size)
+	}
+	if err != nil {
+		cancel()
+		hop.Error = err.Error()
+		hop.RTT = time.Since(start)
+		return hop
+	}
+
+	d.awaitTransportHop(hop, probe, deadline)
+	probe.stop(cancel)
+
+	hop.RTT = time.Since(start)
+	if hop.Addr != nil && hop.Error == "" {
+		d.hopTimeouts.Observe(ttl, time.Since(sent))
+	}
+	return hop
+}
+
+// awaitTransportHop fills hop from the first answer to probe before deadline
+func (d *MTUDiscoverer) awaitTransportHop(hop *HopInfo, probe *hopTransportProbe, deadline time.Time) {
+	target := d.targetIP()
+	response := make([]byte, 1500)
+	for {
+		n, addr, err := d.conn.ReadFrom(response)
+		if err == nil {
+			var from net.IP
+			if ipAddr, ok := addr.(*net.IPAddr); ok {
+				from = ipAddr.IP
+			}
+			if d.classifyTransportReply(hop, response[:n], from, target, probe) {
+				return
+			}
+			continue
+		}
+		if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+			hop.Error = fmt.Sprintf("read error: %v", err)
+			return
+		}
+
+		// Before the deadline, only the probe's socket moves it up, once it
+		// has its answer
+		var answer hopProbeAnswer
+		early := time.Now().Before(deadline)
+		if early {
+			answer = <-probe.answer
+		} else {
+			select {
+			case answer = <-probe.answer:
+			default:
+			}
+		}
+		switch {
+		case answer.err != nil:
+			hop.Error = answer.err.Error()
+			return
+		case answer.reached:
+			hop.Addr = target
+			return
+		case !early:
+			hop.Timeout = true
+			return
+		}
+
+		// The socket gave up, as on EMSGSIZE after a Fragmentation Needed,
+		// but the ICMP error itself may still be on its way
+		if err := d.conn.SetReadDeadline(deadline); err != nil {
+			hop.Timeout = true
+			return
+		}
+	}
+}
+
+// classifyTransportReply fills hop from an ICMP message if it quotes probe,
+// and reports whether it did
+func (d *MTUDiscoverer) classifyTransportReply(hop *HopInfo, data []byte, from, target net.IP, probe *hopTransportProbe) bool {
+	if len(data) < 8 {
+		return false
+	}
+	typ, code := data[0], int(data[1])
+
+	var quoted *FragmentationError
+	timeExceeded, tooBig := false, false
+	if d.ipv6 {
+		switch ipv6.ICMPType(typ) {
+		case ipv6.ICMPTypeTimeExceeded:
+			timeExceeded = true
+			quoted = parseICMPv6Error(data[8:])
+		case ipv6.ICMPTypePacketTooBig:
+			tooBig = true
+			quoted = parseICMPv6Error(data[8:])
+		case ipv6.ICMPTypeDestinationUnreachable:
+			quoted = parseICMPv6Error(data[8:])
+		}
+	} else {
+		switch ipv4.ICMPType(typ) {
+		case ipv4.ICMPTypeTimeExceeded:
+			timeExceeded = true
+			quoted = parseICMPv4Error(data[8:])
+		case ipv4.ICMPTypeDestinationUnreachable:
+			tooBig = code == 4
+			quoted = parseICMPv4Error(data[8:])
+		}
+	}
+	if quoted == nil || !probe.matches(quoted, target) {
+		return false
+	}
+
+	hop.Addr = from
+	portUnreachable := 3
+	if d.ipv6 {
+		portUnreachable = 4
+	}
+	switch {
+	case timeExceeded:
+		// A router on the way, which is what the TTL was for
+	case tooBig && d.ipv6:
+		hop.Error = "Packet Too Big"
+		hop.MTU = int(binary.BigEndian.Uint32(data[4:8]))
+	case tooBig:
+		// RFC 1191: the next-hop MTU is in the last two bytes of the header
+		hop.Error = "Fragmentation Needed and Don't Fragment was Set"
+		hop.MTU = int(binary.BigEndian.Uint16(data[6:8]))
+	case code == portUnreachable && from.Equal(target):
+		// The destination itself, with nothing listening on the port
+	default:
+		hop.Error = fmt.Sprintf("Destination Unreachable (code %d)", code)
+	}
+	return true
+}
+
+// startUDPHopProbe sends a datagram of size bytes with DF set and the given
+// TTL. Without --port it goes to a high port that moves up with the TTL, as
+// tracepath's do, so the destination answers with Port Unreachable.
+func (d *MTUDiscoverer) startUDPHopProbe(ctx context.Context, ttl int, size int) (*hopTransportProbe, error) {
+	port := d.port
+	if port == 0 {
+		port = tracerouteBasePort + ttl - 1
+	}
+
+	var localAddr *net.UDPAddr
+	if sourcePort := d.sourcePorts.Next(); sourcePort != 0 {
+		localAddr = &net.UDPAddr{Port: sourcePort}
+	}
+	conn, err := net.DialUDP("udp", localAddr, &net.UDPAddr{IP: d.targetIP(), Port: port})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open UDP socket: %w", err)
+	}
+	raw, err := conn.SyscallConn()
+	if err == nil {
+		err = controlHopLimit(raw, d.ipv6, ttl)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	// DF is best-effort, as for UDP discovery
+	_ = setDontFragment(conn, d.ipv6)
+
+	payload := d.security.Randomizer.GenerateRandomPayload(payloadSizeForPacket(size, udpPacketOverhead(d.ipv6)))
+	if _, err := conn.Write(payload); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to send packet: %w", err)
+	}
+
+	probe := &hopTransportProbe{
+		protocol: syscall.IPPROTO_UDP,
+		srcPort:  conn.LocalAddr().(*net.UDPAddr).Port,
+		dstPort:  port,
+		answer:   make(chan hopProbeAnswer, 1),
+		conn:     conn,
+	}
+	deadline, _ := ctx.Deadline()
+	d.runHopProbe(probe, func() hopProbeAnswer {
+		_ = conn.SetReadDeadline(deadline)
+		_, err := conn.Read(make([]byte, 1500))
+		// Any reply comes from the destination, and so does the Port
+		// Unreachable the kernel reports as ECONNREFUSED
+		return hopProbeAnswer{reached: err == nil || errors.Is(err, syscall.ECONNREFUSED)}
+	})
+	return probe, nil
+}
+
+// startTCPHopProbe opens a connection whose SYN carries the given TTL, as
+// tcptraceroute does, to --port or 443. SYNs to a service the destination
+// offers pass firewalls that drop UDP and echo.
+func (d *MTUDiscoverer) startTCPHopProbe(ctx context.Context, ttl int) (*hopTransportProbe, error) {
+	port := d.port
+	if port == 0 {
+		port = defaultHopTCPPort
+	}
+
+	sourcePort := d.sourcePorts.Next()
+	var controlErr error
+	dialer := &net.Dialer{
+		Control: func(network, address string, c syscall.RawConn) error {
+			if sourcePort != 0 {
+				_ = c.Control(func(fd uintptr) { _ = setReuseAddr(fd) })
+			}
+			controlErr = controlHopLimit(c, d.ipv6, ttl)
+			return controlErr
+		},
+	}
+	if sourcePort != 0 {
+		dialer.LocalAddr = &net.TCPAddr{Port: sourcePort}
+	}
+
+	probe := &hopTransportProbe{
+		protocol: syscall.IPPROTO_TCP,
+		srcPort:  sourcePort,
+		dstPort:  port,
+		answer:   make(chan hopProbeAnswer, 1),
+	}
+	address := net.JoinHostPort(d.targetIP().String(), strconv.Itoa(port))
+	d.runHopProbe(probe, func() hopProbeAnswer {
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			// A RST is the destination answering with the port closed
+			return hopProbeAnswer{reached: errors.Is(err, syscall.ECONNREFUSED), err: controlErr}
+		}
+		if tcpConn, ok := conn.(*net.TCPConn); ok {
+			// Reset instead of lingering in TIME_WAIT, as --source-port may
+			// need the port again for the next hop
+			_ = tcpConn.SetLinger(0)
+		}
+		_ = conn.Close()
+		return hopProbeAnswer{reached: true}
+	})
+	return probe, nil
+}
+
+// runHopProbe waits for the probe's own answer in the background, then wakes
+// the ICMP read so the answer is seen at once
+func (d *MTUDiscoverer) runHopProbe(probe *hopTransportProbe, wait func() hopProbeAnswer) {
+	probe.wg.Add(1)
+	go func() {
+		defer probe.wg.Done()
+		answer := wait()
+		_ = d.conn.SetReadDeadline(time.Now())
+		probe.answer <- answer
+	}()
+}
+
+// targetIP returns the resolved target address
+func (d *MTUDiscoverer) targetIP() net.IP {
+	if ipAddr, ok := d.targetAddr.(*net.IPAddr); ok {
+		return ipAddr.IP
+	}
+	return nil
+}
+
+// controlHopLimit sets the TTL of the socket behind c
+func controlHopLimit(c syscall.RawConn, ipv6 bool, ttl int) error {
+	var setErr error
+	if err := c.Control(func(fd uintptr) {
+		setErr = setHopLimit(fd, ipv6, ttl)
+	}); err != nil {
+		return err
+	}
+	if setErr != nil {
+		return fmt.Errorf("failed to set TTL: %w", setErr)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package mtu
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// quotedUDPv4 builds an ICMPv4 error of type and code whose payload quotes a
+// UDP packet from srcPort to dst:dstPort, with rest as the header's last four
+// bytes
+func quotedUDPv4(typ ipv4.ICMPType, code int, rest uint32, dst net.IP, srcPort, dstPort int) []byte {
+	data := make([]byte, 8+28)
+	data[0], data[1] = byte(typ), byte(code)
+	binary.BigEndian.PutUint32(data[4:8], rest)
+	quoted := data[8:]
+	quoted[0] = 0x45
+	binary.BigEndian.PutUint16(quoted[2:4], 1500)
+	quoted[9] = 17
+	copy(quoted[16:20], dst.To4())
+	binary.BigEndian.PutUint16(quoted[20:22], uint16(srcPort))
+	binary.BigEndian.PutUint16(quoted[22:24], uint16(dstPort))
+	return data
+}
+
+func TestClassifyTransportReply(t *testing.T) {
+	routerIP := net.ParseIP("10.10.0.1")
+	targetIP := net.ParseIP("198.51.100.10")
+	probe := &hopTransportProbe{protocol: 17, srcPort: 53000, dstPort: 33435}
+
+	tests := []struct {
+		name      string
+		data      []byte
+		from      net.IP
+		wantMatch bool
+		wantError string
+		wantMTU   int
+	}{
+		{
+			name:      "time exceeded names the hop",
+			data:      quotedUDPv4(ipv4.ICMPTypeTimeExceeded, 0, 0, targetIP, 53000, 33435),
+			from:      routerIP,
+			wantMatch: true,
+		},
+		{
+			name:      "fragmentation needed carries the next-hop MTU",
+			data:      quotedUDPv4(ipv4.ICMPTypeDestinationUnreachable, 4, 1400, targetIP, 53000, 33435),
+			from:      routerIP,
+			wantMatch: true,
+			wantError: "Fragmentation Needed and Don't Fragment was Set",
+			wantMTU:   1400,
+		},
+		{
+			name:      "port unreachable from the target is the destination",
+			data:      quotedUDPv4(ipv4.ICMPTypeDestinationUnreachable, 3, 0, targetIP, 53000, 33435),
+			from:      targetIP,
+			wantMatch: true,
+		},
+		{
+			name:      "host unreachable from a router is an error",
+			data:      quotedUDPv4(ipv4.ICMPTypeDestinationUnreachable, 1, 0, targetIP, 53000, 33435),
+			from:      routerIP,
+			wantMatch: true,
+			wantError: "Destination Unreachable (code 1)",
+		},
+		{
+			name: "another socket's probe is skipped",
+			data: quotedUDPv4(ipv4.ICMPTypeTimeExceeded, 0, 0, targetIP, 53001, 33435),
+			from: routerIP,
+		},
+		{
+			name: "an earlier TTL's port is skipped",
+			data: quotedUDPv4(ipv4.ICMPTypeTimeExceeded, 0, 0, targetIP, 53000, 33434),
+			from: routerIP,
+		},
+		{
+			name: "echo replies are skipped",
+			data: []byte{byte(ipv4.ICMPTypeEchoReply), 0, 0, 0, 0, 1, 0, 1},
+			from: targetIP,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discoverer := newICMPDiscovererForTest(&fakePacketConn{}, false)
+			hop := &HopInfo{Hop: 2}
+			if got := discoverer.classifyTransportReply(hop, tt.data, tt.from, targetIP, probe); got != tt.wantMatch {
+				t.Fatalf("classifyTransportReply matched = %v, want %v", got, tt.wantMatch)
+			}
+			if !tt.wantMatch {
+				if hop.Addr != nil {
+					t.Fatalf("skipped reply set the hop address: %+v", hop)
+				}
+				return
+			}
+			if !hop.Addr.Equal(tt.from) || hop.Error != tt.wantError || hop.MTU != tt.wantMTU {
+				t.Fatalf("unexpected hop: %+v", hop)
+			}
+		})
+	}
+
+	t.Run("packet too big over IPv6", func(t *testing.T) {
+		target6 := net.ParseIP("2001:db8::10")
+		data := make([]byte, 8+48)
+		data[0] = byte(ipv6.ICMPTypePacketTooBig)
+		binary.BigEndian.PutUint32(data[4:8], 1280)
+		quoted := data[8:]
+		quoted[0] = 0x60
+		quoted[6] = 6
+		copy(quoted[24:40], target6)
+		binary.BigEndian.PutUint16(quoted[42:44], 443)
+
+		discoverer := newICMPDiscovererForTest(&fakePacketConn{}, true)
+		hop := &HopInfo{Hop: 3}
+		tcpProbe := &hopTransportProbe{protocol: 6, dstPort: 443}
+		if !discoverer.classifyTransportReply(hop, data, net.ParseIP("2001:db8::1"), target6, tcpProbe) {
+			t.Fatal("expected Packet Too Big quoting the TCP probe to match")
+		}
+		if hop.Error != "Packet Too Big" || hop.MTU != 1280 {
+			t.Fatalf("unexpected hop: %+v", hop)
+		}
+	})
+}
+
+// newTransportHopDiscovererForTest returns a discoverer for target whose raw
+// ICMP socket is a UDP socket nothing is sent to, so its reads time out
+func newTransportHopDiscovererForTest(t *testing.T, protocol string, port int) *MTUDiscoverer {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to open stand-in ICMP socket: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	discoverer := newICMPDiscovererForTest(conn, false)
+	discoverer.protocol = protocol
+	discoverer.port = port
+	discoverer.timeout = 2 * time.Second
+	discoverer.targetAddr = &net.IPAddr{IP: net.ParseIP("127.0.0.1")}
+	return discoverer
+}
+
+func TestProbeTransportHopReachesDestination(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		defer func() { _ = listener.Close() }()
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				_ = conn.Close()
+			}
+		}()
+
+		discoverer := newTransportHopDiscovererForTest(t, "tcp", listener.Addr().(*net.TCPAddr).Port)
+		hop := discoverer.probeTransportHop(context.Background(), 64, 0)
+		if hop.Timeout || hop.Error != "" || !hop.Addr.Equal(net.ParseIP("127.0.0.1")) {
+			t.Fatalf("unexpected hop: %+v", hop)
+		}
+		if !discoverer.isDestinationReached(hop) {
+			t.Fatal("expected the handshake to count as reaching the destination")
+		}
+	})
+
+	t.Run("udp", func(t *testing.T) {
+		echo, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		defer func() { _ = echo.Close() }()
+		go func() {
+			buf := make([]byte, 2048)
+			n, addr, err := echo.ReadFrom(buf)
+			if err == nil {
+				_, _ = echo.WriteTo(buf[:n], addr)
+			}
+		}()
+
+		discoverer := newTransportHopDiscovererForTest(t, "udp", echo.LocalAddr().(*net.UDPAddr).Port)
+		hop := discoverer.probeTransportHop(context.Background(), 64, 1000)
+		if hop.Timeout || hop.Error != "" || !discoverer.isDestinationReached(hop) {
+			t.Fatalf("unexpected hop: %+v", hop)
+		}
+	})
+
+	t.Run("udp without an answer times out", func(t *testing.T) {
+		silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		defer func() { _ = silent.Close() }()
+
+		discoverer := newTransportHopDiscovererForTest(t, "udp", silent.LocalAddr().(*net.UDPAddr).Port)
+		discoverer.timeout = 100 * time.Millisecond
+		hop := discoverer.probeTransportHop(context.Background(), 64, 1000)
+		if !hop.Timeout || hop.Addr != nil {
+			t.Fatalf("expected a timeout, got %+v", hop)
+		}
+	})
+}
+
+func TestStartUDPHopProbeUsesTraceroutePorts(t *testing.T) {
+	discoverer := newTransportHopDiscovererForTest(t, "udp", 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	probe, err := discoverer.startUDPHopProbe(ctx, 3, 600)
+	if err != nil {
+		t.Fatalf("startUDPHopProbe returned error: %v", err)
+	}
+	probe.stop(cancel)
+
+	if probe.dstPort != tracerouteBasePort+2 {
+		t.Fatalf("probe went to port %d, want %d", probe.dstPort, tracerouteBasePort+2)
+	}
+	if probe.protocol != 17 || probe.srcPort == 0 {
+		t.Fatalf("unexpected probe: %+v", probe)
+	}
+}
@@ -0,0 +1,300 @@
+package mtu
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// Chart geometry for the inline SVGs in the --html-report page
+const (
+	chartWidth   = 720
+	chartHeight  = 160
+	chartPadding = 40
+)
+
+// watchSample is one watch cycle's outcome for one target
+type watchSample struct {
+	Time   time.Time
+	Status string // "ok" or "error" for single-target watches, the health class in fleet mode
+	PMTU   int    // 0 when discovery failed
+	MSS    int    // 0 when discovery failed
+	RTTMS  float64
+	Error  string
+	Code   errcode.Code // Code of Error
+}
+
+// htmlReport accumulates watch results for the session and renders them as a
+// self-contained HTML page. A nil report records nothing.
+type htmlReport struct {
+	path      string
+	every     time.Duration // Rewrite the page this often while watching (0 = only on exit)
+	started   time.Time
+	lastWrite time.Time
+	targets   []string
+	samples   map[string][]watchSample
+}
+
+func newHTMLReport(path string, every time.Duration, targets []string) *htmlReport {
+	now := time.Now()
+	return &htmlReport{
+		path:      path,
+		every:     every,
+		started:   now,
+		lastWrite: now,
+		targets:   targets,
+		samples:   make(map[string][]watchSample, len(targets)),
+	}
+}
+
+// Add records one sample for target
+func (r *htmlReport) Add(target string, sample watchSample) {
+	if r == nil {
+		return
+	}
+	r.samples[target] = append(r.samples[target], sample)
+}
+
+// AddTargets charts targets that joined the watch after it started
+func (r *htmlReport) AddTargets(targets ...string) {
+	if r == nil {
+		return
+	}
+	for _, target := range targets {
+		if !slices.Contains(r.targets, target) {
+			r.targets = append(r.targets, target)
+		}
+	}
+}
+
+// MaybeWrite rewrites the page when the periodic interval has elapsed
+func (r *htmlReport) MaybeWrite(now time.Time) error {
+	if r == nil || r.every == 0 || now.Sub(r.lastWrite) < r.every {
+		return nil
+	}
+	return r.Write(now)
+}
+
+// Write renders the page to a temporary file and renames it into place so a
+// browser never sees a half-written report
+func (r *htmlReport) Write(now time.Time) error {
+	if r == nil {
+		return nil
+	}
+	r.lastWrite = now
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), ".cidrator-report-*.html")
+	if err != nil {
+		return fmt.Errorf("html report: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if err := htmlReportTemplate.Execute(tmp, r.view(now)); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("html report: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("html report: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("html report: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("html report: %w", err)
+	}
+	return nil
+}
+
+// Finish writes the final page and tells the user where it is
+func (r *htmlReport) Finish() error {
+	if r == nil {
+		return nil
+	}
+	if err := r.Write(time.Now()); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote HTML report to %s\n", r.path)
+	return nil
+}
+
+// sleepInterval waits for the next watch cycle and reports false if ctx ended first
+func sleepInterval(ctx context.Context, interval time.Duration) bool {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+type reportView struct {
+	Generated string
+	Started   string
+	Duration  string
+	Targets   []targetView
+}
+
+type targetView struct {
+	Name      string
+	Samples   int
+	Failures  int
+	LastPMTU  int
+	MinPMTU   int
+	MaxPMTU   int
+	AvgRTTMS  float64
+	LastState string
+	PMTUChart template.HTML
+	RTTChart  template.HTML
+	Events    []string
+}
+
+func (r *htmlReport) view(now time.Time) reportView {
+	view := reportView{
+		Generated: now.Format(time.RFC3339),
+		Started:   r.started.Format(time.RFC3339),
+		Duration:  now.Sub(r.started).Round(time.Second).String(),
+	}
+	for _, target := range r.targets {
+		samples := r.samples[target]
+		tv := targetView{Name: target, Samples: len(samples)}
+
+		var rttTotal float64
+		rttCount := 0
+		previousState := ""
+		for _, sample := range samples {
+			if sample.PMTU == 0 {
+				tv.Failures++
+			} else {
+				tv.LastPMTU = sample.PMTU
+				tv.MaxPMTU = max(tv.MaxPMTU, sample.PMTU)
+				if tv.MinPMTU == 0 || sample.PMTU < tv.MinPMTU {
+					tv.MinPMTU = sample.PMTU
+				}
+			}
+			if sample.RTTMS > 0 {
+				rttTotal += sample.RTTMS
+				rttCount++
+			}
+			if previousState != "" && sample.Status != previousState {
+				tv.Events = append(tv.Events, fmt.Sprintf("%s: %s -> %s", sample.Time.Format("15:04:05"), previousState, sample.Status))
+			}
+			previousState = sample.Status
+			tv.LastState = sample.Status
+		}
+		if rttCount > 0 {
+			tv.AvgRTTMS = rttTotal / float64(rttCount)
+		}
+
+		tv.PMTUChart = svgChart(samples, r.started, now, "bytes", func(s watchSample) float64 { return float64(s.PMTU) })
+		tv.RTTChart = svgChart(samples, r.started, now, "ms", func(s watchSample) float64 { return s.RTTMS })
+		view.Targets = append(view.Targets, tv)
+	}
+	return view
+}
+
+// svgChart plots value over time. Samples with no value are drawn as red
+// markers on the time axis so failures stay visible.
+func svgChart(samples []watchSample, start, end time.Time, unit string, value func(watchSample) float64) template.HTML {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg viewBox="0 0 %d %d" width="%d" height="%d" role="img">`, chartWidth, chartHeight, chartWidth, chartHeight)
+	plotWidth := float64(chartWidth - 2*chartPadding)
+	plotHeight := float64(chartHeight - 2*chartPadding)
+	bottom := float64(chartHeight - chartPadding)
+	fmt.Fprintf(&b, `<line class="axis" x1="%d" y1="%.0f" x2="%d" y2="%.0f"/>`, chartPadding, bottom, chartWidth-chartPadding, bottom)
+
+	low, high := 0.0, 0.0
+	for _, sample := range samples {
+		v := value(sample)
+		if v <= 0 {
+			continue
+		}
+		if low == 0 || v < low {
+			low = v
+		}
+		high = max(high, v)
+	}
+	if high == low {
+		// Keep a flat line off the axis
+		low, high = low*0.95, high*1.05+1
+	}
+
+	span := end.Sub(start).Seconds()
+	if span <= 0 {
+		span = 1
+	}
+	x := func(t time.Time) float64 {
+		return float64(chartPadding) + plotWidth*t.Sub(start).Seconds()/span
+	}
+	y := func(v float64) float64 {
+		return bottom - plotHeight*(v-low)/(high-low)
+	}
+
+	var points []string
+	for _, sample := range samples {
+		v := value(sample)
+		if v <= 0 {
+			fmt.Fprintf(&b, `<circle class="failure" cx="%.1f" cy="%.0f" r="3"/>`, x(sample.Time), bottom)
+			continue
+		}
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x(sample.Time), y(v)))
+	}
+	if len(points) > 0 {
+		fmt.Fprintf(&b, `<polyline class="series" points="%s"/>`, strings.Join(points, " "))
+		fmt.Fprintf(&b, `<text x="4" y="%.0f">%.4g %s</text>`, y(high)+4, high, unit)
+		fmt.Fprintf(&b, `<text x="4" y="%.0f">%.4g %s</text>`, y(low)+4, low, unit)
+	} else {
+		fmt.Fprintf(&b, `<text x="%d" y="%d">no data</text>`, chartWidth/2-24, chartHeight/2)
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`, chartPadding, chartHeight-12, start.Format("15:04:05"))
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end">%s</text>`, chartWidth-chartPadding, chartHeight-12, end.Format("15:04:05"))
+	b.WriteString(`</svg>`)
+
+	// Only numbers and fixed markup are written above
+	return template.HTML(b.String())
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>cidrator mtu watch report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+svg { background: #fafafa; border: 1px solid #ddd; display: block; margin: 0.5em 0 1em; }
+svg text { font-size: 11px; fill: #555; }
+.axis { stroke: #999; }
+.series { fill: none; stroke: #1f6feb; stroke-width: 2; }
+.failure { fill: #d73a49; }
+</style>
+</head>
+<body>
+<h1>MTU watch report</h1>
+<p>Session started {{.Started}}, report generated {{.Generated}} ({{.Duration}}).</p>
+<table>
+<tr><th>Target</th><th>State</th><th>Samples</th><th>Failures</th><th>Last PMTU</th><th>Min PMTU</th><th>Max PMTU</th><th>Avg RTT (ms)</th></tr>
+{{range .Targets}}<tr><td>{{.Name}}</td><td>{{.LastState}}</td><td>{{.Samples}}</td><td>{{.Failures}}</td><td>{{.LastPMTU}}</td><td>{{.MinPMTU}}</td><td>{{.MaxPMTU}}</td><td>{{printf "%.2f" .AvgRTTMS}}</td></tr>
+{{end}}</table>
+{{range .Targets}}
+<h2>{{.Name}}</h2>
+<h3>Path MTU</h3>
+{{.PMTUChart}}
+<h3>RTT</h3>
+{{.RTTChart}}
+{{if .Events}}<h3>State changes</h3>
+<ul>{{range .Events}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{end}}
+</body>
+</html>
+`))
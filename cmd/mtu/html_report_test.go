@@ -0,0 +1,114 @@
+package mtu
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+func TestHTMLReportWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.html")
+	report := newHTMLReport(path, 0, []string{"192.0.2.1", "<script>alert(1)</script>"})
+	start := report.started
+
+	report.Add("192.0.2.1", watchSample{Time: start.Add(10 * time.Second), Status: "ok", PMTU: 1500, RTTMS: 12})
+	report.Add("192.0.2.1", watchSample{Time: start.Add(20 * time.Second), Status: "ok", PMTU: 1400, RTTMS: 14})
+	report.Add("192.0.2.1", watchSample{Time: start.Add(30 * time.Second), Status: "error", Error: "timeout"})
+	report.Add("<script>alert(1)</script>", watchSample{Time: start.Add(10 * time.Second), Status: healthDown, Error: "timeout"})
+
+	if err := report.Write(start.Add(40 * time.Second)); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(data)
+
+	for _, want := range []string{
+		"<td>192.0.2.1</td><td>error</td><td>3</td><td>1</td><td>1400</td><td>1400</td><td>1500</td><td>13.00</td>",
+		`<polyline class="series"`,
+		`<circle class="failure"`,
+		": ok -&gt; error",
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		"no data",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+	if strings.Contains(page, "<script>alert") {
+		t.Error("target names must be escaped")
+	}
+	if strings.Contains(page, "http://") || strings.Contains(page, "https://") {
+		t.Error("report should not reference external resources")
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".cidrator-report-*"))
+	if len(leftovers) != 0 {
+		t.Fatalf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestHTMLReportMaybeWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.html")
+	report := newHTMLReport(path, time.Minute, []string{"192.0.2.1"})
+
+	if err := report.MaybeWrite(report.started.Add(30 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("report written before --html-every elapsed, stat err = %v", err)
+	}
+
+	if err := report.MaybeWrite(report.started.Add(61 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("report not written after --html-every elapsed: %v", err)
+	}
+
+	var none *htmlReport
+	none.Add("192.0.2.1", watchSample{})
+	if err := none.MaybeWrite(time.Now()); err != nil {
+		t.Fatalf("nil report should be a no-op, got %v", err)
+	}
+}
+
+func TestReadHTMLReport(t *testing.T) {
+	newCmd := func(t *testing.T, values map[string]string) (*htmlReport, error) {
+		cmd := newDiscoveryOptionsCommand()
+		cmd.Flags().String("html-report", "", "")
+		cmd.Flags().Duration("html-every", 0, "")
+		for name, value := range values {
+			mustSetFlag(t, cmd, name, value)
+		}
+		return readHTMLReport(cmd, []string{"192.0.2.1"})
+	}
+
+	if report, err := newCmd(t, nil); report != nil || err != nil {
+		t.Fatalf("expected no report without --html-report, got %v, %v", report, err)
+	}
+	if report, err := newCmd(t, map[string]string{"html-report": "out.html", "html-every": "5m"}); err != nil || report.every != 5*time.Minute {
+		t.Fatalf("unexpected report: %+v, %v", report, err)
+	}
+	if _, err := newCmd(t, map[string]string{"html-every": "5m"}); errcode.Of(err) != errcode.CLIUsage {
+		t.Fatalf("expected --html-every without --html-report to be rejected, got %v", err)
+	}
+}
+
+func TestSleepIntervalStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if sleepInterval(ctx, time.Hour) {
+		t.Fatal("expected sleepInterval to stop when the watch is interrupted")
+	}
+	if !sleepInterval(context.Background(), time.Millisecond) {
+		t.Fatal("expected sleepInterval to complete")
+	}
+}
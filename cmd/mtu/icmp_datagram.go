@@ -0,0 +1,53 @@
+package mtu
+
+import (
+	"net"
+)
+
+// listenICMPDatagram opens the unprivileged ICMP socket used when a raw one
+// cannot be opened
+var listenICMPDatagram = openICMPDatagram
+
+// icmpDatagramConn carries echo requests over a datagram ICMP socket, the
+// kind icmp.ListenPacket("udp4") opens. It takes and returns IP addresses so
+// the discoverer addresses its target the same way as over a raw socket.
+//
+// The kernel answers only echo on these sockets: Fragmentation Needed and
+// Time Exceeded never arrive, so too-big sizes go unanswered and hop-by-hop
+// discovery cannot run. With DF set, Linux still lowers its cached path MTU
+// on Fragmentation Needed, and later sends above it fail with EMSGSIZE.
+type icmpDatagramConn struct {
+	*net.UDPConn
+}
+
+func (c icmpDatagramConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if ip, ok := addr.(*net.IPAddr); ok {
+		addr = &net.UDPAddr{IP: ip.IP, Zone: ip.Zone}
+	}
+	return c.UDPConn.WriteTo(b, addr)
+}
+
+func (c icmpDatagramConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.UDPConn.ReadFrom(b)
+	if udp, ok := addr.(*net.UDPAddr); ok {
+		addr = &net.IPAddr{IP: udp.IP, Zone: udp.Zone}
+	}
+	return n, addr, err
+}
+
+// canProbeICMP reports whether echo probes can be sent, over a raw socket or
+// else an unprivileged datagram one. Opening a socket sends no packets.
+func canProbeICMP(ipv6 bool) bool {
+	if canListenICMP(ipv6) {
+		return true
+	}
+	if !datagramICMPSupported {
+		return false
+	}
+	conn, err := listenICMPDatagram(ipv6)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
@@ -0,0 +1,19 @@
+//go:build !linux && !darwin
+
+package mtu
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+// datagramICMPSupported is false: only Linux and macOS have unprivileged ICMP
+// sockets
+const datagramICMPSupported = false
+
+// openICMPDatagram fails outside Linux and macOS, which are the only systems
+// with unprivileged ICMP sockets
+func openICMPDatagram(ipv6 bool) (*net.UDPConn, error) {
+	return nil, fmt.Errorf("unprivileged ICMP sockets are not supported on %s", runtime.GOOS)
+}
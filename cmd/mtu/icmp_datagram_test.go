@@ -0,0 +1,45 @@
+package mtu
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestICMPDatagramConnTranslatesAddresses(t *testing.T) {
+	listen := func() *net.UDPConn {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatalf("failed to open UDP socket: %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn
+	}
+	sender, receiver := icmpDatagramConn{UDPConn: listen()}, icmpDatagramConn{UDPConn: listen()}
+
+	// Replies arrive from a UDP address and are reported as an IP one
+	target := &net.IPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	if _, err := sender.UDPConn.WriteTo([]byte("echo"), receiver.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	n, addr, err := receiver.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom returned error: %v", err)
+	}
+	if ip, ok := addr.(*net.IPAddr); !ok || !ip.IP.Equal(target.IP) || string(buf[:n]) != "echo" {
+		t.Fatalf("ReadFrom returned %q from %v (%T), want echo from %v", buf[:n], addr, addr, target)
+	}
+
+	// An IP address goes out as a UDP one. UDP may refuse port 0, which the
+	// ICMP socket ignores, but the error must name the translated address.
+	if _, err := sender.WriteTo([]byte("echo"), target); err != nil {
+		var opErr *net.OpError
+		if !errors.As(err, &opErr) {
+			t.Fatalf("WriteTo returned %v", err)
+		}
+		if _, ok := opErr.Addr.(*net.UDPAddr); !ok {
+			t.Fatalf("WriteTo sent to %T, want *net.UDPAddr", opErr.Addr)
+		}
+	}
+}
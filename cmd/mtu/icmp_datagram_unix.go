@@ -0,0 +1,62 @@
+//go:build linux || darwin
+
+package mtu
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// datagramICMPSupported reports whether this platform has unprivileged ICMP
+// sockets. They need no raw socket support, so minimal builds use them too.
+const datagramICMPSupported = true
+
+// ipStripHdr is Darwin's IP_STRIPHDR, which drops the IPv4 header from what a
+// datagram ICMP socket reads
+const ipStripHdr = 0x17
+
+// openICMPDatagram opens the socket icmp.ListenPacket("udp4") or ("udp6")
+// does, but keeps it a *net.UDPConn so DF can be set on it. Linux allows it
+// for groups in net.ipv4.ping_group_range; macOS allows it for every user.
+func openICMPDatagram(ipv6 bool) (*net.UDPConn, error) {
+	family, proto := unix.AF_INET, unix.IPPROTO_ICMP
+	var addr unix.Sockaddr = &unix.SockaddrInet4{}
+	if ipv6 {
+		family, proto = unix.AF_INET6, unix.IPPROTO_ICMPV6
+		addr = &unix.SockaddrInet6{}
+	}
+
+	fd, err := unix.Socket(family, unix.SOCK_DGRAM, proto)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	unix.CloseOnExec(fd)
+	if runtime.GOOS == "darwin" && !ipv6 {
+		if err := unix.SetsockoptInt(fd, unix.IPPROTO_IP, ipStripHdr, 1); err != nil {
+			_ = unix.Close(fd)
+			return nil, os.NewSyscallError("setsockopt", err)
+		}
+	}
+	if err := unix.Bind(fd, addr); err != nil {
+		_ = unix.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+
+	// FilePacketConn duplicates the descriptor, so the file is closed either way
+	file := os.NewFile(uintptr(fd), "datagram icmp")
+	defer func() { _ = file.Close() }()
+	conn, err := net.FilePacketConn(file)
+	if err != nil {
+		return nil, err
+	}
+	udp, ok := conn.(*net.UDPConn)
+	if !ok {
+		_ = conn.Close()
+		return nil, fmt.Errorf("unexpected datagram ICMP connection type: %T", conn)
+	}
+	return udp, nil
+}
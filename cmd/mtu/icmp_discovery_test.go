@@ -34,6 +34,7 @@ type fakePacketConn struct {
 	writeErr           error
 	readErr            error
 	setReadDeadlineErr error
+	lastReadDeadline   time.Time
 	lastProbeSize      int
 	writes             int
 	responseForProbe   func(size int) fakePacketResponse
@@ -88,6 +89,9 @@ func (f *fakePacketConn) SetDeadline(t time.Time) error      { return nil }
 func (f *fakePacketConn) SetWriteDeadline(t time.Time) error { return nil }
 
 func (f *fakePacketConn) SetReadDeadline(t time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastReadDeadline = t
 	return f.setReadDeadlineErr
 }
 
@@ -161,8 +165,9 @@ func TestProbeAndDiscoverICMP(t *testing.T) {
 			},
 		}
 		discoverer := newICMPDiscovererForTest(conn, false)
-		discoverer.icmpListener = &ICMPListener{errors: make(chan *FragmentationError, 1)}
-		discoverer.icmpListener.errors <- &FragmentationError{NextHopMTU: 1360}
+		listener := &ICMPListener{errors: make(chan *FragmentationError, 1)}
+		listener.errors <- &FragmentationError{NextHopMTU: 1360}
+		discoverer.icmpListener = listener
 
 		result := discoverer.probe(context.Background(), 1400)
 		if result.Success || result.ICMPErr == nil {
@@ -4,12 +4,11 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
-	"io"
 	"net"
-	"os"
 	"sync"
 	"time"
 
+	"github.com/euan-cowie/cidrator/internal/errcode"
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
 	"golang.org/x/net/ipv6"
@@ -25,9 +24,17 @@ type FragmentationError struct {
 	// OriginalDst is the destination from the original packet that triggered the error
 	OriginalDst net.IP
 
+	// OriginalProtocol is the embedded packet's IP protocol, or IPv6 next
+	// header: 6 for TCP, 17 for UDP
+	OriginalProtocol int
+
 	// OriginalSrcPort and OriginalDstPort from the embedded packet header
 	OriginalSrcPort int
 	OriginalDstPort int
+
+	// OriginalSize is the length of the packet that was too big, from the
+	// embedded IP header (0 if not available)
+	OriginalSize int
 }
 
 type icmpReadConn interface {
@@ -40,13 +47,13 @@ var openICMPListenPacket = func(network, address string) (icmpReadConn, error) {
 	return icmp.ListenPacket(network, address)
 }
 
-var icmpListenerWarningOutput io.Writer = os.Stderr
-
 // ICMPListener listens for ICMP "Fragmentation Needed and DF Set" errors
 // as specified in RFC 1191 Section 4
 type ICMPListener struct {
 	conn4   icmpReadConn
 	conn6   icmpReadConn
+	err4    error // Why conn4 did not open
+	err6    error // Why conn6 did not open
 	errors  chan *FragmentationError
 	done    chan struct{}
 	mu      sync.Mutex
@@ -61,31 +68,38 @@ func NewICMPListener() (*ICMPListener, error) {
 		done:   make(chan struct{}),
 	}
 
-	// Try to open IPv4 ICMP socket
+	// Try to open IPv4 ICMP socket; may fail without privileges, continue anyway
 	conn4, err := openICMPListenPacket("ip4:icmp", "0.0.0.0")
 	if err != nil {
-		// May fail without privileges, continue anyway
-		_, _ = fmt.Fprintf(icmpListenerWarningOutput, "Warning: Could not open IPv4 ICMP socket: %v\n", err)
+		listener.err4 = fmt.Errorf("could not open IPv4 ICMP socket: %w", err)
 	} else {
 		listener.conn4 = conn4
 	}
 
-	// Try to open IPv6 ICMP socket
+	// Try to open IPv6 ICMP socket; may fail without privileges or IPv6 support
 	conn6, err := openICMPListenPacket("ip6:ipv6-icmp", "::")
 	if err != nil {
-		// May fail without privileges or IPv6 support
-		_, _ = fmt.Fprintf(icmpListenerWarningOutput, "Warning: Could not open IPv6 ICMP socket: %v\n", err)
+		listener.err6 = fmt.Errorf("could not open IPv6 ICMP socket: %w", err)
 	} else {
 		listener.conn6 = conn6
 	}
 
 	if listener.conn4 == nil && listener.conn6 == nil {
-		return nil, fmt.Errorf("failed to open any ICMP socket (requires root)")
+		return nil, errcode.Errorf(errcode.MTUPermissionDenied, "failed to open any ICMP socket (requires root): %v", listener.err4)
 	}
 
 	return listener, nil
 }
 
+// unavailable returns why the listener has no socket for an IP version, or
+// nil if it has one
+func (l *ICMPListener) unavailable(ipv6 bool) error {
+	if ipv6 {
+		return l.err6
+	}
+	return l.err4
+}
+
 // Start begins listening for ICMP errors in the background
 func (l *ICMPListener) Start(ctx context.Context) {
 	l.mu.Lock()
@@ -157,7 +171,7 @@ func (l *ICMPListener) listenIPv4(ctx context.Context) {
 			continue
 		}
 
-		n, peer, err := l.conn4.ReadFrom(buf)
+		n, _, err := l.conn4.ReadFrom(buf)
 		if err != nil {
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 				continue
@@ -195,8 +209,11 @@ func (l *ICMPListener) listenIPv4(ctx context.Context) {
 		// Extract Next-Hop MTU from the ICMP message
 		// Per RFC 1191: bytes 6-7 of the ICMP header contain Next-Hop MTU
 		// In the parsed message, this is available in the Data field prefix
-		icmpErr := l.parseICMPv4Error(dstUnreach.Data, peer)
+		icmpErr := parseICMPv4Error(dstUnreach.Data)
 		if icmpErr != nil {
+			// The ICMP library drops the header's second word, which
+			// carries the Next-Hop MTU in its low 16 bits
+			icmpErr.NextHopMTU = int(binary.BigEndian.Uint16(buf[6:8]))
 			select {
 			case l.errors <- icmpErr:
 			default:
@@ -253,14 +270,8 @@ func (l *ICMPListener) listenIPv6(ctx context.Context) {
 			continue
 		}
 
-		icmpErr := &FragmentationError{
-			NextHopMTU: pktTooBig.MTU,
-		}
-
-		// Try to extract destination from embedded packet
-		if len(pktTooBig.Data) >= 40 {
-			icmpErr.OriginalDst = net.IP(pktTooBig.Data[24:40])
-		}
+		icmpErr := parseICMPv6Error(pktTooBig.Data)
+		icmpErr.NextHopMTU = pktTooBig.MTU
 
 		select {
 		case l.errors <- icmpErr:
@@ -273,7 +284,7 @@ func (l *ICMPListener) listenIPv6(ctx context.Context) {
 
 // parseICMPv4Error extracts error information from ICMP message data
 // The data contains the original IP header + first 8 bytes of payload
-func (l *ICMPListener) parseICMPv4Error(data []byte, peer net.Addr) *FragmentationError {
+func parseICMPv4Error(data []byte) *FragmentationError {
 	// Need at least IP header (20 bytes) + 8 bytes of original data
 	if len(data) < 28 {
 		return nil
@@ -288,11 +299,13 @@ func (l *ICMPListener) parseICMPv4Error(data []byte, peer net.Addr) *Fragmentati
 		NextHopMTU: 0, // Will be set by caller from raw message
 	}
 
-	// IP header: destination is at bytes 16-19
+	// IP header: total length is at bytes 2-3, destination at bytes 16-19
+	icmpErr.OriginalSize = int(binary.BigEndian.Uint16(data[2:4]))
 	icmpErr.OriginalDst = net.IP(data[16:20])
 
 	// Protocol is at byte 9
 	protocol := data[9]
+	icmpErr.OriginalProtocol = int(protocol)
 	ihl := int(data[0]&0x0f) * 4 // IP Header Length
 
 	// Extract ports from transport header (UDP/TCP)
@@ -303,7 +316,28 @@ func (l *ICMPListener) parseICMPv4Error(data []byte, peer net.Addr) *Fragmentati
 		}
 	}
 
-	_ = peer // Suppress unused warning
+	return icmpErr
+}
+
+// parseICMPv6Error extracts what it can of the original packet from a
+// Packet Too Big message: the IPv6 header and the start of its payload
+func parseICMPv6Error(data []byte) *FragmentationError {
+	icmpErr := &FragmentationError{}
+	if len(data) < 40 {
+		return icmpErr
+	}
+
+	// IPv6 header: payload length at bytes 4-5, next header at byte 6,
+	// destination at bytes 24-39
+	icmpErr.OriginalSize = 40 + int(binary.BigEndian.Uint16(data[4:6]))
+	icmpErr.OriginalDst = net.IP(data[24:40])
+	icmpErr.OriginalProtocol = int(data[6])
+
+	// Ports of a TCP or UDP packet without extension headers
+	if nextHeader := data[6]; (nextHeader == 6 || nextHeader == 17) && len(data) >= 44 {
+		icmpErr.OriginalSrcPort = int(binary.BigEndian.Uint16(data[40:42]))
+		icmpErr.OriginalDstPort = int(binary.BigEndian.Uint16(data[42:44]))
+	}
 	return icmpErr
 }
 
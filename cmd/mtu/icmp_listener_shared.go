@@ -0,0 +1,145 @@
+package mtu
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// FragmentationSource delivers ICMP fragmentation errors to a discoverer.
+// Both a dedicated ICMPListener and a shared ICMPSubscription satisfy it.
+type FragmentationSource interface {
+	Errors() <-chan *FragmentationError
+}
+
+// icmpHub owns the process-wide ICMP listener. The listener is opened when the
+// first discovery subscribes and closed when the last one leaves, so concurrent
+// discoveries share one pair of raw sockets instead of each opening their own.
+type icmpHub struct {
+	newListener func() (*ICMPListener, error)
+
+	mu          sync.Mutex
+	listener    *ICMPListener
+	cancel      context.CancelFunc
+	dispatched  chan struct{} // Closed when the current listener's dispatcher exits
+	subscribers map[*ICMPSubscription]struct{}
+}
+
+var sharedICMPHub = &icmpHub{newListener: NewICMPListener}
+
+// ICMPSubscription receives the fragmentation errors for one destination from
+// the shared listener. Close it when the discovery finishes.
+type ICMPSubscription struct {
+	hub    *icmpHub
+	dst    net.IP
+	errors chan *FragmentationError
+	once   sync.Once
+}
+
+// SubscribeICMPErrors attaches to the shared ICMP listener, starting it if no
+// other discovery is using it, and returns a subscription that only sees
+// errors for packets sent to dst
+func SubscribeICMPErrors(dst net.IP) (*ICMPSubscription, error) {
+	return sharedICMPHub.attach(dst)
+}
+
+// attach subscribes to errors for dst, failing if the listener opened only
+// the other IP version's socket
+func (h *icmpHub) attach(dst net.IP) (*ICMPSubscription, error) {
+	sub, err := h.subscribe(dst)
+	if err != nil {
+		return nil, err
+	}
+	if err := sub.unavailable(); err != nil {
+		_ = sub.Close()
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (h *icmpHub) subscribe(dst net.IP) (*ICMPSubscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.listener == nil {
+		listener, err := h.newListener()
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		h.listener, h.cancel, h.dispatched = listener, cancel, make(chan struct{})
+		h.subscribers = make(map[*ICMPSubscription]struct{})
+		listener.Start(ctx)
+		go h.dispatch(ctx, listener, h.dispatched)
+	}
+
+	sub := &ICMPSubscription{hub: h, dst: dst, errors: make(chan *FragmentationError, 16)}
+	h.subscribers[sub] = struct{}{}
+	return sub, nil
+}
+
+// dispatch routes each error to the subscriptions for its original destination.
+// Errors that cannot be attributed to a destination are dropped, since handing
+// them to an arbitrary discovery would corrupt its search.
+func (h *icmpHub) dispatch(ctx context.Context, listener *ICMPListener, done chan<- struct{}) {
+	defer close(done)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case fragErr := <-listener.Errors():
+			if fragErr.OriginalDst == nil {
+				continue
+			}
+			h.mu.Lock()
+			for sub := range h.subscribers {
+				if !sub.dst.Equal(fragErr.OriginalDst) {
+					continue
+				}
+				select {
+				case sub.errors <- fragErr:
+				default:
+					// Subscriber is not keeping up; drop rather than stall the others
+				}
+			}
+			h.mu.Unlock()
+		}
+	}
+}
+
+// release removes sub and closes the listener once nobody is subscribed
+func (h *icmpHub) release(sub *ICMPSubscription) error {
+	h.mu.Lock()
+	delete(h.subscribers, sub)
+	if len(h.subscribers) > 0 || h.listener == nil {
+		h.mu.Unlock()
+		return nil
+	}
+	listener, cancel, dispatched := h.listener, h.cancel, h.dispatched
+	h.listener, h.cancel, h.dispatched = nil, nil, nil
+	h.mu.Unlock()
+
+	cancel()
+	<-dispatched
+	return listener.Close()
+}
+
+// unavailable returns why the listener cannot see errors for this
+// subscription's IP version, or nil if it can
+func (s *ICMPSubscription) unavailable() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.hub.listener.unavailable(s.dst.To4() == nil)
+}
+
+// Errors returns the fragmentation errors for this subscription's destination
+func (s *ICMPSubscription) Errors() <-chan *FragmentationError {
+	return s.errors
+}
+
+// Close detaches from the shared listener. It is safe to call more than once.
+func (s *ICMPSubscription) Close() error {
+	var err error
+	s.once.Do(func() { err = s.hub.release(s) })
+	return err
+}
@@ -0,0 +1,136 @@
+package mtu
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// newTestICMPHub returns a hub whose listeners read from fake sockets and
+// counts how many listeners it opened
+func newTestICMPHub(t *testing.T) (*icmpHub, *int, *[]*fakeICMPReadConn) {
+	t.Helper()
+	opened := 0
+	var conns []*fakeICMPReadConn
+	hub := &icmpHub{newListener: func() (*ICMPListener, error) {
+		opened++
+		conn := &fakeICMPReadConn{}
+		conns = append(conns, conn)
+		return &ICMPListener{conn4: conn, errors: make(chan *FragmentationError, 16), done: make(chan struct{})}, nil
+	}}
+	return hub, &opened, &conns
+}
+
+func receiveFragErr(t *testing.T, sub *ICMPSubscription) *FragmentationError {
+	t.Helper()
+	select {
+	case fragErr := <-sub.Errors():
+		return fragErr
+	case <-time.After(time.Second):
+		t.Fatal("expected a fragmentation error")
+		return nil
+	}
+}
+
+func TestSharedICMPListenerRoutesByDestination(t *testing.T) {
+	hub, opened, _ := newTestICMPHub(t)
+	first, err := hub.subscribe(net.ParseIP("192.0.2.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := hub.subscribe(net.ParseIP("2001:db8::1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *opened != 1 {
+		t.Fatalf("expected concurrent subscriptions to share one listener, opened %d", *opened)
+	}
+
+	hub.listener.errors <- &FragmentationError{NextHopMTU: 1200}
+	hub.listener.errors <- &FragmentationError{OriginalDst: net.ParseIP("2001:db8::1"), NextHopMTU: 1280}
+	hub.listener.errors <- &FragmentationError{OriginalDst: net.ParseIP("192.0.2.1").To4(), NextHopMTU: 1400}
+
+	if got := receiveFragErr(t, first); got.NextHopMTU != 1400 {
+		t.Fatalf("first subscription got %+v, want the 192.0.2.1 error", got)
+	}
+	if got := receiveFragErr(t, second); got.NextHopMTU != 1280 {
+		t.Fatalf("second subscription got %+v, want the 2001:db8::1 error", got)
+	}
+	select {
+	case extra := <-first.Errors():
+		t.Fatalf("unattributed or foreign error delivered: %+v", extra)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	_ = first.Close()
+	_ = second.Close()
+}
+
+func TestSharedICMPListenerReferenceCounting(t *testing.T) {
+	hub, opened, conns := newTestICMPHub(t)
+
+	first, _ := hub.subscribe(net.ParseIP("192.0.2.1"))
+	second, _ := hub.subscribe(net.ParseIP("192.0.2.2"))
+
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatalf("second Close should be a no-op, got %v", err)
+	}
+	if hub.listener == nil || (*conns)[0].closeCalls != 0 {
+		t.Fatal("listener closed while a subscription is still active")
+	}
+
+	if err := second.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if hub.listener != nil || (*conns)[0].closeCalls != 1 {
+		t.Fatalf("expected the last Close to close the listener, close calls = %d", (*conns)[0].closeCalls)
+	}
+
+	third, err := hub.subscribe(net.ParseIP("192.0.2.3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = third.Close() }()
+	if *opened != 2 {
+		t.Fatalf("expected a fresh listener after the previous one closed, opened %d", *opened)
+	}
+}
+
+func TestSharedICMPListenerOpenFailure(t *testing.T) {
+	hub := &icmpHub{newListener: func() (*ICMPListener, error) { return nil, errors.New("requires root") }}
+	if _, err := hub.subscribe(net.ParseIP("192.0.2.1")); err == nil {
+		t.Fatal("expected subscribe to report the listener failure")
+	}
+	if hub.listener != nil {
+		t.Fatal("failed open should leave no listener behind")
+	}
+}
+
+func TestSharedICMPListenerMissingFamily(t *testing.T) {
+	hub := &icmpHub{newListener: func() (*ICMPListener, error) {
+		return &ICMPListener{
+			conn4:  &fakeICMPReadConn{},
+			err6:   errors.New("could not open IPv6 ICMP socket: address family not supported"),
+			errors: make(chan *FragmentationError, 16),
+			done:   make(chan struct{}),
+		}, nil
+	}}
+
+	if _, err := hub.attach(net.ParseIP("2001:db8::1")); err == nil {
+		t.Fatal("expected attach to fail without an IPv6 socket")
+	}
+	if hub.listener != nil {
+		t.Fatal("a listener nobody can use should be closed")
+	}
+	sub, err := hub.attach(net.ParseIP("192.0.2.1"))
+	if err != nil {
+		t.Fatalf("attach over IPv4 returned error: %v", err)
+	}
+	if err := sub.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,7 +1,6 @@
 package mtu
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
 	"errors"
@@ -124,43 +123,59 @@ func TestICMPListenerHelpers(t *testing.T) {
 }
 
 func TestParseICMPv4Error(t *testing.T) {
-	listener := &ICMPListener{}
-
-	if err := listener.parseICMPv4Error([]byte{0x45}, nil); err != nil {
+	if err := parseICMPv4Error([]byte{0x45}); err != nil {
 		t.Fatalf("expected short ICMP payload to be ignored, got %+v", err)
 	}
 
 	data := make([]byte, 28)
 	data[0] = 0x45
+	binary.BigEndian.PutUint16(data[2:4], 1500)
 	data[9] = 17
 	copy(data[16:20], net.ParseIP("198.51.100.7").To4())
 	binary.BigEndian.PutUint16(data[20:22], 53000)
 	binary.BigEndian.PutUint16(data[22:24], 4821)
 
-	err := listener.parseICMPv4Error(data, nil)
+	err := parseICMPv4Error(data)
 	if err == nil {
 		t.Fatal("expected parsed ICMPv4 fragmentation error")
 	}
 	if !err.OriginalDst.Equal(net.ParseIP("198.51.100.7")) {
 		t.Fatalf("unexpected original destination: %v", err.OriginalDst)
 	}
-	if err.OriginalSrcPort != 53000 || err.OriginalDstPort != 4821 {
-		t.Fatalf("unexpected original ports: %+v", err)
+	if err.OriginalProtocol != 17 || err.OriginalSrcPort != 53000 || err.OriginalDstPort != 4821 || err.OriginalSize != 1500 {
+		t.Fatalf("unexpected original protocol, ports, or size: %+v", err)
+	}
+}
+
+func TestParseICMPv6Error(t *testing.T) {
+	if err := parseICMPv6Error([]byte{0x60}); err.OriginalDst != nil {
+		t.Fatalf("expected short ICMPv6 payload to yield no destination, got %+v", err)
+	}
+
+	data := make([]byte, 48)
+	data[0] = 0x60
+	binary.BigEndian.PutUint16(data[4:6], 1412)
+	data[6] = 17
+	copy(data[24:40], net.ParseIP("2001:db8::7"))
+	binary.BigEndian.PutUint16(data[40:42], 53000)
+	binary.BigEndian.PutUint16(data[42:44], 4821)
+
+	err := parseICMPv6Error(data)
+	if !err.OriginalDst.Equal(net.ParseIP("2001:db8::7")) || err.OriginalSize != 1452 {
+		t.Fatalf("unexpected original packet: %+v", err)
+	}
+	if err.OriginalProtocol != 17 || err.OriginalSrcPort != 53000 || err.OriginalDstPort != 4821 {
+		t.Fatalf("unexpected original protocol or ports: %+v", err)
 	}
 }
 
 func TestNewICMPListener(t *testing.T) {
 	originalOpen := openICMPListenPacket
-	originalWarnings := icmpListenerWarningOutput
 	t.Cleanup(func() {
 		openICMPListenPacket = originalOpen
-		icmpListenerWarningOutput = originalWarnings
 	})
 
-	t.Run("uses available sockets and warns for unavailable families", func(t *testing.T) {
-		var warnings bytes.Buffer
-		icmpListenerWarningOutput = &warnings
-
+	t.Run("uses available sockets and records unavailable families", func(t *testing.T) {
 		ipv6Conn := &fakeICMPReadConn{}
 		openICMPListenPacket = func(network, address string) (icmpReadConn, error) {
 			if network == "ip4:icmp" {
@@ -183,8 +198,11 @@ func TestNewICMPListener(t *testing.T) {
 		if listener.conn6 != ipv6Conn {
 			t.Fatal("expected IPv6 socket to be attached")
 		}
-		if !strings.Contains(warnings.String(), "Could not open IPv4 ICMP socket") {
-			t.Fatalf("expected IPv4 warning, got %q", warnings.String())
+		if err := listener.unavailable(false); err == nil || !strings.Contains(err.Error(), "could not open IPv4 ICMP socket") {
+			t.Fatalf("expected the IPv4 socket failure to be recorded, got %v", err)
+		}
+		if err := listener.unavailable(true); err != nil {
+			t.Fatalf("expected IPv6 to be available, got %v", err)
 		}
 	})
 
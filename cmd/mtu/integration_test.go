@@ -8,6 +8,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/euan-cowie/cidrator/internal/family"
 )
 
 // TestMain sets up and tears down for integration tests
@@ -17,6 +19,9 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
+	// Unit tests expect IPv4 unless they ask for IPv6, whatever the host has
+	_ = family.Configure(family.IPv4)
+
 	// Run tests
 	code := m.Run()
 
@@ -84,10 +89,10 @@ func TestMTUIntegration(t *testing.T) {
 	})
 
 	t.Run("MTU interfaces JSON", func(t *testing.T) {
-		cmd := exec.Command(binary, "mtu", "interfaces", "--json")
+		cmd := exec.Command(binary, "mtu", "interfaces", "--format", "json")
 		output, err := cmd.CombinedOutput()
 		if err != nil {
-			t.Errorf("mtu interfaces --json failed: %v", err)
+			t.Errorf("mtu interfaces --format json failed: %v", err)
 		}
 
 		outputStr := string(output)
@@ -117,10 +122,10 @@ func TestMTUIntegration(t *testing.T) {
 	})
 
 	t.Run("MTU suggest JSON", func(t *testing.T) {
-		cmd := exec.Command(binary, "mtu", "suggest", "localhost", "--proto", "tcp", "--json")
+		cmd := exec.Command(binary, "mtu", "suggest", "localhost", "--proto", "tcp", "--format", "json")
 		output, err := cmd.CombinedOutput()
 		if err != nil {
-			t.Errorf("mtu suggest localhost --json failed: %v", err)
+			t.Errorf("mtu suggest localhost --format json failed: %v", err)
 		}
 
 		outputStr := string(output)
@@ -191,7 +196,7 @@ func TestMTUIntegration(t *testing.T) {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
-		cmd := exec.CommandContext(ctx, binary, "mtu", "discover", "localhost", "--proto", "tcp", "--max", "1500", "--json")
+		cmd := exec.CommandContext(ctx, binary, "mtu", "discover", "localhost", "--proto", "tcp", "--max", "1500", "--format", "json")
 		output, err := cmd.CombinedOutput()
 
 		if err != nil {
@@ -8,20 +8,62 @@ import (
 
 // NetworkInterface represents a network interface with MTU information
 type NetworkInterface struct {
-	Name string `json:"name"`
-	MTU  int    `json:"mtu"`
-	Type string `json:"type"`
+	Name           string   `json:"name"`
+	MTU            int      `json:"mtu"`
+	Type           string   `json:"type"`
+	Driver         string   `json:"driver,omitempty"`
+	Virtual        bool     `json:"virtual,omitempty"`        // Not backed by a physical device
+	Virtualization string   `json:"virtualization,omitempty"` // Hypervisor or container platform suggested by the driver
+	Master         string   `json:"master,omitempty"`         // Bond, bridge, or team this interface belongs to
+	MasterType     string   `json:"master_type,omitempty"`
+	Members        []string `json:"members,omitempty"` // Ports of a bond, bridge, or team
+	Parent         string   `json:"parent,omitempty"`  // Lower interface of a VLAN, macvlan, or ipvlan
+	MAC            string   `json:"mac,omitempty"`
+	Addresses      []string `json:"addresses,omitempty"` // Assigned addresses in CIDR notation
+	Flags          []string `json:"flags,omitempty"`     // As net.Flags names them, such as up, broadcast, multicast
+	AdminState     string   `json:"admin_state"`         // up or down, as configured
+	OperState      string   `json:"oper_state"`          // RFC 2863 state where the platform reports it (up, down, dormant, lowerlayerdown, unknown), else up or down
+}
+
+// interfaceDetails is what a platform shim knows about an interface beyond
+// what net.Interface reports. Empty fields mean the platform cannot tell.
+type interfaceDetails struct {
+	Type           string
+	Driver         string
+	Virtual        bool
+	Virtualization string
+	Master         string
+	MasterType     string
+	Members        []string
+	Parent         string
+	OperState      string
 }
 
 // InterfaceResult represents the result of interface detection
 type InterfaceResult struct {
 	Interfaces []NetworkInterface `json:"interfaces"`
+	Issues     []InterfaceIssue   `json:"issues,omitempty"` // MTU mismatches between stacked interfaces
 }
 
-// getInterfaceTypeFromOS will be defined in platform-specific files
+// getInterfaceDetailsFromOS is defined in platform-specific files
 
-// GetNetworkInterfaces returns all network interfaces with their MTU values
+// GetNetworkInterfaces returns all network interfaces that are up with their
+// MTU values
 func GetNetworkInterfaces() (*InterfaceResult, error) {
+	return getNetworkInterfaces(false)
+}
+
+// getNetworkInterfaces lists the interfaces, with those that are
+// administratively down too if includeDown is set
+func getNetworkInterfaces(includeDown bool) (*InterfaceResult, error) {
+	interfaces, err := listNetworkInterfaces(includeDown)
+	if err != nil {
+		return nil, err
+	}
+	return &InterfaceResult{Interfaces: interfaces, Issues: validateInterfaceMTUs(interfaces, lookupInterfaceMTU)}, nil
+}
+
+func listNetworkInterfaces(includeDown bool) ([]NetworkInterface, error) {
 	interfaces, err := net.Interfaces()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get interfaces: %w", err)
@@ -31,11 +73,11 @@ func GetNetworkInterfaces() (*InterfaceResult, error) {
 
 	for _, iface := range interfaces {
 		// Skip interfaces that are down
-		if iface.Flags&net.FlagUp == 0 {
+		if iface.Flags&net.FlagUp == 0 && !includeDown {
 			continue
 		}
 
-		interfaceType := determineInterfaceType(iface.Name, iface.Flags)
+		details := determineInterfaceDetails(iface.Name, iface.Flags)
 
 		// Get MTU - some platforms might need special handling
 		mtu := iface.MTU
@@ -47,31 +89,91 @@ func GetNetworkInterfaces() (*InterfaceResult, error) {
 		}
 
 		result = append(result, NetworkInterface{
-			Name: iface.Name,
-			MTU:  mtu,
-			Type: interfaceType,
+			Name:           iface.Name,
+			MTU:            mtu,
+			Type:           details.Type,
+			Driver:         details.Driver,
+			Virtual:        details.Virtual,
+			Virtualization: details.Virtualization,
+			Master:         details.Master,
+			MasterType:     details.MasterType,
+			Members:        details.Members,
+			Parent:         details.Parent,
+			MAC:            iface.HardwareAddr.String(),
+			Addresses:      interfaceAddresses(iface),
+			Flags:          interfaceFlags(iface.Flags),
+			AdminState:     linkState(iface.Flags&net.FlagUp != 0),
+			OperState:      details.OperState,
 		})
 	}
 
-	return &InterfaceResult{Interfaces: result}, nil
+	return result, nil
 }
 
-// determineInterfaceType determines the type of network interface
-func determineInterfaceType(name string, flags net.Flags) string {
-	name = strings.ToLower(name)
+// interfaceAddresses lists the addresses assigned to iface in CIDR notation
+func interfaceAddresses(iface net.Interface) []string {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil
+	}
+	var result []string
+	for _, addr := range addrs {
+		result = append(result, addr.String())
+	}
+	return result
+}
 
-	// Try platform-specific interface type detection first
-	if t, ok := getInterfaceTypeFromOS(name); ok {
-		return t
+func interfaceFlags(flags net.Flags) []string {
+	if flags == 0 {
+		return nil
 	}
+	return strings.Split(flags.String(), "|")
+}
+
+func linkState(up bool) string {
+	if up {
+		return "up"
+	}
+	return "down"
+}
 
-	// Check for loopback
-	if flags&net.FlagLoopback != 0 {
-		return "loopback"
+// determineInterfaceDetails classifies an interface using the platform shim,
+// falling back to the loopback flag when the platform cannot tell
+func determineInterfaceDetails(name string, flags net.Flags) interfaceDetails {
+	details, ok := getInterfaceDetailsFromOS(name)
+	if !ok {
+		details = interfaceDetails{}
+	}
+	if details.Type == "" {
+		details.Type = "unknown"
+		if flags&net.FlagLoopback != 0 {
+			details.Type = "loopback"
+		}
 	}
+	if details.Virtualization == "" {
+		details.Virtualization = virtualizationFromDriver(details.Driver)
+	}
+	if details.OperState == "" {
+		details.OperState = linkState(flags&net.FlagRunning != 0)
+	}
+	return details
+}
+
+// virtualizationDrivers maps paravirtual NIC drivers to the platform they imply
+var virtualizationDrivers = map[string]string{
+	"virtio_net":   "kvm",
+	"vmxnet3":      "vmware",
+	"vmxnet":       "vmware",
+	"hv_netvsc":    "hyper-v",
+	"netvsc":       "hyper-v",
+	"xen-netfront": "xen",
+	"ena":          "aws",
+	"gve":          "gcp",
+	"veth":         "container",
+}
 
-	// Common interface name patterns
-	return "unknown"
+func virtualizationFromDriver(driver string) string {
+	return virtualizationDrivers[strings.ToLower(driver)]
 }
 
 // GetMaxMTU returns the maximum MTU among all interfaces (useful for auto-setting --max)
@@ -30,15 +30,25 @@ var parseDarwinRouteRIB = func(rib []byte) ([]route.Message, error) {
 	return route.ParseRIB(route.RIBTypeInterface, rib)
 }
 
-// getInterfaceTypeFromOS gets interface type using BSD route information (Darwin/macOS specific)
-func getInterfaceTypeFromOS(ifName string) (string, bool) {
+// darwinVirtualTypes are link types that are never backed by a physical port
+var darwinVirtualTypes = map[string]bool{
+	"bridge":  true,
+	"vlan":    true,
+	"tunnel":  true,
+	"virtual": true,
+}
+
+// getInterfaceDetailsFromOS classifies an interface from its BSD route
+// interface metrics (Darwin/macOS specific). Darwin does not report the
+// driver or bond/bridge membership through the routing socket.
+func getInterfaceDetailsFromOS(ifName string) (interfaceDetails, bool) {
 	rib, err := fetchDarwinRouteRIB()
 	if err != nil {
-		return "", false
+		return interfaceDetails{}, false
 	}
 	msgs, err := parseDarwinRouteRIB(rib)
 	if err != nil {
-		return "", false
+		return interfaceDetails{}, false
 	}
 	for _, m := range msgs {
 		imsg, ok := m.(*route.InterfaceMessage)
@@ -47,12 +57,13 @@ func getInterfaceTypeFromOS(ifName string) (string, bool) {
 		}
 		for _, sys := range imsg.Sys() {
 			if imx, ok := sys.(*route.InterfaceMetrics); ok {
-				if s, exists := ifTypeMap[imx.Type]; exists {
-					return s, true
+				typ, exists := ifTypeMap[imx.Type]
+				if !exists {
+					typ = "unknown"
 				}
-				return "unknown", true
+				return interfaceDetails{Type: typ, Virtual: darwinVirtualTypes[typ]}, true
 			}
 		}
 	}
-	return "", false
+	return interfaceDetails{}, false
 }
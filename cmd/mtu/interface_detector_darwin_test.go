@@ -9,7 +9,7 @@ import (
 	"golang.org/x/net/route"
 )
 
-func TestGetInterfaceTypeFromOSDarwinErrorPaths(t *testing.T) {
+func TestGetInterfaceDetailsFromOSDarwinErrorPaths(t *testing.T) {
 	originalFetch := fetchDarwinRouteRIB
 	originalParse := parseDarwinRouteRIB
 	t.Cleanup(func() {
@@ -21,8 +21,8 @@ func TestGetInterfaceTypeFromOSDarwinErrorPaths(t *testing.T) {
 		fetchDarwinRouteRIB = func() ([]byte, error) {
 			return nil, errors.New("fetch failed")
 		}
-		if typ, ok := getInterfaceTypeFromOS("en0"); ok || typ != "" {
-			t.Fatalf("expected fetch failure result, got type=%q ok=%v", typ, ok)
+		if details, ok := getInterfaceDetailsFromOS("en0"); ok || details.Type != "" {
+			t.Fatalf("expected fetch failure result, got type=%q ok=%v", details.Type, ok)
 		}
 	})
 
@@ -33,8 +33,8 @@ func TestGetInterfaceTypeFromOSDarwinErrorPaths(t *testing.T) {
 		parseDarwinRouteRIB = func(rib []byte) ([]route.Message, error) {
 			return nil, errors.New("parse failed")
 		}
-		if typ, ok := getInterfaceTypeFromOS("en0"); ok || typ != "" {
-			t.Fatalf("expected parse failure result, got type=%q ok=%v", typ, ok)
+		if details, ok := getInterfaceDetailsFromOS("en0"); ok || details.Type != "" {
+			t.Fatalf("expected parse failure result, got type=%q ok=%v", details.Type, ok)
 		}
 	})
 
@@ -45,8 +45,8 @@ func TestGetInterfaceTypeFromOSDarwinErrorPaths(t *testing.T) {
 		parseDarwinRouteRIB = func(rib []byte) ([]route.Message, error) {
 			return []route.Message{}, nil
 		}
-		if typ, ok := getInterfaceTypeFromOS("en0"); ok || typ != "" {
-			t.Fatalf("expected missing interface result, got type=%q ok=%v", typ, ok)
+		if details, ok := getInterfaceDetailsFromOS("en0"); ok || details.Type != "" {
+			t.Fatalf("expected missing interface result, got type=%q ok=%v", details.Type, ok)
 		}
 	})
 }
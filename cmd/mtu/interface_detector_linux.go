@@ -2,7 +2,209 @@
 
 package mtu
 
-// getInterfaceTypeFromOS gets interface type using Linux sysfs (Linux-specific)
-func getInterfaceTypeFromOS(_ string) (string, bool) {
-	return "", false
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// sysfsNetRoot is where Linux exposes one directory per network interface
+var sysfsNetRoot = "/sys/class/net"
+
+// ethtoolDriver asks the kernel which driver backs an interface (ETHTOOL_GDRVINFO).
+// Unlike sysfs it also names drivers of virtual devices such as veth or bridge.
+var ethtoolDriver = func(name string) (string, error) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = unix.Close(fd) }()
+
+	info, err := unix.IoctlGetEthtoolDrvinfo(fd, name)
+	if err != nil {
+		return "", err
+	}
+	return unix.ByteSliceToString(info.Driver[:]), nil
+}
+
+// ARPHRD_* link types from /sys/class/net/<name>/type
+const (
+	arphrdEther      = 1
+	arphrdInfiniband = 32
+	arphrdPPP        = 512
+	arphrdTunnel     = 768
+	arphrdTunnel6    = 769
+	arphrdLoopback   = 772
+	arphrdSit        = 776
+	arphrdIPGRE      = 778
+	arphrdIP6GRE     = 823
+	arphrdNone       = 65534 // Layer 3 devices without a link header, such as tun and WireGuard
+)
+
+// devTypes maps the DEVTYPE a driver reports in uevent to an interface type
+var devTypes = map[string]string{
+	"bridge":    "bridge",
+	"bond":      "bond",
+	"vlan":      "vlan",
+	"wlan":      "wifi",
+	"wireguard": "wireguard",
+	"vxlan":     "vxlan",
+	"geneve":    "geneve",
+	"macvlan":   "macvlan",
+	"macvtap":   "macvtap",
+	"ipvlan":    "ipvlan",
+	"gre":       "tunnel",
+}
+
+// driverTypes classifies virtual devices whose drivers set no DEVTYPE
+var driverTypes = map[string]string{
+	"veth":    "veth",
+	"team":    "team",
+	"dummy":   "dummy",
+	"bonding": "bond",
+	"bridge":  "bridge",
+}
+
+// getInterfaceDetailsFromOS classifies an interface from sysfs and ethtool
+func getInterfaceDetailsFromOS(name string) (interfaceDetails, bool) {
+	dir := filepath.Join(sysfsNetRoot, name)
+	if _, err := os.Stat(dir); err != nil {
+		return interfaceDetails{}, false
+	}
+
+	details := interfaceDetails{Driver: linuxDriver(dir, name), OperState: readSysfsString(filepath.Join(dir, "operstate"))}
+	details.Type = linuxInterfaceType(dir, details.Driver)
+
+	// Physical NICs have a device link; everything under /devices/virtual does not
+	if _, err := os.Stat(filepath.Join(dir, "device")); err != nil {
+		details.Virtual = details.Type != "loopback"
+	}
+
+	if master, err := os.Readlink(filepath.Join(dir, "master")); err == nil {
+		details.Master = filepath.Base(master)
+		masterDir := filepath.Join(sysfsNetRoot, details.Master)
+		details.MasterType = linuxInterfaceType(masterDir, linuxDriver(masterDir, details.Master))
+	}
+	switch lower := linuxLowerLinks(dir); details.Type {
+	case "bridge", "bond", "team":
+		details.Members = lower
+	case "vlan", "macvlan", "macvtap", "ipvlan":
+		if len(lower) == 1 {
+			details.Parent = lower[0]
+		}
+	}
+
+	return details, true
+}
+
+// linuxDriver prefers ethtool and falls back to the device's driver link
+func linuxDriver(dir, name string) string {
+	if driver, err := ethtoolDriver(name); err == nil && driver != "" {
+		return driver
+	}
+	if link, err := os.Readlink(filepath.Join(dir, "device", "driver")); err == nil {
+		return filepath.Base(link)
+	}
+	return ""
+}
+
+func linuxInterfaceType(dir, driver string) string {
+	linkType := readSysfsInt(filepath.Join(dir, "type"))
+	if linkType == arphrdLoopback {
+		return "loopback"
+	}
+
+	switch {
+	case sysfsExists(dir, "bridge"):
+		return "bridge"
+	case sysfsExists(dir, "bonding"):
+		return "bond"
+	case sysfsExists(dir, "wireless"), sysfsExists(dir, "phy80211"):
+		return "wifi"
+	case sysfsExists(dir, "tun_flags"):
+		if readSysfsInt(filepath.Join(dir, "tun_flags"))&unix.IFF_TAP != 0 {
+			return "tap"
+		}
+		return "tun"
+	}
+
+	if devType, ok := devTypes[readUeventValue(dir, "DEVTYPE")]; ok {
+		return devType
+	}
+	if driverType, ok := driverTypes[driver]; ok {
+		return driverType
+	}
+
+	switch linkType {
+	case arphrdEther:
+		return "ethernet"
+	case arphrdInfiniband:
+		return "infiniband"
+	case arphrdPPP:
+		return "ppp"
+	case arphrdTunnel, arphrdTunnel6, arphrdSit, arphrdIPGRE, arphrdIP6GRE, arphrdNone:
+		return "tunnel"
+	}
+	return ""
+}
+
+// linuxLowerLinks lists the interfaces an interface is stacked on: the ports of
+// a bridge, bond, or team, or the parent of a VLAN
+func linuxLowerLinks(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var lower []string
+	for _, entry := range entries {
+		if name, ok := strings.CutPrefix(entry.Name(), "lower_"); ok {
+			lower = append(lower, name)
+		}
+	}
+	sort.Strings(lower)
+	return lower
+}
+
+func sysfsExists(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+	return err == nil
+}
+
+// readSysfsInt parses a decimal or 0x-prefixed sysfs attribute, returning -1 if unreadable
+func readSysfsInt(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return -1
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(string(data)), 0, 64)
+	if err != nil {
+		return -1
+	}
+	return int(value)
+}
+
+// readSysfsString returns a sysfs attribute without its newline, or "" if unreadable
+func readSysfsString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func readUeventValue(dir, key string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "uevent"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, key+"="); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
 }
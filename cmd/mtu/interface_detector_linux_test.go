@@ -0,0 +1,114 @@
+//go:build linux
+
+package mtu
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeSysfs builds a /sys/class/net tree in a temp dir and points the
+// detector at it. Each interface maps attribute paths to file contents;
+// values starting with "->" become symlinks.
+func fakeSysfs(t *testing.T, interfaces map[string]map[string]string) {
+	t.Helper()
+	root := t.TempDir()
+	for name, attrs := range interfaces {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for attr, value := range attrs {
+			path := filepath.Join(dir, attr)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			var err error
+			switch {
+			case value == "/":
+				err = os.MkdirAll(path, 0o755)
+			case len(value) > 2 && value[:2] == "->":
+				err = os.Symlink(value[2:], path)
+			default:
+				err = os.WriteFile(path, []byte(value+"\n"), 0o644)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	originalRoot, originalDriver := sysfsNetRoot, ethtoolDriver
+	t.Cleanup(func() { sysfsNetRoot, ethtoolDriver = originalRoot, originalDriver })
+	sysfsNetRoot = root
+	ethtoolDriver = func(string) (string, error) { return "", errors.New("no ethtool in tests") }
+}
+
+func TestGetInterfaceDetailsFromOSLinux(t *testing.T) {
+	fakeSysfs(t, map[string]map[string]string{
+		"lo":     {"type": "772"},
+		"eth0":   {"type": "1", "device/driver": "->../../bus/virtio/drivers/virtio_net", "master": "->../bond0", "operstate": "up"},
+		"eth1":   {"type": "1", "device/driver": "->../../bus/pci/drivers/ixgbe", "master": "->../bond0", "operstate": "lowerlayerdown"},
+		"bond0":  {"type": "1", "bonding": "/", "lower_eth0": "->../eth0", "lower_eth1": "->../eth1", "master": "->../br0"},
+		"br0":    {"type": "1", "bridge": "/", "lower_bond0": "->../bond0", "lower_veth1": "->../veth1"},
+		"veth1":  {"type": "1", "master": "->../br0"},
+		"wlan0":  {"type": "1", "wireless": "/", "device/driver": "->../../bus/pci/drivers/iwlwifi"},
+		"tun0":   {"type": "65534", "tun_flags": "0x1001"},
+		"tap0":   {"type": "1", "tun_flags": "0x1002"},
+		"wg0":    {"type": "65534", "uevent": "DEVTYPE=wireguard\nINTERFACE=wg0"},
+		"eth0.5": {"type": "1", "uevent": "DEVTYPE=vlan\nINTERFACE=eth0.5", "lower_eth0": "->../eth0"},
+	})
+	ethtoolDriver = func(name string) (string, error) {
+		if name == "veth1" {
+			return "veth", nil
+		}
+		return "", errors.New("not supported")
+	}
+
+	tests := map[string]interfaceDetails{
+		"lo":     {Type: "loopback"},
+		"eth0":   {Type: "ethernet", Driver: "virtio_net", Master: "bond0", MasterType: "bond", OperState: "up"},
+		"eth1":   {Type: "ethernet", Driver: "ixgbe", Master: "bond0", MasterType: "bond", OperState: "lowerlayerdown"},
+		"bond0":  {Type: "bond", Virtual: true, Master: "br0", MasterType: "bridge", Members: []string{"eth0", "eth1"}},
+		"br0":    {Type: "bridge", Virtual: true, Members: []string{"bond0", "veth1"}},
+		"veth1":  {Type: "veth", Driver: "veth", Virtual: true, Master: "br0", MasterType: "bridge"},
+		"wlan0":  {Type: "wifi", Driver: "iwlwifi"},
+		"tun0":   {Type: "tun", Virtual: true},
+		"tap0":   {Type: "tap", Virtual: true},
+		"wg0":    {Type: "wireguard", Virtual: true},
+		"eth0.5": {Type: "vlan", Virtual: true, Parent: "eth0"},
+	}
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, ok := getInterfaceDetailsFromOS(name)
+			if !ok {
+				t.Fatalf("expected %s to be found", name)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	if _, ok := getInterfaceDetailsFromOS("missing0"); ok {
+		t.Fatal("expected unknown interface to report not found")
+	}
+}
+
+func TestDetermineInterfaceDetailsLinux(t *testing.T) {
+	fakeSysfs(t, map[string]map[string]string{
+		"eth0":   {"type": "1", "device/driver": "->../../bus/vmbus/drivers/hv_netvsc"},
+		"weird0": {"type": "999"},
+	})
+
+	if got := determineInterfaceDetails("eth0", 0); got.Virtualization != "hyper-v" || got.Virtual {
+		t.Fatalf("expected a physical-looking Hyper-V NIC, got %+v", got)
+	}
+	if got := determineInterfaceDetails("weird0", net.FlagUp|net.FlagRunning); got.Type != "unknown" || got.OperState != "up" {
+		t.Fatalf("expected unknown type, up from the flags, got %+v", got)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package mtu
+
+// getInterfaceDetailsFromOS is a stub for unsupported platforms
+func getInterfaceDetailsFromOS(_ string) (interfaceDetails, bool) {
+	return interfaceDetails{}, false
+}
@@ -0,0 +1,92 @@
+//go:build windows
+
+package mtu
+
+import (
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// IANA ifType values reported by GetAdaptersAddresses
+var windowsIfTypes = map[uint32]string{
+	windows.IF_TYPE_ETHERNET_CSMACD:    "ethernet",
+	windows.IF_TYPE_SOFTWARE_LOOPBACK:  "loopback",
+	windows.IF_TYPE_IEEE80211:          "wifi",
+	windows.IF_TYPE_TUNNEL:             "tunnel",
+	windows.IF_TYPE_PPP:                "ppp",
+	windows.IF_TYPE_ISO88025_TOKENRING: "tokenring",
+	53:                                 "virtual", // IF_TYPE_PROP_VIRTUAL
+	135:                                "vlan",    // IF_TYPE_L2_VLAN
+	209:                                "bridge",  // IF_TYPE_BRIDGE
+}
+
+// windowsVirtualAdapters maps adapter description substrings to the platform
+// that provides the adapter
+var windowsVirtualAdapters = []struct {
+	match          string
+	virtualization string
+}{
+	{"hyper-v", "hyper-v"},
+	{"vmware", "vmware"},
+	{"virtualbox", "virtualbox"},
+	{"virtio", "kvm"},
+	{"tap-windows", "vpn"},
+	{"wireguard", "vpn"},
+	{"wintun", "vpn"},
+}
+
+// fetchWindowsAdapters returns the adapter list from GetAdaptersAddresses,
+// growing the buffer until the call succeeds
+var fetchWindowsAdapters = func() ([]*windows.IpAdapterAddresses, error) {
+	size := uint32(15000)
+	for {
+		buf := make([]byte, size)
+		first := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0]))
+		err := windows.GetAdaptersAddresses(windows.AF_UNSPEC, windows.GAA_FLAG_INCLUDE_PREFIX, 0, first, &size)
+		if err == windows.ERROR_BUFFER_OVERFLOW {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var adapters []*windows.IpAdapterAddresses
+		for adapter := first; adapter != nil; adapter = adapter.Next {
+			adapters = append(adapters, adapter)
+		}
+		return adapters, nil
+	}
+}
+
+// getInterfaceDetailsFromOS classifies an interface from its adapter type and
+// description. Go names Windows interfaces by the adapter's friendly name.
+func getInterfaceDetailsFromOS(name string) (interfaceDetails, bool) {
+	adapters, err := fetchWindowsAdapters()
+	if err != nil {
+		return interfaceDetails{}, false
+	}
+	for _, adapter := range adapters {
+		if windows.UTF16PtrToString(adapter.FriendlyName) != name {
+			continue
+		}
+		details := interfaceDetails{
+			Type:   windowsIfTypes[adapter.IfType],
+			Driver: windows.UTF16PtrToString(adapter.Description),
+		}
+		description := strings.ToLower(details.Driver)
+		for _, candidate := range windowsVirtualAdapters {
+			if strings.Contains(description, candidate.match) {
+				details.Virtualization = candidate.virtualization
+				details.Virtual = true
+				break
+			}
+		}
+		switch details.Type {
+		case "tunnel", "virtual", "vlan", "bridge":
+			details.Virtual = true
+		}
+		return details, true
+	}
+	return interfaceDetails{}, false
+}
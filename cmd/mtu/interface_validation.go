@@ -0,0 +1,98 @@
+package mtu
+
+import (
+	"fmt"
+	"net"
+)
+
+// InterfaceIssue is an MTU mismatch between an interface and the bond, bridge,
+// or parent it is stacked with
+type InterfaceIssue struct {
+	Interface    string `json:"interface"`
+	MTU          int    `json:"mtu"`
+	Related      string `json:"related"` // The master or parent interface
+	RelatedMTU   int    `json:"related_mtu"`
+	Message      string `json:"message"`
+	SuggestedFix string `json:"suggested_fix"`
+}
+
+// lookupInterfaceMTU finds the MTU of interfaces that are not in the listing,
+// such as bond members that are administratively down
+var lookupInterfaceMTU = func(name string) (int, bool) {
+	if iface, err := net.InterfaceByName(name); err == nil && iface.MTU > 0 {
+		return iface.MTU, true
+	}
+	if mtu, err := getMTU(name); err == nil {
+		return mtu, true
+	}
+	return 0, false
+}
+
+// validateInterfaceMTUs checks that bond, team, and bridge ports match the MTU
+// of their master, and that VLAN-style subinterfaces do not exceed their parent.
+// Mismatches here let small packets through while jumbo frames are dropped,
+// which is why they tend to show up as intermittent failures.
+func validateInterfaceMTUs(interfaces []NetworkInterface, lookup func(string) (int, bool)) []InterfaceIssue {
+	mtus := make(map[string]int, len(interfaces))
+	for _, iface := range interfaces {
+		mtus[iface.Name] = iface.MTU
+	}
+	mtuOf := func(name string) (int, bool) {
+		if mtu, ok := mtus[name]; ok {
+			return mtu, true
+		}
+		return lookup(name)
+	}
+
+	var issues []InterfaceIssue
+	for _, iface := range interfaces {
+		for _, member := range iface.Members {
+			memberMTU, ok := mtuOf(member)
+			if !ok || memberMTU == iface.MTU {
+				continue
+			}
+			issues = append(issues, InterfaceIssue{
+				Interface:    member,
+				MTU:          memberMTU,
+				Related:      iface.Name,
+				RelatedMTU:   iface.MTU,
+				Message:      memberMismatchMessage(iface, member, memberMTU),
+				SuggestedFix: setMTUCommand(member, iface.MTU),
+			})
+		}
+
+		if iface.Parent == "" {
+			continue
+		}
+		parentMTU, ok := mtuOf(iface.Parent)
+		if !ok || iface.MTU <= parentMTU {
+			continue
+		}
+		issues = append(issues, InterfaceIssue{
+			Interface:  iface.Name,
+			MTU:        iface.MTU,
+			Related:    iface.Parent,
+			RelatedMTU: parentMTU,
+			Message: fmt.Sprintf("%s %s MTU %d exceeds parent %s MTU %d; frames between %d and %d bytes are dropped (alternatively raise %s to %d)",
+				iface.Type, iface.Name, iface.MTU, iface.Parent, parentMTU, parentMTU+1, iface.MTU, iface.Parent, iface.MTU),
+			SuggestedFix: setMTUCommand(iface.Name, parentMTU),
+		})
+	}
+	return issues
+}
+
+func memberMismatchMessage(master NetworkInterface, member string, memberMTU int) string {
+	if master.Type == "bridge" {
+		if memberMTU < master.MTU {
+			return fmt.Sprintf("bridge port %s MTU %d is below %s MTU %d; larger frames are dropped on that port", member, memberMTU, master.Name, master.MTU)
+		}
+		return fmt.Sprintf("bridge port %s MTU %d differs from %s MTU %d; frames forwarded to smaller ports are dropped", member, memberMTU, master.Name, master.MTU)
+	}
+	return fmt.Sprintf("%s member %s MTU %d differs from %s MTU %d; traffic fails only while hashed to or failed over to that member", master.Type, member, memberMTU, master.Name, master.MTU)
+}
+
+// setMTUCommand is the iproute2 command that applies mtu to name. Membership
+// is only detected on Linux, so that is the only syntax needed.
+func setMTUCommand(name string, mtu int) string {
+	return fmt.Sprintf("ip link set dev %s mtu %d", name, mtu)
+}
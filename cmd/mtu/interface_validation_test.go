@@ -0,0 +1,67 @@
+package mtu
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateInterfaceMTUs(t *testing.T) {
+	interfaces := []NetworkInterface{
+		{Name: "eth0", MTU: 9000, Type: "ethernet", Master: "bond0"},
+		{Name: "bond0", MTU: 9000, Type: "bond", Members: []string{"eth0", "eth1"}},
+		{Name: "br0", MTU: 9000, Type: "bridge", Members: []string{"bond0", "veth1"}},
+		{Name: "veth1", MTU: 1500, Type: "veth", Master: "br0"},
+		{Name: "bond0.20", MTU: 9216, Type: "vlan", Parent: "bond0"},
+		{Name: "bond0.30", MTU: 1500, Type: "vlan", Parent: "bond0"},
+		{Name: "team0", MTU: 1500, Type: "team", Members: []string{"missing0"}},
+	}
+	// eth1 is down so it is not listed; its MTU comes from the lookup
+	lookup := func(name string) (int, bool) {
+		if name == "eth1" {
+			return 1500, true
+		}
+		return 0, false
+	}
+
+	issues := validateInterfaceMTUs(interfaces, lookup)
+	if len(issues) != 3 {
+		t.Fatalf("expected 3 issues, got %d: %+v", len(issues), issues)
+	}
+
+	tests := []struct {
+		iface, related, fix, message string
+	}{
+		{"eth1", "bond0", "ip link set dev eth1 mtu 9000", "bond member eth1 MTU 1500 differs from bond0 MTU 9000"},
+		{"veth1", "br0", "ip link set dev veth1 mtu 9000", "bridge port veth1 MTU 1500 is below br0 MTU 9000"},
+		{"bond0.20", "bond0", "ip link set dev bond0.20 mtu 9000", "vlan bond0.20 MTU 9216 exceeds parent bond0 MTU 9000"},
+	}
+	for i, tt := range tests {
+		issue := issues[i]
+		if issue.Interface != tt.iface || issue.Related != tt.related || issue.SuggestedFix != tt.fix {
+			t.Errorf("issue %d = %+v, want %s vs %s fixed by %q", i, issue, tt.iface, tt.related, tt.fix)
+		}
+		if !strings.Contains(issue.Message, tt.message) {
+			t.Errorf("issue %d message %q does not contain %q", i, issue.Message, tt.message)
+		}
+	}
+}
+
+func TestOutputInterfacesTableShowsIssues(t *testing.T) {
+	result := &InterfaceResult{
+		Interfaces: []NetworkInterface{
+			{Name: "bond0", MTU: 9000, Type: "bond", Driver: "bonding", Virtual: true, Members: []string{"eth0", "eth1"}},
+			{Name: "eth1", MTU: 1500, Type: "ethernet", Driver: "ixgbe", Master: "bond0", MasterType: "bond"},
+		},
+		Issues: []InterfaceIssue{{Interface: "eth1", Message: "bond member eth1 MTU 1500 differs from bond0 MTU 9000", SuggestedFix: "ip link set dev eth1 mtu 9000"}},
+	}
+
+	output, err := captureStdout(t, func() error { return outputInterfacesTable(result) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"virtual; members eth0,eth1", "member of bond0 (bond)", "MTU issues:", "Fix: ip link set dev eth1 mtu 9000"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+}
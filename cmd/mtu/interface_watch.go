@@ -0,0 +1,194 @@
+package mtu
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/notify"
+	"github.com/spf13/cobra"
+)
+
+// interfacesAlertSource names mtu interfaces --watch as the source of its alerts
+const interfacesAlertSource = "mtu interfaces"
+
+// Changes reported by interfaceEvent
+const (
+	interfaceAdded   = "added"
+	interfaceRemoved = "removed"
+	interfaceMTU     = "mtu"   // The MTU changed
+	interfaceState   = "state" // The admin or oper state changed
+)
+
+// listWatchedInterfaces lists every interface, down ones included, so the
+// watch sees an interface go down rather than disappear
+var listWatchedInterfaces = func() ([]NetworkInterface, error) {
+	return listNetworkInterfaces(true)
+}
+
+var watchLinkChanges = subscribeLinkChanges
+
+// interfaceEvent is one interface change, printed as its own record
+type interfaceEvent struct {
+	Timestamp          string `json:"timestamp"`
+	Interface          string `json:"interface"`
+	Change             string `json:"change"` // added, removed, mtu, or state
+	MTU                int    `json:"mtu"`
+	PreviousMTU        int    `json:"previous_mtu,omitempty"`
+	AdminState         string `json:"admin_state,omitempty"`
+	PreviousAdminState string `json:"previous_admin_state,omitempty"`
+	OperState          string `json:"oper_state,omitempty"`
+	PreviousOperState  string `json:"previous_oper_state,omitempty"`
+}
+
+// interfaceMTUAlert is the detail of an interface MTU change alert
+type interfaceMTUAlert struct {
+	PreviousMTU int `json:"previous_mtu"`
+	MTU         int `json:"mtu"`
+}
+
+// runInterfacesWatch prints an event for each interface change until Ctrl+C.
+// It lists the interfaces every interval, and on Linux also whenever rtnetlink
+// reports a link or address change.
+func runInterfacesWatch(cmd *cobra.Command, format outputFormat, interval time.Duration) error {
+	notifiers, closeNotifiers, err := notify.OpenFlags(cmd.Flags())
+	if err != nil {
+		return err
+	}
+	defer closeNotifiers()
+
+	ctx, stop := watchContext(watchLimits{})
+	defer stop()
+
+	changes, unsubscribe, err := watchLinkChanges()
+	if err != nil {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Warning: link change notifications unavailable, polling every %s: %v\n", interval, err)
+	} else {
+		defer unsubscribe()
+	}
+
+	previous, err := listWatchedInterfaces()
+	if err != nil {
+		return fmt.Errorf("failed to get network interfaces: %w", err)
+	}
+	if !format.structured() {
+		fmt.Printf("Watching %d interfaces for MTU and state changes (Ctrl+C to stop)\n", len(previous))
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-changes:
+		}
+
+		current, err := listWatchedInterfaces()
+		if err != nil {
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Warning: failed to get network interfaces: %v\n", err)
+			continue
+		}
+		now := time.Now()
+		for _, event := range diffInterfaces(previous, current, now) {
+			if err := writeInterfaceEvent(format, event); err != nil {
+				return err
+			}
+			if event.Change == interfaceMTU {
+				notifyWatch(ctx, cmd, notifiers, newInterfaceMTUEvent(now, event))
+			}
+		}
+		previous = current
+	}
+}
+
+// diffInterfaces lists the changes between two listings: interfaces added,
+// then each interface's MTU and state changes, then interfaces removed
+func diffInterfaces(previous, current []NetworkInterface, now time.Time) []interfaceEvent {
+	timestamp := now.Format(time.RFC3339)
+	before := make(map[string]NetworkInterface, len(previous))
+	for _, iface := range previous {
+		before[iface.Name] = iface
+	}
+
+	var events []interfaceEvent
+	seen := make(map[string]bool, len(current))
+	for _, iface := range current {
+		seen[iface.Name] = true
+		event := interfaceEvent{
+			Timestamp:  timestamp,
+			Interface:  iface.Name,
+			MTU:        iface.MTU,
+			AdminState: iface.AdminState,
+			OperState:  iface.OperState,
+		}
+		old, ok := before[iface.Name]
+		if !ok {
+			event.Change = interfaceAdded
+			events = append(events, event)
+			continue
+		}
+		if old.MTU != iface.MTU {
+			mtu := event
+			mtu.Change, mtu.PreviousMTU = interfaceMTU, old.MTU
+			events = append(events, mtu)
+		}
+		if old.AdminState != iface.AdminState || old.OperState != iface.OperState {
+			state := event
+			state.Change = interfaceState
+			state.PreviousAdminState, state.PreviousOperState = old.AdminState, old.OperState
+			events = append(events, state)
+		}
+	}
+
+	var removed []string
+	for name := range before {
+		if !seen[name] {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		events = append(events, interfaceEvent{Timestamp: timestamp, Interface: name, Change: interfaceRemoved, MTU: before[name].MTU})
+	}
+	return events
+}
+
+func writeInterfaceEvent(format outputFormat, event interfaceEvent) error {
+	if format.structured() {
+		return format.writeRecord(event)
+	}
+	fmt.Printf("%s  %-15s %s\n", event.Timestamp, event.Interface, event.describe())
+	return nil
+}
+
+// describe summarizes the change for the table output and alerts
+func (e interfaceEvent) describe() string {
+	switch e.Change {
+	case interfaceAdded:
+		return fmt.Sprintf("added (MTU %d, %s)", e.MTU, e.states())
+	case interfaceRemoved:
+		return "removed"
+	case interfaceMTU:
+		return fmt.Sprintf("MTU changed from %d to %d", e.PreviousMTU, e.MTU)
+	default:
+		previous := interfaceEvent{AdminState: e.PreviousAdminState, OperState: e.PreviousOperState}
+		return fmt.Sprintf("%s (was %s)", e.states(), previous.states())
+	}
+}
+
+func (e interfaceEvent) states() string {
+	return fmt.Sprintf("admin %s, oper %s", e.AdminState, e.OperState)
+}
+
+// newInterfaceMTUEvent describes an interface MTU change as an alert
+func newInterfaceMTUEvent(timestamp time.Time, event interfaceEvent) notify.Event {
+	return notify.Event{
+		Time:    timestamp,
+		Source:  interfacesAlertSource,
+		Target:  event.Interface,
+		Summary: event.describe(),
+		Details: interfaceMTUAlert{PreviousMTU: event.PreviousMTU, MTU: event.MTU},
+	}
+}
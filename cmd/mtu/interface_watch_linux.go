@@ -0,0 +1,43 @@
+//go:build linux
+
+package mtu
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// subscribeLinkChanges signals whenever rtnetlink announces a link or address
+// change, so the watch checks at once instead of at the next poll. The
+// messages themselves are not parsed; the watch lists the interfaces again.
+func subscribeLinkChanges() (<-chan struct{}, func(), error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK, unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, nil, err
+	}
+	groups := uint32(unix.RTMGRP_LINK | unix.RTMGRP_IPV4_IFADDR | unix.RTMGRP_IPV6_IFADDR)
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: groups}); err != nil {
+		_ = unix.Close(fd)
+		return nil, nil, err
+	}
+
+	// A nonblocking descriptor joins the runtime poller, so Close unblocks Read
+	socket := os.NewFile(uintptr(fd), "rtnetlink")
+	changes := make(chan struct{}, 1)
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			// ENOBUFS means notifications overflowed the socket, so something changed
+			if _, err := socket.Read(buf); err != nil && !errors.Is(err, unix.ENOBUFS) {
+				return
+			}
+			select {
+			case changes <- struct{}{}:
+			default: // A check is already pending
+			}
+		}
+	}()
+	return changes, func() { _ = socket.Close() }, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package mtu
+
+// subscribeLinkChanges has no notification source outside Linux, so the watch
+// relies on polling
+func subscribeLinkChanges() (<-chan struct{}, func(), error) {
+	return nil, func() {}, nil
+}
@@ -0,0 +1,51 @@
+package mtu
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffInterfaces(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	previous := []NetworkInterface{
+		{Name: "eth0", MTU: 1500, AdminState: "up", OperState: "up"},
+		{Name: "wg0", MTU: 1420, AdminState: "up", OperState: "unknown"},
+		{Name: "eth1", MTU: 9000, AdminState: "up", OperState: "up"},
+	}
+	current := []NetworkInterface{
+		{Name: "eth0", MTU: 1400, AdminState: "up", OperState: "lowerlayerdown"},
+		{Name: "wg0", MTU: 1420, AdminState: "up", OperState: "unknown"},
+		{Name: "tun0", MTU: 1500, AdminState: "up", OperState: "down"},
+	}
+
+	got := diffInterfaces(previous, current, now)
+	want := []interfaceEvent{
+		{Timestamp: "2026-01-02T03:04:05Z", Interface: "eth0", Change: interfaceMTU, MTU: 1400, PreviousMTU: 1500, AdminState: "up", OperState: "lowerlayerdown"},
+		{Timestamp: "2026-01-02T03:04:05Z", Interface: "eth0", Change: interfaceState, MTU: 1400, AdminState: "up", PreviousAdminState: "up", OperState: "lowerlayerdown", PreviousOperState: "up"},
+		{Timestamp: "2026-01-02T03:04:05Z", Interface: "tun0", Change: interfaceAdded, MTU: 1500, AdminState: "up", OperState: "down"},
+		{Timestamp: "2026-01-02T03:04:05Z", Interface: "eth1", Change: interfaceRemoved, MTU: 9000},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("diffInterfaces =\n%+v\nwant\n%+v", got, want)
+	}
+
+	descriptions := []string{
+		"MTU changed from 1500 to 1400",
+		"admin up, oper lowerlayerdown (was admin up, oper up)",
+		"added (MTU 1500, admin up, oper down)",
+		"removed",
+	}
+	for i, event := range got {
+		if event.describe() != descriptions[i] {
+			t.Errorf("event %d describes as %q, want %q", i, event.describe(), descriptions[i])
+		}
+	}
+	if alert := newInterfaceMTUEvent(now, got[0]); alert.Target != "eth0" || alert.Summary != descriptions[0] {
+		t.Errorf("alert = %+v", alert)
+	}
+
+	if events := diffInterfaces(current, current, now); len(events) != 0 {
+		t.Fatalf("unchanged interfaces reported %+v", events)
+	}
+}
@@ -2,7 +2,11 @@ package mtu
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/units"
 	"github.com/spf13/cobra"
 )
 
@@ -13,38 +17,122 @@ var interfacesCmd = &cobra.Command{
 	Long: `Interfaces lists all local network interfaces and their configured MTU values.
 This helps establish baseline MTU values for discovery operations.
 
-Examples:
-  cidrator mtu interfaces
-  cidrator mtu interfaces --json`,
+Interface types come from the operating system (sysfs and ethtool on Linux,
+routing socket metrics on macOS, adapter types on Windows). Where the platform
+reports it, the output also shows the driver, whether the interface is virtual
+and which hypervisor or container platform the driver suggests, bond or
+bridge membership, and the parent of VLAN subinterfaces.
+
+Stacked interfaces are validated: bond, team, and bridge ports must match their
+master's MTU, and a VLAN may not exceed its parent's MTU. Mismatches are listed
+with the command that fixes them.
+
+Each interface also lists its MAC, assigned addresses, flags, and admin and
+operational state. Interfaces that are administratively down are left out
+unless --all is given.
+
+--watch keeps running and prints an event when an interface is added or
+removed, or its MTU or state changes: a line each in the table format, or a
+record each with --format json or yaml. It lists the interfaces every
+--interval (default 2s), and on Linux also as soon as rtnetlink reports a link
+or address change. MTU changes are also sent as alerts to --webhook, --syslog,
+and the notifiers of the alerts section of the config file.`,
 	RunE: runInterfaces,
 }
 
+func init() {
+	interfacesCmd.Flags().Bool("all", false, "Include interfaces that are administratively down")
+	interfacesCmd.Flags().Bool("watch", false, "Keep running and print an event when an interface MTU or state changes")
+	units.Duration(interfacesCmd.Flags(), "interval", 2*time.Second, "With --watch, how often to list the interfaces")
+	interfacesCmd.Flags().String("webhook", "", "With --watch, POST a JSON alert to this URL when an interface MTU changes")
+	interfacesCmd.Flags().Bool("syslog", false, "With --watch, also log alerts to the local syslog")
+}
+
 func runInterfaces(cmd *cobra.Command, args []string) error {
-	jsonOutput, _ := cmd.Flags().GetBool("json")
+	format, err := readOutputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	if watch, _ := cmd.Flags().GetBool("watch"); watch {
+		interval, _ := cmd.Flags().GetDuration("interval")
+		if interval <= 0 {
+			return errcode.Errorf(errcode.CLIUsage, "--interval must be positive")
+		}
+		return runInterfacesWatch(cmd, format, interval)
+	}
 
 	// Get real network interfaces
-	result, err := GetNetworkInterfaces()
+	all, _ := cmd.Flags().GetBool("all")
+	result, err := getNetworkInterfaces(all)
 	if err != nil {
 		return fmt.Errorf("failed to get network interfaces: %w", err)
 	}
 
-	if jsonOutput {
-		return outputInterfacesJSON(result)
+	if format.structured() {
+		return outputInterfacesStructured(result, format)
 	}
 	return outputInterfacesTable(result)
 }
 
-func outputInterfacesJSON(result *InterfaceResult) error {
-	return writePrettyJSON(result)
+func outputInterfacesStructured(result *InterfaceResult, format outputFormat) error {
+	return format.write(result)
 }
 
 func outputInterfacesTable(result *InterfaceResult) error {
-	fmt.Printf("%-15s %-6s %s\n", "Interface", "MTU", "Type")
-	fmt.Printf("%-15s %-6s %s\n", "---------------", "------", "--------")
+	fmt.Printf("%-15s %-6s %-16s %-10s %-12s %-17s %s\n", "Interface", "MTU", "State", "Type", "Driver", "MAC", "Details")
+	fmt.Printf("%-15s %-6s %-16s %-10s %-12s %-17s %s\n", "---------------", "------", "----------------", "----------", "------------", "-----------------", "--------")
 
 	for _, iface := range result.Interfaces {
-		fmt.Printf("%-15s %-6d %s\n", iface.Name, iface.MTU, iface.Type)
+		driver := iface.Driver
+		if driver == "" {
+			driver = "-"
+		}
+		mac := iface.MAC
+		if mac == "" {
+			mac = "-"
+		}
+		state := iface.AdminState + "/" + iface.OperState
+		fmt.Printf("%-15s %-6d %-16s %-10s %-12s %-17s %s\n", iface.Name, iface.MTU, state, iface.Type, driver, mac, interfaceNotes(iface))
+	}
+
+	if len(result.Issues) > 0 {
+		fmt.Printf("\nMTU issues:\n")
+		for _, issue := range result.Issues {
+			fmt.Printf("  ! %s\n", issue.Message)
+			fmt.Printf("    Fix: %s\n", issue.SuggestedFix)
+		}
 	}
 
 	return nil
 }
+
+// interfaceNotes summarizes virtualization hints and bond/bridge membership
+func interfaceNotes(iface NetworkInterface) string {
+	var notes []string
+	if iface.Virtualization != "" {
+		notes = append(notes, "virtualization "+iface.Virtualization)
+	} else if iface.Virtual {
+		notes = append(notes, "virtual")
+	}
+	if iface.Master != "" {
+		member := "member of " + iface.Master
+		if iface.MasterType != "" {
+			member += " (" + iface.MasterType + ")"
+		}
+		notes = append(notes, member)
+	}
+	if len(iface.Members) > 0 {
+		notes = append(notes, "members "+strings.Join(iface.Members, ","))
+	}
+	if iface.Parent != "" {
+		notes = append(notes, "parent "+iface.Parent)
+	}
+	if len(iface.Addresses) > 0 {
+		notes = append(notes, "addresses "+strings.Join(iface.Addresses, ","))
+	}
+	if len(notes) == 0 {
+		return "-"
+	}
+	return strings.Join(notes, "; ")
+}
@@ -1,6 +1,7 @@
 package mtu
 
 import (
+	"github.com/euan-cowie/cidrator/internal/units"
 	"github.com/spf13/cobra"
 )
 
@@ -22,22 +23,31 @@ func init() {
 	MTUCmd.AddCommand(interfacesCmd)
 	MTUCmd.AddCommand(suggestCmd)
 	MTUCmd.AddCommand(peerCmd)
+	MTUCmd.AddCommand(compareCmd)
+	MTUCmd.AddCommand(clampCmd)
+	MTUCmd.AddCommand(blackholeCmd)
 
 	// Global flags for MTU commands
-	MTUCmd.PersistentFlags().Bool("4", false, "Force IPv4")
-	MTUCmd.PersistentFlags().Bool("6", false, "Force IPv6")
-	MTUCmd.PersistentFlags().String("proto", "icmp", "Probe method (icmp|udp|tcp)")
-	MTUCmd.PersistentFlags().Int("min", 0, "Lower bound (IPv4 default: 576, IPv6: 1280)")
-	MTUCmd.PersistentFlags().Int("max", 9216, "Upper bound")
-	MTUCmd.PersistentFlags().Int("step", 0, "Granularity for linear sweep mode (0 = binary search)")
-	MTUCmd.PersistentFlags().Duration("timeout", 0, "Wait per probe (default: 2s)")
+	MTUCmd.PersistentFlags().Bool("4", false, "Force IPv4; fail if the target has no IPv4 address")
+	MTUCmd.PersistentFlags().Bool("6", false, "Force IPv6; fail if the target has no IPv6 address")
+	MTUCmd.PersistentFlags().String("prefer", "", "IP version to use when the target has both (4|6), falling back to the other (default: --prefer-family)")
+	MTUCmd.PersistentFlags().String("proto", "icmp", "Probe method (icmp|udp|tcp|tls; discover also accepts all)")
+	units.Size(MTUCmd.PersistentFlags(), "min", 0, "Lower bound in bytes, such as 1280 or 1.5k (IPv4 default: 576, IPv6: 1280)")
+	units.Size(MTUCmd.PersistentFlags(), "max", 9216, "Upper bound in bytes, such as 1500 or 9k")
+	units.Size(MTUCmd.PersistentFlags(), "step", 0, "Granularity in bytes for linear sweep mode (0 = binary search)")
+	MTUCmd.PersistentFlags().Bool("exhaustive", false, "Skip the common-MTU fast path and binary search the whole range")
+	units.Duration(MTUCmd.PersistentFlags(), "timeout", 0, "Wait per probe, such as 1500ms (default: 2s)")
 	MTUCmd.PersistentFlags().Int("ttl", 64, "Initial hop limit")
-	MTUCmd.PersistentFlags().Bool("json", false, "Structured output")
+	MTUCmd.PersistentFlags().StringP("format", "f", formatTable, "Output format (table, json, yaml)")
+	MTUCmd.PersistentFlags().Bool("json", false, "Same as --format json")
 	MTUCmd.PersistentFlags().Bool("quiet", false, "Suppress informational output")
 	MTUCmd.PersistentFlags().Int("pps", 10, "Rate limit probes per second")
-	MTUCmd.PersistentFlags().Bool("hops", false, "Enable hop-by-hop MTU discovery (similar to tracepath)")
+	units.Rate(MTUCmd.PersistentFlags(), "rate", 0, "Rate limit probe bandwidth instead of --pps, such as 500kbps or 2mbps")
+	MTUCmd.PersistentFlags().Bool("hops", false, "Enable hop-by-hop MTU discovery (similar to tracepath) with ICMP, UDP, or TCP probes")
 	MTUCmd.PersistentFlags().Int("max-hops", 30, "Maximum hops for hop-by-hop discovery")
 	MTUCmd.PersistentFlags().Int("port", 0, "Target port for TCP/UDP probes (0 = default)")
-	MTUCmd.PersistentFlags().Bool("plpmtud", false, "Enable PLPMTUD fallback for black-hole detection (RFC 4821)")
+	MTUCmd.PersistentFlags().Int("src-port", 0, "Source port for TCP/UDP probes (implies --src-port-mode fixed; start port for sequential)")
+	MTUCmd.PersistentFlags().String("src-port-mode", SourcePortRandom, "Source port selection for TCP/UDP probes (random|fixed|sequential)")
+	MTUCmd.PersistentFlags().Bool("plpmtud", false, "Fall back to the RFC 8899 PLPMTUD state machine over UDP when ICMP discovery fails")
 	MTUCmd.PersistentFlags().Int("plp-port", 443, "Port for PLPMTUD probes")
 }
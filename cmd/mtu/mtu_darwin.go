@@ -20,7 +20,7 @@ var ioctlGetDarwinIfreqMTU = func(fd int, interfaceName string) (*unix.IfreqMTU,
 	return unix.IoctlGetIfreqMTU(fd, interfaceName)
 }
 
-// getDarwinMTU gets MTU on macOS using ioctl
+// getMTU reads the MTU of an interface on macOS with ioctl SIOCGIFMTU
 func getMTU(interfaceName string) (int, error) {
 	// Open a dummy datagram socket; required for the ioctl.
 	fd, err := openDarwinMTUSocket(unix.AF_INET, unix.SOCK_DGRAM, 0)
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package mtu
+
+import "fmt"
+
+// getMTU is a stub for unsupported platforms, which rely on net.Interface
+func getMTU(interfaceName string) (int, error) {
+	return 0, fmt.Errorf("platform not supported")
+}
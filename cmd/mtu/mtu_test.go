@@ -298,7 +298,7 @@ func TestInterfacesJSONOutput(t *testing.T) {
 		}
 	}
 
-	cmd := exec.Command(binary, "mtu", "interfaces", "--json")
+	cmd := exec.Command(binary, "mtu", "interfaces", "--format", "json")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		t.Fatalf("interfaces command failed: %v, output: %s", err, string(output))
@@ -389,7 +389,7 @@ func TestSuggestJSONOutput(t *testing.T) {
 		}
 	}
 
-	cmd := exec.Command(binary, "mtu", "suggest", "localhost", "--proto", "tcp", "--json")
+	cmd := exec.Command(binary, "mtu", "suggest", "localhost", "--proto", "tcp", "--format", "json")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		t.Fatalf("suggest command failed: %v, output: %s", err, string(output))
@@ -507,7 +507,7 @@ func TestOutputJSON(t *testing.T) {
 	r, w, _ := os.Pipe()
 	os.Stdout = w
 
-	err := outputJSON(result)
+	err := outputStructured(result, formatJSON)
 
 	_ = w.Close()
 	os.Stdout = oldStdout
@@ -517,13 +517,13 @@ func TestOutputJSON(t *testing.T) {
 	output := strings.TrimSpace(buf.String())
 
 	if err != nil {
-		t.Errorf("outputJSON failed: %v", err)
+		t.Errorf("outputStructured failed: %v", err)
 	}
 
 	// Validate JSON structure
 	var parsed MTUResult
 	if err := json.Unmarshal([]byte(output), &parsed); err != nil {
-		t.Errorf("outputJSON produced invalid JSON: %v\nOutput: %s", err, output)
+		t.Errorf("outputStructured produced invalid JSON: %v\nOutput: %s", err, output)
 	}
 
 	if parsed.Target != result.Target {
@@ -557,7 +557,7 @@ func TestOutputHopJSONUsesMilliseconds(t *testing.T) {
 	r, w, _ := os.Pipe()
 	os.Stdout = w
 
-	err := outputHopJSON(result)
+	err := outputHopStructured(result, formatJSON)
 
 	_ = w.Close()
 	os.Stdout = oldStdout
@@ -567,7 +567,7 @@ func TestOutputHopJSONUsesMilliseconds(t *testing.T) {
 	output := strings.TrimSpace(buf.String())
 
 	if err != nil {
-		t.Fatalf("outputHopJSON failed: %v", err)
+		t.Fatalf("outputHopStructured failed: %v", err)
 	}
 
 	var parsed struct {
@@ -579,7 +579,7 @@ func TestOutputHopJSONUsesMilliseconds(t *testing.T) {
 	}
 
 	if err := json.Unmarshal([]byte(output), &parsed); err != nil {
-		t.Fatalf("outputHopJSON produced invalid JSON: %v\nOutput: %s", err, output)
+		t.Fatalf("outputHopStructured produced invalid JSON: %v\nOutput: %s", err, output)
 	}
 
 	if len(parsed.Hops) != 2 {
@@ -658,7 +658,7 @@ func TestNewWatchDropErrorSilencesCobraErrorsForJSON(t *testing.T) {
 	watch := &cobra.Command{
 		Use: "watch",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return newWatchDropError(cmd, 1500, 1400, true)
+			return newWatchDropError(cmd, 1500, 1400, formatJSON)
 		},
 	}
 	root.AddCommand(watch)
@@ -1079,6 +1079,6 @@ func BenchmarkOutputJSON(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_ = outputJSON(result) // Ignore error in benchmark
+		_ = outputStructured(result, formatJSON) // Ignore error in benchmark
 	}
 }
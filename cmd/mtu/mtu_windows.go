@@ -0,0 +1,30 @@
+//go:build windows
+
+package mtu
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// getMTU reads the MTU GetAdaptersAddresses reports for an interface. Go names
+// Windows interfaces by the adapter's friendly name.
+func getMTU(interfaceName string) (int, error) {
+	adapters, err := fetchWindowsAdapters()
+	if err != nil {
+		return 0, fmt.Errorf("GetAdaptersAddresses: %w", err)
+	}
+	for _, adapter := range adapters {
+		if windows.UTF16PtrToString(adapter.FriendlyName) != interfaceName {
+			continue
+		}
+		// The loopback pseudo-interface reports 0xffffffff, and adapters
+		// without an IP stack 0
+		if adapter.Mtu == 0 || adapter.Mtu == ^uint32(0) {
+			return 0, fmt.Errorf("adapter %s reports no usable MTU (%d)", interfaceName, adapter.Mtu)
+		}
+		return int(adapter.Mtu), nil
+	}
+	return 0, fmt.Errorf("interface %s not found", interfaceName)
+}
@@ -0,0 +1,69 @@
+//go:build windows
+
+package mtu
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+// fakeAdapter builds a GetAdaptersAddresses entry
+func fakeAdapter(t *testing.T, name string, mtu uint32) *windows.IpAdapterAddresses {
+	t.Helper()
+	friendly, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &windows.IpAdapterAddresses{FriendlyName: friendly, Mtu: mtu}
+}
+
+func TestGetMTUOnWindowsWithInjectedAdapters(t *testing.T) {
+	original := fetchWindowsAdapters
+	t.Cleanup(func() { fetchWindowsAdapters = original })
+
+	t.Run("propagates GetAdaptersAddresses failures", func(t *testing.T) {
+		fetchWindowsAdapters = func() ([]*windows.IpAdapterAddresses, error) {
+			return nil, errors.New("access denied")
+		}
+		if mtu, err := getMTU("Ethernet"); err == nil || !strings.Contains(err.Error(), "GetAdaptersAddresses: access denied") {
+			t.Fatalf("expected adapter error, got mtu=%d err=%v", mtu, err)
+		}
+	})
+
+	adapters := []*windows.IpAdapterAddresses{
+		fakeAdapter(t, "Loopback Pseudo-Interface 1", ^uint32(0)),
+		fakeAdapter(t, "Ethernet", 1500),
+		fakeAdapter(t, "WireGuard Tunnel", 1420),
+		fakeAdapter(t, "Bluetooth Network Connection", 0),
+	}
+	fetchWindowsAdapters = func() ([]*windows.IpAdapterAddresses, error) { return adapters, nil }
+
+	tests := []struct {
+		name    string
+		want    int
+		wantErr string
+	}{
+		{"Ethernet", 1500, ""},
+		{"WireGuard Tunnel", 1420, ""},
+		{"Loopback Pseudo-Interface 1", 0, "no usable MTU"},
+		{"Bluetooth Network Connection", 0, "no usable MTU"},
+		{"Wi-Fi", 0, "interface Wi-Fi not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mtu, err := getMTU(tt.name)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got mtu=%d err=%v", tt.wantErr, mtu, err)
+				}
+				return
+			}
+			if err != nil || mtu != tt.want {
+				t.Fatalf("expected mtu=%d, got mtu=%d err=%v", tt.want, mtu, err)
+			}
+		})
+	}
+}
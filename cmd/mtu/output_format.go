@@ -0,0 +1,108 @@
+package mtu
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats of --format
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+// outputFormat is how a command prints its results
+type outputFormat string
+
+// readOutputFormat reads --format, with --json standing for --format json
+func readOutputFormat(cmd *cobra.Command) (outputFormat, error) {
+	format, _ := cmd.Flags().GetString("format")
+	if jsonOutput, _ := cmd.Flags().GetBool("json"); jsonOutput {
+		if cmd.Flags().Changed("format") && format != formatJSON {
+			return "", errcode.Errorf(errcode.CLIUsage, "--json is --format json and cannot be combined with --format %s", format)
+		}
+		format = formatJSON
+	}
+	switch format {
+	case "", formatTable:
+		return formatTable, nil
+	case formatJSON, formatYAML:
+		return outputFormat(format), nil
+	}
+	return "", errcode.Errorf(errcode.CLIUnsupportedFormat, "unsupported output format: %s", format)
+}
+
+// structured reports whether results are printed as data rather than as a
+// table for people
+func (f outputFormat) structured() bool {
+	return f == formatJSON || f == formatYAML
+}
+
+// write prints v as one document: indented JSON, or YAML
+func (f outputFormat) write(v any) error {
+	return f.encode(os.Stdout, v, true)
+}
+
+// writeRecord prints v as one record of a stream: a JSON line, or a YAML
+// document starting with ---
+func (f outputFormat) writeRecord(v any) error {
+	return f.encode(os.Stdout, v, false)
+}
+
+func (f outputFormat) encode(w io.Writer, v any, indent bool) error {
+	if f != formatYAML {
+		encoder := json.NewEncoder(w)
+		if indent {
+			encoder.SetIndent("", "  ")
+		}
+		return encoder.Encode(v)
+	}
+
+	node, err := yamlNode(v)
+	if err != nil {
+		return err
+	}
+	if !indent {
+		if _, err := fmt.Fprintln(w, "---"); err != nil {
+			return err
+		}
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// yamlNode converts v to YAML through its JSON encoding, so YAML output has
+// the same field names, omitted fields, and custom encodings as JSON output
+// without a second set of struct tags
+func yamlNode(v any) (*yaml.Node, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+	return &node, nil
+}
+
+// blockStyle drops the flow style and quoting JSON syntax parses as, so the
+// encoder writes ordinary block YAML and quotes only where it must
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
@@ -0,0 +1,114 @@
+package mtu
+
+import (
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// assertGolden compares output with testdata/name, or rewrites it with -update
+func assertGolden(t *testing.T, name, output string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	output += "\n"
+	if *updateGolden {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(output), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("missing golden file (run go test -update): %v", err)
+	}
+	if output != string(want) {
+		t.Errorf("%s mismatch\n--- got\n%s--- want\n%s", name, output, want)
+	}
+}
+
+func TestReadOutputFormat(t *testing.T) {
+	tests := []struct {
+		flags map[string]string
+		want  outputFormat
+		code  errcode.Code
+	}{
+		{nil, formatTable, ""},
+		{map[string]string{"format": "yaml"}, formatYAML, ""},
+		{map[string]string{"json": "true"}, formatJSON, ""},
+		{map[string]string{"json": "true", "format": "json"}, formatJSON, ""},
+		{map[string]string{"json": "true", "format": "yaml"}, "", errcode.CLIUsage},
+		{map[string]string{"format": "xml"}, "", errcode.CLIUnsupportedFormat},
+	}
+	for _, tt := range tests {
+		cmd := &cobra.Command{Use: "test"}
+		cmd.Flags().String("format", formatTable, "")
+		cmd.Flags().Bool("json", false, "")
+		for name, value := range tt.flags {
+			mustSetFlag(t, cmd, name, value)
+		}
+		got, err := readOutputFormat(cmd)
+		if (err == nil) != (tt.code == "") || (err != nil && errcode.Of(err) != tt.code) || got != tt.want {
+			t.Errorf("%v: readOutputFormat = %q, %v, want %q, %s", tt.flags, got, err, tt.want, tt.code)
+		}
+	}
+}
+
+func TestStructuredOutputGolden(t *testing.T) {
+	// Strings needing escapes in both formats
+	result := &MTUResult{
+		Target:    `odd"name\.example`,
+		Protocol:  "tcp",
+		PMTU:      1492,
+		MSS:       1452,
+		Hops:      7,
+		ElapsedMS: 312,
+		RTTMS:     12.5,
+		Warnings:  []string{"ICMP rate limited: 3 probes lost", "yes: no\n# not a comment"},
+	}
+	hops := &HopMTUResult{
+		Target:       "192.0.2.1",
+		Protocol:     "icmp",
+		MaxProbeSize: 1500,
+		FinalPMTU:    1400,
+		Hops: []*HopInfo{
+			{Hop: 1, Addr: net.ParseIP("198.51.100.1"), MTU: 1500, RTT: 1500 * time.Microsecond, Name: "gw.example", ASN: 64500, ASHolder: "EXAMPLE-AS"},
+			{Hop: 2, Timeout: true},
+			{Hop: 3, Addr: net.ParseIP("192.0.2.1"), MTU: 1400, RTT: 20 * time.Millisecond},
+		},
+		ElapsedMS: 4100,
+	}
+	pmtu := suggestionPMTU{Target: "example.com", PMTU: 1500, Source: pmtuMeasured, Protocol: "tcp"}
+	timestamp := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+
+	for _, format := range []outputFormat{formatJSON, formatYAML} {
+		outputs := map[string]func() error{
+			"discover": func() error { return outputStructured(result, format) },
+			"hops":     func() error { return outputHopStructured(hops, format) },
+			"suggest":  func() error { return outputSuggestionsStructured(pmtu, calculateSuggestions(1500), format) },
+			"watch": func() error {
+				if err := outputWatchResult(timestamp, result, true, false, format); err != nil {
+					return err
+				}
+				return outputWatchError(timestamp, "192.0.2.1", errcode.Errorf(errcode.MTUTimeout, "probe timed out"), format)
+			},
+		}
+		for name, output := range outputs {
+			got, err := captureStdout(t, output)
+			if err != nil {
+				t.Fatalf("%s %s: %v", name, format, err)
+			}
+			assertGolden(t, name+"."+string(format)+".golden", got)
+		}
+	}
+}
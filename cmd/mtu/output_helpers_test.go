@@ -39,7 +39,7 @@ func captureStdout(t *testing.T, fn func() error) (string, error) {
 
 func TestWriteJSONLine(t *testing.T) {
 	output, err := captureStdout(t, func() error {
-		return writeJSONLine(map[string]any{
+		return outputFormat(formatJSON).writeRecord(map[string]any{
 			"target": "example.com",
 			"pmtu":   1500,
 		})
@@ -66,21 +66,22 @@ func TestOutputSuggestionsHelpers(t *testing.T) {
 	suggestions := calculateSuggestions(1500)
 
 	jsonOutput, err := captureStdout(t, func() error {
-		return outputSuggestionsJSON("example.com", 1500, suggestions)
+		return outputSuggestionsStructured(suggestionPMTU{Target: "example.com", PMTU: 1500, Source: pmtuMeasured, Protocol: "tcp"}, suggestions, formatJSON)
 	})
 	if err != nil {
-		t.Fatalf("outputSuggestionsJSON returned error: %v", err)
+		t.Fatalf("outputSuggestionsStructured returned error: %v", err)
 	}
 
 	var jsonResult struct {
 		Target      string      `json:"target"`
 		PMTU        int         `json:"pmtu"`
+		PMTUSource  string      `json:"pmtu_source"`
 		Suggestions Suggestions `json:"suggestions"`
 	}
 	if err := json.Unmarshal([]byte(jsonOutput), &jsonResult); err != nil {
-		t.Fatalf("outputSuggestionsJSON produced invalid JSON: %v", err)
+		t.Fatalf("outputSuggestionsStructured produced invalid JSON: %v", err)
 	}
-	if jsonResult.Target != "example.com" || jsonResult.PMTU != 1500 {
+	if jsonResult.Target != "example.com" || jsonResult.PMTU != 1500 || jsonResult.PMTUSource != pmtuMeasured {
 		t.Fatalf("unexpected suggestion JSON payload: %+v", jsonResult)
 	}
 	if jsonResult.Suggestions.TCPMSSv4 != 1460 {
@@ -88,14 +89,14 @@ func TestOutputSuggestionsHelpers(t *testing.T) {
 	}
 
 	tableOutput, err := captureStdout(t, func() error {
-		return outputSuggestionsTable("example.com", 1500, suggestions)
+		return outputSuggestionsTable(suggestionPMTU{Target: "example.com", PMTU: 1500, Source: pmtuMeasured, Protocol: "tcp"}, suggestions)
 	})
 	if err != nil {
 		t.Fatalf("outputSuggestionsTable returned error: %v", err)
 	}
 
 	expectedLines := []string{
-		"Suggestions for example.com (PMTU: 1500):",
+		"Suggestions for example.com (PMTU: 1500, measured over tcp):",
 		"TCP MSS (IPv4):              1460",
 		"WireGuard payload:           1440",
 		"VXLAN payload:               1450",
@@ -159,10 +160,10 @@ func TestWatchJSONHelpers(t *testing.T) {
 	timestamp := time.Date(2026, time.April, 18, 12, 30, 0, 0, time.UTC)
 
 	errorOutput, err := captureStdout(t, func() error {
-		return outputWatchErrorJSON(timestamp, "example.com", fmt.Errorf("timeout"))
+		return outputWatchError(timestamp, "example.com", fmt.Errorf("timeout"), formatJSON)
 	})
 	if err != nil {
-		t.Fatalf("outputWatchErrorJSON returned error: %v", err)
+		t.Fatalf("outputWatchError returned error: %v", err)
 	}
 
 	var errorResult struct {
@@ -171,21 +172,21 @@ func TestWatchJSONHelpers(t *testing.T) {
 		Error     string `json:"error"`
 	}
 	if err := json.Unmarshal([]byte(errorOutput), &errorResult); err != nil {
-		t.Fatalf("outputWatchErrorJSON produced invalid JSON: %v", err)
+		t.Fatalf("outputWatchError produced invalid JSON: %v", err)
 	}
 	if errorResult.Target != "example.com" || errorResult.Error != "timeout" {
 		t.Fatalf("unexpected watch error JSON payload: %+v", errorResult)
 	}
 
 	watchOutput, err := captureStdout(t, func() error {
-		return outputWatchResultJSON(timestamp, &MTUResult{
+		return outputWatchResult(timestamp, &MTUResult{
 			Target: "example.com",
 			PMTU:   1480,
 			MSS:    1440,
-		}, true, false)
+		}, true, false, formatJSON)
 	})
 	if err != nil {
-		t.Fatalf("outputWatchResultJSON returned error: %v", err)
+		t.Fatalf("outputWatchResult returned error: %v", err)
 	}
 
 	var watchResult struct {
@@ -196,7 +197,7 @@ func TestWatchJSONHelpers(t *testing.T) {
 		MSSChanged bool   `json:"mss_changed"`
 	}
 	if err := json.Unmarshal([]byte(watchOutput), &watchResult); err != nil {
-		t.Fatalf("outputWatchResultJSON produced invalid JSON: %v", err)
+		t.Fatalf("outputWatchResult produced invalid JSON: %v", err)
 	}
 	if watchResult.Target != "example.com" || !watchResult.Changed || watchResult.MSSChanged {
 		t.Fatalf("unexpected watch result JSON payload: %+v", watchResult)
@@ -206,7 +207,7 @@ func TestWatchJSONHelpers(t *testing.T) {
 func TestNewWatchDropErrorPreservesPlainTextErrors(t *testing.T) {
 	cmd := &cobra.Command{Use: "watch"}
 
-	err := newWatchDropError(cmd, 1500, 1400, false)
+	err := newWatchDropError(cmd, 1500, 1400, formatTable)
 	if err == nil {
 		t.Fatal("expected drop error")
 	}
@@ -5,9 +5,12 @@ import (
 	"context"
 	"errors"
 	"net"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/euan-cowie/cidrator/internal/audit"
 )
 
 type fakeListener struct {
@@ -236,4 +239,36 @@ func TestRunPeerWithRuntime(t *testing.T) {
 			t.Fatalf("runPeerWithRuntime returned error: %v", err)
 		}
 	})
+	t.Run("the listen address is audited before anything is opened", func(t *testing.T) {
+		auditPath := filepath.Join(t.TempDir(), "audit.log")
+		audit.SetPath(auditPath)
+		t.Cleanup(func() { audit.SetPath("") })
+
+		cmd := newPeerCommandForTest()
+		mustSetFlag(t, cmd, "proto", "udp")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		err := runPeerWithRuntime(cmd, peerRuntime{
+			newContext: func() (context.Context, context.CancelFunc) {
+				return ctx, cancel
+			},
+			openUDP: func(listenAddr string, port int) (peerUDPConn, error) {
+				return nil, errors.New("udp open failed")
+			},
+		})
+		if err == nil {
+			t.Fatal("expected the UDP open failure")
+		}
+
+		entries, err := audit.ReadAll(auditPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].Command != "peer" || entries[0].Protocol != "udp" ||
+			len(entries[0].Targets) != 1 || !strings.HasSuffix(entries[0].Targets[0], ":4821") {
+			t.Fatalf("unexpected audit entries: %+v", entries)
+		}
+	})
 }
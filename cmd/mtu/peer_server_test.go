@@ -205,7 +205,7 @@ func newPeerCommandForTest() *cobra.Command {
 	flags := cmd.Flags()
 	flags.Int("port", defaultPeerPort, "")
 	flags.String("proto", "udp,tcp", "")
-	flags.String("listen", defaultPeerListenAddress, "")
+	flags.String("listen", "", "")
 	flags.Bool("allow-remote", false, "")
 	flags.Int("max-packet-size", defaultPeerMaxPacketSize, "")
 	flags.Int("response-pps", defaultPeerResponsePPS, "")
@@ -4,22 +4,65 @@ import (
 	"context"
 	"fmt"
 	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// PLPMTUD states, as named in RFC 8899 section 5.2
+const (
+	plpStateDisabled       = "DISABLED"
+	plpStateBase           = "BASE"
+	plpStateSearching      = "SEARCHING"
+	plpStateSearchComplete = "SEARCH_COMPLETE"
+	plpStateError          = "ERROR"
+)
+
+const (
+	// plpBasePLPMTU is the size the search confirms first. RFC 8899
+	// recommends 1200 bytes, which nearly every path carries.
+	plpBasePLPMTU = 1200
+
+	// plpMaxProbes is MAX_PROBES: a size counts as lost once this many
+	// probes in a row go unanswered
+	plpMaxProbes = 3
+
+	// plpConfirmTimer is how long SEARCH_COMPLETE waits before checking the
+	// PLPMTU still gets through, which is how a black hole is detected
+	plpConfirmTimer = 500 * time.Millisecond
+
+	// plpMaxBlackHoles is how many black holes the search restarts from BASE
+	// after before giving up on the path
+	plpMaxBlackHoles = 1
 )
 
 // PLPMTUDOptions contains options for PLPMTUD fallback
 type PLPMTUDOptions struct {
-	PLPPort     int
-	MaxProbes   int
-	StepSize    int
-	BaseTimeout time.Duration
+	PLPPort      int
+	MaxProbes    int                 // MAX_PROBES (0 = plpMaxProbes)
+	BaseTimeout  time.Duration       // PROBE_TIMER: how long each probe waits for its answer
+	ConfirmTimer time.Duration       // Wait before the black-hole check (0 = plpConfirmTimer)
+	SourcePorts  *SourcePortSelector // nil lets the kernel pick each probe's source port
 }
 
-// PLPMTUDProber implements RFC 4821 style PLPMTUD
+// PLPMTUDTransition is one state change of the search, as shown by
+// --verbose and in JSON output
+type PLPMTUDTransition struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	PLPMTU    int    `json:"plpmtu"` // Largest confirmed size on entering To (0 = none)
+	Reason    string `json:"reason"`
+	ElapsedMS int    `json:"elapsed_ms"`
+}
+
+// PLPMTUDProber implements the RFC 8899 / RFC 4821 PLPMTUD state machine
+// over UDP probes that must be echoed back
 type PLPMTUDProber struct {
 	target   string
 	ipv6     bool
 	options  PLPMTUDOptions
 	probeUDP func(ctx context.Context, size int) bool
+	udp      *UDPProber              // Sends the probes, over one socket for the whole search
+	onChange func(PLPMTUDTransition) // Called as each transition happens
 }
 
 // NewPLPMTUDProber creates a new PLPMTUD prober
@@ -31,82 +74,151 @@ func NewPLPMTUDProber(target string, ipv6 bool, options PLPMTUDOptions) *PLPMTUD
 	}
 }
 
-// DiscoverPMTUWithPLPMTUD performs PLPMTUD-style MTU discovery
-// This is used as a fallback when ICMP is filtered/blocked
-func (p *PLPMTUDProber) DiscoverPMTUWithPLPMTUD(ctx context.Context, minMTU, maxMTU int) (*MTUResult, error) {
-	start := time.Now()
+// plpSearch is the state of one PLPMTUD run
+type plpSearch struct {
+	prober      *PLPMTUDProber
+	start       time.Time
+	state       string
+	plpmtu      int // Largest size confirmed so far (0 = none)
+	low, high   int // Sizes still to search in SEARCHING
+	upper       int // Largest size the path may carry; lowered by a black hole
+	blackHoles  int
+	transitions []PLPMTUDTransition
+}
 
-	stepSize := p.options.StepSize
-	if stepSize <= 0 {
-		stepSize = 64
+// enter moves the search to state, recording why
+func (s *plpSearch) enter(state, format string, args ...any) {
+	transition := PLPMTUDTransition{
+		From:      s.state,
+		To:        state,
+		PLPMTU:    s.plpmtu,
+		Reason:    fmt.Sprintf(format, args...),
+		ElapsedMS: int(time.Since(s.start).Milliseconds()),
+	}
+	s.transitions = append(s.transitions, transition)
+	s.state = state
+	if s.prober.onChange != nil {
+		s.prober.onChange(transition)
 	}
+}
 
-	confirmedMTU := 0
-	firstFailedMTU := maxMTU + 1
+// DiscoverPMTUWithPLPMTUD runs the PLPMTUD state machine from BASE until it
+// reaches SEARCH_COMPLETE with a PLPMTU that survives the black-hole check.
+// BASE confirms BASE_PLPMTU, or --min when that is larger; if it is lost the
+// search moves to ERROR and falls back to --min, the MIN_PLPMTU. SEARCHING
+// raises the PLPMTU with each confirmed probe, binary searching up to --max.
+// This is used as a fallback when ICMP is filtered/blocked.
+func (p *PLPMTUDProber) DiscoverPMTUWithPLPMTUD(ctx context.Context, minMTU, maxMTU int) (*MTUResult, error) {
+	s := &plpSearch{prober: p, start: time.Now(), state: plpStateDisabled, upper: maxMTU}
+	defer p.closeUDP()
+	s.enter(plpStateBase, "search started")
 
-	// Coarse sweep to find the first failing region.
-	for size := minMTU; size <= maxMTU; size += stepSize {
-		success, err := p.confirmPacketSize(ctx, size)
-		if err != nil {
-			return nil, err
-		}
-		if success {
-			confirmedMTU = size
-		} else {
-			firstFailedMTU = size
-			break
-		}
-		if err := waitForNextPLPProbe(ctx); err != nil {
-			return nil, err
-		}
-	}
+	for {
+		switch s.state {
+		case plpStateBase:
+			base := min(max(plpBasePLPMTU, minMTU), s.upper)
+			ok, err := p.confirmPacketSize(ctx, base)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				s.enter(plpStateError, "BASE_PLPMTU %d lost %d probes in a row", base, p.maxProbes())
+				continue
+			}
+			s.plpmtu, s.low, s.high = base, base+1, s.upper
+			s.enter(plpStateSearching, "BASE_PLPMTU %d confirmed", base)
 
-	if confirmedMTU == 0 {
-		return nil, fmt.Errorf("no working PLPMTUD size found in range %d-%d", minMTU, maxMTU)
-	}
+		case plpStateError:
+			base := min(max(plpBasePLPMTU, minMTU), s.upper)
+			if base <= minMTU {
+				return nil, errcode.Errorf(errcode.MTUNoWorkingSize, "no working PLPMTUD size found in range %d-%d", minMTU, s.upper)
+			}
+			ok, err := p.confirmPacketSize(ctx, minMTU)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, errcode.Errorf(errcode.MTUNoWorkingSize, "no working PLPMTUD size found in range %d-%d", minMTU, s.upper)
+			}
+			s.plpmtu, s.low, s.high = minMTU, minMTU+1, base-1
+			s.enter(plpStateSearching, "MIN_PLPMTU %d confirmed", minMTU)
 
-	refineUpperBound := maxMTU
-	if firstFailedMTU <= maxMTU {
-		refineUpperBound = firstFailedMTU - 1
-	}
+		case plpStateSearching:
+			if s.low > s.high {
+				s.enter(plpStateSearchComplete, "no larger size left to probe")
+				continue
+			}
+			size := s.low + (s.high-s.low)/2
+			ok, err := p.confirmPacketSize(ctx, size)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				s.plpmtu, s.low = size, size+1
+			} else {
+				s.high = size - 1
+			}
 
-	// Refine inside the last successful coarse bucket so PLPMTUD returns an exact PMTU.
-	low := confirmedMTU + 1
-	high := refineUpperBound
-	for low <= high {
-		mid := low + (high-low)/2
-		success, err := p.confirmPacketSize(ctx, mid)
-		if err != nil {
-			return nil, err
-		}
-		if success {
-			confirmedMTU = mid
-			low = mid + 1
-		} else {
-			high = mid - 1
+		case plpStateSearchComplete:
+			if err := waitForPLPConfirmation(ctx, p.confirmTimer()); err != nil {
+				return nil, err
+			}
+			ok, err := p.confirmPacketSize(ctx, s.plpmtu)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return s.result(), nil
+			}
+			s.blackHoles++
+			if s.blackHoles > plpMaxBlackHoles || s.plpmtu <= minMTU {
+				return nil, errcode.Errorf(errcode.MTUNoWorkingSize, "PLPMTU %d stopped getting through after it was confirmed, and the path kept dropping probes", s.plpmtu)
+			}
+			// Lower the PLPMTU: start again from BASE, below the size that
+			// went dark
+			lost := s.plpmtu
+			s.upper, s.plpmtu = lost-1, 0
+			s.enter(plpStateBase, "black hole: PLPMTU %d lost %d probes in a row", lost, p.maxProbes())
 		}
 	}
+}
 
-	elapsed := time.Since(start)
-
+// result reports the confirmed PLPMTU and how the search got there
+func (s *plpSearch) result() *MTUResult {
+	sourceAddr := ""
+	if s.prober.udp != nil {
+		sourceAddr = s.prober.udp.source.String()
+	}
 	return &MTUResult{
-		Target:    p.target,
-		Protocol:  "plpmtud",
-		PMTU:      confirmedMTU,
-		MSS:       tcpMSSForMTU(confirmedMTU, p.ipv6),
-		Hops:      0, // Not applicable for PLPMTUD
-		ElapsedMS: int(elapsed.Milliseconds()),
-	}, nil
+		Target:     s.prober.target,
+		Protocol:   "plpmtud",
+		PMTU:       s.plpmtu,
+		MSS:        tcpMSSForMTU(s.plpmtu, s.prober.ipv6),
+		Hops:       0, // Not applicable for PLPMTUD
+		ElapsedMS:  int(time.Since(s.start).Milliseconds()),
+		PLPMTUD:    s.transitions,
+		SourceAddr: sourceAddr,
+	}
 }
 
-func (p *PLPMTUDProber) confirmPacketSize(ctx context.Context, size int) (bool, error) {
-	maxProbes := p.options.MaxProbes
-	if maxProbes <= 0 {
-		maxProbes = 1
+func (p *PLPMTUDProber) maxProbes() int {
+	if p.options.MaxProbes <= 0 {
+		return plpMaxProbes
+	}
+	return p.options.MaxProbes
+}
+
+func (p *PLPMTUDProber) confirmTimer() time.Duration {
+	if p.options.ConfirmTimer <= 0 {
+		return plpConfirmTimer
 	}
+	return p.options.ConfirmTimer
+}
 
-	successCount := 0
-	for attempt := 0; attempt < maxProbes; attempt++ {
+// confirmPacketSize sends probes of size until one is echoed back, which
+// confirms it, or MAX_PROBES in a row are lost
+func (p *PLPMTUDProber) confirmPacketSize(ctx context.Context, size int) (bool, error) {
+	for attempt := 0; attempt < p.maxProbes(); attempt++ {
 		select {
 		case <-ctx.Done():
 			return false, ctx.Err()
@@ -114,15 +226,14 @@ func (p *PLPMTUDProber) confirmPacketSize(ctx context.Context, size int) (bool,
 		}
 
 		if p.testPacketSize(ctx, size) {
-			successCount++
+			return true, nil
 		}
 	}
-
-	return successCount > maxProbes/2, nil
+	return false, nil
 }
 
-func waitForNextPLPProbe(ctx context.Context) error {
-	timer := time.NewTimer(100 * time.Millisecond)
+func waitForPLPConfirmation(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
 	defer timer.Stop()
 
 	select {
@@ -139,15 +250,27 @@ func (p *PLPMTUDProber) testPacketSize(ctx context.Context, size int) bool {
 		return p.probeUDP(ctx, size)
 	}
 
-	prober, err := NewUDPProber(p.target, p.ipv6, p.options.PLPPort, p.options.BaseTimeout)
-	if err != nil {
-		return false
+	if p.udp == nil {
+		prober, err := NewUDPProber(p.target, p.ipv6, p.options.PLPPort, p.options.BaseTimeout)
+		if err != nil {
+			return false
+		}
+		prober.SetSourcePorts(p.options.SourcePorts)
+		p.udp = prober
 	}
 
-	result := prober.ProbeUDP(ctx, size)
+	result := p.udp.ProbeUDP(ctx, size)
 	return result.Success
 }
 
+// closeUDP releases the socket the search probed over
+func (p *PLPMTUDProber) closeUDP() {
+	if p.udp != nil {
+		_ = p.udp.Close()
+		p.udp = nil
+	}
+}
+
 // WithPLPMTUDFallback modifies MTU discovery to use PLPMTUD when ICMP fails
 func (d *MTUDiscoverer) WithPLPMTUDFallback(ctx context.Context, minMTU, maxMTU int, plpPort int) (*MTUResult, error) {
 	// First try normal ICMP discovery
@@ -157,14 +280,17 @@ func (d *MTUDiscoverer) WithPLPMTUDFallback(ctx context.Context, minMTU, maxMTU
 	}
 
 	// If ICMP failed, fall back to PLPMTUD
+	d.warningf("ICMP discovery failed (%v), falling back to PLPMTUD on port %d", err, plpPort)
 	options := PLPMTUDOptions{
 		PLPPort:     plpPort,
-		MaxProbes:   3,
-		StepSize:    64, // Conservative step size
 		BaseTimeout: d.timeout,
+		SourcePorts: d.sourcePorts,
 	}
 
 	plpProber := NewPLPMTUDProber(d.target, d.ipv6, options)
+	plpProber.onChange = func(t PLPMTUDTransition) {
+		d.emit(ProgressEvent{Kind: ProgressPLPMTUD, Transition: &t})
+	}
 
 	// Try PLPMTUD fallback
 	plpResult, plpErr := plpProber.DiscoverPMTUWithPLPMTUD(ctx, minMTU, maxMTU)
@@ -173,5 +299,5 @@ func (d *MTUDiscoverer) WithPLPMTUDFallback(ctx context.Context, minMTU, maxMTU
 	}
 
 	// Both methods failed
-	return nil, fmt.Errorf("both ICMP and PLPMTUD discovery failed: icmp_error=%v, plpmtud_error=%v", err, plpErr)
+	return nil, errcode.Errorf(errcode.MTUNoWorkingSize, "both ICMP and PLPMTUD discovery failed: icmp_error=%v, plpmtud_error=%v", err, plpErr)
 }
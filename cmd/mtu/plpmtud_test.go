@@ -2,20 +2,38 @@ package mtu
 
 import (
 	"context"
+	"slices"
 	"testing"
 	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
 )
 
-func TestPLPMTUDRefinesToExactPMTU(t *testing.T) {
+// newTestPLPMTUDProber probes a fake path through carries, which is told the
+// size of each probe
+func newTestPLPMTUDProber(carries func(size int) bool) *PLPMTUDProber {
 	prober := NewPLPMTUDProber("example.com", false, PLPMTUDOptions{
-		PLPPort:     4821,
-		MaxProbes:   3,
-		StepSize:    64,
-		BaseTimeout: 50 * time.Millisecond,
+		PLPPort:      4821,
+		BaseTimeout:  50 * time.Millisecond,
+		ConfirmTimer: time.Millisecond,
 	})
 	prober.probeUDP = func(_ context.Context, size int) bool {
-		return size <= 1400
+		return carries(size)
+	}
+	return prober
+}
+
+// states lists the state entered by each transition
+func states(transitions []PLPMTUDTransition) []string {
+	var entered []string
+	for _, transition := range transitions {
+		entered = append(entered, transition.To)
 	}
+	return entered
+}
+
+func TestPLPMTUDRefinesToExactPMTU(t *testing.T) {
+	prober := newTestPLPMTUDProber(func(size int) bool { return size <= 1400 })
 
 	result, err := prober.DiscoverPMTUWithPLPMTUD(context.Background(), 576, 1500)
 	if err != nil {
@@ -31,21 +49,93 @@ func TestPLPMTUDRefinesToExactPMTU(t *testing.T) {
 	if result.MSS != 1360 {
 		t.Fatalf("MSS = %d, want 1360", result.MSS)
 	}
+	want := []string{plpStateBase, plpStateSearching, plpStateSearchComplete}
+	if got := states(result.PLPMTUD); !slices.Equal(got, want) {
+		t.Fatalf("states = %v, want %v", got, want)
+	}
+	if first := result.PLPMTUD[0]; first.From != plpStateDisabled {
+		t.Fatalf("first transition = %+v, want it to leave DISABLED", first)
+	}
+	if last := result.PLPMTUD[2]; last.PLPMTU != 1400 {
+		t.Fatalf("SEARCH_COMPLETE entered with PLPMTU %d, want 1400", last.PLPMTU)
+	}
 }
 
-func TestPLPMTUDReturnsErrorWhenNoSizeWorks(t *testing.T) {
-	prober := NewPLPMTUDProber("example.com", false, PLPMTUDOptions{
-		PLPPort:     4821,
-		MaxProbes:   3,
-		StepSize:    64,
-		BaseTimeout: 50 * time.Millisecond,
+func TestPLPMTUDFallsBackToMinWhenBaseIsLost(t *testing.T) {
+	prober := newTestPLPMTUDProber(func(size int) bool { return size <= 1000 })
+
+	result, err := prober.DiscoverPMTUWithPLPMTUD(context.Background(), 576, 1500)
+	if err != nil {
+		t.Fatalf("DiscoverPMTUWithPLPMTUD returned error: %v", err)
+	}
+	if result.PMTU != 1000 {
+		t.Fatalf("PMTU = %d, want 1000", result.PMTU)
+	}
+	want := []string{plpStateBase, plpStateError, plpStateSearching, plpStateSearchComplete}
+	if got := states(result.PLPMTUD); !slices.Equal(got, want) {
+		t.Fatalf("states = %v, want %v", got, want)
+	}
+}
+
+func TestPLPMTUDConfirmsDespiteLoss(t *testing.T) {
+	// Every other probe is lost, but one answer within MAX_PROBES confirms a size
+	var sent int
+	prober := newTestPLPMTUDProber(func(size int) bool {
+		sent++
+		return sent%2 == 0 && size <= 1400
 	})
-	prober.probeUDP = func(_ context.Context, size int) bool {
-		return false
+
+	result, err := prober.DiscoverPMTUWithPLPMTUD(context.Background(), 576, 1500)
+	if err != nil {
+		t.Fatalf("DiscoverPMTUWithPLPMTUD returned error: %v", err)
+	}
+	if result.PMTU != 1400 {
+		t.Fatalf("PMTU = %d, want 1400", result.PMTU)
 	}
+}
+
+func TestPLPMTUDLowersAfterBlackHole(t *testing.T) {
+	// The path shrinks to 1300 once the first search completes
+	limit := 1400
+	prober := newTestPLPMTUDProber(func(size int) bool { return size <= limit })
+	prober.onChange = func(transition PLPMTUDTransition) {
+		if transition.To == plpStateSearchComplete {
+			limit = 1300
+		}
+	}
+
+	result, err := prober.DiscoverPMTUWithPLPMTUD(context.Background(), 576, 1500)
+	if err != nil {
+		t.Fatalf("DiscoverPMTUWithPLPMTUD returned error: %v", err)
+	}
+	if result.PMTU != 1300 {
+		t.Fatalf("PMTU = %d, want 1300", result.PMTU)
+	}
+	want := []string{plpStateBase, plpStateSearching, plpStateSearchComplete, plpStateBase, plpStateSearching, plpStateSearchComplete}
+	if got := states(result.PLPMTUD); !slices.Equal(got, want) {
+		t.Fatalf("states = %v, want %v", got, want)
+	}
+	if blackHole := result.PLPMTUD[3]; blackHole.From != plpStateSearchComplete || blackHole.PLPMTU != 0 {
+		t.Fatalf("black-hole transition = %+v, want SEARCH_COMPLETE -> BASE with the PLPMTU dropped", blackHole)
+	}
+
+	// A path that keeps shrinking is given up on
+	limit = 1400
+	prober.onChange = func(transition PLPMTUDTransition) {
+		if transition.To == plpStateSearchComplete {
+			limit -= 100
+		}
+	}
+	if _, err := prober.DiscoverPMTUWithPLPMTUD(context.Background(), 576, 1500); errcode.Of(err) != errcode.MTUNoWorkingSize {
+		t.Fatalf("error = %v, want %s", err, errcode.MTUNoWorkingSize)
+	}
+}
+
+func TestPLPMTUDReturnsErrorWhenNoSizeWorks(t *testing.T) {
+	prober := newTestPLPMTUDProber(func(size int) bool { return false })
 
 	_, err := prober.DiscoverPMTUWithPLPMTUD(context.Background(), 576, 1500)
-	if err == nil {
-		t.Fatal("DiscoverPMTUWithPLPMTUD returned nil error, want failure")
+	if errcode.Of(err) != errcode.MTUNoWorkingSize {
+		t.Fatalf("error = %v, want %s", err, errcode.MTUNoWorkingSize)
 	}
 }
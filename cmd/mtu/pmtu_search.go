@@ -0,0 +1,482 @@
+package mtu
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// commonPMTUs are the path MTUs most discoveries end at, in ascending order:
+// IPsec and other tunnels (1380, 1400, 1436), PPPoE with and without extra
+// encapsulation (1452, 1472, 1492), plain Ethernet, and jumbo frames
+var commonPMTUs = []int{1380, 1400, 1436, 1452, 1472, 1492, 1500, 9000}
+
+// probeFunc sends one probe of the given size
+type probeFunc func(ctx context.Context, size int) *ProbeResult
+
+// burstFunc sends one probe per size without waiting between them and returns
+// the results in the same order as sizes
+type burstFunc func(ctx context.Context, sizes []int) []*ProbeResult
+
+// pmtuSearch is the outcome of a search before it becomes an MTUResult
+type pmtuSearch struct {
+	PMTU       int
+	RTT        time.Duration // RTT of the probe that proved PMTU
+	Probes     int
+	Confidence *ProbeConfidence // Set when the search resent unanswered sizes
+
+	History                []ProbeSizeStats // Every probe sent, by size
+	RTTMin, RTTAvg, RTTMax time.Duration    // Over every answered probe
+}
+
+// commonMTUCandidates returns the common PMTUs inside [minMTU, maxMTU]
+func commonMTUCandidates(minMTU, maxMTU int) []int {
+	var candidates []int
+	for _, size := range commonPMTUs {
+		if size >= minMTU && size <= maxMTU {
+			candidates = append(candidates, size)
+		}
+	}
+	return candidates
+}
+
+// maxParallelProbes bounds --parallel, keeping each round's burst small
+// enough that a path's ICMP rate limit does not drop the replies
+const maxParallelProbes = 16
+
+// maxProbeRetries bounds --retries; the RetryThrottler's backoff doubles
+// with each resend, so more would mostly add waiting
+const maxProbeRetries = 5
+
+// retryBaseDelay is the RetryThrottler's backoff before the second resend
+// of a size; the first goes out at once, since the lost probe has already
+// waited out its timeout. Tests shorten it.
+var retryBaseDelay = 100 * time.Millisecond
+
+// searchPlan is how searchPMTU uses its burst function and how persistent it
+// is with sizes that draw no answer
+type searchPlan struct {
+	CommonFirst bool // Burst the common PMTUs before the search
+	Parallel    int  // Sizes in flight per search round; 1 or less is a binary search
+	Retries     int  // Resends of a size that goes unanswered before it counts as too big
+}
+
+// lowConfidenceScore is the ProbeConfidence score below which discovery
+// warns that loss may have made the PMTU an underestimate
+const lowConfidenceScore = 0.95
+
+// ProbeConfidence tells a clean result from one measured through loss. It
+// is reported when --retries is set, since a search that never resends a
+// size cannot see loss.
+type ProbeConfidence struct {
+	Retries  int     `json:"retries"`   // Resends allowed per unanswered size
+	Resent   int     `json:"resent"`    // Probes sent again after going unanswered
+	LossRate float64 `json:"loss_rate"` // Share of tries at sizes that got through that went unanswered
+	Score    float64 `json:"score"`     // Chance, 0-1, that no size was ruled out by loss alone
+}
+
+// searchPMTU finds the largest size in [minMTU, maxMTU] that gets through.
+// With plan.CommonFirst it first bursts the common PMTUs and only searches
+// what they leave unresolved. With plan.Parallel above 1 each round bursts
+// that many sizes spread over the range, so a round costs one timeout
+// instead of one per failed size.
+func searchPMTU(ctx context.Context, minMTU, maxMTU int, probe probeFunc, burst burstFunc, plan searchPlan) (pmtuSearch, error) {
+	history := &probeHistory{}
+	probe, burst = history.recording(probe), history.recordingBurst(burst)
+	var tally *probeTally
+	if plan.Retries > 0 {
+		tally = &probeTally{retries: plan.Retries}
+		probe, burst = tally.retrying(probe), tally.retryingBurst(burst)
+	}
+
+	low, high := minMTU, maxMTU
+	var search pmtuSearch
+	if plan.CommonFirst {
+		fast := commonMTUFastPath(ctx, minMTU, maxMTU, probe, burst)
+		if err := ctx.Err(); err != nil {
+			return pmtuSearch{}, err
+		}
+		if fast.Confirmed {
+			return history.finish(tally.finish(fast.pmtuSearch)), nil
+		}
+		search = fast.pmtuSearch
+		low, high = fast.Low, fast.High
+	}
+
+	for low <= high {
+		select {
+		case <-ctx.Done():
+			return pmtuSearch{}, ctx.Err()
+		default:
+		}
+
+		if plan.Parallel > 1 {
+			sizes := searchRoundSizes(low, high, plan.Parallel)
+			results := burst(ctx, sizes)
+			if err := ctx.Err(); err != nil {
+				// An interrupted burst reads as every size failing
+				return pmtuSearch{}, err
+			}
+			search.Probes += len(sizes)
+			low, high = narrowSearch(&search, sizes, results, low, high)
+			continue
+		}
+
+		mid := (low + high) / 2
+		result := probe(ctx, mid)
+		search.Probes++
+
+		if result.Success {
+			search.PMTU = mid
+			search.RTT = result.RTT
+			low = mid + 1
+		} else {
+			// Fragmentation needed, timeouts, and other errors all mean smaller
+			high = mid - 1
+		}
+	}
+
+	if search.PMTU == 0 {
+		return pmtuSearch{}, fmt.Errorf("%w in range %d-%d", errNoWorkingMTU, minMTU, maxMTU)
+	}
+	return history.finish(tally.finish(search)), nil
+}
+
+// probeTally resends sizes that go unanswered and counts what it saw
+type probeTally struct {
+	retries  int
+	resent   int // Probes sent again after going unanswered
+	lost     int // Unanswered tries at sizes that got through on a later one
+	answered int // Sizes that got through
+	silent   int // Sizes given up on after every try went unanswered
+}
+
+// unanswered reports whether a probe drew no reply at all. An ICMP error
+// such as Fragmentation Needed says the size is too big, so it is final.
+func unanswered(result *ProbeResult) bool {
+	return !result.Success && result.ICMPErr == nil
+}
+
+// retrying wraps probe so an unanswered size is sent again up to t.retries
+// times, backing off between tries with a RetryThrottler
+func (t *probeTally) retrying(probe probeFunc) probeFunc {
+	return func(ctx context.Context, size int) *ProbeResult {
+		throttle := NewRetryThrottler(t.retries, retryBaseDelay)
+		result, misses := probe(ctx, size), 0
+		for unanswered(result) && throttle.ShouldRetry() && ctx.Err() == nil {
+			throttle.WaitForRetry()
+			result = probe(ctx, size)
+			misses++
+			t.resent++
+		}
+		t.settle(result, misses)
+		return result
+	}
+}
+
+// retryingBurst wraps burst so the unanswered sizes of a burst are sent
+// again together, each resend waiting out one timeout for all of them
+func (t *probeTally) retryingBurst(burst burstFunc) burstFunc {
+	if burst == nil {
+		return nil
+	}
+	return func(ctx context.Context, sizes []int) []*ProbeResult {
+		throttle := NewRetryThrottler(t.retries, retryBaseDelay)
+		results := burst(ctx, sizes)
+		misses := make([]int, len(sizes))
+		for ctx.Err() == nil && throttle.ShouldRetry() {
+			var pending, resend []int
+			for i, result := range results {
+				if unanswered(result) {
+					pending = append(pending, i)
+					resend = append(resend, sizes[i])
+				}
+			}
+			if len(pending) == 0 {
+				break
+			}
+			throttle.WaitForRetry()
+			for j, result := range burst(ctx, resend) {
+				results[pending[j]] = result
+				misses[pending[j]]++
+			}
+			t.resent += len(resend)
+		}
+		for i, result := range results {
+			t.settle(result, misses[i])
+		}
+		return results
+	}
+}
+
+// settle records the final result of a size after misses unanswered tries
+func (t *probeTally) settle(result *ProbeResult, misses int) {
+	switch {
+	case result.Success:
+		t.answered++
+		t.lost += misses
+	case unanswered(result):
+		t.silent++
+	}
+}
+
+// finish adds the resends to the search's probe count and scores it. The
+// loss rate comes from sizes that got through, since those must have; each
+// size given up on was lost on all 1+retries tries, which loss alone does
+// with probability lossRate^(1+retries). A nil tally leaves search as is.
+func (t *probeTally) finish(search pmtuSearch) pmtuSearch {
+	if t == nil {
+		return search
+	}
+	search.Probes += t.resent
+	confidence := &ProbeConfidence{Retries: t.retries, Resent: t.resent, Score: 1}
+	if tries := t.answered + t.lost; tries > 0 {
+		confidence.LossRate = float64(t.lost) / float64(tries)
+	}
+	if confidence.LossRate > 0 {
+		falseNegative := math.Pow(confidence.LossRate, float64(1+t.retries))
+		confidence.Score = math.Pow(1-falseNegative, float64(t.silent))
+	}
+	search.Confidence = confidence
+	return search
+}
+
+// retryBackoff bounds what a RetryThrottler sleeps over retries resends of
+// one size, jitter included
+func retryBackoff(retries int) time.Duration {
+	var total time.Duration
+	delay := retryBaseDelay
+	for i := 1; i < retries; i++ {
+		total += min(delay, 10*time.Second) * 5 / 4
+		delay *= 2
+	}
+	return total
+}
+
+// searchRoundSizes spreads up to parallel sizes evenly inside [low, high],
+// splitting it into gaps of at most (high-low+1)/(parallel+1) sizes
+func searchRoundSizes(low, high, parallel int) []int {
+	span := high - low + 1
+	if span <= parallel {
+		sizes := make([]int, 0, span)
+		for size := low; size <= high; size++ {
+			sizes = append(sizes, size)
+		}
+		return sizes
+	}
+	sizes := make([]int, parallel)
+	for i := range sizes {
+		sizes[i] = low + (i+1)*span/(parallel+1)
+	}
+	return sizes
+}
+
+// narrowSearch applies one round's results to [low, high]. A size that got
+// through proves itself whatever happened to smaller ones, so the largest
+// success raises the floor; the failure just above it caps the range, as a
+// failure does in binary search.
+func narrowSearch(search *pmtuSearch, sizes []int, results []*ProbeResult, low, high int) (int, int) {
+	best := -1
+	for i, result := range results {
+		if result.Success {
+			best = i
+		}
+	}
+	if best >= 0 {
+		search.PMTU, search.RTT = sizes[best], results[best].RTT
+		low = sizes[best] + 1
+	}
+	if best+1 < len(sizes) {
+		high = sizes[best+1] - 1
+	}
+	return low, high
+}
+
+// fastPathResult is what the common-MTU check learned. When not Confirmed,
+// Low and High bound the binary search that finishes the job and PMTU holds
+// the largest size already known to work, if any.
+type fastPathResult struct {
+	pmtuSearch
+	Confirmed bool
+	Low, High int
+}
+
+// commonMTUFastPath probes every common PMTU in range at once. If the sizes
+// that got through are exactly the ones below some boundary, the largest of
+// them is re-probed and the next size up is checked to fail; two probes then
+// settle the PMTU. Any other pattern, such as a loss among the smaller sizes,
+// leaves the whole range to binary search.
+func commonMTUFastPath(ctx context.Context, minMTU, maxMTU int, probe probeFunc, burst burstFunc) fastPathResult {
+	fast := fastPathResult{Low: minMTU, High: maxMTU}
+	candidates := commonMTUCandidates(minMTU, maxMTU)
+	if len(candidates) == 0 {
+		return fast
+	}
+
+	results := burst(ctx, candidates)
+	fast.Probes += len(candidates)
+
+	boundary := -1 // Index of the largest candidate that got through
+	for i, result := range results {
+		if !result.Success {
+			continue
+		}
+		if i != boundary+1 {
+			return fast
+		}
+		boundary = i
+	}
+	if boundary < 0 || ctx.Err() != nil {
+		return fast
+	}
+
+	fits := candidates[boundary]
+	ceiling := maxMTU
+	if boundary+1 < len(candidates) {
+		ceiling = candidates[boundary+1] - 1
+	}
+
+	confirm := probe(ctx, fits)
+	fast.Probes++
+	if !confirm.Success {
+		return fast
+	}
+	fast.PMTU, fast.RTT = fits, confirm.RTT
+	if fits == maxMTU {
+		fast.Confirmed = true
+		return fast
+	}
+
+	next := probe(ctx, fits+1)
+	fast.Probes++
+	if !next.Success {
+		fast.Confirmed = true
+		return fast
+	}
+
+	// The PMTU is uncommon but lies between two candidates
+	fast.PMTU, fast.RTT = fits+1, next.RTT
+	fast.Low, fast.High = fits+2, ceiling
+	return fast
+}
+
+// parallelProbes runs probe for every size concurrently
+func parallelProbes(ctx context.Context, sizes []int, probe probeFunc) []*ProbeResult {
+	results := make([]*ProbeResult, len(sizes))
+	var wg sync.WaitGroup
+	for i, size := range sizes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = probe(ctx, size)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// sequentialProbes runs probe for every size in turn
+func sequentialProbes(ctx context.Context, sizes []int, probe probeFunc) []*ProbeResult {
+	results := make([]*ProbeResult, len(sizes))
+	for i, size := range sizes {
+		results[i] = probe(ctx, size)
+	}
+	return results
+}
+
+// burstProbes sends an echo request per size with one identifier and
+// consecutive sequence numbers, then waits one timeout for the replies. Sizes
+// that draw no echo reply count as too big.
+func (d *MTUDiscoverer) burstProbes(ctx context.Context, sizes []int) []*ProbeResult {
+	results := make([]*ProbeResult, len(sizes))
+	for i, size := range sizes {
+		results[i] = &ProbeResult{Size: size}
+	}
+
+	id := d.security.Randomizer.GenerateRandomID()
+	baseSeq := d.security.Randomizer.GenerateRandomSeq()
+
+	if err := d.conn.SetReadDeadline(time.Time{}); err != nil {
+		return failProbes(results, fmt.Errorf("failed to set read deadline: %w", err))
+	}
+	arrivalsChan := make(chan []trainArrival, 1)
+	go func() {
+		var arrivals []trainArrival
+		seen := make(map[int]bool, len(sizes))
+		buf := make([]byte, 65535)
+		for len(seen) < len(sizes) {
+			n, _, err := d.conn.ReadFrom(buf)
+			received := time.Now()
+			if err != nil {
+				break
+			}
+			if seq, ok := d.parseTrainReply(buf[:n], id, baseSeq, len(sizes)); ok && !seen[seq] {
+				arrivals = append(arrivals, trainArrival{Seq: seq, Received: received})
+				seen[seq] = true
+			}
+		}
+		arrivalsChan <- arrivals
+	}()
+
+	sent := make([]time.Time, len(sizes))
+	var sendErr error
+	for i, size := range sizes {
+		if sendErr = ctx.Err(); sendErr != nil {
+			break
+		}
+		d.security.RateLimiter.Wait()
+
+		packet, err := d.createEchoPacket(size, id, (baseSeq+i)&0xffff)
+		if err != nil {
+			sendErr = err
+			break
+		}
+		sent[i] = time.Now()
+		if _, err := d.conn.WriteTo(packet, d.targetAddr); err != nil {
+			// Local EMSGSIZE for sizes above the interface MTU just means too big
+			results[i].Error = err
+		}
+	}
+
+	drain := time.Now().Add(d.timeout)
+	if sendErr != nil {
+		drain = time.Now()
+	}
+	if err := d.conn.SetReadDeadline(drain); err != nil && sendErr == nil {
+		sendErr = fmt.Errorf("failed to set read deadline: %w", err)
+	}
+	arrivals := <-arrivalsChan
+
+	// Fragmentation errors for the burst are already accounted for; leaving
+	// them queued would fail the probes that follow
+	if d.icmpListener != nil {
+	drained:
+		for {
+			select {
+			case <-d.icmpListener.Errors():
+			default:
+				break drained
+			}
+		}
+	}
+
+	if sendErr != nil {
+		return failProbes(results, sendErr)
+	}
+	for _, arrival := range arrivals {
+		results[arrival.Seq].Success = true
+		results[arrival.Seq].RTT = arrival.Received.Sub(sent[arrival.Seq])
+	}
+	return results
+}
+
+// failProbes marks every probe in a burst as failed with err
+func failProbes(results []*ProbeResult, err error) []*ProbeResult {
+	for _, result := range results {
+		result.Success = false
+		result.Error = err
+	}
+	return results
+}
@@ -0,0 +1,266 @@
+package mtu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakePath answers probes as a path with the given PMTU would, except for the
+// sizes listed in lost and the first drops[size] tries of a size, and records
+// every size it was asked to send. With icmp, sizes above the PMTU draw a
+// Fragmentation Needed error instead of silence.
+type fakePath struct {
+	pmtu  int
+	lost  map[int]bool
+	drops map[int]int
+	icmp  bool
+	sizes []int
+}
+
+func (p *fakePath) probe(ctx context.Context, size int) *ProbeResult {
+	p.sizes = append(p.sizes, size)
+	if p.drops[size] > 0 {
+		p.drops[size]--
+		return &ProbeResult{Size: size}
+	}
+	result := &ProbeResult{Size: size, Success: size <= p.pmtu && !p.lost[size], RTT: time.Millisecond}
+	if p.icmp && size > p.pmtu {
+		result.ICMPErr = &ICMPError{Type: 3, Code: 4, MTU: p.pmtu}
+	}
+	return result
+}
+
+func (p *fakePath) burst(ctx context.Context, sizes []int) []*ProbeResult {
+	return sequentialProbes(ctx, sizes, p.probe)
+}
+
+func TestCommonMTUCandidates(t *testing.T) {
+	if got := commonMTUCandidates(576, 9216); !slices.Equal(got, commonPMTUs) {
+		t.Fatalf("expected every common PMTU in the default range, got %v", got)
+	}
+	if got := commonMTUCandidates(1280, 1450); !slices.Equal(got, []int{1380, 1400, 1436}) {
+		t.Fatalf("unexpected candidates: %v", got)
+	}
+	if got := commonMTUCandidates(1300, 1350); got != nil {
+		t.Fatalf("expected no candidates, got %v", got)
+	}
+}
+
+func TestSearchPMTU(t *testing.T) {
+	tests := []struct {
+		name       string
+		pmtu       int
+		lost       map[int]bool
+		maxMTU     int
+		wantProbes int // 0 = the candidates plus a full binary search
+	}{
+		{name: "common pmtu confirmed with two probes", pmtu: 1500, maxMTU: 9216, wantProbes: len(commonPMTUs) + 2},
+		{name: "pppoe pmtu", pmtu: 1492, maxMTU: 9216, wantProbes: len(commonPMTUs) + 2},
+		{name: "pmtu at the top of the range", pmtu: 9216, maxMTU: 1500, wantProbes: 7 + 1},
+		{name: "uncommon pmtu narrows the binary search", pmtu: 1450, maxMTU: 9216, wantProbes: len(commonPMTUs) + 2 + positiveIntBitLen(1451-1438+1)},
+		{name: "inconsistent burst falls back to binary search", pmtu: 1500, lost: map[int]bool{1400: true}, maxMTU: 9216},
+		{name: "pmtu below every candidate falls back", pmtu: 1280, maxMTU: 9216},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := &fakePath{pmtu: tt.pmtu, lost: tt.lost}
+			if tt.wantProbes == 0 {
+				plain, err := searchPMTU(context.Background(), 576, tt.maxMTU, (&fakePath{pmtu: tt.pmtu, lost: tt.lost}).probe, nil, searchPlan{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				tt.wantProbes = len(commonPMTUs) + plain.Probes
+			}
+			search, err := searchPMTU(context.Background(), 576, tt.maxMTU, path.probe, path.burst, searchPlan{CommonFirst: true})
+			if err != nil {
+				t.Fatalf("searchPMTU returned error: %v", err)
+			}
+			want := min(tt.pmtu, tt.maxMTU)
+			if search.PMTU != want {
+				t.Fatalf("PMTU = %d, want %d (probed %v)", search.PMTU, want, path.sizes)
+			}
+			if search.Probes != tt.wantProbes || search.Probes != len(path.sizes) {
+				t.Fatalf("Probes = %d, want %d (probed %v)", search.Probes, tt.wantProbes, path.sizes)
+			}
+		})
+	}
+}
+
+func TestSearchPMTUWithoutBurstIsBinarySearch(t *testing.T) {
+	path := &fakePath{pmtu: 1500}
+	search, err := searchPMTU(context.Background(), 576, 9216, path.probe, nil, searchPlan{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if search.PMTU != 1500 || search.Probes > positiveIntBitLen(9216-576+1) {
+		t.Fatalf("unexpected binary search: %+v", search)
+	}
+	if path.sizes[0] != (576+9216)/2 {
+		t.Fatalf("expected the first probe at the midpoint, got %v", path.sizes)
+	}
+}
+
+func TestSearchPMTUErrors(t *testing.T) {
+	path := &fakePath{pmtu: 500}
+	if _, err := searchPMTU(context.Background(), 576, 1500, path.probe, path.burst, searchPlan{CommonFirst: true}); !errors.Is(err, errNoWorkingMTU) {
+		t.Fatalf("expected errNoWorkingMTU, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := searchPMTU(ctx, 576, 1500, path.probe, path.burst, searchPlan{CommonFirst: true}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestSearchPMTUParallel(t *testing.T) {
+	for _, parallel := range []int{2, 4, 8, maxParallelProbes} {
+		for _, pmtu := range []int{576, 1280, 1450, 1500, 9000, 9216} {
+			path := &fakePath{pmtu: pmtu}
+			rounds := 0
+			burst := func(ctx context.Context, sizes []int) []*ProbeResult {
+				rounds++
+				if len(sizes) > parallel {
+					t.Fatalf("round of %d sizes with --parallel %d", len(sizes), parallel)
+				}
+				return path.burst(ctx, sizes)
+			}
+			search, err := searchPMTU(context.Background(), 576, 9216, path.probe, burst, searchPlan{Parallel: parallel})
+			if err != nil {
+				t.Fatal(err)
+			}
+			span := 9216 - 576 + 1
+			if search.PMTU != pmtu || search.Probes != len(path.sizes) || search.Probes > searchProbes(span, parallel) || rounds > searchRounds(span, parallel) {
+				t.Errorf("parallel %d, pmtu %d: got %+v in %d rounds (probed %v)", parallel, pmtu, search, rounds, path.sizes)
+			}
+		}
+	}
+
+	// A lost probe below the PMTU does not hide a larger size that got through
+	path := &fakePath{pmtu: 1500, lost: map[int]bool{1230: true}}
+	search, err := searchPMTU(context.Background(), 576, 1500, path.probe, path.burst, searchPlan{Parallel: 4})
+	if err != nil || search.PMTU != 1500 {
+		t.Fatalf("search with a lost probe = %+v, %v (probed %v)", search, err, path.sizes)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := func(ctx context.Context, sizes []int) []*ProbeResult {
+		cancel()
+		return path.burst(ctx, sizes)
+	}
+	if _, err := searchPMTU(ctx, 576, 1500, path.probe, cancelled, searchPlan{Parallel: 4}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled from an interrupted round, got %v", err)
+	}
+}
+
+func TestSearchPMTURetries(t *testing.T) {
+	original := retryBaseDelay
+	t.Cleanup(func() { retryBaseDelay = original })
+	retryBaseDelay = time.Millisecond
+
+	// One lost probe at the PMTU makes a plain search settle below it
+	plain, err := searchPMTU(context.Background(), 576, 1600, (&fakePath{pmtu: 1500, drops: map[int]int{1088: 1}}).probe, nil, searchPlan{})
+	if err != nil || plain.PMTU >= 1500 || plain.Confidence != nil {
+		t.Fatalf("search without retries = %+v, %v; want an underestimate and no confidence", plain, err)
+	}
+
+	for _, plan := range []searchPlan{{Retries: 1}, {Retries: 2, CommonFirst: true}, {Retries: 2, Parallel: 4}} {
+		path := &fakePath{pmtu: 1500, drops: map[int]int{1088: 1, 1500: 2, 1380: 1}}
+		search, err := searchPMTU(context.Background(), 576, 1600, path.probe, path.burst, plan)
+		if err != nil {
+			t.Fatalf("%+v: %v", plan, err)
+		}
+		c := search.Confidence
+		if plan.Retries == 1 {
+			// 1500 is lost on both of its tries, so this search ends short
+			if search.PMTU != 1499 {
+				t.Errorf("%+v: PMTU = %d, want 1499 (probed %v)", plan, search.PMTU, path.sizes)
+			}
+		} else if search.PMTU != 1500 {
+			t.Errorf("%+v: PMTU = %d, want 1500 (probed %v)", plan, search.PMTU, path.sizes)
+		}
+		if c == nil || c.Retries != plan.Retries || c.Resent == 0 || search.Probes != len(path.sizes) {
+			t.Fatalf("%+v: search = %+v, confidence %+v (probed %v)", plan, search, c, path.sizes)
+		}
+		if c.LossRate <= 0 || c.Score <= 0 || c.Score >= 1 {
+			t.Errorf("%+v: lossy search scored %+v", plan, c)
+		}
+	}
+
+	// Fragmentation Needed is final: nothing is resent and the result is clean
+	path := &fakePath{pmtu: 1500, icmp: true}
+	search, err := searchPMTU(context.Background(), 576, 9216, path.probe, nil, searchPlan{Retries: 3})
+	if err != nil || search.PMTU != 1500 || *search.Confidence != (ProbeConfidence{Retries: 3, Score: 1}) {
+		t.Fatalf("search with ICMP errors = %+v (%+v), %v", search, search.Confidence, err)
+	}
+}
+
+func TestSearchRoundSizes(t *testing.T) {
+	if got := searchRoundSizes(576, 1500, 3); !slices.Equal(got, []int{807, 1038, 1269}) {
+		t.Fatalf("searchRoundSizes(576, 1500, 3) = %v", got)
+	}
+	if got := searchRoundSizes(1498, 1500, 4); !slices.Equal(got, []int{1498, 1499, 1500}) {
+		t.Fatalf("expected every size of a short range, got %v", got)
+	}
+}
+
+// timedPath is a path whose lost probes each cost a timeout, as on a network
+// that drops oversized packets silently. A burst waits one timeout at most.
+type timedPath struct {
+	pmtu    int
+	timeout time.Duration
+}
+
+func (p timedPath) probe(ctx context.Context, size int) *ProbeResult {
+	if size > p.pmtu {
+		time.Sleep(p.timeout)
+		return &ProbeResult{Size: size}
+	}
+	return &ProbeResult{Size: size, Success: true}
+}
+
+func (p timedPath) burst(ctx context.Context, sizes []int) []*ProbeResult {
+	results := make([]*ProbeResult, len(sizes))
+	for i, size := range sizes {
+		results[i] = &ProbeResult{Size: size, Success: size <= p.pmtu}
+	}
+	if sizes[len(sizes)-1] > p.pmtu {
+		time.Sleep(p.timeout)
+	}
+	return results
+}
+
+// BenchmarkSearchPMTU compares binary search with parallel rounds over the
+// default range; ns/op is dominated by the timeouts each search waits out
+func BenchmarkSearchPMTU(b *testing.B) {
+	path := timedPath{pmtu: 1450, timeout: time.Millisecond}
+	for _, parallel := range []int{1, 4, 8, maxParallelProbes} {
+		b.Run(fmt.Sprintf("parallel-%d", parallel), func(b *testing.B) {
+			var search pmtuSearch
+			for i := 0; i < b.N; i++ {
+				var err error
+				search, err = searchPMTU(context.Background(), 576, 9216, path.probe, path.burst, searchPlan{Parallel: parallel})
+				if err != nil || search.PMTU != path.pmtu {
+					b.Fatalf("search = %+v, %v", search, err)
+				}
+			}
+			b.ReportMetric(float64(search.Probes), "probes/op")
+		})
+	}
+}
+
+func TestParallelProbesKeepsOrder(t *testing.T) {
+	sizes := []int{1380, 1500, 9000}
+	results := parallelProbes(context.Background(), sizes, func(ctx context.Context, size int) *ProbeResult {
+		return &ProbeResult{Size: size, Success: size <= 1500}
+	})
+	for i, result := range results {
+		if result.Size != sizes[i] || result.Success != (sizes[i] <= 1500) {
+			t.Fatalf("result %d out of order: %+v", i, result)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package mtu
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ProbeSizeStats summarizes every probe a discovery sent at one size, so
+// latency or loss that grows with size, a sign of shaping, shows up beside
+// the PMTU
+type ProbeSizeStats struct {
+	Size        int     `json:"size"`
+	Sent        int     `json:"sent"`
+	Received    int     `json:"received"`
+	LossPercent float64 `json:"loss_percent"`
+	RTTMinMS    float64 `json:"rtt_min_ms,omitempty"`
+	RTTAvgMS    float64 `json:"rtt_avg_ms,omitempty"`
+	RTTMaxMS    float64 `json:"rtt_max_ms,omitempty"`
+}
+
+// probeHistory records the outcome of each probe by size. A search probes
+// one size or one burst at a time, so it needs no lock.
+type probeHistory struct {
+	sizes map[int]*sizeHistory
+}
+
+type sizeHistory struct {
+	sent     int
+	received int
+	rttMin   time.Duration
+	rttMax   time.Duration
+	rttSum   time.Duration
+}
+
+// recording wraps probe so each result is added to h
+func (h *probeHistory) recording(probe probeFunc) probeFunc {
+	return func(ctx context.Context, size int) *ProbeResult {
+		result := probe(ctx, size)
+		h.add(size, result)
+		return result
+	}
+}
+
+// recordingBurst wraps burst so each result is added to h
+func (h *probeHistory) recordingBurst(burst burstFunc) burstFunc {
+	if burst == nil {
+		return nil
+	}
+	return func(ctx context.Context, sizes []int) []*ProbeResult {
+		results := burst(ctx, sizes)
+		for i, result := range results {
+			h.add(sizes[i], result)
+		}
+		return results
+	}
+}
+
+func (h *probeHistory) add(size int, result *ProbeResult) {
+	if h.sizes == nil {
+		h.sizes = make(map[int]*sizeHistory)
+	}
+	entry := h.sizes[size]
+	if entry == nil {
+		entry = &sizeHistory{}
+		h.sizes[size] = entry
+	}
+	entry.sent++
+	if !result.Success {
+		return
+	}
+	if entry.received == 0 || result.RTT < entry.rttMin {
+		entry.rttMin = result.RTT
+	}
+	entry.rttMax = max(entry.rttMax, result.RTT)
+	entry.rttSum += result.RTT
+	entry.received++
+}
+
+// finish adds the history, smallest size first, and the RTT spread across
+// every answered probe to search
+func (h *probeHistory) finish(search pmtuSearch) pmtuSearch {
+	search.History = make([]ProbeSizeStats, 0, len(h.sizes))
+	var overall sizeHistory
+	for size, entry := range h.sizes {
+		stats := ProbeSizeStats{
+			Size:        size,
+			Sent:        entry.sent,
+			Received:    entry.received,
+			LossPercent: percent(entry.sent-entry.received, entry.sent),
+		}
+		if entry.received > 0 {
+			stats.RTTMinMS = durationMS(entry.rttMin)
+			stats.RTTAvgMS = durationMS(entry.rttSum / time.Duration(entry.received))
+			stats.RTTMaxMS = durationMS(entry.rttMax)
+			if overall.received == 0 || entry.rttMin < overall.rttMin {
+				overall.rttMin = entry.rttMin
+			}
+			overall.rttMax = max(overall.rttMax, entry.rttMax)
+			overall.rttSum += entry.rttSum
+			overall.received += entry.received
+		}
+		search.History = append(search.History, stats)
+	}
+	sort.Slice(search.History, func(i, j int) bool { return search.History[i].Size < search.History[j].Size })
+
+	if overall.received > 0 {
+		search.RTTMin = overall.rttMin
+		search.RTTAvg = overall.rttSum / time.Duration(overall.received)
+		search.RTTMax = overall.rttMax
+	}
+	return search
+}
+
+// outputProbeHistoryTable prints the probes sent at each size
+func outputProbeHistoryTable(history []ProbeSizeStats) {
+	fmt.Printf("\nProbes by size:\n")
+	fmt.Printf("%6s %5s %5s %7s  %s\n", "SIZE", "SENT", "RECV", "LOSS", "RTT MIN/AVG/MAX (ms)")
+	for _, stats := range history {
+		rtt := "-"
+		if stats.Received > 0 {
+			rtt = fmt.Sprintf("%.2f/%.2f/%.2f", stats.RTTMinMS, stats.RTTAvgMS, stats.RTTMaxMS)
+		}
+		fmt.Printf("%6d %5d %5d %6.1f%%  %s\n", stats.Size, stats.Sent, stats.Received, stats.LossPercent, rtt)
+	}
+}
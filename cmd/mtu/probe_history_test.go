@@ -0,0 +1,82 @@
+package mtu
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProbeHistory(t *testing.T) {
+	var history probeHistory
+	history.add(1400, &ProbeResult{Success: true, RTT: 2 * time.Millisecond})
+	history.add(1400, &ProbeResult{Success: true, RTT: 4 * time.Millisecond})
+	history.add(1500, &ProbeResult{})
+	history.add(1400, &ProbeResult{})
+	history.add(1300, &ProbeResult{Success: true, RTT: time.Millisecond})
+
+	search := history.finish(pmtuSearch{})
+	want := []ProbeSizeStats{
+		{Size: 1300, Sent: 1, Received: 1, RTTMinMS: 1, RTTAvgMS: 1, RTTMaxMS: 1},
+		{Size: 1400, Sent: 3, Received: 2, LossPercent: 100.0 / 3, RTTMinMS: 2, RTTAvgMS: 3, RTTMaxMS: 4},
+		{Size: 1500, Sent: 1, LossPercent: 100},
+	}
+	if len(search.History) != len(want) {
+		t.Fatalf("History = %+v, want %+v", search.History, want)
+	}
+	for i := range want {
+		if search.History[i] != want[i] {
+			t.Errorf("History[%d] = %+v, want %+v", i, search.History[i], want[i])
+		}
+	}
+	if search.RTTMin != time.Millisecond || search.RTTAvg != 7*time.Millisecond/3 || search.RTTMax != 4*time.Millisecond {
+		t.Fatalf("RTT min/avg/max = %v/%v/%v", search.RTTMin, search.RTTAvg, search.RTTMax)
+	}
+}
+
+func TestSearchPMTURecordsHistory(t *testing.T) {
+	path := &fakePath{pmtu: 1400, drops: map[int]int{1400: 1}}
+	search, err := searchPMTU(context.Background(), 1200, 1500, path.probe, path.burst, searchPlan{CommonFirst: true, Retries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := 0
+	for _, stats := range search.History {
+		sent += stats.Sent
+		if stats.Size == 1400 && stats.Sent-stats.Received != 1 {
+			t.Errorf("1400 = %+v, want the dropped try counted as lost", stats)
+		}
+	}
+	if sent != len(path.sizes) || sent != search.Probes {
+		t.Fatalf("history counts %d probes, path saw %d and search %d", sent, len(path.sizes), search.Probes)
+	}
+	if search.RTTMax != time.Millisecond {
+		t.Fatalf("RTTMax = %v, want 1ms", search.RTTMax)
+	}
+}
+
+func TestOutputTableProbeHistory(t *testing.T) {
+	output, err := captureStdout(t, func() error {
+		return outputTable(&MTUResult{
+			Target: "example.com", Protocol: "udp", PMTU: 1400,
+			RTTMinMS: 1, RTTAvgMS: 1.5, RTTMaxMS: 2,
+			History: []ProbeSizeStats{
+				{Size: 1400, Sent: 2, Received: 2, RTTMinMS: 1, RTTAvgMS: 1.5, RTTMaxMS: 2},
+				{Size: 1500, Sent: 1, LossPercent: 100},
+			},
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"RTT min/avg/max: 1.00/1.50/2.00 ms",
+		"  1400     2     2    0.0%  1.00/1.50/2.00",
+		"  1500     1     0  100.0%  -",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+}
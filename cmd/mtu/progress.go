@@ -0,0 +1,67 @@
+package mtu
+
+import (
+	"fmt"
+	"io"
+	"net"
+)
+
+// ProgressKind says what a ProgressEvent reports
+type ProgressKind string
+
+const (
+	// ProgressHop is a hop that answered, with the PMTU to it when the size
+	// search found one
+	ProgressHop ProgressKind = "hop"
+	// ProgressDestination is the destination answering, with the path's PMTU
+	ProgressDestination ProgressKind = "destination"
+	// ProgressPLPMTUD is a PLPMTUD state transition
+	ProgressPLPMTUD ProgressKind = "plpmtud"
+	// ProgressWarning is something that degraded the measurement; it is also
+	// recorded in the result's warnings
+	ProgressWarning ProgressKind = "warning"
+)
+
+// ProgressEvent is one step of a discovery as it runs. Only the fields of its
+// Kind are set.
+type ProgressEvent struct {
+	Kind       ProgressKind
+	Hop        int                // ProgressHop and ProgressDestination
+	Addr       net.IP             // ProgressHop and ProgressDestination
+	MTU        int                // PMTU to the hop or destination, 0 if not found
+	Transition *PLPMTUDTransition // ProgressPLPMTUD
+	Message    string             // ProgressWarning, or why a hop has no MTU
+}
+
+// ProgressFunc receives progress events on the goroutine running the discovery
+type ProgressFunc func(ProgressEvent)
+
+// progressPrinter renders progress for the terminal: warnings always go to
+// warnings, and the other events to out unless it is nil
+func progressPrinter(out, warnings io.Writer) ProgressFunc {
+	return func(event ProgressEvent) {
+		if event.Kind == ProgressWarning {
+			_, _ = fmt.Fprintf(warnings, "Warning: %s\n", event.Message)
+			return
+		}
+		if out == nil {
+			return
+		}
+		switch event.Kind {
+		case ProgressHop:
+			switch {
+			case event.MTU > 0:
+				_, _ = fmt.Fprintf(out, "Hop %d: %s (Path MTU to this hop: %d bytes)\n", event.Hop, event.Addr, event.MTU)
+			case event.Message != "":
+				_, _ = fmt.Fprintf(out, "Hop %d: %s (%s)\n", event.Hop, event.Addr, event.Message)
+			default:
+				_, _ = fmt.Fprintf(out, "Hop %d: %s (MTU discovery failed)\n", event.Hop, event.Addr)
+			}
+		case ProgressDestination:
+			_, _ = fmt.Fprintf(out, "Reached destination at hop %d with PMTU: %d bytes\n", event.Hop, event.MTU)
+		case ProgressPLPMTUD:
+			t := event.Transition
+			_, _ = fmt.Fprintf(out, "PLPMTUD: %s -> %s, PLPMTU %d (%s)\n", t.From, t.To, t.PLPMTU, t.Reason)
+		}
+	}
+}
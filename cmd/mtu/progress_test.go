@@ -0,0 +1,55 @@
+package mtu
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestProgressPrinter(t *testing.T) {
+	var out, warnings bytes.Buffer
+	printer := progressPrinter(&out, &warnings)
+
+	printer(ProgressEvent{Kind: ProgressHop, Hop: 1, Addr: net.ParseIP("10.0.0.1"), MTU: 1500})
+	printer(ProgressEvent{Kind: ProgressHop, Hop: 2, Addr: net.ParseIP("10.0.0.2")})
+	printer(ProgressEvent{Kind: ProgressHop, Hop: 3, Addr: net.ParseIP("10.0.0.3"), Message: "SYN probes do not measure hop MTUs"})
+	printer(ProgressEvent{Kind: ProgressDestination, Hop: 4, Addr: net.ParseIP("10.0.0.4"), MTU: 1400})
+	printer(ProgressEvent{Kind: ProgressPLPMTUD, Transition: &PLPMTUDTransition{From: plpStateBase, To: plpStateSearching, PLPMTU: 1200, Reason: "base confirmed"}})
+	printer(ProgressEvent{Kind: ProgressWarning, Message: "failed to set DF flag"})
+
+	want := "Hop 1: 10.0.0.1 (Path MTU to this hop: 1500 bytes)\n" +
+		"Hop 2: 10.0.0.2 (MTU discovery failed)\n" +
+		"Hop 3: 10.0.0.3 (SYN probes do not measure hop MTUs)\n" +
+		"Reached destination at hop 4 with PMTU: 1400 bytes\n" +
+		"PLPMTUD: BASE -> SEARCHING, PLPMTU 1200 (base confirmed)\n"
+	if out.String() != want {
+		t.Fatalf("unexpected progress output:\n%s\nwant:\n%s", out.String(), want)
+	}
+	if warnings.String() != "Warning: failed to set DF flag\n" {
+		t.Fatalf("unexpected warning output: %q", warnings.String())
+	}
+
+	var quiet bytes.Buffer
+	silent := progressPrinter(nil, &quiet)
+	silent(ProgressEvent{Kind: ProgressHop, Hop: 1, Addr: net.ParseIP("10.0.0.1"), MTU: 1500})
+	silent(ProgressEvent{Kind: ProgressWarning, Message: "still shown"})
+	if quiet.String() != "Warning: still shown\n" {
+		t.Fatalf("expected only the warning without an output writer, got %q", quiet.String())
+	}
+}
+
+func TestSetProgressReplaysWarnings(t *testing.T) {
+	discoverer := newICMPDiscovererForTest(&fakePacketConn{}, false)
+	discoverer.warningf("raw ICMP socket unavailable")
+
+	var events []ProgressEvent
+	discoverer.SetProgress(func(event ProgressEvent) { events = append(events, event) })
+	discoverer.warningf("failed to set DF flag")
+
+	if len(events) != 2 || events[0].Message != "raw ICMP socket unavailable" || events[1].Message != "failed to set DF flag" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if warnings := discoverer.Warnings(); len(warnings) != 2 {
+		t.Fatalf("expected both warnings recorded, got %v", warnings)
+	}
+}
@@ -0,0 +1,103 @@
+package mtu
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// scheduleJitter is how much of its slot a probe may be delayed by at random,
+// so targets sharing an interval across several watch processes drift apart
+const scheduleJitter = 0.5
+
+// jitterFraction picks a probe's share of the jitter window; tests replace it
+var jitterFraction = rand.Float64
+
+// probeSchedule spreads a fleet's probes evenly over the watch interval. Each
+// target gets a fixed phase offset, so probes never fire in one burst and the
+// rate limiter sees a steady load.
+type probeSchedule struct {
+	interval time.Duration
+	slots    []scheduleSlot
+	log      io.Writer // Where --verbose reports the schedule; nil is quiet
+}
+
+// scheduleSlot is when in each cycle a target is probed
+type scheduleSlot struct {
+	index  int // Position of the target in the fleet monitor
+	target string
+	offset time.Duration
+}
+
+// newProbeSchedule assigns each destination a phase offset within interval.
+// Slots are ordered by a hash of the target, so the schedule is the same on
+// every run and does not depend on the order targets were listed in.
+func newProbeSchedule(destinations []string, interval time.Duration, log io.Writer) probeSchedule {
+	slots := make([]scheduleSlot, len(destinations))
+	for i, target := range destinations {
+		slots[i] = scheduleSlot{index: i, target: target}
+	}
+	slices.SortStableFunc(slots, func(a, b scheduleSlot) int {
+		if c := cmp.Compare(targetPhase(a.target), targetPhase(b.target)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.target, b.target)
+	})
+
+	s := probeSchedule{interval: interval, slots: slots, log: log}
+	for k := range s.slots {
+		s.slots[k].offset = time.Duration(k) * s.slotWidth()
+	}
+	return s
+}
+
+// targetPhase hashes a target to order it in the schedule
+func targetPhase(target string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(target))
+	return h.Sum64()
+}
+
+// slotWidth is the time between consecutive probes
+func (s probeSchedule) slotWidth() time.Duration {
+	if len(s.slots) == 0 {
+		return 0
+	}
+	return s.interval / time.Duration(len(s.slots))
+}
+
+// jitter returns a random delay within the first part of a slot
+func (s probeSchedule) jitter() time.Duration {
+	return time.Duration(jitterFraction() * scheduleJitter * float64(s.slotWidth()))
+}
+
+// wait sleeps until slot is due in the cycle that began at start, and returns
+// false if ctx ends first
+func (s probeSchedule) wait(ctx context.Context, start time.Time, slot scheduleSlot) bool {
+	jitter := s.jitter()
+	due := start.Add(slot.offset + jitter)
+	if s.log != nil {
+		_, _ = fmt.Fprintf(s.log, "[%s] probing %s (slot +%v, jitter +%v)\n", due.Format("15:04:05"), slot.target, slot.offset, jitter.Round(time.Millisecond))
+	}
+	if delay := time.Until(due); delay > 0 {
+		return sleepInterval(ctx, delay)
+	}
+	return ctx.Err() == nil
+}
+
+// print writes the schedule for --verbose
+func (s probeSchedule) print() {
+	if s.log == nil || len(s.slots) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(s.log, "Probe schedule: %d targets every %v, one every %v with up to %v of jitter\n",
+		len(s.slots), s.interval, s.slotWidth(), time.Duration(scheduleJitter*float64(s.slotWidth())))
+	for _, slot := range s.slots {
+		_, _ = fmt.Fprintf(s.log, "  +%-10v %s\n", slot.offset, slot.target)
+	}
+}
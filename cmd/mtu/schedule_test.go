@@ -0,0 +1,87 @@
+package mtu
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewProbeSchedule(t *testing.T) {
+	destinations := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}
+	schedule := newProbeSchedule(destinations, time.Minute, nil)
+
+	if width := schedule.slotWidth(); width != 15*time.Second {
+		t.Fatalf("slotWidth = %v, want 15s", width)
+	}
+	var order []string
+	for k, slot := range schedule.slots {
+		if slot.offset != time.Duration(k)*15*time.Second {
+			t.Errorf("slot %d offset = %v, want %v", k, slot.offset, time.Duration(k)*15*time.Second)
+		}
+		if destinations[slot.index] != slot.target {
+			t.Errorf("slot %d index %d points at %s, not %s", k, slot.index, destinations[slot.index], slot.target)
+		}
+		order = append(order, slot.target)
+	}
+
+	// The order depends on the targets, not the order they were listed in
+	reversed := slices.Clone(destinations)
+	slices.Reverse(reversed)
+	var again []string
+	for _, slot := range newProbeSchedule(reversed, time.Minute, nil).slots {
+		again = append(again, slot.target)
+	}
+	if !slices.Equal(order, again) {
+		t.Errorf("schedule order changed with listing order: %v vs %v", order, again)
+	}
+}
+
+func TestProbeScheduleJitter(t *testing.T) {
+	original := jitterFraction
+	t.Cleanup(func() { jitterFraction = original })
+
+	schedule := newProbeSchedule([]string{"a", "b"}, 10*time.Second, nil)
+	for _, tt := range []struct {
+		fraction float64
+		want     time.Duration
+	}{
+		{0, 0},
+		{0.5, 1250 * time.Millisecond},
+		{0.999, 2497500 * time.Microsecond},
+	} {
+		jitterFraction = func() float64 { return tt.fraction }
+		if got := schedule.jitter(); got != tt.want {
+			t.Errorf("jitter at %v = %v, want %v", tt.fraction, got, tt.want)
+		}
+	}
+}
+
+func TestProbeScheduleVerbose(t *testing.T) {
+	original := jitterFraction
+	t.Cleanup(func() { jitterFraction = original })
+	jitterFraction = func() float64 { return 0 }
+
+	var log bytes.Buffer
+	schedule := newProbeSchedule([]string{"10.0.0.1", "10.0.0.2"}, 0, &log)
+	schedule.print()
+	for _, slot := range schedule.slots {
+		if !schedule.wait(context.Background(), time.Now(), slot) {
+			t.Fatal("wait returned false with a live context")
+		}
+	}
+	out := log.String()
+	for _, want := range []string{"Probe schedule: 2 targets", "probing 10.0.0.1 (slot +0s, jitter +0s)", "probing 10.0.0.2"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("verbose output missing %q:\n%s", want, out)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if schedule.wait(ctx, time.Now(), schedule.slots[0]) {
+		t.Error("wait returned true after the context ended")
+	}
+}
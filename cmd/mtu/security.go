@@ -2,9 +2,7 @@ package mtu
 
 import (
 	"crypto/rand"
-	"fmt"
 	"math/big"
-	"os"
 	"sync"
 	"time"
 )
@@ -90,17 +88,10 @@ func (pr *PacketRandomizer) GenerateRandomPayload(size int) []byte {
 		return payload
 	}
 
-	// Generate cryptographically random payload
+	// Generate cryptographically random payload; crypto/rand.Read never
+	// fails since Go 1.24
 	payload := make([]byte, size)
-	if _, err := rand.Read(payload); err != nil {
-		// Fallback to a simple pattern if crypto/rand fails
-		// This is a significant issue, so we should log it.
-		// In a real application, this might be a fatal error.
-		fmt.Fprintf(os.Stderr, "Warning: crypto/rand.Read failed: %v. Using predictable payload.\n", err)
-		for i := range payload {
-			payload[i] = byte(i % 256)
-		}
-	}
+	_, _ = rand.Read(payload)
 	return payload
 }
 
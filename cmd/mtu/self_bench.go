@@ -0,0 +1,469 @@
+package mtu
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/dns"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/family"
+	"github.com/euan-cowie/cidrator/internal/policy"
+	"github.com/euan-cowie/cidrator/internal/units"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultBenchPPS        = 1000
+	defaultBenchDuration   = time.Second
+	defaultBenchDNSName    = "example.com"
+	defaultBenchDNSQueries = 5
+	benchDNSTimeout        = 2 * time.Second
+	benchInterfaceRuns     = 5
+	benchUDPPayload        = 64
+	maxLoopbackProbe       = 65535 // Largest IPv4 packet
+
+	// maxUnlimitedBenchPackets bounds the send test without a limiter, so
+	// --dry-run and the audit log have an upper bound to report
+	maxUnlimitedBenchPackets = 1_000_000
+)
+
+// Stages of the self benchmark, replaced in tests
+var (
+	benchLoopbackPMTU = measureLoopbackPMTU
+	benchUDPSend      = measureUDPSend
+	benchDNS          = measureDNS
+	benchInterfaces   = measureInterfaces
+)
+
+// SelfBenchCmd measures the local stack so slow results can be blamed on the
+// host or the network. It lives in the mtu package for the peer and discovery
+// machinery and is registered under the bench group.
+var SelfBenchCmd = &cobra.Command{
+	Use:   "self",
+	Short: "Benchmark the local stack as a baseline for interpreting results",
+	Long: `Self benchmarks the host cidrator runs on and prints a baseline report:
+
+- Loopback PMTU: UDP discovery against an in-process peer on 127.0.0.1 (::1
+  on hosts without IPv4 loopback), compared with the loopback interface MTU
+- UDP send rate: packets per second sent to loopback with the probe rate
+  limiter set to --pps, and with no limiter
+- DNS resolver latency: --dns-queries lookups of --dns-name through the
+  system resolver, or --dns-server
+- Interface enumeration: how long listing interfaces and their MTUs takes
+
+Only the DNS stage leaves the host. A stage that fails is reported with its
+error and the others still run. Notes point out results slow enough to
+distort other commands, such as a limiter that cannot reach --pps or a slow
+local resolver.
+
+Under a policy max_pps, the send test without a limiter is held to the cap
+as well. --dry-run prints the traffic of each stage without sending it.`,
+	Args:        cobra.NoArgs,
+	RunE:        runSelfBench,
+	Annotations: dryRunAnnotations,
+}
+
+func init() {
+	flags := SelfBenchCmd.Flags()
+	flags.Int("pps", defaultBenchPPS, "Rate to ask of the limiter in the UDP send test")
+	units.Duration(flags, "duration", defaultBenchDuration, "Length of each UDP send test")
+	flags.String("dns-name", defaultBenchDNSName, "Name to resolve in the DNS latency test")
+	flags.String("dns-server", "", "DNS server to time instead of the system resolver")
+	flags.Int("dns-queries", defaultBenchDNSQueries, "Lookups in the DNS latency test (0 = skip)")
+	flags.StringP("format", "f", formatTable, "Output format (table, json, yaml)")
+	flags.Bool("json", false, "Same as --format json")
+}
+
+// SelfBenchReport is the baseline of the local stack
+type SelfBenchReport struct {
+	LoopbackPMTU LoopbackBench  `json:"loopback_pmtu"`
+	UDPSend      UDPSendBench   `json:"udp_send"`
+	DNS          *DNSBench      `json:"dns,omitempty"` // Nil when skipped
+	Interfaces   InterfaceBench `json:"interfaces"`
+	Notes        []string       `json:"notes,omitempty"`
+}
+
+// LoopbackBench is Path MTU discovery against a local peer
+type LoopbackBench struct {
+	PMTU         int     `json:"pmtu,omitempty"`
+	Interface    string  `json:"interface,omitempty"`
+	InterfaceMTU int     `json:"interface_mtu,omitempty"`
+	Probes       int     `json:"probes,omitempty"`
+	ElapsedMS    float64 `json:"elapsed_ms"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// UDPSendBench is the UDP send rate with and without the rate limiter
+type UDPSendBench struct {
+	TargetPPS    int     `json:"target_pps"`
+	LimitedPPS   float64 `json:"limited_pps"`
+	UnlimitedPPS float64 `json:"unlimited_pps"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// DNSBench is resolver latency across repeated lookups
+type DNSBench struct {
+	Name    string           `json:"name"`
+	Server  string           `json:"server,omitempty"` // Empty for the system resolver
+	Failed  int              `json:"failed"`
+	Latency dns.LatencyStats `json:"latency"`
+	Error   string           `json:"error,omitempty"` // Last failure
+}
+
+// InterfaceBench is the time taken to enumerate interfaces
+type InterfaceBench struct {
+	Count  int     `json:"count"`
+	Runs   int     `json:"runs"`
+	MinMS  float64 `json:"min_ms"`
+	MeanMS float64 `json:"mean_ms"`
+	Error  string  `json:"error,omitempty"`
+}
+
+func runSelfBench(cmd *cobra.Command, args []string) error {
+	pps, _ := cmd.Flags().GetInt("pps")
+	duration, _ := cmd.Flags().GetDuration("duration")
+	dnsName, _ := cmd.Flags().GetString("dns-name")
+	dnsServer, _ := cmd.Flags().GetString("dns-server")
+	dnsQueries, _ := cmd.Flags().GetInt("dns-queries")
+	format, err := readOutputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	if pps <= 0 {
+		return errcode.Errorf(errcode.CLIUsage, "--pps must be positive")
+	}
+	if duration <= 0 {
+		return errcode.Errorf(errcode.CLIUsage, "--duration must be positive")
+	}
+	if dnsQueries < 0 {
+		return errcode.Errorf(errcode.CLIUsage, "--dns-queries must be non-negative")
+	}
+
+	plans := selfBenchPlans(pps, duration)
+	if dnsQueries > 0 {
+		plans = append(plans, dnsBenchPlan(dnsName, dnsQueries))
+	}
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		return outputDryRunPlans(plans, format)
+	}
+
+	// The DNS plan names what is looked up; the queries go to the resolver
+	targets := []string{family.Loopback()}
+	if dnsQueries > 0 && dnsServer != "" {
+		targets = append(targets, dnsServer)
+	}
+	if err := policy.CheckTargets(cmd.Context(), cmd.Flags(), targets...); err != nil {
+		return err
+	}
+	if err := recordProbeAudit(cmd, plans...); err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	report := &SelfBenchReport{
+		LoopbackPMTU: benchLoopbackPMTU(ctx),
+		UDPSend:      benchUDPSend(ctx, pps, duration),
+		Interfaces:   benchInterfaces(),
+	}
+	if dnsQueries > 0 {
+		bench := benchDNS(dnsName, dnsServer, dnsQueries)
+		report.DNS = &bench
+	}
+	report.Notes = selfBenchNotes(report)
+
+	if format.structured() {
+		return format.write(report)
+	}
+	writeSelfBenchTable(cmd.OutOrStdout(), report)
+	return nil
+}
+
+// selfBenchPlans describes the traffic of the loopback stages: discovery
+// against the peer, then the send test with and without the limiter
+func selfBenchPlans(pps int, duration time.Duration) []dryRunPlan {
+	discovery := newDryRunPlan(loopbackBenchOptions(0))
+	discovery.Port = 0 // The peer listens on an ephemeral port
+
+	plans := []dryRunPlan{discovery}
+	for i, rate := range []int{pps, unlimitedBenchPPS()} {
+		mode := "send rate through the limiter"
+		if i > 0 {
+			mode = "send rate without the limiter"
+		}
+		packets := maxUnlimitedBenchPackets
+		if rate > 0 {
+			packets = min(packets, int(math.Ceil(float64(rate)*duration.Seconds())))
+		}
+		plans = append(plans, dryRunPlan{
+			Target:              family.Loopback(),
+			Protocol:            "udp",
+			Mode:                mode,
+			MinSize:             benchUDPPayload,
+			MaxSize:             benchUDPPayload,
+			MaxProbes:           packets,
+			MaxPackets:          packets,
+			MaxBytes:            packets * benchUDPPayload,
+			PacketsPerSecond:    rate,
+			EstimatedDurationMS: duration.Milliseconds(),
+		})
+	}
+	return plans
+}
+
+// dnsBenchPlan describes the DNS stage's lookups of name. Sizes are of the
+// query: the header, the name in wire format, and the question's type and
+// class.
+func dnsBenchPlan(name string, queries int) dryRunPlan {
+	size := 12 + len(strings.TrimSuffix(name, ".")) + 2 + 4
+	return dryRunPlan{
+		Target:              name,
+		Protocol:            "dns",
+		Port:                53,
+		Mode:                "A lookups",
+		MinSize:             size,
+		MaxSize:             size,
+		MaxProbes:           queries,
+		MaxPackets:          queries,
+		MaxBytes:            queries * size,
+		EstimatedDurationMS: (time.Duration(queries) * benchDNSTimeout).Milliseconds(),
+	}
+}
+
+// unlimitedBenchPPS is the rate of the send test without a limiter: none,
+// unless policy caps every probe at max_pps
+func unlimitedBenchPPS() int {
+	return policy.Current().MaxPPS
+}
+
+// loopbackBenchOptions are the options of UDP discovery against a loopback
+// peer on port
+func loopbackBenchOptions(port int) discoveryOptions {
+	maxMTU := maxLoopbackProbe
+	if iface, ok := loopbackInterface(); ok {
+		maxMTU = min(maxMTU, iface.MTU)
+	}
+	loopback := family.Loopback()
+	ipv6 := net.ParseIP(loopback).To4() == nil
+	return discoveryOptions{
+		Destination: loopback,
+		IPv6:        ipv6,
+		Protocol:    "udp",
+		Port:        port,
+		MinMTU:      defaultMinMTU(ipv6),
+		MaxMTU:      maxMTU,
+		Timeout:     500 * time.Millisecond,
+		TTL:         64,
+		Quiet:       true,
+	}
+}
+
+// measureLoopbackPMTU runs UDP discovery against an in-process peer
+func measureLoopbackPMTU(ctx context.Context) LoopbackBench {
+	var bench LoopbackBench
+	if iface, ok := loopbackInterface(); ok {
+		bench.Interface, bench.InterfaceMTU = iface.Name, iface.MTU
+	}
+
+	conn, err := openPeerUDPListener(family.Loopback(), 0)
+	if err != nil {
+		bench.Error = err.Error()
+		return bench
+	}
+	peerCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = runUDPServer(peerCtx, conn, false, maxLoopbackProbe, NewRateLimiter(0))
+	}()
+	defer func() {
+		cancel()
+		<-done
+		_ = conn.Close()
+	}()
+
+	opts := loopbackBenchOptions(conn.LocalAddr().(*net.UDPAddr).Port)
+	start := time.Now()
+	result, err := performMTUDiscovery(ctx, opts)
+	bench.ElapsedMS = durationMS(time.Since(start))
+	if err != nil {
+		bench.Error = err.Error()
+		return bench
+	}
+	bench.PMTU, bench.Probes = result.PMTU, result.Hops
+	return bench
+}
+
+func loopbackInterface() (net.Interface, bool) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return net.Interface{}, false
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagLoopback != 0 && iface.Flags&net.FlagUp != 0 && iface.MTU > 0 {
+			return iface, true
+		}
+	}
+	return net.Interface{}, false
+}
+
+// measureUDPSend sends small datagrams to a loopback sink for duration, once
+// through the limiter at pps and once without it, or at max_pps under a
+// policy
+func measureUDPSend(ctx context.Context, pps int, duration time.Duration) UDPSendBench {
+	bench := UDPSendBench{TargetPPS: pps}
+
+	sink, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(family.Loopback())})
+	if err != nil {
+		bench.Error = err.Error()
+		return bench
+	}
+	defer func() { _ = sink.Close() }()
+	conn, err := net.DialUDP("udp", nil, sink.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		bench.Error = err.Error()
+		return bench
+	}
+	defer func() { _ = conn.Close() }()
+
+	if bench.LimitedPPS, err = sendRate(ctx, conn, NewRateLimiter(pps), duration); err != nil {
+		bench.Error = err.Error()
+		return bench
+	}
+	if bench.UnlimitedPPS, err = sendRate(ctx, conn, NewRateLimiter(unlimitedBenchPPS()), duration); err != nil {
+		bench.Error = err.Error()
+	}
+	return bench
+}
+
+func sendRate(ctx context.Context, conn *net.UDPConn, limiter *RateLimiter, duration time.Duration) (float64, error) {
+	payload := make([]byte, benchUDPPayload)
+	sent := 0
+	start := time.Now()
+	for time.Since(start) < duration && sent < maxUnlimitedBenchPackets && ctx.Err() == nil {
+		limiter.Wait()
+		if _, err := conn.Write(payload); err != nil {
+			return 0, fmt.Errorf("UDP send failed after %d packets: %w", sent, err)
+		}
+		sent++
+	}
+	return float64(sent) / time.Since(start).Seconds(), nil
+}
+
+// measureDNS times queries A lookups of name
+func measureDNS(name, server string, queries int) DNSBench {
+	bench := DNSBench{Name: name, Server: server}
+	opts := dns.DefaultLookupOptions()
+	opts.Server = server
+	opts.Timeout = benchDNSTimeout
+
+	var samples []time.Duration
+	for range queries {
+		start := time.Now()
+		if _, err := dns.Lookup(name, opts); err != nil {
+			bench.Failed++
+			bench.Error = err.Error()
+			continue
+		}
+		samples = append(samples, time.Since(start))
+	}
+	bench.Latency = dns.NewLatencyStats(samples, 0, nil)
+	return bench
+}
+
+// measureInterfaces times interface enumeration over a few runs
+func measureInterfaces() InterfaceBench {
+	bench := InterfaceBench{Runs: benchInterfaceRuns}
+	var total, fastest time.Duration
+	for run := range benchInterfaceRuns {
+		start := time.Now()
+		result, err := GetNetworkInterfaces()
+		elapsed := time.Since(start)
+		if err != nil {
+			bench.Error = err.Error()
+			return bench
+		}
+		bench.Count = len(result.Interfaces)
+		total += elapsed
+		if run == 0 || elapsed < fastest {
+			fastest = elapsed
+		}
+	}
+	bench.MinMS = durationMS(fastest)
+	bench.MeanMS = durationMS(total / benchInterfaceRuns)
+	return bench
+}
+
+// selfBenchNotes flags results slow enough to distort other commands
+func selfBenchNotes(report *SelfBenchReport) []string {
+	var notes []string
+	if pmtu := report.LoopbackPMTU; pmtu.Error == "" && pmtu.InterfaceMTU > 0 && pmtu.PMTU < min(pmtu.InterfaceMTU, maxLoopbackProbe) {
+		notes = append(notes, fmt.Sprintf("Loopback PMTU %d is under the %s MTU of %d: local firewall rules or socket limits cap probe sizes, so discovered PMTUs may be the host's limit rather than the path's", pmtu.PMTU, pmtu.Interface, pmtu.InterfaceMTU))
+	}
+	if send := report.UDPSend; send.Error == "" && send.LimitedPPS < 0.9*float64(send.TargetPPS) {
+		notes = append(notes, fmt.Sprintf("The limiter reached %.0f of %d pps: probing at this rate takes longer than planned because of the host, not the network", send.LimitedPPS, send.TargetPPS))
+	}
+	if bench := report.DNS; bench != nil && bench.Latency.Count > 0 && bench.Latency.P50MS > 50 {
+		notes = append(notes, fmt.Sprintf("The resolver takes %.1f ms for a typical lookup: target names resolve slowly before any probe is sent", bench.Latency.P50MS))
+	}
+	if bench := report.Interfaces; bench.Error == "" && bench.MeanMS > 100 {
+		notes = append(notes, fmt.Sprintf("Enumerating interfaces takes %.1f ms: commands that inspect interfaces start slowly", bench.MeanMS))
+	}
+	return notes
+}
+
+func writeSelfBenchTable(w io.Writer, report *SelfBenchReport) {
+	_, _ = fmt.Fprintf(w, "%-16s %s\n", "Stage", "Result")
+	_, _ = fmt.Fprintf(w, "%-16s %s\n", "----------------", "------")
+
+	pmtu := report.LoopbackPMTU
+	switch {
+	case pmtu.Error != "":
+		_, _ = fmt.Fprintf(w, "%-16s failed: %s\n", "Loopback PMTU", pmtu.Error)
+	case pmtu.Interface != "":
+		_, _ = fmt.Fprintf(w, "%-16s %d (%s MTU %d), %d probes in %.1f ms\n", "Loopback PMTU", pmtu.PMTU, pmtu.Interface, pmtu.InterfaceMTU, pmtu.Probes, pmtu.ElapsedMS)
+	default:
+		_, _ = fmt.Fprintf(w, "%-16s %d, %d probes in %.1f ms\n", "Loopback PMTU", pmtu.PMTU, pmtu.Probes, pmtu.ElapsedMS)
+	}
+
+	if send := report.UDPSend; send.Error != "" {
+		_, _ = fmt.Fprintf(w, "%-16s failed: %s\n", "UDP send", send.Error)
+	} else {
+		_, _ = fmt.Fprintf(w, "%-16s %.0f of %d pps under the limiter, %.0f pps unlimited\n", "UDP send", send.LimitedPPS, send.TargetPPS, send.UnlimitedPPS)
+	}
+
+	if bench := report.DNS; bench != nil {
+		resolver := "system resolver"
+		if bench.Server != "" {
+			resolver = bench.Server
+		}
+		queries := bench.Latency.Count + bench.Failed
+		if bench.Latency.Count == 0 {
+			_, _ = fmt.Fprintf(w, "%-16s %s via %s: all %d lookups failed: %s\n", "DNS resolver", bench.Name, resolver, queries, bench.Error)
+		} else {
+			_, _ = fmt.Fprintf(w, "%-16s %s via %s: p50 %.1f ms, max %.1f ms, %d of %d failed\n", "DNS resolver", bench.Name, resolver, bench.Latency.P50MS, bench.Latency.MaxMS, bench.Failed, queries)
+		}
+	}
+
+	if bench := report.Interfaces; bench.Error != "" {
+		_, _ = fmt.Fprintf(w, "%-16s failed: %s\n", "Interfaces", bench.Error)
+	} else {
+		_, _ = fmt.Fprintf(w, "%-16s %d in %.1f ms (fastest of %d runs %.1f ms)\n", "Interfaces", bench.Count, bench.MeanMS, bench.Runs, bench.MinMS)
+	}
+
+	if len(report.Notes) > 0 {
+		_, _ = fmt.Fprintf(w, "\nNotes:\n")
+		for _, note := range report.Notes {
+			_, _ = fmt.Fprintf(w, "  - %s\n", note)
+		}
+	}
+}
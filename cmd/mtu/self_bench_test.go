@@ -0,0 +1,194 @@
+package mtu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/audit"
+	"github.com/euan-cowie/cidrator/internal/dns"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+)
+
+func newSelfBenchTestCommand() *cobra.Command {
+	cmd := &cobra.Command{Use: "self"}
+	flags := cmd.Flags()
+	flags.Int("pps", defaultBenchPPS, "")
+	flags.Duration("duration", defaultBenchDuration, "")
+	flags.String("dns-name", defaultBenchDNSName, "")
+	flags.String("dns-server", "", "")
+	flags.Int("dns-queries", defaultBenchDNSQueries, "")
+	flags.Bool("json", false, "")
+	flags.Bool("dry-run", false, "")
+	flags.Bool("allow-doc-ranges", false, "")
+	return cmd
+}
+
+func stubSelfBench(t *testing.T) {
+	t.Helper()
+	loopback, udpSend, dnsStage, interfaces := benchLoopbackPMTU, benchUDPSend, benchDNS, benchInterfaces
+	t.Cleanup(func() {
+		benchLoopbackPMTU, benchUDPSend, benchDNS, benchInterfaces = loopback, udpSend, dnsStage, interfaces
+	})
+
+	benchLoopbackPMTU = func(ctx context.Context) LoopbackBench {
+		return LoopbackBench{PMTU: 9000, Interface: "lo", InterfaceMTU: 65536, Probes: 12, ElapsedMS: 3.5}
+	}
+	benchUDPSend = func(ctx context.Context, pps int, duration time.Duration) UDPSendBench {
+		return UDPSendBench{TargetPPS: pps, LimitedPPS: float64(pps) / 2, UnlimitedPPS: 250000}
+	}
+	benchDNS = func(name, server string, queries int) DNSBench {
+		samples := make([]time.Duration, queries)
+		for i := range samples {
+			samples[i] = 80 * time.Millisecond
+		}
+		return DNSBench{Name: name, Server: server, Latency: dns.NewLatencyStats(samples, 0, nil)}
+	}
+	benchInterfaces = func() InterfaceBench {
+		return InterfaceBench{Count: 3, Runs: benchInterfaceRuns, MinMS: 0.2, MeanMS: 0.3}
+	}
+}
+
+func TestRunSelfBench(t *testing.T) {
+	stubSelfBench(t)
+
+	var out bytes.Buffer
+	cmd := newSelfBenchTestCommand()
+	cmd.SetOut(&out)
+	mustSetFlag(t, cmd, "dns-server", "192.0.2.53")
+	mustSetFlag(t, cmd, "allow-doc-ranges", "true")
+	if err := runSelfBench(cmd, nil); err != nil {
+		t.Fatalf("runSelfBench returned error: %v", err)
+	}
+
+	for _, expected := range []string{
+		"Loopback PMTU    9000 (lo MTU 65536), 12 probes in 3.5 ms",
+		"UDP send         500 of 1000 pps under the limiter, 250000 pps unlimited",
+		"DNS resolver     example.com via 192.0.2.53: p50 80.0 ms",
+		"Interfaces       3 in 0.3 ms",
+		"Loopback PMTU 9000 is under the lo MTU of 65536",
+		"The limiter reached 500 of 1000 pps",
+		"The resolver takes 80.0 ms",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected report to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestRunSelfBenchJSONSkipsDNS(t *testing.T) {
+	stubSelfBench(t)
+	benchDNS = func(name, server string, queries int) DNSBench {
+		t.Fatal("--dns-queries 0 should skip the DNS stage")
+		return DNSBench{}
+	}
+
+	cmd := newSelfBenchTestCommand()
+	mustSetFlag(t, cmd, "dns-queries", "0")
+	mustSetFlag(t, cmd, "json", "true")
+	output, err := captureStdout(t, func() error { return runSelfBench(cmd, nil) })
+	if err != nil {
+		t.Fatalf("runSelfBench returned error: %v", err)
+	}
+
+	var report SelfBenchReport
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, output)
+	}
+	if report.DNS != nil || report.LoopbackPMTU.PMTU != 9000 || report.Interfaces.Count != 3 || len(report.Notes) != 2 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func TestRunSelfBenchDryRunAndAudit(t *testing.T) {
+	stubSelfBench(t)
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	audit.SetPath(auditPath)
+	t.Cleanup(func() { audit.SetPath("") })
+
+	cmd := newSelfBenchTestCommand()
+	mustSetFlag(t, cmd, "dry-run", "true")
+	mustSetFlag(t, cmd, "json", "true")
+	mustSetFlag(t, cmd, "pps", "100")
+	mustSetFlag(t, cmd, "duration", "2s")
+	benchUDPSend = func(ctx context.Context, pps int, duration time.Duration) UDPSendBench {
+		t.Fatal("--dry-run should not send")
+		return UDPSendBench{}
+	}
+	output, err := captureStdout(t, func() error { return runSelfBench(cmd, nil) })
+	if err != nil {
+		t.Fatalf("runSelfBench returned error: %v", err)
+	}
+	var plans []dryRunPlan
+	if err := json.Unmarshal([]byte(output), &plans); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, output)
+	}
+	if len(plans) != 4 || plans[1].MaxPackets != 200 || plans[2].MaxPackets != maxUnlimitedBenchPackets || plans[3].Protocol != "dns" || plans[3].MaxPackets != defaultBenchDNSQueries {
+		t.Fatalf("unexpected plans: %+v", plans)
+	}
+	if _, err := os.Stat(auditPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no audit entry for a dry run, got %v", err)
+	}
+
+	stubSelfBench(t)
+	cmd = newSelfBenchTestCommand()
+	mustSetFlag(t, cmd, "dns-server", "192.0.2.53")
+	if err := runSelfBench(cmd, nil); errcode.Of(err) != errcode.CLIDocumentationRange {
+		t.Fatalf("expected CLI011 for a documentation-range --dns-server, got %v", err)
+	}
+
+	cmd = newSelfBenchTestCommand()
+	cmd.SetOut(&bytes.Buffer{})
+	mustSetFlag(t, cmd, "pps", "100")
+	mustSetFlag(t, cmd, "duration", "2s")
+	if err := runSelfBench(cmd, nil); err != nil {
+		t.Fatalf("runSelfBench returned error: %v", err)
+	}
+	entries, err := audit.ReadAll(auditPath)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one audit entry, got %+v (%v)", entries, err)
+	}
+	want := plans[0].MaxPackets + 200 + maxUnlimitedBenchPackets + defaultBenchDNSQueries
+	if got := entries[0]; got.Command != "self" || got.Packets != want || got.Protocol != "udp,dns" {
+		t.Fatalf("unexpected audit entry: %+v, want %d packets", got, want)
+	}
+}
+
+func TestRunSelfBenchRejectsBadFlags(t *testing.T) {
+	for flag, value := range map[string]string{"pps": "0", "duration": "0s", "dns-queries": "-1"} {
+		cmd := newSelfBenchTestCommand()
+		mustSetFlag(t, cmd, flag, value)
+		if err := runSelfBench(cmd, nil); errcode.Of(err) != errcode.CLIUsage {
+			t.Errorf("--%s %s: expected CLI002, got %v", flag, value, err)
+		}
+	}
+}
+
+func TestMeasureUDPSend(t *testing.T) {
+	bench := measureUDPSend(context.Background(), 200, 100*time.Millisecond)
+	if bench.Error != "" {
+		t.Skipf("loopback UDP unavailable: %s", bench.Error)
+	}
+	if bench.LimitedPPS <= 0 || bench.LimitedPPS > 220 {
+		t.Errorf("expected the limiter to hold to about 200 pps, got %.0f", bench.LimitedPPS)
+	}
+	if bench.UnlimitedPPS <= bench.LimitedPPS {
+		t.Errorf("expected unlimited sending to beat the limiter, got %.0f vs %.0f", bench.UnlimitedPPS, bench.LimitedPPS)
+	}
+}
+
+func TestMeasureLoopbackPMTU(t *testing.T) {
+	bench := measureLoopbackPMTU(context.Background())
+	if bench.Error != "" {
+		t.Skipf("loopback discovery unavailable: %s", bench.Error)
+	}
+	if bench.PMTU < defaultMinMTU(false) || bench.PMTU > maxLoopbackProbe || bench.Probes == 0 {
+		t.Errorf("unexpected loopback result: %+v", bench)
+	}
+}
@@ -13,12 +13,15 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/euan-cowie/cidrator/internal/audit"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/family"
+	"github.com/euan-cowie/cidrator/internal/units"
 	"github.com/spf13/cobra"
 )
 
 const (
 	defaultPeerPort          = 4821
-	defaultPeerListenAddress = "127.0.0.1"
 	defaultPeerMaxPacketSize = 9216
 	defaultPeerResponsePPS   = 100
 )
@@ -114,23 +117,18 @@ want application-to-application MTU validation. Use it with
 endpoint.
 
 Safety defaults:
-- Binds to 127.0.0.1 by default
+- Binds to 127.0.0.1 by default, or ::1 on hosts without IPv4 loopback
 - Requires --allow-remote for non-loopback addresses
-- Rate-limits responses and caps echoed packet size
-
-Examples:
-  cidrator mtu peer
-  cidrator mtu peer --proto udp --listen 0.0.0.0 --allow-remote
-  cidrator mtu discover branch-office.example.com --proto udp --port 4821`,
+- Rate-limits responses and caps echoed packet size`,
 	RunE: runPeer,
 }
 
 func init() {
 	peerCmd.Flags().Int("port", defaultPeerPort, "Port to listen on")
 	peerCmd.Flags().String("proto", "udp,tcp", "Protocols to serve (udp, tcp, or udp,tcp)")
-	peerCmd.Flags().String("listen", defaultPeerListenAddress, "Listen address (defaults to localhost only)")
+	peerCmd.Flags().String("listen", "", "Listen address (default: 127.0.0.1, or ::1 on hosts without IPv4 loopback)")
 	peerCmd.Flags().Bool("allow-remote", false, "Allow binding to non-loopback addresses for controlled remote testing")
-	peerCmd.Flags().Int("max-packet-size", defaultPeerMaxPacketSize, "Maximum bytes echoed per packet or read")
+	units.Size(peerCmd.Flags(), "max-packet-size", defaultPeerMaxPacketSize, "Maximum bytes echoed per packet or read")
 	peerCmd.Flags().Int("response-pps", defaultPeerResponsePPS, "Maximum responses per second across all protocols (0 = unlimited)")
 	peerCmd.Flags().Bool("verbose", false, "Log accepted packets and dropped oversized packets")
 }
@@ -149,23 +147,36 @@ func runPeerWithRuntime(cmd *cobra.Command, runtime peerRuntime) error {
 	verbose, _ := cmd.Flags().GetBool("verbose")
 
 	if port < 1 || port > 65535 {
-		return fmt.Errorf("--port must be between 1 and 65535")
+		return errcode.Errorf(errcode.CLIUsage, "--port must be between 1 and 65535")
 	}
 	if maxPacketSize <= 0 {
-		return fmt.Errorf("--max-packet-size must be positive")
+		return errcode.Errorf(errcode.CLIUsage, "--max-packet-size must be positive")
 	}
 	if responsePPS < 0 {
-		return fmt.Errorf("--response-pps must be non-negative")
+		return errcode.Errorf(errcode.CLIUsage, "--response-pps must be non-negative")
 	}
 
 	protocols, err := parsePeerProtocols(proto)
 	if err != nil {
 		return err
 	}
+	if listenAddr == "" {
+		listenAddr = family.Loopback()
+	}
 	if err := validatePeerListenAddress(listenAddr, allowRemote); err != nil {
 		return err
 	}
 
+	// The endpoint only answers, so how many packets it sends is up to the
+	// peers; the entry records where it listened
+	if err := audit.Record(audit.Entry{
+		Command:  cmd.CommandPath(),
+		Targets:  []string{net.JoinHostPort(listenAddr, strconv.Itoa(port))},
+		Protocol: protocols.String(),
+	}); err != nil {
+		return err
+	}
+
 	if runtime.newContext == nil {
 		runtime.newContext = defaultPeerRuntime.newContext
 	}
@@ -235,7 +246,7 @@ func runPeerWithRuntime(cmd *cobra.Command, runtime peerRuntime) error {
 			defer wg.Done()
 			if serveErr := runtime.runUDP(ctx, udpConn, verbose, maxPacketSize, limiter); serveErr != nil && ctx.Err() == nil {
 				select {
-				case errCh <- fmt.Errorf("udp peer error: %w", serveErr):
+				case errCh <- errcode.Wrap(errcode.MTUPeerFailed, fmt.Errorf("udp peer error: %w", serveErr)):
 				default:
 				}
 			}
@@ -249,7 +260,7 @@ func runPeerWithRuntime(cmd *cobra.Command, runtime peerRuntime) error {
 			defer wg.Done()
 			if serveErr := runtime.runTCP(ctx, tcpListener, verbose, maxPacketSize, limiter); serveErr != nil && ctx.Err() == nil {
 				select {
-				case errCh <- fmt.Errorf("tcp peer error: %w", serveErr):
+				case errCh <- errcode.Wrap(errcode.MTUPeerFailed, fmt.Errorf("tcp peer error: %w", serveErr)):
 				default:
 				}
 			}
@@ -291,12 +302,12 @@ func parsePeerProtocols(raw string) (peerProtocolSet, error) {
 			protocols.tcp = true
 		case "":
 		default:
-			return peerProtocolSet{}, fmt.Errorf("unsupported peer protocol %q: use tcp, udp, or tcp,udp", entry)
+			return peerProtocolSet{}, errcode.Errorf(errcode.MTUUnsupportedProtocol, "unsupported peer protocol %q: use tcp, udp, or tcp,udp", entry)
 		}
 	}
 
 	if !protocols.udp && !protocols.tcp {
-		return peerProtocolSet{}, fmt.Errorf("at least one peer protocol must be selected")
+		return peerProtocolSet{}, errcode.Errorf(errcode.CLIUsage, "at least one peer protocol must be selected")
 	}
 
 	return protocols, nil
@@ -304,7 +315,7 @@ func parsePeerProtocols(raw string) (peerProtocolSet, error) {
 
 func validatePeerListenAddress(listenAddr string, allowRemote bool) error {
 	if listenAddr == "" {
-		return fmt.Errorf("--listen must not be empty")
+		return errcode.Errorf(errcode.CLIUsage, "--listen must not be empty")
 	}
 
 	ips, err := resolvePeerListenIPs(listenAddr)
@@ -318,7 +329,7 @@ func validatePeerListenAddress(listenAddr string, allowRemote bool) error {
 
 	for _, ip := range ips {
 		if !ip.IsLoopback() {
-			return fmt.Errorf("refusing to bind peer endpoint to %q without --allow-remote; advanced mode defaults to localhost for safety", listenAddr)
+			return errcode.Errorf(errcode.CLIUsage, "refusing to bind peer endpoint to %q without --allow-remote; advanced mode defaults to localhost for safety", listenAddr)
 		}
 	}
 
@@ -340,7 +351,7 @@ func openPeerUDPListener(listenAddr string, port int) (*net.UDPConn, error) {
 
 	conn, err := listenPeerUDP("udp", addr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to start UDP peer endpoint: %w", err)
+		return nil, errcode.Wrap(errcode.MTUPeerFailed, fmt.Errorf("failed to start UDP peer endpoint: %w", err))
 	}
 	return conn, nil
 }
@@ -348,7 +359,7 @@ func openPeerUDPListener(listenAddr string, port int) (*net.UDPConn, error) {
 func openPeerTCPListener(listenAddr string, port int) (net.Listener, error) {
 	listener, err := listenPeerTCP("tcp", net.JoinHostPort(listenAddr, strconv.Itoa(port)))
 	if err != nil {
-		return nil, fmt.Errorf("failed to start TCP peer endpoint: %w", err)
+		return nil, errcode.Wrap(errcode.MTUPeerFailed, fmt.Errorf("failed to start TCP peer endpoint: %w", err))
 	}
 	return listener, nil
 }
@@ -10,6 +10,10 @@ import (
 	"golang.org/x/sys/unix"
 )
 
+// socketFeatures lists the probe socket options macOS implements; it has no
+// IP_MTU to read the cached path MTU
+var socketFeatures = platformSocketFeatures{DontFragment: true, SetMSS: true, ReadMSS: true, TCPTimestamps: true}
+
 const tcpciOptTimestamps = 0x00000001
 
 var darwinSetsockoptInt = unix.SetsockoptInt
@@ -87,6 +91,20 @@ func setTCPMSS(fd uintptr, mss int) error {
 	return darwinSetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_MAXSEG, mss)
 }
 
+// setReuseAddr lets a fixed probe source port be bound again while the
+// previous probe's socket is still closing
+func setReuseAddr(fd uintptr) error {
+	return darwinSetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1)
+}
+
+// setHopLimit sets the TTL, or IPv6 hop limit, of the socket's packets
+func setHopLimit(fd uintptr, ipv6 bool, ttl int) error {
+	if ipv6 {
+		return darwinSetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_UNICAST_HOPS, ttl)
+	}
+	return darwinSetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TTL, ttl)
+}
+
 // getTCPMSS retrieves the current effective MSS for the connection.
 // This allows us to detect if the kernel negotiated a smaller MSS than our probe size.
 func getTCPMSS(conn net.Conn) (int, error) {
@@ -155,3 +173,8 @@ func tcpTimestampsEnabled(conn net.Conn) (bool, error) {
 	}
 	return enabled, nil
 }
+
+// getPathMTU is not available on macOS, which has no IP_MTU socket option
+func getPathMTU(conn *net.UDPConn, ipv6 bool) (int, error) {
+	return 0, fmt.Errorf("platform not supported")
+}
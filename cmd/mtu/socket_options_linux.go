@@ -10,6 +10,9 @@ import (
 	"golang.org/x/sys/unix"
 )
 
+// socketFeatures lists the probe socket options Linux implements: all of them
+var socketFeatures = platformSocketFeatures{DontFragment: true, SetMSS: true, ReadMSS: true, TCPTimestamps: true, PathMTU: true}
+
 // Linux constants for MTU discovery
 const (
 	IP_MTU_DISCOVER   = 10
@@ -96,6 +99,20 @@ func setTCPMSS(fd uintptr, mss int) error {
 	return linuxSetsockoptInt(int(fd), syscall.IPPROTO_TCP, syscall.TCP_MAXSEG, mss)
 }
 
+// setReuseAddr lets a fixed probe source port be bound again while the
+// previous probe's socket is still closing
+func setReuseAddr(fd uintptr) error {
+	return linuxSetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1)
+}
+
+// setHopLimit sets the TTL, or IPv6 hop limit, of the socket's packets
+func setHopLimit(fd uintptr, ipv6 bool, ttl int) error {
+	if ipv6 {
+		return linuxSetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_UNICAST_HOPS, ttl)
+	}
+	return linuxSetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TTL, ttl)
+}
+
 // getTCPMSS retrieves the current effective MSS for the connection.
 // This allows us to detect if the kernel negotiated a smaller MSS than our probe size.
 func getTCPMSS(conn net.Conn) (int, error) {
@@ -165,3 +182,26 @@ func tcpTimestampsEnabled(conn net.Conn) (bool, error) {
 	}
 	return enabled, nil
 }
+
+// getPathMTU returns the path MTU the kernel has cached for a connected
+// socket's destination, which ICMP Fragmentation Needed errors lower
+func getPathMTU(conn *net.UDPConn, ipv6 bool) (int, error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get syscall conn: %w", err)
+	}
+
+	var mtu int
+	var sockErr error
+	err = rawConn.Control(func(f uintptr) {
+		if ipv6 {
+			mtu, sockErr = linuxGetsockoptInt(int(f), unix.IPPROTO_IPV6, unix.IPV6_MTU)
+		} else {
+			mtu, sockErr = linuxGetsockoptInt(int(f), unix.IPPROTO_IP, unix.IP_MTU)
+		}
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to control raw conn: %w", err)
+	}
+	return mtu, sockErr
+}
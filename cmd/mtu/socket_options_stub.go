@@ -1,4 +1,4 @@
-//go:build !linux && !darwin
+//go:build !linux && !darwin && !windows
 
 package mtu
 
@@ -7,6 +7,9 @@ import (
 	"net"
 )
 
+// socketFeatures is empty on unsupported platforms
+var socketFeatures platformSocketFeatures
+
 // setIPv4DontFragment is a stub for unsupported platforms
 func setIPv4DontFragment(conn net.Conn) error {
 	return fmt.Errorf("platform not supported")
@@ -19,7 +22,17 @@ func setIPv6DontFragment(conn net.Conn) error {
 
 // setTCPMSS is a stub for unsupported platforms
 func setTCPMSS(fd uintptr, mss int) error {
-	return nil // No-op on unsupported platforms
+	return errTCPMSSUnsupported
+}
+
+// setReuseAddr is a stub for unsupported platforms
+func setReuseAddr(fd uintptr) error {
+	return nil
+}
+
+// setHopLimit is a stub for unsupported platforms
+func setHopLimit(fd uintptr, ipv6 bool, ttl int) error {
+	return fmt.Errorf("setting the TTL is not supported on this platform")
 }
 
 // getTCPMSS is a stub for unsupported platforms
@@ -31,3 +44,8 @@ func getTCPMSS(conn net.Conn) (int, error) {
 func tcpTimestampsEnabled(conn net.Conn) (bool, error) {
 	return false, nil
 }
+
+// getPathMTU is a stub for unsupported platforms
+func getPathMTU(conn *net.UDPConn, ipv6 bool) (int, error) {
+	return 0, fmt.Errorf("platform not supported")
+}
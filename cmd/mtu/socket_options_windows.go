@@ -0,0 +1,130 @@
+//go:build windows
+
+package mtu
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// Winsock options from ws2ipdef.h that golang.org/x/sys/windows does not define
+const (
+	IP_DONTFRAGMENT = 14
+	IPV6_DONTFRAG   = 14
+	IP_MTU          = 73 // Windows 10 1703 and later
+	IPV6_MTU        = 72
+)
+
+// socketFeatures lists the probe socket options Windows implements. Winsock
+// reads TCP_MAXSEG but cannot set it, and does not report TCP timestamps.
+var socketFeatures = platformSocketFeatures{DontFragment: true, ReadMSS: true, PathMTU: true}
+
+var windowsSetsockoptInt = windows.SetsockoptInt
+
+var windowsGetsockoptInt = windows.GetsockoptInt
+
+// windowsSyscallConn returns the raw connection of the socket types probes use
+func windowsSyscallConn(conn net.Conn) (syscall.RawConn, error) {
+	var rawConn syscall.RawConn
+	var err error
+	switch c := conn.(type) {
+	case *net.IPConn:
+		rawConn, err = c.SyscallConn()
+	case *net.UDPConn:
+		rawConn, err = c.SyscallConn()
+	case *net.TCPConn:
+		rawConn, err = c.SyscallConn()
+	default:
+		return nil, fmt.Errorf("unsupported connection type: %T", conn)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get syscall conn: %w", err)
+	}
+	return rawConn, nil
+}
+
+// setIPv4DontFragment sets DF flag for IPv4 on Windows
+func setIPv4DontFragment(conn net.Conn) error {
+	return setWindowsSocketOption(conn, windows.IPPROTO_IP, IP_DONTFRAGMENT, 1)
+}
+
+// setIPv6DontFragment sets DF flag for IPv6 on Windows
+func setIPv6DontFragment(conn net.Conn) error {
+	return setWindowsSocketOption(conn, windows.IPPROTO_IPV6, IPV6_DONTFRAG, 1)
+}
+
+func setWindowsSocketOption(conn net.Conn, level, option, value int) error {
+	rawConn, err := windowsSyscallConn(conn)
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	err = rawConn.Control(func(f uintptr) {
+		sockErr = windowsSetsockoptInt(windows.Handle(f), level, option, value)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to control raw conn: %w", err)
+	}
+	return sockErr
+}
+
+// setTCPMSS is not available on Windows, where TCP_MAXSEG is read-only
+func setTCPMSS(fd uintptr, mss int) error {
+	return errTCPMSSUnsupported
+}
+
+// setReuseAddr does nothing on Windows, where SO_REUSEADDR lets another
+// socket steal a port that is still in use
+func setReuseAddr(fd uintptr) error {
+	return nil
+}
+
+// setHopLimit sets the TTL, or IPv6 hop limit, of the socket's packets
+func setHopLimit(fd uintptr, ipv6 bool, ttl int) error {
+	if ipv6 {
+		return windowsSetsockoptInt(windows.Handle(fd), windows.IPPROTO_IPV6, windows.IPV6_UNICAST_HOPS, ttl)
+	}
+	return windowsSetsockoptInt(windows.Handle(fd), windows.IPPROTO_IP, windows.IP_TTL, ttl)
+}
+
+// getTCPMSS retrieves the current effective MSS for the connection
+func getTCPMSS(conn net.Conn) (int, error) {
+	if _, ok := conn.(*net.TCPConn); !ok {
+		return 0, fmt.Errorf("unsupported connection type for TCP MSS: %T", conn)
+	}
+	return getWindowsSocketOption(conn, windows.IPPROTO_TCP, windows.TCP_MAXSEG)
+}
+
+// tcpTimestampsEnabled reports false: Winsock does not say whether the
+// connection negotiated TCP timestamps
+func tcpTimestampsEnabled(conn net.Conn) (bool, error) {
+	return false, nil
+}
+
+// getPathMTU returns the path MTU Windows has cached for a connected socket's
+// destination, which ICMP Fragmentation Needed errors lower
+func getPathMTU(conn *net.UDPConn, ipv6 bool) (int, error) {
+	if ipv6 {
+		return getWindowsSocketOption(conn, windows.IPPROTO_IPV6, IPV6_MTU)
+	}
+	return getWindowsSocketOption(conn, windows.IPPROTO_IP, IP_MTU)
+}
+
+func getWindowsSocketOption(conn net.Conn, level, option int) (int, error) {
+	rawConn, err := windowsSyscallConn(conn)
+	if err != nil {
+		return 0, err
+	}
+	var value int
+	var sockErr error
+	err = rawConn.Control(func(f uintptr) {
+		value, sockErr = windowsGetsockoptInt(windows.Handle(f), level, option)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to control raw conn: %w", err)
+	}
+	return value, sockErr
+}
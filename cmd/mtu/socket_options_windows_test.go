@@ -0,0 +1,83 @@
+//go:build windows
+
+package mtu
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestWindowsSocketOptionsRejectUnsupportedConnTypes(t *testing.T) {
+	client, _ := net.Pipe()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	if err := setIPv4DontFragment(client); err == nil || !strings.Contains(err.Error(), "unsupported connection type") {
+		t.Fatalf("expected unsupported IPv4 DF error, got %v", err)
+	}
+	if err := setIPv6DontFragment(client); err == nil || !strings.Contains(err.Error(), "unsupported connection type") {
+		t.Fatalf("expected unsupported IPv6 DF error, got %v", err)
+	}
+	if _, err := getTCPMSS(client); err == nil || !strings.Contains(err.Error(), "unsupported connection type") {
+		t.Fatalf("expected unsupported TCP MSS error, got %v", err)
+	}
+	if err := setTCPMSS(10, 1400); !errors.Is(err, errTCPMSSUnsupported) {
+		t.Fatalf("expected setTCPMSS to be unsupported, got %v", err)
+	}
+}
+
+func TestWindowsSocketOptionsUseInjectedSyscalls(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("failed to open UDP socket: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	originalSet := windowsSetsockoptInt
+	originalGet := windowsGetsockoptInt
+	t.Cleanup(func() {
+		windowsSetsockoptInt = originalSet
+		windowsGetsockoptInt = originalGet
+	})
+
+	var sawLevel, sawOption int
+	windowsSetsockoptInt = func(fd windows.Handle, level, opt, value int) error {
+		sawLevel, sawOption = level, opt
+		return nil
+	}
+
+	if err := setIPv4DontFragment(conn); err != nil {
+		t.Fatalf("setIPv4DontFragment returned error: %v", err)
+	}
+	if sawLevel != windows.IPPROTO_IP || sawOption != IP_DONTFRAGMENT {
+		t.Fatalf("setIPv4DontFragment used level %d opt %d, want %d %d", sawLevel, sawOption, windows.IPPROTO_IP, IP_DONTFRAGMENT)
+	}
+
+	if err := setIPv6DontFragment(conn); err != nil {
+		t.Fatalf("setIPv6DontFragment returned error: %v", err)
+	}
+	if sawLevel != windows.IPPROTO_IPV6 || sawOption != IPV6_DONTFRAG {
+		t.Fatalf("setIPv6DontFragment used level %d opt %d, want %d %d", sawLevel, sawOption, windows.IPPROTO_IPV6, IPV6_DONTFRAG)
+	}
+
+	windowsGetsockoptInt = func(fd windows.Handle, level, opt int) (int, error) {
+		if level != windows.IPPROTO_IP || opt != IP_MTU {
+			t.Fatalf("getPathMTU used level %d opt %d, want %d %d", level, opt, windows.IPPROTO_IP, IP_MTU)
+		}
+		return 1400, nil
+	}
+	mtu, err := getPathMTU(conn, false)
+	if err != nil {
+		t.Fatalf("getPathMTU returned error: %v", err)
+	}
+	if mtu != 1400 {
+		t.Fatalf("getPathMTU returned %d, want 1400", mtu)
+	}
+}
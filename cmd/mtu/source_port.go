@@ -0,0 +1,91 @@
+package mtu
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// Source port selection modes for TCP and UDP probes
+const (
+	SourcePortRandom     = "random"     // Kernel-assigned ephemeral ports, which the OS randomizes
+	SourcePortFixed      = "fixed"      // Every probe leaves from --src-port
+	SourcePortSequential = "sequential" // Probes count up from --src-port, wrapping back to it after 65535
+)
+
+// defaultSequentialSourcePort is where sequential mode starts without --src-port,
+// matching the traceroute convention firewall rules are often written for
+const defaultSequentialSourcePort = 33434
+
+// SourcePortSelector hands out local ports for successive probes. A nil
+// selector leaves the choice to the kernel.
+type SourcePortSelector struct {
+	mode string
+	base int
+
+	mu   sync.Mutex
+	next int
+}
+
+// NewSourcePortSelector validates a mode and port. It returns nil for random
+// mode, so callers can treat "no selector" and "random" the same way.
+func NewSourcePortSelector(mode string, port int) (*SourcePortSelector, error) {
+	if port < 0 || port > 65535 {
+		return nil, errcode.Errorf(errcode.CLIUsage, "--src-port must be between 1 and 65535")
+	}
+	switch mode {
+	case "", SourcePortRandom:
+		if port != 0 {
+			return nil, errcode.Errorf(errcode.CLIUsage, "--src-port cannot be used with --src-port-mode %s", SourcePortRandom)
+		}
+		return nil, nil
+	case SourcePortFixed:
+		if port == 0 {
+			return nil, errcode.Errorf(errcode.CLIUsage, "--src-port-mode %s requires --src-port", SourcePortFixed)
+		}
+	case SourcePortSequential:
+		if port == 0 {
+			port = defaultSequentialSourcePort
+		}
+	default:
+		return nil, errcode.Errorf(errcode.CLIUsage, "unsupported --src-port-mode %q (use %s, %s, or %s)", mode, SourcePortRandom, SourcePortFixed, SourcePortSequential)
+	}
+	return &SourcePortSelector{mode: mode, base: port, next: port}, nil
+}
+
+// Fixed reports whether every probe uses the same source port
+func (s *SourcePortSelector) Fixed() bool {
+	return s != nil && s.mode == SourcePortFixed
+}
+
+// Next returns the local port for the next probe, or 0 to let the kernel choose
+func (s *SourcePortSelector) Next() int {
+	if s == nil {
+		return 0
+	}
+	if s.mode == SourcePortFixed {
+		return s.base
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	port := s.next
+	s.next++
+	if s.next > 65535 {
+		s.next = s.base
+	}
+	return port
+}
+
+// String describes the selection for dry-run plans
+func (s *SourcePortSelector) String() string {
+	switch {
+	case s == nil:
+		return SourcePortRandom
+	case s.mode == SourcePortFixed:
+		return fmt.Sprintf("%s %d", SourcePortFixed, s.base)
+	default:
+		return fmt.Sprintf("%s from %d", SourcePortSequential, s.base)
+	}
+}
@@ -0,0 +1,181 @@
+package mtu
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// freeLocalPort returns a port that was free a moment ago
+func freeLocalPort(t *testing.T, network string) int {
+	t.Helper()
+	if network == "udp" {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = conn.Close() }()
+		return conn.LocalAddr().(*net.UDPAddr).Port
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestSourcePortSelector(t *testing.T) {
+	if ports, err := NewSourcePortSelector(SourcePortRandom, 0); ports != nil || err != nil || ports.Next() != 0 {
+		t.Fatalf("random mode should leave ports to the kernel, got %v, %v", ports, err)
+	}
+
+	fixed, err := NewSourcePortSelector(SourcePortFixed, 40000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixed.Next() != 40000 || fixed.Next() != 40000 || fixed.String() != "fixed 40000" {
+		t.Fatalf("unexpected fixed selector behavior: %s", fixed)
+	}
+
+	sequential, err := NewSourcePortSelector(SourcePortSequential, 65534)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := []int{sequential.Next(), sequential.Next(), sequential.Next()}; got[0] != 65534 || got[1] != 65535 || got[2] != 65534 {
+		t.Fatalf("sequential ports should wrap back to the start port, got %v", got)
+	}
+	if defaulted, _ := NewSourcePortSelector(SourcePortSequential, 0); defaulted.Next() != defaultSequentialSourcePort {
+		t.Fatal("sequential mode without --src-port should start at the traceroute base port")
+	}
+
+	for _, tt := range []struct {
+		mode string
+		port int
+	}{
+		{SourcePortFixed, 0},
+		{SourcePortRandom, 40000},
+		{SourcePortFixed, 70000},
+		{"round-robin", 40000},
+	} {
+		if _, err := NewSourcePortSelector(tt.mode, tt.port); errcode.Of(err) != errcode.CLIUsage {
+			t.Errorf("NewSourcePortSelector(%q, %d) = %v, want CLI usage error", tt.mode, tt.port, err)
+		}
+	}
+}
+
+func TestReadDiscoveryOptionsSourcePorts(t *testing.T) {
+	cmd := newDiscoveryOptionsCommand()
+	mustSetFlag(t, cmd, "proto", "udp")
+	mustSetFlag(t, cmd, "src-port", "40000")
+	opts, err := readDiscoveryOptions(cmd, "192.0.2.1")
+	if err != nil || opts.SourcePortMode != SourcePortFixed || opts.SourcePort != 40000 {
+		t.Fatalf("--src-port alone should select fixed mode, got %+v, %v", opts, err)
+	}
+	if plan := newDryRunPlan(opts); plan.SourcePorts != "fixed 40000" {
+		t.Fatalf("dry-run plan should describe source ports, got %q", plan.SourcePorts)
+	}
+
+	// Minimal builds default to TCP, which takes --src-port
+	if rawICMPSupported {
+		cmd = newDiscoveryOptionsCommand()
+		mustSetFlag(t, cmd, "src-port", "40000")
+		if _, err := readDiscoveryOptions(cmd, "192.0.2.1"); errcode.Of(err) != errcode.MTUUnsupportedProtocol {
+			t.Fatalf("expected ICMP probes to reject --src-port, got %v", err)
+		}
+	}
+
+	cmd = newDiscoveryOptionsCommand()
+	mustSetFlag(t, cmd, "src-port-mode", SourcePortSequential)
+	mustSetFlag(t, cmd, "plpmtud", "true")
+	if _, err := readDiscoveryOptions(cmd, "192.0.2.1"); err != nil {
+		t.Fatalf("PLPMTUD's UDP fallback should accept source port selection, got %v", err)
+	}
+}
+
+func TestUDPProberUsesSelectedSourcePorts(t *testing.T) {
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = peer.Close() }()
+	seen := make(chan int, 4)
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			_, addr, err := peer.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			seen <- addr.Port
+			_, _ = peer.WriteToUDP([]byte{1}, addr)
+		}
+	}()
+
+	base := freeLocalPort(t, "udp")
+	ports, err := NewSourcePortSelector(SourcePortSequential, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prober, err := NewUDPProber("127.0.0.1", false, peer.LocalAddr().(*net.UDPAddr).Port, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prober.SetSourcePorts(ports)
+
+	for i := range 2 {
+		if result := prober.ProbeUDP(context.Background(), 600); !result.Success {
+			t.Fatalf("probe %d failed: %+v", i, result)
+		}
+		if got := <-seen; got != base+i {
+			t.Fatalf("probe %d left from port %d, want %d", i, got, base+i)
+		}
+	}
+}
+
+func TestTCPProberReusesFixedSourcePort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+	seen := make(chan int, 4)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			seen <- conn.RemoteAddr().(*net.TCPAddr).Port
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	fixed := freeLocalPort(t, "tcp")
+	ports, err := NewSourcePortSelector(SourcePortFixed, fixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prober, err := NewTCPProber("127.0.0.1", false, listener.Addr().(*net.TCPAddr).Port, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prober.SetSourcePorts(ports)
+
+	// Back-to-back probes from one port only work if the first one does not linger
+	for i := range 2 {
+		if result := prober.ProbeTCP(context.Background(), 600); !result.Success {
+			t.Fatalf("probe %d failed: %+v", i, result)
+		}
+		if got := <-seen; got != fixed {
+			t.Fatalf("probe %d left from port %d, want %d", i, got, fixed)
+		}
+	}
+}
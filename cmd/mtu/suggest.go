@@ -4,9 +4,18 @@ import (
 	"fmt"
 	"net"
 
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/units"
 	"github.com/spf13/cobra"
 )
 
+// Where the PMTU behind the suggestions came from
+const (
+	pmtuMeasured = "measured" // Discovered by probing the path
+	pmtuLoopback = "loopback" // Discovery failed, so the MTU of the loopback interface a local destination uses
+	pmtuAssumed  = "assumed"  // Given with --pmtu; nothing was sent
+)
+
 var getSuggestionInterfaces = GetNetworkInterfaces
 var suggestMTUDiscovery = performMTUDiscovery
 
@@ -22,47 +31,110 @@ Calculations:
 • WireGuard payload = PMTU - 60
 • IPSec ESP + UDP-encap = PMTU - (ESP + UDP + IP)
 
-Examples:
-  cidrator mtu suggest example.com --proto tcp
-  cidrator mtu suggest 8.8.8.8 --proto tcp --json`,
-	Args: cobra.ExactArgs(1),
-	RunE: runSuggest,
+Discovery honors --proto (tcp unless set), --timeout, and --4/--6/--prefer.
+--pmtu calculates from a known PMTU instead, without a destination or any
+probes. The output says whether the PMTU was measured, taken from the
+loopback interface for a local destination, or assumed from --pmtu.`,
+	Args:        cobra.RangeArgs(0, 1),
+	RunE:        runSuggest,
+	Annotations: dryRunAnnotations,
+}
+
+func init() {
+	units.Size(suggestCmd.Flags(), "pmtu", 0, "Path MTU to calculate from instead of discovering it; nothing is sent")
 }
 
 func runSuggest(cmd *cobra.Command, args []string) error {
+	format, err := readOutputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	if pmtu, _ := cmd.Flags().GetInt("pmtu"); pmtu != 0 || cmd.Flags().Changed("pmtu") {
+		if pmtu < minSuggestionPMTU || pmtu > maxSuggestionPMTU {
+			return errcode.Errorf(errcode.CLIUsage, "--pmtu must be between %d and %d", minSuggestionPMTU, maxSuggestionPMTU)
+		}
+		var target string
+		if len(args) > 0 {
+			target = args[0]
+		}
+		return outputSuggestions(suggestionPMTU{Target: target, PMTU: pmtu, Source: pmtuAssumed}, format)
+	}
+	if len(args) == 0 {
+		return errcode.Errorf(errcode.CLIUsage, "mtu suggest needs a destination to discover the PMTU to, or --pmtu")
+	}
+
 	opts, err := readDiscoveryOptions(cmd, args[0])
 	if err != nil {
 		return err
 	}
 	if opts.HopsMode {
-		return fmt.Errorf("--hops is only supported by mtu discover")
+		return errcode.Errorf(errcode.CLIUsage, "--hops is only supported by mtu discover")
+	}
+	if opts.Protocol == protocolAll {
+		return errcode.Errorf(errcode.CLIUsage, "--proto all is only supported by mtu discover")
 	}
 	opts = applySuggestProbeDefaults(cmd, opts)
 
-	jsonOutput, _ := cmd.Flags().GetBool("json")
+	plan := newDryRunPlan(opts)
+	if opts.DryRun {
+		return outputDryRun(plan, format)
+	}
+	if err := checkProbeTargets(cmd, plan); err != nil {
+		return err
+	}
+	if err := recordProbeAudit(cmd, plan); err != nil {
+		return err
+	}
 
 	ctx, cancel := newDiscoveryContext(opts)
 	defer cancel()
 
+	measured := suggestionPMTU{Target: opts.Destination, Source: pmtuMeasured, Protocol: opts.Protocol}
 	result, err := suggestMTUDiscovery(ctx, opts)
 	if err != nil {
 		pmtu, fallbackErr := fallbackSuggestionPMTU(opts)
 		if fallbackErr != nil {
-			return fmt.Errorf("MTU discovery failed: %w", err)
-		}
-		result = &MTUResult{
-			Target:   opts.Destination,
-			Protocol: opts.Protocol,
-			PMTU:     pmtu,
+			return withDiscoveryErrorCode(fmt.Errorf("MTU discovery failed: %w", err), opts.Protocol)
 		}
+		measured.PMTU, measured.Source, measured.Protocol = pmtu, pmtuLoopback, ""
+	} else {
+		measured.Target, measured.PMTU = result.Target, result.PMTU
 	}
+	return outputSuggestions(measured, format)
+}
+
+// The --pmtu range: the IPv4 minimum every link must carry, up to the
+// largest IPv4 packet
+const (
+	minSuggestionPMTU = 576
+	maxSuggestionPMTU = 65535
+)
 
-	suggestions := calculateSuggestions(result.PMTU)
+// suggestionPMTU is the path MTU the suggestions are calculated from
+type suggestionPMTU struct {
+	Target   string // Empty for --pmtu without a destination
+	PMTU     int
+	Source   string // pmtuMeasured, pmtuLoopback, or pmtuAssumed
+	Protocol string // Probe protocol, when measured
+}
 
-	if jsonOutput {
-		return outputSuggestionsJSON(result.Target, result.PMTU, suggestions)
+// describe says where the PMTU came from
+func (p suggestionPMTU) describe() string {
+	switch p.Source {
+	case pmtuMeasured:
+		return "measured over " + p.Protocol
+	case pmtuLoopback:
+		return "loopback interface MTU; discovery failed"
 	}
-	return outputSuggestionsTable(result.Target, result.PMTU, suggestions)
+	return "assumed from --pmtu"
+}
+
+func outputSuggestions(pmtu suggestionPMTU, format outputFormat) error {
+	suggestions := calculateSuggestions(pmtu.PMTU)
+	if format.structured() {
+		return outputSuggestionsStructured(pmtu, suggestions, format)
+	}
+	return outputSuggestionsTable(pmtu, suggestions)
 }
 
 func applySuggestProbeDefaults(cmd *cobra.Command, opts discoveryOptions) discoveryOptions {
@@ -100,20 +172,28 @@ func calculateSuggestions(pmtu int) Suggestions {
 	}
 }
 
-func outputSuggestionsJSON(destination string, pmtu int, suggestions Suggestions) error {
-	return writePrettyJSON(struct {
-		Target      string      `json:"target"`
+func outputSuggestionsStructured(pmtu suggestionPMTU, suggestions Suggestions, format outputFormat) error {
+	return format.write(struct {
+		Target      string      `json:"target,omitempty"`
 		PMTU        int         `json:"pmtu"`
+		PMTUSource  string      `json:"pmtu_source"`        // measured, loopback, or assumed
+		Protocol    string      `json:"protocol,omitempty"` // Set when measured
 		Suggestions Suggestions `json:"suggestions"`
 	}{
-		Target:      destination,
-		PMTU:        pmtu,
+		Target:      pmtu.Target,
+		PMTU:        pmtu.PMTU,
+		PMTUSource:  pmtu.Source,
+		Protocol:    pmtu.Protocol,
 		Suggestions: suggestions,
 	})
 }
 
-func outputSuggestionsTable(destination string, pmtu int, suggestions Suggestions) error {
-	fmt.Printf("Suggestions for %s (PMTU: %d):\n\n", destination, pmtu)
+func outputSuggestionsTable(pmtu suggestionPMTU, suggestions Suggestions) error {
+	if pmtu.Target != "" {
+		fmt.Printf("Suggestions for %s (PMTU: %d, %s):\n\n", pmtu.Target, pmtu.PMTU, pmtu.describe())
+	} else {
+		fmt.Printf("Suggestions for PMTU %d (%s):\n\n", pmtu.PMTU, pmtu.describe())
+	}
 	fmt.Printf("TCP MSS (IPv4):              %d\n", suggestions.TCPMSSv4)
 	fmt.Printf("TCP MSS (IPv6):              %d\n", suggestions.TCPMSSv6)
 	fmt.Printf("TCP MSS (IPv4+timestamps):   %d\n", suggestions.TCPMSSv4Timestamps)
@@ -127,6 +207,10 @@ func outputSuggestionsTable(destination string, pmtu int, suggestions Suggestion
 }
 
 func fallbackSuggestionPMTU(opts discoveryOptions) (int, error) {
+	opts, err := selectAddressFamily(opts, true)
+	if err != nil {
+		return 0, err
+	}
 	ips, err := resolveTargetIPs(opts.Destination)
 	if err != nil {
 		return 0, err
@@ -0,0 +1,102 @@
+package mtu
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTCPProberAndDiscoveryAgainstEchoPeer(t *testing.T) {
+	const maxPMTU = 1400
+	port, _ := startTCPEchoPeer(t, payloadSizeForPacket(maxPMTU, tcpPacketOverhead(false)), true)
+
+	prober, err := NewTCPProber("127.0.0.1", false, port, 150*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewTCPProber returned error: %v", err)
+	}
+
+	if success := prober.ProbeTCP(context.Background(), maxPMTU); !success.Success {
+		t.Fatalf("expected TCP probe at PMTU %d to succeed, got %+v", maxPMTU, success)
+	}
+	if failure := prober.ProbeTCP(context.Background(), maxPMTU+20); failure.Success {
+		t.Fatalf("expected oversized TCP probe to fail, got %+v", failure)
+	}
+
+	result, err := prober.DiscoverPMTUTCP(context.Background(), 1300, 1450)
+	if err != nil {
+		t.Fatalf("DiscoverPMTUTCP returned error: %v", err)
+	}
+	// The peer caps the payload; TCP timestamps carry a segment 12 bytes past it
+	if result.PMTU != maxPMTU && result.PMTU != maxPMTU+tcpTimestampOptionBytes {
+		t.Fatalf("unexpected PMTU: got %d, want %d", result.PMTU, maxPMTU)
+	}
+}
+
+func TestProbeTCPRejectsBanner(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			// Like an SSH server, greet every connection before reading
+			_, _ = conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_, _ = conn.Read(make([]byte, 65535))
+			}()
+		}
+	}()
+
+	prober, err := NewTCPProber("127.0.0.1", false, listener.Addr().(*net.TCPAddr).Port, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := prober.ProbeTCP(context.Background(), 1400)
+	if result.Success || result.Error == nil || !strings.Contains(result.Error.Error(), "did not echo") {
+		t.Fatalf("expected the banner to be rejected, got %+v", result)
+	}
+}
+
+func TestProbeTCPRejectsPartialEcho(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			// Echo only the start of the probe, then hold the connection
+			go func() {
+				defer func() { _ = conn.Close() }()
+				buf := make([]byte, 65535)
+				n, err := conn.Read(buf)
+				if err != nil {
+					return
+				}
+				_, _ = conn.Write(buf[:min(n, 64)])
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	prober, err := NewTCPProber("127.0.0.1", false, listener.Addr().(*net.TCPAddr).Port, 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := prober.ProbeTCP(context.Background(), 1400); result.Success {
+		t.Fatalf("expected a probe echoed only in part to fail, got %+v", result)
+	}
+}
@@ -0,0 +1,142 @@
+package mtu
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// SetReuseConnection makes DiscoverPMTUTCP send every size over one
+// established connection instead of dialing a new one per size
+func (p *TCPProber) SetReuseConnection(enabled bool) {
+	p.reuse = enabled
+}
+
+// probeReused sends a probe of size over the held connection, dialing one
+// first when there is none. The connection announces the MSS of the largest
+// size the search may try, so smaller sizes only need a shorter write; the
+// TCP_MAXSEG cap is lowered to each size as well so offloads cannot merge
+// segments. A lost size leaves its data queued for retransmission, so the
+// connection is dropped and the next size reconnects. A peer that does not
+// echo a probe back in full fails that size, and the rest of the search
+// falls back to a connection per size.
+func (p *TCPProber) probeReused(ctx context.Context, size int) *ProbeResult {
+	if !p.reuse || p.noEcho {
+		return p.ProbeTCP(ctx, size)
+	}
+
+	start := time.Now()
+	for {
+		fresh := p.held == nil
+		if fresh {
+			conn, err := p.dialProbe(ctx, p.reuseMSS)
+			if err != nil {
+				return &ProbeResult{
+					Size:    size,
+					Success: false,
+					RTT:     time.Since(start),
+					Error:   err,
+				}
+			}
+			p.held = conn
+		}
+
+		result, stale := p.sendHeld(size, start)
+		if !stale || fresh {
+			return result
+		}
+		// The peer closed the held connection while it sat idle, or the
+		// kernel lowered its MSS: the size has not really been tried yet
+	}
+}
+
+// sendHeld sends one probe over the held connection. stale reports that the
+// connection, not the size, was at fault, so the probe is worth repeating on
+// a new one.
+func (p *TCPProber) sendHeld(size int, start time.Time) (result *ProbeResult, stale bool) {
+	conn := p.held
+	failed := func(err error, stale bool) (*ProbeResult, bool) {
+		p.dropHeld()
+		return &ProbeResult{
+			Size:    size,
+			Success: false,
+			RTT:     time.Since(start),
+			Error:   err,
+		}, stale
+	}
+
+	payloadSize, err := p.probePayloadSize(conn, size)
+	if err != nil {
+		return failed(err, true)
+	}
+	if rawConn, err := conn.SyscallConn(); err == nil {
+		_ = rawConn.Control(func(fd uintptr) {
+			_ = setTCPMSS(fd, payloadSizeForPacket(size, tcpPacketOverhead(p.ipv6)))
+		})
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(p.timeout)); err != nil {
+		return failed(err, false)
+	}
+	payload := tcpProbePayload(payloadSize)
+	if _, err := conn.Write(payload); err != nil {
+		return failed(err, true)
+	}
+
+	// The first bytes back decide the probe; a timeout means the size was
+	// too large, and any other error that the connection had gone
+	echo := make([]byte, payloadSize)
+	n, err := conn.Read(echo)
+	rtt := time.Since(start)
+	if err != nil {
+		return failed(err, !errors.Is(err, os.ErrDeadlineExceeded))
+	}
+	if err := checkProbeEcho(echo[:n], payload); err != nil {
+		return failed(err, false)
+	}
+
+	// The size only counts once the whole payload is back, which also
+	// leaves the stream empty for the next probe. A peer that stops short
+	// does not echo byte for byte, so the rest of the search dials per size.
+	if _, err := io.ReadFull(conn, echo[n:]); err != nil {
+		p.noEcho = true
+		return failed(err, false)
+	}
+	if err := checkProbeEcho(echo, payload); err != nil {
+		return failed(err, false)
+	}
+	if err := checkProbeMSS(conn, size, payloadSize); err != nil {
+		return failed(err, false)
+	}
+
+	return &ProbeResult{
+		Size:    size,
+		Success: true,
+		RTT:     rtt,
+	}, false
+}
+
+// dropHeld closes the held connection so the next probe dials a new one
+func (p *TCPProber) dropHeld() {
+	if p.held == nil {
+		return
+	}
+	_ = p.held.Close()
+	p.held = nil
+}
+
+// probers returns what DiscoverPMTUTCP probes with: the held connection when
+// reuse is on, and a connection per size otherwise
+func (p *TCPProber) probers(maxMTU int) (probeFunc, burstFunc) {
+	if !p.reuse {
+		return p.ProbeTCP, p.burst
+	}
+	p.reuseMSS = payloadSizeForPacket(maxMTU, tcpPacketOverhead(p.ipv6))
+	p.noEcho = false
+	// One connection carries one probe at a time
+	return p.probeReused, func(ctx context.Context, sizes []int) []*ProbeResult {
+		return sequentialProbes(ctx, sizes, p.probeReused)
+	}
+}
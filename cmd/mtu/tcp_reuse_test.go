@@ -0,0 +1,101 @@
+package mtu
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startTCPEchoPeer echoes every read back until one is larger than
+// maxPayload, after which that connection goes silent as if the segment had
+// been dropped. It returns the port and a count of accepted connections.
+func startTCPEchoPeer(t *testing.T, maxPayload int, echo bool) (int, *atomic.Int32) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	var accepted atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			go func() {
+				defer func() { _ = conn.Close() }()
+				buf := make([]byte, 65535)
+				for {
+					n, err := conn.Read(buf)
+					if err != nil {
+						return
+					}
+					if n > maxPayload {
+						_, _ = io.Copy(io.Discard, conn)
+						return
+					}
+					if !echo {
+						n = 1
+					}
+					if _, err := conn.Write(buf[:n]); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, &accepted
+}
+
+func TestDiscoverPMTUTCPReusesConnection(t *testing.T) {
+	const pmtu = 1400
+	port, accepted := startTCPEchoPeer(t, payloadSizeForPacket(pmtu, tcpPacketOverhead(false)), true)
+
+	prober, err := NewTCPProber("127.0.0.1", false, port, 200*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prober.SetReuseConnection(true)
+
+	result, err := prober.DiscoverPMTUTCP(context.Background(), 1000, 1500)
+	if err != nil {
+		t.Fatalf("DiscoverPMTUTCP: %v", err)
+	}
+	if result.PMTU != pmtu {
+		t.Fatalf("PMTU = %d, want %d", result.PMTU, pmtu)
+	}
+	// Only the first probe and the ones after a lost size connect
+	if got := int(accepted.Load()); got >= result.Hops {
+		t.Fatalf("%d connections for %d probes, want fewer", got, result.Hops)
+	}
+	if prober.held != nil {
+		t.Fatal("held connection left open after the search")
+	}
+}
+
+func TestDiscoverPMTUTCPReuseRejectsPartialEcho(t *testing.T) {
+	port, accepted := startTCPEchoPeer(t, 65535, false)
+
+	prober, err := NewTCPProber("127.0.0.1", false, port, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prober.SetReuseConnection(true)
+
+	// The peer answers each read with a single byte, so no size is confirmed
+	if _, err := prober.DiscoverPMTUTCP(context.Background(), 1200, 1500); err == nil {
+		t.Fatal("expected no working MTU from a peer that echoes a prefix")
+	}
+	if !prober.noEcho {
+		t.Fatal("expected reuse to be abandoned for a peer that does not echo")
+	}
+	if got := int(accepted.Load()); got < 2 {
+		t.Fatalf("%d connections, want the search to dial again per size", got)
+	}
+}
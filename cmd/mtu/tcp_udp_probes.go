@@ -1,29 +1,64 @@
 package mtu
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
 )
 
 // TCPProber handles MTU discovery using TCP SYN packets
 type TCPProber struct {
-	target     string
-	targetAddr *net.TCPAddr
-	timeout    time.Duration
-	ipv6       bool
+	target      string
+	targetAddr  *net.TCPAddr
+	timeout     time.Duration
+	ipv6        bool
+	sourcePorts *SourcePortSelector // nil lets the kernel pick each probe's source port
+	commonFirst bool                // Try the common PMTUs before binary search
+	parallel    int                 // Sizes in flight per search round (0 or 1 = serial)
+	retries     int                 // Resends of an unanswered size
+	reuse       bool                // Probe every size over one connection; see tcp_reuse.go
+	reuseMSS    int                 // MSS the held connection announces
+	held        *net.TCPConn        // Connection probeReused sends over (nil = dial one)
+	noEcho      bool                // The peer did not echo a probe, so reuse fell back to a connection per size
+	source      probeSource         // Local address of the probe connections
 }
 
 // UDPProber handles MTU discovery using UDP packets
 type UDPProber struct {
-	target     string
-	targetAddr *net.UDPAddr
-	timeout    time.Duration
-	ipv6       bool
+	target      string
+	targetAddr  *net.UDPAddr
+	timeout     time.Duration
+	ipv6        bool
+	sourcePorts *SourcePortSelector // nil lets the kernel pick each probe's source port
+	commonFirst bool                // Try the common PMTUs before binary search
+	parallel    int                 // Sizes in flight per search round (0 or 1 = serial)
+	retries     int                 // Resends of an unanswered size
+
+	mu          sync.Mutex   // Serializes probes over conn
+	conn        *net.UDPConn // Socket ProbeUDP sends over (nil = open one)
+	sentLengths map[int]bool // Payload lengths sent over conn, to spot late echoes
+	response    []byte       // Read buffer for conn
+	payload     []byte       // Random bytes every probe's payload is sliced from
+	source      probeSource  // Local address of the probe sockets
+
+	icmp    FragmentationSource // Optional ICMP error source; see SetICMPListener
+	refused atomic.Bool         // A probe drew ICMP Port Unreachable
 }
 
+// maxUDPProbePayload is the largest payload a UDP probe can carry, which the
+// random payload buffer is generated at
+const maxUDPProbePayload = 65535 - 8 - 20
+
 // NewTCPProber creates a new TCP-based MTU prober
 func NewTCPProber(target string, ipv6 bool, port int, timeout time.Duration) (*TCPProber, error) {
 	// Resolve target address
@@ -41,18 +76,21 @@ func NewTCPProber(target string, ipv6 bool, port int, timeout time.Duration) (*T
 		ports = []string{"443", "80", "22"}
 	}
 
-	var addr *net.TCPAddr
-	var err error
+	host, err := resolveProbeHost(target, ipv6)
+	if err != nil {
+		return nil, errcode.Wrap(errcode.MTUResolveFailed, fmt.Errorf("failed to resolve TCP address: %w", err))
+	}
 
+	var addr *net.TCPAddr
 	for _, p := range ports {
-		addr, err = net.ResolveTCPAddr(network, net.JoinHostPort(target, p))
+		addr, err = net.ResolveTCPAddr(network, net.JoinHostPort(host, p))
 		if err == nil {
 			break
 		}
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve TCP address: %w", err)
+		return nil, errcode.Wrap(errcode.MTUResolveFailed, fmt.Errorf("failed to resolve TCP address: %w", err))
 	}
 
 	return &TCPProber{
@@ -76,9 +114,13 @@ func NewUDPProber(target string, ipv6 bool, port int, timeout time.Duration) (*U
 		targetPort = fmt.Sprintf("%d", port)
 	}
 
-	addr, err := net.ResolveUDPAddr(network, net.JoinHostPort(target, targetPort))
+	host, err := resolveProbeHost(target, ipv6)
+	if err != nil {
+		return nil, errcode.Wrap(errcode.MTUResolveFailed, fmt.Errorf("failed to resolve UDP address: %w", err))
+	}
+	addr, err := net.ResolveUDPAddr(network, net.JoinHostPort(host, targetPort))
 	if err != nil {
-		return nil, fmt.Errorf("failed to resolve UDP address: %w", err)
+		return nil, errcode.Wrap(errcode.MTUResolveFailed, fmt.Errorf("failed to resolve UDP address: %w", err))
 	}
 
 	return &UDPProber{
@@ -86,9 +128,57 @@ func NewUDPProber(target string, ipv6 bool, port int, timeout time.Duration) (*U
 		targetAddr: addr,
 		timeout:    timeout,
 		ipv6:       ipv6,
+		payload:    NewPacketRandomizer().GenerateRandomPayload(maxUDPProbePayload),
 	}, nil
 }
 
+// SetSourcePorts controls which local port each probe is sent from
+func (p *TCPProber) SetSourcePorts(ports *SourcePortSelector) {
+	p.sourcePorts = ports
+}
+
+// SetSourcePorts controls which local port each probe is sent from
+func (p *UDPProber) SetSourcePorts(ports *SourcePortSelector) {
+	p.sourcePorts = ports
+}
+
+// SetCommonMTUFirst makes DiscoverPMTUTCP try the common PMTUs first
+func (p *TCPProber) SetCommonMTUFirst(enabled bool) {
+	p.commonFirst = enabled
+}
+
+// SetCommonMTUFirst makes DiscoverPMTUUDP try the common PMTUs first
+func (p *UDPProber) SetCommonMTUFirst(enabled bool) {
+	p.commonFirst = enabled
+}
+
+// SetParallel makes DiscoverPMTUTCP probe n sizes at once per round
+func (p *TCPProber) SetParallel(n int) {
+	p.parallel = n
+}
+
+// SetParallel makes DiscoverPMTUUDP probe n sizes at once per round
+func (p *UDPProber) SetParallel(n int) {
+	p.parallel = n
+}
+
+// SetRetries makes DiscoverPMTUTCP resend an unanswered size up to n times
+func (p *TCPProber) SetRetries(n int) {
+	p.retries = n
+}
+
+// SetRetries makes DiscoverPMTUUDP resend an unanswered size up to n times
+func (p *UDPProber) SetRetries(n int) {
+	p.retries = n
+}
+
+// SetICMPListener gives ProbeUDP a source of ICMP errors, so a probe that
+// draws Fragmentation Needed fails as soon as the error arrives rather than
+// at its timeout
+func (p *UDPProber) SetICMPListener(source FragmentationSource) {
+	p.icmp = source
+}
+
 // ProbeTCP performs a TCP-based MTU probe
 func (p *TCPProber) ProbeTCP(ctx context.Context, size int) *ProbeResult {
 	start := time.Now()
@@ -96,25 +186,7 @@ func (p *TCPProber) ProbeTCP(ctx context.Context, size int) *ProbeResult {
 	// Calculate target MSS to bypass TSO/GSO.
 	targetMSS := payloadSizeForPacket(size, tcpPacketOverhead(p.ipv6))
 
-	// Safety check for minimum TCP MSS
-	if targetMSS < 1 {
-		targetMSS = 1
-	}
-
-	// Create TCP connection with specific socket options
-	dialer := &net.Dialer{
-		Timeout: p.timeout,
-		Control: func(network, address string, c syscall.RawConn) error {
-			return c.Control(func(fd uintptr) {
-				// FIX: Force kernel to segment at exactly our probe size
-				// This defeats TSO/GSO false positives (The "9216 Problem")
-				_ = setTCPMSS(fd, targetMSS)
-			})
-		},
-	}
-
-	// Connect to target
-	connRaw, err := dialer.DialContext(ctx, "tcp", p.targetAddr.String())
+	conn, err := p.dialProbe(ctx, targetMSS)
 	if err != nil {
 		return &ProbeResult{
 			Size:    size,
@@ -123,7 +195,6 @@ func (p *TCPProber) ProbeTCP(ctx context.Context, size int) *ProbeResult {
 			Error:   err,
 		}
 	}
-	conn := connRaw.(*net.TCPConn)
 	defer func() {
 		if closeErr := conn.Close(); closeErr != nil {
 			// Log close error but don't override main error
@@ -131,40 +202,15 @@ func (p *TCPProber) ProbeTCP(ctx context.Context, size int) *ProbeResult {
 		}
 	}()
 
-	payloadSize := targetMSS
-
-	// --- MSS Validation: Detect TSO/GSO False Positives ---
-	// If we asked for 9000 bytes (Jumbo), but the kernel negotiated 1460 (Standard),
-	// any large Write() will be segmented. This is a false positive for PMTUD.
-	//
-	// Allow the common 12-byte TCP timestamp option in the negotiated MSS. In that
-	// case we still write only the negotiated MSS so the kernel emits a single
-	// full-size segment on the wire instead of splitting the payload.
-	actualMSS, err := getTCPMSS(conn)
-	if err == nil && actualMSS > 0 {
-		timestampsEnabled, timestampErr := tcpTimestampsEnabled(conn)
-		if timestampErr != nil {
-			timestampsEnabled = false
-		}
-
-		var ok bool
-		payloadSize, ok = tcpProbePayloadSize(size, actualMSS, timestampsEnabled, p.ipv6)
-		if !ok {
-			return &ProbeResult{
-				Size:    size,
-				Success: false,
-				RTT:     time.Since(start),
-				Error:   fmt.Errorf("false positive detected: negotiated MSS %d is too small for packet size %d", actualMSS, size),
-			}
+	payloadSize, err := p.probePayloadSize(conn, size)
+	if err != nil {
+		return &ProbeResult{
+			Size:    size,
+			Success: false,
+			RTT:     time.Since(start),
+			Error:   err,
 		}
 	}
-	// -------------------------------------------------------
-
-	// Set DF flag for Path-MTU discovery (RFC 1191/8201)
-	if err := setDontFragment(conn, p.ipv6); err != nil {
-		// Log warning but continue - some systems may not support this
-		_ = err // DF flag is best-effort
-	}
 
 	// Set deadline
 	deadline := time.Now().Add(p.timeout)
@@ -178,11 +224,7 @@ func (p *TCPProber) ProbeTCP(ctx context.Context, size int) *ProbeResult {
 	}
 
 	// Send payload data to actually test the path MTU
-	payload := make([]byte, payloadSize)
-	for i := range payload {
-		payload[i] = byte(i % 256)
-	}
-
+	payload := tcpProbePayload(payloadSize)
 	_, err = conn.Write(payload)
 	if err != nil {
 		return &ProbeResult{
@@ -193,11 +235,18 @@ func (p *TCPProber) ProbeTCP(ctx context.Context, size int) *ProbeResult {
 		}
 	}
 
-	// Wait for any response from the peer-assisted endpoint.
-	// A timeout or error indicates the packet was too large
-	response := make([]byte, 1)
-	_, err = conn.Read(response)
+	// Wait for the peer-assisted endpoint to echo the whole payload. A
+	// timeout or error indicates the packet was too large; a reply that is
+	// not the payload, that the peer is not an echo endpoint
+	response := make([]byte, payloadSize)
+	n, err := io.ReadFull(conn, response)
 	rtt := time.Since(start)
+	if echoErr := checkProbeEcho(response[:n], payload); echoErr != nil {
+		err = echoErr
+	}
+	if err == nil {
+		err = checkProbeMSS(conn, size, payloadSize)
+	}
 
 	if err != nil {
 		return &ProbeResult{
@@ -215,12 +264,162 @@ func (p *TCPProber) ProbeTCP(ctx context.Context, size int) *ProbeResult {
 	}
 }
 
-// ProbeUDP performs a UDP-based MTU probe
+// dialProbe opens a probe connection that announces targetMSS, so the
+// kernel segments at exactly the probe size, with DF set
+func (p *TCPProber) dialProbe(ctx context.Context, targetMSS int) (*net.TCPConn, error) {
+	// Safety check for minimum TCP MSS
+	if targetMSS < 1 {
+		targetMSS = 1
+	}
+
+	// Create TCP connection with specific socket options
+	sourcePort := p.sourcePorts.Next()
+	dialer := &net.Dialer{
+		Timeout: p.timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			var mssErr error
+			err := c.Control(func(fd uintptr) {
+				// FIX: Force kernel to segment at exactly our probe size
+				// This defeats TSO/GSO false positives (The "9216 Problem")
+				mssErr = setTCPMSS(fd, targetMSS)
+				if sourcePort != 0 {
+					_ = setReuseAddr(fd)
+				}
+			})
+			if errors.Is(mssErr, errTCPMSSUnsupported) {
+				// The kernel would segment at its own MSS, and every size
+				// above it would pass
+				return mssErr
+			}
+			return err
+		},
+	}
+	if sourcePort != 0 {
+		dialer.LocalAddr = &net.TCPAddr{Port: sourcePort}
+	}
+
+	// Connect to target
+	connRaw, err := dialer.DialContext(ctx, "tcp", p.targetAddr.String())
+	if err != nil {
+		return nil, err
+	}
+	conn := connRaw.(*net.TCPConn)
+	p.source.note(conn.LocalAddr())
+	if sourcePort != 0 {
+		// Reset instead of lingering in TIME_WAIT, which would keep the next
+		// probe from reusing the same source port towards the same target
+		_ = conn.SetLinger(0)
+	}
+
+	// Set DF flag for Path-MTU discovery (RFC 1191/8201)
+	if err := setDontFragment(conn, p.ipv6); err != nil {
+		// Log warning but continue - some systems may not support this
+		_ = err // DF flag is best-effort
+	}
+	return conn, nil
+}
+
+// probePayloadSize returns how many bytes to write so that conn sends a
+// single segment of size bytes on the wire
+func (p *TCPProber) probePayloadSize(conn *net.TCPConn, size int) (int, error) {
+	payloadSize := max(payloadSizeForPacket(size, tcpPacketOverhead(p.ipv6)), 1)
+
+	// --- MSS Validation: Detect TSO/GSO False Positives ---
+	// If we asked for 9000 bytes (Jumbo), but the kernel negotiated 1460 (Standard),
+	// any large Write() will be segmented. This is a false positive for PMTUD.
+	//
+	// Allow the common 12-byte TCP timestamp option in the negotiated MSS. In that
+	// case we still write only the negotiated MSS so the kernel emits a single
+	// full-size segment on the wire instead of splitting the payload.
+	actualMSS, err := getTCPMSS(conn)
+	if err == nil && actualMSS > 0 {
+		timestampsEnabled, timestampErr := tcpTimestampsEnabled(conn)
+		if timestampErr != nil {
+			timestampsEnabled = false
+		}
+
+		var ok bool
+		payloadSize, ok = tcpProbePayloadSize(size, actualMSS, timestampsEnabled, p.ipv6)
+		if !ok {
+			return 0, fmt.Errorf("false positive detected: negotiated MSS %d is too small for packet size %d", actualMSS, size)
+		}
+	}
+	// -------------------------------------------------------
+	return payloadSize, nil
+}
+
+// checkProbeEcho fails a probe whose reply does not start the way the
+// payload does. Only an echo shows that the peer read the probe: a server
+// that speaks first, such as SSH, would answer every size with its banner.
+func checkProbeEcho(reply, payload []byte) error {
+	if !bytes.Equal(reply, payload[:len(reply)]) {
+		return fmt.Errorf("peer did not echo the probe: unexpected reply % x", reply[:min(len(reply), 8)])
+	}
+	return nil
+}
+
+// checkProbeMSS fails a probe whose reply came back after the kernel lowered
+// the connection's MSS below payloadSize. An ICMP Packet Too Big for the
+// probe does that: the kernel resends the data in smaller segments, so the
+// reply no longer shows that a segment of size bytes got through.
+func checkProbeMSS(conn *net.TCPConn, size, payloadSize int) error {
+	if mss, err := getTCPMSS(conn); err == nil && mss > 0 && mss < payloadSize {
+		return fmt.Errorf("path MTU dropped below packet size %d during the probe (MSS now %d)", size, mss)
+	}
+	return nil
+}
+
+// tcpProbePayload is the data a TCP probe writes
+func tcpProbePayload(size int) []byte {
+	payload := make([]byte, size)
+	for i := range payload {
+		payload[i] = byte(i % 256)
+	}
+	return payload
+}
+
+// ProbeUDP performs a UDP-based MTU probe. Probes share one connected
+// socket, except in sequential source port mode, where each needs a port of
+// its own.
 func (p *UDPProber) ProbeUDP(ctx context.Context, size int) *ProbeResult {
+	if p.sourcePorts != nil && !p.sourcePorts.Fixed() {
+		return p.probeOwnSocket(ctx, size)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	start := time.Now()
+	if p.conn == nil {
+		conn, err := p.dial()
+		if err != nil {
+			return &ProbeResult{
+				Size:    size,
+				Success: false,
+				RTT:     time.Since(start),
+				Error:   err,
+			}
+		}
+		p.conn, p.sentLengths = conn, map[int]bool{}
+		if p.response == nil {
+			p.response = make([]byte, len(p.payload)+1)
+		}
+	}
+
+	result := p.probeOver(p.conn, size, start, p.response, p.sentLengths)
+	if result.Error != nil && !errors.Is(result.Error, os.ErrDeadlineExceeded) {
+		// Do not carry a refused or broken socket on to the next probe
+		_ = p.conn.Close()
+		p.conn = nil
+	}
+	return result
+}
+
+// probeOwnSocket performs a UDP-based MTU probe over a socket opened for it,
+// for probes sent concurrently or from their own source port
+func (p *UDPProber) probeOwnSocket(ctx context.Context, size int) *ProbeResult {
 	start := time.Now()
 
-	// Create UDP connection
-	conn, err := net.DialUDP("udp", nil, p.targetAddr)
+	conn, err := p.dial()
 	if err != nil {
 		return &ProbeResult{
 			Size:    size,
@@ -236,12 +435,35 @@ func (p *UDPProber) ProbeUDP(ctx context.Context, size int) *ProbeResult {
 		}
 	}()
 
+	response := make([]byte, payloadSizeForPacket(size, udpPacketOverhead(p.ipv6))+1)
+	return p.probeOver(conn, size, start, response, nil)
+}
+
+// dial opens a probe socket connected to the target, with DF set
+func (p *UDPProber) dial() (*net.UDPConn, error) {
+	var localAddr *net.UDPAddr
+	if sourcePort := p.sourcePorts.Next(); sourcePort != 0 {
+		localAddr = &net.UDPAddr{Port: sourcePort}
+	}
+	conn, err := net.DialUDP("udp", localAddr, p.targetAddr)
+	if err != nil {
+		return nil, err
+	}
+	p.source.note(conn.LocalAddr())
+
 	// Set DF flag for Path-MTU discovery (RFC 1191/8201)
 	if err := setDontFragment(conn, p.ipv6); err != nil {
 		// Log warning but continue - some systems may not support this
 		_ = err // DF flag is best-effort
 	}
+	return conn, nil
+}
 
+// probeOver sends a probe of size over conn and waits for the answer,
+// reading it into response. sent, when not nil, holds the payload lengths
+// conn has carried before; a reply of one of those lengths is a late echo
+// of an earlier probe and is skipped rather than taken for this one's.
+func (p *UDPProber) probeOver(conn *net.UDPConn, size int, start time.Time, response []byte, sent map[int]bool) *ProbeResult {
 	// Set deadline
 	deadline := time.Now().Add(p.timeout)
 	if err := conn.SetDeadline(deadline); err != nil {
@@ -254,34 +476,34 @@ func (p *UDPProber) ProbeUDP(ctx context.Context, size int) *ProbeResult {
 	}
 
 	// Probe size refers to the full packet size on the wire, not just UDP payload.
-	payload := make([]byte, payloadSizeForPacket(size, udpPacketOverhead(p.ipv6)))
-	for i := range payload {
-		payload[i] = byte(i % 256)
-	}
+	payload := p.payloadFor(size)
 
 	// Send UDP packet
-	_, err = conn.Write(payload)
+	_, err := conn.Write(payload)
 	if err != nil {
-		return &ProbeResult{
-			Size:    size,
-			Success: false,
-			RTT:     time.Since(start),
-			Error:   err,
-		}
+		return p.failedProbe(conn, size, time.Since(start), err)
 	}
 
 	// Try to read response (will timeout if packet was dropped/lost)
-	response := make([]byte, 1500)
-	_, err = conn.Read(response)
+	var fragErr *FragmentationError
+	for {
+		var n int
+		n, fragErr, err = p.read(conn, response, size)
+		if err != nil || fragErr != nil || n == len(payload) || !sent[n] {
+			break
+		}
+	}
+	if sent != nil {
+		sent[len(payload)] = true
+	}
 	rtt := time.Since(start)
 
+	if fragErr != nil {
+		return fragNeededResult(size, rtt, fragErr.NextHopMTU, p.ipv6)
+	}
 	if err != nil {
-		return &ProbeResult{
-			Size:    size,
-			Success: false, // Strict: packet loss or timeout = failure
-			RTT:     rtt,
-			Error:   err,
-		}
+		// Strict: packet loss or timeout = failure
+		return p.failedProbe(conn, size, rtt, err)
 	}
 
 	// For RFC 8899 PLPMTUD, successful receipt of Echo is required
@@ -293,94 +515,220 @@ func (p *UDPProber) ProbeUDP(ctx context.Context, size int) *ProbeResult {
 	}
 }
 
-// DiscoverPMTUTCP performs TCP-based MTU discovery
-func (p *TCPProber) DiscoverPMTUTCP(ctx context.Context, minMTU, maxMTU int) (*MTUResult, error) {
-	start := time.Now()
-
-	// Binary search for maximum working MTU
-	low := minMTU
-	high := maxMTU
-	lastWorking := 0
-	hops := 0
-
-	for low <= high {
-		mid := (low + high) / 2
+// read reads a reply from conn into response. With an ICMP listener it
+// returns early, with the error, when a Fragmentation Needed for the probe
+// of size arrives first; errors for other probes are dropped, so concurrent
+// probes sharing the listener may have to wait out their timeout instead.
+func (p *UDPProber) read(conn *net.UDPConn, response []byte, size int) (int, *FragmentationError, error) {
+	if p.icmp == nil {
+		n, err := conn.Read(response)
+		return n, nil, err
+	}
 
+	type readResult struct {
+		n   int
+		err error
+	}
+	done := make(chan readResult, 1)
+	go func() {
+		n, err := conn.Read(response)
+		done <- readResult{n, err}
+	}()
+	for {
 		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
+		case res := <-done:
+			return res.n, nil, res.err
+		case fragErr := <-p.icmp.Errors():
+			if !p.ownsFragError(fragErr, conn, size) {
+				continue
+			}
+			// Unblock the read so it cannot take the next probe's reply
+			_ = conn.SetReadDeadline(time.Now())
+			<-done
+			return 0, fragErr, nil
 		}
+	}
+}
 
-		result := p.ProbeTCP(ctx, mid)
-		hops++
+// ownsFragError reports whether fragErr is about the probe of size sent
+// from conn: the ports match, and so does the length when the embedded
+// header carried one
+func (p *UDPProber) ownsFragError(fragErr *FragmentationError, conn *net.UDPConn, size int) bool {
+	local, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok || fragErr.OriginalSrcPort != local.Port || fragErr.OriginalDstPort != p.targetAddr.Port {
+		return false
+	}
+	if fragErr.OriginalDst != nil && !fragErr.OriginalDst.Equal(p.targetAddr.IP) {
+		return false
+	}
+	return fragErr.OriginalSize == 0 || fragErr.OriginalSize == size
+}
 
-		if result.Success {
-			lastWorking = mid
-			low = mid + 1
-		} else {
-			high = mid - 1
+// failedProbe classifies the socket error that failed a probe. The kernel
+// reports an ICMP Port Unreachable on a connected socket as a refused
+// connection, and refuses to send a packet larger than a path MTU it has
+// already learned from a Fragmentation Needed error.
+func (p *UDPProber) failedProbe(conn *net.UDPConn, size int, rtt time.Duration, err error) *ProbeResult {
+	switch {
+	case errors.Is(err, syscall.EMSGSIZE):
+		mtu, _ := getPathMTU(conn, p.ipv6)
+		return fragNeededResult(size, rtt, mtu, p.ipv6)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		p.refused.Store(true)
+		icmpErr := &ICMPError{Type: 3, Code: 3, Message: "Port Unreachable"}
+		if p.ipv6 {
+			icmpErr = &ICMPError{Type: 1, Code: 4, Message: "Port Unreachable"}
+		}
+		return &ProbeResult{
+			Size:    size,
+			Success: false,
+			RTT:     rtt,
+			Error:   fmt.Errorf("nothing is listening on UDP port %d: %w", p.targetAddr.Port, err),
+			ICMPErr: icmpErr,
+			Outcome: OutcomePortUnreachable,
 		}
 	}
 
-	if lastWorking == 0 {
-		return nil, fmt.Errorf("no working MTU found in range %d-%d", minMTU, maxMTU)
+	result := &ProbeResult{
+		Size:    size,
+		Success: false,
+		RTT:     rtt,
+		Error:   err,
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		result.Outcome = OutcomeNoResponse
+	}
+	return result
+}
+
+// fragNeededResult fails a probe of size that was too big for the path.
+// mtu is the next-hop MTU the error reported, or 0.
+func fragNeededResult(size int, rtt time.Duration, mtu int, ipv6 bool) *ProbeResult {
+	icmpErr := &ICMPError{Type: 3, Code: 4, Message: "Fragmentation Needed", MTU: mtu}
+	if ipv6 {
+		icmpErr = &ICMPError{Type: 2, Code: 0, Message: "Packet Too Big", MTU: mtu}
+	}
+	err := fmt.Errorf("packet size %d is too big for the path", size)
+	if mtu > 0 {
+		err = fmt.Errorf("packet size %d is too big for the path (next-hop MTU %d)", size, mtu)
+	}
+	return &ProbeResult{
+		Size:    size,
+		Success: false,
+		RTT:     rtt,
+		Error:   err,
+		ICMPErr: icmpErr,
+		Outcome: OutcomeFragNeeded,
+	}
+}
+
+// payloadFor returns the payload of a probe of size, sliced from the random
+// bytes generated with the prober
+func (p *UDPProber) payloadFor(size int) []byte {
+	n := payloadSizeForPacket(size, udpPacketOverhead(p.ipv6))
+	if n > len(p.payload) {
+		return NewPacketRandomizer().GenerateRandomPayload(n)
+	}
+	return p.payload[:n]
+}
+
+// Close releases the socket probes share
+func (p *UDPProber) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
+
+// DiscoverPMTUTCP performs TCP-based MTU discovery
+func (p *TCPProber) DiscoverPMTUTCP(ctx context.Context, minMTU, maxMTU int) (*MTUResult, error) {
+	start := time.Now()
+
+	probe, burst := p.probers(maxMTU)
+	defer p.dropHeld()
+
+	plan := searchPlan{CommonFirst: p.commonFirst, Parallel: p.parallel, Retries: p.retries}
+	search, err := searchPMTU(ctx, minMTU, maxMTU, probe, burst, plan)
+	if err != nil {
+		return nil, err
 	}
 
 	elapsed := time.Since(start)
+	sourceAddr, egressInterface := p.source.egress()
 
 	return &MTUResult{
-		Target:    p.target,
-		Protocol:  "tcp",
-		PMTU:      lastWorking,
-		MSS:       tcpMSSForMTU(lastWorking, p.ipv6),
-		Hops:      hops,
-		ElapsedMS: int(elapsed.Milliseconds()),
+		Target:          p.target,
+		Protocol:        "tcp",
+		PMTU:            search.PMTU,
+		MSS:             tcpMSSForMTU(search.PMTU, p.ipv6),
+		Hops:            search.Probes,
+		ElapsedMS:       int(elapsed.Milliseconds()),
+		RTTMS:           durationMS(search.RTT),
+		Confidence:      search.Confidence,
+		RTTMinMS:        durationMS(search.RTTMin),
+		RTTAvgMS:        durationMS(search.RTTAvg),
+		RTTMaxMS:        durationMS(search.RTTMax),
+		History:         search.History,
+		SourceAddr:      sourceAddr,
+		EgressInterface: egressInterface,
 	}, nil
 }
 
+// burst probes several sizes concurrently, one socket each. A fixed
+// source port cannot be shared, so those probes go one at a time.
+func (p *TCPProber) burst(ctx context.Context, sizes []int) []*ProbeResult {
+	if p.sourcePorts.Fixed() {
+		return sequentialProbes(ctx, sizes, p.ProbeTCP)
+	}
+	return parallelProbes(ctx, sizes, p.ProbeTCP)
+}
+
 // DiscoverPMTUUDP performs UDP-based MTU discovery
 func (p *UDPProber) DiscoverPMTUUDP(ctx context.Context, minMTU, maxMTU int) (*MTUResult, error) {
 	start := time.Now()
 
-	// Binary search for maximum working MTU
-	low := minMTU
-	high := maxMTU
-	lastWorking := 0
-	hops := 0
-
-	for low <= high {
-		mid := (low + high) / 2
-
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-		}
+	defer func() { _ = p.Close() }()
 
-		result := p.ProbeUDP(ctx, mid)
-		hops++
-
-		if result.Success {
-			lastWorking = mid
-			low = mid + 1
-		} else {
-			high = mid - 1
+	plan := searchPlan{CommonFirst: p.commonFirst, Parallel: p.parallel, Retries: p.retries}
+	search, err := searchPMTU(ctx, minMTU, maxMTU, p.ProbeUDP, p.burst, plan)
+	if err != nil {
+		if errors.Is(err, errNoWorkingMTU) && p.refused.Load() {
+			return nil, fmt.Errorf("%w: %s answered with ICMP Port Unreachable, so nothing is listening on UDP port %d (run cidrator mtu peer there or pick another --port)", err, p.targetAddr.IP, p.targetAddr.Port)
 		}
-	}
-
-	if lastWorking == 0 {
-		return nil, fmt.Errorf("no working MTU found in range %d-%d", minMTU, maxMTU)
+		return nil, err
 	}
 
 	elapsed := time.Since(start)
+	sourceAddr, egressInterface := p.source.egress()
 
 	return &MTUResult{
-		Target:    p.target,
-		Protocol:  "udp",
-		PMTU:      lastWorking,
-		MSS:       tcpMSSForMTU(lastWorking, p.ipv6),
-		Hops:      hops,
-		ElapsedMS: int(elapsed.Milliseconds()),
+		Target:          p.target,
+		Protocol:        "udp",
+		PMTU:            search.PMTU,
+		MSS:             tcpMSSForMTU(search.PMTU, p.ipv6),
+		Hops:            search.Probes,
+		ElapsedMS:       int(elapsed.Milliseconds()),
+		RTTMS:           durationMS(search.RTT),
+		Confidence:      search.Confidence,
+		RTTMinMS:        durationMS(search.RTTMin),
+		RTTAvgMS:        durationMS(search.RTTAvg),
+		RTTMaxMS:        durationMS(search.RTTMax),
+		History:         search.History,
+		SourceAddr:      sourceAddr,
+		EgressInterface: egressInterface,
 	}, nil
 }
+
+// burst probes several sizes concurrently, one socket each. A fixed
+// source port cannot be shared, so those probes go one at a time over the
+// shared socket.
+func (p *UDPProber) burst(ctx context.Context, sizes []int) []*ProbeResult {
+	if p.sourcePorts.Fixed() {
+		return sequentialProbes(ctx, sizes, p.ProbeUDP)
+	}
+	return parallelProbes(ctx, sizes, p.probeOwnSocket)
+}
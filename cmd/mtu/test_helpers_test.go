@@ -12,9 +12,20 @@ import (
 	"testing"
 	"time"
 
+	"github.com/euan-cowie/cidrator/internal/family"
 	"github.com/spf13/cobra"
 )
 
+// preferFamily sets the global prefer-family for one test
+func preferFamily(t *testing.T, preference string) {
+	t.Helper()
+	original := family.Preference()
+	if err := family.Configure(preference); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = family.Configure(original) })
+}
+
 // TestHelper provides common testing utilities
 type TestHelper struct {
 	t      *testing.T
@@ -0,0 +1,244 @@
+package mtu
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// TLS record and handshake values a probe writes and expects back
+const (
+	tlsRecordHandshake    = 0x16
+	tlsRecordAlert        = 0x15
+	tlsClientHello        = 0x01
+	tlsServerHello        = 0x02
+	tlsRecordHeaderBytes  = 5
+	tlsMaxRecordPlaintext = 16384
+
+	tlsExtServerName          = 0
+	tlsExtSupportedGroups     = 10
+	tlsExtECPointFormats      = 11
+	tlsExtSignatureAlgorithms = 13
+	tlsExtPadding             = 21 // RFC 7685
+	tlsExtSupportedVersions   = 43
+	tlsExtPSKKeyExchangeModes = 45
+	tlsExtKeyShare            = 51
+)
+
+// TLSProber handles MTU discovery using TLS handshakes. Each probe opens a
+// TCP connection that segments at the probe size, like TCPProber, and sends
+// a ClientHello padded to fill exactly one such segment; a ServerHello back
+// confirms the size. Any HTTPS server will answer, and no privileges are
+// needed.
+type TLSProber struct {
+	*TCPProber
+	serverName string // SNI sent in the ClientHello; empty for an address literal
+}
+
+// NewTLSProber creates a new TLS-based MTU prober. Port 0 means 443.
+func NewTLSProber(target string, ipv6 bool, port int, timeout time.Duration) (*TLSProber, error) {
+	if port == 0 {
+		port = 443
+	}
+	tcpProber, err := NewTCPProber(target, ipv6, port, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	serverName := ""
+	if net.ParseIP(target) == nil {
+		serverName = strings.TrimSuffix(target, ".")
+	}
+	return &TLSProber{TCPProber: tcpProber, serverName: serverName}, nil
+}
+
+// ProbeTLS performs a TLS-based MTU probe
+func (p *TLSProber) ProbeTLS(ctx context.Context, size int) *ProbeResult {
+	start := time.Now()
+	failed := func(err error) *ProbeResult {
+		return &ProbeResult{
+			Size:    size,
+			Success: false,
+			RTT:     time.Since(start),
+			Error:   err,
+		}
+	}
+
+	conn, err := p.dialProbe(ctx, payloadSizeForPacket(size, tcpPacketOverhead(p.ipv6)))
+	if err != nil {
+		return failed(err)
+	}
+	defer func() {
+		if closeErr := conn.Close(); closeErr != nil {
+			// Log close error but don't override main error
+			_ = closeErr // Silence linter
+		}
+	}()
+
+	payloadSize, err := p.probePayloadSize(conn, size)
+	if err != nil {
+		return failed(err)
+	}
+	hello, err := paddedClientHello(p.serverName, payloadSize)
+	if err != nil {
+		return failed(fmt.Errorf("packet size %d: %w", size, err))
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(p.timeout)); err != nil {
+		return failed(err)
+	}
+	if _, err := conn.Write(hello); err != nil {
+		return failed(err)
+	}
+
+	// A server only answers once it has the whole ClientHello, so either a
+	// ServerHello or an alert shows the full-size segment arrived. A
+	// timeout means it was too large.
+	reply := make([]byte, tlsRecordHeaderBytes+1)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return failed(err)
+	}
+	rtt := time.Since(start)
+	switch {
+	case reply[0] == tlsRecordHandshake && reply[tlsRecordHeaderBytes] == tlsServerHello:
+	case reply[0] == tlsRecordAlert:
+	default:
+		return failed(fmt.Errorf("not a TLS server: unexpected reply % x", reply))
+	}
+
+	return &ProbeResult{
+		Size:    size,
+		Success: true,
+		RTT:     rtt,
+	}
+}
+
+// DiscoverPMTUTLS performs TLS-based MTU discovery
+func (p *TLSProber) DiscoverPMTUTLS(ctx context.Context, minMTU, maxMTU int) (*MTUResult, error) {
+	start := time.Now()
+
+	plan := searchPlan{CommonFirst: p.commonFirst, Parallel: p.parallel, Retries: p.retries}
+	search, err := searchPMTU(ctx, minMTU, maxMTU, p.ProbeTLS, p.burst, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	elapsed := time.Since(start)
+	sourceAddr, egressInterface := p.source.egress()
+
+	return &MTUResult{
+		Target:          p.target,
+		Protocol:        "tls",
+		PMTU:            search.PMTU,
+		MSS:             tcpMSSForMTU(search.PMTU, p.ipv6),
+		Hops:            search.Probes,
+		ElapsedMS:       int(elapsed.Milliseconds()),
+		RTTMS:           durationMS(search.RTT),
+		Confidence:      search.Confidence,
+		RTTMinMS:        durationMS(search.RTTMin),
+		RTTAvgMS:        durationMS(search.RTTAvg),
+		RTTMaxMS:        durationMS(search.RTTMax),
+		History:         search.History,
+		SourceAddr:      sourceAddr,
+		EgressInterface: egressInterface,
+	}, nil
+}
+
+// burst probes several sizes concurrently, one connection each. A fixed
+// source port cannot be shared, so those probes go one at a time.
+func (p *TLSProber) burst(ctx context.Context, sizes []int) []*ProbeResult {
+	if p.sourcePorts.Fixed() {
+		return sequentialProbes(ctx, sizes, p.ProbeTLS)
+	}
+	return parallelProbes(ctx, sizes, p.ProbeTLS)
+}
+
+// paddedClientHello builds a TLS 1.3 ClientHello record of exactly size
+// bytes, offering TLS 1.2 as well so older servers still answer. The
+// padding extension takes up whatever the other fields leave.
+func paddedClientHello(serverName string, size int) ([]byte, error) {
+	if size-tlsRecordHeaderBytes > tlsMaxRecordPlaintext {
+		return nil, fmt.Errorf("a ClientHello record carries at most %d bytes, %d needed", tlsRecordHeaderBytes+tlsMaxRecordPlaintext, size)
+	}
+
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key share: %w", err)
+	}
+
+	var extensions []byte
+	if serverName != "" {
+		name := binary.BigEndian.AppendUint16([]byte{0}, uint16(len(serverName)))
+		extensions = appendTLSExtension(extensions, tlsExtServerName, appendTLSVector16(nil, append(name, serverName...)))
+	}
+	extensions = appendTLSExtension(extensions, tlsExtSupportedGroups, appendTLSVector16(nil, tlsUint16s(
+		uint16(tls.X25519), uint16(tls.CurveP256), uint16(tls.CurveP384))))
+	extensions = appendTLSExtension(extensions, tlsExtECPointFormats, []byte{1, 0})
+	extensions = appendTLSExtension(extensions, tlsExtSignatureAlgorithms, appendTLSVector16(nil, tlsUint16s(
+		uint16(tls.ECDSAWithP256AndSHA256), uint16(tls.PSSWithSHA256), uint16(tls.PKCS1WithSHA256),
+		uint16(tls.ECDSAWithP384AndSHA384), uint16(tls.PSSWithSHA384), uint16(tls.PKCS1WithSHA384),
+		uint16(tls.PSSWithSHA512), uint16(tls.PKCS1WithSHA512), uint16(tls.Ed25519))))
+	versions := tlsUint16s(tls.VersionTLS13, tls.VersionTLS12)
+	extensions = appendTLSExtension(extensions, tlsExtSupportedVersions, append([]byte{byte(len(versions))}, versions...))
+	extensions = appendTLSExtension(extensions, tlsExtPSKKeyExchangeModes, []byte{1, 1})
+	share := binary.BigEndian.AppendUint16(nil, uint16(tls.X25519))
+	share = appendTLSVector16(share, key.PublicKey().Bytes())
+	extensions = appendTLSExtension(extensions, tlsExtKeyShare, appendTLSVector16(nil, share))
+
+	body := binary.BigEndian.AppendUint16(nil, tls.VersionTLS12)
+	body = append(body, randomBytes(32)...)             // random
+	body = append(append(body, 32), randomBytes(32)...) // legacy_session_id, for middlebox compatibility
+	body = appendTLSVector16(body, tlsUint16s(
+		tls.TLS_AES_128_GCM_SHA256, tls.TLS_AES_256_GCM_SHA384, tls.TLS_CHACHA20_POLY1305_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+		tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256))
+	body = append(body, 1, 0) // compression_methods: null
+
+	// Record header, handshake header, body, extensions length, then the
+	// padding extension's own header
+	unpadded := tlsRecordHeaderBytes + 4 + len(body) + 2 + len(extensions) + 4
+	if unpadded > size {
+		return nil, fmt.Errorf("a ClientHello needs at least %d bytes, only %d fit", unpadded, size)
+	}
+	extensions = appendTLSExtension(extensions, tlsExtPadding, make([]byte, size-unpadded))
+	body = appendTLSVector16(body, extensions)
+
+	handshake := []byte{tlsClientHello, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+	record := []byte{tlsRecordHandshake, 0x03, 0x01} // TLS 1.0 in the record layer, as TLS 1.3 clients send
+	record = appendTLSVector16(record, append(handshake, body...))
+	return record, nil
+}
+
+// appendTLSExtension appends an extension with its type and length
+func appendTLSExtension(b []byte, extension uint16, data []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, extension)
+	return appendTLSVector16(b, data)
+}
+
+// appendTLSVector16 appends data behind a two-byte length
+func appendTLSVector16(b, data []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+func tlsUint16s(values ...uint16) []byte {
+	var b []byte
+	for _, value := range values {
+		b = binary.BigEndian.AppendUint16(b, value)
+	}
+	return b
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return b
+}
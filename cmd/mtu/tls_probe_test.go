@@ -0,0 +1,78 @@
+package mtu
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPaddedClientHello(t *testing.T) {
+	for _, size := range []int{536, 1460, 8960} {
+		hello, err := paddedClientHello("example.com", size)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if len(hello) != size {
+			t.Fatalf("size %d: ClientHello is %d bytes", size, len(hello))
+		}
+		if hello[0] != tlsRecordHandshake || hello[tlsRecordHeaderBytes] != tlsClientHello {
+			t.Fatalf("size %d: not a ClientHello record: % x", size, hello[:tlsRecordHeaderBytes+1])
+		}
+		if got := int(hello[3])<<8 | int(hello[4]); got != size-tlsRecordHeaderBytes {
+			t.Fatalf("size %d: record length %d, want %d", size, got, size-tlsRecordHeaderBytes)
+		}
+	}
+
+	if _, err := paddedClientHello("example.com", 100); err == nil || !strings.Contains(err.Error(), "only 100 fit") {
+		t.Fatalf("expected a too-small error, got %v", err)
+	}
+	if _, err := paddedClientHello("", 20000); err == nil {
+		t.Fatal("expected an error for a ClientHello larger than one record")
+	}
+}
+
+func TestDiscoverPMTUTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	// Each probe hangs up after the ServerHello
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	prober, err := NewTLSProber("127.0.0.1", false, server.Listener.Addr().(*net.TCPAddr).Port, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := prober.DiscoverPMTUTLS(context.Background(), 1200, 1500)
+	if err != nil {
+		t.Fatalf("DiscoverPMTUTLS: %v", err)
+	}
+	if result.Protocol != "tls" || result.PMTU != 1500 || result.MSS != tcpMSSForMTU(1500, false) {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.SourceAddr != "127.0.0.1" {
+		t.Fatalf("SourceAddr = %q, want 127.0.0.1", result.SourceAddr)
+	}
+	if loopback, ok := loopbackInterface(); ok && result.EgressInterface != loopback.Name {
+		t.Fatalf("EgressInterface = %q, want %q", result.EgressInterface, loopback.Name)
+	}
+}
+
+func TestProbeTLSRejectsOtherServers(t *testing.T) {
+	// An echo server sends the ClientHello straight back
+	port, _ := startTCPEchoPeer(t, 65535, true)
+
+	prober, err := NewTLSProber("127.0.0.1", false, port, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := prober.ProbeTLS(context.Background(), 1400)
+	if result.Success || result.Error == nil || !strings.Contains(result.Error.Error(), "not a TLS server") {
+		t.Fatalf("expected the echo to be rejected, got %+v", result)
+	}
+}
@@ -0,0 +1,256 @@
+package mtu
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	// maxTrainPackets bounds --train so a typo cannot flood a target
+	maxTrainPackets = 1000
+
+	// defaultTrainInterval matches the 20ms packetization of common VoIP codecs
+	defaultTrainInterval = 20 * time.Millisecond
+
+	// jitterGain is the RFC 3550 smoothing factor for interarrival jitter
+	jitterGain = 1.0 / 16
+)
+
+// TrainResult summarizes a train of sequence-numbered echo probes
+type TrainResult struct {
+	Size             int     `json:"size"`
+	IntervalMS       float64 `json:"interval_ms"`
+	Sent             int     `json:"sent"`
+	Received         int     `json:"received"`
+	LossPercent      float64 `json:"loss_percent"`
+	RTTMinMS         float64 `json:"rtt_min_ms"`
+	RTTAvgMS         float64 `json:"rtt_avg_ms"`
+	RTTMaxMS         float64 `json:"rtt_max_ms"`
+	JitterMS         float64 `json:"jitter_ms"`          // RFC 3550 interarrival jitter over round-trip transit times
+	Reordered        int     `json:"reordered"`          // Replies that arrived after a later sequence number (RFC 4737)
+	ReorderedPercent float64 `json:"reordered_percent"`  // Share of received replies that were reordered
+	MaxReorderExtent int     `json:"max_reorder_extent"` // Most replies any reordered reply arrived behind
+	Duplicates       int     `json:"duplicates"`
+}
+
+// trainArrival is one echo reply matched to its position in the train
+type trainArrival struct {
+	Seq      int
+	Received time.Time
+}
+
+// SendTrain sends count echo requests of size bytes, interval apart, sharing one
+// identifier with consecutive sequence numbers, and summarizes the replies. The
+// rate limiter still applies, so --pps caps trains faster than it allows.
+func (d *MTUDiscoverer) SendTrain(ctx context.Context, count, size int, interval time.Duration) (*TrainResult, error) {
+	if d.conn == nil {
+		return nil, errcode.Errorf(errcode.MTUUnsupportedProtocol, "packet trains only support ICMP protocol")
+	}
+
+	id := d.security.Randomizer.GenerateRandomID()
+	baseSeq := d.security.Randomizer.GenerateRandomSeq()
+
+	// Collect replies until every probe is accounted for or the read deadline
+	// set after the last send expires
+	if err := d.conn.SetReadDeadline(time.Time{}); err != nil {
+		return nil, fmt.Errorf("failed to set read deadline: %w", err)
+	}
+	arrivalsChan := make(chan []trainArrival, 1)
+	go func() {
+		var arrivals []trainArrival
+		seen := make(map[int]bool, count)
+		buf := make([]byte, 65535)
+		for len(seen) < count {
+			n, _, err := d.conn.ReadFrom(buf)
+			received := time.Now()
+			if err != nil {
+				break
+			}
+			if seq, ok := d.parseTrainReply(buf[:n], id, baseSeq, count); ok {
+				arrivals = append(arrivals, trainArrival{Seq: seq, Received: received})
+				seen[seq] = true
+			}
+		}
+		arrivalsChan <- arrivals
+	}()
+
+	sent, sendErr := d.sendTrainProbes(ctx, count, size, interval, id, baseSeq)
+
+	drain := time.Now().Add(d.timeout)
+	if sendErr != nil {
+		drain = time.Now()
+	}
+	if err := d.conn.SetReadDeadline(drain); err != nil && sendErr == nil {
+		sendErr = fmt.Errorf("failed to set read deadline: %w", err)
+	}
+	arrivals := <-arrivalsChan
+
+	if sendErr != nil {
+		return nil, sendErr
+	}
+	return summarizeTrain(size, interval, sent, arrivals), nil
+}
+
+// sendTrainProbes paces the train and returns the send time of each probe
+func (d *MTUDiscoverer) sendTrainProbes(ctx context.Context, count, size int, interval time.Duration, id, baseSeq int) ([]time.Time, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	sent := make([]time.Time, 0, count)
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return sent, ctx.Err()
+			case <-ticker.C:
+			}
+		}
+		d.security.RateLimiter.Wait()
+
+		packet, err := d.createEchoPacket(size, id, (baseSeq+i)&0xffff)
+		if err != nil {
+			return sent, err
+		}
+		sent = append(sent, time.Now())
+		if _, err := d.conn.WriteTo(packet, d.targetAddr); err != nil {
+			return sent, fmt.Errorf("packet train probe %d: %w", i, err)
+		}
+	}
+	return sent, nil
+}
+
+// parseTrainReply returns the train position of an echo reply, or false if the
+// packet is not a reply to this train
+func (d *MTUDiscoverer) parseTrainReply(data []byte, id, baseSeq, count int) (int, bool) {
+	proto := 1
+	if d.ipv6 {
+		proto = 58
+	}
+	msg, err := icmp.ParseMessage(proto, data)
+	if err != nil || (msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply) {
+		return 0, false
+	}
+	// Linux rewrites the identifier on datagram sockets, whose replies it
+	// already matches to the socket
+	echo, ok := msg.Body.(*icmp.Echo)
+	if !ok || (echo.ID != id && !d.datagram) {
+		return 0, false
+	}
+	seq := (echo.Seq - baseSeq) & 0xffff
+	if seq >= count {
+		return 0, false
+	}
+	return seq, true
+}
+
+// summarizeTrain computes loss, RTT, jitter, and reordering from the send time
+// of each probe and the replies in the order they arrived
+func summarizeTrain(size int, interval time.Duration, sent []time.Time, arrivals []trainArrival) *TrainResult {
+	result := &TrainResult{
+		Size:       size,
+		IntervalMS: durationMS(interval),
+		Sent:       len(sent),
+	}
+
+	seen := make(map[int]bool, len(arrivals))
+	order := make([]int, 0, len(arrivals)) // Unique sequence numbers in arrival order
+	var minRTT, maxRTT, totalRTT, previousTransit time.Duration
+	var jitter float64
+	highestSeq := -1
+
+	for _, arrival := range arrivals {
+		if arrival.Seq >= len(sent) {
+			continue
+		}
+		if seen[arrival.Seq] {
+			result.Duplicates++
+			continue
+		}
+		seen[arrival.Seq] = true
+
+		transit := arrival.Received.Sub(sent[arrival.Seq])
+		if len(order) == 0 || transit < minRTT {
+			minRTT = transit
+		}
+		maxRTT = max(maxRTT, transit)
+		totalRTT += transit
+
+		// RFC 3550 section 6.4.1: J += (|D(i-1,i)| - J) / 16, in arrival order
+		if len(order) > 0 {
+			difference := transit - previousTransit
+			if difference < 0 {
+				difference = -difference
+			}
+			jitter += (float64(difference) - jitter) * jitterGain
+		}
+		previousTransit = transit
+
+		// RFC 4737: a packet is reordered if a later sequence number arrived first;
+		// its extent is how far back the earliest such packet arrived
+		if arrival.Seq < highestSeq {
+			result.Reordered++
+			for j, earlier := range order {
+				if earlier > arrival.Seq {
+					result.MaxReorderExtent = max(result.MaxReorderExtent, len(order)-j)
+					break
+				}
+			}
+		}
+		highestSeq = max(highestSeq, arrival.Seq)
+		order = append(order, arrival.Seq)
+	}
+
+	result.Received = len(order)
+	if result.Sent > 0 {
+		result.LossPercent = percent(result.Sent-result.Received, result.Sent)
+	}
+	if result.Received > 0 {
+		result.RTTMinMS = durationMS(minRTT)
+		result.RTTAvgMS = durationMS(totalRTT / time.Duration(result.Received))
+		result.RTTMaxMS = durationMS(maxRTT)
+		result.JitterMS = jitter / float64(time.Millisecond)
+		result.ReorderedPercent = percent(result.Reordered, result.Received)
+	}
+	return result
+}
+
+func durationMS(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func percent(part, whole int) float64 {
+	return float64(part) * 100 / float64(whole)
+}
+
+// estimatedTrainDuration is how long a train takes to send at the slower of
+// --train-interval and --pps, plus one timeout for the last reply
+func estimatedTrainDuration(opts discoveryOptions) time.Duration {
+	if opts.TrainCount == 0 {
+		return 0
+	}
+	pace := opts.TrainInterval
+	if opts.PacketsPerSecond > 0 {
+		pace = max(pace, time.Second/time.Duration(opts.PacketsPerSecond))
+	}
+	return time.Duration(opts.TrainCount-1)*pace + opts.Timeout
+}
+
+func outputTrainTable(train *TrainResult) {
+	fmt.Printf("\nPacket train: %d x %d bytes every %.0fms\n", train.Sent, train.Size, train.IntervalMS)
+	fmt.Printf("Received: %d/%d (%.1f%% loss)\n", train.Received, train.Sent, train.LossPercent)
+	if train.Received == 0 {
+		return
+	}
+	fmt.Printf("RTT min/avg/max: %.2f/%.2f/%.2f ms\n", train.RTTMinMS, train.RTTAvgMS, train.RTTMaxMS)
+	fmt.Printf("Jitter: %.2f ms\n", train.JitterMS)
+	fmt.Printf("Reordered: %d (%.1f%%, max extent %d)\n", train.Reordered, train.ReorderedPercent, train.MaxReorderExtent)
+	if train.Duplicates > 0 {
+		fmt.Printf("Duplicates: %d\n", train.Duplicates)
+	}
+}
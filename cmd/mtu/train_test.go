@@ -0,0 +1,289 @@
+package mtu
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// trainEchoConn answers echo requests with echo replies. Sequence offsets in
+// drop are never answered; offsets in hold are answered after the next probe.
+type trainEchoConn struct {
+	fakePacketConn
+	drop map[int]bool
+	hold map[int]bool
+
+	mu       sync.Mutex
+	replies  chan []byte
+	held     [][]byte
+	writes   int
+	deadline time.Time
+}
+
+func newTrainEchoConn() *trainEchoConn {
+	return &trainEchoConn{replies: make(chan []byte, 64)}
+}
+
+func (c *trainEchoConn) WriteTo(p []byte, _ net.Addr) (int, error) {
+	msg, err := icmp.ParseMessage(1, p)
+	if err != nil {
+		return 0, err
+	}
+	echo := msg.Body.(*icmp.Echo)
+	reply, err := (&icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: echo}).Marshal(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	offset := c.writes
+	c.writes++
+
+	held := c.held
+	c.held = nil
+	switch {
+	case c.drop[offset]:
+	case c.hold[offset]:
+		c.held = append(c.held, reply)
+	default:
+		c.replies <- reply
+	}
+	for _, r := range held {
+		c.replies <- r
+	}
+	return len(p), nil
+}
+
+func (c *trainEchoConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		select {
+		case reply := <-c.replies:
+			return copy(p, reply), &net.IPAddr{IP: net.ParseIP("198.51.100.10")}, nil
+		case <-time.After(5 * time.Millisecond):
+		}
+		c.mu.Lock()
+		expired := !c.deadline.IsZero() && time.Now().After(c.deadline)
+		c.mu.Unlock()
+		if expired {
+			return 0, nil, timeoutNetError{message: "i/o timeout"}
+		}
+	}
+}
+
+func (c *trainEchoConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return nil
+}
+
+func TestSummarizeTrain(t *testing.T) {
+	start := time.Unix(0, 0)
+	ms := func(n float64) time.Duration { return time.Duration(n * float64(time.Millisecond)) }
+	sentAt := func(count int) []time.Time {
+		sent := make([]time.Time, count)
+		for i := range sent {
+			sent[i] = start.Add(time.Duration(i) * 20 * time.Millisecond)
+		}
+		return sent
+	}
+	arrive := func(sent []time.Time, seq int, rtt time.Duration) trainArrival {
+		return trainArrival{Seq: seq, Received: sent[seq].Add(rtt)}
+	}
+
+	t.Run("steady path has no jitter", func(t *testing.T) {
+		sent := sentAt(4)
+		var arrivals []trainArrival
+		for seq := range sent {
+			arrivals = append(arrivals, arrive(sent, seq, ms(10)))
+		}
+		result := summarizeTrain(1500, 20*time.Millisecond, sent, arrivals)
+		if result.Received != 4 || result.LossPercent != 0 || result.JitterMS != 0 || result.Reordered != 0 {
+			t.Fatalf("unexpected summary: %+v", result)
+		}
+		if result.RTTMinMS != 10 || result.RTTAvgMS != 10 || result.RTTMaxMS != 10 {
+			t.Fatalf("unexpected RTTs: %+v", result)
+		}
+	})
+
+	t.Run("jitter follows RFC 3550", func(t *testing.T) {
+		sent := sentAt(3)
+		arrivals := []trainArrival{arrive(sent, 0, ms(10)), arrive(sent, 1, ms(26)), arrive(sent, 2, ms(10))}
+		result := summarizeTrain(1500, 20*time.Millisecond, sent, arrivals)
+
+		// J1 = 16/16 = 1, J2 = 1 + (16-1)/16
+		want := 1 + 15.0/16
+		if math.Abs(result.JitterMS-want) > 1e-9 {
+			t.Fatalf("JitterMS = %v, want %v", result.JitterMS, want)
+		}
+	})
+
+	t.Run("loss reordering and duplicates", func(t *testing.T) {
+		sent := sentAt(6)
+		arrivals := []trainArrival{
+			arrive(sent, 0, ms(10)),
+			arrive(sent, 2, ms(10)),
+			arrive(sent, 3, ms(10)),
+			arrive(sent, 1, ms(60)), // Arrives two replies late
+			arrive(sent, 3, ms(70)), // Duplicate
+			arrive(sent, 5, ms(10)),
+		}
+		result := summarizeTrain(1500, 20*time.Millisecond, sent, arrivals)
+		if result.Sent != 6 || result.Received != 5 || math.Abs(result.LossPercent-100.0/6) > 1e-9 {
+			t.Fatalf("unexpected loss: %+v", result)
+		}
+		if result.Reordered != 1 || result.MaxReorderExtent != 2 || result.ReorderedPercent != 20 {
+			t.Fatalf("unexpected reordering: %+v", result)
+		}
+		if result.Duplicates != 1 || result.RTTMaxMS != 60 {
+			t.Fatalf("unexpected duplicates or RTT: %+v", result)
+		}
+	})
+
+	t.Run("nothing received", func(t *testing.T) {
+		result := summarizeTrain(1500, 20*time.Millisecond, sentAt(3), nil)
+		if result.Received != 0 || result.LossPercent != 100 || result.ReorderedPercent != 0 {
+			t.Fatalf("unexpected summary: %+v", result)
+		}
+	})
+}
+
+func TestSendTrain(t *testing.T) {
+	conn := newTrainEchoConn()
+	conn.drop = map[int]bool{4: true}
+	conn.hold = map[int]bool{1: true}
+	d := newICMPDiscovererForTest(conn, false)
+
+	result, err := d.SendTrain(context.Background(), 6, 600, time.Millisecond)
+	if err != nil {
+		t.Fatalf("SendTrain returned error: %v", err)
+	}
+	if result.Sent != 6 || result.Received != 5 || result.Size != 600 {
+		t.Fatalf("unexpected counts: %+v", result)
+	}
+	if result.Reordered != 1 || result.MaxReorderExtent != 1 {
+		t.Fatalf("expected the held reply to be reordered: %+v", result)
+	}
+}
+
+func TestSendTrainIgnoresForeignReplies(t *testing.T) {
+	conn := newTrainEchoConn()
+	foreign, err := (&icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 999, Seq: 1}}).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.replies <- foreign
+	d := newICMPDiscovererForTest(conn, false)
+
+	result, err := d.SendTrain(context.Background(), 3, 600, time.Millisecond)
+	if err != nil {
+		t.Fatalf("SendTrain returned error: %v", err)
+	}
+	if result.Received != 3 || result.Duplicates != 0 {
+		t.Fatalf("foreign reply should be ignored: %+v", result)
+	}
+}
+
+func TestParseTrainReplyOnDatagramSocket(t *testing.T) {
+	// Linux replaces the identifier with the socket's own on datagram sockets
+	reply, err := (&icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: 999, Seq: 11}}).Marshal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := newICMPDiscovererForTest(newTrainEchoConn(), false)
+	if _, ok := d.parseTrainReply(reply, 1, 10, 3); ok {
+		t.Fatal("raw socket should reject a reply with another identifier")
+	}
+	d.datagram = true
+	if seq, ok := d.parseTrainReply(reply, 1, 10, 3); !ok || seq != 1 {
+		t.Fatalf("datagram socket reply parsed as %d, %v; want 1, true", seq, ok)
+	}
+}
+
+func TestSendTrainCancelled(t *testing.T) {
+	d := newICMPDiscovererForTest(newTrainEchoConn(), false)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := d.SendTrain(ctx, 5, 600, time.Second); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
+
+func TestReadDiscoveryOptionsTrain(t *testing.T) {
+	if !rawICMPSupported {
+		t.Skip("ICMP is compiled out of minimal builds")
+	}
+	newCmd := func(t *testing.T, values map[string]string) (discoveryOptions, error) {
+		cmd := newDiscoveryOptionsCommand()
+		cmd.Flags().Int("train", 0, "")
+		cmd.Flags().Duration("train-interval", defaultTrainInterval, "")
+		cmd.Flags().Int("train-size", 0, "")
+		for name, value := range values {
+			mustSetFlag(t, cmd, name, value)
+		}
+		return readDiscoveryOptions(cmd, "example.com")
+	}
+
+	opts, err := newCmd(t, map[string]string{"train": "50"})
+	if err != nil {
+		t.Fatalf("readDiscoveryOptions returned error: %v", err)
+	}
+	if opts.TrainCount != 50 || opts.TrainInterval != defaultTrainInterval || opts.TrainSize != 0 {
+		t.Fatalf("unexpected train options: %+v", opts)
+	}
+
+	tests := []struct {
+		name   string
+		values map[string]string
+		code   errcode.Code
+		want   string
+	}{
+		{name: "too many", values: map[string]string{"train": "5000"}, code: errcode.CLIUsage, want: "--train must be between 0 and 1000"},
+		{name: "non-icmp", values: map[string]string{"train": "10", "proto": "udp"}, code: errcode.MTUUnsupportedProtocol, want: "only support ICMP"},
+		{name: "hops", values: map[string]string{"train": "10", "hops": "true"}, code: errcode.CLIUsage, want: "--train cannot be combined with --hops"},
+		{name: "small size", values: map[string]string{"train": "10", "train-size": "100"}, code: errcode.CLIUsage, want: "--train-size must be 0 or between 576 and 9216"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newCmd(t, tt.values)
+			if err == nil || errcode.Of(err) != tt.code || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected %s error containing %q, got %v", tt.code, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestDryRunPlanIncludesTrain(t *testing.T) {
+	opts := discoveryOptions{
+		Destination:      "192.0.2.1",
+		Protocol:         "icmp",
+		MinMTU:           576,
+		MaxMTU:           1500,
+		Timeout:          time.Second,
+		PacketsPerSecond: 10,
+	}
+	base := newDryRunPlan(opts)
+
+	opts.TrainCount = 100
+	opts.TrainInterval = defaultTrainInterval
+	plan := newDryRunPlan(opts)
+	if plan.TrainPackets != 100 || plan.MaxPackets != base.MaxPackets+100 || plan.MaxBytes != base.MaxBytes+100*1500 {
+		t.Fatalf("unexpected train plan: %+v (base %+v)", plan, base)
+	}
+
+	// 99 gaps at the 100ms --pps pace plus one timeout
+	if got, want := plan.EstimatedDurationMS-base.EstimatedDurationMS, int64(99*100+1000); got != want {
+		t.Fatalf("train adds %dms to the estimate, want %dms", got, want)
+	}
+}
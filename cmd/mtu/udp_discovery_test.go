@@ -1,6 +1,7 @@
 package mtu
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net"
@@ -10,7 +11,7 @@ import (
 	"time"
 )
 
-func startLocalUDPPeer(t *testing.T, maxWirePacketSize int) (*net.UDPConn, func()) {
+func startLocalUDPPeer(t testing.TB, maxWirePacketSize int) (*net.UDPConn, func()) {
 	t.Helper()
 
 	conn, err := openPeerUDPListener("127.0.0.1", 0)
@@ -95,6 +96,141 @@ func TestUDPProberAndDiscoveryAgainstPeer(t *testing.T) {
 	}
 }
 
+func TestUDPProberSkipsLateEchoes(t *testing.T) {
+	const maxPMTU, timeout = 1400, 100 * time.Millisecond
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = peer.Close() }()
+	go func() {
+		buf := make([]byte, 65535)
+		for first := true; ; first = false {
+			n, addr, err := peer.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			echo := append([]byte(nil), buf[:n]...)
+			switch {
+			case first:
+				// Answer too late for the first probe to see it
+				time.AfterFunc(timeout*3/2, func() { _, _ = peer.WriteToUDP(echo, addr) })
+			case udpPacketSizeFromPayload(n, false) <= maxPMTU:
+				_, _ = peer.WriteToUDP(echo, addr)
+			}
+		}
+	}()
+
+	prober, err := NewUDPProber("127.0.0.1", false, peer.LocalAddr().(*net.UDPAddr).Port, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = prober.Close() }()
+
+	if result := prober.ProbeUDP(context.Background(), maxPMTU); result.Success {
+		t.Fatalf("expected the first probe to time out, got %+v", result)
+	}
+	// The first probe's echo arrives while this one waits; it must not be
+	// taken for the answer to a size the peer drops
+	if result := prober.ProbeUDP(context.Background(), maxPMTU+100); result.Success {
+		t.Fatalf("a late echo confirmed a dropped size: %+v", result)
+	}
+	if result := prober.ProbeUDP(context.Background(), maxPMTU); !result.Success {
+		t.Fatalf("expected the shared socket to keep working, got %+v", result)
+	}
+}
+
+// fragmentationChan is a FragmentationSource fed by the test
+type fragmentationChan chan *FragmentationError
+
+func (c fragmentationChan) Errors() <-chan *FragmentationError { return c }
+
+func TestUDPProberClassifiesFailures(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := peer.LocalAddr().(*net.UDPAddr).Port
+
+	prober, err := NewUDPProber("127.0.0.1", false, port, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = prober.Close() }()
+	icmp := make(fragmentationChan, 4)
+	prober.SetICMPListener(icmp)
+
+	// The peer reads but never answers
+	if result := prober.ProbeUDP(context.Background(), 1400); result.Outcome != OutcomeNoResponse || result.Describe() != "no-response" {
+		t.Fatalf("silent peer: got %+v", result)
+	}
+
+	// A router reports the probe too big; errors about other packets are ignored
+	go func() {
+		buf := make([]byte, 65535)
+		_, from, err := peer.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		icmp <- &FragmentationError{NextHopMTU: 1300, OriginalDst: net.IPv4(127, 0, 0, 1), OriginalSrcPort: from.Port + 1, OriginalDstPort: port}
+		icmp <- &FragmentationError{NextHopMTU: 1200, OriginalDst: net.IPv4(127, 0, 0, 1), OriginalSrcPort: from.Port, OriginalDstPort: port, OriginalSize: 1401}
+		icmp <- &FragmentationError{NextHopMTU: 1400, OriginalDst: net.IPv4(127, 0, 0, 1), OriginalSrcPort: from.Port, OriginalDstPort: port, OriginalSize: 1500}
+	}()
+	start := time.Now()
+	result := prober.ProbeUDP(context.Background(), 1500)
+	if result.Outcome != OutcomeFragNeeded || result.Describe() != "icmp-frag-needed(mtu=1400)" || result.ICMPErr == nil || result.ICMPErr.Code != 4 {
+		t.Fatalf("fragmentation needed: got %+v", result)
+	}
+	if elapsed := time.Since(start); elapsed >= timeout {
+		t.Fatalf("probe waited %v for its timeout despite the ICMP error", elapsed)
+	}
+
+	// Nothing listens on the port any more
+	_ = peer.Close()
+	result = prober.ProbeUDP(context.Background(), 1400)
+	if result.Outcome != OutcomePortUnreachable {
+		result = prober.ProbeUDP(context.Background(), 1400) // The refusal can surface on the next probe
+	}
+	if result.Outcome != OutcomePortUnreachable || result.ICMPErr == nil || result.ICMPErr.Code != 3 {
+		t.Fatalf("closed port: got %+v", result)
+	}
+	if _, err := prober.DiscoverPMTUUDP(context.Background(), 1200, 1500); err == nil || !strings.Contains(err.Error(), "Port Unreachable") {
+		t.Fatalf("DiscoverPMTUUDP = %v, want a closed-port hint", err)
+	}
+}
+
+// BenchmarkProbeUDP compares probing over the shared socket with opening a
+// socket per probe, against a loopback peer
+func BenchmarkProbeUDP(b *testing.B) {
+	conn, shutdown := startLocalUDPPeer(b, 1500)
+	defer shutdown()
+
+	prober, err := NewUDPProber("127.0.0.1", false, conn.LocalAddr().(*net.UDPAddr).Port, time.Second)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer func() { _ = prober.Close() }()
+
+	for _, bench := range []struct {
+		name  string
+		probe probeFunc
+	}{
+		{"shared-socket", prober.ProbeUDP},
+		{"socket-per-probe", prober.probeOwnSocket},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if result := bench.probe(context.Background(), 1400); !result.Success {
+					b.Fatalf("probe failed: %+v", result)
+				}
+			}
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "probes/s")
+		})
+	}
+}
+
 func TestDiscoveryCommandFlowsAgainstUDPPeer(t *testing.T) {
 	maxPMTU := 1400
 	conn, shutdown := startLocalUDPPeer(t, maxPMTU)
@@ -185,12 +321,17 @@ func TestPerformMTUDiscoveryLinearAndFallback(t *testing.T) {
 	if linearResult.PMTU != maxPMTU {
 		t.Fatalf("unexpected linear PMTU: got %d, want %d", linearResult.PMTU, maxPMTU)
 	}
+	if linearResult.Warnings == nil || len(linearResult.Warnings) != 0 {
+		t.Fatalf("expected an empty warnings array, got %#v", linearResult.Warnings)
+	}
 
+	var warnings, progress bytes.Buffer
 	fallbackDiscoverer := &MTUDiscoverer{
 		target:   "127.0.0.1",
 		ipv6:     false,
 		protocol: "bogus",
 		timeout:  150 * time.Millisecond,
+		progress: progressPrinter(&progress, &warnings),
 	}
 
 	fallbackResult, err := fallbackDiscoverer.WithPLPMTUDFallback(context.Background(), 1300, 1450, port)
@@ -200,6 +341,16 @@ func TestPerformMTUDiscoveryLinearAndFallback(t *testing.T) {
 	if fallbackResult.Protocol != "plpmtud" || fallbackResult.PMTU != maxPMTU {
 		t.Fatalf("unexpected PLPMTUD fallback result: %+v", fallbackResult)
 	}
+	if !strings.Contains(progress.String(), "PLPMTUD: BASE -> SEARCHING, PLPMTU 1300") || len(fallbackResult.PLPMTUD) == 0 {
+		t.Fatalf("expected the state transitions in progress output and the result, got %q", progress.String())
+	}
+	recorded := fallbackDiscoverer.Warnings()
+	if len(recorded) != 1 || !strings.Contains(recorded[0], "falling back to PLPMTUD on port "+strconv.Itoa(port)) {
+		t.Fatalf("expected the fallback to be recorded as a warning, got %q", recorded)
+	}
+	if warnings.String() != "Warning: "+recorded[0]+"\n" {
+		t.Fatalf("expected the warning on the warning writer, got %q", warnings.String())
+	}
 
 	plpResult, err := performMTUDiscovery(context.Background(), discoveryOptions{
 		Destination: "127.0.0.1",
@@ -4,42 +4,217 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/notify"
+	"github.com/euan-cowie/cidrator/internal/proxy"
+	"github.com/euan-cowie/cidrator/internal/targets"
+	"github.com/euan-cowie/cidrator/internal/units"
 	"github.com/spf13/cobra"
 )
 
 // watchCmd represents the watch command
 var watchCmd = &cobra.Command{
-	Use:   "watch <destination>",
+	Use:   "watch [destination...]",
 	Short: "Re-run discover every N seconds and notify on change",
 	Long: `Watch continuously monitors the Path-MTU to a destination and alerts
 when changes are detected. Useful for detecting MTU black holes or path changes.
 
-Examples:
-  cidrator mtu watch example.com -i 10s
-  cidrator mtu watch 8.8.8.8 --interval 30s --mss-only`,
-	Args: cobra.ExactArgs(1),
-	RunE: runWatch,
+Watch runs until Ctrl+C or SIGTERM, a PMTU drop, --count cycles, or the --for
+duration has passed. A probe in flight when it stops is abandoned. On exit it
+prints a summary per target (samples, failures, and the min, max, and median
+PMTU) and every PMTU change seen; with --format json or yaml the summary is the
+last record, under "summary".
+
+--html-report writes a self-contained HTML page charting PMTU and RTT over time
+for each target when watch exits, for sharing in incident retrospectives.
+--html-every also rewrites it periodically.
+
+--export writes every sample, one row per target per cycle with its time,
+status, PMTU, RTT, and error, to a CSV or Parquet file as watch runs, ready for
+pandas or DuckDB. CSV rows are flushed as they are taken. Parquet rows are
+written in row groups at least once a minute, and the file is readable between
+row groups, so a long session can be analyzed while it is still running.
+
+With more than one destination, watch probes each target once per interval and
+classifies it as healthy, degraded (PMTU below the best seen for that target),
+icmp-blocked (ICMP discovery failed but a TCP connect to --port, default 443,
+succeeded), or down. Each cycle prints a fleet summary with the count in each
+class and any targets that changed class. Fleet mode keeps running when a PMTU
+drops instead of exiting. --proxy sends the TCP connect through a SOCKS5 or
+HTTP CONNECT egress proxy and reports the proxy leg of the timing separately.
+
+Fleet probes are spread evenly over the interval rather than fired together:
+each target gets a fixed slot, ordered by a hash of its name so the schedule is
+the same on every run, plus random jitter of up to half a slot. A cycle starts
+an interval after the previous one did. --verbose prints the schedule and each
+probe as it fires to stderr. A probe that takes longer than its slot runs
+alongside the next ones, up to --concurrency (default 8) at once, and all
+probes share one --pps budget, so a large fleet adds no extra load.
+
+--events (with --format json or yaml) prints one record per target change
+instead of a fleet summary per cycle: the first result for each target
+("initial"), a move to another health class ("health"), or a PMTU change within
+a class ("pmtu"), each with the previous and current values.
+
+--listen serves Prometheus metrics at /metrics on the given address while watch
+runs: cidrator_pmtu_bytes and cidrator_mss_bytes per target (absent until a
+probe succeeds), the cidrator_probe_rtt_seconds histogram, cidrator_probes_total,
+and cidrator_probe_failures_total by error code. With --listen, a single-target
+watch keeps running after a PMTU drop instead of exiting, so the drop can be
+scraped and alerted on.
+
+--webhook POSTs a JSON alert when the PMTU or MSS changes (only MSS changes
+with --mss-only), or in fleet mode when a target changes health class. A
+failed delivery is retried three times, 1s, 2s, and 4s apart, and then
+reported on stderr without ending the watch. --syslog also logs each alert to
+the local syslog at warning level. Alerts also go to the notifiers of the
+alerts section of the config file (Slack, email, scripts, and more).
+
+--targets-from adds the members of a service discovery source to the fleet and
+asks the source again every --targets-refresh (default 1m), so watch follows a
+fleet that scales or moves without a restart. Sources are
+prometheus-http-sd:<URL> (the Prometheus HTTP SD JSON format) and
+consul:service=<name>[,tag=<tag>][,dc=<dc>][,addr=<host:port>] (passing
+instances from the Consul health API; CONSUL_HTTP_ADDR and CONSUL_HTTP_TOKEN are
+honored), and file:<PATH> (one target per line, # starts a comment). Ports in
+discovered targets are ignored. A failed refresh is reported and the previous
+target list is kept.`,
+	RunE:        runWatch,
+	Annotations: dryRunAnnotations,
 }
 
 func init() {
-	watchCmd.Flags().Duration("interval", 10*time.Second, "Interval between checks")
+	units.Duration(watchCmd.Flags(), "interval", 10*time.Second, "Interval between checks")
 	watchCmd.Flags().Bool("mss-only", false, "Only alert on MSS changes")
+	watchCmd.Flags().Int("count", 0, "Stop after this many cycles (0 = no limit)")
+	units.Duration(watchCmd.Flags(), "for", 0, "Stop once this long has passed, such as 1h (0 = no limit)")
+	watchCmd.Flags().String("html-report", "", "Write a self-contained HTML page charting PMTU and RTT per target when watch exits")
+	units.Duration(watchCmd.Flags(), "html-every", 0, "Also rewrite the --html-report page this often while watching (0 = only on exit)")
+	watchCmd.Flags().String("proxy", "", "SOCKS5 or HTTP CONNECT proxy for fleet TCP reachability checks (socks5://host:1080, http://host:3128)")
+	watchCmd.Flags().StringArray("targets-from", nil, "Also watch the targets a service discovery source lists (prometheus-http-sd:<URL>, consul:service=<name>) or a targets file (file:<PATH>); repeatable")
+	units.Duration(watchCmd.Flags(), "targets-refresh", time.Minute, "How often to ask --targets-from sources for the current targets")
+	watchCmd.Flags().Int("concurrency", defaultFleetConcurrency, "In fleet mode, how many probes may be in flight at once; they share the --pps budget")
+	watchCmd.Flags().Bool("events", false, "In fleet mode, print one record per target change instead of a summary per cycle (needs --format json or yaml)")
+	watchCmd.Flags().Bool("verbose", false, "In fleet mode, print the probe schedule and when each probe fires to stderr")
+	watchCmd.Flags().String("export", "", "Write every sample to a time-series file as watch runs (csv:<PATH> or parquet:<PATH>)")
+	watchCmd.Flags().String("listen", "", "Serve Prometheus metrics at http://<ADDR>/metrics, such as :9123, and keep running after a PMTU drop")
+	watchCmd.Flags().String("webhook", "", "POST a JSON alert to this URL when the PMTU or MSS changes, or a fleet target changes health class")
+	watchCmd.Flags().Bool("syslog", false, "Also log alerts to the local syslog")
+}
+
+// readProxyDialer builds the dialer for TCP reachability checks from --proxy
+func readProxyDialer(cmd *cobra.Command, timeout time.Duration) (*proxy.Dialer, error) {
+	dialer := &proxy.Dialer{Timeout: timeout}
+	raw, _ := cmd.Flags().GetString("proxy")
+	if raw == "" {
+		return dialer, nil
+	}
+	u, err := proxy.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	dialer.URL = u
+	return dialer, nil
 }
 
 func runWatch(cmd *cobra.Command, args []string) error {
-	opts, err := readDiscoveryOptions(cmd, args[0])
+	fleet, err := readFleetTargets(cmd, args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 && fleet == nil {
+		return errcode.Errorf(errcode.CLIUsage, "mtu watch needs at least one destination or --targets-from")
+	}
+
+	var destination string
+	if len(args) > 0 {
+		destination = args[0]
+	}
+	opts, err := readDiscoveryOptions(cmd, destination)
 	if err != nil {
 		return err
 	}
 	if opts.HopsMode {
-		return fmt.Errorf("--hops is only supported by mtu discover")
+		return errcode.Errorf(errcode.CLIUsage, "--hops is only supported by mtu discover")
+	}
+	if opts.Protocol == protocolAll {
+		return errcode.Errorf(errcode.CLIUsage, "--proto all is only supported by mtu discover")
 	}
 
 	interval, _ := cmd.Flags().GetDuration("interval")
 	mssOnly, _ := cmd.Flags().GetBool("mss-only")
-	jsonOutput, _ := cmd.Flags().GetBool("json")
+	format, err := readOutputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	report, err := readHTMLReport(cmd, args)
+	if err != nil {
+		return err
+	}
+	exp, err := readWatchExport(cmd)
+	if err != nil {
+		return err
+	}
+	metrics, err := readWatchMetrics(cmd)
+	if err != nil {
+		return err
+	}
+	limits, err := readWatchLimits(cmd)
+	if err != nil {
+		return err
+	}
+	notifiers, closeNotifiers, err := notify.OpenFlags(cmd.Flags())
+	if err != nil {
+		return err
+	}
+	defer closeNotifiers()
+
+	if concurrency, _ := cmd.Flags().GetInt("concurrency"); concurrency < 1 && cmd.Flags().Changed("concurrency") {
+		return errcode.Errorf(errcode.CLIUsage, "--concurrency must be at least 1")
+	}
+	if events, _ := cmd.Flags().GetBool("events"); events && !format.structured() {
+		return errcode.Errorf(errcode.CLIUsage, "--events prints records; use it with --format json|yaml")
+	}
 
-	if !jsonOutput {
+	if len(args) > 1 || fleet != nil {
+		if fleet == nil {
+			fleet = &fleetTargets{static: args}
+		}
+		dialer, err := readProxyDialer(cmd, opts.Timeout)
+		if err != nil {
+			return err
+		}
+		return runFleetWatch(cmd, opts, fleet, interval, limits, dialer, report, exp, metrics, notifiers, format)
+	}
+	if proxyURL, _ := cmd.Flags().GetString("proxy"); proxyURL != "" {
+		return errcode.Errorf(errcode.CLIUsage, "--proxy only applies to the TCP reachability check in fleet mode (more than one destination)")
+	}
+	if events, _ := cmd.Flags().GetBool("events"); events {
+		return errcode.Errorf(errcode.CLIUsage, "--events only applies in fleet mode (more than one destination); a single-target watch already prints every sample")
+	}
+
+	plan := newDryRunPlan(opts)
+	plan.IntervalMS = interval.Milliseconds()
+	if opts.DryRun {
+		return outputDryRun(plan, format)
+	}
+	if err := checkProbeTargets(cmd, plan); err != nil {
+		return err
+	}
+	if err := recordProbeAudit(cmd, plan); err != nil {
+		return err
+	}
+	if err := exp.open(); err != nil {
+		return err
+	}
+	defer func() { _ = exp.Close() }()
+	if err := metrics.open(); err != nil {
+		return err
+	}
+	defer func() { _ = metrics.Close() }()
+
+	if !format.structured() {
 		fmt.Printf("Watching MTU to %s every %v...\n", opts.Destination, interval)
 		if mssOnly {
 			fmt.Printf("Will only alert on MSS changes\n")
@@ -48,22 +223,41 @@ func runWatch(cmd *cobra.Command, args []string) error {
 	}
 
 	var lastResult *MTUResult
+	summary := newWatchSummary(time.Now())
+
+	watchCtx, stop := watchContext(limits)
+	defer stop()
 
 	for {
 		// Perform MTU discovery
-		ctx, cancel := newDiscoveryContext(opts)
+		ctx, cancel := newCycleContext(watchCtx, opts)
 		result, err := performMTUDiscovery(ctx, opts)
 		cancel()
+		if watchCtx.Err() != nil {
+			// Stopped mid-probe; the partial result is not a sample
+			return endWatch(summary, watchStopReason(watchCtx), format, report, exp)
+		}
+		err = withDiscoveryErrorCode(err, opts.Protocol)
 
 		timestamp := time.Now()
+		sample := newWatchSample(timestamp, result, err)
+		summary.Add(opts.Destination, sample)
+		report.Add(opts.Destination, sample)
+		metrics.Add(opts.Destination, sample)
+		if exportErr := exp.Add(opts.Destination, sample); exportErr != nil {
+			return exportErr
+		}
+		if reportErr := report.MaybeWrite(timestamp); reportErr != nil {
+			return reportErr
+		}
 
 		if err != nil {
-			if jsonOutput {
-				if jsonErr := outputWatchErrorJSON(timestamp, opts.Destination, err); jsonErr != nil {
-					return jsonErr
+			if format.structured() {
+				if outputErr := outputWatchError(timestamp, opts.Destination, err, format); outputErr != nil {
+					return outputErr
 				}
 			} else {
-				fmt.Printf("[%s] Error: %v\n", timestamp.Format("15:04:05"), err)
+				fmt.Printf("[%s] Error [%s]: %v\n", timestamp.Format("15:04:05"), errcode.Of(err), err)
 			}
 		} else {
 			// Check for changes
@@ -71,9 +265,9 @@ func runWatch(cmd *cobra.Command, args []string) error {
 			mssChanged := lastResult == nil || result.MSS != lastResult.MSS
 
 			// Output based on mode
-			if jsonOutput {
-				if jsonErr := outputWatchResultJSON(timestamp, result, changed, mssChanged); jsonErr != nil {
-					return jsonErr
+			if format.structured() {
+				if outputErr := outputWatchResult(timestamp, result, changed, mssChanged, format); outputErr != nil {
+					return outputErr
 				}
 			} else {
 				symbol := " "
@@ -97,9 +291,15 @@ func runWatch(cmd *cobra.Command, args []string) error {
 				if mssOnly && !mssChanged {
 					// Skip alert if only monitoring MSS changes
 				} else {
-					// Non-zero exit if PMTU drops as specified in requirements
-					if result.PMTU < lastResult.PMTU {
-						return newWatchDropError(cmd, lastResult.PMTU, result.PMTU, jsonOutput)
+					notifyWatch(watchCtx, cmd, notifiers, newWatchChangeEvent(timestamp, lastResult, result))
+					// Non-zero exit if PMTU drops as specified in requirements,
+					// unless the drop is there to be scraped
+					if result.PMTU < lastResult.PMTU && metrics == nil {
+						summary.cycles++
+						if endErr := endWatch(summary, watchStoppedDrop, format, report, exp); endErr != nil {
+							return endErr
+						}
+						return newWatchDropError(cmd, lastResult.PMTU, result.PMTU, format)
 					}
 				}
 			}
@@ -107,32 +307,90 @@ func runWatch(cmd *cobra.Command, args []string) error {
 			lastResult = result
 		}
 
-		time.Sleep(interval)
+		summary.cycles++
+		if limits.reached(summary.cycles) {
+			return endWatch(summary, watchStoppedCount, format, report, exp)
+		}
+		if !sleepInterval(watchCtx, interval) {
+			return endWatch(summary, watchStopReason(watchCtx), format, report, exp)
+		}
+	}
+}
+
+// readFleetTargets returns the --targets-from sources merged with the
+// command-line destinations, or nil when no source was given
+func readFleetTargets(cmd *cobra.Command, args []string) (*fleetTargets, error) {
+	specs, _ := cmd.Flags().GetStringArray("targets-from")
+	refresh, _ := cmd.Flags().GetDuration("targets-refresh")
+	if len(specs) == 0 {
+		if cmd.Flags().Changed("targets-refresh") {
+			return nil, errcode.Errorf(errcode.CLIUsage, "--targets-refresh requires --targets-from")
+		}
+		return nil, nil
+	}
+	if refresh <= 0 {
+		return nil, errcode.Errorf(errcode.CLIUsage, "--targets-refresh must be positive")
+	}
+
+	fleet := &fleetTargets{static: args, refresh: refresh}
+	for _, spec := range specs {
+		source, err := targets.Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		fleet.sources = append(fleet.sources, source)
+	}
+	return fleet, nil
+}
+
+// readHTMLReport returns the --html-report accumulator, or nil when no report was requested
+func readHTMLReport(cmd *cobra.Command, targets []string) (*htmlReport, error) {
+	path, _ := cmd.Flags().GetString("html-report")
+	every, _ := cmd.Flags().GetDuration("html-every")
+	if every < 0 {
+		return nil, errcode.Errorf(errcode.CLIUsage, "--html-every must be non-negative")
+	}
+	if path == "" {
+		if every > 0 {
+			return nil, errcode.Errorf(errcode.CLIUsage, "--html-every requires --html-report")
+		}
+		return nil, nil
+	}
+	return newHTMLReport(path, every, targets), nil
+}
+
+// newWatchSample converts one single-target watch cycle into a report sample
+func newWatchSample(timestamp time.Time, result *MTUResult, err error) watchSample {
+	if err != nil {
+		return watchSample{Time: timestamp, Status: "error", Error: err.Error(), Code: errcode.Of(err)}
 	}
+	return watchSample{Time: timestamp, Status: "ok", PMTU: result.PMTU, MSS: result.MSS, RTTMS: result.RTTMS}
 }
 
-func newWatchDropError(cmd *cobra.Command, previousPMTU, currentPMTU int, jsonOutput bool) error {
+func newWatchDropError(cmd *cobra.Command, previousPMTU, currentPMTU int, format outputFormat) error {
 	cmd.SilenceUsage = true
-	if jsonOutput {
+	if format.structured() {
 		cmd.SilenceErrors = true
 	}
-	return fmt.Errorf("pmtu dropped from %d to %d", previousPMTU, currentPMTU)
+	return errcode.Errorf(errcode.MTUPMTUDropped, "pmtu dropped from %d to %d", previousPMTU, currentPMTU)
 }
 
-func outputWatchErrorJSON(timestamp time.Time, destination string, err error) error {
-	return writeJSONLine(struct {
+func outputWatchError(timestamp time.Time, destination string, err error, format outputFormat) error {
+	return format.writeRecord(struct {
 		Timestamp string `json:"timestamp"`
 		Target    string `json:"target"`
+		Code      string `json:"code"`
 		Error     string `json:"error"`
 	}{
 		Timestamp: timestamp.Format(time.RFC3339),
 		Target:    destination,
+		Code:      string(errcode.Of(err)),
 		Error:     err.Error(),
 	})
 }
 
-func outputWatchResultJSON(timestamp time.Time, result *MTUResult, changed, mssChanged bool) error {
-	return writeJSONLine(struct {
+func outputWatchResult(timestamp time.Time, result *MTUResult, changed, mssChanged bool, format outputFormat) error {
+	return format.writeRecord(struct {
 		Timestamp  string `json:"timestamp"`
 		Target     string `json:"target"`
 		PMTU       int    `json:"pmtu"`
@@ -0,0 +1,93 @@
+package mtu
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/notify"
+	"github.com/spf13/cobra"
+)
+
+// watchAlertSource names mtu watch as the source of its alerts
+const watchAlertSource = "mtu watch"
+
+// watchChangeAlert is the detail of a single-target PMTU or MSS change alert
+type watchChangeAlert struct {
+	Protocol     string `json:"protocol"`
+	PreviousPMTU int    `json:"previous_pmtu"`
+	PMTU         int    `json:"pmtu"`
+	PreviousMSS  int    `json:"previous_mss"`
+	MSS          int    `json:"mss"`
+	Dropped      bool   `json:"dropped"` // A drop also ends the watch
+}
+
+// fleetTransitionAlert is the detail of a fleet target changing health class
+type fleetTransitionAlert struct {
+	From   string             `json:"from"`
+	To     string             `json:"to"`
+	Status *fleetTargetStatus `json:"status"`
+}
+
+// newWatchChangeEvent describes a change between two single-target results
+func newWatchChangeEvent(timestamp time.Time, previous, current *MTUResult) notify.Event {
+	var summary string
+	switch {
+	case current.PMTU < previous.PMTU:
+		summary = fmt.Sprintf("PMTU dropped from %d to %d", previous.PMTU, current.PMTU)
+	case current.PMTU > previous.PMTU:
+		summary = fmt.Sprintf("PMTU rose from %d to %d", previous.PMTU, current.PMTU)
+	}
+	if current.MSS != previous.MSS {
+		if summary != "" {
+			summary += ", "
+		}
+		summary += fmt.Sprintf("MSS %d to %d", previous.MSS, current.MSS)
+	}
+
+	return notify.Event{
+		Time:    timestamp,
+		Source:  watchAlertSource,
+		Target:  current.Target,
+		Summary: summary,
+		Details: watchChangeAlert{
+			Protocol:     current.Protocol,
+			PreviousPMTU: previous.PMTU,
+			PMTU:         current.PMTU,
+			PreviousMSS:  previous.MSS,
+			MSS:          current.MSS,
+			Dropped:      current.PMTU < previous.PMTU,
+		},
+	}
+}
+
+// newFleetTransitionEvent describes a fleet target moving to another health class
+func newFleetTransitionEvent(timestamp time.Time, transition fleetTransition, status *fleetTargetStatus) notify.Event {
+	summary := fmt.Sprintf("%s -> %s", transition.From, transition.To)
+	switch {
+	case status.Health == healthDegraded:
+		summary += fmt.Sprintf(": PMTU %d (best %d)", status.PMTU, status.BestPMTU)
+	case status.Error != "":
+		summary += fmt.Sprintf(": [%s] %s", status.ErrorCode, status.Error)
+	case status.PMTU > 0:
+		summary += fmt.Sprintf(": PMTU %d", status.PMTU)
+	}
+
+	return notify.Event{
+		Time:    timestamp,
+		Source:  watchAlertSource,
+		Target:  transition.Target,
+		Summary: summary,
+		Details: fleetTransitionAlert{From: transition.From, To: transition.To, Status: status},
+	}
+}
+
+// notifyWatch delivers alerts, warning about failed deliveries on stderr; a
+// flaky webhook should not end the watch
+func notifyWatch(ctx context.Context, cmd *cobra.Command, notifiers notify.Notifiers, events ...notify.Event) {
+	for _, event := range events {
+		if err := notifiers.Notify(ctx, event); err != nil {
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Warning: alert delivery failed: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package mtu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/notify"
+	"github.com/spf13/cobra"
+)
+
+func TestNewWatchChangeEvent(t *testing.T) {
+	timestamp := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		previous, current MTUResult
+		want              string
+	}{
+		{MTUResult{PMTU: 1500, MSS: 1460}, MTUResult{PMTU: 1400, MSS: 1360}, "PMTU dropped from 1500 to 1400, MSS 1460 to 1360"},
+		{MTUResult{PMTU: 1400, MSS: 1360}, MTUResult{PMTU: 1500, MSS: 1360}, "PMTU rose from 1400 to 1500"},
+		{MTUResult{PMTU: 1500, MSS: 1460}, MTUResult{PMTU: 1500, MSS: 1440}, "MSS 1460 to 1440"},
+	}
+	for _, tt := range tests {
+		tt.current.Target = "vpn.example.com"
+		event := newWatchChangeEvent(timestamp, &tt.previous, &tt.current)
+		if event.Summary != tt.want || event.Source != "mtu watch" || event.Target != "vpn.example.com" || !event.Time.Equal(timestamp) {
+			t.Errorf("newWatchChangeEvent = %+v, want summary %q", event, tt.want)
+		}
+		details := event.Details.(watchChangeAlert)
+		if details.Dropped != (tt.current.PMTU < tt.previous.PMTU) || details.PreviousPMTU != tt.previous.PMTU {
+			t.Errorf("unexpected details %+v", details)
+		}
+	}
+}
+
+func TestNewFleetTransitionEvent(t *testing.T) {
+	tests := []struct {
+		status fleetTargetStatus
+		want   string
+	}{
+		{fleetTargetStatus{Health: healthDegraded, PMTU: 1400, BestPMTU: 1500}, "healthy -> degraded: PMTU 1400 (best 1500)"},
+		{fleetTargetStatus{Health: healthDown, ErrorCode: errcode.MTUTimeout, Error: "probe timed out"}, "healthy -> down: [MTU011] probe timed out"},
+		{fleetTargetStatus{Health: healthHealthy, PMTU: 1500}, "healthy -> healthy: PMTU 1500"},
+	}
+	for _, tt := range tests {
+		transition := fleetTransition{Target: "192.0.2.1", From: healthHealthy, To: tt.status.Health}
+		if event := newFleetTransitionEvent(time.Now(), transition, &tt.status); event.Summary != tt.want || event.Target != "192.0.2.1" {
+			t.Errorf("newFleetTransitionEvent = %+v, want summary %q", event, tt.want)
+		}
+	}
+}
+
+func TestNotifyWatchPostsAndWarns(t *testing.T) {
+	var received []notify.Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event notify.Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("invalid webhook body: %v", err)
+		}
+		received = append(received, event)
+		if strings.Contains(event.Summary, "dropped") {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	cmd := &cobra.Command{Use: "watch"}
+	cmd.Flags().String("webhook", "", "")
+	cmd.Flags().Bool("syslog", false, "")
+	mustSetFlag(t, cmd, "webhook", server.URL)
+	var stderr bytes.Buffer
+	cmd.SetErr(&stderr)
+
+	notifiers, closeNotifiers, err := notify.OpenFlags(cmd.Flags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeNotifiers()
+
+	previous, current := &MTUResult{Target: "a", PMTU: 1400}, &MTUResult{Target: "a", PMTU: 1500}
+	notifyWatch(context.Background(), cmd, notifiers,
+		newWatchChangeEvent(time.Now(), previous, current),
+		newWatchChangeEvent(time.Now(), current, previous))
+	if len(received) != 2 || received[0].Summary != "PMTU rose from 1400 to 1500" {
+		t.Fatalf("webhook received %+v, want both alerts", received)
+	}
+	if !strings.Contains(stderr.String(), "Warning: alert delivery failed") || !strings.Contains(stderr.String(), "400") {
+		t.Errorf("expected a warning for the rejected alert, got %q", stderr.String())
+	}
+}
+
+func TestRunWatchRejectsBadWebhook(t *testing.T) {
+	cmd := newDiscoveryOptionsCommand()
+	cmd.Flags().Duration("interval", 10*time.Second, "")
+	cmd.Flags().Bool("dry-run", false, "")
+	cmd.Flags().String("webhook", "", "")
+	mustSetFlag(t, cmd, "dry-run", "true")
+	mustSetFlag(t, cmd, "webhook", "ftp://hooks.example.com")
+
+	if err := runWatch(cmd, []string{"192.0.2.1"}); errcode.Of(err) != errcode.CLIUsage {
+		t.Fatalf("runWatch with an ftp webhook = %v, want CLI002", err)
+	}
+}
@@ -136,8 +136,13 @@ func TestRunWatchDetectsPMTUDropInJSONMode(t *testing.T) {
 	}
 
 	lines := strings.Split(strings.TrimSpace(output), "\n")
-	if len(lines) < 2 {
-		t.Fatalf("expected at least two JSON watch records, got %q", output)
+	if len(lines) < 3 {
+		t.Fatalf("expected at least two JSON watch records and a summary, got %q", output)
+	}
+
+	var summary watchSummaryRecord
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &summary); err != nil || summary.Summary.Stopped != watchStoppedDrop {
+		t.Fatalf("expected a pmtu-drop summary last, got %q (%v)", lines[len(lines)-1], err)
 	}
 
 	var finalRecord struct {
@@ -145,7 +150,7 @@ func TestRunWatchDetectsPMTUDropInJSONMode(t *testing.T) {
 		PMTU    int    `json:"pmtu"`
 		Changed bool   `json:"changed"`
 	}
-	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &finalRecord); err != nil {
+	if err := json.Unmarshal([]byte(lines[len(lines)-2]), &finalRecord); err != nil {
 		t.Fatalf("failed to parse final watch JSON record: %v", err)
 	}
 	if finalRecord.Target != "127.0.0.1" || finalRecord.PMTU != currentPMTU || !finalRecord.Changed {
@@ -154,6 +159,9 @@ func TestRunWatchDetectsPMTUDropInJSONMode(t *testing.T) {
 }
 
 func TestRunWatchRejectsHopMode(t *testing.T) {
+	if !rawICMPSupported {
+		t.Skip("minimal builds reject --hops before the commands check it")
+	}
 	cmd := newDiscoveryOptionsCommand()
 	cmd.Flags().Duration("interval", 10*time.Second, "")
 	cmd.Flags().Bool("mss-only", false, "")
@@ -0,0 +1,91 @@
+package mtu
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/euan-cowie/cidrator/internal/export"
+	"github.com/spf13/cobra"
+)
+
+// watchExportColumns are the columns of an --export file, one row per target
+// per cycle. pmtu and rtt_ms are empty when discovery failed, error when it
+// did not.
+var watchExportColumns = []export.Column{
+	{Name: "time", Type: export.Timestamp},
+	{Name: "target", Type: export.String},
+	{Name: "status", Type: export.String},
+	{Name: "pmtu", Type: export.Int64},
+	{Name: "rtt_ms", Type: export.Float64},
+	{Name: "error", Type: export.String},
+}
+
+// watchExport writes every watch sample to the --export file as it is taken.
+// A nil export records nothing.
+type watchExport struct {
+	spec   string
+	writer export.Writer
+}
+
+// readWatchExport checks --export, returning nil when no export was
+// requested. The file is only created by open, so dry runs leave no trace.
+func readWatchExport(cmd *cobra.Command) (*watchExport, error) {
+	spec, _ := cmd.Flags().GetString("export")
+	if spec == "" {
+		return nil, nil
+	}
+	if _, _, err := export.Parse(spec); err != nil {
+		return nil, err
+	}
+	return &watchExport{spec: spec}, nil
+}
+
+// open creates the export file
+func (e *watchExport) open() error {
+	if e == nil {
+		return nil
+	}
+	writer, err := export.Create(e.spec, watchExportColumns)
+	if err != nil {
+		return err
+	}
+	e.writer = writer
+	return nil
+}
+
+// Add writes one sample for target
+func (e *watchExport) Add(target string, sample watchSample) error {
+	if e == nil || e.writer == nil {
+		return nil
+	}
+	var pmtu, rtt, errMsg any
+	if sample.PMTU > 0 {
+		pmtu, rtt = sample.PMTU, sample.RTTMS
+	}
+	if sample.Error != "" {
+		errMsg = sample.Error
+	}
+	return e.writer.Write(sample.Time, target, sample.Status, pmtu, rtt, errMsg)
+}
+
+// Close finishes the file and tells the user where it is. Closing twice is
+// harmless, so error paths can close it too.
+func (e *watchExport) Close() error {
+	if e == nil || e.writer == nil {
+		return nil
+	}
+	err := e.writer.Close()
+	e.writer = nil
+	if err != nil {
+		return err
+	}
+	_, path, _ := export.Parse(e.spec)
+	fmt.Fprintf(os.Stderr, "Wrote session export to %s\n", path)
+	return nil
+}
+
+// finishWatch writes the final HTML report and closes the export
+func finishWatch(report *htmlReport, exp *watchExport) error {
+	return errors.Join(report.Finish(), exp.Close())
+}
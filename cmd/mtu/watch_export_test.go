@@ -0,0 +1,75 @@
+package mtu
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+func TestReadWatchExport(t *testing.T) {
+	newCmd := func(t *testing.T, spec string) (*watchExport, error) {
+		cmd := newDiscoveryOptionsCommand()
+		cmd.Flags().String("export", "", "")
+		if spec != "" {
+			mustSetFlag(t, cmd, "export", spec)
+		}
+		return readWatchExport(cmd)
+	}
+
+	if exp, err := newCmd(t, ""); exp != nil || err != nil {
+		t.Fatalf("expected no export without --export, got %v, %v", exp, err)
+	}
+	path := filepath.Join(t.TempDir(), "soak.parquet")
+	exp, err := newCmd(t, "parquet:"+path)
+	if err != nil || exp == nil {
+		t.Fatalf("unexpected export: %+v, %v", exp, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("the file should not exist before open, got %v", err)
+	}
+	if _, err := newCmd(t, "soak.json"); errcode.Of(err) != errcode.CLIUsage {
+		t.Fatalf("expected an unknown format to be rejected, got %v", err)
+	}
+}
+
+func TestWatchExportWritesSamples(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "soak.csv")
+	exp := &watchExport{spec: path}
+	if err := exp.open(); err != nil {
+		t.Fatalf("open returned error: %v", err)
+	}
+
+	at := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	if err := exp.Add("192.0.2.1", watchSample{Time: at, Status: "ok", PMTU: 1500, RTTMS: 11.25}); err != nil {
+		t.Fatalf("Add returned error: %v", err)
+	}
+	if err := exp.Add("192.0.2.1", watchSample{Time: at.Add(10 * time.Second), Status: "error", Error: "timed out"}); err != nil {
+		t.Fatalf("Add returned error: %v", err)
+	}
+	if err := finishWatch(nil, exp); err != nil {
+		t.Fatalf("finishWatch returned error: %v", err)
+	}
+	if err := exp.Close(); err != nil {
+		t.Fatalf("second Close returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "time,target,status,pmtu,rtt_ms,error\n" +
+		"2026-10-18T09:30:00.000Z,192.0.2.1,ok,1500,11.25,\n" +
+		"2026-10-18T09:30:10.000Z,192.0.2.1,error,,,timed out\n"
+	if string(data) != want {
+		t.Fatalf("export = %q, want %q", data, want)
+	}
+
+	// A nil export records nothing
+	var none *watchExport
+	if err := none.Add("192.0.2.1", watchSample{}); err != nil || none.open() != nil || none.Close() != nil {
+		t.Fatal("nil export should be a no-op")
+	}
+}
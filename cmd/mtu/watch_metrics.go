@@ -0,0 +1,179 @@
+package mtu
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+)
+
+// rttBuckets are the upper bounds, in seconds, of the probe RTT histogram:
+// the Prometheus client defaults, which span LAN to intercontinental paths
+var rttBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// targetMetrics is what the exporter knows about one target
+type targetMetrics struct {
+	pmtu, mss int // Latest successful values
+	probes    int
+	failures  map[errcode.Code]int
+	rttCounts []int // Cumulative per bucket of rttBuckets
+	rttCount  int
+	rttSum    float64
+}
+
+// watchMetrics serves the watch samples as Prometheus metrics on --listen.
+// A nil exporter records nothing.
+type watchMetrics struct {
+	addr     string
+	listener net.Listener
+	server   *http.Server
+
+	mu      sync.Mutex
+	targets map[string]*targetMetrics
+}
+
+// readWatchMetrics checks --listen, returning nil when no exporter was
+// requested. Nothing listens until open, so dry runs bind no port.
+func readWatchMetrics(cmd *cobra.Command) (*watchMetrics, error) {
+	addr, _ := cmd.Flags().GetString("listen")
+	if addr == "" {
+		return nil, nil
+	}
+	if _, port, err := net.SplitHostPort(addr); err != nil || port == "" {
+		return nil, errcode.Errorf(errcode.CLIUsage, "invalid --listen address %q: expected [host]:port, such as :9123", addr)
+	}
+	return &watchMetrics{addr: addr, targets: make(map[string]*targetMetrics)}, nil
+}
+
+// open starts serving /metrics
+func (m *watchMetrics) open() error {
+	if m == nil {
+		return nil
+	}
+	listener, err := net.Listen("tcp", m.addr)
+	if err != nil {
+		return fmt.Errorf("failed to start metrics listener: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.write(w)
+	})
+	m.listener = listener
+	m.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := m.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "Warning: metrics listener stopped: %v\n", err)
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "Serving metrics at http://%s/metrics\n", listener.Addr())
+	return nil
+}
+
+// Add records one sample for target
+func (m *watchMetrics) Add(target string, sample watchSample) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.targets[target]
+	if t == nil {
+		t = &targetMetrics{failures: make(map[errcode.Code]int), rttCounts: make([]int, len(rttBuckets))}
+		m.targets[target] = t
+	}
+	t.probes++
+	if sample.Error != "" {
+		t.failures[cmp.Or(sample.Code, errcode.Unknown)]++
+		return
+	}
+	t.pmtu, t.mss = sample.PMTU, sample.MSS
+	rtt := sample.RTTMS / 1000
+	for i, bound := range rttBuckets {
+		if rtt <= bound {
+			t.rttCounts[i]++
+		}
+	}
+	t.rttCount++
+	t.rttSum += rtt
+}
+
+// Close stops serving. Closing twice is harmless.
+func (m *watchMetrics) Close() error {
+	if m == nil || m.server == nil {
+		return nil
+	}
+	err := m.server.Close()
+	m.server = nil
+	return err
+}
+
+// write prints the metrics in the Prometheus text exposition format
+func (m *watchMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := slices.Sorted(maps.Keys(m.targets))
+
+	family := func(name, kind, help string, each func(target string, t *targetMetrics)) {
+		_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, target := range names {
+			each(target, m.targets[target])
+		}
+	}
+	sample := func(name string, value float64, labels ...string) {
+		_, _ = fmt.Fprintf(w, "%s%s %s\n", name, metricLabels(labels...), strconv.FormatFloat(value, 'g', -1, 64))
+	}
+
+	family("cidrator_pmtu_bytes", "gauge", "Latest discovered Path MTU", func(target string, t *targetMetrics) {
+		if t.pmtu > 0 {
+			sample("cidrator_pmtu_bytes", float64(t.pmtu), "target", target)
+		}
+	})
+	family("cidrator_mss_bytes", "gauge", "TCP MSS for the latest discovered Path MTU", func(target string, t *targetMetrics) {
+		if t.mss > 0 {
+			sample("cidrator_mss_bytes", float64(t.mss), "target", target)
+		}
+	})
+	family("cidrator_probe_rtt_seconds", "histogram", "Round trip of the probe at the discovered Path MTU", func(target string, t *targetMetrics) {
+		for i, bound := range rttBuckets {
+			sample("cidrator_probe_rtt_seconds_bucket", float64(t.rttCounts[i]), "target", target, "le", strconv.FormatFloat(bound, 'g', -1, 64))
+		}
+		sample("cidrator_probe_rtt_seconds_bucket", float64(t.rttCount), "target", target, "le", "+Inf")
+		sample("cidrator_probe_rtt_seconds_sum", t.rttSum, "target", target)
+		sample("cidrator_probe_rtt_seconds_count", float64(t.rttCount), "target", target)
+	})
+	family("cidrator_probes_total", "counter", "Discovery attempts", func(target string, t *targetMetrics) {
+		sample("cidrator_probes_total", float64(t.probes), "target", target)
+	})
+	family("cidrator_probe_failures_total", "counter", "Failed discovery attempts by error code", func(target string, t *targetMetrics) {
+		for _, code := range slices.Sorted(maps.Keys(t.failures)) {
+			sample("cidrator_probe_failures_total", float64(t.failures[code]), "target", target, "code", string(code))
+		}
+	})
+}
+
+// metricLabels formats name/value pairs as a label set, escaping values
+func metricLabels(pairs ...string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, pairs[i], escaper.Replace(pairs[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
@@ -0,0 +1,100 @@
+package mtu
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+)
+
+func TestReadWatchMetrics(t *testing.T) {
+	for _, tt := range []struct {
+		listen string
+		want   bool
+		code   errcode.Code
+	}{
+		{"", false, ""},
+		{":9123", true, ""},
+		{"127.0.0.1:0", true, ""},
+		{"9123", false, errcode.CLIUsage},
+		{"localhost:", false, errcode.CLIUsage},
+	} {
+		cmd := &cobra.Command{Use: "watch"}
+		cmd.Flags().String("listen", "", "")
+		mustSetFlag(t, cmd, "listen", tt.listen)
+		metrics, err := readWatchMetrics(cmd)
+		if (metrics != nil) != tt.want || (err == nil) != (tt.code == "") || (err != nil && errcode.Of(err) != tt.code) {
+			t.Errorf("readWatchMetrics(%q) = %v, %v", tt.listen, metrics, err)
+		}
+	}
+}
+
+func TestWatchMetricsExposition(t *testing.T) {
+	metrics := &watchMetrics{targets: make(map[string]*targetMetrics)}
+	now := time.Now()
+	metrics.Add("b.example", watchSample{Time: now, PMTU: 1500, MSS: 1460, RTTMS: 12})
+	metrics.Add("b.example", watchSample{Time: now, PMTU: 1400, MSS: 1360, RTTMS: 300})
+	metrics.Add("b.example", watchSample{Time: now, Error: "probe timed out", Code: errcode.MTUTimeout})
+	metrics.Add(`a"odd`, watchSample{Time: now, Error: "no route"})
+
+	var out strings.Builder
+	metrics.write(&out)
+	got := out.String()
+
+	for _, want := range []string{
+		"# TYPE cidrator_pmtu_bytes gauge\ncidrator_pmtu_bytes{target=\"b.example\"} 1400\n# HELP cidrator_mss_bytes",
+		`cidrator_mss_bytes{target="b.example"} 1360`,
+		`cidrator_probe_rtt_seconds_bucket{target="b.example",le="0.01"} 0`,
+		`cidrator_probe_rtt_seconds_bucket{target="b.example",le="0.025"} 1`,
+		`cidrator_probe_rtt_seconds_bucket{target="b.example",le="0.5"} 2`,
+		`cidrator_probe_rtt_seconds_bucket{target="b.example",le="+Inf"} 2`,
+		`cidrator_probe_rtt_seconds_sum{target="b.example"} 0.312`,
+		`cidrator_probe_rtt_seconds_count{target="a\"odd"} 0`,
+		`cidrator_probes_total{target="b.example"} 3`,
+		`cidrator_probe_failures_total{target="a\"odd",code="ERR000"} 1`,
+		`cidrator_probe_failures_total{target="b.example",code="MTU011"} 1`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `cidrator_pmtu_bytes{target="a\"odd"}`) {
+		t.Errorf("a target that never succeeded should have no PMTU gauge:\n%s", got)
+	}
+}
+
+func TestWatchMetricsServe(t *testing.T) {
+	metrics := &watchMetrics{addr: "127.0.0.1:0", targets: make(map[string]*targetMetrics)}
+	if err := metrics.open(); err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	defer func() { _ = metrics.Close() }()
+	metrics.Add("192.0.2.1", watchSample{PMTU: 1500, MSS: 1460, RTTMS: 1})
+
+	resp, err := http.Get("http://" + metrics.listener.Addr().String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain; version=0.0.4") ||
+		!strings.Contains(string(body), `cidrator_pmtu_bytes{target="192.0.2.1"} 1500`) {
+		t.Fatalf("GET /metrics = %s %q", resp.Status, body)
+	}
+
+	if err := metrics.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := metrics.Close(); err != nil {
+		t.Fatalf("second Close = %v", err)
+	}
+	var nilMetrics *watchMetrics
+	nilMetrics.Add("x", watchSample{})
+	if err := nilMetrics.open(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,213 @@
+package mtu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/cobra"
+)
+
+// Why a watch stopped, as reported in its summary
+const (
+	watchStoppedCount    = "count"
+	watchStoppedDuration = "duration"
+	watchStoppedSignal   = "signal"
+	watchStoppedDrop     = "pmtu-drop"
+)
+
+// watchStopText describes each stop reason in the table summary
+var watchStopText = map[string]string{
+	watchStoppedCount:    "--count reached",
+	watchStoppedDuration: "--for elapsed",
+	watchStoppedSignal:   "interrupted",
+	watchStoppedDrop:     "PMTU drop",
+}
+
+// watchLimits ends a watch after --count cycles or once --for has passed;
+// zero values leave it running until Ctrl+C
+type watchLimits struct {
+	count    int
+	duration time.Duration
+}
+
+// readWatchLimits checks --count and --for
+func readWatchLimits(cmd *cobra.Command) (watchLimits, error) {
+	count, _ := cmd.Flags().GetInt("count")
+	duration, _ := cmd.Flags().GetDuration("for")
+	if count < 0 {
+		return watchLimits{}, errcode.Errorf(errcode.CLIUsage, "--count must be non-negative")
+	}
+	if duration < 0 {
+		return watchLimits{}, errcode.Errorf(errcode.CLIUsage, "--for must be non-negative")
+	}
+	return watchLimits{count: count, duration: duration}, nil
+}
+
+// reached reports whether cycles completed is the --count limit
+func (l watchLimits) reached(cycles int) bool {
+	return l.count > 0 && cycles >= l.count
+}
+
+// watchContext ends on Ctrl+C or SIGTERM, so the watch can finish its report,
+// export, and summary before exiting, or once the --for limit has passed
+func watchContext(limits watchLimits) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if limits.duration <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, limits.duration)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// watchStopReason tells a --for limit apart from a signal once ctx has ended
+func watchStopReason(ctx context.Context) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return watchStoppedDuration
+	}
+	return watchStoppedSignal
+}
+
+// endWatch finishes the report and export and prints the summary
+func endWatch(summary *watchSummary, stopped string, format outputFormat, report *htmlReport, exp *watchExport) error {
+	return errors.Join(finishWatch(report, exp), summary.write(os.Stdout, stopped, format))
+}
+
+// targetSummary is what the summary tracks for one target
+type targetSummary struct {
+	samples, failures int
+	pmtus             []int // Successful samples only
+}
+
+// watchChange is a PMTU change seen between two successful samples
+type watchChange struct {
+	Time   time.Time `json:"time"`
+	Target string    `json:"target"`
+	From   int       `json:"from_pmtu"`
+	To     int       `json:"to_pmtu"`
+}
+
+// watchSummary accumulates the samples of a watch for the report printed on exit
+type watchSummary struct {
+	started time.Time
+	cycles  int
+	targets map[string]*targetSummary
+	changes []watchChange
+}
+
+func newWatchSummary(started time.Time) *watchSummary {
+	return &watchSummary{started: started, targets: make(map[string]*targetSummary)}
+}
+
+// Add records one sample for target, noting a change when its PMTU differs
+// from the target's previous successful sample
+func (s *watchSummary) Add(target string, sample watchSample) {
+	t := s.targets[target]
+	if t == nil {
+		t = &targetSummary{}
+		s.targets[target] = t
+	}
+	t.samples++
+	if sample.Error != "" || sample.PMTU <= 0 {
+		t.failures++
+		return
+	}
+	if n := len(t.pmtus); n > 0 && t.pmtus[n-1] != sample.PMTU {
+		s.changes = append(s.changes, watchChange{Time: sample.Time, Target: target, From: t.pmtus[n-1], To: sample.PMTU})
+	}
+	t.pmtus = append(t.pmtus, sample.PMTU)
+}
+
+// watchSummaryRecord is the structured form of the summary, printed as the
+// last record of the stream
+type watchSummaryRecord struct {
+	Summary watchSummaryView `json:"summary"`
+}
+
+type watchSummaryView struct {
+	Started string              `json:"started"`
+	Ended   string              `json:"ended"`
+	Cycles  int                 `json:"cycles"`
+	Stopped string              `json:"stopped"` // count, duration, signal, or pmtu-drop
+	Targets []targetSummaryView `json:"targets"`
+	Changes []watchChange       `json:"changes"`
+}
+
+type targetSummaryView struct {
+	Target     string `json:"target"`
+	Samples    int    `json:"samples"`
+	Failures   int    `json:"failures"`
+	MinPMTU    int    `json:"min_pmtu,omitempty"`
+	MaxPMTU    int    `json:"max_pmtu,omitempty"`
+	MedianPMTU int    `json:"median_pmtu,omitempty"`
+}
+
+// view computes the per-target statistics; targets are sorted by name
+func (s *watchSummary) view(ended time.Time, stopped string) watchSummaryView {
+	view := watchSummaryView{
+		Started: s.started.Format(time.RFC3339),
+		Ended:   ended.Format(time.RFC3339),
+		Cycles:  s.cycles,
+		Stopped: stopped,
+		Targets: []targetSummaryView{},
+		Changes: s.changes,
+	}
+	if view.Changes == nil {
+		view.Changes = []watchChange{}
+	}
+	for _, name := range slices.Sorted(maps.Keys(s.targets)) {
+		t := s.targets[name]
+		target := targetSummaryView{Target: name, Samples: t.samples, Failures: t.failures}
+		if len(t.pmtus) > 0 {
+			sorted := slices.Sorted(slices.Values(t.pmtus))
+			// The lower middle of an even count, so the median is a PMTU that was seen
+			target.MinPMTU, target.MaxPMTU, target.MedianPMTU = sorted[0], sorted[len(sorted)-1], sorted[(len(sorted)-1)/2]
+		}
+		view.Targets = append(view.Targets, target)
+	}
+	return view
+}
+
+// write prints the summary: a table for people, or one final record
+func (s *watchSummary) write(w io.Writer, stopped string, format outputFormat) error {
+	ended := time.Now()
+	view := s.view(ended, stopped)
+	if format.structured() {
+		return format.encode(w, watchSummaryRecord{Summary: view}, false)
+	}
+
+	_, _ = fmt.Fprintf(w, "\nSummary: %d cycles over %v (%s)\n", view.Cycles, ended.Sub(s.started).Round(time.Second), watchStopText[stopped])
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "TARGET\tSAMPLES\tFAILURES\tMIN\tMAX\tMEDIAN\n")
+	for _, target := range view.Targets {
+		if target.MedianPMTU == 0 {
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t-\t-\t-\n", target.Target, target.Samples, target.Failures)
+			continue
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\n", target.Target, target.Samples, target.Failures, target.MinPMTU, target.MaxPMTU, target.MedianPMTU)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(view.Changes) == 0 {
+		_, _ = fmt.Fprintln(w, "No PMTU changes")
+		return nil
+	}
+	_, _ = fmt.Fprintf(w, "PMTU changes:\n")
+	for _, change := range view.Changes {
+		_, _ = fmt.Fprintf(w, "  [%s] %s: %d -> %d\n", change.Time.Format("15:04:05"), change.Target, change.From, change.To)
+	}
+	return nil
+}
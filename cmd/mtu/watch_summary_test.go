@@ -0,0 +1,138 @@
+package mtu
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/proxy"
+	"github.com/spf13/cobra"
+)
+
+func TestReadWatchLimits(t *testing.T) {
+	for _, tt := range []struct {
+		flags map[string]string
+		want  watchLimits
+		code  errcode.Code
+	}{
+		{nil, watchLimits{}, ""},
+		{map[string]string{"count": "3", "for": "1h"}, watchLimits{count: 3, duration: time.Hour}, ""},
+		{map[string]string{"count": "-1"}, watchLimits{}, errcode.CLIUsage},
+		{map[string]string{"for": "-5s"}, watchLimits{}, errcode.CLIUsage},
+	} {
+		cmd := &cobra.Command{Use: "watch"}
+		cmd.Flags().Int("count", 0, "")
+		cmd.Flags().Duration("for", 0, "")
+		for name, value := range tt.flags {
+			mustSetFlag(t, cmd, name, value)
+		}
+		limits, err := readWatchLimits(cmd)
+		if limits != tt.want || (err == nil) != (tt.code == "") || (err != nil && errcode.Of(err) != tt.code) {
+			t.Errorf("readWatchLimits(%v) = %+v, %v", tt.flags, limits, err)
+		}
+	}
+
+	if (watchLimits{}).reached(100) || !(watchLimits{count: 2}).reached(2) || (watchLimits{count: 2}).reached(1) {
+		t.Error("unexpected reached result")
+	}
+}
+
+func TestWatchContextFor(t *testing.T) {
+	ctx, stop := watchContext(watchLimits{duration: time.Millisecond})
+	defer stop()
+	<-ctx.Done()
+	if reason := watchStopReason(ctx); reason != watchStoppedDuration {
+		t.Fatalf("watchStopReason = %q, want %q", reason, watchStoppedDuration)
+	}
+
+	ctx, stop = watchContext(watchLimits{})
+	stop()
+	if reason := watchStopReason(ctx); reason != watchStoppedSignal {
+		t.Fatalf("watchStopReason = %q, want %q", reason, watchStoppedSignal)
+	}
+}
+
+func TestWatchSummary(t *testing.T) {
+	start := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	summary := newWatchSummary(start)
+	for i, pmtu := range []int{1500, 1500, 1400, 0, 1500, 1400} {
+		sample := watchSample{Time: start.Add(time.Duration(i) * time.Minute), PMTU: pmtu}
+		if pmtu == 0 {
+			sample.Error = "probe timed out"
+		}
+		summary.Add("b.example", sample)
+		summary.cycles++
+	}
+	summary.Add("a.example", watchSample{Time: start, Error: "no route"})
+
+	view := summary.view(start.Add(time.Hour), watchStoppedCount)
+	if len(view.Targets) != 2 || view.Targets[0] != (targetSummaryView{Target: "a.example", Samples: 1, Failures: 1}) {
+		t.Fatalf("targets = %+v, want a.example first with no PMTU", view.Targets)
+	}
+	if got := view.Targets[1]; got != (targetSummaryView{Target: "b.example", Samples: 6, Failures: 1, MinPMTU: 1400, MaxPMTU: 1500, MedianPMTU: 1500}) {
+		t.Errorf("b.example = %+v", got)
+	}
+	// The failed sample in between does not hide the rise back to 1500
+	if len(view.Changes) != 3 || view.Changes[1].From != 1400 || view.Changes[1].To != 1500 || !view.Changes[1].Time.Equal(start.Add(4*time.Minute)) {
+		t.Errorf("changes = %+v", view.Changes)
+	}
+
+	var out bytes.Buffer
+	if err := summary.write(&out, watchStoppedSignal, formatTable); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Summary: 6 cycles over", "(interrupted)", "a.example  1        1         -", "b.example  6        1         1400  1500  1500", "PMTU changes:", "b.example: 1500 -> 1400"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("summary missing %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := summary.write(&out, watchStoppedDrop, formatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var record watchSummaryRecord
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil || record.Summary.Stopped != watchStoppedDrop || record.Summary.Cycles != 6 {
+		t.Fatalf("JSON summary = %q (%v)", out.String(), err)
+	}
+}
+
+func TestRunFleetWatchStopsAfterCount(t *testing.T) {
+	var probes atomic.Int32
+	stubFleet(t, func(opts discoveryOptions) (*MTUResult, error) {
+		// 192.0.2.2 drops to 1400 in the second cycle
+		pmtu := 1500
+		if probes.Add(1) > 2 && opts.Destination == "192.0.2.2" {
+			pmtu = 1400
+		}
+		return &MTUResult{Target: opts.Destination, PMTU: pmtu, MSS: pmtu - 40}, nil
+	}, func(opts discoveryOptions) (proxy.Timing, error) { return proxy.Timing{}, nil })
+
+	cmd := newDiscoveryOptionsCommand()
+	cmd.Flags().Duration("interval", time.Millisecond, "")
+	cmd.Flags().Int("count", 0, "")
+	cmd.Flags().Int("concurrency", 1, "")
+	mustSetFlag(t, cmd, "interval", "1ms")
+	mustSetFlag(t, cmd, "count", "2")
+	mustSetFlag(t, cmd, "json", "true")
+	mustSetFlag(t, cmd, "allow-doc-ranges", "true")
+
+	output, err := captureStdout(t, func() error {
+		return runWatch(cmd, []string{"192.0.2.1", "192.0.2.2"})
+	})
+	if err != nil {
+		t.Fatalf("runWatch returned error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	var record watchSummaryRecord
+	if len(lines) != 3 || json.Unmarshal([]byte(lines[2]), &record) != nil {
+		t.Fatalf("expected two cycles and a summary, got:\n%s", output)
+	}
+	if record.Summary.Stopped != watchStoppedCount || record.Summary.Cycles != 2 || len(record.Summary.Targets) != 2 || len(record.Summary.Changes) != 1 {
+		t.Fatalf("unexpected summary %+v", record.Summary)
+	}
+}
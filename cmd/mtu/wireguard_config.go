@@ -0,0 +1,269 @@
+package mtu
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/units"
+	"github.com/spf13/cobra"
+)
+
+// WireGuard encapsulation: outer IP header + UDP (8) + WireGuard data header (32)
+const (
+	wireGuardOverheadIPv4 = 20 + 8 + 32
+	wireGuardOverheadIPv6 = 40 + 8 + 32
+	defaultWireGuardPort  = 51820
+	minIPv6LinkMTU        = 1280 // RFC 8200
+)
+
+var wireGuardMTUDiscovery = performMTUDiscovery
+
+// WireGuardConfigCmd discovers the underlay Path-MTU to a WireGuard endpoint
+// and prints a config skeleton with the matching MTU. It lives in the mtu
+// package for the discovery machinery and is registered under the fw group.
+var WireGuardConfigCmd = &cobra.Command{
+	Use:   "wireguard-config",
+	Short: "Generate a WireGuard config with an MTU measured to the endpoint",
+	Long: `Wireguard-config discovers the Path-MTU of the underlay to --endpoint, works
+out the tunnel MTU that avoids fragmenting WireGuard packets, and prints a
+commented [Interface]/[Peer] skeleton ready to fill in with keys.
+
+The tunnel MTU is the underlay PMTU minus the WireGuard encapsulation: 60
+bytes when the endpoint is reached over IPv4 (IP 20, UDP 8, WireGuard 32) and
+80 bytes over IPv6. Pass --pmtu to skip discovery and use a known underlay
+PMTU instead.
+
+Discovery uses TCP probes by default so it runs unprivileged; --proto icmp
+is often more reliable against a VPN gateway that only listens on UDP.`,
+	Args:        cobra.NoArgs,
+	RunE:        runWireGuardConfig,
+	Annotations: dryRunAnnotations,
+}
+
+func init() {
+	flags := WireGuardConfigCmd.Flags()
+	flags.String("endpoint", "", "WireGuard server as host or host:port (default port 51820)")
+	flags.StringArray("address", nil, "Tunnel address of this peer in CIDR form (repeatable)")
+	flags.StringArray("allowed-ips", nil, "Networks to route through the tunnel (repeatable, default: the --address networks)")
+	flags.StringArray("dns", nil, "DNS server to use while the tunnel is up (repeatable)")
+	units.Duration(flags, "keepalive", 25*time.Second, "PersistentKeepalive, such as 25s or plain seconds (0 = off)")
+	units.Size(flags, "pmtu", 0, "Underlay Path-MTU to use instead of discovering it")
+	_ = WireGuardConfigCmd.MarkFlagRequired("endpoint")
+	_ = WireGuardConfigCmd.MarkFlagRequired("address")
+
+	// The discovery flags readDiscoveryOptions needs, as on the mtu group
+	flags.Bool("4", false, "Force IPv4; fail if the endpoint has no IPv4 address")
+	flags.Bool("6", false, "Force IPv6; fail if the endpoint has no IPv6 address")
+	flags.String("prefer", "", "IP version to use when the endpoint has both (4|6), falling back to the other (default: --prefer-family)")
+	flags.String("proto", "icmp", "Probe method (icmp|udp|tcp|tls; default tcp unless set)")
+	units.Size(flags, "min", 0, "Lower bound in bytes (IPv4 default: 576, IPv6: 1280)")
+	units.Size(flags, "max", 9216, "Upper bound in bytes, such as 1500 or 9k")
+	units.Duration(flags, "timeout", 0, "Wait per probe, such as 1500ms (default: 2s)")
+	flags.Int("ttl", 64, "Initial hop limit")
+	flags.Int("pps", 10, "Rate limit probes per second")
+	units.Rate(flags, "rate", 0, "Rate limit probe bandwidth instead of --pps, such as 500kbps or 2mbps")
+	flags.Int("port", 0, "Target port for TCP/UDP probes (0 = default)")
+}
+
+// wireGuardConfig is everything the skeleton needs
+type wireGuardConfig struct {
+	Endpoint   string // host:port
+	Addresses  []netip.Prefix
+	AllowedIPs []netip.Prefix
+	DNS        []string
+	Keepalive  int
+	PMTU       int
+	Protocol   string // Probe protocol, empty when --pmtu was given
+	IPv6       bool   // Endpoint reached over IPv6
+}
+
+// Overhead returns the WireGuard encapsulation for the underlay address family
+func (c wireGuardConfig) Overhead() int {
+	if c.IPv6 {
+		return wireGuardOverheadIPv6
+	}
+	return wireGuardOverheadIPv4
+}
+
+// MTU returns the tunnel MTU for the underlay PMTU
+func (c wireGuardConfig) MTU() int {
+	return c.PMTU - c.Overhead()
+}
+
+func runWireGuardConfig(cmd *cobra.Command, args []string) error {
+	config, err := readWireGuardConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	if config.PMTU == 0 {
+		host, _, _ := net.SplitHostPort(config.Endpoint)
+		opts, err := readDiscoveryOptions(cmd, host)
+		if err != nil {
+			return err
+		}
+		opts = applySuggestProbeDefaults(cmd, opts)
+		if opts.Protocol == protocolAll {
+			return errcode.Errorf(errcode.CLIUsage, "--proto all is only supported by mtu discover")
+		}
+
+		plan := newDryRunPlan(opts)
+		if opts.DryRun {
+			return outputDryRun(plan, formatTable)
+		}
+		if err := checkProbeTargets(cmd, plan); err != nil {
+			return err
+		}
+		if err := recordProbeAudit(cmd, plan); err != nil {
+			return err
+		}
+		// The overhead depends on the version the endpoint is reached over
+		if opts, err = selectAddressFamily(opts, true); err != nil {
+			return err
+		}
+
+		ctx, cancel := newDiscoveryContext(opts)
+		defer cancel()
+
+		result, err := wireGuardMTUDiscovery(ctx, opts)
+		if err != nil {
+			return withDiscoveryErrorCode(fmt.Errorf("MTU discovery failed: %w", err), opts.Protocol)
+		}
+		config.PMTU, config.Protocol = result.PMTU, opts.Protocol
+		config.IPv6 = opts.IPv6
+	}
+
+	if config.MTU() < minTunnelMTU(config) {
+		return errcode.Errorf(errcode.CLIUsage, "underlay PMTU %d leaves a tunnel MTU of %d, too small for WireGuard", config.PMTU, config.MTU())
+	}
+
+	writeWireGuardConfig(cmd.OutOrStdout(), config)
+	return nil
+}
+
+// readWireGuardConfig validates the config flags
+func readWireGuardConfig(cmd *cobra.Command) (wireGuardConfig, error) {
+	endpoint, _ := cmd.Flags().GetString("endpoint")
+	addresses, _ := cmd.Flags().GetStringArray("address")
+	allowedIPs, _ := cmd.Flags().GetStringArray("allowed-ips")
+	dns, _ := cmd.Flags().GetStringArray("dns")
+	keepalive, _ := cmd.Flags().GetDuration("keepalive")
+	pmtu, _ := cmd.Flags().GetInt("pmtu")
+	forceIPv6, _ := cmd.Flags().GetBool("6")
+
+	config := wireGuardConfig{DNS: dns, Keepalive: int(keepalive / time.Second), PMTU: pmtu}
+
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		host, port = strings.Trim(endpoint, "[]"), strconv.Itoa(defaultWireGuardPort)
+	}
+	if host == "" {
+		return config, errcode.Errorf(errcode.CLIUsage, "invalid --endpoint %q: expected host or host:port", endpoint)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return config, errcode.Errorf(errcode.CLIUsage, "invalid --endpoint port %q", port)
+	}
+	config.Endpoint = net.JoinHostPort(host, port)
+	config.IPv6 = forceIPv6 || isIPv6Literal(host)
+
+	for _, address := range addresses {
+		prefix, err := netip.ParsePrefix(address)
+		if err != nil {
+			return config, errcode.Errorf(errcode.CLIUsage, "invalid --address %q: expected an address in CIDR form such as 10.8.0.2/24", address)
+		}
+		config.Addresses = append(config.Addresses, prefix)
+	}
+	for _, allowed := range allowedIPs {
+		prefix, err := netip.ParsePrefix(allowed)
+		if err != nil {
+			return config, errcode.Errorf(errcode.CLIUsage, "invalid --allowed-ips %q: expected a CIDR", allowed)
+		}
+		config.AllowedIPs = append(config.AllowedIPs, prefix.Masked())
+	}
+	if len(config.AllowedIPs) == 0 {
+		for _, address := range config.Addresses {
+			config.AllowedIPs = append(config.AllowedIPs, address.Masked())
+		}
+	}
+	for _, server := range dns {
+		if _, err := netip.ParseAddr(server); err != nil {
+			return config, errcode.Errorf(errcode.CLIUsage, "invalid --dns %q: expected an IP address", server)
+		}
+	}
+
+	if keepalive < 0 || keepalive > 65535*time.Second {
+		return config, errcode.Errorf(errcode.CLIUsage, "--keepalive must be between 0 and 65535s")
+	}
+	if keepalive%time.Second != 0 {
+		return config, errcode.Errorf(errcode.CLIUsage, "--keepalive must be a whole number of seconds")
+	}
+	if pmtu < 0 {
+		return config, errcode.Errorf(errcode.CLIUsage, "--pmtu must be non-negative")
+	}
+	return config, nil
+}
+
+// minTunnelMTU is the smallest tunnel MTU worth configuring: IPv6
+// inside the tunnel needs 1280, IPv4 needs 576
+func minTunnelMTU(config wireGuardConfig) int {
+	for _, address := range config.Addresses {
+		if address.Addr().Is6() {
+			return minIPv6LinkMTU
+		}
+	}
+	return defaultMinMTU(false)
+}
+
+func isIPv6Literal(host string) bool {
+	addr, err := netip.ParseAddr(host)
+	return err == nil && addr.Is6() && !addr.Is4In6()
+}
+
+// writeWireGuardConfig prints the commented wg-quick skeleton
+func writeWireGuardConfig(w io.Writer, config wireGuardConfig) {
+	family := "IPv4"
+	if config.IPv6 {
+		family = "IPv6"
+	}
+
+	_, _ = fmt.Fprintf(w, "# WireGuard config generated by cidrator fw wireguard-config\n")
+	if config.Protocol != "" {
+		_, _ = fmt.Fprintf(w, "# Underlay Path-MTU to %s, discovered over %s: %d\n", config.Endpoint, config.Protocol, config.PMTU)
+	} else {
+		_, _ = fmt.Fprintf(w, "# Underlay Path-MTU to %s, given with --pmtu: %d\n", config.Endpoint, config.PMTU)
+	}
+	_, _ = fmt.Fprintf(w, "\n[Interface]\n")
+	_, _ = fmt.Fprintf(w, "# This peer's private key: wg genkey > peer.key\n")
+	_, _ = fmt.Fprintf(w, "PrivateKey = <private key>\n")
+	_, _ = fmt.Fprintf(w, "Address = %s\n", joinPrefixes(config.Addresses))
+	if len(config.DNS) > 0 {
+		_, _ = fmt.Fprintf(w, "DNS = %s\n", strings.Join(config.DNS, ", "))
+	}
+	_, _ = fmt.Fprintf(w, "# %d underlay PMTU - %d bytes of WireGuard over %s (IP, UDP, and WireGuard headers)\n", config.PMTU, config.Overhead(), family)
+	_, _ = fmt.Fprintf(w, "MTU = %d\n", config.MTU())
+
+	_, _ = fmt.Fprintf(w, "\n[Peer]\n")
+	_, _ = fmt.Fprintf(w, "# The server's public key: wg pubkey < server.key\n")
+	_, _ = fmt.Fprintf(w, "PublicKey = <server public key>\n")
+	_, _ = fmt.Fprintf(w, "Endpoint = %s\n", config.Endpoint)
+	_, _ = fmt.Fprintf(w, "# Traffic for these networks goes through the tunnel\n")
+	_, _ = fmt.Fprintf(w, "AllowedIPs = %s\n", joinPrefixes(config.AllowedIPs))
+	if config.Keepalive > 0 {
+		_, _ = fmt.Fprintf(w, "# Keeps NAT and firewall state open while the tunnel is idle\n")
+		_, _ = fmt.Fprintf(w, "PersistentKeepalive = %d\n", config.Keepalive)
+	}
+}
+
+func joinPrefixes(prefixes []netip.Prefix) string {
+	parts := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		parts[i] = prefix.String()
+	}
+	return strings.Join(parts, ", ")
+}
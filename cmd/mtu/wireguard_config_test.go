@@ -0,0 +1,122 @@
+package mtu
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/units"
+	"github.com/spf13/cobra"
+)
+
+func newWireGuardConfigTestCommand(out *bytes.Buffer) *cobra.Command {
+	cmd := newDiscoveryOptionsCommand()
+	cmd.SetOut(out)
+	flags := cmd.Flags()
+	flags.Bool("dry-run", false, "")
+	flags.String("endpoint", "", "")
+	flags.StringArray("address", nil, "")
+	flags.StringArray("allowed-ips", nil, "")
+	flags.StringArray("dns", nil, "")
+	units.Duration(flags, "keepalive", 25*time.Second, "")
+	flags.Int("pmtu", 0, "")
+	return cmd
+}
+
+func TestRunWireGuardConfig(t *testing.T) {
+	original := wireGuardMTUDiscovery
+	t.Cleanup(func() { wireGuardMTUDiscovery = original })
+
+	var gotOpts discoveryOptions
+	wireGuardMTUDiscovery = func(ctx context.Context, opts discoveryOptions) (*MTUResult, error) {
+		gotOpts = opts
+		return &MTUResult{Target: opts.Destination, Protocol: opts.Protocol, PMTU: 1492}, nil
+	}
+
+	var out bytes.Buffer
+	cmd := newWireGuardConfigTestCommand(&out)
+	mustSetFlag(t, cmd, "endpoint", "vpn.example.com")
+	mustSetFlag(t, cmd, "address", "10.8.0.2/24")
+	mustSetFlag(t, cmd, "dns", "10.8.0.1")
+	if err := runWireGuardConfig(cmd, nil); err != nil {
+		t.Fatalf("runWireGuardConfig returned error: %v", err)
+	}
+	if gotOpts.Destination != "vpn.example.com" || gotOpts.Protocol != "tcp" {
+		t.Errorf("expected unprivileged discovery to the endpoint host, got %+v", gotOpts)
+	}
+
+	for _, expected := range []string{
+		"# Underlay Path-MTU to vpn.example.com:51820, discovered over tcp: 1492",
+		"[Interface]",
+		"Address = 10.8.0.2/24",
+		"DNS = 10.8.0.1",
+		"# 1492 underlay PMTU - 60 bytes of WireGuard over IPv4",
+		"MTU = 1432",
+		"[Peer]",
+		"Endpoint = vpn.example.com:51820",
+		"AllowedIPs = 10.8.0.0/24",
+		"PersistentKeepalive = 25",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected config to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+}
+
+func TestRunWireGuardConfigGivenPMTU(t *testing.T) {
+	original := wireGuardMTUDiscovery
+	t.Cleanup(func() { wireGuardMTUDiscovery = original })
+	wireGuardMTUDiscovery = func(ctx context.Context, opts discoveryOptions) (*MTUResult, error) {
+		t.Fatal("--pmtu should skip discovery")
+		return nil, nil
+	}
+
+	var out bytes.Buffer
+	cmd := newWireGuardConfigTestCommand(&out)
+	mustSetFlag(t, cmd, "endpoint", "[2001:db8::1]:51821")
+	mustSetFlag(t, cmd, "address", "fd00:8::2/64")
+	mustSetFlag(t, cmd, "allowed-ips", "::/0")
+	mustSetFlag(t, cmd, "keepalive", "0s")
+	mustSetFlag(t, cmd, "pmtu", "1500")
+	if err := runWireGuardConfig(cmd, nil); err != nil {
+		t.Fatalf("runWireGuardConfig returned error: %v", err)
+	}
+
+	config := out.String()
+	for _, expected := range []string{"given with --pmtu: 1500", "80 bytes of WireGuard over IPv6", "MTU = 1420", "Endpoint = [2001:db8::1]:51821", "AllowedIPs = ::/0"} {
+		if !strings.Contains(config, expected) {
+			t.Errorf("expected config to contain %q, got:\n%s", expected, config)
+		}
+	}
+	if strings.Contains(config, "PersistentKeepalive") || strings.Contains(config, "DNS =") {
+		t.Errorf("unexpected optional settings in:\n%s", config)
+	}
+}
+
+func TestRunWireGuardConfigRejectsBadFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags map[string]string
+	}{
+		{name: "bad address", flags: map[string]string{"endpoint": "vpn.example.com", "address": "10.8.0.2"}},
+		{name: "bad port", flags: map[string]string{"endpoint": "vpn.example.com:0", "address": "10.8.0.2/24"}},
+		{name: "bad dns", flags: map[string]string{"endpoint": "vpn.example.com", "address": "10.8.0.2/24", "dns": "dns.example.com"}},
+		{name: "fractional keepalive", flags: map[string]string{"endpoint": "vpn.example.com", "address": "10.8.0.2/24", "pmtu": "1500", "keepalive": "1500ms"}},
+		{name: "tunnel too small for ipv6", flags: map[string]string{"endpoint": "vpn.example.com", "address": "fd00:8::2/64", "pmtu": "1280"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			cmd := newWireGuardConfigTestCommand(&out)
+			for name, value := range tt.flags {
+				mustSetFlag(t, cmd, name, value)
+			}
+			if err := runWireGuardConfig(cmd, nil); errcode.Of(err) != errcode.CLIUsage {
+				t.Fatalf("expected CLI002, got %v", err)
+			}
+		})
+	}
+}
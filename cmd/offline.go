@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/offline"
+	"github.com/euan-cowie/cidrator/internal/policy"
+	"github.com/spf13/cobra"
+)
+
+// offlineCommand is a command --offline lets run, since it needs no network
+// of its own, or none once Requires supplies what it would otherwise measure
+type offlineCommand struct {
+	Command  string // Path below root; a group covers its subcommands
+	Requires string // Flag that must be set to run offline; empty if none
+}
+
+// offlineCommands lists every command that can run offline. Anything else
+// fails at once with CLI012, so a new command stays off the air-gapped list
+// until it is added here. Keep the list in README.md in step with it.
+var offlineCommands = []offlineCommand{
+	{Command: "cidr"},
+	{Command: "set"},
+	{Command: "audit"},
+	{Command: "mtu interfaces"},
+	{Command: "mtu compare"},
+	{Command: "mtu suggest", Requires: "pmtu"},
+	{Command: "fw wireguard-config", Requires: "pmtu"},
+}
+
+// checkOffline rejects commands that need the network while --offline is
+// set. A --dry-run plan sends nothing, so it is allowed wherever supported.
+// Commands that do run offline still cannot resolve hostnames.
+func checkOffline(cmd *cobra.Command) error {
+	if !offline.Enabled() {
+		return nil
+	}
+	path := commandPath(cmd)
+	if path == "" || slices.ContainsFunc(policy.AlwaysAllowed, func(command string) bool { return covers(command, path) }) {
+		return nil
+	}
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun && cmd.Annotations["dry-run"] == "supported" {
+		return nil
+	}
+	for _, allowed := range offlineCommands {
+		if !covers(allowed.Command, path) {
+			continue
+		}
+		if allowed.Requires == "" || cmd.Flags().Changed(allowed.Requires) {
+			return nil
+		}
+		return offline.Check(fmt.Sprintf("%s without --%s", path, allowed.Requires))
+	}
+	return offline.Check(path)
+}
+
+// covers reports whether path is command or one of its subcommands
+func covers(command, path string) bool {
+	return path == command || strings.HasPrefix(path, command+" ")
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/offline"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// runOffline runs args against a small tree shaped like the real one, with
+// offline mode on
+func runOffline(t *testing.T, args ...string) error {
+	t.Helper()
+	// Execute runs initConfig, which takes --offline from viper
+	viper.Set("offline", true)
+	t.Cleanup(func() {
+		viper.Set("offline", false)
+		offline.Configure(false)
+	})
+
+	run := func(cmd *cobra.Command, args []string) error { return nil }
+	root := &cobra.Command{
+		Use:               "cidrator",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return checkOffline(cmd) },
+	}
+	root.PersistentFlags().Bool("dry-run", false, "")
+	cidr := &cobra.Command{Use: "cidr"}
+	cidr.AddCommand(&cobra.Command{Use: "explain", RunE: run})
+	mtu := &cobra.Command{Use: "mtu"}
+	suggest := &cobra.Command{Use: "suggest", RunE: run}
+	suggest.Flags().Int("pmtu", 0, "")
+	mtu.AddCommand(suggest, &cobra.Command{Use: "discover", RunE: run, Annotations: map[string]string{"dry-run": "supported"}})
+	dns := &cobra.Command{Use: "dns"}
+	dns.AddCommand(&cobra.Command{Use: "lookup", RunE: run})
+	root.AddCommand(cidr, mtu, dns, &cobra.Command{Use: "explain", RunE: run}, &cobra.Command{Use: "lookup", RunE: run}, &cobra.Command{Use: "version", RunE: run})
+
+	root.SetArgs(args)
+	return root.Execute()
+}
+
+func TestCheckOffline(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want errcode.Code
+	}{
+		{[]string{"cidr", "explain"}, ""},
+		{[]string{"explain"}, ""},
+		{[]string{"version"}, ""},
+		{[]string{"mtu", "suggest", "--pmtu", "1420"}, ""},
+		{[]string{"mtu", "discover", "--dry-run"}, ""},
+		{[]string{"mtu", "suggest"}, errcode.CLIOffline},
+		{[]string{"mtu", "discover"}, errcode.CLIOffline},
+		{[]string{"dns", "lookup"}, errcode.CLIOffline},
+		{[]string{"lookup"}, errcode.CLIOffline},
+	} {
+		if err := runOffline(t, tt.args...); (err == nil) != (tt.want == "") || (err != nil && errcode.Of(err) != tt.want) {
+			t.Errorf("%v offline = %v, want %q", tt.args, err, tt.want)
+		}
+	}
+
+	err := runOffline(t, "mtu", "suggest")
+	if want := "mtu suggest without --pmtu needs the network, which --offline turns off"; err == nil || err.Error() != want {
+		t.Errorf("error = %v, want %q", err, want)
+	}
+}
+
+func TestOfflineCommandsExist(t *testing.T) {
+	for _, allowed := range offlineCommands {
+		cmd := mustFindCommand(rootCmd, allowed.Command)
+		if allowed.Requires != "" && cmd.Flags().Lookup(allowed.Requires) == nil {
+			t.Errorf("%s has no --%s flag", allowed.Command, allowed.Requires)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+//go:build minimal
+
+package cmd
+
+// buildProfile names the feature set compiled into this binary
+const buildProfile = "minimal"
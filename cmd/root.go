@@ -3,16 +3,36 @@ package cmd
 import (
 	"errors"
 	"os"
+	"strconv"
+	"strings"
 
+	"github.com/euan-cowie/cidrator/cmd/audit"
+	"github.com/euan-cowie/cidrator/cmd/bench"
 	"github.com/euan-cowie/cidrator/cmd/cidr"
 	"github.com/euan-cowie/cidrator/cmd/dns"
+	"github.com/euan-cowie/cidrator/cmd/fw"
 	"github.com/euan-cowie/cidrator/cmd/mtu"
+	"github.com/euan-cowie/cidrator/cmd/set"
+	"github.com/euan-cowie/cidrator/cmd/view"
+	auditlog "github.com/euan-cowie/cidrator/internal/audit"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/family"
+	"github.com/euan-cowie/cidrator/internal/notify"
+	"github.com/euan-cowie/cidrator/internal/offline"
+	"github.com/euan-cowie/cidrator/internal/policy"
+	"github.com/euan-cowie/cidrator/internal/store"
+	"github.com/euan-cowie/cidrator/internal/target"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var cfgFile string
 
+// SystemPolicyPath is the admin-managed policy file. When it exists it
+// replaces the policy section of the user's config, so users cannot loosen
+// it. Packagers may override it via ldflags.
+var SystemPolicyPath = "/etc/cidrator/policy.yaml"
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "cidrator",
@@ -21,13 +41,30 @@ var rootCmd = &cobra.Command{
 
 It provides focused tools for CIDR inspection, DNS queries, and Path MTU analysis.
 Use 'cidrator <command> --help' for command-specific details.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if examplesRequested(cmd) {
+			return showExamples(cmd)
+		}
+		if err := checkPolicy(cmd); err != nil {
+			return err
+		}
+		if err := checkOffline(cmd); err != nil {
+			return err
+		}
+		if err := checkDryRunSupport(cmd, args); err != nil {
+			return err
+		}
+		warnDeprecated(cmd)
+		return nil
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
-	if err != nil {
+	cmd, err := rootCmd.ExecuteC()
+	if err != nil && !errors.Is(err, errExamplesShown) {
+		reportError(os.Stderr, cmd, err)
 		os.Exit(1)
 	}
 }
@@ -39,18 +76,124 @@ func init() {
 	rootCmd.AddCommand(cidr.CidrCmd)
 	rootCmd.AddCommand(mtu.MTUCmd)
 	rootCmd.AddCommand(dns.DNSCmd)
+	rootCmd.AddCommand(fw.FwCmd)
+	rootCmd.AddCommand(bench.BenchCmd)
+	rootCmd.AddCommand(audit.AuditCmd)
+	rootCmd.AddCommand(set.SetCmd)
+	rootCmd.AddCommand(view.ViewCmd)
+	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(selfUpdateCmd)
+	configureNDJSONInput(rootCmd)
+	configureCommandDiscovery(rootCmd)
+	configureDeprecations(rootCmd)
+	configureExamples(rootCmd)
+
+	// Errors and usage are printed by Execute so errors carry their error code
+	rootCmd.SilenceErrors = true
+	rootCmd.SilenceUsage = true
 
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.cidrator.yaml)")
+	rootCmd.PersistentFlags().Bool("examples", false, "Print copy-pasteable examples of the command and exit")
+	rootCmd.PersistentFlags().Bool("dry-run", false, "Print the traffic an active probing command would generate without sending anything")
+	rootCmd.PersistentFlags().Bool("allow-doc-ranges", false, "Let probing commands send to documentation and benchmarking ranges, such as 192.0.2.0/24 and 2001:db8::/32")
+	rootCmd.PersistentFlags().String("audit-log", "", "Append an audit entry for every probing command to this file (default: audit-log from config, otherwise disabled)")
+	cobra.CheckErr(viper.BindPFlag("audit-log", rootCmd.PersistentFlags().Lookup("audit-log")))
+	rootCmd.PersistentFlags().String("state-dir", "", "Directory for caches and saved state (default: state-dir from config, otherwise the user config directory)")
+	cobra.CheckErr(viper.BindPFlag("state-dir", rootCmd.PersistentFlags().Lookup("state-dir")))
+	rootCmd.PersistentFlags().Bool("strict-input", false, "Accept only the form each argument expects: no bare IPs or ranges for CIDRs and no hostnames for addresses")
+	cobra.CheckErr(viper.BindPFlag("strict-input", rootCmd.PersistentFlags().Lookup("strict-input")))
+	rootCmd.PersistentFlags().Bool("offline", false, "Refuse every network call, for air-gapped hosts: only commands that can work from their inputs run (default: offline from config)")
+	cobra.CheckErr(viper.BindPFlag("offline", rootCmd.PersistentFlags().Lookup("offline")))
+	rootCmd.PersistentFlags().String("prefer-family", "", "IP version to use when a target has both (auto|4|6); auto picks IPv6 on hosts without IPv4 connectivity (default: prefer-family from config, otherwise auto)")
+	cobra.CheckErr(viper.BindPFlag("prefer-family", rootCmd.PersistentFlags().Lookup("prefer-family")))
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	// rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
+// checkDryRunSupport rejects --dry-run on commands that cannot plan their traffic
+// up front, so the flag never silently sends packets.
+func checkDryRunSupport(cmd *cobra.Command, args []string) error {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	if dryRun && cmd.Annotations["dry-run"] != "supported" {
+		return errcode.Errorf(errcode.CLIDryRunUnsupported, "--dry-run is not supported by %s", cmd.CommandPath())
+	}
+	return nil
+}
+
+// commandPath is cmd's path below the root, such as "mtu discover", with a
+// top-level shortcut replaced by the command it stands for
+func commandPath(cmd *cobra.Command) string {
+	path := strings.TrimSpace(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()))
+	name, rest, _ := strings.Cut(path, " ")
+	if target, ok := shortcuts[name]; ok {
+		path = strings.TrimSpace(target + " " + rest)
+	}
+	return path
+}
+
+// policyRateFlags are the probe rate flags max_pps caps
+var policyRateFlags = []string{"pps", "qps"}
+
+// checkPolicy rejects commands the policy does not allow and holds the rate
+// flags to max_pps, lowering defaults and refusing explicit values above it.
+// Targets are checked by the probing commands once they have resolved them.
+func checkPolicy(cmd *cobra.Command) error {
+	if err := policy.CheckCommand(commandPath(cmd)); err != nil {
+		return err
+	}
+
+	limit := policy.Current().MaxPPS
+	if limit == 0 {
+		return nil
+	}
+	for _, name := range policyRateFlags {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil {
+			continue
+		}
+		value, err := strconv.ParseFloat(flag.Value.String(), 64)
+		if err != nil {
+			continue
+		}
+		rate, err := policy.Rate(name, value, flag.Changed)
+		if err != nil {
+			return err
+		}
+		if rate != value {
+			if err := flag.Value.Set(strconv.FormatFloat(rate, 'f', -1, 64)); err != nil {
+				return err
+			}
+		}
+	}
+	if rate, _ := cmd.Flags().GetFloat64("rate"); rate > 0 {
+		return errcode.Errorf(errcode.CLIPolicy, "--rate is not allowed while policy %s caps probes at max_pps %d; use --pps", policy.Current().Source, limit)
+	}
+	return nil
+}
+
+// configurePolicy puts the system policy in force when it exists, otherwise
+// the policy section of the config file
+func configurePolicy() error {
+	var p policy.Policy
+	if _, err := os.Stat(SystemPolicyPath); err == nil {
+		if p, err = policy.Load(SystemPolicyPath); err != nil {
+			return err
+		}
+	} else if viper.IsSet("policy") {
+		if err := viper.UnmarshalKey("policy", &p); err != nil {
+			return errcode.Errorf(errcode.CLIPolicy, "policy %s: %w", viper.ConfigFileUsed(), err)
+		}
+		p.Source = viper.ConfigFileUsed()
+	}
+	return policy.Configure(p)
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	if cfgFile != "" {
@@ -75,4 +218,25 @@ func initConfig() {
 			cobra.CheckErr(err)
 		}
 	}
+
+	auditlog.SetPath(viper.GetString("audit-log"))
+	store.Configure(viper.GetString("store"), viper.GetString("state-dir"))
+	target.Configure(viper.GetBool("strict-input"), viper.GetString("resolver"))
+	offline.Configure(viper.GetBool("offline"))
+	cobra.CheckErr(family.Configure(viper.GetString("prefer-family")))
+	cobra.CheckErr(configurePolicy())
+	cobra.CheckErr(configureAlerts())
+}
+
+// configureAlerts puts the alerts section of the config file in force for
+// every watch command
+func configureAlerts() error {
+	var c notify.Config
+	if viper.IsSet("alerts") {
+		if err := viper.UnmarshalKey("alerts", &c); err != nil {
+			return errcode.Errorf(errcode.CLIAlerts, "alerts %s: %w", viper.ConfigFileUsed(), err)
+		}
+		c.Source = viper.ConfigFileUsed()
+	}
+	return notify.Configure(c)
 }
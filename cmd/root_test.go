@@ -1,7 +1,13 @@
 package cmd
 
 import (
+	"bytes"
+	"strings"
 	"testing"
+
+	"github.com/euan-cowie/cidrator/cmd/mtu"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/policy"
 )
 
 func TestVersionVariables(t *testing.T) {
@@ -39,7 +45,120 @@ func TestRootCommand(t *testing.T) {
 	if commandNames["scan"] {
 		t.Error("scan should not be exposed on the root command")
 	}
-	if commandNames["fw"] {
-		t.Error("fw should not be exposed on the root command")
+	if !commandNames["fw"] {
+		t.Error("fw should be exposed on the root command")
+	}
+}
+
+func TestCheckDryRunSupport(t *testing.T) {
+	for _, tc := range []struct {
+		path    []string
+		wantErr bool
+	}{
+		{[]string{"mtu", "discover"}, false},
+		{[]string{"mtu", "watch"}, false},
+		{[]string{"mtu", "suggest"}, false},
+		{[]string{"fw", "wireguard-config"}, false},
+		{[]string{"dns", "ptr-audit"}, false},
+		{[]string{"dns", "delegation"}, true},
+		{[]string{"mtu", "interfaces"}, true},
+		{[]string{"bench", "self"}, false},
+	} {
+		cmd, _, err := rootCmd.Find(tc.path)
+		if err != nil {
+			t.Fatalf("command %v not found: %v", tc.path, err)
+		}
+		flags := cmd.Flags()
+		flags.AddFlagSet(rootCmd.PersistentFlags())
+		if err := flags.Set("dry-run", "true"); err != nil {
+			t.Fatalf("failed to set --dry-run on %v: %v", tc.path, err)
+		}
+
+		err = checkDryRunSupport(cmd, nil)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("checkDryRunSupport(%v) error = %v, wantErr %v", tc.path, err, tc.wantErr)
+		}
+		_ = flags.Set("dry-run", "false")
+	}
+}
+
+func TestCheckPolicy(t *testing.T) {
+	if err := policy.Configure(policy.Policy{AllowedCommands: []string{"cidr", "dns"}, MaxPPS: 5, Source: "test.yaml"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = policy.Configure(policy.Policy{}) })
+
+	for _, tc := range []struct {
+		path    []string
+		wantErr bool
+	}{
+		{[]string{"cidr", "explain"}, false},
+		{[]string{"explain"}, false}, // shortcut for cidr explain
+		{[]string{"lookup"}, false},
+		{[]string{"version"}, false},
+		{[]string{"mtu", "discover"}, true},
+		{[]string{"fw", "wireguard-config"}, true},
+	} {
+		cmd, _, err := rootCmd.Find(tc.path)
+		if err != nil {
+			t.Fatalf("command %v not found: %v", tc.path, err)
+		}
+		err = checkPolicy(cmd)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("checkPolicy(%v) error = %v, wantErr %v", tc.path, err, tc.wantErr)
+		}
+		if err != nil && errcode.Of(err) != errcode.CLIPolicy {
+			t.Errorf("checkPolicy(%v): expected CLI009, got %v", tc.path, err)
+		}
+	}
+
+	// The unlimited default --qps is lowered to the cap; a higher explicit value is refused
+	cmd, _, _ := rootCmd.Find([]string{"dns", "ptr-audit"})
+	flag := cmd.Flags().Lookup("qps")
+	t.Cleanup(func() {
+		_ = flag.Value.Set(flag.DefValue)
+		flag.Changed = false
+	})
+	if err := checkPolicy(cmd); err != nil || flag.Value.String() != "5" {
+		t.Fatalf("checkPolicy = %v with --qps %s, want the cap of 5", err, flag.Value)
+	}
+	if err := cmd.Flags().Set("qps", "20"); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkPolicy(cmd); errcode.Of(err) != errcode.CLIPolicy {
+		t.Fatalf("expected CLI009 for --qps above max_pps, got %v", err)
+	}
+}
+
+func TestVersionReportsProfileAndCapabilities(t *testing.T) {
+	original := mtuCapabilities
+	t.Cleanup(func() { mtuCapabilities = original })
+	mtuCapabilities = func() []mtu.Capability {
+		return []mtu.Capability{
+			{Name: "icmp", Available: false, Reason: "disabled in minimal build"},
+			{Name: "tcp", Available: true},
+			{Name: "udp", Available: true, Reason: "over a datagram socket"},
+		}
+	}
+
+	var out bytes.Buffer
+	versionCmd.SetOut(&out)
+	t.Cleanup(func() { versionCmd.SetOut(nil) })
+	if err := versionCmd.Flags().Set("capabilities", "true"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = versionCmd.Flags().Set("capabilities", "false") })
+
+	versionCmd.Run(versionCmd, nil)
+
+	for _, fragment := range []string{
+		"Profile: " + buildProfile,
+		"icmp          unavailable (disabled in minimal build)",
+		"tcp           available",
+		"udp           available (over a datagram socket)",
+	} {
+		if !strings.Contains(out.String(), fragment) {
+			t.Fatalf("expected version output to contain %q, got:\n%s", fragment, out.String())
+		}
 	}
 }
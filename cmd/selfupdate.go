@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	auditlog "github.com/euan-cowie/cidrator/internal/audit"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/selfupdate"
+	"github.com/spf13/cobra"
+)
+
+// ReleasePublicKey is the minisign public key release checksums are signed
+// with, set at build time. Builds without one need --public-key to update.
+var ReleasePublicKey = ""
+
+// releaseSource and executablePath are replaced in tests
+var (
+	releaseSource  = &selfupdate.GitHub{}
+	executablePath = os.Executable
+)
+
+// selfUpdateCmd represents the self-update command
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Replace this binary with the latest signed release",
+	Long: `Self-update downloads the newest cidrator release for this platform from GitHub
+and installs it in place of the running binary.
+
+The release's checksums.txt must carry a valid minisign signature from the
+release key built into this binary (or --public-key), and the archive must match
+its checksum; nothing is installed otherwise. The new binary is written beside
+the old one and renamed over it, then run once with "version". If that fails,
+the previous binary is put back.
+
+--channel stable follows full releases; --channel edge also takes prereleases.
+--check only reports whether an update is available.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		channel, _ := cmd.Flags().GetString("channel")
+		checkOnly, _ := cmd.Flags().GetBool("check")
+		force, _ := cmd.Flags().GetBool("force")
+		keyFlag, _ := cmd.Flags().GetString("public-key")
+		w := cmd.OutOrStdout()
+		if channel != selfupdate.ChannelStable && channel != selfupdate.ChannelEdge {
+			return errcode.Errorf(errcode.CLIUsage, "invalid channel %q: expected %s or %s", channel, selfupdate.ChannelStable, selfupdate.ChannelEdge)
+		}
+
+		var key selfupdate.PublicKey
+		if !checkOnly {
+			var err error
+			if key, err = releaseKey(keyFlag); err != nil {
+				return err
+			}
+		}
+
+		ctx := cmd.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if err := auditlog.Record(auditlog.Entry{Command: cmd.CommandPath(), Targets: []string{releaseSource.ReleasesURL()}, Protocol: "https", Packets: 1}); err != nil {
+			return err
+		}
+		release, err := releaseSource.Latest(ctx, channel)
+		if err != nil {
+			return err
+		}
+
+		if !force && !selfupdate.Newer(release.Version(), Version) {
+			_, _ = fmt.Fprintf(w, "cidrator %s is up to date (latest %s release: %s)\n", Version, channel, release.Version())
+			return nil
+		}
+		if checkOnly {
+			_, _ = fmt.Fprintf(w, "Update available: %s -> %s\n", Version, release.Version())
+			return nil
+		}
+
+		return installRelease(ctx, cmd, release, key)
+	},
+}
+
+// installRelease downloads, verifies, and installs the archive of release for
+// this platform
+func installRelease(ctx context.Context, cmd *cobra.Command, release *selfupdate.Release, key selfupdate.PublicKey) error {
+	name := selfupdate.CurrentArchiveName()
+	downloads := []string{selfupdate.ChecksumsAsset, selfupdate.SignatureAsset, name}
+	urls := make([]string, 0, len(downloads))
+	for _, asset := range downloads {
+		if url, ok := release.Assets[asset]; ok {
+			urls = append(urls, url)
+		}
+	}
+	if err := auditlog.Record(auditlog.Entry{Command: cmd.CommandPath(), Targets: urls, Protocol: "https", Packets: len(urls)}); err != nil {
+		return err
+	}
+
+	assets := make(map[string][]byte)
+	for _, asset := range downloads {
+		data, err := releaseSource.Download(ctx, release, asset)
+		if err != nil {
+			return err
+		}
+		assets[asset] = data
+	}
+
+	comment, err := selfupdate.Verify(key, assets[selfupdate.ChecksumsAsset], assets[selfupdate.SignatureAsset], assets[name], name)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Verified %s with key %s (%s)\n", name, key, comment)
+
+	binary, err := selfupdate.ExtractBinary(assets[name])
+	if err != nil {
+		return err
+	}
+	path, err := executablePath()
+	if err != nil {
+		return errcode.Wrap(errcode.CLISelfUpdate, err)
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	if err := selfupdate.Install(ctx, path, binary, checkInstalledVersion(release.Version())); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Updated %s from %s to %s\n", path, Version, release.Version())
+	return nil
+}
+
+// checkInstalledVersion runs "version" on the installed binary and expects it
+// to report version
+func checkInstalledVersion(version string) func(ctx context.Context, path string) error {
+	return func(ctx context.Context, path string) error {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		output, err := exec.CommandContext(ctx, path, "version").CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, bytes.TrimSpace(output))
+		}
+		if want := "cidrator version " + version + "\n"; !strings.HasPrefix(string(output), want) {
+			return fmt.Errorf("reported %q, want version %s", strings.SplitN(string(output), "\n", 2)[0], version)
+		}
+		return nil
+	}
+}
+
+// releaseKey reads --public-key, a .pub file or the key itself, falling back
+// to the key built into this binary
+func releaseKey(flag string) (selfupdate.PublicKey, error) {
+	text := flag
+	if data, err := os.ReadFile(flag); flag != "" && err == nil {
+		text = string(data)
+	}
+	if text == "" {
+		text = ReleasePublicKey
+	}
+	if text == "" {
+		return selfupdate.PublicKey{}, errcode.Errorf(errcode.CLIUsage, "this build has no release signing key; pass --public-key")
+	}
+	return selfupdate.ParsePublicKey(text)
+}
+
+func init() {
+	selfUpdateCmd.Flags().String("channel", selfupdate.ChannelStable, "Release channel to follow (stable, edge)")
+	selfUpdateCmd.Flags().Bool("check", false, "Only report whether an update is available")
+	selfUpdateCmd.Flags().Bool("force", false, "Install the latest release even if it is not newer than this binary")
+	selfUpdateCmd.Flags().String("public-key", "", "Minisign public key, or a .pub file, to verify the release with (default: the key built into this binary)")
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	auditlog "github.com/euan-cowie/cidrator/internal/audit"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/selfupdate"
+)
+
+// serveRelease publishes v9.9.9 with a signed checksum file and an archive
+// for this platform holding binary, returning the public key it is signed with
+func serveRelease(t *testing.T, binary string) string {
+	t.Helper()
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	_ = tw.WriteHeader(&tar.Header{Name: "cidrator", Mode: 0o755, Size: int64(len(binary)), Typeflag: tar.TypeReg})
+	_, _ = tw.Write([]byte(binary))
+	_ = tw.Close()
+	_ = gz.Close()
+
+	name := selfupdate.CurrentArchiveName()
+	sum := sha256.Sum256(archive.Bytes())
+	checksums := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), name)
+
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	id := []byte("testkey!")
+	sig := ed25519.Sign(priv, []byte(checksums))
+	global := ed25519.Sign(priv, append(bytes.Clone(sig), "cidrator 9.9.9"...))
+	signature := fmt.Sprintf("untrusted comment: x\n%s\ntrusted comment: cidrator 9.9.9\n%s\n",
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), id...), sig...)), base64.StdEncoding.EncodeToString(global))
+
+	assets := map[string][]byte{
+		selfupdate.ChecksumsAsset: []byte(checksums),
+		selfupdate.SignatureAsset: []byte(signature),
+		name:                      archive.Bytes(),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/euan-cowie/cidrator/releases/latest" {
+			_, _ = fmt.Fprint(w, `{"tag_name":"v9.9.9","assets":[`)
+			sep := ""
+			for asset := range assets {
+				_, _ = fmt.Fprintf(w, `%s{"name":%q,"browser_download_url":"http://%s/dl/%s"}`, sep, asset, r.Host, asset)
+				sep = ","
+			}
+			_, _ = fmt.Fprint(w, `]}`)
+			return
+		}
+		if data, ok := assets[strings.TrimPrefix(r.URL.Path, "/dl/")]; ok {
+			_, _ = w.Write(data)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	original := releaseSource
+	releaseSource = &selfupdate.GitHub{API: server.URL}
+	t.Cleanup(func() { releaseSource = original })
+	return base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), id...), pub...))
+}
+
+// runSelfUpdate runs self-update with flags set and the rest at their defaults
+func runSelfUpdate(t *testing.T, flags map[string]string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	selfUpdateCmd.SetOut(&out)
+	selfUpdateCmd.SetErr(&out)
+	defer func() {
+		selfUpdateCmd.SetOut(nil)
+		selfUpdateCmd.SetErr(nil)
+	}()
+	for _, name := range []string{"channel", "check", "force", "public-key"} {
+		flag := selfUpdateCmd.Flags().Lookup(name)
+		value, ok := flags[name]
+		if !ok {
+			value = flag.DefValue
+		}
+		if err := flag.Value.Set(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		for name := range flags {
+			flag := selfUpdateCmd.Flags().Lookup(name)
+			_ = flag.Value.Set(flag.DefValue)
+		}
+	})
+	err := selfUpdateCmd.RunE(selfUpdateCmd, nil)
+	return out.String(), err
+}
+
+func TestSelfUpdate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("installs a shell script as the new binary")
+	}
+	key := serveRelease(t, "#!/bin/sh\necho 'cidrator version 9.9.9'\n")
+
+	out, err := runSelfUpdate(t, map[string]string{"check": "true"})
+	if err != nil || !strings.Contains(out, "Update available: dev -> 9.9.9") {
+		t.Fatalf("--check = %q, %v", out, err)
+	}
+
+	if _, err := runSelfUpdate(t, nil); errcode.Of(err) != errcode.CLIUsage {
+		t.Fatalf("expected CLI002 without a release key, got %v", err)
+	}
+	if _, err := runSelfUpdate(t, map[string]string{"channel": "nightly"}); errcode.Of(err) != errcode.CLIUsage {
+		t.Fatalf("expected CLI002 for an unknown channel, got %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "cidrator")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho 'cidrator version dev'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	originalPath := executablePath
+	executablePath = func() (string, error) { return path, nil }
+	t.Cleanup(func() { executablePath = originalPath })
+
+	out, err = runSelfUpdate(t, map[string]string{"public-key": key})
+	if err != nil {
+		t.Fatalf("self-update returned error: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Verified") || !strings.Contains(out, "from dev to 9.9.9") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "9.9.9") {
+		t.Fatalf("binary was not replaced: %q", data)
+	}
+
+	// A different key is rejected before anything is installed
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	otherKey := base64.StdEncoding.EncodeToString(append([]byte("Edtestkey!"), otherPub...))
+	if _, err := runSelfUpdate(t, map[string]string{"public-key": otherKey, "force": "true"}); errcode.Of(err) != errcode.CLISelfUpdate {
+		t.Fatalf("expected CLI008 for the wrong key, got %v", err)
+	}
+}
+
+func TestSelfUpdateIsAudited(t *testing.T) {
+	serveRelease(t, "#!/bin/sh\necho 'cidrator version 9.9.9'\n")
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditlog.SetPath(path)
+	t.Cleanup(func() { auditlog.SetPath("") })
+
+	if _, err := runSelfUpdate(t, map[string]string{"check": "true"}); err != nil {
+		t.Fatal(err)
+	}
+	// The wrong key fails verification only after every asset is downloaded
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	otherKey := base64.StdEncoding.EncodeToString(append([]byte("Edtestkey!"), otherPub...))
+	if _, err := runSelfUpdate(t, map[string]string{"public-key": otherKey}); errcode.Of(err) != errcode.CLISelfUpdate {
+		t.Fatalf("expected CLI008 for the wrong key, got %v", err)
+	}
+
+	entries, err := auditlog.ReadAll(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected a listing for each run and one entry for the downloads, got %+v", entries)
+	}
+	for _, i := range []int{0, 1} {
+		if len(entries[i].Targets) != 1 || !strings.HasSuffix(entries[i].Targets[0], "/repos/euan-cowie/cidrator/releases") {
+			t.Fatalf("entry %d should be the release listing, got %+v", i, entries[i])
+		}
+	}
+	if entries[2].Packets != 3 || len(entries[2].Targets) != 3 || !strings.Contains(entries[2].Targets[0], "/dl/") {
+		t.Fatalf("the downloads should be one entry of three requests, got %+v", entries[2])
+	}
+}
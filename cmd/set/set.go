@@ -0,0 +1,235 @@
+package set
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/prefixset"
+	"github.com/spf13/cobra"
+)
+
+// SetCmd represents the set command
+var SetCmd = &cobra.Command{
+	Use:   "set",
+	Short: "Manage saved prefix sets",
+	Long: `Save named prefix sets in the state directory so other commands can refer
+to them as @name.
+
+Sets are stored as the fewest CIDRs that cover their addresses, so adjacent
+and overlapping entries are merged on save. They are accepted by
+'cidrator cidr eval' and 'cidrator cidr contains'.`,
+}
+
+// createCmd represents the set create command
+var createCmd = &cobra.Command{
+	Use:   "create <NAME> [CIDR...]",
+	Short: "Save a new prefix set",
+	Long: `Create saves a prefix set from CIDRs and addresses given as arguments, read
+from a file with --from (one per line, # comments allowed; - reads stdin), or
+both.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runCreate,
+}
+
+// addCmd represents the set add command
+var addCmd = &cobra.Command{
+	Use:   "add <NAME> [CIDR...]",
+	Short: "Add prefixes to a saved set",
+	Long:  `Add merges CIDRs and addresses into an existing set.`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runAdd,
+}
+
+// listCmd represents the set list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved prefix sets",
+	Args:  cobra.NoArgs,
+	RunE:  runList,
+}
+
+// showCmd represents the set show command
+var showCmd = &cobra.Command{
+	Use:   "show <NAME>",
+	Short: "Print the prefixes in a saved set",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runShow,
+}
+
+// deleteCmd represents the set delete command
+var deleteCmd = &cobra.Command{
+	Use:   "delete <NAME>",
+	Short: "Delete a saved prefix set",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runDelete,
+}
+
+// stdin is where --from - reads, replaced in tests
+var stdin io.Reader = os.Stdin
+
+func init() {
+	SetCmd.AddCommand(createCmd)
+	SetCmd.AddCommand(addCmd)
+	SetCmd.AddCommand(listCmd)
+	SetCmd.AddCommand(showCmd)
+	SetCmd.AddCommand(deleteCmd)
+
+	createCmd.Flags().String("from", "", "Read prefixes from this file, one per line (- for stdin)")
+	createCmd.Flags().Bool("force", false, "Replace the set if it already exists")
+	addCmd.Flags().String("from", "", "Read prefixes from this file, one per line (- for stdin)")
+	listCmd.Flags().StringP("format", "f", "table", "Output format (table, json)")
+}
+
+func runCreate(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if err := prefixset.ValidateName(name); err != nil {
+		return err
+	}
+	force, _ := cmd.Flags().GetBool("force")
+	if !force {
+		if _, found, err := prefixset.Get(name); err != nil {
+			return err
+		} else if found {
+			return errcode.Errorf(errcode.CLIUsage, "set @%s already exists (use --force to replace it, or set add)", name)
+		}
+	}
+
+	set, err := readPrefixes(cmd, args[1:])
+	if err != nil {
+		return err
+	}
+	if set.IsEmpty() {
+		return errcode.Errorf(errcode.CLIUsage, "no prefixes given: pass CIDRs as arguments or use --from")
+	}
+	if err := prefixset.Put(name, set); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Saved @%s (%s)\n", name, prefixCount(len(set.Prefixes())))
+	return nil
+}
+
+func runAdd(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	existing, found, err := prefixset.Get(name)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errcode.Errorf(errcode.CIDRInvalidExpr, "unknown set @%s (create it with set create)", name)
+	}
+
+	added, err := readPrefixes(cmd, args[1:])
+	if err != nil {
+		return err
+	}
+	if added.IsEmpty() {
+		return errcode.Errorf(errcode.CLIUsage, "no prefixes given: pass CIDRs as arguments or use --from")
+	}
+	set := existing.Union(added)
+	if err := prefixset.Put(name, set); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Saved @%s (%s)\n", name, prefixCount(len(set.Prefixes())))
+	return nil
+}
+
+func runList(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	summaries, err := prefixset.List()
+	if err != nil {
+		return err
+	}
+
+	w := cmd.OutOrStdout()
+	switch format {
+	case "json":
+		output, err := json.MarshalIndent(summaries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to generate JSON: %v", err)
+		}
+		_, _ = fmt.Fprintln(w, string(output))
+	case "table":
+		if len(summaries) == 0 {
+			_, _ = fmt.Fprintln(w, "No saved sets")
+			return nil
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		defer func() { _ = tw.Flush() }()
+
+		_, _ = fmt.Fprintf(tw, "NAME\tPREFIXES\tUPDATED\n")
+		_, _ = fmt.Fprintf(tw, "----\t--------\t-------\n")
+		for _, summary := range summaries {
+			_, _ = fmt.Fprintf(tw, "@%s\t%d\t%s\n", summary.Name, summary.Prefixes, summary.Updated.Local().Format(time.RFC3339))
+		}
+	default:
+		return errcode.Errorf(errcode.CLIUnsupportedFormat, "unsupported output format: %s", format)
+	}
+	return nil
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	set, found, err := prefixset.Get(args[0])
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errcode.Errorf(errcode.CIDRInvalidExpr, "unknown set @%s", args[0])
+	}
+	for _, prefix := range set.Strings() {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), prefix)
+	}
+	return nil
+}
+
+func runDelete(cmd *cobra.Command, args []string) error {
+	found, err := prefixset.Delete(args[0])
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errcode.Errorf(errcode.CIDRInvalidExpr, "unknown set @%s", args[0])
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Deleted @%s\n", args[0])
+	return nil
+}
+
+// readPrefixes combines prefixes given as arguments with those in --from
+func readPrefixes(cmd *cobra.Command, entries []string) (*cidr.Set, error) {
+	set, err := cidr.ParseSet(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	from, _ := cmd.Flags().GetString("from")
+	if from == "" {
+		return set, nil
+	}
+	r := stdin
+	if from != "-" {
+		file, err := os.Open(from)
+		if err != nil {
+			return nil, errcode.Wrap(errcode.CLIUsage, fmt.Errorf("--from: %w", err))
+		}
+		defer func() { _ = file.Close() }()
+		r = file
+	}
+	fromFile, err := cidr.ReadSet(r)
+	if err != nil {
+		return nil, fmt.Errorf("--from %s: %w", from, err)
+	}
+	return set.Union(fromFile), nil
+}
+
+func prefixCount(n int) string {
+	if n == 1 {
+		return "1 prefix"
+	}
+	return fmt.Sprintf("%d prefixes", n)
+}
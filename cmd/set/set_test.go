@@ -0,0 +1,114 @@
+package set
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/store"
+	"github.com/spf13/pflag"
+)
+
+// runSet executes the set command with fresh flag values and returns its output
+func runSet(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	for _, sub := range SetCmd.Commands() {
+		sub.Flags().VisitAll(func(flag *pflag.Flag) {
+			_ = flag.Value.Set(flag.DefValue)
+			flag.Changed = false
+		})
+	}
+
+	var out bytes.Buffer
+	SetCmd.SetOut(&out)
+	SetCmd.SetArgs(args)
+	SetCmd.SilenceUsage = true
+	SetCmd.SilenceErrors = true
+	err := SetCmd.Execute()
+	return strings.TrimSpace(out.String()), err
+}
+
+func TestSetLifecycle(t *testing.T) {
+	store.Configure(store.BackendJSON, t.TempDir())
+	t.Cleanup(func() { store.Configure("", "") })
+
+	from := filepath.Join(t.TempDir(), "corp.txt")
+	if err := os.WriteFile(from, []byte("# offices\n10.0.0.0/16\n10.1.0.0/16\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if out, err := runSet(t, "create", "corp", "--from", from); err != nil || out != "Saved @corp (1 prefix)" {
+		t.Fatalf("create = %q, %v", out, err)
+	}
+	if _, err := runSet(t, "create", "corp", "192.0.2.0/24"); errcode.Of(err) != errcode.CLIUsage {
+		t.Fatalf("expected create to refuse an existing set, got %v", err)
+	}
+	if out, err := runSet(t, "add", "corp", "192.0.2.0/24"); err != nil || out != "Saved @corp (2 prefixes)" {
+		t.Fatalf("add = %q, %v", out, err)
+	}
+	if out, err := runSet(t, "show", "corp"); err != nil || out != "10.0.0.0/15\n192.0.2.0/24" {
+		t.Fatalf("show = %q, %v", out, err)
+	}
+
+	if _, err := runSet(t, "create", "lab", "203.0.113.0/24"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runSet(t, "list")
+	if err != nil || !strings.Contains(out, "@corp  2") || !strings.Contains(out, "@lab   1") {
+		t.Fatalf("list = %q, %v", out, err)
+	}
+	if out, err := runSet(t, "list", "--format", "json"); err != nil || !strings.Contains(out, `"name": "lab"`) {
+		t.Fatalf("list --format json = %q, %v", out, err)
+	}
+
+	if out, err := runSet(t, "create", "lab", "198.51.100.0/24", "--force"); err != nil || out != "Saved @lab (1 prefix)" {
+		t.Fatalf("create --force = %q, %v", out, err)
+	}
+	if out, err := runSet(t, "delete", "lab"); err != nil || out != "Deleted @lab" {
+		t.Fatalf("delete = %q, %v", out, err)
+	}
+	if _, err := runSet(t, "show", "lab"); errcode.Of(err) != errcode.CIDRInvalidExpr {
+		t.Fatalf("expected show of a deleted set to fail, got %v", err)
+	}
+}
+
+func TestSetInputErrors(t *testing.T) {
+	store.Configure(store.BackendJSON, t.TempDir())
+	t.Cleanup(func() { store.Configure("", "") })
+
+	tests := []struct {
+		name string
+		args []string
+		code errcode.Code
+	}{
+		{name: "invalid name", args: []string{"create", "corp-eu", "10.0.0.0/8"}, code: errcode.CLIUsage},
+		{name: "no prefixes", args: []string{"create", "empty"}, code: errcode.CLIUsage},
+		{name: "bad prefix", args: []string{"create", "bad", "10.0.0.0/33"}, code: errcode.CIDRInvalid},
+		{name: "missing file", args: []string{"create", "bad", "--from", filepath.Join(t.TempDir(), "nope.txt")}, code: errcode.CLIUsage},
+		{name: "add to unknown set", args: []string{"add", "nope", "10.0.0.0/8"}, code: errcode.CIDRInvalidExpr},
+		{name: "unsupported format", args: []string{"list", "--format", "yaml"}, code: errcode.CLIUnsupportedFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := runSet(t, tt.args...); errcode.Of(err) != tt.code {
+				t.Fatalf("expected %s, got %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestCreateFromStdin(t *testing.T) {
+	store.Configure(store.BackendJSON, t.TempDir())
+	t.Cleanup(func() { store.Configure("", "") })
+
+	original := stdin
+	t.Cleanup(func() { stdin = original })
+	stdin = strings.NewReader("2001:db8::/48\n")
+
+	if out, err := runSet(t, "create", "v6", "--from", "-"); err != nil || out != "Saved @v6 (1 prefix)" {
+		t.Fatalf("create --from - = %q, %v", out, err)
+	}
+}
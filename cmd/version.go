@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/euan-cowie/cidrator/cmd/mtu"
 	"github.com/spf13/cobra"
 )
 
@@ -12,18 +13,40 @@ var (
 	Date    = "unknown"
 )
 
+var mtuCapabilities = mtu.Capabilities
+
 // versionCmd represents the version command
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print the version number of cidrator",
-	Long:  `Print the version number, commit hash, and build date of cidrator.`,
+	Long: `Print the version number, commit hash, build date, and build profile of cidrator.
+
+With --capabilities, also report which MTU subsystems can run in this build and
+environment. Minimal builds disable the raw ICMP subsystems; full builds report
+them as unavailable when the process lacks the privileges to open raw sockets,
+except ICMP echo where an unprivileged datagram ICMP socket opens instead.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("cidrator version %s\n", Version)
-		fmt.Printf("Commit: %s\n", Commit)
-		fmt.Printf("Built: %s\n", Date)
+		w := cmd.OutOrStdout()
+		_, _ = fmt.Fprintf(w, "cidrator version %s\n", Version)
+		_, _ = fmt.Fprintf(w, "Commit: %s\n", Commit)
+		_, _ = fmt.Fprintf(w, "Built: %s\n", Date)
+		_, _ = fmt.Fprintf(w, "Profile: %s\n", buildProfile)
+
+		if showCapabilities, _ := cmd.Flags().GetBool("capabilities"); showCapabilities {
+			_, _ = fmt.Fprintln(w, "\nCapabilities:")
+			for _, capability := range mtuCapabilities() {
+				status := "available"
+				if capability.Available && capability.Reason != "" {
+					status = "available (" + capability.Reason + ")"
+				} else if !capability.Available {
+					status = "unavailable (" + capability.Reason + ")"
+				}
+				_, _ = fmt.Fprintf(w, "  %-13s %s\n", capability.Name, status)
+			}
+		}
 	},
 }
 
 func init() {
-	rootCmd.AddCommand(versionCmd)
+	versionCmd.Flags().Bool("capabilities", false, "Report which MTU subsystems can run in this build and environment")
 }
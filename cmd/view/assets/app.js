@@ -0,0 +1,159 @@
+"use strict";
+
+// Rows rendered per table; filtering and sorting still cover every row
+const pageSize = 500;
+const colors = ["#1f6feb", "#d73a49", "#28a745", "#6f42c1", "#e36209", "#0598bc"];
+
+const isNumber = (s) => s !== "" && !isNaN(Number(s));
+
+function compare(a, b) {
+  if (isNumber(a) && isNumber(b)) return Number(a) - Number(b);
+  return a.localeCompare(b, undefined, { numeric: true });
+}
+
+function element(tag, attrs, text) {
+  const el = document.createElement(tag);
+  for (const [name, value] of Object.entries(attrs || {})) el.setAttribute(name, value);
+  if (text !== undefined) el.textContent = text;
+  return el;
+}
+
+function svgElement(tag, attrs) {
+  const el = document.createElementNS("http://www.w3.org/2000/svg", tag);
+  for (const [name, value] of Object.entries(attrs)) el.setAttribute(name, value);
+  return el;
+}
+
+// renderTable draws a table with a filter box and sortable headers
+function renderTable(section, table, onChange) {
+  let sortColumn = -1;
+  let descending = false;
+  let filter = "";
+
+  const controls = element("div", { class: "controls" });
+  const input = element("input", { type: "search", placeholder: "Filter rows" });
+  const count = element("span");
+  controls.append(input, count);
+
+  const tableEl = element("table");
+  const head = element("tr");
+  table.columns.forEach((column, i) => {
+    const th = element("th", {}, column);
+    th.addEventListener("click", () => {
+      descending = sortColumn === i ? !descending : false;
+      sortColumn = i;
+      head.querySelectorAll("th").forEach((h) => h.classList.remove("asc", "desc"));
+      th.classList.add(descending ? "desc" : "asc");
+      draw();
+    });
+    head.append(th);
+  });
+  const thead = element("thead");
+  thead.append(head);
+  const tbody = element("tbody");
+  tableEl.append(thead, tbody);
+  section.append(controls, tableEl);
+
+  function visibleRows() {
+    const needle = filter.toLowerCase();
+    let rows = table.rows.filter((row) => row.some((cell) => cell.toLowerCase().includes(needle)));
+    if (sortColumn >= 0) {
+      rows = rows.slice().sort((a, b) => compare(a[sortColumn], b[sortColumn]) * (descending ? -1 : 1));
+    }
+    return rows;
+  }
+
+  function draw() {
+    const rows = visibleRows();
+    tbody.replaceChildren();
+    for (const row of rows.slice(0, pageSize)) {
+      const tr = element("tr");
+      for (const cell of row) tr.append(element("td", isNumber(cell) ? { class: "number" } : {}, cell));
+      tbody.append(tr);
+    }
+    const shown = Math.min(rows.length, pageSize);
+    count.textContent = rows.length === table.rows.length
+      ? `${table.rows.length} rows` + (shown < rows.length ? `, showing the first ${shown}` : "")
+      : `${rows.length} of ${table.rows.length} rows match` + (shown < rows.length ? `, showing the first ${shown}` : "");
+    if (onChange) onChange(rows);
+  }
+
+  input.addEventListener("input", () => {
+    filter = input.value;
+    draw();
+  });
+  draw();
+}
+
+// renderChart plots PMTU over time for each target of a history table
+function renderChart(container, table, rows) {
+  const col = (names) => table.columns.findIndex((c) => names.includes(c));
+  const timeCol = col(["timestamp", "time"]);
+  const pmtuCol = col(["pmtu"]);
+  const targetCol = col(["target"]);
+  container.replaceChildren();
+  if (timeCol < 0 || pmtuCol < 0) return;
+
+  const series = new Map();
+  for (const row of rows) {
+    const t = Date.parse(row[timeCol]);
+    const pmtu = Number(row[pmtuCol]);
+    if (isNaN(t) || !pmtu) continue;
+    const target = targetCol >= 0 ? row[targetCol] : "";
+    if (!series.has(target)) series.set(target, []);
+    series.get(target).push([t, pmtu]);
+  }
+  if (series.size === 0) return;
+
+  const width = 720, height = 180, pad = 44;
+  const points = [...series.values()].flat();
+  const minT = Math.min(...points.map((p) => p[0])), maxT = Math.max(...points.map((p) => p[0]));
+  const minV = Math.min(...points.map((p) => p[1])), maxV = Math.max(...points.map((p) => p[1]));
+  const x = (t) => pad + (maxT === minT ? 0 : (t - minT) / (maxT - minT)) * (width - 2 * pad);
+  const y = (v) => height - pad + (maxV === minV ? -(height - 2 * pad) / 2 : -((v - minV) / (maxV - minV)) * (height - 2 * pad));
+
+  const svg = svgElement("svg", { width, height, viewBox: `0 0 ${width} ${height}` });
+  svg.append(svgElement("line", { class: "axis", x1: pad, y1: height - pad, x2: width - pad, y2: height - pad }));
+  svg.append(svgElement("line", { class: "axis", x1: pad, y1: pad, x2: pad, y2: height - pad }));
+  for (const [value, label] of [[maxV, maxV], [minV, minV]]) {
+    const text = svgElement("text", { x: 4, y: y(value) + 4 });
+    text.textContent = label;
+    svg.append(text);
+  }
+  let i = 0;
+  for (const [target, pts] of series) {
+    const color = colors[i % colors.length];
+    pts.sort((a, b) => a[0] - b[0]);
+    svg.append(svgElement("polyline", { class: "series", stroke: color, points: pts.map((p) => `${x(p[0])},${y(p[1])}`).join(" ") }));
+    const legend = svgElement("text", { x: pad + 8 + (i % 4) * 170, y: 16 + Math.floor(i / 4) * 14, fill: color });
+    legend.textContent = target || "pmtu";
+    svg.append(legend);
+    i++;
+  }
+  container.append(svg);
+}
+
+async function main() {
+  const response = await fetch("api/tables");
+  const tables = await response.json();
+  const main = document.getElementById("tables");
+  const rows = tables.reduce((n, t) => n + t.rows.length, 0);
+  document.getElementById("summary").textContent = `${tables.length} tables, ${rows} rows. Click a column to sort it.`;
+
+  for (const table of tables) {
+    const section = element("section");
+    section.append(element("h2", {}, table.title), element("p", { class: "source" }, table.source));
+    main.append(section);
+    if (table.kind === "history") {
+      const chart = element("div");
+      section.append(chart);
+      renderTable(section, table, (visible) => renderChart(chart, table, visible));
+    } else {
+      renderTable(section, table);
+    }
+  }
+}
+
+main().catch((err) => {
+  document.getElementById("summary").textContent = `Failed to load results: ${err}`;
+});
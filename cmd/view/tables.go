@@ -0,0 +1,339 @@
+package view
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// Kinds of table, which decide how the page presents them
+const (
+	kindHops    = "hops"    // mtu discover --hops: one table per traced target
+	kindHistory = "history" // mtu watch samples: charted PMTU per target
+	kindResults = "results" // Anything else: one row per record
+)
+
+// table is one sortable, filterable table of the page
+type table struct {
+	Title   string     `json:"title"`
+	Kind    string     `json:"kind"`
+	Source  string     `json:"source"`
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
+}
+
+// object is a JSON object that remembers its key order, so columns appear in
+// the order the command printed them
+type object struct {
+	keys   []string
+	values map[string]any
+}
+
+func (o *object) get(key string) (any, bool) {
+	v, ok := o.values[key]
+	return v, ok
+}
+
+// builder collects the tables of one file
+type builder struct {
+	source  string
+	hops    []*table
+	history *table
+	results *table
+}
+
+// loadTables reads a results file: JSON, a JSON array, JSON lines as the
+// --json and --format jsonl outputs print them, or CSV such as mtu watch
+// --export writes
+func loadTables(source string, data []byte) ([]*table, error) {
+	b := &builder{source: source}
+	if isCSV(source, data) {
+		if err := b.addCSV(data); err != nil {
+			return nil, errcode.Errorf(errcode.CLIUsage, "%s: %w", source, err)
+		}
+		return b.tables(), nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	records := 0
+	for {
+		value, err := decodeValue(decoder)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, errcode.Errorf(errcode.CLIUsage, "%s: invalid JSON after record %d: %w", source, records, err)
+		}
+		list, ok := value.([]any)
+		if !ok {
+			list = []any{value}
+		}
+		for _, record := range list {
+			records++
+			b.add(record)
+		}
+	}
+	return b.tables(), nil
+}
+
+// isCSV reports whether a file holds CSV rather than JSON
+func isCSV(source string, data []byte) bool {
+	if strings.HasSuffix(strings.ToLower(source), ".csv") {
+		return true
+	}
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && trimmed[0] != '{' && trimmed[0] != '['
+}
+
+func (b *builder) tables() []*table {
+	var tables []*table
+	tables = append(tables, b.hops...)
+	if b.history != nil {
+		tables = append(tables, b.history)
+	}
+	if b.results != nil {
+		tables = append(tables, b.results)
+	}
+	return tables
+}
+
+// add sorts one record into the table it belongs in
+func (b *builder) add(record any) {
+	obj, ok := record.(*object)
+	if !ok {
+		b.addRow(&b.results, "Results", kindResults, []string{"value"}, []string{scalarString(record)})
+		return
+	}
+
+	if hops, ok := obj.get("hops"); ok {
+		if list, ok := hops.([]any); ok {
+			b.addHops(obj, list)
+			return
+		}
+	}
+
+	_, hasTimestamp := obj.get("timestamp")
+	if targets, ok := obj.get("targets"); ok && hasTimestamp {
+		// A fleet summary: one history row per target
+		if list, ok := targets.([]any); ok {
+			timestamp, _ := obj.get("timestamp")
+			for _, target := range list {
+				columns, values := flatten(target)
+				b.addRow(&b.history, "PMTU history", kindHistory, append([]string{"timestamp"}, columns...), append([]string{scalarString(timestamp)}, values...))
+			}
+			return
+		}
+	}
+	// A watch sample, or the error a watch probe ended with
+	_, hasPMTU := obj.get("pmtu")
+	_, hasTarget := obj.get("target")
+	if hasTimestamp && (hasPMTU || hasTarget) {
+		columns, values := flatten(obj)
+		b.addRow(&b.history, "PMTU history", kindHistory, columns, values)
+		return
+	}
+
+	columns, values := flatten(obj)
+	b.addRow(&b.results, "Results", kindResults, columns, values)
+}
+
+// addHops adds the hop table of one hop-by-hop result
+func (b *builder) addHops(result *object, hops []any) {
+	title := "Hops"
+	if target, ok := result.get("target"); ok {
+		title = "Hops to " + scalarString(target)
+	}
+	if pmtu, ok := result.get("final_pmtu"); ok {
+		title += " (PMTU " + scalarString(pmtu) + ")"
+	}
+
+	var t *table
+	for _, hop := range hops {
+		columns, values := flatten(hop)
+		b.addRow(&t, title, kindHops, columns, values)
+	}
+	if t == nil {
+		t = &table{Title: title, Kind: kindHops, Source: b.source}
+	}
+	b.hops = append(b.hops, t)
+}
+
+// addRow appends a row to *t, creating the table and widening its columns as
+// new fields appear
+func (b *builder) addRow(t **table, title, kind string, columns, values []string) {
+	if *t == nil {
+		*t = &table{Title: title, Kind: kind, Source: b.source}
+	}
+	tbl := *t
+	row := make([]string, len(tbl.Columns))
+	for i, column := range columns {
+		index := slices.Index(tbl.Columns, column)
+		if index < 0 {
+			tbl.Columns = append(tbl.Columns, column)
+			for j := range tbl.Rows {
+				tbl.Rows[j] = append(tbl.Rows[j], "")
+			}
+			row = append(row, "")
+			index = len(tbl.Columns) - 1
+		}
+		row[index] = values[i]
+	}
+	tbl.Rows = append(tbl.Rows, row)
+}
+
+// addCSV reads a CSV file with a header row. A file with time and pmtu
+// columns, as mtu watch --export writes, is PMTU history.
+func (b *builder) addCSV(data []byte) error {
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	columns := rows[0]
+	title, kind := "Results", kindResults
+	if slices.Contains(columns, "pmtu") && (slices.Contains(columns, "time") || slices.Contains(columns, "timestamp")) {
+		title, kind = "PMTU history", kindHistory
+	}
+	t := &table{Title: title, Kind: kind, Source: b.source, Columns: columns, Rows: rows[1:]}
+	if t.Rows == nil {
+		t.Rows = [][]string{}
+	}
+	if kind == kindHistory {
+		b.history = t
+	} else {
+		b.results = t
+	}
+	return nil
+}
+
+// flatten turns a record into columns and cell values. Nested objects become
+// dotted columns, and array elements are joined with commas, objects among
+// them as JSON.
+func flatten(v any) ([]string, []string) {
+	var columns, values []string
+	var walk func(prefix string, v any)
+	walk = func(prefix string, v any) {
+		switch v := v.(type) {
+		case *object:
+			for _, key := range v.keys {
+				name := key
+				if prefix != "" {
+					name = prefix + "." + key
+				}
+				walk(name, v.values[key])
+			}
+			return
+		case []any:
+			parts := make([]string, 0, len(v))
+			for _, element := range v {
+				parts = append(parts, scalarString(element))
+			}
+			columns, values = append(columns, columnName(prefix)), append(values, strings.Join(parts, ", "))
+			return
+		}
+		columns, values = append(columns, columnName(prefix)), append(values, scalarString(v))
+	}
+	walk("", v)
+	return columns, values
+}
+
+func columnName(prefix string) string {
+	if prefix == "" {
+		return "value"
+	}
+	return prefix
+}
+
+func scalarString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	}
+	data, _ := json.Marshal(toPlain(v))
+	return string(data)
+}
+
+// toPlain converts ordered objects back to maps for marshaling
+func toPlain(v any) any {
+	switch v := v.(type) {
+	case *object:
+		m := make(map[string]any, len(v.keys))
+		for _, key := range v.keys {
+			m[key] = toPlain(v.values[key])
+		}
+		return m
+	case []any:
+		out := make([]any, len(v))
+		for i, element := range v {
+			out[i] = toPlain(element)
+		}
+		return out
+	}
+	return v
+}
+
+// decodeValue reads the next JSON value, keeping the key order of objects.
+// It returns io.EOF only when no value is left, not for a truncated one.
+func decodeValue(decoder *json.Decoder) (any, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	value, err := decodeRest(decoder, token)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return value, err
+}
+
+// decodeRest reads the rest of the value starting with token
+func decodeRest(decoder *json.Decoder, token json.Token) (any, error) {
+	switch token {
+	case json.Delim('{'):
+		obj := &object{values: make(map[string]any)}
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := keyToken.(string)
+			value, err := decodeValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			if _, seen := obj.values[key]; !seen {
+				obj.keys = append(obj.keys, key)
+			}
+			obj.values[key] = value
+		}
+		_, err := decoder.Token()
+		return obj, err
+	case json.Delim('['):
+		list := []any{}
+		for decoder.More() {
+			value, err := decodeValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := decoder.Token()
+		return list, err
+	}
+	return token, nil
+}
@@ -0,0 +1,153 @@
+package view
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/family"
+	"github.com/spf13/cobra"
+)
+
+// assets is the page the viewer serves: plain HTML, CSS, and JavaScript with
+// no external requests, so it works offline
+//
+//go:embed assets
+var assets embed.FS
+
+// ViewCmd represents the view command
+var ViewCmd = &cobra.Command{
+	Use:   "view FILE...",
+	Short: "Browse result files in a local web page",
+	Long: `View serves a small web page on localhost for browsing saved results, with
+every table sortable by column and filterable as you type.
+
+Files may hold the JSON any command prints with --format json, JSON lines as
+--format jsonl and mtu watch --format json print them, or CSV such as mtu watch
+--export writes; - reads stdin. What the page shows depends on the
+records:
+
+- Hop-by-hop results (mtu discover --hops) become one hop table per target
+- mtu watch samples and fleet summaries become PMTU history, charted per target
+- Anything else, such as cidr expand or dns ptr-audit results, is one row per
+  record, with nested fields as dotted columns
+
+The page only listens on the loopback address and only answers requests for
+localhost, so other hosts and other web sites cannot read the results. It runs
+until Ctrl+C.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runView,
+}
+
+// viewContext ends when the viewer should stop; tests replace it
+var viewContext = func() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+func init() {
+	ViewCmd.Flags().Int("port", 0, "Port to serve on (0 = any free port)")
+}
+
+func runView(cmd *cobra.Command, args []string) error {
+	port, _ := cmd.Flags().GetInt("port")
+	if port < 0 || port > 65535 {
+		return errcode.Errorf(errcode.CLIUsage, "--port must be between 0 and 65535")
+	}
+
+	var tables []*table
+	for _, path := range args {
+		data, err := readResults(cmd, path)
+		if err != nil {
+			return err
+		}
+		fileTables, err := loadTables(path, data)
+		if err != nil {
+			return err
+		}
+		tables = append(tables, fileTables...)
+	}
+	if len(tables) == 0 {
+		return errcode.Errorf(errcode.CLIUsage, "no results in %d file(s)", len(args))
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(family.Loopback(), strconv.Itoa(port)))
+	if err != nil {
+		return fmt.Errorf("failed to start viewer: %w", err)
+	}
+	server := &http.Server{Handler: newHandler(tables), ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := viewContext()
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	rows := 0
+	for _, t := range tables {
+		rows += len(t.Rows)
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Serving %d tables (%d rows) at http://%s/\nPress Ctrl+C to stop\n", len(tables), rows, listener.Addr())
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("viewer stopped: %w", err)
+	}
+	return nil
+}
+
+func readResults(cmd *cobra.Command, path string) ([]byte, error) {
+	if path == "-" {
+		data, err := io.ReadAll(cmd.InOrStdin())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %w", err)
+		}
+		return data, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errcode.Wrap(errcode.CLIUsage, err)
+	}
+	return data, nil
+}
+
+// newHandler serves the page and, at /api/tables, the tables it shows
+func newHandler(tables []*table) http.Handler {
+	static, _ := fs.Sub(assets, "assets")
+	mux := http.NewServeMux()
+	mux.Handle("GET /", http.FileServerFS(static))
+	mux.HandleFunc("GET /api/tables", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(tables)
+	})
+	return localOnly(mux)
+}
+
+// localOnly rejects requests whose Host is not a loopback name, so a web
+// page elsewhere cannot read the results through DNS rebinding
+func localOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		ip := net.ParseIP(host)
+		if host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			http.Error(w, "cidrator view only answers requests for localhost", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "default-src 'self'")
+		next.ServeHTTP(w, r)
+	})
+}
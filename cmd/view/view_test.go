@@ -0,0 +1,180 @@
+package view
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+func TestLoadTables(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		data    string
+		titles  []string
+		kinds   []string
+		columns []string // of the last table
+		rows    int      // of the last table
+	}{
+		{
+			name:    "hops",
+			source:  "hops.json",
+			data:    `{"target":"192.0.2.1","final_pmtu":1400,"hops":[{"hop":1,"addr":"198.51.100.1","mtu":1500},{"hop":2,"timeout":true}]}`,
+			titles:  []string{"Hops to 192.0.2.1 (PMTU 1400)"},
+			kinds:   []string{kindHops},
+			columns: []string{"hop", "addr", "mtu", "timeout"},
+			rows:    2,
+		},
+		{
+			name:    "watch samples",
+			source:  "watch.jsonl",
+			data:    "{\"timestamp\":\"2026-10-18T09:30:00Z\",\"target\":\"a\",\"pmtu\":1500}\n{\"timestamp\":\"2026-10-18T09:31:00Z\",\"target\":\"a\",\"code\":\"MTU011\",\"error\":\"timeout\"}\n",
+			titles:  []string{"PMTU history"},
+			kinds:   []string{kindHistory},
+			columns: []string{"timestamp", "target", "pmtu", "code", "error"},
+			rows:    2,
+		},
+		{
+			name:    "fleet summary",
+			source:  "fleet.jsonl",
+			data:    `{"timestamp":"2026-10-18T09:30:00Z","targets":[{"target":"a","pmtu":1500},{"target":"b","pmtu":1400}]}`,
+			titles:  []string{"PMTU history"},
+			kinds:   []string{kindHistory},
+			columns: []string{"timestamp", "target", "pmtu"},
+			rows:    2,
+		},
+		{
+			name:    "array with nested fields",
+			source:  "audit.json",
+			data:    `[{"ip":"10.0.0.1","ptr":{"name":"a.example","ok":true},"tags":["x","y"]},{"ip":"10.0.0.2"}]`,
+			titles:  []string{"Results"},
+			kinds:   []string{kindResults},
+			columns: []string{"ip", "ptr.name", "ptr.ok", "tags"},
+			rows:    2,
+		},
+		{
+			name:    "watch export",
+			source:  "soak.csv",
+			data:    "time,target,pmtu\n2026-10-18T09:30:00Z,a,1500\n",
+			titles:  []string{"PMTU history"},
+			kinds:   []string{kindHistory},
+			columns: []string{"time", "target", "pmtu"},
+			rows:    1,
+		},
+		{
+			name:    "hops and results in one stream",
+			source:  "-",
+			data:    "{\"target\":\"b\",\"hops\":[]}\n\"loose\"\n",
+			titles:  []string{"Hops to b", "Results"},
+			kinds:   []string{kindHops, kindResults},
+			columns: []string{"value"},
+			rows:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tables, err := loadTables(tt.source, []byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var titles, kinds []string
+			for _, table := range tables {
+				titles, kinds = append(titles, table.Title), append(kinds, table.Kind)
+			}
+			if !slices.Equal(titles, tt.titles) || !slices.Equal(kinds, tt.kinds) {
+				t.Fatalf("tables = %v %v, want %v %v", titles, kinds, tt.titles, tt.kinds)
+			}
+			last := tables[len(tables)-1]
+			if !slices.Equal(last.Columns, tt.columns) || len(last.Rows) != tt.rows {
+				t.Errorf("columns = %v with %d rows, want %v with %d", last.Columns, len(last.Rows), tt.columns, tt.rows)
+			}
+			for _, row := range last.Rows {
+				if len(row) != len(last.Columns) {
+					t.Errorf("row %v does not match columns %v", row, last.Columns)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadTablesInvalidJSON(t *testing.T) {
+	_, err := loadTables("bad.json", []byte("{\"a\":1}\n{\"b\":"))
+	if errcode.Of(err) != errcode.CLIUsage || !strings.Contains(err.Error(), "bad.json: invalid JSON after record 1") {
+		t.Errorf("loadTables = %v, want a usage error after record 1", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tables, err := loadTables("hops.json", []byte(`{"target":"a","hops":[{"hop":1}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := newHandler(tables)
+
+	get := func(host, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("127.0.0.1:8080", "/"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "app.js") {
+		t.Errorf("GET / = %d %q, want the page", rec.Code, rec.Body.String())
+	}
+	for _, asset := range []string{"/app.js", "/style.css"} {
+		if rec := get("localhost:8080", asset); rec.Code != http.StatusOK {
+			t.Errorf("GET %s = %d, want 200", asset, rec.Code)
+		}
+	}
+
+	rec := get("[::1]:8080", "/api/tables")
+	var got []table
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || len(got) != 1 || got[0].Title != "Hops to a" {
+		t.Errorf("GET /api/tables = %q (%v), want the hop table", rec.Body.String(), err)
+	}
+
+	// A rebound name resolving to loopback is still refused
+	for _, host := range []string{"attacker.example:8080", "192.0.2.1"} {
+		if rec := get(host, "/api/tables"); rec.Code != http.StatusForbidden {
+			t.Errorf("GET with Host %s = %d, want 403", host, rec.Code)
+		}
+	}
+}
+
+func TestRunView(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watch.jsonl")
+	if err := os.WriteFile(path, []byte(`{"timestamp":"2026-10-18T09:30:00Z","target":"a","pmtu":1500}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	original := viewContext
+	t.Cleanup(func() { viewContext = original })
+	viewContext = func() (context.Context, context.CancelFunc) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return ctx, cancel
+	}
+
+	var out bytes.Buffer
+	ViewCmd.SetOut(&out)
+	t.Cleanup(func() { ViewCmd.SetOut(nil) })
+	if err := runView(ViewCmd, []string{path}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Serving 1 tables (1 rows) at http://") {
+		t.Errorf("output = %q, want the viewer address", out.String())
+	}
+
+	if err := runView(ViewCmd, []string{filepath.Join(t.TempDir(), "missing.json")}); errcode.Of(err) != errcode.CLIUsage {
+		t.Errorf("missing file: %v, want a usage error", err)
+	}
+}
@@ -0,0 +1,129 @@
+// Package audit records invocations of commands that put packets on the wire
+// to an append-only, line-delimited JSON log.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// ErrNotConfigured is returned when the audit log is read without a path
+var ErrNotConfigured = errcode.Wrap(errcode.CLIAuditLog, errors.New("no audit log configured (set audit-log in the config file or pass --audit-log)"))
+
+// logPath is the audit log location; empty disables logging
+var logPath string
+
+// Entry is one audited invocation
+type Entry struct {
+	Time       time.Time `json:"time"`
+	User       string    `json:"user"`
+	SudoUser   string    `json:"sudo_user,omitempty"`
+	Host       string    `json:"host"`
+	PID        int       `json:"pid"`
+	Command    string    `json:"command"`
+	Args       []string  `json:"args"`
+	Targets    []string  `json:"targets"`
+	Protocol   string    `json:"protocol,omitempty"`
+	Packets    int       `json:"packets,omitempty"`          // Packets or queries planned up front (0 = not known in advance)
+	IntervalMS int64     `json:"interval_ms,omitempty"`      // Set for repeating commands; Packets is per cycle
+	Deprecated []string  `json:"deprecated_flags,omitempty"` // Deprecated flags the invocation used
+}
+
+// SetPath enables audit logging to path, or disables it when path is empty
+func SetPath(path string) {
+	logPath = path
+}
+
+// Path returns the configured audit log location
+func Path() string {
+	return logPath
+}
+
+// Enabled reports whether invocations are being audited
+func Enabled() bool {
+	return logPath != ""
+}
+
+// Record fills in who, where, and when for entry and appends it to the
+// configured log. It is a no-op when auditing is disabled.
+func Record(entry Entry) error {
+	if !Enabled() {
+		return nil
+	}
+
+	entry.Time = time.Now().UTC()
+	entry.User = currentUser()
+	entry.SudoUser = os.Getenv("SUDO_USER")
+	entry.Host, _ = os.Hostname()
+	entry.PID = os.Getpid()
+	if len(os.Args) > 1 && entry.Args == nil {
+		entry.Args = os.Args[1:]
+	}
+
+	return Append(logPath, entry)
+}
+
+// Append writes entry as a single JSON line at the end of the log at path
+func Append(path string, entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return errcode.Errorf(errcode.CLIAuditLog, "audit log: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return errcode.Errorf(errcode.CLIAuditLog, "audit log: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		_ = file.Close()
+		return errcode.Errorf(errcode.CLIAuditLog, "audit log: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return errcode.Errorf(errcode.CLIAuditLog, "audit log: %w", err)
+	}
+	return nil
+}
+
+// ReadAll returns every entry in the log at path, oldest first
+func ReadAll(path string) ([]Entry, error) {
+	if path == "" {
+		return nil, ErrNotConfigured
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errcode.Errorf(errcode.CLIAuditLog, "audit log: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, errcode.Errorf(errcode.CLIAuditLog, "audit log %s line %d: %w", path, lineNo, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errcode.Errorf(errcode.CLIAuditLog, "audit log: %w", err)
+	}
+	return entries, nil
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
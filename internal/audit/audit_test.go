@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordDisabledIsNoop(t *testing.T) {
+	SetPath("")
+	if err := Record(Entry{Command: "cidrator mtu discover"}); err != nil {
+		t.Fatalf("Record returned error while disabled: %v", err)
+	}
+	if _, err := ReadAll(Path()); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("expected ErrNotConfigured, got %v", err)
+	}
+}
+
+func TestRecordAppendsEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	SetPath(path)
+	t.Cleanup(func() { SetPath("") })
+
+	if err := Record(Entry{Command: "cidrator mtu discover", Args: []string{"mtu", "discover", "192.0.2.1"}, Targets: []string{"192.0.2.1"}, Protocol: "icmp", Packets: 14}); err != nil {
+		t.Fatalf("Record returned error: %v", err)
+	}
+	if err := Record(Entry{Command: "cidrator dns ptr-audit", Targets: []string{"192.0.2.0/30"}, Packets: 4}); err != nil {
+		t.Fatalf("Record returned error: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("audit log not created: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("unexpected audit log permissions: %v", info.Mode().Perm())
+	}
+
+	entries, err := ReadAll(path)
+	if err != nil {
+		t.Fatalf("ReadAll returned error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	first := entries[0]
+	if first.Command != "cidrator mtu discover" || first.Packets != 14 || first.Targets[0] != "192.0.2.1" {
+		t.Fatalf("unexpected first entry: %+v", first)
+	}
+	if first.Time.IsZero() || first.User == "" || first.PID != os.Getpid() {
+		t.Fatalf("expected who/when to be filled in: %+v", first)
+	}
+	if entries[1].Command != "cidrator dns ptr-audit" {
+		t.Fatalf("unexpected second entry: %+v", entries[1])
+	}
+}
+
+func TestReadAllRejectsCorruptLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if err := os.WriteFile(path, []byte("{\"command\":\"ok\"}\nnot json\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadAll(path); err == nil {
+		t.Fatal("expected error for corrupt line")
+	}
+}
@@ -0,0 +1,96 @@
+package cidr
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// Allocation strategies
+const (
+	// StrategyFirstFit takes the lowest free subnets in address order
+	StrategyFirstFit = "first-fit"
+	// StrategyBestFit takes subnets from the smallest free blocks that fit,
+	// keeping large blocks whole for later allocations
+	StrategyBestFit = "best-fit"
+)
+
+// AllocationOptions holds configuration for subnet allocation
+type AllocationOptions struct {
+	Prefix   int    // Prefix length of each allocated subnet
+	Count    int    // Number of subnets to allocate (0 = 1)
+	Strategy string // StrategyFirstFit or StrategyBestFit (empty = first-fit)
+}
+
+// Allocate returns the next free subnets of length opts.Prefix inside
+// supernet that overlap nothing in used. Used prefixes outside the supernet
+// are ignored; used may be nil. Subnets are returned in address order.
+func Allocate(supernet string, used *Set, opts AllocationOptions) ([]string, error) {
+	network, err := netip.ParsePrefix(strings.TrimSpace(supernet))
+	if err != nil {
+		return nil, NewCIDRError("allocate", supernet, ErrInvalidCIDR)
+	}
+	network = network.Masked()
+
+	switch {
+	case opts.Prefix > network.Addr().BitLen():
+		return nil, NewValidationError("prefix", fmt.Sprintf("/%d", opts.Prefix), ErrInvalidCIDR)
+	case opts.Prefix < network.Bits():
+		return nil, NewValidationError("prefix", fmt.Sprintf("/%d", opts.Prefix), ErrInvalidPrefix)
+	case opts.Count < 0:
+		return nil, NewValidationError("count", fmt.Sprintf("%d", opts.Count), ErrInvalidParts)
+	}
+	count := max(opts.Count, 1)
+
+	free := &Set{ranges: []addrRange{{network.Addr(), lastAddr(network)}}}
+	if used != nil {
+		free = free.Difference(used)
+	}
+
+	// Every free aligned subnet lies inside one block of the minimal cover
+	var blocks []netip.Prefix
+	for _, block := range free.Prefixes() {
+		if block.Bits() <= opts.Prefix {
+			blocks = append(blocks, block)
+		}
+	}
+
+	switch opts.Strategy {
+	case "", StrategyFirstFit:
+	case StrategyBestFit:
+		// Longest prefix (smallest block) first; SortStableFunc keeps address order within a size
+		slices.SortStableFunc(blocks, func(a, b netip.Prefix) int { return b.Bits() - a.Bits() })
+	default:
+		return nil, errcode.Errorf(errcode.CLIUsage, "unknown allocation strategy %q (use %s or %s)", opts.Strategy, StrategyFirstFit, StrategyBestFit)
+	}
+
+	var allocated []netip.Prefix
+	for _, block := range blocks {
+		last := lastAddr(block)
+		for addr := block.Addr(); len(allocated) < count; {
+			subnet := netip.PrefixFrom(addr, opts.Prefix)
+			allocated = append(allocated, subnet)
+			end := lastAddr(subnet)
+			if end == last {
+				break
+			}
+			addr = end.Next()
+		}
+		if len(allocated) == count {
+			break
+		}
+	}
+	if len(allocated) < count {
+		return nil, errcode.Errorf(errcode.CIDRExhausted, "only %d free /%d subnets in %s, %d requested", len(allocated), opts.Prefix, network, count)
+	}
+
+	slices.SortFunc(allocated, func(a, b netip.Prefix) int { return a.Addr().Compare(b.Addr()) })
+	subnets := make([]string, len(allocated))
+	for i, subnet := range allocated {
+		subnets[i] = subnet.String()
+	}
+	return subnets, nil
+}
@@ -0,0 +1,96 @@
+package cidr
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name     string
+		supernet string
+		used     []string
+		opts     AllocationOptions
+		want     []string
+	}{
+		{
+			name:     "first fit skips used subnets",
+			supernet: "10.0.0.0/16",
+			used:     []string{"10.0.0.0/24", "10.0.1.0/25", "10.0.3.0/24"},
+			opts:     AllocationOptions{Prefix: 24, Count: 3},
+			want:     []string{"10.0.2.0/24", "10.0.4.0/24", "10.0.5.0/24"},
+		},
+		{
+			name:     "best fit fills the smallest hole",
+			supernet: "10.0.0.0/22",
+			used:     []string{"10.0.0.0/25", "10.0.1.0/24"},
+			opts:     AllocationOptions{Prefix: 25, Count: 1, Strategy: StrategyBestFit},
+			want:     []string{"10.0.0.128/25"},
+		},
+		{
+			name:     "best fit keeps the large block whole",
+			supernet: "10.0.0.0/22",
+			used:     []string{"10.0.2.0/25", "10.0.3.0/24"},
+			opts:     AllocationOptions{Prefix: 26, Count: 2, Strategy: StrategyBestFit},
+			want:     []string{"10.0.2.128/26", "10.0.2.192/26"},
+		},
+		{
+			name:     "first fit takes the lowest addresses",
+			supernet: "10.0.0.0/22",
+			used:     []string{"10.0.2.0/25", "10.0.3.0/24"},
+			opts:     AllocationOptions{Prefix: 26, Count: 2},
+			want:     []string{"10.0.0.0/26", "10.0.0.64/26"},
+		},
+		{
+			name:     "used prefixes outside the supernet are ignored",
+			supernet: "192.168.0.0/24",
+			used:     []string{"10.0.0.0/8", "192.168.0.0/26"},
+			opts:     AllocationOptions{Prefix: 26},
+			want:     []string{"192.168.0.64/26"},
+		},
+		{
+			name:     "IPv6",
+			supernet: "2001:db8::/48",
+			used:     []string{"2001:db8::/64"},
+			opts:     AllocationOptions{Prefix: 64, Count: 2},
+			want:     []string{"2001:db8:0:1::/64", "2001:db8:0:2::/64"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Allocate(tt.supernet, mustParseSet(t, tt.used...), tt.opts)
+			if err != nil {
+				t.Fatalf("Allocate returned error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Allocate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocateErrors(t *testing.T) {
+	full := mustParseSet(t, "10.0.0.0/24", "10.0.2.0/23")
+	tests := []struct {
+		name     string
+		supernet string
+		opts     AllocationOptions
+		want     errcode.Code
+	}{
+		{name: "not enough space", supernet: "10.0.0.0/22", opts: AllocationOptions{Prefix: 24, Count: 2}, want: errcode.CIDRExhausted},
+		{name: "prefix shorter than the supernet", supernet: "10.0.0.0/22", opts: AllocationOptions{Prefix: 16}, want: errcode.CIDRInvalid},
+		{name: "prefix beyond the address length", supernet: "10.0.0.0/22", opts: AllocationOptions{Prefix: 33}, want: errcode.CIDRInvalid},
+		{name: "invalid supernet", supernet: "10.0.0.0", opts: AllocationOptions{Prefix: 24}, want: errcode.CIDRInvalid},
+		{name: "negative count", supernet: "10.0.0.0/22", opts: AllocationOptions{Prefix: 24, Count: -1}, want: errcode.CIDRInvalidParts},
+		{name: "unknown strategy", supernet: "10.0.0.0/22", opts: AllocationOptions{Prefix: 24, Strategy: "worst-fit"}, want: errcode.CLIUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Allocate(tt.supernet, full, tt.opts); errcode.Of(err) != tt.want {
+				t.Fatalf("expected %s, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -2,13 +2,18 @@ package cidr
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
-	"math"
+	"iter"
 	"math/big"
+	"math/bits"
 	"net"
+	"net/netip"
+	"slices"
 	"strings"
 
+	"github.com/euan-cowie/cidrator/internal/errcode"
 	"gopkg.in/yaml.v3"
 )
 
@@ -22,18 +27,27 @@ const (
 	HostRoutePrefixV6       = 128 // /128 host routes
 )
 
+// MaxDivideSubnets is the most subnets Divide returns at once by prefix
+// length; DivideStream has no limit
+const MaxDivideSubnets = 1 << 16
+
 // ExpansionOptions holds configuration for IP address expansion
 type ExpansionOptions struct {
-	Limit int // Maximum number of IPs to expand (0 = no limit)
+	Limit           int  // Maximum number of IPs to expand (0 = no limit)
+	ExcludeReserved bool // Skip addresses in reserved special-purpose blocks
 }
 
 // DivisionOptions holds configuration for subnet division
 type DivisionOptions struct {
-	Parts int // Number of parts to divide the network into
+	Parts  int // Number of parts to divide the network into
+	Prefix int // Prefix length of each subnet (0 = derive it from Parts)
 }
 
-// NetworkInfo represents detailed information about a CIDR network
+// NetworkInfo represents detailed information about a CIDR network. Prefix
+// is the canonical form; the net.IP fields are kept for callers that still
+// use the net package types.
 type NetworkInfo struct {
+	Prefix          netip.Prefix // Masked network prefix
 	Network         *net.IPNet
 	IP              net.IP
 	BaseAddress     net.IP
@@ -42,6 +56,9 @@ type NetworkInfo struct {
 	LastUsable      net.IP
 	Netmask         net.IP
 	HostMask        net.IP
+	WildcardMask    net.IP // Cisco ACL wildcard, the host mask of an IPv4 network (nil for IPv6)
+	HexNetmask      string // Netmask as 0x-prefixed hex, e.g. 0xffffff00
+	BinaryPrefix    string // Base address in binary with | between network and host bits
 	PrefixLength    int
 	HostBits        int
 	TotalAddresses  *big.Int
@@ -57,6 +74,9 @@ type NetworkInfoOutput struct {
 	LastUsable      string `json:"last_usable" yaml:"last_usable"`
 	Netmask         string `json:"netmask" yaml:"netmask"`
 	HostMask        string `json:"host_mask,omitempty" yaml:"host_mask,omitempty"`
+	WildcardMask    string `json:"wildcard_mask,omitempty" yaml:"wildcard_mask,omitempty"`
+	HexNetmask      string `json:"hex_netmask" yaml:"hex_netmask"`
+	BinaryPrefix    string `json:"binary_prefix" yaml:"binary_prefix"`
 	PrefixLength    int    `json:"prefix_length" yaml:"prefix_length"`
 	HostBits        int    `json:"host_bits" yaml:"host_bits"`
 	TotalAddresses  string `json:"total_addresses" yaml:"total_addresses"`
@@ -71,6 +91,8 @@ func (info *NetworkInfo) ToOutput() *NetworkInfoOutput {
 		FirstUsable:     info.FirstUsable.String(),
 		LastUsable:      info.LastUsable.String(),
 		Netmask:         info.Netmask.String(),
+		HexNetmask:      info.HexNetmask,
+		BinaryPrefix:    info.BinaryPrefix,
 		PrefixLength:    info.PrefixLength,
 		HostBits:        info.HostBits,
 		TotalAddresses:  FormatBigInt(info.TotalAddresses),
@@ -85,6 +107,9 @@ func (info *NetworkInfo) ToOutput() *NetworkInfoOutput {
 		if info.HostMask != nil {
 			output.HostMask = info.HostMask.String()
 		}
+		if info.WildcardMask != nil {
+			output.WildcardMask = info.WildcardMask.String()
+		}
 	}
 
 	return output
@@ -112,54 +137,55 @@ func (info *NetworkInfo) ToYAML() (string, error) {
 
 // ParseCIDR parses a CIDR string and returns network information
 func ParseCIDR(cidr string) (*NetworkInfo, error) {
-	ip, network, err := net.ParseCIDR(cidr)
+	prefix, err := netip.ParsePrefix(cidr)
 	if err != nil {
 		return nil, NewCIDRError("parse", cidr, ErrInvalidCIDR)
 	}
+	network := prefix.Masked()
+	bitLen := network.Addr().BitLen()
 
 	info := &NetworkInfo{
-		Network:      network,
-		IP:           ip,
-		BaseAddress:  network.IP,
-		PrefixLength: getPrefixLength(network),
-		IsIPv6:       ip.To4() == nil,
-	}
-
-	if info.IsIPv6 {
-		return configureIPv6Network(info, network)
+		Prefix:       network,
+		IP:           ipFromAddr(prefix.Addr()),
+		BaseAddress:  ipFromAddr(network.Addr()),
+		PrefixLength: network.Bits(),
+		HostBits:     bitLen - network.Bits(),
+		IsIPv6:       !network.Addr().Is4(),
 	}
-	return configureIPv4Network(info, network)
-}
-
-// configureIPv6Network configures network information for IPv6 networks
-func configureIPv6Network(info *NetworkInfo, network *net.IPNet) (*NetworkInfo, error) {
-	info.HostBits = IPv6Bits - info.PrefixLength
 	info.TotalAddresses = calculateTotalAddresses(info.HostBits)
 	info.UsableAddresses = calculateUsableAddresses(info.TotalAddresses, info.HostBits)
 
-	mask := net.CIDRMask(info.PrefixLength, IPv6Bits)
+	mask := net.CIDRMask(info.PrefixLength, bitLen)
+	info.Network = &net.IPNet{IP: info.BaseAddress, Mask: mask}
 	info.Netmask = net.IP(mask)
 	info.HostMask = getHostMask(info.Netmask)
-	info.FirstUsable = info.BaseAddress
-	info.LastUsable = getLastIPv6(network)
+	info.HexNetmask = fmt.Sprintf("0x%x", []byte(mask))
+	info.BinaryPrefix = binaryPrefix(network)
 
+	first, last := usableRange(network)
+	info.FirstUsable = ipFromAddr(first)
+	info.LastUsable = ipFromAddr(last)
+	if !info.IsIPv6 {
+		info.BroadcastAddr = ipFromAddr(lastAddr(network))
+		info.WildcardMask = info.HostMask
+	}
 	return info, nil
 }
 
-// configureIPv4Network configures network information for IPv4 networks
-func configureIPv4Network(info *NetworkInfo, network *net.IPNet) (*NetworkInfo, error) {
-	info.HostBits = IPv4Bits - info.PrefixLength
-	info.TotalAddresses = calculateTotalAddresses(info.HostBits)
-	info.UsableAddresses = calculateUsableAddresses(info.TotalAddresses, info.HostBits)
-
-	mask := net.CIDRMask(info.PrefixLength, IPv4Bits)
-	info.Netmask = net.IP(mask)
-	info.HostMask = getHostMask(info.Netmask)
-	info.BroadcastAddr = getBroadcastAddress(network)
-	info.FirstUsable = getFirstUsable(network)
-	info.LastUsable = getLastUsable(network)
+// ipFromAddr converts addr to a net.IP of its natural length (4 or 16 bytes)
+func ipFromAddr(addr netip.Addr) net.IP {
+	return net.IP(addr.AsSlice())
+}
 
-	return info, nil
+// usableRange returns the first and last host addresses of network. IPv4
+// networks larger than /31 lose their network and broadcast addresses; /31
+// point-to-point links, /32 host routes, and IPv6 use every address.
+func usableRange(network netip.Prefix) (first, last netip.Addr) {
+	first, last = network.Addr(), lastAddr(network)
+	if network.Addr().Is4() && network.Bits() < MinPointToPointPrefixV4 {
+		first, last = first.Next(), last.Prev()
+	}
+	return first, last
 }
 
 // calculateTotalAddresses calculates total addresses for given host bits
@@ -178,8 +204,9 @@ func calculateUsableAddresses(totalAddresses *big.Int, hostBits int) *big.Int {
 
 // ExpandResult contains either an IP string or an error from streaming expansion
 type ExpandResult struct {
-	IP  string
-	Err error
+	IP    string
+	Index uint64 // Offset of IP from the start of the range
+	Err   error
 }
 
 // Expand streams all IP addresses in a CIDR range through a channel.
@@ -193,7 +220,7 @@ func Expand(ctx context.Context, cidr string, opts ExpansionOptions) <-chan Expa
 	go func() {
 		defer close(ch)
 
-		_, network, err := net.ParseCIDR(cidr)
+		network, err := netip.ParsePrefix(cidr)
 		if err != nil {
 			select {
 			case ch <- ExpandResult{Err: NewCIDRError("expand", cidr, ErrInvalidCIDR)}:
@@ -202,53 +229,112 @@ func Expand(ctx context.Context, cidr string, opts ExpansionOptions) <-chan Expa
 			return
 		}
 
-		currentIP := make(net.IP, len(network.IP))
-		copy(currentIP, network.IP)
-
-		count := 0
-		for network.Contains(currentIP) {
-			// Check limit if specified
-			if opts.Limit > 0 && count >= opts.Limit {
-				return
+		var (
+			batch   textBatch
+			addrs   = make([]netip.Addr, 0, formatBatchSize)
+			indexes = make([]uint64, 0, formatBatchSize)
+			strs    []string
+			flushed = func() bool {
+				strs = formatAll(&batch, strs[:0], slices.Values(addrs), len(addrs))
+				for i, ip := range strs {
+					// Try to send, but respect context cancellation to avoid goroutine leak
+					select {
+					case ch <- ExpandResult{IP: ip, Index: indexes[i]}:
+					case <-ctx.Done():
+						return false
+					}
+				}
+				addrs, indexes = addrs[:0], indexes[:0]
+				return true
 			}
-
-			// Try to send, but respect context cancellation to avoid goroutine leak
-			select {
-			case ch <- ExpandResult{IP: currentIP.String()}:
-			case <-ctx.Done():
+		)
+		for index, addr := range expansion(network, opts) {
+			addrs = append(addrs, addr)
+			indexes = append(indexes, index)
+			if len(addrs) == formatBatchSize && !flushed() {
 				return
 			}
-			count++
-			incrementIP(currentIP)
 		}
+		flushed()
 	}()
 
 	return ch
 }
 
-// incrementIP increments an IP address by 1
-func incrementIP(ip net.IP) {
-	for i := len(ip) - 1; i >= 0; i-- {
-		ip[i]++
-		if ip[i] != 0 {
-			break
+// expansion yields the addresses Expand sends, with their offsets from the
+// start of network. Reserved blocks are skipped whole rather than address by
+// address, so excluding 0.0.0.0/8 from 0.0.0.0/0 costs nothing.
+func expansion(network netip.Prefix, opts ExpansionOptions) iter.Seq2[uint64, netip.Addr] {
+	if !opts.ExcludeReserved {
+		return Addrs(network, opts.Limit)
+	}
+	return func(yield func(uint64, netip.Addr) bool) {
+		network = network.Masked()
+		sent := 0
+		for _, block := range ExcludeReserved(network).Prefixes() {
+			limit := 0
+			if opts.Limit > 0 {
+				limit = opts.Limit - sent
+			}
+			base := addrOffset(network.Addr(), block.Addr())
+			for index, addr := range Addrs(block, limit) {
+				if !yield(base+index, addr) {
+					return
+				}
+				sent++
+			}
+			if opts.Limit > 0 && sent >= opts.Limit {
+				return
+			}
+		}
+	}
+}
+
+// addrOffset returns to minus from, modulo 2^64 as Addrs counts
+func addrOffset(from, to netip.Addr) uint64 {
+	a, b := from.As16(), to.As16()
+	return binary.BigEndian.Uint64(b[8:]) - binary.BigEndian.Uint64(a[8:])
+}
+
+// Addrs yields every address of network in order with its offset from the
+// start, stopping after limit addresses when limit is positive. Iteration ends
+// at the last address of the range, so it never wraps past the end of the
+// address space, and it does not allocate.
+func Addrs(network netip.Prefix, limit int) iter.Seq2[uint64, netip.Addr] {
+	return func(yield func(uint64, netip.Addr) bool) {
+		if !network.IsValid() {
+			return
+		}
+		network = network.Masked()
+		last := lastAddr(network)
+		addr := network.Addr()
+		for index := uint64(0); limit <= 0 || index < uint64(limit); index++ {
+			if !yield(index, addr) || addr == last {
+				return
+			}
+			addr = addr.Next()
 		}
 	}
 }
 
-// Contains checks if an IP address is within the CIDR range
+// Contains checks if an IP address is within the CIDR range. IPv4-mapped IPv6
+// addresses match IPv4 ranges, as they do with net.IPNet.
 func Contains(cidr, ipStr string) (bool, error) {
-	_, network, err := net.ParseCIDR(cidr)
+	network, err := netip.ParsePrefix(cidr)
 	if err != nil {
 		return false, NewCIDRError("contains", cidr, ErrInvalidCIDR)
 	}
 
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
+	addr, err := netip.ParseAddr(ipStr)
+	if err != nil {
 		return false, NewValidationError("ip", ipStr, ErrInvalidIP)
 	}
+	addr = addr.WithZone("")
+	if network.Addr().Is4() {
+		addr = addr.Unmap()
+	}
 
-	return network.Contains(ip), nil
+	return network.Masked().Contains(addr), nil
 }
 
 // Count returns the total number of addresses in a CIDR range
@@ -262,189 +348,249 @@ func Count(cidr string) (*big.Int, error) {
 
 // Overlaps checks if two CIDR ranges overlap
 func Overlaps(cidr1, cidr2 string) (bool, error) {
-	_, net1, err := net.ParseCIDR(cidr1)
+	net1, err := netip.ParsePrefix(cidr1)
 	if err != nil {
-		return false, fmt.Errorf("invalid first CIDR: %v", err)
+		return false, errcode.Errorf(errcode.CIDRInvalid, "invalid first CIDR: %v", err)
 	}
 
-	_, net2, err := net.ParseCIDR(cidr2)
+	net2, err := netip.ParsePrefix(cidr2)
 	if err != nil {
-		return false, fmt.Errorf("invalid second CIDR: %v", err)
+		return false, errcode.Errorf(errcode.CIDRInvalid, "invalid second CIDR: %v", err)
 	}
 
-	// Check if either network contains the other's network address
-	return net1.Contains(net2.IP) || net2.Contains(net1.IP), nil
+	return net1.Masked().Overlaps(net2.Masked()), nil
 }
 
-// Divide splits a CIDR range into N smaller subnets
+// Divide splits a CIDR range into N smaller subnets, or into every subnet of
+// length opts.Prefix when it is set. Prefix divisions yielding more than
+// MaxDivideSubnets subnets fail with ErrTooLarge; use DivideStream for those.
 func Divide(cidr string, opts DivisionOptions) ([]string, error) {
+	if opts.Prefix != 0 {
+		return divideByPrefix(cidr, opts.Prefix)
+	}
 	if opts.Parts <= 0 {
 		return nil, NewValidationError("parts", fmt.Sprintf("%d", opts.Parts), ErrInvalidParts)
 	}
 
-	_, network, err := net.ParseCIDR(cidr)
+	network, err := netip.ParsePrefix(cidr)
 	if err != nil {
 		return nil, NewCIDRError("divide", cidr, ErrInvalidCIDR)
 	}
 
-	return generateSubnets(network, opts.Parts)
-}
-
-// generateSubnets creates the actual subnet list from a network and part count
-func generateSubnets(network *net.IPNet, parts int) ([]string, error) {
-	subnetConfig, err := calculateSubnetConfiguration(network, parts)
+	subnets, err := DivideParts(network, opts.Parts)
 	if err != nil {
 		return nil, err
 	}
-
-	return createSubnetList(network, subnetConfig, parts)
-}
-
-// SubnetConfiguration holds the calculated parameters for subnet division
-type SubnetConfiguration struct {
-	NewPrefixLen int
-	Increment    *big.Int
-	Bits         int
+	return formatAll(&textBatch{}, nil, slices.Values(subnets), len(subnets)), nil
 }
 
-// calculateSubnetConfiguration determines the subnet parameters
-func calculateSubnetConfiguration(network *net.IPNet, parts int) (*SubnetConfiguration, error) {
-	prefixLen, bits := network.Mask.Size()
-	isIPv6 := len(network.IP) == 16
-
-	bitsNeeded := int(math.Ceil(math.Log2(float64(parts))))
-	newPrefixLen := prefixLen + bitsNeeded
+// DivideParts returns the first parts subnets of network when it is split
+// into equal subnets, as many as the smallest power of two at least parts
+func DivideParts(network netip.Prefix, parts int) ([]netip.Prefix, error) {
+	if parts <= 0 {
+		return nil, NewValidationError("parts", fmt.Sprintf("%d", parts), ErrInvalidParts)
+	}
+	network = network.Masked()
 
-	if (isIPv6 && newPrefixLen > IPv6Bits) || (!isIPv6 && newPrefixLen > IPv4Bits) {
+	newPrefixLen := network.Bits() + bits.Len(uint(parts-1))
+	if newPrefixLen > network.Addr().BitLen() {
 		return nil, ErrInsufficientBits
 	}
 
-	increment := big.NewInt(0).Exp(big.NewInt(2), big.NewInt(int64(bits-newPrefixLen)), nil)
-
-	return &SubnetConfiguration{
-		NewPrefixLen: newPrefixLen,
-		Increment:    increment,
-		Bits:         bits,
-	}, nil
+	subnets := make([]netip.Prefix, 0, parts)
+	addr := network.Addr()
+	for i := 0; i < parts; i++ {
+		subnet := netip.PrefixFrom(addr, newPrefixLen)
+		subnets = append(subnets, subnet)
+		addr = lastAddr(subnet).Next()
+	}
+	return subnets, nil
 }
 
-// createSubnetList generates the list of subnet strings
-func createSubnetList(network *net.IPNet, config *SubnetConfiguration, parts int) ([]string, error) {
-	var subnets []string
-	currentIP := make(net.IP, len(network.IP))
-	copy(currentIP, network.IP)
+// DivideResult contains either a subnet string or an error from streaming division
+type DivideResult struct {
+	Subnet string
+	Err    error
+}
 
-	for i := 0; i < parts; i++ {
-		subnet := &net.IPNet{
-			IP:   make(net.IP, len(currentIP)),
-			Mask: net.CIDRMask(config.NewPrefixLen, config.Bits),
-		}
-		copy(subnet.IP, currentIP)
+// DivideStream streams every subnet of length opts.Prefix in a CIDR range,
+// in address order, using constant memory however many there are. The
+// channel is closed when iteration completes, an error occurs, or context is
+// cancelled. Check DivideResult.Err on each receive for errors.
+func DivideStream(ctx context.Context, cidr string, opts DivisionOptions) <-chan DivideResult {
+	ch := make(chan DivideResult, 256) // Buffered for performance
 
-		subnets = append(subnets, subnet.String())
-		addToIP(currentIP, config.Increment)
-	}
+	go func() {
+		defer close(ch)
 
-	return subnets, nil
-}
+		network, err := parseDivisionPrefix(cidr, opts.Prefix)
+		if err != nil {
+			select {
+			case ch <- DivideResult{Err: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
 
-// Helper functions
+		var (
+			batch   textBatch
+			subnets = make([]netip.Prefix, 0, formatBatchSize)
+			strs    []string
+			flushed = func() bool {
+				strs = formatAll(&batch, strs[:0], slices.Values(subnets), len(subnets))
+				for _, subnet := range strs {
+					// Try to send, but respect context cancellation to avoid goroutine leak
+					select {
+					case ch <- DivideResult{Subnet: subnet}:
+					case <-ctx.Done():
+						return false
+					}
+				}
+				subnets = subnets[:0]
+				return true
+			}
+		)
+		for subnet := range subnetsOf(network, opts.Prefix) {
+			subnets = append(subnets, subnet)
+			if len(subnets) == formatBatchSize && !flushed() {
+				return
+			}
+		}
+		flushed()
+	}()
 
-func getPrefixLength(network *net.IPNet) int {
-	ones, _ := network.Mask.Size()
-	return ones
+	return ch
 }
 
-func getHostMask(netmask net.IP) net.IP {
-	hostMask := make(net.IP, len(netmask))
-	for i := range netmask {
-		hostMask[i] = ^netmask[i]
+// DivisionCount returns how many subnets of length prefix a CIDR range holds
+func DivisionCount(cidr string, prefix int) (*big.Int, error) {
+	network, err := parseDivisionPrefix(cidr, prefix)
+	if err != nil {
+		return nil, err
 	}
-	return hostMask
+	return new(big.Int).Lsh(big.NewInt(1), uint(prefix-network.Bits())), nil
 }
 
-func getBroadcastAddress(network *net.IPNet) net.IP {
-	broadcast := make(net.IP, len(network.IP))
-	copy(broadcast, network.IP)
+// divideByPrefix lists the subnets of length prefix for ranges small enough
+// to return at once
+func divideByPrefix(cidr string, prefix int) ([]string, error) {
+	count, err := DivisionCount(cidr, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if count.Cmp(big.NewInt(MaxDivideSubnets)) > 0 {
+		return nil, NewCIDRError("divide", cidr, ErrTooLarge)
+	}
 
-	for i := range broadcast {
-		broadcast[i] |= ^network.Mask[i]
+	network, err := parseDivisionPrefix(cidr, prefix)
+	if err != nil {
+		return nil, err
 	}
-	return broadcast
+	return formatAll(&textBatch{}, nil, subnetsOf(network, prefix), int(count.Int64())), nil
 }
 
-func getFirstUsable(network *net.IPNet) net.IP {
-	prefixLen, _ := network.Mask.Size()
-	if prefixLen >= MinPointToPointPrefixV4 {
-		// For /31 and /32, first address is usable
-		return network.IP
+// subnetsOf yields every subnet of length prefix in network, in address order
+func subnetsOf(network netip.Prefix, prefix int) iter.Seq[netip.Prefix] {
+	return func(yield func(netip.Prefix) bool) {
+		last := lastAddr(network)
+		addr := network.Addr()
+		for {
+			subnet := netip.PrefixFrom(addr, prefix)
+			end := lastAddr(subnet)
+			if !yield(subnet) || end == last {
+				return
+			}
+			addr = end.Next()
+		}
 	}
+}
 
-	first := make(net.IP, len(network.IP))
-	copy(first, network.IP)
+// parseDivisionPrefix parses the range to divide and checks that prefix lies
+// between its own prefix length and the address length
+func parseDivisionPrefix(cidr string, prefix int) (netip.Prefix, error) {
+	network, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, NewCIDRError("divide", cidr, ErrInvalidCIDR)
+	}
+	network = network.Masked()
 
-	// Add 1 to the last byte
-	for i := len(first) - 1; i >= 0; i-- {
-		first[i]++
-		if first[i] != 0 {
-			break
-		}
+	switch {
+	case prefix > network.Addr().BitLen():
+		return netip.Prefix{}, NewValidationError("prefix", fmt.Sprintf("/%d", prefix), ErrInsufficientBits)
+	case prefix < network.Bits():
+		return netip.Prefix{}, NewValidationError("prefix", fmt.Sprintf("/%d", prefix), ErrInvalidPrefix)
 	}
-	return first
+	return network, nil
 }
 
-func getLastUsable(network *net.IPNet) net.IP {
-	prefixLen, _ := network.Mask.Size()
-	if prefixLen >= MinPointToPointPrefixV4 {
-		// For /31, both addresses are usable; for /32, only one
-		broadcast := getBroadcastAddress(network)
-		if prefixLen == HostRoutePrefixV4 {
-			return broadcast
-		}
-		return broadcast
-	}
+// Helper functions
 
-	broadcast := getBroadcastAddress(network)
-	last := make(net.IP, len(broadcast))
-	copy(last, broadcast)
+// formatBatchSize is how many addresses or prefixes the streaming functions
+// format at a time
+const formatBatchSize = 256
 
-	// Subtract 1 from the last byte
-	for i := len(last) - 1; i >= 0; i-- {
-		if last[i] > 0 {
-			last[i]--
-			break
+// textBatch holds the buffers formatAll reuses between batches
+type textBatch struct {
+	buf  []byte
+	ends []int
+}
+
+// formatAll appends the text of each of the n values to dst. The strings are
+// cut from one shared string, so a batch costs a single allocation for its
+// text rather than one per value, which dominates dividing or expanding large
+// ranges.
+func formatAll[T interface{ AppendTo([]byte) []byte }](batch *textBatch, dst []string, values iter.Seq[T], n int) []string {
+	batch.buf, batch.ends = batch.buf[:0], slices.Grow(batch.ends[:0], n)
+	dst = slices.Grow(dst, n)
+	for v := range values {
+		batch.buf = v.AppendTo(batch.buf)
+		if len(batch.ends) == 0 {
+			// Values in a batch are mostly the same length as the first
+			batch.buf = slices.Grow(batch.buf, len(batch.buf)*n)
 		}
-		last[i] = 255
+		batch.ends = append(batch.ends, len(batch.buf))
+	}
+	text := string(batch.buf)
+	start := 0
+	for _, end := range batch.ends {
+		dst = append(dst, text[start:end])
+		start = end
 	}
-	return last
+	return dst
 }
 
-func getLastIPv6(network *net.IPNet) net.IP {
-	last := make(net.IP, len(network.IP))
-	copy(last, network.IP)
+// binaryPrefix writes the base address of network in binary, IPv4 as dotted
+// octets and IPv6 as colon separated hextets, with a | where the network bits
+// end. The | takes the place of a separator that falls on the boundary.
+func binaryPrefix(network netip.Prefix) string {
+	addr := network.Addr().AsSlice()
+	group, sep := 8, byte('.')
+	if len(addr) == 16 {
+		group, sep = 16, ':'
+	}
 
-	for i := range last {
-		last[i] |= ^network.Mask[i]
+	var b strings.Builder
+	for i := range len(addr) * 8 {
+		switch {
+		case i == network.Bits():
+			b.WriteByte('|')
+		case i > 0 && i%group == 0:
+			b.WriteByte(sep)
+		}
+		b.WriteByte('0' + addr[i/8]>>(7-i%8)&1)
 	}
-	return last
+	if network.Bits() == len(addr)*8 {
+		b.WriteByte('|')
+	}
+	return b.String()
 }
 
-func addToIP(ip net.IP, increment *big.Int) {
-	// Convert IP to big.Int, add increment, convert back
-	ipInt := big.NewInt(0)
-	ipInt.SetBytes(ip)
-	ipInt.Add(ipInt, increment)
-
-	bytes := ipInt.Bytes()
-
-	// Pad with zeros if necessary
-	if len(bytes) < len(ip) {
-		padded := make([]byte, len(ip))
-		copy(padded[len(ip)-len(bytes):], bytes)
-		bytes = padded
+func getHostMask(netmask net.IP) net.IP {
+	hostMask := make(net.IP, len(netmask))
+	for i := range netmask {
+		hostMask[i] = ^netmask[i]
 	}
-
-	copy(ip, bytes)
+	return hostMask
 }
 
 // FormatBigInt formats a big.Int with thousand separators
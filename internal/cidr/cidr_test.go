@@ -2,10 +2,14 @@ package cidr
 
 import (
 	"context"
+	"fmt"
 	"math/big"
-	"net"
+	"net/netip"
+	"slices"
 	"strings"
 	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
 )
 
 func assertError(t *testing.T, got error, want bool) {
@@ -383,6 +387,133 @@ func TestDivide(t *testing.T) {
 	}
 }
 
+func TestDivideByPrefix(t *testing.T) {
+	tests := []struct {
+		name          string
+		cidr          string
+		prefix        int
+		expectedCount int
+		expectedFirst string
+		expectedLast  string
+		errCode       errcode.Code
+	}{
+		{
+			name:          "IPv4 /16 into /24s",
+			cidr:          "10.0.0.0/16",
+			prefix:        24,
+			expectedCount: 256,
+			expectedFirst: "10.0.0.0/24",
+			expectedLast:  "10.0.255.0/24",
+		},
+		{
+			name:          "host bits are ignored",
+			cidr:          "10.0.7.9/22",
+			prefix:        23,
+			expectedCount: 2,
+			expectedFirst: "10.0.4.0/23",
+			expectedLast:  "10.0.6.0/23",
+		},
+		{
+			name:          "same prefix length",
+			cidr:          "192.168.1.0/24",
+			prefix:        24,
+			expectedCount: 1,
+			expectedFirst: "192.168.1.0/24",
+			expectedLast:  "192.168.1.0/24",
+		},
+		{
+			name:          "IPv6 to the end of the address space",
+			cidr:          "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fff0/124",
+			prefix:        128,
+			expectedCount: 16,
+			expectedFirst: "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fff0/128",
+			expectedLast:  "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128",
+		},
+		{name: "prefix shorter than the network", cidr: "10.0.0.0/16", prefix: 8, errCode: errcode.CIDRInvalid},
+		{name: "prefix beyond the address length", cidr: "10.0.0.0/16", prefix: 33, errCode: errcode.CIDRInsufficientBits},
+		{name: "too many to return at once", cidr: "10.0.0.0/8", prefix: 32, errCode: errcode.CIDRTooLarge},
+		{name: "invalid CIDR", cidr: "invalid", prefix: 24, errCode: errcode.CIDRInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Divide(tt.cidr, DivisionOptions{Prefix: tt.prefix})
+			if tt.errCode != "" {
+				if got := errcode.Of(err); got != tt.errCode {
+					t.Fatalf("expected %s, got %v", tt.errCode, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Divide returned error: %v", err)
+			}
+
+			if len(result) != tt.expectedCount {
+				t.Fatalf("Expected %d subnets, got %d", tt.expectedCount, len(result))
+			}
+			if result[0] != tt.expectedFirst || result[len(result)-1] != tt.expectedLast {
+				t.Errorf("Expected %s..%s, got %s..%s", tt.expectedFirst, tt.expectedLast, result[0], result[len(result)-1])
+			}
+		})
+	}
+}
+
+func TestDivideStream(t *testing.T) {
+	// 2^64 subnets: only streaming can handle this, and cancelling must stop it
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := DivideStream(ctx, "2001:db8::/32", DivisionOptions{Prefix: 96})
+
+	var got []string
+	for result := range results {
+		if result.Err != nil {
+			t.Fatalf("DivideStream returned error: %v", result.Err)
+		}
+		got = append(got, result.Subnet)
+		if len(got) == 3 {
+			cancel()
+			break
+		}
+	}
+	want := []string{"2001:db8::/96", "2001:db8::1:0:0/96", "2001:db8::2:0:0/96"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("DivideStream = %v, want %v", got, want)
+	}
+	for range results {
+		// Drain until the producer notices the cancellation and closes
+	}
+
+	count, err := DivisionCount("2001:db8::/32", 96)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count.String() != "18446744073709551616" {
+		t.Errorf("DivisionCount = %s, want 2^64", count)
+	}
+}
+
+func TestExpandIndex(t *testing.T) {
+	network := netip.MustParsePrefix("2001:db8::/119")
+	var want []string
+	for _, addr := range Addrs(network, 0) {
+		want = append(want, addr.String())
+	}
+
+	var got []string
+	for result := range Expand(context.Background(), network.String(), ExpansionOptions{}) {
+		if result.Err != nil {
+			t.Fatalf("Expand returned error: %v", result.Err)
+		}
+		if result.Index != uint64(len(got)) {
+			t.Fatalf("result %d has index %d", len(got), result.Index)
+		}
+		got = append(got, result.IP)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Expand returned %d addresses that differ from Addrs' %d", len(got), len(want))
+	}
+}
+
 func TestExpand(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -425,6 +556,14 @@ func TestExpand(t *testing.T) {
 			expectedFirst: "10.0.0.0",
 			expectedLast:  "10.0.0.9",
 		},
+		{
+			name:          "IPv4 /23 spans several batches",
+			cidr:          "10.0.0.0/23",
+			limit:         300,
+			expectedCount: 300,
+			expectedFirst: "10.0.0.0",
+			expectedLast:  "10.0.1.43",
+		},
 		{
 			name:          "IPv6 /126",
 			cidr:          "2001:db8::/126",
@@ -454,6 +593,9 @@ func TestExpand(t *testing.T) {
 					err = r.Err
 					break
 				}
+				if r.Index != uint64(len(result)) {
+					t.Errorf("Expected index %d for %s, got %d", len(result), r.IP, r.Index)
+				}
 				result = append(result, r.IP)
 			}
 
@@ -537,6 +679,37 @@ func TestNetworkInfoOutput(t *testing.T) {
 	}
 }
 
+func TestMaskFormats(t *testing.T) {
+	tests := []struct {
+		cidr     string
+		wildcard string
+		hex      string
+		binary   string
+	}{
+		{cidr: "192.168.1.0/24", wildcard: "0.0.0.255", hex: "0xffffff00", binary: "11000000.10101000.00000001|00000000"},
+		{cidr: "10.0.0.0/23", wildcard: "0.0.1.255", hex: "0xfffffe00", binary: "00001010.00000000.0000000|0.00000000"},
+		{cidr: "192.0.2.1/32", wildcard: "0.0.0.0", hex: "0xffffffff", binary: "11000000.00000000.00000010.00000001|"},
+		{cidr: "0.0.0.0/0", wildcard: "255.255.255.255", hex: "0x00000000", binary: "|00000000.00000000.00000000.00000000"},
+		{
+			cidr:   "2001:db8::/34",
+			hex:    "0xffffffffc00000000000000000000000",
+			binary: "0010000000000001:0000110110111000:00|00000000000000:0000000000000000:0000000000000000:0000000000000000:0000000000000000:0000000000000000",
+		},
+	}
+
+	for _, tt := range tests {
+		info, err := ParseCIDR(tt.cidr)
+		if err != nil {
+			t.Fatalf("ParseCIDR(%s) returned error: %v", tt.cidr, err)
+		}
+		output := info.ToOutput()
+		if output.WildcardMask != tt.wildcard || output.HexNetmask != tt.hex || output.BinaryPrefix != tt.binary {
+			t.Errorf("ParseCIDR(%s) masks = %q, %q, %q; want %q, %q, %q", tt.cidr,
+				output.WildcardMask, output.HexNetmask, output.BinaryPrefix, tt.wildcard, tt.hex, tt.binary)
+		}
+	}
+}
+
 func TestFormatBigInt(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -575,78 +748,177 @@ func TestFormatBigInt(t *testing.T) {
 	}
 }
 
-func TestIncrementIP(t *testing.T) {
+func TestAddrs(t *testing.T) {
 	tests := []struct {
 		name     string
-		input    net.IP
-		expected string
+		prefix   string
+		limit    int
+		expected []string
 	}{
 		{
-			name:     "IPv4 simple increment",
-			input:    net.ParseIP("192.168.1.1"),
-			expected: "192.168.1.2",
+			name:     "IPv4 carry over",
+			prefix:   "192.168.1.254/31",
+			expected: []string{"192.168.1.254", "192.168.1.255"},
 		},
 		{
-			name:     "IPv4 carry over",
-			input:    net.ParseIP("192.168.1.255"),
-			expected: "192.168.2.0",
+			name:     "IPv4 end of address space",
+			prefix:   "255.255.255.254/31",
+			expected: []string{"255.255.255.254", "255.255.255.255"},
 		},
 		{
-			name:     "IPv6 simple increment",
-			input:    net.ParseIP("2001:db8::1"),
-			expected: "2001:db8::2",
+			name:     "IPv6 end of address space",
+			prefix:   "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe/127",
+			expected: []string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"},
+		},
+		{
+			name:     "limit",
+			prefix:   "0.0.0.0/0",
+			limit:    2,
+			expected: []string{"0.0.0.0", "0.0.0.1"},
+		},
+		{
+			name:     "host bits are masked",
+			prefix:   "2001:db8::7/127",
+			expected: []string{"2001:db8::6", "2001:db8::7"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ip := make(net.IP, len(tt.input))
-			copy(ip, tt.input)
-
-			incrementIP(ip)
-
-			if ip.String() != tt.expected {
-				t.Errorf("Expected %s, got %s", tt.expected, ip.String())
+			var got []string
+			for index, addr := range Addrs(netip.MustParsePrefix(tt.prefix), tt.limit) {
+				if index != uint64(len(got)) {
+					t.Errorf("Expected index %d for %s, got %d", len(got), addr, index)
+				}
+				got = append(got, addr.String())
+			}
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
 			}
 		})
 	}
+
+	for range Addrs(netip.Prefix{}, 0) {
+		t.Fatal("Expected no addresses for an invalid prefix")
+	}
 }
 
-func TestHelperFunctions(t *testing.T) {
-	// Test getBroadcastAddress
-	_, network, _ := net.ParseCIDR("192.168.1.0/24")
-	broadcast := getBroadcastAddress(network)
-	if broadcast.String() != "192.168.1.255" {
-		t.Errorf("Expected broadcast 192.168.1.255, got %s", broadcast.String())
+func TestUsableRange(t *testing.T) {
+	tests := []struct {
+		prefix string
+		first  string
+		last   string
+	}{
+		{prefix: "192.168.1.0/24", first: "192.168.1.1", last: "192.168.1.254"},
+		{prefix: "192.168.1.0/31", first: "192.168.1.0", last: "192.168.1.1"},
+		{prefix: "192.168.1.1/32", first: "192.168.1.1", last: "192.168.1.1"},
+		{prefix: "2001:db8::/126", first: "2001:db8::", last: "2001:db8::3"},
 	}
 
-	// Test getFirstUsable
-	first := getFirstUsable(network)
-	if first.String() != "192.168.1.1" {
-		t.Errorf("Expected first usable 192.168.1.1, got %s", first.String())
+	for _, tt := range tests {
+		first, last := usableRange(netip.MustParsePrefix(tt.prefix))
+		if first.String() != tt.first || last.String() != tt.last {
+			t.Errorf("usableRange(%s) = %s-%s, want %s-%s", tt.prefix, first, last, tt.first, tt.last)
+		}
 	}
 
-	// Test getLastUsable
-	last := getLastUsable(network)
-	if last.String() != "192.168.1.254" {
-		t.Errorf("Expected last usable 192.168.1.254, got %s", last.String())
+	info, err := ParseCIDR("192.168.1.0/24")
+	if err != nil {
+		t.Fatalf("ParseCIDR returned error: %v", err)
+	}
+	if info.BroadcastAddr.String() != "192.168.1.255" || info.HostMask.String() != "0.0.0.255" || info.Network.String() != "192.168.1.0/24" {
+		t.Errorf("Unexpected net.IP compatibility fields: %+v", info)
 	}
+}
 
-	// Test /31 network (point-to-point)
-	_, network31, _ := net.ParseCIDR("192.168.1.0/31")
-	first31 := getFirstUsable(network31)
-	last31 := getLastUsable(network31)
-	if first31.String() != "192.168.1.0" {
-		t.Errorf("Expected /31 first usable 192.168.1.0, got %s", first31.String())
+func TestContainsMappedAndZoned(t *testing.T) {
+	if ok, err := Contains("192.168.1.0/24", "::ffff:192.168.1.5"); err != nil || !ok {
+		t.Errorf("Expected IPv4-mapped address inside IPv4 range, got %v, %v", ok, err)
+	}
+	if ok, err := Contains("fe80::/64", "fe80::1%eth0"); err != nil || !ok {
+		t.Errorf("Expected zoned link-local address inside fe80::/64, got %v, %v", ok, err)
 	}
-	if last31.String() != "192.168.1.1" {
-		t.Errorf("Expected /31 last usable 192.168.1.1, got %s", last31.String())
+	if ok, err := Contains("::ffff:0:0/96", "192.168.1.5"); err != nil || ok {
+		t.Errorf("Expected IPv4 address outside an IPv6 range, got %v, %v", ok, err)
 	}
+}
 
-	// Test /32 network (host route)
-	_, network32, _ := net.ParseCIDR("192.168.1.1/32")
-	first32 := getFirstUsable(network32)
-	if first32.String() != "192.168.1.1" {
-		t.Errorf("Expected /32 first usable 192.168.1.1, got %s", first32.String())
+func BenchmarkAddrs(b *testing.B) {
+	network := netip.MustParsePrefix("10.0.0.0/22")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for range Addrs(network, 0) {
+		}
+	}
+}
+
+func BenchmarkExpand(b *testing.B) {
+	for _, cidr := range []string{"10.0.0.0/22", "2001:db8::/118", "10.0.0.0/16"} {
+		b.Run(cidr, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for result := range Expand(context.Background(), cidr, ExpansionOptions{}) {
+					if result.Err != nil {
+						b.Fatal(result.Err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDivide(b *testing.B) {
+	for _, cidr := range []string{"10.0.0.0/8", "2001:db8::/32"} {
+		b.Run(cidr, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Divide(cidr, DivisionOptions{Parts: 256}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+	for _, tt := range []struct {
+		cidr   string
+		prefix int
+	}{
+		{"10.0.0.0/8", 24},
+		{"2001:db8::/32", 48},
+	} {
+		b.Run(fmt.Sprintf("%s into /%d", tt.cidr, tt.prefix), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Divide(tt.cidr, DivisionOptions{Prefix: tt.prefix}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDivideStream(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for result := range DivideStream(context.Background(), "10.0.0.0/8", DivisionOptions{Prefix: 24}) {
+			if result.Err != nil {
+				b.Fatal(result.Err)
+			}
+		}
+	}
+}
+
+func BenchmarkContains(b *testing.B) {
+	for _, tt := range []struct{ cidr, ip string }{
+		{"10.0.0.0/8", "10.20.30.40"},
+		{"2001:db8::/32", "2001:db8:1234::5678"},
+	} {
+		b.Run(tt.cidr, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Contains(tt.cidr, tt.ip); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
 	}
 }
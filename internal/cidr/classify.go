@@ -0,0 +1,233 @@
+package cidr
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Address classes reported by Classify
+const (
+	ClassUnspecified   = "unspecified"
+	ClassThisNetwork   = "this-network"
+	ClassPrivate       = "private" // RFC 1918 and IPv6 unique local
+	ClassCGN           = "cgn"     // Carrier-grade NAT shared address space
+	ClassLoopback      = "loopback"
+	ClassLinkLocal     = "link-local"
+	ClassProtocol      = "protocol-assignment"
+	ClassDocumentation = "documentation"
+	ClassBenchmarking  = "benchmarking"
+	ClassMulticast     = "multicast"
+	ClassReserved      = "reserved"
+	ClassBroadcast     = "broadcast"
+	ClassIPv4Mapped    = "ipv4-mapped"
+	ClassNAT64         = "nat64"
+	ClassDiscardOnly   = "discard-only"
+	Class6to4          = "6to4"
+	ClassTeredo        = "teredo"
+)
+
+// specialRange is one entry of the IANA special-purpose address registries
+type specialRange struct {
+	prefix      netip.Prefix
+	class       string
+	description string
+	rfc         string
+	global      bool // Reachable across the internet despite being special
+}
+
+// specialRanges lists the special-purpose blocks Classify recognizes
+var specialRanges = []specialRange{
+	{netip.MustParsePrefix("0.0.0.0/32"), ClassUnspecified, "Unspecified address", "RFC 1122", false},
+	{netip.MustParsePrefix("0.0.0.0/8"), ClassThisNetwork, "This network", "RFC 791", false},
+	{netip.MustParsePrefix("10.0.0.0/8"), ClassPrivate, "Private use", "RFC 1918", false},
+	{netip.MustParsePrefix("100.64.0.0/10"), ClassCGN, "Shared address space (carrier-grade NAT)", "RFC 6598", false},
+	{netip.MustParsePrefix("127.0.0.0/8"), ClassLoopback, "Loopback", "RFC 1122", false},
+	{netip.MustParsePrefix("169.254.0.0/16"), ClassLinkLocal, "Link-local", "RFC 3927", false},
+	{netip.MustParsePrefix("172.16.0.0/12"), ClassPrivate, "Private use", "RFC 1918", false},
+	{netip.MustParsePrefix("192.0.0.0/24"), ClassProtocol, "IETF protocol assignments", "RFC 6890", false},
+	{netip.MustParsePrefix("192.0.2.0/24"), ClassDocumentation, "Documentation (TEST-NET-1)", "RFC 5737", false},
+	{netip.MustParsePrefix("192.88.99.0/24"), Class6to4, "6to4 relay anycast (deprecated)", "RFC 7526", false},
+	{netip.MustParsePrefix("192.168.0.0/16"), ClassPrivate, "Private use", "RFC 1918", false},
+	{netip.MustParsePrefix("198.18.0.0/15"), ClassBenchmarking, "Benchmarking", "RFC 2544", false},
+	{netip.MustParsePrefix("198.51.100.0/24"), ClassDocumentation, "Documentation (TEST-NET-2)", "RFC 5737", false},
+	{netip.MustParsePrefix("203.0.113.0/24"), ClassDocumentation, "Documentation (TEST-NET-3)", "RFC 5737", false},
+	{netip.MustParsePrefix("224.0.0.0/4"), ClassMulticast, "Multicast", "RFC 5771", false},
+	{netip.MustParsePrefix("255.255.255.255/32"), ClassBroadcast, "Limited broadcast", "RFC 919", false},
+	{netip.MustParsePrefix("240.0.0.0/4"), ClassReserved, "Reserved for future use", "RFC 1112", false},
+
+	{netip.MustParsePrefix("::/128"), ClassUnspecified, "Unspecified address", "RFC 4291", false},
+	{netip.MustParsePrefix("::1/128"), ClassLoopback, "Loopback", "RFC 4291", false},
+	{netip.MustParsePrefix("::ffff:0:0/96"), ClassIPv4Mapped, "IPv4-mapped address", "RFC 4291", false},
+	{netip.MustParsePrefix("64:ff9b::/96"), ClassNAT64, "IPv4/IPv6 translation (well-known prefix)", "RFC 6052", true},
+	{netip.MustParsePrefix("64:ff9b:1::/48"), ClassNAT64, "IPv4/IPv6 translation (local use)", "RFC 8215", false},
+	{netip.MustParsePrefix("100::/64"), ClassDiscardOnly, "Discard-only", "RFC 6666", false},
+	{netip.MustParsePrefix("2001::/32"), ClassTeredo, "Teredo", "RFC 4380", true},
+	{netip.MustParsePrefix("2001:2::/48"), ClassBenchmarking, "Benchmarking", "RFC 5180", false},
+	{netip.MustParsePrefix("2001:db8::/32"), ClassDocumentation, "Documentation", "RFC 3849", false},
+	{netip.MustParsePrefix("2002::/16"), Class6to4, "6to4", "RFC 3056", true},
+	{netip.MustParsePrefix("3fff::/20"), ClassDocumentation, "Documentation", "RFC 9637", false},
+	{netip.MustParsePrefix("fc00::/7"), ClassPrivate, "Unique local", "RFC 4193", false},
+	{netip.MustParsePrefix("fe80::/10"), ClassLinkLocal, "Link-local unicast", "RFC 4291", false},
+	{netip.MustParsePrefix("ff00::/8"), ClassMulticast, "Multicast", "RFC 4291", false},
+}
+
+// AddressClass is one special-purpose block an address belongs to
+type AddressClass struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description" yaml:"description"`
+	Range       string `json:"range" yaml:"range"`
+	RFC         string `json:"rfc" yaml:"rfc"`
+}
+
+// AddressInfo describes a single IP address
+type AddressInfo struct {
+	IP           string         `json:"ip" yaml:"ip"`
+	Version      int            `json:"version" yaml:"version"`
+	Classes      []AddressClass `json:"classes" yaml:"classes"` // Empty for ordinary unicast addresses
+	Global       bool           `json:"global" yaml:"global"`   // Reachable across the internet
+	EmbeddedIPv4 string         `json:"embedded_ipv4,omitempty" yaml:"embedded_ipv4,omitempty"`
+	ReverseName  string         `json:"reverse_name" yaml:"reverse_name"` // PTR owner name
+	ReverseZone  string         `json:"reverse_zone" yaml:"reverse_zone"` // Enclosing /24 or /64 reverse zone
+	Decimal      string         `json:"decimal" yaml:"decimal"`
+	Hex          string         `json:"hex" yaml:"hex"`
+	Binary       string         `json:"binary" yaml:"binary"`
+}
+
+// ToJSON converts AddressInfo to JSON string
+func (info *AddressInfo) ToJSON() (string, error) {
+	bytes, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// ToYAML converts AddressInfo to YAML string
+func (info *AddressInfo) ToYAML() (string, error) {
+	bytes, err := yaml.Marshal(info)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// Classify reports which special-purpose blocks ip belongs to (private,
+// loopback, documentation, CGN, 6to4, Teredo, and so on), whether it is
+// globally reachable, its reverse DNS names, and its numeric forms
+func Classify(ip string) (*AddressInfo, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return nil, NewValidationError("ip", ip, ErrInvalidIP)
+	}
+	addr = addr.WithZone("")
+
+	info := &AddressInfo{
+		IP:          addr.String(),
+		Version:     6,
+		Classes:     []AddressClass{},
+		Global:      true,
+		ReverseName: strings.TrimSuffix(ReverseName(addr), "."),
+		Decimal:     new(big.Int).SetBytes(addr.AsSlice()).String(),
+		Hex:         "0x" + hex.EncodeToString(addr.AsSlice()),
+		Binary:      binaryString(addr),
+	}
+	if addr.Is4() {
+		info.Version = 4
+	}
+	info.ReverseZone = reverseZone(info.ReverseName, addr)
+
+	for _, special := range specialRanges {
+		if !special.prefix.Contains(addr) {
+			continue
+		}
+		info.Classes = append(info.Classes, AddressClass{
+			Name:        special.class,
+			Description: special.description,
+			Range:       special.prefix.String(),
+			RFC:         special.rfc,
+		})
+		info.Global = info.Global && special.global
+	}
+	info.EmbeddedIPv4 = embeddedIPv4(addr, info.Classes)
+	return info, nil
+}
+
+// ReverseName returns the in-addr.arpa or ip6.arpa name of addr, with the
+// trailing dot
+func ReverseName(addr netip.Addr) string {
+	addr = addr.Unmap()
+	var labels []string
+	if addr.Is4() {
+		octets := addr.As4()
+		for i := len(octets) - 1; i >= 0; i-- {
+			labels = append(labels, strconv.Itoa(int(octets[i])))
+		}
+		return strings.Join(labels, ".") + ".in-addr.arpa."
+	}
+	bytes := addr.As16()
+	for i := len(bytes) - 1; i >= 0; i-- {
+		labels = append(labels, strconv.FormatUint(uint64(bytes[i]&0x0f), 16), strconv.FormatUint(uint64(bytes[i]>>4), 16))
+	}
+	return strings.Join(labels, ".") + ".ip6.arpa."
+}
+
+// reverseZone drops the host part of a reverse name: one octet for IPv4,
+// sixteen nibbles (the interface identifier) for IPv6
+func reverseZone(name string, addr netip.Addr) string {
+	hostLabels := 16
+	if addr.Unmap().Is4() {
+		hostLabels = 1
+	}
+	labels := strings.Split(name, ".")
+	return strings.Join(labels[hostLabels:], ".")
+}
+
+// binaryString writes IPv4 as dotted octets and IPv6 as colon-separated
+// 16-bit groups, in binary
+func binaryString(addr netip.Addr) string {
+	bytes := addr.AsSlice()
+	if addr.Is4() {
+		parts := make([]string, len(bytes))
+		for i, b := range bytes {
+			parts[i] = fmt.Sprintf("%08b", b)
+		}
+		return strings.Join(parts, ".")
+	}
+	parts := make([]string, len(bytes)/2)
+	for i := range parts {
+		parts[i] = fmt.Sprintf("%08b%08b", bytes[2*i], bytes[2*i+1])
+	}
+	return strings.Join(parts, ":")
+}
+
+// embeddedIPv4 extracts the IPv4 address carried inside IPv4-mapped, NAT64,
+// 6to4, and Teredo addresses
+func embeddedIPv4(addr netip.Addr, classes []AddressClass) string {
+	if !addr.Is6() {
+		return ""
+	}
+	b := addr.As16()
+	for _, class := range classes {
+		switch class.Name {
+		case ClassIPv4Mapped:
+			return netip.AddrFrom4([4]byte(b[12:16])).String()
+		case ClassNAT64:
+			if class.Range == "64:ff9b::/96" {
+				return netip.AddrFrom4([4]byte(b[12:16])).String()
+			}
+		case Class6to4:
+			return netip.AddrFrom4([4]byte(b[2:6])).String()
+		case ClassTeredo:
+			// The client's public address, stored inverted
+			return netip.AddrFrom4([4]byte{^b[12], ^b[13], ^b[14], ^b[15]}).String()
+		}
+	}
+	return ""
+}
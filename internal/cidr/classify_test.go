@@ -0,0 +1,116 @@
+package cidr
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		ip       string
+		classes  []string
+		global   bool
+		embedded string
+	}{
+		{ip: "8.8.8.8", global: true},
+		{ip: "10.1.2.3", classes: []string{ClassPrivate}},
+		{ip: "172.31.255.255", classes: []string{ClassPrivate}},
+		{ip: "172.32.0.1", global: true},
+		{ip: "100.64.0.1", classes: []string{ClassCGN}},
+		{ip: "127.0.0.1", classes: []string{ClassLoopback}},
+		{ip: "169.254.169.254", classes: []string{ClassLinkLocal}},
+		{ip: "198.51.100.7", classes: []string{ClassDocumentation}},
+		{ip: "198.19.0.1", classes: []string{ClassBenchmarking}},
+		{ip: "239.255.255.250", classes: []string{ClassMulticast}},
+		{ip: "0.0.0.0", classes: []string{ClassUnspecified, ClassThisNetwork}},
+		{ip: "255.255.255.255", classes: []string{ClassBroadcast, ClassReserved}},
+		{ip: "2001:4860:4860::8888", global: true},
+		{ip: "::1", classes: []string{ClassLoopback}},
+		{ip: "fd12:3456::1", classes: []string{ClassPrivate}},
+		{ip: "fe80::1%eth0", classes: []string{ClassLinkLocal}},
+		{ip: "ff02::1", classes: []string{ClassMulticast}},
+		{ip: "2001:db8::1", classes: []string{ClassDocumentation}},
+		{ip: "::ffff:192.168.1.1", classes: []string{ClassIPv4Mapped}, embedded: "192.168.1.1"},
+		{ip: "64:ff9b::c000:221", classes: []string{ClassNAT64}, global: true, embedded: "192.0.2.33"},
+		{ip: "2002:c000:0204::1", classes: []string{Class6to4}, global: true, embedded: "192.0.2.4"},
+		{ip: "2001:0:4136:e378:8000:63bf:3fff:fdd2", classes: []string{ClassTeredo}, global: true, embedded: "192.0.2.45"},
+		{ip: "2001:2::1", classes: []string{ClassBenchmarking}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			info, err := Classify(tt.ip)
+			if err != nil {
+				t.Fatalf("Classify(%s) returned error: %v", tt.ip, err)
+			}
+			var got []string
+			for _, class := range info.Classes {
+				got = append(got, class.Name)
+			}
+			if len(got) != len(tt.classes) {
+				t.Fatalf("classes = %v, want %v", got, tt.classes)
+			}
+			for i := range got {
+				if got[i] != tt.classes[i] {
+					t.Fatalf("classes = %v, want %v", got, tt.classes)
+				}
+			}
+			if info.Global != tt.global {
+				t.Errorf("Global = %v, want %v", info.Global, tt.global)
+			}
+			if info.EmbeddedIPv4 != tt.embedded {
+				t.Errorf("EmbeddedIPv4 = %q, want %q", info.EmbeddedIPv4, tt.embedded)
+			}
+		})
+	}
+
+	if _, err := Classify("not-an-ip"); errcode.Of(err) != errcode.CIDRInvalidIP {
+		t.Fatalf("expected CIDR002 for a bad address, got %v", err)
+	}
+}
+
+func TestClassifyRepresentations(t *testing.T) {
+	info, err := Classify("192.0.2.10")
+	if err != nil {
+		t.Fatalf("Classify returned error: %v", err)
+	}
+	want := AddressInfo{
+		IP:          "192.0.2.10",
+		Version:     4,
+		ReverseName: "10.2.0.192.in-addr.arpa",
+		ReverseZone: "2.0.192.in-addr.arpa",
+		Decimal:     "3221225994",
+		Hex:         "0xc000020a",
+		Binary:      "11000000.00000000.00000010.00001010",
+	}
+	if info.IP != want.IP || info.Version != want.Version || info.ReverseName != want.ReverseName ||
+		info.ReverseZone != want.ReverseZone || info.Decimal != want.Decimal || info.Hex != want.Hex || info.Binary != want.Binary {
+		t.Fatalf("Classify(192.0.2.10) = %+v, want %+v", info, want)
+	}
+
+	info, err = Classify("2001:db8::567:89ab")
+	if err != nil {
+		t.Fatalf("Classify returned error: %v", err)
+	}
+	if info.Version != 6 ||
+		info.ReverseZone != "0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa" ||
+		info.Hex != "0x20010db80000000000000000056789ab" ||
+		info.Binary != "0010000000000001:0000110110111000:0000000000000000:0000000000000000:0000000000000000:0000000000000000:0000010101100111:1000100110101011" {
+		t.Fatalf("unexpected IPv6 representations: %+v", info)
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	tests := map[string]string{
+		"192.0.2.10":         "10.2.0.192.in-addr.arpa.",
+		"::ffff:192.0.2.10":  "10.2.0.192.in-addr.arpa.",
+		"2001:db8::567:89ab": "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+	}
+	for addr, want := range tests {
+		if got := ReverseName(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("ReverseName(%s) = %s, want %s", addr, got, want)
+		}
+	}
+}
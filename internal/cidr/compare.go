@@ -0,0 +1,110 @@
+package cidr
+
+import (
+	"io"
+	"net/netip"
+	"slices"
+)
+
+// ListDiff is the difference in address space between two prefix lists.
+// Unchanged is all the space both lists cover, including any Reaggregated.
+type ListDiff struct {
+	Added        []string        `json:"added" yaml:"added"`
+	Removed      []string        `json:"removed" yaml:"removed"`
+	Unchanged    []string        `json:"unchanged" yaml:"unchanged"`
+	Reaggregated []Reaggregation `json:"reaggregated" yaml:"reaggregated"`
+}
+
+// Reaggregation is space both lists cover but write as different prefixes,
+// such as a /23 split into two /24s
+type Reaggregation struct {
+	Old []string `json:"old" yaml:"old"`
+	New []string `json:"new" yaml:"new"`
+}
+
+// HasChanges reports whether the lists differ in coverage or in how it is written
+func (d *ListDiff) HasChanges() bool {
+	return len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Reaggregated) > 0
+}
+
+// CompareLists reads two prefix lists, one CIDR or address per line as for
+// ReadSet, and reports the space added, removed, and kept between them. A
+// line diff would flag a /23 rewritten as two /24s; here it is unchanged
+// space, listed under Reaggregated.
+func CompareLists(oldList, newList io.Reader) (*ListDiff, error) {
+	oldRanges, err := readRanges(oldList)
+	if err != nil {
+		return nil, err
+	}
+	newRanges, err := readRanges(newList)
+	if err != nil {
+		return nil, err
+	}
+	before, after := newSet(slices.Clone(oldRanges)), newSet(slices.Clone(newRanges))
+
+	diff := &ListDiff{
+		Added:        nonNil(after.Difference(before).Strings()),
+		Removed:      nonNil(before.Difference(after).Strings()),
+		Unchanged:    nonNil(before.Intersect(after).Strings()),
+		Reaggregated: []Reaggregation{},
+	}
+
+	// Entries only one list writes, over space the other list still covers
+	oldOnly := rewrittenEntries(oldRanges, newRanges, after)
+	newOnly := rewrittenEntries(newRanges, oldRanges, before)
+	for _, group := range newSet(slices.Concat(oldOnly, newOnly)).Prefixes() {
+		r := Reaggregation{Old: entriesWithin(oldOnly, group), New: entriesWithin(newOnly, group)}
+		if len(r.Old) > 0 && len(r.New) > 0 {
+			diff.Reaggregated = append(diff.Reaggregated, r)
+		}
+	}
+	return diff, nil
+}
+
+// rewrittenEntries returns the entries that others does not write the same
+// way but whose space covered still holds
+func rewrittenEntries(entries, others []addrRange, covered *Set) []addrRange {
+	written := make(map[addrRange]bool, len(others))
+	for _, r := range others {
+		written[r] = true
+	}
+
+	var rewritten []addrRange
+	for _, r := range entries {
+		if !written[r] && covered.containsRange(r) {
+			rewritten = append(rewritten, r)
+		}
+	}
+	return rewritten
+}
+
+// entriesWithin returns the entries inside group as written, in address
+// order without repeats
+func entriesWithin(entries []addrRange, group netip.Prefix) []string {
+	var within []netip.Prefix
+	for _, r := range entries {
+		if group.Contains(r.from) && group.Contains(r.to) {
+			// Every entry is one CIDR or address, so this is the entry itself
+			within = append(within, largestPrefix(r.from, r.to))
+		}
+	}
+	slices.SortFunc(within, func(a, b netip.Prefix) int {
+		if c := a.Addr().Compare(b.Addr()); c != 0 {
+			return c
+		}
+		return a.Bits() - b.Bits()
+	})
+
+	out := make([]string, 0, len(within))
+	for _, prefix := range slices.Compact(within) {
+		out = append(out, prefix.String())
+	}
+	return out
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
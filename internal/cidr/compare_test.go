@@ -0,0 +1,57 @@
+package cidr
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCompareLists(t *testing.T) {
+	oldList := "10.0.0.0/23\n10.1.0.0/24\n192.168.0.0/24 # lab\n2001:db8::/48\n"
+	newList := "\n10.0.1.0/24\n10.0.0.0/24\n10.2.0.0/24\n192.168.0.0/25\n2001:db8::/48\n2001:db8::/48\n"
+
+	diff, err := CompareLists(strings.NewReader(oldList), strings.NewReader(newList))
+	if err != nil {
+		t.Fatalf("CompareLists returned error: %v", err)
+	}
+	if !slices.Equal(diff.Added, []string{"10.2.0.0/24"}) {
+		t.Errorf("Added = %v", diff.Added)
+	}
+	if !slices.Equal(diff.Removed, []string{"10.1.0.0/24", "192.168.0.128/25"}) {
+		t.Errorf("Removed = %v", diff.Removed)
+	}
+	if !slices.Equal(diff.Unchanged, []string{"10.0.0.0/23", "192.168.0.0/25", "2001:db8::/48"}) {
+		t.Errorf("Unchanged = %v", diff.Unchanged)
+	}
+	if len(diff.Reaggregated) != 1 || !slices.Equal(diff.Reaggregated[0].Old, []string{"10.0.0.0/23"}) ||
+		!slices.Equal(diff.Reaggregated[0].New, []string{"10.0.0.0/24", "10.0.1.0/24"}) {
+		t.Errorf("Reaggregated = %+v", diff.Reaggregated)
+	}
+	if !diff.HasChanges() {
+		t.Error("expected changes")
+	}
+}
+
+func TestCompareListsSameSpace(t *testing.T) {
+	// Reordering, repeating, and bare addresses for /32s are not changes
+	diff, err := CompareLists(strings.NewReader("192.0.2.1\n10.0.0.0/8\n"), strings.NewReader("10.0.0.0/8\n192.0.2.1/32\n10.0.0.0/8\n"))
+	if err != nil {
+		t.Fatalf("CompareLists returned error: %v", err)
+	}
+	if diff.HasChanges() || len(diff.Added) != 0 || len(diff.Removed) != 0 || len(diff.Unchanged) != 2 {
+		t.Fatalf("expected no changes, got %+v", diff)
+	}
+
+	// A shrunken prefix is removed space, not a rewrite
+	diff, err = CompareLists(strings.NewReader("10.0.0.0/23\n"), strings.NewReader("10.0.0.0/24\n"))
+	if err != nil {
+		t.Fatalf("CompareLists returned error: %v", err)
+	}
+	if len(diff.Reaggregated) != 0 || !slices.Equal(diff.Removed, []string{"10.0.1.0/24"}) {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+
+	if _, err := CompareLists(strings.NewReader("10.0.0.0/8\nbogus\n"), strings.NewReader("")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected a line number in the error, got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package cidr
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"gopkg.in/yaml.v3"
+)
+
+// Relationships between two overlapping prefixes. Two CIDRs either nest or
+// are disjoint, so every overlap is one of these.
+const (
+	RelationDuplicate = "duplicate" // Both entries cover the same range
+	RelationContains  = "contains"  // Outer strictly contains Inner
+)
+
+// PlanEntry is one prefix of an address plan
+type PlanEntry struct {
+	Prefix string `json:"prefix" yaml:"prefix"`
+	Label  string `json:"label,omitempty" yaml:"label,omitempty"` // Rest of the line after the prefix
+	Line   int    `json:"line" yaml:"line"`                       // 1-based line number (0 = not from a file)
+
+	prefix netip.Prefix
+}
+
+// Conflict is a pair of overlapping plan entries
+type Conflict struct {
+	Outer    PlanEntry `json:"outer" yaml:"outer"` // The larger prefix, or the earlier line for duplicates
+	Inner    PlanEntry `json:"inner" yaml:"inner"`
+	Relation string    `json:"relation" yaml:"relation"`
+}
+
+// ConflictReport lists every overlapping pair in an address plan
+type ConflictReport struct {
+	Prefixes  int        `json:"prefixes" yaml:"prefixes"`
+	Conflicts []Conflict `json:"conflicts" yaml:"conflicts"`
+}
+
+// ToJSON converts ConflictReport to JSON string
+func (r *ConflictReport) ToJSON() (string, error) {
+	bytes, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// ToYAML converts ConflictReport to YAML string
+func (r *ConflictReport) ToYAML() (string, error) {
+	bytes, err := yaml.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// NewPlanEntry parses a CIDR, or a bare address as a host prefix
+func NewPlanEntry(prefix, label string, line int) (PlanEntry, error) {
+	entry := PlanEntry{Label: label, Line: line}
+	if !strings.Contains(prefix, "/") {
+		addr, err := netip.ParseAddr(prefix)
+		if err != nil {
+			return entry, NewValidationError("ip", prefix, ErrInvalidIP)
+		}
+		addr = addr.WithZone("")
+		entry.prefix = netip.PrefixFrom(addr, addr.BitLen())
+	} else {
+		parsed, err := netip.ParsePrefix(prefix)
+		if err != nil {
+			return entry, NewCIDRError("parse", prefix, ErrInvalidCIDR)
+		}
+		entry.prefix = parsed.Masked()
+	}
+	entry.Prefix = entry.prefix.String()
+	return entry, nil
+}
+
+// ReadPlan reads an address plan: one CIDR or address per line, optionally
+// followed by a label such as a VPC or subnet name. Blank lines and #
+// comments are skipped.
+func ReadPlan(r io.Reader) ([]PlanEntry, error) {
+	var entries []PlanEntry
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		entry, err := NewPlanEntry(fields[0], strings.Join(fields[1:], " "), line)
+		if err != nil {
+			return nil, errcode.Wrap(errcode.Of(err), fmt.Errorf("line %d: %w", line, err))
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// FindConflicts reports every pair of entries that overlap, ordered by the
+// outer prefix and then the inner one
+func FindConflicts(entries []PlanEntry) *ConflictReport {
+	sorted := slices.Clone(entries)
+	slices.SortStableFunc(sorted, func(a, b PlanEntry) int {
+		if c := a.prefix.Addr().Compare(b.prefix.Addr()); c != 0 {
+			return c
+		}
+		return a.prefix.Bits() - b.prefix.Bits()
+	})
+
+	// Sorted by address with wider prefixes first, each entry overlaps exactly
+	// the entries still on the stack once those that end before it are popped
+	report := &ConflictReport{Prefixes: len(entries), Conflicts: []Conflict{}}
+	var stack []PlanEntry
+	for _, entry := range sorted {
+		for len(stack) > 0 && !containsPrefix(stack[len(stack)-1].prefix, entry.prefix) {
+			stack = stack[:len(stack)-1]
+		}
+		for _, outer := range stack {
+			relation := RelationContains
+			if outer.prefix == entry.prefix {
+				relation = RelationDuplicate
+			}
+			report.Conflicts = append(report.Conflicts, Conflict{Outer: outer, Inner: entry, Relation: relation})
+		}
+		stack = append(stack, entry)
+	}
+
+	slices.SortStableFunc(report.Conflicts, func(a, b Conflict) int {
+		if c := a.Outer.prefix.Addr().Compare(b.Outer.prefix.Addr()); c != 0 {
+			return c
+		}
+		return a.Outer.prefix.Bits() - b.Outer.prefix.Bits()
+	})
+	return report
+}
+
+// containsPrefix reports whether outer covers every address of inner
+func containsPrefix(outer, inner netip.Prefix) bool {
+	return outer.Bits() <= inner.Bits() && outer.Contains(inner.Addr())
+}
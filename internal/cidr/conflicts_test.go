@@ -0,0 +1,83 @@
+package cidr
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+func TestReadPlan(t *testing.T) {
+	plan := `# VPC plan
+10.0.0.0/16   prod vpc
+10.0.1.0/24   # unlabelled
+10.0.0.5/24 mgmt
+
+2001:db8::1
+`
+	entries, err := ReadPlan(strings.NewReader(plan))
+	if err != nil {
+		t.Fatalf("ReadPlan returned error: %v", err)
+	}
+	want := []PlanEntry{
+		{Prefix: "10.0.0.0/16", Label: "prod vpc", Line: 2},
+		{Prefix: "10.0.1.0/24", Line: 3},
+		{Prefix: "10.0.0.0/24", Label: "mgmt", Line: 4},
+		{Prefix: "2001:db8::1/128", Line: 6},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("ReadPlan returned %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, entry := range entries {
+		if entry.Prefix != want[i].Prefix || entry.Label != want[i].Label || entry.Line != want[i].Line {
+			t.Errorf("entry %d = %+v, want %+v", i, entry, want[i])
+		}
+	}
+
+	_, err = ReadPlan(strings.NewReader("10.0.0.0/16\n10.0.0.0/33 bad\n"))
+	if errcode.Of(err) != errcode.CIDRInvalid || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected CIDR001 on line 2, got %v", err)
+	}
+}
+
+func TestFindConflicts(t *testing.T) {
+	entries, err := ReadPlan(strings.NewReader(`10.0.1.0/24 app
+10.0.0.0/16 vpc
+10.1.0.0/16 other
+10.0.1.128/25 app-b
+10.0.1.0/24 app-dup
+2001:db8::/32
+2001:db8:1::/48
+192.168.0.0/24
+`))
+	if err != nil {
+		t.Fatalf("ReadPlan returned error: %v", err)
+	}
+
+	report := FindConflicts(entries)
+	if report.Prefixes != 8 {
+		t.Fatalf("Prefixes = %d, want 8", report.Prefixes)
+	}
+
+	want := []string{
+		"10.0.0.0/16:2 contains 10.0.1.0/24:1",
+		"10.0.0.0/16:2 contains 10.0.1.0/24:5",
+		"10.0.0.0/16:2 contains 10.0.1.128/25:4",
+		"10.0.1.0/24:1 duplicate 10.0.1.0/24:5",
+		"10.0.1.0/24:1 contains 10.0.1.128/25:4",
+		"10.0.1.0/24:5 contains 10.0.1.128/25:4",
+		"2001:db8::/32:6 contains 2001:db8:1::/48:7",
+	}
+	var got []string
+	for _, c := range report.Conflicts {
+		got = append(got, fmt.Sprintf("%s:%d %s %s:%d", c.Outer.Prefix, c.Outer.Line, c.Relation, c.Inner.Prefix, c.Inner.Line))
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("conflicts:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if clean := FindConflicts(entries[2:3]); len(clean.Conflicts) != 0 {
+		t.Fatalf("expected no conflicts for a single prefix, got %+v", clean.Conflicts)
+	}
+}
@@ -3,6 +3,8 @@ package cidr
 import (
 	"errors"
 	"fmt"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
 )
 
 // CIDRError represents a CIDR-specific error with operation context
@@ -38,13 +40,16 @@ func (e *ValidationError) Unwrap() error {
 	return e.Err
 }
 
-// Common error variables
+// Common error variables, each carrying its stable error code
 var (
-	ErrInvalidCIDR      = errors.New("invalid CIDR format")
-	ErrInvalidIP        = errors.New("invalid IP address")
-	ErrTooLarge         = errors.New("CIDR range too large for expansion")
-	ErrInvalidParts     = errors.New("invalid number of parts")
-	ErrInsufficientBits = errors.New("insufficient host bits for division")
+	ErrInvalidCIDR      = errcode.Wrap(errcode.CIDRInvalid, errors.New("invalid CIDR format"))
+	ErrInvalidIP        = errcode.Wrap(errcode.CIDRInvalidIP, errors.New("invalid IP address"))
+	ErrTooLarge         = errcode.Wrap(errcode.CIDRTooLarge, errors.New("CIDR range too large for expansion"))
+	ErrInvalidParts     = errcode.Wrap(errcode.CIDRInvalidParts, errors.New("invalid number of parts"))
+	ErrInsufficientBits = errcode.Wrap(errcode.CIDRInsufficientBits, errors.New("insufficient host bits for division"))
+	ErrInvalidPrefix    = errcode.Wrap(errcode.CIDRInvalid, errors.New("prefix length shorter than the network"))
+	ErrNotIPv6          = errcode.Wrap(errcode.CIDRInvalid, errors.New("not an IPv6 prefix"))
+	ErrNotNibbleAligned = errcode.Wrap(errcode.CIDRInvalid, errors.New("prefix length is not a multiple of 4"))
 )
 
 // Error creation helpers
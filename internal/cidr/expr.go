@@ -0,0 +1,235 @@
+package cidr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// SetResolver loads the set an @name reference in an expression stands for
+type SetResolver func(name string) (*Set, error)
+
+// Eval evaluates a set expression such as
+//
+//	10.0.0.0/8 & (192.168.0.0/16 | 10.1.0.0/16) - 10.1.2.0/24
+//
+// Operands are CIDRs, bare addresses, and @name references to sets loaded by
+// resolve. Operators, from tightest to loosest binding:
+//
+//	~a     complement
+//	a & b  intersection
+//	a | b  union, a - b difference (left to right)
+//
+// A complement covers the address families that appear anywhere in the
+// expression, so ~10.0.0.0/8 is every other IPv4 address.
+func Eval(expr string, resolve SetResolver) (*Set, error) {
+	p := &exprParser{input: expr, resolve: resolve}
+	p.next()
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, p.unexpected()
+	}
+	return root(p.ipv4, p.ipv6), nil
+}
+
+// setExpr computes a subexpression once the families complements cover are known
+type setExpr func(ipv4, ipv6 bool) *Set
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenOperand
+	tokenName
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int // Byte offset in the expression, for error messages
+}
+
+type exprParser struct {
+	input   string
+	offset  int
+	tok     token
+	resolve SetResolver
+
+	// Families seen so far, which complements cover
+	ipv4, ipv6 bool
+}
+
+// next advances to the following token
+func (p *exprParser) next() {
+	for p.offset < len(p.input) && strings.IndexByte(" \t\r\n", p.input[p.offset]) >= 0 {
+		p.offset++
+	}
+	start := p.offset
+	if p.offset == len(p.input) {
+		p.tok = token{kind: tokenEOF, pos: start}
+		return
+	}
+
+	c := p.input[p.offset]
+	switch {
+	case strings.IndexByte("&|-~()", c) >= 0:
+		p.offset++
+		p.tok = token{kind: tokenOp, text: string(c), pos: start}
+	case c == '@':
+		p.offset++
+		for p.offset < len(p.input) && isNameByte(p.input[p.offset]) {
+			p.offset++
+		}
+		p.tok = token{kind: tokenName, text: p.input[start+1 : p.offset], pos: start}
+	default:
+		for p.offset < len(p.input) && isOperandByte(p.input[p.offset]) {
+			p.offset++
+		}
+		if p.offset == start {
+			// Leave the stray character for unexpected to report
+			p.offset++
+		}
+		p.tok = token{kind: tokenOperand, text: p.input[start:p.offset], pos: start}
+	}
+}
+
+// parseExpr handles union and difference
+func (p *exprParser) parseExpr() (setExpr, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokenOp && (p.tok.text == "|" || p.tok.text == "-") {
+		op := p.tok.text
+		p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		a, b := left, right
+		if op == "|" {
+			left = func(ipv4, ipv6 bool) *Set { return a(ipv4, ipv6).Union(b(ipv4, ipv6)) }
+		} else {
+			left = func(ipv4, ipv6 bool) *Set { return a(ipv4, ipv6).Difference(b(ipv4, ipv6)) }
+		}
+	}
+	return left, nil
+}
+
+// parseTerm handles intersection
+func (p *exprParser) parseTerm() (setExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokenOp && p.tok.text == "&" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		a, b := left, right
+		left = func(ipv4, ipv6 bool) *Set { return a(ipv4, ipv6).Intersect(b(ipv4, ipv6)) }
+	}
+	return left, nil
+}
+
+// parseUnary handles complement
+func (p *exprParser) parseUnary() (setExpr, error) {
+	if p.tok.kind == tokenOp && p.tok.text == "~" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(ipv4, ipv6 bool) *Set { return operand(ipv4, ipv6).Complement(ipv4, ipv6) }, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary handles parentheses, @name references, and literals
+func (p *exprParser) parsePrimary() (setExpr, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokenOp:
+		if tok.text != "(" {
+			return nil, p.unexpected()
+		}
+		p.next()
+		inner, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokenOp || p.tok.text != ")" {
+			return nil, p.unexpected()
+		}
+		p.next()
+		return inner, nil
+
+	case tokenName:
+		if tok.text == "" {
+			return nil, errcode.Errorf(errcode.CIDRInvalidExpr, "missing set name after @ at position %d", tok.pos+1)
+		}
+		if p.resolve == nil {
+			return nil, errcode.Errorf(errcode.CIDRInvalidExpr, "unknown set @%s", tok.text)
+		}
+		set, err := p.resolve(tok.text)
+		if err != nil {
+			return nil, err
+		}
+		p.next()
+		return p.literal(set), nil
+
+	case tokenOperand:
+		set, err := ParseSet([]string{tok.text})
+		if err != nil {
+			return nil, errcode.Wrap(errcode.Of(err), fmt.Errorf("at position %d: %w", tok.pos+1, err))
+		}
+		p.next()
+		return p.literal(set), nil
+	}
+	return nil, p.unexpected()
+}
+
+// literal records the families a leaf set contributes to complements
+func (p *exprParser) literal(set *Set) setExpr {
+	p.ipv4 = p.ipv4 || set.HasIPv4()
+	p.ipv6 = p.ipv6 || set.HasIPv6()
+	return func(bool, bool) *Set { return set }
+}
+
+func (p *exprParser) unexpected() error {
+	if p.tok.kind == tokenEOF {
+		return errcode.Errorf(errcode.CIDRInvalidExpr, "unexpected end of expression")
+	}
+	return errcode.Errorf(errcode.CIDRInvalidExpr, "unexpected %q at position %d", p.tok.text, p.tok.pos+1)
+}
+
+// isOperandByte accepts the characters of IPv4 and IPv6 addresses and CIDRs
+func isOperandByte(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F' || c == '.' || c == ':' || c == '/'
+}
+
+// IsSetName reports whether name can be written as an @name reference
+func IsSetName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isNameByte(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isNameByte accepts the characters of set names. Hyphens are excluded so
+// @a-@b reads as a difference.
+func isNameByte(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '.'
+}
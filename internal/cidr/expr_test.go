@@ -0,0 +1,76 @@
+package cidr
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+func TestEval(t *testing.T) {
+	sets := map[string][]string{
+		"corp":  {"10.0.0.0/8", "172.16.0.0/12"},
+		"cloud": {"10.128.0.0/9"},
+	}
+	resolve := func(name string) (*Set, error) {
+		entries, ok := sets[name]
+		if !ok {
+			return nil, errcode.Errorf(errcode.CIDRInvalidExpr, "unknown set @%s", name)
+		}
+		return ParseSet(entries)
+	}
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{expr: "10.0.0.0/8 & (192.168.0.0/16 | 10.1.0.0/16) - 10.1.2.0/24", want: []string{
+			"10.1.0.0/23", "10.1.3.0/24", "10.1.4.0/22", "10.1.8.0/21", "10.1.16.0/20", "10.1.32.0/19", "10.1.64.0/18", "10.1.128.0/17",
+		}},
+		{expr: "10.0.0.0/24 - 10.0.0.0/25 - 10.0.0.128/26", want: []string{"10.0.0.192/26"}},
+		{expr: "10.0.0.0/24|10.0.1.0/24", want: []string{"10.0.0.0/23"}},
+		{expr: "~128.0.0.0/1", want: []string{"0.0.0.0/1"}},
+		{expr: "~~10.0.0.0/8", want: []string{"10.0.0.0/8"}},
+		{expr: "~10.0.0.0/8 & 2001:db8::/32", want: []string{"2001:db8::/32"}},
+		{expr: "@corp - @cloud", want: []string{"10.0.0.0/9", "172.16.0.0/12"}},
+		{expr: "@corp & @cloud | 2001:db8::1", want: []string{"10.128.0.0/9", "2001:db8::1/128"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			set, err := Eval(tt.expr, resolve)
+			if err != nil {
+				t.Fatalf("Eval returned error: %v", err)
+			}
+			if got := set.Strings(); !slices.Equal(got, tt.want) {
+				t.Fatalf("Eval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		code errcode.Code
+		want string
+	}{
+		{expr: "", code: errcode.CIDRInvalidExpr, want: "unexpected end"},
+		{expr: "10.0.0.0/8 &", code: errcode.CIDRInvalidExpr, want: "unexpected end"},
+		{expr: "(10.0.0.0/8", code: errcode.CIDRInvalidExpr, want: "unexpected end"},
+		{expr: "10.0.0.0/8)", code: errcode.CIDRInvalidExpr, want: `unexpected ")" at position 11`},
+		{expr: "10.0.0.0/8 10.1.0.0/16", code: errcode.CIDRInvalidExpr, want: "position 12"},
+		{expr: "10.0.0.0/8 + 10.1.0.0/16", code: errcode.CIDRInvalidExpr, want: `unexpected "+"`},
+		{expr: "@ | 10.0.0.0/8", code: errcode.CIDRInvalidExpr, want: "missing set name"},
+		{expr: "@corp", code: errcode.CIDRInvalidExpr, want: "unknown set @corp"},
+		{expr: "10.0.0.0/40", code: errcode.CIDRInvalid, want: "at position 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Eval(tt.expr, nil)
+			if errcode.Of(err) != tt.code || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Eval(%q) = %v, want %s error containing %q", tt.expr, err, tt.code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package cidr
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strings"
+)
+
+// NibbleBits is the width of one ip6.arpa label: reverse DNS for IPv6 can
+// only be delegated at prefix lengths that are a multiple of it
+const NibbleBits = 4
+
+// NibbleBoundary rounds a prefix length up to the next nibble boundary
+func NibbleBoundary(bits int) int {
+	return (bits + NibbleBits - 1) / NibbleBits * NibbleBits
+}
+
+// SplitByNibble returns every subnet of a nibble-aligned length within an
+// IPv6 prefix, the units an ip6.arpa zone can be delegated in. With prefix 0
+// it splits at the next boundary: a prefix that is not nibble-aligned is
+// rounded up to one (a /46 gives four /48s), and an aligned one is split by
+// one nibble (a /48 gives sixteen /52s). Splits yielding more than
+// MaxDivideSubnets subnets fail with ErrTooLarge.
+func SplitByNibble(cidr string, prefix int) ([]netip.Prefix, error) {
+	network, err := parseIPv6Prefix("split-by-nibble", cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	if prefix == 0 {
+		prefix = NibbleBoundary(network.Bits())
+		if prefix == network.Bits() {
+			prefix += NibbleBits
+		}
+	}
+	switch {
+	case prefix%NibbleBits != 0:
+		return nil, NewValidationError("prefix", fmt.Sprintf("/%d", prefix), ErrNotNibbleAligned)
+	case prefix > network.Addr().BitLen():
+		return nil, NewValidationError("prefix", fmt.Sprintf("/%d", prefix), ErrInsufficientBits)
+	case prefix < network.Bits():
+		return nil, NewValidationError("prefix", fmt.Sprintf("/%d", prefix), ErrInvalidPrefix)
+	}
+
+	count := new(big.Int).Lsh(big.NewInt(1), uint(prefix-network.Bits()))
+	if count.Cmp(big.NewInt(MaxDivideSubnets)) > 0 {
+		return nil, NewCIDRError("split-by-nibble", cidr, ErrTooLarge)
+	}
+
+	subnets := make([]netip.Prefix, 0, count.Int64())
+	last := lastAddr(network)
+	for addr := network.Addr(); ; {
+		subnet := netip.PrefixFrom(addr, prefix)
+		subnets = append(subnets, subnet)
+		end := lastAddr(subnet)
+		if end == last {
+			return subnets, nil
+		}
+		addr = end.Next()
+	}
+}
+
+// ReverseZone returns the ip6.arpa zone of a nibble-aligned IPv6 prefix,
+// without the trailing dot: one label per nibble of the network, last
+// nibble first. Bits past the last whole nibble are ignored.
+func ReverseZone(network netip.Prefix) string {
+	labels := strings.Split(strings.TrimSuffix(ReverseName(network.Masked().Addr()), "."), ".")
+	// 32 nibble labels followed by "ip6" and "arpa"
+	return strings.Join(labels[32-network.Bits()/NibbleBits:], ".")
+}
+
+// ReverseZones returns the ip6.arpa zones that together hold the reverse
+// DNS of an IPv6 prefix: its own zone when it is nibble-aligned, otherwise
+// one per subnet at the next nibble boundary.
+func ReverseZones(cidr string) ([]string, error) {
+	network, err := parseIPv6Prefix("reverse-zones", cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	subnets := []netip.Prefix{network}
+	if boundary := NibbleBoundary(network.Bits()); boundary != network.Bits() {
+		if subnets, err = SplitByNibble(network.String(), boundary); err != nil {
+			return nil, err
+		}
+	}
+	zones := make([]string, len(subnets))
+	for i, subnet := range subnets {
+		zones[i] = ReverseZone(subnet)
+	}
+	return zones, nil
+}
+
+// parseIPv6Prefix parses and masks an IPv6 prefix
+func parseIPv6Prefix(op, cidr string) (netip.Prefix, error) {
+	network, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+	if err != nil {
+		return netip.Prefix{}, NewCIDRError(op, cidr, ErrInvalidCIDR)
+	}
+	if !network.Addr().Is6() || network.Addr().Is4In6() {
+		return netip.Prefix{}, NewCIDRError(op, cidr, ErrNotIPv6)
+	}
+	return network.Masked(), nil
+}
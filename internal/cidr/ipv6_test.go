@@ -0,0 +1,104 @@
+package cidr
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestSplitByNibble(t *testing.T) {
+	tests := []struct {
+		name   string
+		cidr   string
+		prefix int
+		count  int
+		first  string
+		last   string
+	}{
+		{name: "aligned splits by one nibble", cidr: "2001:db8::/32", count: 16, first: "2001:db8::/36", last: "2001:db8:f000::/36"},
+		{name: "unaligned rounds up", cidr: "2001:db8:4::/46", count: 4, first: "2001:db8:4::/48", last: "2001:db8:7::/48"},
+		{name: "explicit prefix", cidr: "2001:db8:1::/48", prefix: 56, count: 256, first: "2001:db8:1::/56", last: "2001:db8:1:ff00::/56"},
+		{name: "host bits are masked", cidr: "2001:db8:1::1/60", prefix: 64, count: 16, first: "2001:db8:1::/64", last: "2001:db8:1:f::/64"},
+		{name: "same length", cidr: "2001:db8::/48", prefix: 48, count: 1, first: "2001:db8::/48", last: "2001:db8::/48"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subnets, err := SplitByNibble(tt.cidr, tt.prefix)
+			if err != nil {
+				t.Fatalf("SplitByNibble returned error: %v", err)
+			}
+			if len(subnets) != tt.count {
+				t.Fatalf("expected %d subnets, got %d", tt.count, len(subnets))
+			}
+			if subnets[0].String() != tt.first || subnets[len(subnets)-1].String() != tt.last {
+				t.Fatalf("unexpected subnets %s ... %s", subnets[0], subnets[len(subnets)-1])
+			}
+		})
+	}
+}
+
+func TestSplitByNibbleErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		cidr   string
+		prefix int
+		want   error
+	}{
+		{name: "invalid", cidr: "2001:db8::", want: ErrInvalidCIDR},
+		{name: "ipv4", cidr: "10.0.0.0/8", want: ErrNotIPv6},
+		{name: "unaligned prefix", cidr: "2001:db8::/32", prefix: 50, want: ErrNotNibbleAligned},
+		{name: "shorter prefix", cidr: "2001:db8::/32", prefix: 28, want: ErrInvalidPrefix},
+		{name: "past the address", cidr: "2001:db8::/128", want: ErrInsufficientBits},
+		{name: "too many subnets", cidr: "2001:db8::/32", prefix: 64, want: ErrTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := SplitByNibble(tt.cidr, tt.prefix); !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestReverseZones(t *testing.T) {
+	tests := []struct {
+		cidr string
+		want []string
+	}{
+		{cidr: "2001:db8::/32", want: []string{"8.b.d.0.1.0.0.2.ip6.arpa"}},
+		{cidr: "2001:db8:abcd:12::/64", want: []string{"2.1.0.0.d.c.b.a.8.b.d.0.1.0.0.2.ip6.arpa"}},
+		{cidr: "2001:db8:4::/47", want: []string{"4.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", "5.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"}},
+		{cidr: "::/0", want: []string{"ip6.arpa"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cidr, func(t *testing.T) {
+			zones, err := ReverseZones(tt.cidr)
+			if err != nil {
+				t.Fatalf("ReverseZones returned error: %v", err)
+			}
+			if len(zones) != len(tt.want) {
+				t.Fatalf("ReverseZones(%s) = %q, want %q", tt.cidr, zones, tt.want)
+			}
+			for i := range zones {
+				if zones[i] != tt.want[i] {
+					t.Fatalf("ReverseZones(%s) = %q, want %q", tt.cidr, zones, tt.want)
+				}
+			}
+		})
+	}
+
+	if _, err := ReverseZones("192.0.2.0/24"); !errors.Is(err, ErrNotIPv6) {
+		t.Fatalf("expected IPv4 to be rejected, got %v", err)
+	}
+}
+
+func TestReverseZoneMatchesReverseName(t *testing.T) {
+	// The zone of a /124 is the PTR name of its addresses minus the last nibble
+	zone := ReverseZone(netip.MustParsePrefix("2001:db8::10/124"))
+	if name := ReverseName(netip.MustParseAddr("2001:db8::1a")); name != "a."+zone+"." {
+		t.Fatalf("reverse name %s is not in zone %s", name, zone)
+	}
+}
@@ -0,0 +1,156 @@
+package cidr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"math/rand/v2"
+	"net/netip"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// RandomOptions holds configuration for random sampling
+type RandomOptions struct {
+	Count  int        // Number of unique samples (0 = 1)
+	Prefix int        // Sample subnets of this length instead of addresses (0 = addresses)
+	Usable bool       // Skip the network and broadcast addresses, as usableRange does
+	Rand   *rand.Rand // Source of randomness (nil = randomly seeded)
+}
+
+// Random returns opts.Count unique addresses, or subnets of length
+// opts.Prefix, drawn uniformly from a CIDR range in the order they were
+// drawn. The same seeded opts.Rand always gives the same sample.
+func Random(cidr string, opts RandomOptions) ([]string, error) {
+	network, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+	if err != nil {
+		return nil, NewCIDRError("random", cidr, ErrInvalidCIDR)
+	}
+	network = network.Masked()
+
+	prefix := network.Addr().BitLen()
+	if opts.Prefix != 0 {
+		prefix = opts.Prefix
+	}
+	switch {
+	case prefix > network.Addr().BitLen():
+		return nil, NewValidationError("prefix", fmt.Sprintf("/%d", prefix), ErrInvalidCIDR)
+	case prefix < network.Bits():
+		return nil, NewValidationError("prefix", fmt.Sprintf("/%d", prefix), ErrInvalidPrefix)
+	case opts.Count < 0:
+		return nil, NewValidationError("count", fmt.Sprintf("%d", opts.Count), ErrInvalidParts)
+	}
+	count := max(opts.Count, 1)
+	r := opts.Rand
+	if r == nil {
+		r = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+
+	// Each sample is a k-bit index, shifted into place above the host bits
+	// of a subnet (none when sampling addresses)
+	k := uint(prefix - network.Bits())
+	shift := uint(network.Addr().BitLen() - prefix)
+	skipEnds := opts.Usable && opts.Prefix == 0 && network.Addr().Is4() && network.Bits() < MinPointToPointPrefixV4
+
+	// Only ranges of at most 2^32 samples can be exhausted or densely sampled
+	if k <= 32 {
+		population := uint64(1) << k
+		if skipEnds {
+			population -= 2
+		}
+		if uint64(count) > population {
+			return nil, errcode.Errorf(errcode.CIDRExhausted, "only %d /%d samples in %s, %d requested", population, prefix, network, count)
+		}
+		if uint64(count)*2 > population {
+			return randomDense(network, prefix, k, shift, skipEnds, count, r), nil
+		}
+	}
+
+	seen := make(map[uint128]bool, count)
+	samples := make([]string, 0, count)
+	last := uint128{lo: 1<<k - 1} // Only compared when skipEnds, so k <= 32
+	for len(samples) < count {
+		index := randomIndex(r, k)
+		if seen[index] || (skipEnds && (index == uint128{} || index == last)) {
+			continue
+		}
+		seen[index] = true
+		samples = append(samples, sample(network, prefix, index.lsh(shift)))
+	}
+	return samples, nil
+}
+
+// randomDense draws from a shuffled list of every index, for samples covering
+// more than half the range where rejecting repeats would get slow
+func randomDense(network netip.Prefix, prefix int, k, shift uint, skipEnds bool, count int, r *rand.Rand) []string {
+	first, n := uint64(0), uint64(1)<<k
+	if skipEnds {
+		first, n = 1, n-2
+	}
+
+	// Partial Fisher-Yates: only the first count positions are needed
+	indexes := make([]uint64, n)
+	for i := range indexes {
+		indexes[i] = first + uint64(i)
+	}
+	samples := make([]string, count)
+	for i := range samples {
+		j := uint64(i) + r.Uint64N(n-uint64(i))
+		indexes[i], indexes[j] = indexes[j], indexes[i]
+		samples[i] = sample(network, prefix, uint128{lo: indexes[i]}.lsh(shift))
+	}
+	return samples
+}
+
+// sample formats the address or subnet offset from the start of network
+func sample(network netip.Prefix, prefix int, offset uint128) string {
+	addr := offset.addTo(network.Addr())
+	if prefix == addr.BitLen() {
+		return addr.String()
+	}
+	return netip.PrefixFrom(addr, prefix).String()
+}
+
+// randomIndex returns a uniform k-bit number, k at most 128
+func randomIndex(r *rand.Rand, k uint) uint128 {
+	switch {
+	case k == 0:
+		return uint128{}
+	case k <= 64:
+		return uint128{lo: r.Uint64() >> (64 - k)}
+	default:
+		return uint128{hi: r.Uint64() >> (128 - k), lo: r.Uint64()}
+	}
+}
+
+// uint128 is an offset into an address range
+type uint128 struct {
+	hi, lo uint64
+}
+
+func (u uint128) lsh(n uint) uint128 {
+	switch {
+	case n == 0:
+		return u
+	case n >= 64:
+		return uint128{hi: u.lo << (n - 64)}
+	default:
+		return uint128{hi: u.hi<<n | u.lo>>(64-n), lo: u.lo << n}
+	}
+}
+
+// addTo adds u to addr, which must leave room for it
+func (u uint128) addTo(addr netip.Addr) netip.Addr {
+	if addr.Is4() {
+		a := addr.As4()
+		binary.BigEndian.PutUint32(a[:], binary.BigEndian.Uint32(a[:])+uint32(u.lo))
+		return netip.AddrFrom4(a)
+	}
+	a := addr.As16()
+	lo, carry := bits.Add64(binary.BigEndian.Uint64(a[8:]), u.lo, 0)
+	hi, _ := bits.Add64(binary.BigEndian.Uint64(a[:8]), u.hi, carry)
+	binary.BigEndian.PutUint64(a[:8], hi)
+	binary.BigEndian.PutUint64(a[8:], lo)
+	return netip.AddrFrom16(a)
+}
@@ -0,0 +1,135 @@
+package cidr
+
+import (
+	"math/rand/v2"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+func TestRandom(t *testing.T) {
+	tests := []struct {
+		name  string
+		cidr  string
+		opts  RandomOptions
+		check func(t *testing.T, sample string)
+	}{
+		{
+			name: "ipv4 addresses",
+			cidr: "10.0.0.0/8",
+			opts: RandomOptions{Count: 100},
+			check: func(t *testing.T, sample string) {
+				if !netip.MustParsePrefix("10.0.0.0/8").Contains(netip.MustParseAddr(sample)) {
+					t.Errorf("%s is outside 10.0.0.0/8", sample)
+				}
+			},
+		},
+		{
+			name: "ipv6 addresses",
+			cidr: "2001:db8::/32",
+			opts: RandomOptions{Count: 100},
+			check: func(t *testing.T, sample string) {
+				if !netip.MustParsePrefix("2001:db8::/32").Contains(netip.MustParseAddr(sample)) {
+					t.Errorf("%s is outside 2001:db8::/32", sample)
+				}
+			},
+		},
+		{
+			name: "usable addresses skip network and broadcast",
+			cidr: "192.168.1.0/29",
+			opts: RandomOptions{Count: 6, Usable: true},
+			check: func(t *testing.T, sample string) {
+				if sample == "192.168.1.0" || sample == "192.168.1.7" {
+					t.Errorf("sampled %s with Usable", sample)
+				}
+			},
+		},
+		{
+			name: "subnets",
+			cidr: "10.0.0.0/16",
+			opts: RandomOptions{Count: 200, Prefix: 24},
+			check: func(t *testing.T, sample string) {
+				subnet := netip.MustParsePrefix(sample)
+				if subnet.Bits() != 24 || subnet.Masked() != subnet || !netip.MustParsePrefix("10.0.0.0/16").Overlaps(subnet) {
+					t.Errorf("%s is not a /24 of 10.0.0.0/16", sample)
+				}
+			},
+		},
+		{
+			name: "ipv6 subnets of a large range",
+			cidr: "::/0",
+			opts: RandomOptions{Count: 50, Prefix: 64},
+			check: func(t *testing.T, sample string) {
+				if subnet := netip.MustParsePrefix(sample); subnet.Bits() != 64 || subnet.Masked() != subnet {
+					t.Errorf("%s is not an aligned /64", sample)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Rand = rand.New(rand.NewPCG(42, 42))
+			samples, err := Random(tt.cidr, tt.opts)
+			if err != nil {
+				t.Fatalf("Random returned error: %v", err)
+			}
+			if len(samples) != tt.opts.Count {
+				t.Fatalf("got %d samples, want %d", len(samples), tt.opts.Count)
+			}
+			seen := map[string]bool{}
+			for _, sample := range samples {
+				if seen[sample] {
+					t.Errorf("%s sampled twice", sample)
+				}
+				seen[sample] = true
+				tt.check(t, sample)
+			}
+
+			tt.opts.Rand = rand.New(rand.NewPCG(42, 42))
+			again, _ := Random(tt.cidr, tt.opts)
+			if !slices.Equal(samples, again) {
+				t.Errorf("the same seed gave different samples")
+			}
+		})
+	}
+}
+
+func TestRandomCoversSmallRanges(t *testing.T) {
+	samples, err := Random("2001:db8::/126", RandomOptions{Count: 4, Rand: rand.New(rand.NewPCG(1, 2))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(samples)
+	if !slices.Equal(samples, []string{"2001:db8::", "2001:db8::1", "2001:db8::2", "2001:db8::3"}) {
+		t.Fatalf("expected every address of a /126, got %v", samples)
+	}
+
+	samples, err = Random("198.51.100.7/32", RandomOptions{})
+	if err != nil || !slices.Equal(samples, []string{"198.51.100.7"}) {
+		t.Fatalf("Random(/32) = %v, %v", samples, err)
+	}
+}
+
+func TestRandomErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cidr string
+		opts RandomOptions
+		code errcode.Code
+	}{
+		{name: "invalid cidr", cidr: "10.0.0.0/33", code: errcode.CIDRInvalid},
+		{name: "more samples than addresses", cidr: "10.0.0.0/30", opts: RandomOptions{Count: 5}, code: errcode.CIDRExhausted},
+		{name: "usable leaves two fewer", cidr: "10.0.0.0/30", opts: RandomOptions{Count: 3, Usable: true}, code: errcode.CIDRExhausted},
+		{name: "prefix shorter than the range", cidr: "10.0.0.0/16", opts: RandomOptions{Prefix: 8}, code: errcode.CIDRInvalid},
+		{name: "negative count", cidr: "10.0.0.0/16", opts: RandomOptions{Count: -1}, code: errcode.CIDRInvalidParts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Random(tt.cidr, tt.opts); errcode.Of(err) != tt.code {
+				t.Fatalf("Random error = %v, want %s", err, tt.code)
+			}
+		})
+	}
+}
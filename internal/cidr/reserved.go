@@ -0,0 +1,79 @@
+package cidr
+
+import (
+	"math/big"
+	"net/netip"
+	"sync"
+)
+
+// SpecialPrefix is one block of the IANA special-purpose address registries
+type SpecialPrefix struct {
+	Prefix      netip.Prefix
+	Class       string
+	Description string
+	RFC         string
+	Global      bool // Reachable across the internet despite being special
+	Reserved    bool // Never assignable to hosts, so excluded from capacity planning
+}
+
+// reservedClasses are the special-purpose classes whose addresses no ordinary
+// network can hand out. Private, CGN, benchmarking, and the transition
+// prefixes are special but still assignable, so they are not here.
+var reservedClasses = map[string]bool{
+	ClassUnspecified:   true,
+	ClassThisNetwork:   true,
+	ClassLoopback:      true,
+	ClassLinkLocal:     true,
+	ClassProtocol:      true,
+	ClassDocumentation: true,
+	ClassMulticast:     true,
+	ClassReserved:      true,
+	ClassBroadcast:     true,
+	ClassIPv4Mapped:    true,
+	ClassDiscardOnly:   true,
+}
+
+// SpecialPrefixes returns the special-purpose registry Classify uses, IPv4
+// blocks first
+func SpecialPrefixes() []SpecialPrefix {
+	prefixes := make([]SpecialPrefix, len(specialRanges))
+	for i, r := range specialRanges {
+		prefixes[i] = SpecialPrefix{
+			Prefix:      r.prefix,
+			Class:       r.class,
+			Description: r.description,
+			RFC:         r.rfc,
+			Global:      r.global,
+			Reserved:    reservedClasses[r.class],
+		}
+	}
+	return prefixes
+}
+
+// reservedSet holds every address of the reserved special-purpose blocks
+var reservedSet = sync.OnceValue(func() *Set {
+	var ranges []addrRange
+	for _, r := range specialRanges {
+		if reservedClasses[r.class] {
+			ranges = append(ranges, addrRange{r.prefix.Addr(), lastAddr(r.prefix)})
+		}
+	}
+	return newSet(ranges)
+})
+
+// ExcludeReserved returns the addresses of network outside every reserved
+// special-purpose block: what is left to assign to hosts
+func ExcludeReserved(network netip.Prefix) *Set {
+	network = network.Masked()
+	return newSet([]addrRange{{network.Addr(), lastAddr(network)}}).Difference(reservedSet())
+}
+
+// CountAssignable returns the number of addresses in a CIDR range outside
+// the reserved special-purpose blocks
+func CountAssignable(cidr string) (*big.Int, error) {
+	network, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, NewCIDRError("count", cidr, ErrInvalidCIDR)
+	}
+	return ExcludeReserved(network).Size(), nil
+}
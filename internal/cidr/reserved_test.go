@@ -0,0 +1,79 @@
+package cidr
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+func TestSpecialPrefixes(t *testing.T) {
+	reserved := make(map[string]bool)
+	for _, p := range SpecialPrefixes() {
+		reserved[p.Prefix.String()] = p.Reserved
+	}
+	for prefix, want := range map[string]bool{
+		"0.0.0.0/8":      true,
+		"127.0.0.0/8":    true,
+		"169.254.0.0/16": true,
+		"224.0.0.0/4":    true,
+		"fe80::/10":      true,
+		"10.0.0.0/8":     false,
+		"100.64.0.0/10":  false,
+		"fc00::/7":       false,
+		"2002::/16":      false,
+	} {
+		got, ok := reserved[prefix]
+		if !ok || got != want {
+			t.Errorf("%s: reserved = %v (listed %v), want %v", prefix, got, ok, want)
+		}
+	}
+}
+
+func TestExcludeReserved(t *testing.T) {
+	tests := []struct {
+		cidr string
+		want []string
+	}{
+		{"192.0.0.0/22", []string{"192.0.1.0/24", "192.0.3.0/24"}},
+		{"10.0.0.0/8", []string{"10.0.0.0/8"}},
+		{"127.0.0.0/16", nil},
+		{"fe00::/9", []string{"fe00::/9"}},
+		{"fe80::/9", []string{"fec0::/10"}},
+	}
+	for _, tt := range tests {
+		got := ExcludeReserved(netip.MustParsePrefix(tt.cidr)).Strings()
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ExcludeReserved(%s) = %v, want %v", tt.cidr, got, tt.want)
+		}
+	}
+
+	count, err := CountAssignable("192.0.0.0/22")
+	if err != nil || count.Int64() != 512 {
+		t.Errorf("CountAssignable = %v, %v, want 512", count, err)
+	}
+	if _, err := CountAssignable("invalid"); errcode.Of(err) != errcode.CIDRInvalid {
+		t.Errorf("expected CIDR001, got %v", err)
+	}
+}
+
+func TestExpandExcludeReserved(t *testing.T) {
+	var ips []string
+	var indexes []uint64
+	for result := range Expand(context.Background(), "192.0.0.0/22", ExpansionOptions{ExcludeReserved: true, Limit: 258}) {
+		if result.Err != nil {
+			t.Fatalf("Expand returned error: %v", result.Err)
+		}
+		ips = append(ips, result.IP)
+		indexes = append(indexes, result.Index)
+	}
+	if len(ips) != 258 {
+		t.Fatalf("Expand returned %d addresses, want the limit of 258", len(ips))
+	}
+	// 192.0.2.0/24 is skipped, and indexes count from 192.0.0.0
+	if ips[0] != "192.0.1.0" || indexes[0] != 256 || ips[256] != "192.0.3.0" || indexes[256] != 768 {
+		t.Fatalf("unexpected expansion: %s@%d, %s@%d", ips[0], indexes[0], ips[256], indexes[256])
+	}
+}
@@ -0,0 +1,365 @@
+package cidr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// Set is a set of IPv4 and IPv6 addresses, held as sorted ranges that neither
+// overlap nor touch. The zero value is the empty set.
+type Set struct {
+	ranges []addrRange
+}
+
+// addrRange is an inclusive range of addresses from one family
+type addrRange struct {
+	from, to netip.Addr
+}
+
+var (
+	ipv4Universe = addrRange{netip.IPv4Unspecified(), netip.AddrFrom4([4]byte{255, 255, 255, 255})}
+	ipv6Universe = addrRange{netip.IPv6Unspecified(), netip.AddrFrom16([16]byte{
+		255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255, 255,
+	})}
+)
+
+// ParseSet builds a set from CIDRs and bare addresses. Host bits in a CIDR are
+// ignored, so 10.1.2.3/16 means 10.1.0.0/16.
+func ParseSet(entries []string) (*Set, error) {
+	ranges := make([]addrRange, 0, len(entries))
+	for _, entry := range entries {
+		r, err := parseRange(strings.TrimSpace(entry))
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	return newSet(ranges), nil
+}
+
+// Aggregate merges CIDRs and addresses into the fewest CIDR blocks that cover
+// the same addresses, collapsing adjacent and contained prefixes. IPv4 blocks
+// come first, each family in address order.
+func Aggregate(prefixes []string) ([]string, error) {
+	set, err := ParseSet(prefixes)
+	if err != nil {
+		return nil, err
+	}
+	return set.Strings(), nil
+}
+
+// Subtract removes every exclusion from base and returns the fewest CIDR
+// blocks covering what is left. Exclusions may overlap each other or reach
+// outside base; those of the other address family remove nothing.
+func Subtract(base string, excludes []string) ([]string, error) {
+	baseSet, err := ParseSet([]string{base})
+	if err != nil {
+		return nil, err
+	}
+	excludeSet, err := ParseSet(excludes)
+	if err != nil {
+		return nil, err
+	}
+	return baseSet.Difference(excludeSet).Strings(), nil
+}
+
+// RangeToCIDRs returns the fewest CIDR blocks covering every address from
+// start to end inclusive. Both ends must be addresses of the same family.
+func RangeToCIDRs(start, end string) ([]string, error) {
+	from, err := netip.ParseAddr(strings.TrimSpace(start))
+	if err != nil {
+		return nil, NewValidationError("start", start, ErrInvalidIP)
+	}
+	to, err := netip.ParseAddr(strings.TrimSpace(end))
+	if err != nil {
+		return nil, NewValidationError("end", end, ErrInvalidIP)
+	}
+	from, to = from.WithZone(""), to.WithZone("")
+	if from.Is4() != to.Is4() {
+		return nil, errcode.Errorf(errcode.CIDRInvalidIP, "range %s-%s mixes IPv4 and IPv6", from, to)
+	}
+	if from.Compare(to) > 0 {
+		return nil, errcode.Errorf(errcode.CIDRInvalidIP, "range start %s is after end %s", from, to)
+	}
+	return newSet([]addrRange{{from, to}}).Strings(), nil
+}
+
+// CIDRToRange returns the first and last addresses of a CIDR block
+func CIDRToRange(cidr string) (string, string, error) {
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+	if err != nil {
+		return "", "", NewCIDRError("range", cidr, ErrInvalidCIDR)
+	}
+	prefix = prefix.Masked()
+	return prefix.Addr().String(), lastAddr(prefix).String(), nil
+}
+
+// ReadSet reads one CIDR or address per line. Blank lines and text after a #
+// are ignored.
+func ReadSet(r io.Reader) (*Set, error) {
+	ranges, err := readRanges(r)
+	if err != nil {
+		return nil, err
+	}
+	return newSet(ranges), nil
+}
+
+// readRanges reads the lines of a prefix list, one range per CIDR or address
+func readRanges(r io.Reader) ([]addrRange, error) {
+	var ranges []addrRange
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		entry, _, _ := strings.Cut(scanner.Text(), "#")
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		rng, err := parseRange(entry)
+		if err != nil {
+			return nil, errcode.Wrap(errcode.Of(err), fmt.Errorf("line %d: %w", line, err))
+		}
+		ranges = append(ranges, rng)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ranges, nil
+}
+
+func parseRange(entry string) (addrRange, error) {
+	if !strings.Contains(entry, "/") {
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return addrRange{}, NewValidationError("ip", entry, ErrInvalidIP)
+		}
+		addr = addr.WithZone("")
+		return addrRange{addr, addr}, nil
+	}
+	prefix, err := netip.ParsePrefix(entry)
+	if err != nil {
+		return addrRange{}, NewCIDRError("parse", entry, ErrInvalidCIDR)
+	}
+	prefix = prefix.Masked()
+	return addrRange{prefix.Addr(), lastAddr(prefix)}, nil
+}
+
+// newSet sorts ranges and merges any that overlap or touch
+func newSet(ranges []addrRange) *Set {
+	slices.SortFunc(ranges, func(a, b addrRange) int { return a.from.Compare(b.from) })
+
+	merged := make([]addrRange, 0, len(ranges))
+	for _, r := range ranges {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			if r.from.Compare(last.to) <= 0 || last.to.Next() == r.from {
+				if r.to.Compare(last.to) > 0 {
+					last.to = r.to
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return &Set{ranges: merged}
+}
+
+// IsEmpty reports whether the set holds no addresses
+func (s *Set) IsEmpty() bool {
+	return len(s.ranges) == 0
+}
+
+// HasIPv4 reports whether the set holds any IPv4 address
+func (s *Set) HasIPv4() bool {
+	return len(s.ranges) > 0 && s.ranges[0].from.Is4()
+}
+
+// HasIPv6 reports whether the set holds any IPv6 address
+func (s *Set) HasIPv6() bool {
+	return len(s.ranges) > 0 && s.ranges[len(s.ranges)-1].from.Is6()
+}
+
+// Size returns the number of addresses in the set
+func (s *Set) Size() *big.Int {
+	total := new(big.Int)
+	for _, r := range s.ranges {
+		total.Add(total, rangeSize(r))
+	}
+	return total
+}
+
+// Contains reports whether addr is in the set
+func (s *Set) Contains(addr netip.Addr) bool {
+	addr = addr.WithZone("")
+	i, found := slices.BinarySearchFunc(s.ranges, addr, func(r addrRange, target netip.Addr) int {
+		return r.from.Compare(target)
+	})
+	if found {
+		return true
+	}
+	return i > 0 && s.ranges[i-1].to.Compare(addr) >= 0
+}
+
+// containsRange reports whether every address of r is in the set. Ranges in
+// a set never touch, so r must lie within one of them.
+func (s *Set) containsRange(r addrRange) bool {
+	i, found := slices.BinarySearchFunc(s.ranges, r.from, func(sr addrRange, target netip.Addr) int {
+		return sr.from.Compare(target)
+	})
+	if !found {
+		i--
+	}
+	return i >= 0 && s.ranges[i].to.Compare(r.to) >= 0
+}
+
+// Union returns the addresses in either set
+func (s *Set) Union(other *Set) *Set {
+	return newSet(slices.Concat(s.ranges, other.ranges))
+}
+
+// Intersect returns the addresses in both sets
+func (s *Set) Intersect(other *Set) *Set {
+	var ranges []addrRange
+	i, j := 0, 0
+	for i < len(s.ranges) && j < len(other.ranges) {
+		a, b := s.ranges[i], other.ranges[j]
+		from, to := maxAddr(a.from, b.from), minAddr(a.to, b.to)
+		if from.Compare(to) <= 0 {
+			ranges = append(ranges, addrRange{from, to})
+		}
+		if a.to.Compare(b.to) < 0 {
+			i++
+		} else {
+			j++
+		}
+	}
+	return &Set{ranges: ranges}
+}
+
+// Difference returns the addresses in s that are not in other
+func (s *Set) Difference(other *Set) *Set {
+	return s.Intersect(other.Complement(true, true))
+}
+
+// Complement returns every address of the chosen families not in s
+func (s *Set) Complement(ipv4, ipv6 bool) *Set {
+	var universe []addrRange
+	if ipv4 {
+		universe = append(universe, ipv4Universe)
+	}
+	if ipv6 {
+		universe = append(universe, ipv6Universe)
+	}
+
+	var ranges []addrRange
+	for _, u := range universe {
+		next := u.from
+		exhausted := false
+		for _, r := range s.ranges {
+			if r.to.Compare(u.from) < 0 || r.from.Compare(u.to) > 0 {
+				continue
+			}
+			if r.from.Compare(next) > 0 {
+				ranges = append(ranges, addrRange{next, r.from.Prev()})
+			}
+			if r.to == u.to {
+				exhausted = true
+				break
+			}
+			next = r.to.Next()
+		}
+		if !exhausted {
+			ranges = append(ranges, addrRange{next, u.to})
+		}
+	}
+	return &Set{ranges: ranges}
+}
+
+// Prefixes returns the fewest CIDR blocks that cover the set exactly, in
+// address order with IPv4 first
+func (s *Set) Prefixes() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, r := range s.ranges {
+		from := r.from
+		for {
+			prefix := largestPrefix(from, r.to)
+			prefixes = append(prefixes, prefix)
+			last := lastAddr(prefix)
+			if last == r.to {
+				break
+			}
+			from = last.Next()
+		}
+	}
+	return prefixes
+}
+
+// Strings returns Prefixes in CIDR notation
+func (s *Set) Strings() []string {
+	prefixes := s.Prefixes()
+	out := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		out[i] = prefix.String()
+	}
+	return out
+}
+
+// largestPrefix returns the shortest prefix that starts at from and ends at or before to
+func largestPrefix(from, to netip.Addr) netip.Prefix {
+	best := netip.PrefixFrom(from, from.BitLen())
+	for bits := from.BitLen() - 1; bits >= 0; bits-- {
+		candidate := netip.PrefixFrom(from, bits)
+		if candidate.Masked().Addr() != from || lastAddr(candidate).Compare(to) > 0 {
+			break
+		}
+		best = candidate
+	}
+	return best
+}
+
+// lastAddr returns the highest address in prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	addr := prefix.Masked().Addr()
+	if addr.Is4() {
+		b := addr.As4()
+		setHostBits(b[:], prefix.Bits())
+		return netip.AddrFrom4(b)
+	}
+	b := addr.As16()
+	setHostBits(b[:], prefix.Bits())
+	return netip.AddrFrom16(b)
+}
+
+func setHostBits(b []byte, bits int) {
+	for i := range b {
+		switch {
+		case bits >= 8:
+			bits -= 8
+		case bits > 0:
+			b[i] |= 0xff >> bits
+			bits = 0
+		default:
+			b[i] = 0xff
+		}
+	}
+}
+
+func maxAddr(a, b netip.Addr) netip.Addr {
+	if a.Compare(b) > 0 {
+		return a
+	}
+	return b
+}
+
+func minAddr(a, b netip.Addr) netip.Addr {
+	if a.Compare(b) < 0 {
+		return a
+	}
+	return b
+}
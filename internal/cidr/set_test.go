@@ -0,0 +1,191 @@
+package cidr
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+func mustParseSet(t *testing.T, entries ...string) *Set {
+	t.Helper()
+	set, err := ParseSet(entries)
+	if err != nil {
+		t.Fatalf("ParseSet(%v) returned error: %v", entries, err)
+	}
+	return set
+}
+
+func TestParseSetMergesAndSummarizes(t *testing.T) {
+	set := mustParseSet(t, "10.0.1.0/24", "10.0.0.0/24", "10.0.0.7", "10.1.2.3/16", "2001:db8::/33", "2001:db8:8000::/33")
+	want := []string{"10.0.0.0/23", "10.1.0.0/16", "2001:db8::/32"}
+	if got := set.Strings(); !slices.Equal(got, want) {
+		t.Fatalf("Strings() = %v, want %v", got, want)
+	}
+	if !set.HasIPv4() || !set.HasIPv6() || set.IsEmpty() {
+		t.Fatalf("unexpected families for %v", set.Strings())
+	}
+
+	if _, err := ParseSet([]string{"10.0.0.0/33"}); errcode.Of(err) != errcode.CIDRInvalid {
+		t.Fatalf("expected CIDR001 for a bad prefix, got %v", err)
+	}
+	if _, err := ParseSet([]string{"not-an-ip"}); errcode.Of(err) != errcode.CIDRInvalidIP {
+		t.Fatalf("expected CIDR002 for a bad address, got %v", err)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	got, err := Aggregate([]string{"10.0.1.0/24", "10.0.0.0/24", "10.0.0.128/25", "2001:db8:8000::/33", "2001:db8::/33", "192.168.0.1"})
+	if err != nil {
+		t.Fatalf("Aggregate returned error: %v", err)
+	}
+	want := []string{"10.0.0.0/23", "192.168.0.1/32", "2001:db8::/32"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Aggregate = %v, want %v", got, want)
+	}
+
+	if _, err := Aggregate([]string{"10.0.0.0/24", "10.0.0.0/40"}); errcode.Of(err) != errcode.CIDRInvalid {
+		t.Fatalf("expected CIDR001 for a bad prefix, got %v", err)
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		excludes []string
+		want     []string
+	}{
+		{
+			name:     "two holes in a /8",
+			base:     "10.0.0.0/8",
+			excludes: []string{"10.1.0.0/16", "10.2.3.0/24"},
+			want: []string{
+				"10.0.0.0/16", "10.2.0.0/23", "10.2.2.0/24", "10.2.4.0/22", "10.2.8.0/21", "10.2.16.0/20",
+				"10.2.32.0/19", "10.2.64.0/18", "10.2.128.0/17", "10.3.0.0/16", "10.4.0.0/14", "10.8.0.0/13",
+				"10.16.0.0/12", "10.32.0.0/11", "10.64.0.0/10", "10.128.0.0/9",
+			},
+		},
+		{
+			name:     "overlapping exclusions and addresses",
+			base:     "192.168.0.0/30",
+			excludes: []string{"192.168.0.1", "192.168.0.0/31", "192.168.0.3"},
+			want:     []string{"192.168.0.2/32"},
+		},
+		{
+			name:     "IPv6",
+			base:     "2001:db8::/32",
+			excludes: []string{"2001:db8::/33"},
+			want:     []string{"2001:db8:8000::/33"},
+		},
+		{name: "nothing left", base: "10.0.0.0/24", excludes: []string{"10.0.0.0/8"}, want: []string{}},
+		{name: "other family removes nothing", base: "10.0.0.0/24", excludes: []string{"::/0"}, want: []string{"10.0.0.0/24"}},
+		{name: "no exclusions", base: "10.0.0.7/24", want: []string{"10.0.0.0/24"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Subtract(tt.base, tt.excludes)
+			if err != nil {
+				t.Fatalf("Subtract returned error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Subtract = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Subtract("10.0.0.0/40", nil); errcode.Of(err) != errcode.CIDRInvalid {
+		t.Fatalf("expected CIDR001 for a bad base, got %v", err)
+	}
+	if _, err := Subtract("10.0.0.0/8", []string{"bogus"}); errcode.Of(err) != errcode.CIDRInvalidIP {
+		t.Fatalf("expected CIDR002 for a bad exclusion, got %v", err)
+	}
+}
+
+func TestRangeToCIDRs(t *testing.T) {
+	tests := []struct {
+		start, end string
+		want       []string
+	}{
+		{"192.168.1.10", "192.168.2.55", []string{
+			"192.168.1.10/31", "192.168.1.12/30", "192.168.1.16/28", "192.168.1.32/27", "192.168.1.64/26",
+			"192.168.1.128/25", "192.168.2.0/27", "192.168.2.32/28", "192.168.2.48/29",
+		}},
+		{"10.0.0.0", "10.0.255.255", []string{"10.0.0.0/16"}},
+		{"10.0.0.5", "10.0.0.5", []string{"10.0.0.5/32"}},
+		{"0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0"}},
+		{"2001:db8::", "2001:db8::1:ffff", []string{"2001:db8::/111"}},
+	}
+	for _, tt := range tests {
+		got, err := RangeToCIDRs(tt.start, tt.end)
+		if err != nil {
+			t.Fatalf("RangeToCIDRs(%s, %s) returned error: %v", tt.start, tt.end, err)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("RangeToCIDRs(%s, %s) = %v, want %v", tt.start, tt.end, got, tt.want)
+		}
+	}
+
+	for _, bad := range [][2]string{{"10.0.0.9", "10.0.0.1"}, {"10.0.0.1", "::1"}, {"bogus", "10.0.0.1"}, {"10.0.0.1", "10.0.0.0/24"}} {
+		if _, err := RangeToCIDRs(bad[0], bad[1]); errcode.Of(err) != errcode.CIDRInvalidIP {
+			t.Errorf("RangeToCIDRs(%s, %s) = %v, want CIDR002", bad[0], bad[1], err)
+		}
+	}
+}
+
+func TestCIDRToRange(t *testing.T) {
+	first, last, err := CIDRToRange("192.168.1.77/23")
+	if err != nil || first != "192.168.0.0" || last != "192.168.1.255" {
+		t.Fatalf("CIDRToRange = %s, %s, %v", first, last, err)
+	}
+	first, last, err = CIDRToRange("2001:db8::/126")
+	if err != nil || first != "2001:db8::" || last != "2001:db8::3" {
+		t.Fatalf("CIDRToRange = %s, %s, %v", first, last, err)
+	}
+	if _, _, err := CIDRToRange("10.0.0.1"); errcode.Of(err) != errcode.CIDRInvalid {
+		t.Fatalf("expected CIDR001 for a bare address, got %v", err)
+	}
+}
+
+func TestSetOperations(t *testing.T) {
+	a := mustParseSet(t, "10.0.0.0/8")
+	b := mustParseSet(t, "10.1.0.0/16", "192.168.0.0/16")
+
+	tests := []struct {
+		name string
+		got  *Set
+		want []string
+	}{
+		{name: "union", got: a.Union(b), want: []string{"10.0.0.0/8", "192.168.0.0/16"}},
+		{name: "intersect", got: a.Intersect(b), want: []string{"10.1.0.0/16"}},
+		{name: "difference", got: mustParseSet(t, "10.0.0.0/22").Difference(mustParseSet(t, "10.0.1.0/24")), want: []string{"10.0.0.0/24", "10.0.2.0/23"}},
+		{name: "complement", got: mustParseSet(t, "128.0.0.0/1").Complement(true, false), want: []string{"0.0.0.0/1"}},
+		{name: "complement of everything", got: mustParseSet(t, "0.0.0.0/0").Complement(true, false), want: []string{}},
+		{name: "complement of nothing", got: (&Set{}).Complement(false, true), want: []string{"::/0"}},
+		{name: "families stay apart", got: mustParseSet(t, "0.0.0.0/0").Intersect(mustParseSet(t, "::/0")), want: []string{}},
+		{name: "top of the address space", got: mustParseSet(t, "255.255.255.255").Union(mustParseSet(t, "255.255.255.254")), want: []string{"255.255.255.254/31"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.got.Strings(); !slices.Equal(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSet(t *testing.T) {
+	set, err := ReadSet(strings.NewReader("# corp ranges\n10.0.0.0/16\n\n10.1.0.0/16  # lab\n2001:db8::1\n"))
+	if err != nil {
+		t.Fatalf("ReadSet returned error: %v", err)
+	}
+	if got, want := set.Strings(), []string{"10.0.0.0/15", "2001:db8::1/128"}; !slices.Equal(got, want) {
+		t.Fatalf("ReadSet = %v, want %v", got, want)
+	}
+
+	_, err = ReadSet(strings.NewReader("10.0.0.0/8\nbogus/8\n"))
+	if errcode.Of(err) != errcode.CIDRInvalid || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected a CIDR001 error naming line 2, got %v", err)
+	}
+}
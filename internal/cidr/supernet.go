@@ -0,0 +1,112 @@
+package cidr
+
+import (
+	"math/big"
+	"math/bits"
+	"net/netip"
+	"strconv"
+)
+
+// SupernetResult is the smallest single prefix covering every input of one
+// address family
+type SupernetResult struct {
+	Prefix  netip.Prefix
+	Covered *big.Int // Addresses of the prefix that some input covers
+	Total   *big.Int // Addresses in the prefix
+}
+
+// Unused returns the number of addresses in the prefix that no input covers
+func (r SupernetResult) Unused() *big.Int {
+	return new(big.Int).Sub(r.Total, r.Covered)
+}
+
+// Waste returns the percentage of the prefix that no input covers
+func (r SupernetResult) Waste() float64 {
+	unused := new(big.Float).SetInt(r.Unused())
+	percent, _ := unused.Quo(unused, new(big.Float).SetInt(r.Total)).Float64()
+	return percent * 100
+}
+
+// FormatWaste returns Waste to one decimal place, or to up to four near the
+// bounds, so a prefix that some input covers never reads as 100.0% unused
+// and one with an unused address never reads as 0.0%. Past four places it
+// reads ">99.9999" or "<0.0001".
+func (r SupernetResult) FormatWaste() string {
+	used, unused := r.Covered.Sign() > 0, r.Unused().Sign() > 0
+	waste := r.Waste()
+	for digits := 1; digits <= 4; digits++ {
+		text := strconv.FormatFloat(waste, 'f', digits, 64)
+		rounded, _ := strconv.ParseFloat(text, 64)
+		if (rounded == 100 && used) || (rounded == 0 && unused) {
+			continue
+		}
+		return text
+	}
+	if waste >= 50 {
+		return ">99.9999"
+	}
+	return "<0.0001"
+}
+
+// Supernet returns the smallest prefix containing every CIDR and address in
+// entries, one per address family present, IPv4 first. Inputs may overlap.
+func Supernet(entries []string) ([]SupernetResult, error) {
+	set, err := ParseSet(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []SupernetResult
+	for _, family := range [][]addrRange{set.familyRanges(true), set.familyRanges(false)} {
+		if len(family) == 0 {
+			continue
+		}
+		first, last := family[0].from, family[len(family)-1].to
+		prefix := netip.PrefixFrom(first, commonPrefixLen(first, last)).Masked()
+
+		covered := new(big.Int)
+		for _, r := range family {
+			covered.Add(covered, rangeSize(r))
+		}
+		results = append(results, SupernetResult{
+			Prefix:  prefix,
+			Covered: covered,
+			Total:   calculateTotalAddresses(prefix.Addr().BitLen() - prefix.Bits()),
+		})
+	}
+	return results, nil
+}
+
+// familyRanges returns the ranges of one address family, in address order
+func (s *Set) familyRanges(ipv4 bool) []addrRange {
+	for i, r := range s.ranges {
+		if r.from.Is6() {
+			if ipv4 {
+				return s.ranges[:i]
+			}
+			return s.ranges[i:]
+		}
+	}
+	if ipv4 {
+		return s.ranges
+	}
+	return nil
+}
+
+// commonPrefixLen returns the number of leading bits a and b share
+func commonPrefixLen(a, b netip.Addr) int {
+	x, y := a.AsSlice(), b.AsSlice()
+	for i := range x {
+		if diff := x[i] ^ y[i]; diff != 0 {
+			return i*8 + bits.LeadingZeros8(diff)
+		}
+	}
+	return len(x) * 8
+}
+
+// rangeSize returns the number of addresses in r
+func rangeSize(r addrRange) *big.Int {
+	size := new(big.Int).SetBytes(r.to.AsSlice())
+	size.Sub(size, new(big.Int).SetBytes(r.from.AsSlice()))
+	return size.Add(size, big.NewInt(1))
+}
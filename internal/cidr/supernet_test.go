@@ -0,0 +1,83 @@
+package cidr
+
+import (
+	"math"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+func TestSupernet(t *testing.T) {
+	tests := []struct {
+		name      string
+		entries   []string
+		supernets []string
+		waste     []float64
+	}{
+		{name: "adjacent pair", entries: []string{"10.1.0.0/24", "10.1.1.0/24"}, supernets: []string{"10.1.0.0/23"}, waste: []float64{0}},
+		{name: "gap between inputs", entries: []string{"10.1.3.0/24", "10.1.0.0/24"}, supernets: []string{"10.1.0.0/22"}, waste: []float64{50}},
+		{name: "contained and overlapping", entries: []string{"10.0.0.0/16", "10.0.1.0/24", "10.0.0.5"}, supernets: []string{"10.0.0.0/16"}, waste: []float64{0}},
+		{name: "single address", entries: []string{"192.0.2.1"}, supernets: []string{"192.0.2.1/32"}, waste: []float64{0}},
+		{name: "across the top bit", entries: []string{"10.0.0.0/8", "192.168.0.0/16"}, supernets: []string{"0.0.0.0/0"}},
+		{
+			name:      "per family",
+			entries:   []string{"2001:db8:1::/48", "192.168.1.0/24", "2001:db8:2::1"},
+			supernets: []string{"192.168.1.0/24", "2001:db8::/46"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Supernet(tt.entries)
+			if err != nil {
+				t.Fatalf("Supernet returned error: %v", err)
+			}
+			if len(results) != len(tt.supernets) {
+				t.Fatalf("expected %v, got %v", tt.supernets, results)
+			}
+			for i, result := range results {
+				if result.Prefix.String() != tt.supernets[i] {
+					t.Errorf("supernet %d = %s, want %s", i, result.Prefix, tt.supernets[i])
+				}
+				if i < len(tt.waste) && math.Abs(result.Waste()-tt.waste[i]) > 1e-9 {
+					t.Errorf("supernet %s waste = %g%%, want %g%%", result.Prefix, result.Waste(), tt.waste[i])
+				}
+			}
+		})
+	}
+
+	if _, err := Supernet([]string{"10.0.0.0/33"}); errcode.Of(err) != errcode.CIDRInvalid {
+		t.Errorf("expected CIDR001 for an invalid prefix, got %v", err)
+	}
+}
+
+func TestSupernetCounts(t *testing.T) {
+	results, err := Supernet([]string{"2001:db8::/64", "2001:db8:0:3::/64"})
+	if err != nil {
+		t.Fatalf("Supernet returned error: %v", err)
+	}
+	result := results[0]
+	if result.Prefix.String() != "2001:db8::/62" || FormatBigInt(result.Covered) != "36,893,488,147,419,103,232" || FormatBigInt(result.Unused()) != "36,893,488,147,419,103,232" {
+		t.Errorf("unexpected IPv6 supernet counts: %s covered %s unused %s", result.Prefix, result.Covered, result.Unused())
+	}
+}
+
+func TestSupernetFormatWaste(t *testing.T) {
+	tests := map[string][]string{
+		"50.0":     {"10.1.3.0/24", "10.1.0.0/24"},
+		"0.0":      {"10.1.0.0/24"},
+		"99.95":    {"10.0.0.0/24", "10.7.255.255"},
+		">99.9999": {"0.0.0.0", "255.255.255.255"},
+		"0.002":    {"10.0.0.0/9", "10.128.0.0/10", "10.192.0.0/11", "10.224.0.0/12", "10.240.0.0/13", "10.248.0.0/14", "10.252.0.0/15", "10.254.0.0/16", "10.255.0.0/17", "10.255.128.0/18", "10.255.192.0/19", "10.255.224.0/20", "10.255.240.0/21", "10.255.248.0/22", "10.255.252.0/23", "10.255.254.0/24"},
+		"<0.0001":  {"0.0.0.0/1", "128.0.0.0/2", "192.0.0.0/3", "224.0.0.0/4", "240.0.0.0/5", "248.0.0.0/6", "252.0.0.0/7", "254.0.0.0/8", "255.0.0.0/9", "255.128.0.0/10", "255.192.0.0/11", "255.224.0.0/12", "255.240.0.0/13", "255.248.0.0/14", "255.252.0.0/15", "255.254.0.0/16", "255.255.0.0/17", "255.255.128.0/18", "255.255.192.0/19", "255.255.224.0/20", "255.255.240.0/21", "255.255.248.0/22", "255.255.252.0/23", "255.255.254.0/24", "255.255.255.0/25", "255.255.255.128/26", "255.255.255.192/27", "255.255.255.224/28", "255.255.255.240/29", "255.255.255.248/30", "255.255.255.252/31", "255.255.255.254"},
+	}
+	for want, entries := range tests {
+		results, err := Supernet(entries)
+		if err != nil {
+			t.Fatalf("Supernet(%v) returned error: %v", entries, err)
+		}
+		if got := results[0].FormatWaste(); got != want {
+			t.Errorf("supernet %s waste = %q, want %q (%g%%)", results[0].Prefix, got, want, results[0].Waste())
+		}
+	}
+}
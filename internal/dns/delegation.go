@@ -0,0 +1,458 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"gopkg.in/yaml.v3"
+)
+
+// DelegationOptions configures a delegation consistency check
+type DelegationOptions struct {
+	Timeout     time.Duration // Per-query timeout
+	CaptureWire bool          // Keep every raw response in DelegationResult.WireResponses
+
+	// CheckServer, if set, is called with each nameserver address before it
+	// is queried; a server it rejects is skipped as if it had failed
+	CheckServer func(server string) error
+}
+
+// NameserverCheck holds the outcome of probing one delegated nameserver
+type NameserverCheck struct {
+	Host          string        // Nameserver hostname
+	Addresses     []string      // Addresses that were probed
+	Glue          []string      // Glue addresses supplied by the parent
+	Authoritative []string      // Addresses for Host according to authoritative data
+	GlueMatches   bool          // Glue agrees with authoritative data (true when no glue is present)
+	Reachable     bool          // At least one address answered
+	Lame          bool          // Server did not answer authoritatively for the zone
+	ResponseTime  time.Duration // Fastest response time across addresses
+	Error         string        // Last error seen while probing
+}
+
+// DelegationResult holds the results of a delegation consistency check
+type DelegationResult struct {
+	Domain       string
+	ParentZone   string
+	ParentServer string
+	ParentNS     []string
+	ChildNS      []string
+	Nameservers  []NameserverCheck
+	Issues       []string
+	QueryTime    time.Duration
+	// WireResponses holds every raw response received, in arrival order, when
+	// DelegationOptions.CaptureWire is set
+	WireResponses []WireResponse
+}
+
+// Consistent reports whether the check found no issues
+func (r *DelegationResult) Consistent() bool {
+	return len(r.Issues) == 0
+}
+
+type nameserverCheckOutput struct {
+	Host           string   `json:"host" yaml:"host"`
+	Addresses      []string `json:"addresses" yaml:"addresses"`
+	Glue           []string `json:"glue,omitempty" yaml:"glue,omitempty"`
+	Authoritative  []string `json:"authoritative,omitempty" yaml:"authoritative,omitempty"`
+	GlueMatches    bool     `json:"glue_matches" yaml:"glue_matches"`
+	Reachable      bool     `json:"reachable" yaml:"reachable"`
+	Lame           bool     `json:"lame" yaml:"lame"`
+	ResponseTimeMS int64    `json:"response_time_ms" yaml:"response_time_ms"`
+	Error          string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// delegationResultOutput is the serialization-friendly version of DelegationResult
+type delegationResultOutput struct {
+	Domain       string                  `json:"domain" yaml:"domain"`
+	ParentZone   string                  `json:"parent_zone" yaml:"parent_zone"`
+	ParentServer string                  `json:"parent_server" yaml:"parent_server"`
+	ParentNS     []string                `json:"parent_ns" yaml:"parent_ns"`
+	ChildNS      []string                `json:"child_ns" yaml:"child_ns"`
+	Nameservers  []nameserverCheckOutput `json:"nameservers" yaml:"nameservers"`
+	Consistent   bool                    `json:"consistent" yaml:"consistent"`
+	Issues       []string                `json:"issues" yaml:"issues"`
+	QueryTimeMS  int64                   `json:"query_time_ms" yaml:"query_time_ms"`
+	Wire         []wireResponseOutput    `json:"wire_responses,omitempty" yaml:"wire_responses,omitempty"`
+}
+
+func (r *DelegationResult) toOutput() delegationResultOutput {
+	nameservers := make([]nameserverCheckOutput, 0, len(r.Nameservers))
+	for _, ns := range r.Nameservers {
+		nameservers = append(nameservers, nameserverCheckOutput{
+			Host:           ns.Host,
+			Addresses:      ns.Addresses,
+			Glue:           ns.Glue,
+			Authoritative:  ns.Authoritative,
+			GlueMatches:    ns.GlueMatches,
+			Reachable:      ns.Reachable,
+			Lame:           ns.Lame,
+			ResponseTimeMS: ns.ResponseTime.Milliseconds(),
+			Error:          ns.Error,
+		})
+	}
+
+	issues := r.Issues
+	if issues == nil {
+		issues = []string{}
+	}
+
+	return delegationResultOutput{
+		Domain:       r.Domain,
+		ParentZone:   r.ParentZone,
+		ParentServer: r.ParentServer,
+		ParentNS:     r.ParentNS,
+		ChildNS:      r.ChildNS,
+		Nameservers:  nameservers,
+		Consistent:   r.Consistent(),
+		Issues:       issues,
+		QueryTimeMS:  r.QueryTime.Milliseconds(),
+		Wire:         wireResponsesOutput(r.WireResponses),
+	}
+}
+
+// ToJSON converts DelegationResult to JSON string
+func (r *DelegationResult) ToJSON() (string, error) {
+	bytes, err := json.MarshalIndent(r.toOutput(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// ToYAML converts DelegationResult to YAML string
+func (r *DelegationResult) ToYAML() (string, error) {
+	bytes, err := yaml.Marshal(r.toOutput())
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// CheckDelegation compares the NS set published by the parent zone with the
+// NS set served by the child zone, verifies glue against authoritative data,
+// and probes every listed nameserver for reachability and lameness.
+func CheckDelegation(domain string, opts DelegationOptions) (*DelegationResult, error) {
+	domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+	if domain == "" {
+		return nil, NewDNSError("delegation", domain, ErrEmptyDomain)
+	}
+
+	resolver := resolverFactory(LookupOptions{Timeout: opts.Timeout})
+
+	ctx, cancel := context.WithTimeout(context.Background(), delegationBudget(opts.Timeout))
+	defer cancel()
+
+	var recorder *wireRecorder
+	if opts.CaptureWire {
+		recorder = &wireRecorder{}
+		ctx = withWireRecorder(ctx, recorder)
+	}
+	if opts.CheckServer != nil {
+		ctx = withServerCheck(ctx, opts.CheckServer)
+	}
+
+	start := time.Now()
+
+	parentZone, parentHosts, err := findParentZone(ctx, resolver, domain)
+	if err != nil {
+		return nil, NewDNSError("delegation", domain, err)
+	}
+
+	referral, parentServer, err := queryParent(ctx, resolver, parentHosts, domain, opts.Timeout)
+	if err != nil {
+		return nil, NewDNSError("delegation", domain, err)
+	}
+	if referral.RCode == dnsmessage.RCodeNameError {
+		return nil, NewDNSError("delegation", domain, ErrNXDomain)
+	}
+
+	parentNS, glue := parseReferral(referral, domain)
+	if len(parentNS) == 0 {
+		return nil, NewDNSError("delegation", domain, fmt.Errorf("parent %s returned no delegation for %s", parentServer, domain))
+	}
+
+	result := &DelegationResult{
+		Domain:       domain,
+		ParentZone:   parentZone,
+		ParentServer: parentServer,
+		ParentNS:     parentNS,
+	}
+
+	childSet := make(map[string]bool)
+	var authServer string
+	for _, host := range parentNS {
+		check, childNS := probeNameserver(ctx, resolver, host, glue[host], domain, opts.Timeout)
+		for _, ns := range childNS {
+			childSet[ns] = true
+		}
+		if authServer == "" && check.Reachable && !check.Lame {
+			authServer = check.Addresses[0]
+		}
+		result.Nameservers = append(result.Nameservers, check)
+	}
+	result.ChildNS = sortedKeys(childSet)
+
+	for i := range result.Nameservers {
+		verifyGlue(ctx, resolver, &result.Nameservers[i], authServer, domain, opts.Timeout)
+	}
+
+	result.Issues = delegationIssues(result)
+	result.QueryTime = time.Since(start)
+	if recorder != nil {
+		result.WireResponses = recorder.Responses()
+	}
+
+	return result, nil
+}
+
+// delegationBudget bounds the whole check so a slow parent cannot stall indefinitely
+func delegationBudget(timeout time.Duration) time.Duration {
+	budget := 20 * timeout
+	if budget < 30*time.Second {
+		return 30 * time.Second
+	}
+	return budget
+}
+
+// findParentZone walks up the name until it finds a zone with published nameservers
+func findParentZone(ctx context.Context, resolver dnsResolver, domain string) (string, []string, error) {
+	labels := strings.Split(domain, ".")
+	candidates := make([]string, 0, len(labels))
+	for i := 1; i < len(labels); i++ {
+		candidates = append(candidates, strings.Join(labels[i:], "."))
+	}
+	candidates = append(candidates, ".")
+
+	for _, zone := range candidates {
+		nss, err := resolver.LookupNS(ctx, zone)
+		if err != nil || len(nss) == 0 {
+			continue
+		}
+		hosts := make([]string, 0, len(nss))
+		for _, ns := range nss {
+			hosts = append(hosts, strings.ToLower(trimDot(ns.Host)))
+		}
+		return zone, hosts, nil
+	}
+
+	return "", nil, fmt.Errorf("no parent zone nameservers found")
+}
+
+// queryParent asks each parent nameserver in turn for the child NS set until one answers
+func queryParent(ctx context.Context, resolver dnsResolver, hosts []string, domain string, timeout time.Duration) (*dnsmessage.Message, string, error) {
+	var lastErr error
+	for _, host := range hosts {
+		ips, err := resolver.LookupIP(ctx, "ip", host)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, ip := range ips {
+			server := serverAddress(ip.String())
+			resp, _, err := dnsExchange(ctx, server, domain, dnsmessage.TypeNS, timeout)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if resp.RCode != dnsmessage.RCodeSuccess && resp.RCode != dnsmessage.RCodeNameError {
+				lastErr = fmt.Errorf("%s answered %s", server, resp.RCode)
+				continue
+			}
+			return resp, host, nil
+		}
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no parent nameserver addresses found")
+	}
+	return nil, "", fmt.Errorf("no parent nameserver answered: %w", lastErr)
+}
+
+// parseReferral extracts the delegated NS set and any glue from a parent response
+func parseReferral(msg *dnsmessage.Message, domain string) ([]string, map[string][]string) {
+	nsSet := make(map[string]bool)
+	for _, rr := range append(slices.Clone(msg.Answers), msg.Authorities...) {
+		ns, ok := rr.Body.(*dnsmessage.NSResource)
+		if !ok || !strings.EqualFold(trimDot(rr.Header.Name.String()), domain) {
+			continue
+		}
+		nsSet[strings.ToLower(trimDot(ns.NS.String()))] = true
+	}
+
+	glue := make(map[string][]string)
+	for _, rr := range msg.Additionals {
+		host := strings.ToLower(trimDot(rr.Header.Name.String()))
+		if !nsSet[host] {
+			continue
+		}
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			glue[host] = append(glue[host], net.IP(body.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			glue[host] = append(glue[host], net.IP(body.AAAA[:]).String())
+		}
+	}
+	for host := range glue {
+		slices.Sort(glue[host])
+	}
+
+	return sortedKeys(nsSet), glue
+}
+
+// probeNameserver queries one nameserver for the child NS set on each of its addresses
+func probeNameserver(ctx context.Context, resolver dnsResolver, host string, glue []string, domain string, timeout time.Duration) (NameserverCheck, []string) {
+	check := NameserverCheck{
+		Host:        host,
+		Glue:        glue,
+		GlueMatches: true,
+	}
+
+	addresses := glue
+	if len(addresses) == 0 {
+		ips, err := resolver.LookupIP(ctx, "ip", host)
+		if err != nil {
+			check.Error = fmt.Sprintf("failed to resolve nameserver: %v", err)
+			return check, nil
+		}
+		for _, ip := range ips {
+			addresses = append(addresses, ip.String())
+		}
+	}
+	check.Addresses = addresses
+
+	var childNS []string
+	answeredAuthoritatively := false
+	for _, addr := range addresses {
+		resp, rtt, err := dnsExchange(ctx, serverAddress(addr), domain, dnsmessage.TypeNS, timeout)
+		if err != nil {
+			if isTimeout(err) {
+				check.Error = fmt.Sprintf("%s: timeout", addr)
+			} else {
+				check.Error = fmt.Sprintf("%s: %v", addr, err)
+			}
+			continue
+		}
+
+		if !check.Reachable || rtt < check.ResponseTime {
+			check.ResponseTime = rtt
+		}
+		check.Reachable = true
+
+		if resp.RCode != dnsmessage.RCodeSuccess || !resp.Authoritative {
+			check.Error = fmt.Sprintf("%s: non-authoritative answer (%s)", addr, resp.RCode)
+			continue
+		}
+
+		answeredAuthoritatively = true
+		for _, rr := range resp.Answers {
+			if ns, ok := rr.Body.(*dnsmessage.NSResource); ok {
+				childNS = append(childNS, strings.ToLower(trimDot(ns.NS.String())))
+			}
+		}
+	}
+
+	check.Lame = check.Reachable && !answeredAuthoritatively
+	return check, childNS
+}
+
+// verifyGlue compares parent glue with the authoritative addresses for the nameserver
+func verifyGlue(ctx context.Context, resolver dnsResolver, check *NameserverCheck, authServer, domain string, timeout time.Duration) {
+	if len(check.Glue) == 0 {
+		return
+	}
+
+	var addresses []string
+	if authServer != "" && inBailiwick(check.Host, domain) {
+		for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+			resp, _, err := dnsExchange(ctx, serverAddress(authServer), check.Host, qtype, timeout)
+			if err != nil {
+				continue
+			}
+			for _, rr := range resp.Answers {
+				switch body := rr.Body.(type) {
+				case *dnsmessage.AResource:
+					addresses = append(addresses, net.IP(body.A[:]).String())
+				case *dnsmessage.AAAAResource:
+					addresses = append(addresses, net.IP(body.AAAA[:]).String())
+				}
+			}
+		}
+	} else {
+		ips, err := resolver.LookupIP(ctx, "ip", check.Host)
+		if err == nil {
+			for _, ip := range ips {
+				addresses = append(addresses, ip.String())
+			}
+		}
+	}
+
+	slices.Sort(addresses)
+	check.Authoritative = addresses
+	check.GlueMatches = slices.Equal(check.Glue, addresses)
+}
+
+// delegationIssues summarizes everything that makes a delegation inconsistent
+func delegationIssues(result *DelegationResult) []string {
+	var issues []string
+
+	if len(result.ChildNS) > 0 && !slices.Equal(result.ParentNS, result.ChildNS) {
+		parentOnly, childOnly := setDifference(result.ParentNS, result.ChildNS)
+		if len(parentOnly) > 0 {
+			issues = append(issues, fmt.Sprintf("NS only at parent: %s", strings.Join(parentOnly, ", ")))
+		}
+		if len(childOnly) > 0 {
+			issues = append(issues, fmt.Sprintf("NS only at child: %s", strings.Join(childOnly, ", ")))
+		}
+	}
+
+	for _, ns := range result.Nameservers {
+		switch {
+		case !ns.Reachable:
+			issues = append(issues, fmt.Sprintf("nameserver %s is unreachable", ns.Host))
+		case ns.Lame:
+			issues = append(issues, fmt.Sprintf("lame delegation: %s does not answer authoritatively for %s", ns.Host, result.Domain))
+		}
+		if !ns.GlueMatches {
+			issues = append(issues, fmt.Sprintf("glue for %s (%s) does not match authoritative data (%s)",
+				ns.Host, strings.Join(ns.Glue, ", "), strings.Join(ns.Authoritative, ", ")))
+		}
+	}
+
+	return issues
+}
+
+// inBailiwick reports whether host sits inside zone
+func inBailiwick(host, zone string) bool {
+	return host == zone || strings.HasSuffix(host, "."+zone)
+}
+
+func setDifference(a, b []string) ([]string, []string) {
+	var onlyA, onlyB []string
+	for _, v := range a {
+		if !slices.Contains(b, v) {
+			onlyA = append(onlyA, v)
+		}
+	}
+	for _, v := range b {
+		if !slices.Contains(a, v) {
+			onlyB = append(onlyB, v)
+		}
+	}
+	return onlyA, onlyB
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
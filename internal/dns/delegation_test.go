@@ -0,0 +1,291 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func mustName(t *testing.T, name string) dnsmessage.Name {
+	t.Helper()
+	n, err := dnsmessage.NewName(fqdn(name))
+	if err != nil {
+		t.Fatalf("invalid name %q: %v", name, err)
+	}
+	return n
+}
+
+func nsRR(t *testing.T, owner, host string) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: mustName(t, owner), Type: dnsmessage.TypeNS, Class: dnsmessage.ClassINET},
+		Body:   &dnsmessage.NSResource{NS: mustName(t, host)},
+	}
+}
+
+func aRR(t *testing.T, owner, ip string) dnsmessage.Resource {
+	var a [4]byte
+	copy(a[:], net.ParseIP(ip).To4())
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: mustName(t, owner), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET},
+		Body:   &dnsmessage.AResource{A: a},
+	}
+}
+
+func stubDelegationResolver(t *testing.T) {
+	t.Helper()
+	original := resolverFactory
+	t.Cleanup(func() { resolverFactory = original })
+
+	resolverFactory = func(opts LookupOptions) dnsResolver {
+		return fakeDNSResolver{
+			lookupNSFunc: func(ctx context.Context, name string) ([]*net.NS, error) {
+				if name == "com" {
+					return []*net.NS{{Host: "a.gtld.test."}}, nil
+				}
+				return nil, errors.New("no such zone")
+			},
+			lookupIPFunc: func(ctx context.Context, network, host string) ([]net.IP, error) {
+				switch host {
+				case "a.gtld.test":
+					return []net.IP{net.ParseIP("192.0.2.1")}, nil
+				case "ns.other.test":
+					return []net.IP{net.ParseIP("198.51.100.20")}, nil
+				}
+				return nil, errors.New("not found")
+			},
+		}
+	}
+}
+
+func TestCheckDelegationConsistent(t *testing.T) {
+	stubDelegationResolver(t)
+
+	original := dnsExchange
+	t.Cleanup(func() { dnsExchange = original })
+
+	dnsExchange = func(ctx context.Context, server, name string, qtype dnsmessage.Type, timeout time.Duration) (*dnsmessage.Message, time.Duration, error) {
+		switch {
+		case server == "192.0.2.1:53":
+			return &dnsmessage.Message{
+				Authorities: []dnsmessage.Resource{
+					nsRR(t, "example.com", "ns1.example.com"),
+					nsRR(t, "example.com", "ns.other.test"),
+				},
+				Additionals: []dnsmessage.Resource{aRR(t, "ns1.example.com", "192.0.2.53")},
+			}, time.Millisecond, nil
+		case qtype == dnsmessage.TypeNS:
+			return &dnsmessage.Message{
+				Header: dnsmessage.Header{Authoritative: true},
+				Answers: []dnsmessage.Resource{
+					nsRR(t, "example.com", "ns1.example.com"),
+					nsRR(t, "example.com", "ns.other.test"),
+				},
+			}, 5 * time.Millisecond, nil
+		case qtype == dnsmessage.TypeA && name == "ns1.example.com":
+			return &dnsmessage.Message{
+				Header:  dnsmessage.Header{Authoritative: true},
+				Answers: []dnsmessage.Resource{aRR(t, "ns1.example.com", "192.0.2.53")},
+			}, time.Millisecond, nil
+		}
+		return &dnsmessage.Message{Header: dnsmessage.Header{Authoritative: true}}, time.Millisecond, nil
+	}
+
+	result, err := CheckDelegation("Example.com.", DelegationOptions{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("CheckDelegation returned error: %v", err)
+	}
+
+	if result.ParentZone != "com" || result.ParentServer != "a.gtld.test" {
+		t.Fatalf("unexpected parent: zone=%q server=%q", result.ParentZone, result.ParentServer)
+	}
+	if strings.Join(result.ParentNS, ",") != "ns.other.test,ns1.example.com" {
+		t.Fatalf("unexpected parent NS: %v", result.ParentNS)
+	}
+	if !result.Consistent() {
+		t.Fatalf("expected consistent delegation, got issues %v", result.Issues)
+	}
+	for _, ns := range result.Nameservers {
+		if !ns.Reachable || ns.Lame || ns.ResponseTime != 5*time.Millisecond {
+			t.Fatalf("unexpected nameserver check: %+v", ns)
+		}
+	}
+}
+
+func TestCheckDelegationFlagsIssues(t *testing.T) {
+	stubDelegationResolver(t)
+
+	original := dnsExchange
+	t.Cleanup(func() { dnsExchange = original })
+
+	dnsExchange = func(ctx context.Context, server, name string, qtype dnsmessage.Type, timeout time.Duration) (*dnsmessage.Message, time.Duration, error) {
+		switch server {
+		case "192.0.2.1:53":
+			return &dnsmessage.Message{
+				Authorities: []dnsmessage.Resource{
+					nsRR(t, "example.com", "ns1.example.com"),
+					nsRR(t, "example.com", "ns.other.test"),
+				},
+				Additionals: []dnsmessage.Resource{aRR(t, "ns1.example.com", "192.0.2.99")},
+			}, time.Millisecond, nil
+		case "192.0.2.99:53":
+			if qtype == dnsmessage.TypeA {
+				return &dnsmessage.Message{
+					Header:  dnsmessage.Header{Authoritative: true},
+					Answers: []dnsmessage.Resource{aRR(t, "ns1.example.com", "192.0.2.53")},
+				}, time.Millisecond, nil
+			}
+			return &dnsmessage.Message{
+				Header: dnsmessage.Header{Authoritative: true},
+				Answers: []dnsmessage.Resource{
+					nsRR(t, "example.com", "ns1.example.com"),
+					nsRR(t, "example.com", "ns2.example.com"),
+				},
+			}, time.Millisecond, nil
+		default:
+			// ns.other.test answers, but not authoritatively
+			return &dnsmessage.Message{}, time.Millisecond, nil
+		}
+	}
+
+	result, err := CheckDelegation("example.com", DelegationOptions{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("CheckDelegation returned error: %v", err)
+	}
+
+	issues := strings.Join(result.Issues, "\n")
+	for _, fragment := range []string{
+		"NS only at parent: ns.other.test",
+		"NS only at child: ns2.example.com",
+		"lame delegation: ns.other.test",
+		"glue for ns1.example.com (192.0.2.99) does not match authoritative data (192.0.2.53)",
+	} {
+		if !strings.Contains(issues, fragment) {
+			t.Fatalf("expected issues to contain %q, got:\n%s", fragment, issues)
+		}
+	}
+
+	jsonOutput, err := result.ToJSON()
+	if err != nil {
+		t.Fatalf("ToJSON returned error: %v", err)
+	}
+	var payload map[string]any
+	if err := json.Unmarshal([]byte(jsonOutput), &payload); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if payload["consistent"] != false || payload["parent_zone"] != "com" {
+		t.Fatalf("unexpected JSON payload: %#v", payload)
+	}
+}
+
+func TestCheckDelegationErrors(t *testing.T) {
+	if _, err := CheckDelegation(" ", DelegationOptions{Timeout: time.Second}); !errors.Is(err, ErrEmptyDomain) {
+		t.Fatalf("expected ErrEmptyDomain, got %v", err)
+	}
+
+	stubDelegationResolver(t)
+	original := dnsExchange
+	t.Cleanup(func() { dnsExchange = original })
+
+	dnsExchange = func(ctx context.Context, server, name string, qtype dnsmessage.Type, timeout time.Duration) (*dnsmessage.Message, time.Duration, error) {
+		return &dnsmessage.Message{Header: dnsmessage.Header{RCode: dnsmessage.RCodeNameError}}, time.Millisecond, nil
+	}
+
+	if _, err := CheckDelegation("missing.com", DelegationOptions{Timeout: time.Second}); !errors.Is(err, ErrNXDomain) {
+		t.Fatalf("expected ErrNXDomain, got %v", err)
+	}
+}
+
+func TestExchangeDNSOverUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot open UDP socket: %v", err)
+	}
+	defer conn.Close()
+
+	go func() {
+		buf := make([]byte, 512)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var query dnsmessage.Message
+		if err := query.Unpack(buf[:n]); err != nil {
+			return
+		}
+		reply := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true},
+			Questions: query.Questions,
+			Answers:   []dnsmessage.Resource{aRR(t, "example.com", "192.0.2.10")},
+		}
+		packed, err := reply.Pack()
+		if err != nil {
+			return
+		}
+		_, _ = conn.WriteTo(packed, addr)
+	}()
+
+	recorder := &wireRecorder{}
+	ctx := withWireRecorder(context.Background(), recorder)
+
+	resp, _, err := exchangeDNS(ctx, conn.LocalAddr().String(), "example.com", dnsmessage.TypeA, time.Second)
+	if err != nil {
+		t.Fatalf("exchangeDNS returned error: %v", err)
+	}
+	if !resp.Authoritative || len(resp.Answers) != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	wire := recorder.Responses()
+	if len(wire) != 1 {
+		t.Fatalf("expected one recorded response, got %d", len(wire))
+	}
+	if wire[0].Name != "example.com." || wire[0].Type != "TypeA" || wire[0].Transport != "udp" {
+		t.Fatalf("unexpected wire metadata: %+v", wire[0])
+	}
+	var recorded dnsmessage.Message
+	if err := recorded.Unpack(wire[0].Raw); err != nil || recorded.ID != resp.ID {
+		t.Fatalf("recorded bytes do not match the parsed response: %v", err)
+	}
+}
+
+func TestExchangeDNSServerCheck(t *testing.T) {
+	refused := errors.New("refused by policy")
+	var checked string
+	ctx := withServerCheck(context.Background(), func(server string) error {
+		checked = server
+		return refused
+	})
+
+	if _, _, err := exchangeDNS(ctx, "192.0.2.53:53", "example.com", dnsmessage.TypeA, time.Second); !errors.Is(err, refused) {
+		t.Fatalf("expected the check's error, got %v", err)
+	}
+	if checked != "192.0.2.53:53" {
+		t.Fatalf("check saw %q", checked)
+	}
+}
+
+func TestWireResponsesOutput(t *testing.T) {
+	result := &DelegationResult{
+		Domain:        "example.com",
+		WireResponses: []WireResponse{{Server: "192.0.2.53:53", Name: "example.com.", Type: "TypeNS", Transport: "udp", Raw: []byte("hi")}},
+	}
+
+	output, err := result.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, `"raw_base64": "aGk="`) || !strings.Contains(output, `"size": 2`) {
+		t.Fatalf("expected base64 wire responses in JSON: %s", output)
+	}
+
+	result.WireResponses = nil
+	if output, _ := result.ToJSON(); strings.Contains(output, "wire_responses") {
+		t.Fatalf("expected wire responses to be omitted when not captured: %s", output)
+	}
+}
@@ -8,14 +8,38 @@ import (
 	"strings"
 	"time"
 
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/policy"
 	"gopkg.in/yaml.v3"
 )
 
 var resolverDialContext = func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+	address, err := checkedAddress(address)
+	if err != nil {
+		return nil, err
+	}
 	d := net.Dialer{Timeout: timeout}
 	return d.DialContext(ctx, network, address)
 }
 
+// checkedAddress swaps a server hostname for an address policy checked it
+// against, when it did, so the query goes where the check looked rather
+// than wherever the name resolves now
+func checkedAddress(address string) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address, nil
+	}
+	addrs, ok := policy.Resolved(host)
+	switch {
+	case !ok:
+		return address, nil
+	case len(addrs) == 0:
+		return "", &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return net.JoinHostPort(addrs[0].String(), port), nil
+}
+
 // Record types supported by the lookup command
 const (
 	RecordTypeA     = "A"
@@ -27,12 +51,22 @@ const (
 	RecordTypeALL   = "ALL"
 )
 
+// Per-type outcomes reported by ALL lookups
+const (
+	LookupStatusOK       = "ok"
+	LookupStatusNXDomain = "nxdomain"
+	LookupStatusTimeout  = "timeout"
+	LookupStatusServFail = "servfail"
+)
+
 // LookupOptions configures DNS lookup behavior
 type LookupOptions struct {
 	RecordType string        // Type of record to query (A, AAAA, MX, TXT, CNAME, NS, ALL)
 	Server     string        // Custom DNS server (empty = system resolver)
 	Timeout    time.Duration // Query timeout
 	PreferIPv6 bool          // Prefer IPv6 results when available
+	CheckTTL   bool          // Also query Server directly for TTLs and report TTLWarnings
+	LowTTL     time.Duration // TTLs under this are flagged (0 = DefaultLowTTL)
 }
 
 type dnsResolver interface {
@@ -65,6 +99,35 @@ type DNSResult struct {
 	Records   []DNSRecord   `json:"-" yaml:"-"`
 	QueryTime time.Duration `json:"-" yaml:"-"`
 	Server    string        `json:"-" yaml:"-"`
+	Statuses  []TypeStatus  `json:"-" yaml:"-"` // Per-type outcomes, set only for ALL lookups
+
+	TTLWarnings []TTLWarning `json:"-" yaml:"-"` // Set only with LookupOptions.CheckTTL
+}
+
+// TypeStatus records how the query for one record type in an ALL lookup went,
+// so that an empty section can be told apart from a failed query
+type TypeStatus struct {
+	Type    string `json:"type" yaml:"type"`
+	Status  string `json:"status" yaml:"status"`
+	Records int    `json:"records" yaml:"records"`
+	Error   string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Failed reports whether the query could not be answered. NXDOMAIN is an
+// answer: the resolver was told there is nothing to return.
+func (s TypeStatus) Failed() bool {
+	return s.Status == LookupStatusTimeout || s.Status == LookupStatusServFail
+}
+
+// FailedTypes returns the statuses of record types whose query failed
+func (r *DNSResult) FailedTypes() []TypeStatus {
+	var failed []TypeStatus
+	for _, status := range r.Statuses {
+		if status.Failed() {
+			failed = append(failed, status)
+		}
+	}
+	return failed
 }
 
 // DNSRecord represents a single DNS record
@@ -83,11 +146,13 @@ type ReverseResult struct {
 
 // dnsResultOutput is the serialization-friendly version of DNSResult
 type dnsResultOutput struct {
-	Domain      string      `json:"domain" yaml:"domain"`
-	QueryType   string      `json:"query_type" yaml:"query_type"`
-	Records     []DNSRecord `json:"records" yaml:"records"`
-	QueryTimeMS int64       `json:"query_time_ms" yaml:"query_time_ms"`
-	Server      string      `json:"server,omitempty" yaml:"server,omitempty"`
+	Domain      string       `json:"domain" yaml:"domain"`
+	QueryType   string       `json:"query_type" yaml:"query_type"`
+	Records     []DNSRecord  `json:"records" yaml:"records"`
+	QueryTimeMS int64        `json:"query_time_ms" yaml:"query_time_ms"`
+	Server      string       `json:"server,omitempty" yaml:"server,omitempty"`
+	Statuses    []TypeStatus `json:"statuses,omitempty" yaml:"statuses,omitempty"`
+	TTLWarnings []TTLWarning `json:"ttl_warnings,omitempty" yaml:"ttl_warnings,omitempty"`
 }
 
 // reverseResultOutput is the serialization-friendly version of ReverseResult
@@ -105,6 +170,8 @@ func (r *DNSResult) ToJSON() (string, error) {
 		Records:     r.Records,
 		QueryTimeMS: r.QueryTime.Milliseconds(),
 		Server:      r.Server,
+		Statuses:    r.Statuses,
+		TTLWarnings: r.TTLWarnings,
 	}
 	bytes, err := json.MarshalIndent(output, "", "  ")
 	if err != nil {
@@ -121,6 +188,8 @@ func (r *DNSResult) ToYAML() (string, error) {
 		Records:     r.Records,
 		QueryTimeMS: r.QueryTime.Milliseconds(),
 		Server:      r.Server,
+		Statuses:    r.Statuses,
+		TTLWarnings: r.TTLWarnings,
 	}
 	bytes, err := yaml.Marshal(output)
 	if err != nil {
@@ -208,9 +277,50 @@ func Lookup(domain string, opts LookupOptions) (*DNSResult, error) {
 		return nil, err
 	}
 
+	if opts.CheckTTL {
+		if result.TTLWarnings, err = checkTTLs(domain, result, opts); err != nil {
+			return nil, err
+		}
+	}
+
 	return result, nil
 }
 
+// checkTTLs queries each record type the lookup found records for directly,
+// since the system resolver API does not expose TTLs. A single answer has no
+// history, so only low TTLs can be flagged.
+func checkTTLs(domain string, result *DNSResult, opts LookupOptions) ([]TTLWarning, error) {
+	var recordTypes []string
+	if len(result.Statuses) > 0 {
+		for _, status := range result.Statuses {
+			if status.Records > 0 {
+				recordTypes = append(recordTypes, status.Type)
+			}
+		}
+	} else if len(result.Records) > 0 {
+		recordTypes = append(recordTypes, strings.ToUpper(result.QueryType))
+	}
+
+	var warnings []TTLWarning
+	for _, recordType := range recordTypes {
+		ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+		obs, err := Observe(ctx, domain, WatchOptions{RecordType: recordType, Server: opts.Server, Timeout: opts.Timeout})
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+
+		monitor := TTLMonitor{Low: opts.LowTTL}
+		for _, warning := range monitor.Check(obs) {
+			if len(result.Statuses) > 0 {
+				warning.Type = recordType
+			}
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings, nil
+}
+
 // ReverseLookup performs a PTR record lookup for an IP address
 func ReverseLookup(ip string, timeout time.Duration) (*ReverseResult, error) {
 	if ip == "" {
@@ -252,11 +362,9 @@ func createResolver(opts LookupOptions) dnsResolver {
 		return net.DefaultResolver
 	}
 
-	// Custom DNS server
-	server := opts.Server
-	if !strings.Contains(server, ":") {
-		server = server + ":53"
-	}
+	// Custom DNS server; an IPv6 literal such as 2001:4860:4860::8888 has
+	// colons of its own, so only a parsed host:port keeps its port
+	server := serverAddress(opts.Server)
 
 	return &net.Resolver{
 		PreferGo: true,
@@ -361,51 +469,60 @@ func lookupNS(ctx context.Context, resolver dnsResolver, domain string, result *
 	return nil
 }
 
-// lookupAll performs all supported record lookups
-func lookupAll(ctx context.Context, resolver dnsResolver, domain string, result *DNSResult) error {
-	// A records
-	if ips, err := resolver.LookupIP(ctx, "ip4", domain); err == nil {
-		for _, ip := range ips {
-			result.Records = append(result.Records, DNSRecord{Type: RecordTypeA, Value: ip.String()})
-		}
-	}
-
-	// AAAA records
-	if ips, err := resolver.LookupIP(ctx, "ip6", domain); err == nil {
-		for _, ip := range ips {
-			result.Records = append(result.Records, DNSRecord{Type: RecordTypeAAAA, Value: ip.String()})
-		}
-	}
+// allLookups are the record types an ALL lookup queries, in order
+var allLookups = []struct {
+	recordType string
+	lookup     func(context.Context, dnsResolver, string, *DNSResult) error
+}{
+	{RecordTypeA, lookupA},
+	{RecordTypeAAAA, lookupAAAA},
+	{RecordTypeCNAME, lookupCNAME},
+	{RecordTypeMX, lookupMX},
+	{RecordTypeNS, lookupNS},
+	{RecordTypeTXT, lookupTXT},
+}
 
-	// CNAME record
-	if cname, err := resolver.LookupCNAME(ctx, domain); err == nil {
-		result.Records = append(result.Records, DNSRecord{Type: RecordTypeCNAME, Value: strings.TrimSuffix(cname, ".")})
+// LookupQueries returns the most queries Lookup sends for opts: one per
+// record type, and with CheckTTL one more per type to read its TTL
+func LookupQueries(opts LookupOptions) int {
+	queries := 1
+	if strings.EqualFold(opts.RecordType, RecordTypeALL) {
+		queries = len(allLookups)
 	}
-
-	// MX records
-	if mxs, err := resolver.LookupMX(ctx, domain); err == nil {
-		for _, mx := range mxs {
-			result.Records = append(result.Records, DNSRecord{
-				Type:     RecordTypeMX,
-				Value:    strings.TrimSuffix(mx.Host, "."),
-				Priority: int(mx.Pref),
-			})
-		}
+	if opts.CheckTTL {
+		queries *= 2
 	}
+	return queries
+}
 
-	// NS records
-	if nss, err := resolver.LookupNS(ctx, domain); err == nil {
-		for _, ns := range nss {
-			result.Records = append(result.Records, DNSRecord{Type: RecordTypeNS, Value: strings.TrimSuffix(ns.Host, ".")})
-		}
-	}
+// lookupAll queries every supported record type. A failure for one type does
+// not stop the others; each outcome is recorded in result.Statuses instead.
+func lookupAll(ctx context.Context, resolver dnsResolver, domain string, result *DNSResult) error {
+	for _, l := range allLookups {
+		before := len(result.Records)
+		err := l.lookup(ctx, resolver, domain, result)
 
-	// TXT records
-	if txts, err := resolver.LookupTXT(ctx, domain); err == nil {
-		for _, txt := range txts {
-			result.Records = append(result.Records, DNSRecord{Type: RecordTypeTXT, Value: txt})
+		status := TypeStatus{Type: l.recordType, Status: lookupStatus(err), Records: len(result.Records) - before}
+		if err != nil {
+			status.Error = err.Error()
 		}
+		result.Statuses = append(result.Statuses, status)
 	}
 
 	return nil
 }
+
+// lookupStatus maps a per-type lookup error to its reported status
+func lookupStatus(err error) string {
+	if err == nil {
+		return LookupStatusOK
+	}
+	switch errcode.Of(err) {
+	case errcode.DNSNXDomain:
+		return LookupStatusNXDomain
+	case errcode.DNSTimeout:
+		return LookupStatusTimeout
+	default:
+		return LookupStatusServFail
+	}
+}
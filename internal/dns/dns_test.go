@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/euan-cowie/cidrator/internal/policy"
 	"gopkg.in/yaml.v3"
 )
 
@@ -63,6 +64,27 @@ func (f fakeReverseResolver) LookupAddr(ctx context.Context, addr string) ([]str
 	return f.lookupAddrFunc(ctx, addr)
 }
 
+func TestCheckedAddress(t *testing.T) {
+	// localhost resolves from the hosts file, so checking it pins loopback
+	if err := policy.CheckHosts(context.Background(), []string{"localhost"}, false); err != nil {
+		t.Fatal(err)
+	}
+	address, err := checkedAddress("localhost:53")
+	if err != nil {
+		t.Fatalf("checkedAddress returned error: %v", err)
+	}
+	host, port, _ := net.SplitHostPort(address)
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() || port != "53" {
+		t.Fatalf("checkedAddress(localhost:53) = %q, want a pinned loopback address", address)
+	}
+
+	for _, address := range []string{"192.0.2.53:53", "unchecked.example:53", "no port"} {
+		if got, err := checkedAddress(address); err != nil || got != address {
+			t.Errorf("checkedAddress(%q) = %q, %v, want it unchanged", address, got, err)
+		}
+	}
+}
+
 func TestDefaultLookupOptions(t *testing.T) {
 	opts := DefaultLookupOptions()
 
@@ -335,6 +357,72 @@ func TestLookupSupportedRecordTypes(t *testing.T) {
 	}
 }
 
+func TestLookupAllReportsPerTypeStatus(t *testing.T) {
+	original := resolverFactory
+	t.Cleanup(func() { resolverFactory = original })
+
+	resolverFactory = func(opts LookupOptions) dnsResolver {
+		return fakeDNSResolver{
+			lookupIPFunc: func(ctx context.Context, network, host string) ([]net.IP, error) {
+				if network == "ip6" {
+					return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+				}
+				return []net.IP{net.ParseIP("192.0.2.10")}, nil
+			},
+			lookupMXFunc: func(ctx context.Context, name string) ([]*net.MX, error) {
+				return nil, &net.DNSError{Err: "i/o timeout", Name: name, IsTimeout: true}
+			},
+			lookupTXTFunc: func(ctx context.Context, name string) ([]string, error) {
+				return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+			},
+			lookupCNAMEFunc: func(ctx context.Context, host string) (string, error) {
+				return host + ".", nil
+			},
+		}
+	}
+
+	result, err := Lookup("example.com", LookupOptions{RecordType: RecordTypeALL, Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("ALL lookup should not fail on per-type errors: %v", err)
+	}
+
+	want := map[string]string{
+		RecordTypeA:     LookupStatusOK,
+		RecordTypeAAAA:  LookupStatusNXDomain,
+		RecordTypeCNAME: LookupStatusOK,
+		RecordTypeMX:    LookupStatusTimeout,
+		RecordTypeNS:    LookupStatusOK,
+		RecordTypeTXT:   LookupStatusServFail,
+	}
+	if len(result.Statuses) != len(want) {
+		t.Fatalf("expected %d statuses, got %+v", len(want), result.Statuses)
+	}
+	for _, status := range result.Statuses {
+		if status.Status != want[status.Type] {
+			t.Errorf("%s: status %q, want %q", status.Type, status.Status, want[status.Type])
+		}
+		if status.Failed() != (status.Error != "" && status.Status != LookupStatusNXDomain) {
+			t.Errorf("%s: inconsistent failure state %+v", status.Type, status)
+		}
+	}
+	if result.Statuses[0].Records != 1 {
+		t.Fatalf("expected A record count of 1, got %+v", result.Statuses[0])
+	}
+
+	failed := result.FailedTypes()
+	if len(failed) != 2 || failed[0].Type != RecordTypeMX || failed[1].Type != RecordTypeTXT {
+		t.Fatalf("unexpected failed types: %+v", failed)
+	}
+
+	output, err := result.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, `"status": "timeout"`) {
+		t.Fatalf("expected statuses in JSON output: %s", output)
+	}
+}
+
 func TestLookupWrapsResolverErrors(t *testing.T) {
 	original := resolverFactory
 	t.Cleanup(func() { resolverFactory = original })
@@ -503,6 +591,36 @@ func TestCreateResolver(t *testing.T) {
 			t.Fatalf("unexpected dial inputs: network=%q address=%q timeout=%v", gotNetwork, gotAddress, gotTimeout)
 		}
 	})
+
+	t.Run("custom IPv6 server gets the default port", func(t *testing.T) {
+		original := resolverDialContext
+		t.Cleanup(func() { resolverDialContext = original })
+
+		var gotAddress string
+		resolverDialContext = func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+			gotAddress = address
+			left, right := net.Pipe()
+			_ = right.Close()
+			return left, nil
+		}
+
+		resolver, ok := createResolver(LookupOptions{
+			Server:  "2001:4860:4860::8888",
+			Timeout: time.Second,
+		}).(*net.Resolver)
+		if !ok {
+			t.Fatal("expected *net.Resolver for custom server")
+		}
+
+		conn, err := resolver.Dial(context.Background(), "udp", "ignored:53")
+		if err != nil {
+			t.Fatalf("resolver dial failed: %v", err)
+		}
+		defer conn.Close()
+		if gotAddress != "[2001:4860:4860::8888]:53" {
+			t.Fatalf("unexpected dial address %q", gotAddress)
+		}
+	})
 }
 
 func TestDNSError(t *testing.T) {
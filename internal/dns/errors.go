@@ -1,17 +1,21 @@
 package dns
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
 )
 
-// Sentinel errors for DNS operations
+// Sentinel errors for DNS operations, each carrying its stable error code
 var (
-	ErrEmptyDomain = errors.New("domain cannot be empty")
-	ErrEmptyIP     = errors.New("IP address cannot be empty")
-	ErrInvalidIP   = errors.New("invalid IP address format")
-	ErrNXDomain    = errors.New("domain does not exist (NXDOMAIN)")
-	ErrTimeout     = errors.New("DNS query timed out")
+	ErrEmptyDomain = errcode.Wrap(errcode.DNSEmptyDomain, errors.New("domain cannot be empty"))
+	ErrEmptyIP     = errcode.Wrap(errcode.DNSEmptyIP, errors.New("IP address cannot be empty"))
+	ErrInvalidIP   = errcode.Wrap(errcode.DNSInvalidIP, errors.New("invalid IP address format"))
+	ErrNXDomain    = errcode.Wrap(errcode.DNSNXDomain, errors.New("domain does not exist (NXDOMAIN)"))
+	ErrTimeout     = errcode.Wrap(errcode.DNSTimeout, errors.New("DNS query timed out"))
 )
 
 // DNSError represents a DNS operation error with context
@@ -29,6 +33,23 @@ func (e *DNSError) Unwrap() error {
 	return e.Err
 }
 
+// ErrorCode classifies the underlying failure, falling back to DNSQueryFailed
+func (e *DNSError) ErrorCode() errcode.Code {
+	if code := errcode.Of(e.Err); code != errcode.Unknown {
+		return code
+	}
+
+	var netErr *net.DNSError
+	switch {
+	case errors.As(e.Err, &netErr) && netErr.IsNotFound:
+		return errcode.DNSNXDomain
+	case isTimeout(e.Err) || errors.Is(e.Err, context.DeadlineExceeded):
+		return errcode.DNSTimeout
+	default:
+		return errcode.DNSQueryFailed
+	}
+}
+
 // NewDNSError creates a new DNSError
 func NewDNSError(operation, target string, err error) *DNSError {
 	return &DNSError{
@@ -0,0 +1,179 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// maxUDPResponseSize is large enough for EDNS-less referrals with glue
+const maxUDPResponseSize = 4096
+
+// dnsExchanger sends a single question to a specific server and returns the parsed reply
+type dnsExchanger func(ctx context.Context, server, name string, qtype dnsmessage.Type, timeout time.Duration) (*dnsmessage.Message, time.Duration, error)
+
+var dnsExchange dnsExchanger = exchangeDNS
+
+type serverCheckKey struct{}
+
+// withServerCheck returns a context under which exchangeDNS refuses to query
+// any server check rejects
+func withServerCheck(ctx context.Context, check func(server string) error) context.Context {
+	return context.WithValue(ctx, serverCheckKey{}, check)
+}
+
+// exchangeDNS sends a non-recursive query to server and returns the response and round-trip time.
+// Truncated UDP responses are retried over TCP.
+func exchangeDNS(ctx context.Context, server, name string, qtype dnsmessage.Type, timeout time.Duration) (*dnsmessage.Message, time.Duration, error) {
+	return exchangeQuery(ctx, server, name, qtype, false, timeout)
+}
+
+// exchangeRecursive is exchangeDNS with recursion desired, for querying resolvers
+func exchangeRecursive(ctx context.Context, server, name string, qtype dnsmessage.Type, timeout time.Duration) (*dnsmessage.Message, time.Duration, error) {
+	return exchangeQuery(ctx, server, name, qtype, true, timeout)
+}
+
+func exchangeQuery(ctx context.Context, server, name string, qtype dnsmessage.Type, recursive bool, timeout time.Duration) (*dnsmessage.Message, time.Duration, error) {
+	if check, ok := ctx.Value(serverCheckKey{}).(func(string) error); ok {
+		if err := check(server); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	qname, err := dnsmessage.NewName(fqdn(name))
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid query name %q: %w", name, err)
+	}
+
+	id := uint16(rand.Uint32())
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: recursive},
+		Questions: []dnsmessage.Question{
+			{Name: qname, Type: qtype, Class: dnsmessage.ClassINET},
+		},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to pack query: %w", err)
+	}
+
+	start := time.Now()
+	resp, err := exchangeDNSRecorded(ctx, "udp", server, name, qtype, packed, timeout)
+	if err == nil && resp.Truncated {
+		resp, err = exchangeDNSRecorded(ctx, "tcp", server, name, qtype, packed, timeout)
+	}
+	rtt := time.Since(start)
+	if err != nil {
+		return nil, rtt, err
+	}
+	if resp.ID != id {
+		return nil, rtt, fmt.Errorf("response ID %d does not match query ID %d", resp.ID, id)
+	}
+
+	return resp, rtt, nil
+}
+
+// exchangeDNSRecorded performs one round trip and hands the raw response to
+// any wire recorder on ctx before parsing it
+func exchangeDNSRecorded(ctx context.Context, network, server, name string, qtype dnsmessage.Type, packed []byte, timeout time.Duration) (*dnsmessage.Message, error) {
+	start := time.Now()
+	raw, err := exchangeDNSOver(ctx, network, server, packed, timeout)
+	if err != nil {
+		return nil, err
+	}
+	recordWire(ctx, WireResponse{
+		Server:    server,
+		Name:      fqdn(name),
+		Type:      qtype.String(),
+		Transport: network,
+		RTT:       time.Since(start),
+		Raw:       raw,
+	})
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(raw); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &resp, nil
+}
+
+// exchangeDNSOver performs one query/response round trip over the given network
+// and returns the unparsed response
+func exchangeDNSOver(ctx context.Context, network, server string, packed []byte, timeout time.Duration) ([]byte, error) {
+	conn, err := resolverDialContext(ctx, network, server, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	var raw []byte
+	if network == "tcp" {
+		frame := make([]byte, 2+len(packed))
+		binary.BigEndian.PutUint16(frame, uint16(len(packed)))
+		copy(frame[2:], packed)
+		if _, err := conn.Write(frame); err != nil {
+			return nil, err
+		}
+
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return nil, err
+		}
+		raw = make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, raw); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(packed); err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, maxUDPResponseSize)
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		raw = buf[:n]
+	}
+
+	return raw, nil
+}
+
+// isTimeout reports whether err is a network timeout
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// fqdn returns name with exactly one trailing dot
+func fqdn(name string) string {
+	return strings.TrimSuffix(name, ".") + "."
+}
+
+// trimDot removes the trailing root label from a DNS name
+func trimDot(name string) string {
+	if name == "." {
+		return name
+	}
+	return strings.TrimSuffix(name, ".")
+}
+
+// serverAddress appends the default DNS port to a bare IP or hostname
+func serverAddress(host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, "53")
+}
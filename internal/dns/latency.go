@@ -0,0 +1,98 @@
+package dns
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// DefaultLatencyBuckets are the histogram upper bounds used when none are configured
+var DefaultLatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// LatencyBucket is a cumulative histogram bucket: Count samples took at most LessOrEqualMS
+type LatencyBucket struct {
+	LessOrEqualMS float64 `json:"le_ms" yaml:"le_ms"`
+	Count         int     `json:"count" yaml:"count"`
+}
+
+// LatencyStats summarizes query latency across a batch run
+type LatencyStats struct {
+	Count    int             `json:"count" yaml:"count"`       // Completed queries
+	Timeouts int             `json:"timeouts" yaml:"timeouts"` // Queries that timed out (not in the histogram)
+	MinMS    float64         `json:"min_ms" yaml:"min_ms"`
+	MaxMS    float64         `json:"max_ms" yaml:"max_ms"`
+	MeanMS   float64         `json:"mean_ms" yaml:"mean_ms"`
+	P50MS    float64         `json:"p50_ms" yaml:"p50_ms"`
+	P90MS    float64         `json:"p90_ms" yaml:"p90_ms"`
+	P95MS    float64         `json:"p95_ms" yaml:"p95_ms"`
+	P99MS    float64         `json:"p99_ms" yaml:"p99_ms"`
+	Buckets  []LatencyBucket `json:"buckets" yaml:"buckets"`
+}
+
+// NewLatencyStats builds percentiles and a cumulative histogram from samples.
+// Buckets must be ascending; DefaultLatencyBuckets is used when buckets is empty.
+func NewLatencyStats(samples []time.Duration, timeouts int, buckets []time.Duration) LatencyStats {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+
+	stats := LatencyStats{
+		Count:    len(samples),
+		Timeouts: timeouts,
+		Buckets:  make([]LatencyBucket, len(buckets)),
+	}
+	for i, bound := range buckets {
+		stats.Buckets[i].LessOrEqualMS = durationMS(bound)
+	}
+	if len(samples) == 0 {
+		return stats
+	}
+
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+
+	var total time.Duration
+	for _, sample := range sorted {
+		total += sample
+		for i, bound := range buckets {
+			if sample <= bound {
+				stats.Buckets[i].Count++
+			}
+		}
+	}
+
+	stats.MinMS = durationMS(sorted[0])
+	stats.MaxMS = durationMS(sorted[len(sorted)-1])
+	stats.MeanMS = durationMS(total / time.Duration(len(sorted)))
+	stats.P50MS = durationMS(percentile(sorted, 50))
+	stats.P90MS = durationMS(percentile(sorted, 90))
+	stats.P95MS = durationMS(percentile(sorted, 95))
+	stats.P99MS = durationMS(percentile(sorted, 99))
+
+	return stats
+}
+
+// percentile returns the nearest-rank percentile of an ascending slice
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// durationMS converts a duration to milliseconds rounded to microsecond precision
+func durationMS(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
@@ -0,0 +1,55 @@
+package dns
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewLatencyStats(t *testing.T) {
+	samples := []time.Duration{
+		40 * time.Millisecond,
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		30 * time.Millisecond,
+		1500 * time.Microsecond,
+	}
+	buckets := []time.Duration{5 * time.Millisecond, 25 * time.Millisecond, 100 * time.Millisecond}
+
+	stats := NewLatencyStats(samples, 2, buckets)
+
+	if stats.Count != 5 || stats.Timeouts != 2 {
+		t.Fatalf("unexpected counts: %+v", stats)
+	}
+	if stats.MinMS != 1.5 || stats.MaxMS != 40 || stats.MeanMS != 20.3 {
+		t.Fatalf("unexpected min/max/mean: %+v", stats)
+	}
+	if stats.P50MS != 20 || stats.P90MS != 40 || stats.P99MS != 40 {
+		t.Fatalf("unexpected percentiles: %+v", stats)
+	}
+
+	want := []LatencyBucket{{5, 1}, {25, 3}, {100, 5}}
+	if len(stats.Buckets) != len(want) {
+		t.Fatalf("unexpected bucket count: %+v", stats.Buckets)
+	}
+	for i := range want {
+		if stats.Buckets[i] != want[i] {
+			t.Fatalf("bucket %d: expected %+v, got %+v", i, want[i], stats.Buckets[i])
+		}
+	}
+}
+
+func TestNewLatencyStatsEmpty(t *testing.T) {
+	stats := NewLatencyStats(nil, 3, nil)
+
+	if stats.Count != 0 || stats.Timeouts != 3 || stats.P99MS != 0 {
+		t.Fatalf("unexpected empty stats: %+v", stats)
+	}
+	if len(stats.Buckets) != len(DefaultLatencyBuckets) {
+		t.Fatalf("expected default buckets, got %+v", stats.Buckets)
+	}
+	for _, bucket := range stats.Buckets {
+		if bucket.Count != 0 {
+			t.Fatalf("expected empty buckets, got %+v", stats.Buckets)
+		}
+	}
+}
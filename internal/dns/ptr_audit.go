@@ -0,0 +1,473 @@
+package dns
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"gopkg.in/yaml.v3"
+)
+
+// PTRAuditOptions configures a reverse zone coverage audit
+type PTRAuditOptions struct {
+	ExpectDomain   string          // Domain every PTR hostname is expected to sit under (empty = no check)
+	Timeout        time.Duration   // Per-query timeout
+	Concurrency    int             // Number of addresses audited in parallel
+	MaxAddresses   int             // Refuse ranges larger than this (0 = no limit)
+	LatencyBuckets []time.Duration // Histogram upper bounds for the summary (empty = DefaultLatencyBuckets)
+	Resume         string          // Address to start from, the ResumeToken of an interrupted audit (empty = whole range)
+	Servers        []string        // Resolvers to spread queries across under Throttle (empty = system resolver, unthrottled)
+	Throttle       ThrottleOptions // Per-server caps and backoff, used with Servers
+}
+
+// PTRAuditEntry holds the audit outcome for a single address
+type PTRAuditEntry struct {
+	IP               string   `json:"ip" yaml:"ip"`
+	Hostnames        []string `json:"hostnames" yaml:"hostnames"`
+	HasPTR           bool     `json:"has_ptr" yaml:"has_ptr"`
+	ForwardConfirmed bool     `json:"forward_confirmed" yaml:"forward_confirmed"`
+	DomainMatch      bool     `json:"domain_match" yaml:"domain_match"`
+	QueryTimeMS      int64    `json:"query_time_ms" yaml:"query_time_ms"`
+	TimedOut         bool     `json:"timed_out,omitempty" yaml:"timed_out,omitempty"`
+	Error            string   `json:"error,omitempty" yaml:"error,omitempty"`
+
+	queryTime time.Duration
+}
+
+// OK reports whether the address passed every check
+func (e PTRAuditEntry) OK() bool {
+	return e.HasPTR && e.ForwardConfirmed && e.DomainMatch && e.Error == ""
+}
+
+// PTRAuditSummary counts audit outcomes across the range
+type PTRAuditSummary struct {
+	Total          int           `json:"total" yaml:"total"`
+	WithPTR        int           `json:"with_ptr" yaml:"with_ptr"`
+	MissingPTR     int           `json:"missing_ptr" yaml:"missing_ptr"`
+	FCrDNSFailed   int           `json:"fcrdns_failed" yaml:"fcrdns_failed"`
+	DomainMismatch int           `json:"domain_mismatch" yaml:"domain_mismatch"`
+	Errors         int           `json:"errors" yaml:"errors"`
+	Gaps           []string      `json:"gaps" yaml:"gaps"` // Contiguous runs of addresses without a PTR
+	Latency        LatencyStats  `json:"latency" yaml:"latency"`
+	Servers        []ServerStats `json:"servers,omitempty" yaml:"servers,omitempty"` // Per-server counts when PTRAuditOptions.Servers is set
+}
+
+// PTRAuditResult holds the results of a reverse zone coverage audit
+type PTRAuditResult struct {
+	CIDR         string
+	ExpectDomain string
+	Entries      []PTRAuditEntry
+	Summary      PTRAuditSummary
+	QueryTime    time.Duration
+	Interrupted  bool   // The audit was cancelled before every address was checked
+	Remaining    int    // Addresses left to audit
+	ResumeToken  string // First address left to audit; pass it as PTRAuditOptions.Resume
+}
+
+// ptrAuditResultOutput is the serialization-friendly version of PTRAuditResult
+type ptrAuditResultOutput struct {
+	CIDR         string          `json:"cidr" yaml:"cidr"`
+	ExpectDomain string          `json:"expect_domain,omitempty" yaml:"expect_domain,omitempty"`
+	Summary      PTRAuditSummary `json:"summary" yaml:"summary"`
+	Entries      []PTRAuditEntry `json:"entries" yaml:"entries"`
+	QueryTimeMS  int64           `json:"query_time_ms" yaml:"query_time_ms"`
+	Interrupted  bool            `json:"interrupted" yaml:"interrupted"`
+	Completed    int             `json:"completed" yaml:"completed"`
+	Remaining    int             `json:"remaining" yaml:"remaining"`
+	ResumeToken  string          `json:"resume_token,omitempty" yaml:"resume_token,omitempty"`
+}
+
+func (r *PTRAuditResult) toOutput() ptrAuditResultOutput {
+	return ptrAuditResultOutput{
+		CIDR:         r.CIDR,
+		ExpectDomain: r.ExpectDomain,
+		Summary:      r.Summary,
+		Entries:      r.Entries,
+		QueryTimeMS:  r.QueryTime.Milliseconds(),
+		Interrupted:  r.Interrupted,
+		Completed:    len(r.Entries),
+		Remaining:    r.Remaining,
+		ResumeToken:  r.ResumeToken,
+	}
+}
+
+// ToJSON converts PTRAuditResult to JSON string
+func (r *PTRAuditResult) ToJSON() (string, error) {
+	bytes, err := json.MarshalIndent(r.toOutput(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// ToYAML converts PTRAuditResult to YAML string
+func (r *PTRAuditResult) ToYAML() (string, error) {
+	bytes, err := yaml.Marshal(r.toOutput())
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// PTRAuditPlan describes the traffic a PTR audit would generate
+type PTRAuditPlan struct {
+	CIDR              string
+	ExpectDomain      string
+	Targets           []string
+	PTRQueries        int // One per address; each PTR hostname found adds a forward lookup
+	Concurrency       int
+	Timeout           time.Duration
+	EstimatedDuration time.Duration // Worst case, with every address hitting the timeout
+	Servers           []string      // Resolvers queries are spread across (empty = system resolver)
+	QPS               float64       // Per-server query rate cap (0 = none)
+}
+
+// ptrAuditPlanOutput is the serialization-friendly version of PTRAuditPlan
+type ptrAuditPlanOutput struct {
+	CIDR                string   `json:"cidr" yaml:"cidr"`
+	ExpectDomain        string   `json:"expect_domain,omitempty" yaml:"expect_domain,omitempty"`
+	Targets             []string `json:"targets" yaml:"targets"`
+	PTRQueries          int      `json:"ptr_queries" yaml:"ptr_queries"`
+	Concurrency         int      `json:"concurrency" yaml:"concurrency"`
+	TimeoutMS           int64    `json:"timeout_ms" yaml:"timeout_ms"`
+	EstimatedDurationMS int64    `json:"estimated_duration_ms" yaml:"estimated_duration_ms"`
+	Servers             []string `json:"servers,omitempty" yaml:"servers,omitempty"`
+	QPS                 float64  `json:"qps,omitempty" yaml:"qps,omitempty"`
+}
+
+func (p *PTRAuditPlan) toOutput() ptrAuditPlanOutput {
+	return ptrAuditPlanOutput{
+		CIDR:                p.CIDR,
+		ExpectDomain:        p.ExpectDomain,
+		Targets:             p.Targets,
+		PTRQueries:          p.PTRQueries,
+		Concurrency:         p.Concurrency,
+		TimeoutMS:           p.Timeout.Milliseconds(),
+		EstimatedDurationMS: p.EstimatedDuration.Milliseconds(),
+		Servers:             p.Servers,
+		QPS:                 p.QPS,
+	}
+}
+
+// ToJSON converts PTRAuditPlan to JSON string
+func (p *PTRAuditPlan) ToJSON() (string, error) {
+	bytes, err := json.MarshalIndent(p.toOutput(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// ToYAML converts PTRAuditPlan to YAML string
+func (p *PTRAuditPlan) ToYAML() (string, error) {
+	bytes, err := yaml.Marshal(p.toOutput())
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// AuditPTR checks that every address in cidrStr has a PTR record, that the PTR
+// hostname forward-confirms back to the address (FCrDNS), and optionally that
+// the hostname sits under opts.ExpectDomain.
+//
+// If ctx ends first, AuditPTR still returns a result: the addresses audited
+// so far, in order up to the first one left unfinished, with Interrupted set
+// and ResumeToken naming where a rerun with opts.Resume should pick up.
+func AuditPTR(ctx context.Context, cidrStr string, opts PTRAuditOptions) (*PTRAuditResult, error) {
+	ips, err := ptrAuditTargets(cidrStr, opts.MaxAddresses)
+	if err != nil {
+		return nil, err
+	}
+	if ips, err = resumePTRAudit(ips, cidrStr, opts.Resume); err != nil {
+		return nil, err
+	}
+
+	concurrency := ptrAuditConcurrency(opts)
+	expectDomain := normalizeExpectDomain(opts.ExpectDomain)
+
+	var reverse reverseResolver = reverseLookupResolver
+	var forward forwardResolver = resolverFactory(LookupOptions{Timeout: opts.Timeout})
+	addressTimeout := opts.Timeout
+	var client *throttledClient
+	if len(opts.Servers) > 0 {
+		client = newThrottledClient(opts.Servers, opts.Timeout, opts.Throttle)
+		reverse, forward = client, client
+		// Each query has its own timeout; a deadline for the whole address would cut retries short
+		addressTimeout = 0
+	}
+
+	start := time.Now()
+	entries := make([]PTRAuditEntry, len(ips))
+	done := make([]bool, len(ips))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				entry := auditAddress(ctx, ips[i], reverse, forward, expectDomain, addressTimeout)
+				if ctx.Err() != nil {
+					// Cut short by the cancellation, not a real answer
+					continue
+				}
+				entries[i], done[i] = entry, true
+			}
+		}()
+	}
+feed:
+	for i := range ips {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Keep only the unbroken run of finished addresses so the gaps and the
+	// resume point stay exact; anything finished past it is audited again
+	completed := 0
+	for completed < len(ips) && done[completed] {
+		completed++
+	}
+	entries = entries[:completed]
+
+	result := &PTRAuditResult{
+		CIDR:         cidrStr,
+		ExpectDomain: expectDomain,
+		Entries:      entries,
+		Summary:      summarizePTRAudit(entries, opts.LatencyBuckets),
+		QueryTime:    time.Since(start),
+		Remaining:    len(ips) - completed,
+	}
+	if client != nil {
+		result.Summary.Servers = client.stats()
+	}
+	if completed < len(ips) {
+		result.Interrupted = true
+		result.ResumeToken = ips[completed]
+	}
+	return result, nil
+}
+
+// PlanPTRAudit expands cidrStr and describes the queries AuditPTR would send,
+// without sending any of them
+func PlanPTRAudit(cidrStr string, opts PTRAuditOptions) (*PTRAuditPlan, error) {
+	ips, err := ptrAuditTargets(cidrStr, opts.MaxAddresses)
+	if err != nil {
+		return nil, err
+	}
+	if ips, err = resumePTRAudit(ips, cidrStr, opts.Resume); err != nil {
+		return nil, err
+	}
+
+	concurrency := ptrAuditConcurrency(opts)
+	rounds := (len(ips) + concurrency - 1) / concurrency
+	estimate := time.Duration(rounds) * opts.Timeout
+	if len(opts.Servers) > 0 && opts.Throttle.QPS > 0 {
+		// The rate cap alone sets a floor, however fast the servers answer
+		paced := time.Duration(float64(len(ips)) / (opts.Throttle.QPS * float64(len(opts.Servers))) * float64(time.Second))
+		estimate = max(estimate, paced)
+	}
+
+	plan := &PTRAuditPlan{
+		CIDR:              cidrStr,
+		ExpectDomain:      normalizeExpectDomain(opts.ExpectDomain),
+		Targets:           ips,
+		PTRQueries:        len(ips),
+		Concurrency:       concurrency,
+		Timeout:           opts.Timeout,
+		EstimatedDuration: estimate,
+	}
+	if len(opts.Servers) > 0 {
+		plan.Servers = opts.Servers
+		plan.QPS = opts.Throttle.QPS
+	}
+	return plan, nil
+}
+
+// ptrAuditTargets enforces the address limit and expands the range
+func ptrAuditTargets(cidrStr string, maxAddresses int) ([]string, error) {
+	count, err := cidr.Count(cidrStr)
+	if err != nil {
+		return nil, NewDNSError("ptr-audit", cidrStr, err)
+	}
+	if maxAddresses > 0 && count.Cmp(big.NewInt(int64(maxAddresses))) > 0 {
+		return nil, NewDNSError("ptr-audit", cidrStr,
+			fmt.Errorf("range has %s addresses, above the limit of %d", count, maxAddresses))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var ips []string
+	for res := range cidr.Expand(ctx, cidrStr, cidr.ExpansionOptions{}) {
+		if res.Err != nil {
+			return nil, NewDNSError("ptr-audit", cidrStr, res.Err)
+		}
+		ips = append(ips, res.IP)
+	}
+	return ips, nil
+}
+
+// resumePTRAudit drops the addresses before resume, the ResumeToken of an
+// earlier interrupted audit of the same range
+func resumePTRAudit(ips []string, cidrStr, resume string) ([]string, error) {
+	if resume == "" {
+		return ips, nil
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(resume))
+	if err != nil {
+		return nil, errcode.Errorf(errcode.CLIUsage, "invalid resume token %q: expected an address in %s", resume, cidrStr)
+	}
+	i := slices.Index(ips, addr.String())
+	if i < 0 {
+		return nil, errcode.Errorf(errcode.CLIUsage, "resume token %s is not in %s", addr, cidrStr)
+	}
+	return ips[i:], nil
+}
+
+func ptrAuditConcurrency(opts PTRAuditOptions) int {
+	if opts.Concurrency <= 0 {
+		return 1
+	}
+	return opts.Concurrency
+}
+
+func normalizeExpectDomain(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+}
+
+// forwardResolver confirms PTR hostnames; dnsResolver and throttledClient both satisfy it
+type forwardResolver interface {
+	LookupIP(ctx context.Context, network, host string) ([]net.IP, error)
+}
+
+// auditAddress runs the PTR, FCrDNS, and domain checks for one address,
+// within timeout when it is positive
+func auditAddress(parent context.Context, ip string, reverse reverseResolver, forward forwardResolver, expectDomain string, timeout time.Duration) PTRAuditEntry {
+	entry := PTRAuditEntry{IP: ip, Hostnames: []string{}}
+
+	ctx := parent
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	names, err := reverse.LookupAddr(ctx, ip)
+	entry.queryTime = time.Since(start)
+	entry.QueryTimeMS = entry.queryTime.Milliseconds()
+	if err != nil {
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			entry.Error = err.Error()
+			entry.TimedOut = isTimeout(err) || errors.Is(err, context.DeadlineExceeded)
+		}
+		return entry
+	}
+	for _, name := range names {
+		entry.Hostnames = append(entry.Hostnames, strings.ToLower(trimDot(name)))
+	}
+	entry.HasPTR = len(entry.Hostnames) > 0
+	entry.DomainMatch = expectDomain == ""
+
+	target := net.ParseIP(ip)
+	// Only the target's family can confirm it, so skip the other lookup
+	network := "ip6"
+	if target.To4() != nil {
+		network = "ip4"
+	}
+	for _, host := range entry.Hostnames {
+		if expectDomain != "" && inBailiwick(host, expectDomain) {
+			entry.DomainMatch = true
+		}
+		if entry.ForwardConfirmed {
+			continue
+		}
+		addrs, err := forward.LookupIP(ctx, network, host)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if addr.Equal(target) {
+				entry.ForwardConfirmed = true
+				break
+			}
+		}
+	}
+
+	return entry
+}
+
+// summarizePTRAudit counts outcomes, collapses missing PTRs into address ranges,
+// and builds the PTR query latency histogram
+func summarizePTRAudit(entries []PTRAuditEntry, buckets []time.Duration) PTRAuditSummary {
+	summary := PTRAuditSummary{Total: len(entries), Gaps: []string{}}
+
+	var samples []time.Duration
+	timeouts := 0
+	for _, entry := range entries {
+		if entry.TimedOut {
+			timeouts++
+			continue
+		}
+		samples = append(samples, entry.queryTime)
+	}
+	summary.Latency = NewLatencyStats(samples, timeouts, buckets)
+
+	gapStart := -1
+	closeGap := func(end int) {
+		if gapStart < 0 {
+			return
+		}
+		if gapStart == end {
+			summary.Gaps = append(summary.Gaps, entries[gapStart].IP)
+		} else {
+			summary.Gaps = append(summary.Gaps, entries[gapStart].IP+"-"+entries[end].IP)
+		}
+		gapStart = -1
+	}
+
+	for i, entry := range entries {
+		switch {
+		case entry.Error != "":
+			summary.Errors++
+		case !entry.HasPTR:
+			summary.MissingPTR++
+		default:
+			summary.WithPTR++
+			if !entry.ForwardConfirmed {
+				summary.FCrDNSFailed++
+			}
+			if !entry.DomainMatch {
+				summary.DomainMismatch++
+			}
+		}
+
+		if !entry.HasPTR && entry.Error == "" {
+			if gapStart < 0 {
+				gapStart = i
+			}
+			continue
+		}
+		closeGap(i - 1)
+	}
+	closeGap(len(entries) - 1)
+
+	return summary
+}
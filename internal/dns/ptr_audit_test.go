@@ -0,0 +1,213 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+func TestAuditPTR(t *testing.T) {
+	originalReverse := reverseLookupResolver
+	originalFactory := resolverFactory
+	t.Cleanup(func() {
+		reverseLookupResolver = originalReverse
+		resolverFactory = originalFactory
+	})
+
+	ptrs := map[string][]string{
+		"192.0.2.0": {"net.example.net."},
+		"192.0.2.1": {"mail.example.net."},
+		"192.0.2.2": {"host.other.test."},
+		"192.0.2.5": {"stale.example.net."},
+	}
+	reverseLookupResolver = fakeReverseResolver{
+		lookupAddrFunc: func(ctx context.Context, addr string) ([]string, error) {
+			if addr == "192.0.2.6" {
+				return nil, errors.New("server misbehaving")
+			}
+			if names, ok := ptrs[addr]; ok {
+				return names, nil
+			}
+			return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+		},
+	}
+	resolverFactory = func(opts LookupOptions) dnsResolver {
+		return fakeDNSResolver{
+			lookupIPFunc: func(ctx context.Context, network, host string) ([]net.IP, error) {
+				switch host {
+				case "net.example.net":
+					return []net.IP{net.ParseIP("192.0.2.0")}, nil
+				case "mail.example.net":
+					return []net.IP{net.ParseIP("192.0.2.1")}, nil
+				case "host.other.test":
+					return []net.IP{net.ParseIP("192.0.2.2")}, nil
+				case "stale.example.net":
+					return []net.IP{net.ParseIP("198.51.100.1")}, nil
+				}
+				return nil, errors.New("not found")
+			},
+		}
+	}
+
+	result, err := AuditPTR(context.Background(), "192.0.2.0/29", PTRAuditOptions{
+		ExpectDomain: "Example.NET.",
+		Timeout:      time.Second,
+		Concurrency:  4,
+	})
+	if err != nil {
+		t.Fatalf("AuditPTR returned error: %v", err)
+	}
+
+	summary := result.Summary
+	if summary.Total != 8 || summary.WithPTR != 4 || summary.MissingPTR != 3 || summary.Errors != 1 {
+		t.Fatalf("unexpected summary counts: %+v", summary)
+	}
+	if summary.FCrDNSFailed != 1 || summary.DomainMismatch != 1 {
+		t.Fatalf("unexpected check failures: %+v", summary)
+	}
+	if strings.Join(summary.Gaps, ",") != "192.0.2.3-192.0.2.4,192.0.2.7" {
+		t.Fatalf("unexpected gaps: %v", summary.Gaps)
+	}
+	if !result.Entries[1].OK() || result.Entries[2].DomainMatch || result.Entries[5].ForwardConfirmed {
+		t.Fatalf("unexpected entries: %+v", result.Entries)
+	}
+	if summary.Latency.Count != 8 || summary.Latency.Timeouts != 0 || len(summary.Latency.Buckets) != len(DefaultLatencyBuckets) {
+		t.Fatalf("unexpected latency summary: %+v", summary.Latency)
+	}
+}
+
+func TestAuditPTRCountsTimeoutsSeparately(t *testing.T) {
+	originalReverse := reverseLookupResolver
+	t.Cleanup(func() { reverseLookupResolver = originalReverse })
+
+	reverseLookupResolver = fakeReverseResolver{
+		lookupAddrFunc: func(ctx context.Context, addr string) ([]string, error) {
+			if addr == "192.0.2.1" {
+				return nil, &net.DNSError{Err: "i/o timeout", Name: addr, IsTimeout: true}
+			}
+			return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+		},
+	}
+
+	result, err := AuditPTR(context.Background(), "192.0.2.0/31", PTRAuditOptions{
+		Timeout:        time.Second,
+		LatencyBuckets: []time.Duration{time.Second},
+	})
+	if err != nil {
+		t.Fatalf("AuditPTR returned error: %v", err)
+	}
+
+	latency := result.Summary.Latency
+	if latency.Count != 1 || latency.Timeouts != 1 || latency.Buckets[0].Count != 1 {
+		t.Fatalf("unexpected latency summary: %+v", latency)
+	}
+	if !result.Entries[1].TimedOut {
+		t.Fatalf("expected timed out entry, got %+v", result.Entries[1])
+	}
+}
+
+func TestAuditPTRInterrupted(t *testing.T) {
+	originalReverse := reverseLookupResolver
+	t.Cleanup(func() { reverseLookupResolver = originalReverse })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reverseLookupResolver = fakeReverseResolver{
+		lookupAddrFunc: func(lookupCtx context.Context, addr string) ([]string, error) {
+			if addr == "192.0.2.3" {
+				cancel()
+				<-lookupCtx.Done()
+				return nil, lookupCtx.Err()
+			}
+			return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+		},
+	}
+
+	result, err := AuditPTR(ctx, "192.0.2.0/29", PTRAuditOptions{Timeout: time.Second, Concurrency: 1})
+	if err != nil {
+		t.Fatalf("AuditPTR returned error: %v", err)
+	}
+	if !result.Interrupted || result.ResumeToken != "192.0.2.3" || result.Remaining != 5 {
+		t.Fatalf("unexpected interruption state: interrupted=%v token=%q remaining=%d",
+			result.Interrupted, result.ResumeToken, result.Remaining)
+	}
+	if len(result.Entries) != 3 || result.Summary.Total != 3 || result.Summary.Errors != 0 {
+		t.Fatalf("expected only the 3 finished addresses, got %+v", result.Summary)
+	}
+	if len(result.Summary.Gaps) != 1 || result.Summary.Gaps[0] != "192.0.2.0-192.0.2.2" {
+		t.Fatalf("unexpected gaps: %v", result.Summary.Gaps)
+	}
+	if output, err := result.ToJSON(); err != nil || !strings.Contains(output, `"interrupted": true`) ||
+		!strings.Contains(output, `"completed": 3`) || !strings.Contains(output, `"resume_token": "192.0.2.3"`) {
+		t.Fatalf("unexpected JSON (err %v): %s", err, output)
+	}
+
+	reverseLookupResolver = fakeReverseResolver{
+		lookupAddrFunc: func(ctx context.Context, addr string) ([]string, error) {
+			return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+		},
+	}
+	resumed, err := AuditPTR(context.Background(), "192.0.2.0/29", PTRAuditOptions{Timeout: time.Second, Resume: result.ResumeToken})
+	if err != nil {
+		t.Fatalf("resumed AuditPTR returned error: %v", err)
+	}
+	if resumed.Interrupted || resumed.Remaining != 0 || len(resumed.Entries) != 5 || resumed.Entries[0].IP != "192.0.2.3" {
+		t.Fatalf("unexpected resumed result: %+v", resumed)
+	}
+
+	if _, err := AuditPTR(context.Background(), "192.0.2.0/29", PTRAuditOptions{Resume: "198.51.100.1"}); errcode.Of(err) != errcode.CLIUsage {
+		t.Fatalf("expected CLIUsage for a resume token outside the range, got %v", err)
+	}
+}
+
+func TestAuditPTRValidation(t *testing.T) {
+	if _, err := AuditPTR(context.Background(), "not-a-cidr", PTRAuditOptions{Timeout: time.Second}); err == nil {
+		t.Fatal("expected invalid CIDR error")
+	}
+
+	_, err := AuditPTR(context.Background(), "10.0.0.0/16", PTRAuditOptions{Timeout: time.Second, MaxAddresses: 256})
+	if err == nil || !strings.Contains(err.Error(), "above the limit of 256") {
+		t.Fatalf("expected max address error, got %v", err)
+	}
+}
+
+func TestPlanPTRAudit(t *testing.T) {
+	originalReverse := reverseLookupResolver
+	t.Cleanup(func() { reverseLookupResolver = originalReverse })
+
+	reverseLookupResolver = fakeReverseResolver{
+		lookupAddrFunc: func(ctx context.Context, addr string) ([]string, error) {
+			t.Fatalf("dry-run plan must not send queries (looked up %s)", addr)
+			return nil, nil
+		},
+	}
+
+	plan, err := PlanPTRAudit("192.0.2.0/29", PTRAuditOptions{Timeout: 2 * time.Second, Concurrency: 3, ExpectDomain: "Example.NET."})
+	if err != nil {
+		t.Fatalf("PlanPTRAudit returned error: %v", err)
+	}
+	if len(plan.Targets) != 8 || plan.Targets[0] != "192.0.2.0" || plan.Targets[7] != "192.0.2.7" {
+		t.Fatalf("unexpected targets: %v", plan.Targets)
+	}
+	if plan.PTRQueries != 8 || plan.ExpectDomain != "example.net" {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	// 8 addresses across 3 workers is 3 rounds of at most 2s
+	if plan.EstimatedDuration != 6*time.Second {
+		t.Fatalf("unexpected duration estimate: %v", plan.EstimatedDuration)
+	}
+
+	resumed, err := PlanPTRAudit("192.0.2.0/29", PTRAuditOptions{Timeout: time.Second, Resume: "192.0.2.6"})
+	if err != nil || len(resumed.Targets) != 2 || resumed.PTRQueries != 2 {
+		t.Fatalf("unexpected resumed plan (err %v): %+v", err, resumed)
+	}
+
+	if _, err := PlanPTRAudit("10.0.0.0/16", PTRAuditOptions{MaxAddresses: 256}); err == nil {
+		t.Fatal("expected max address error")
+	}
+}
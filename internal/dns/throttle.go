@@ -0,0 +1,326 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// throttledExchange sends each query of a throttled batch
+var throttledExchange dnsExchanger = exchangeRecursive
+
+// Backoff defaults after a SERVFAIL, REFUSED, or timeout
+const (
+	defaultBackoffBase = 100 * time.Millisecond
+	defaultBackoffMax  = 10 * time.Second
+)
+
+// ThrottleOptions caps the load a batch run puts on each DNS server. A server
+// that answers SERVFAIL or REFUSED, or stops answering, is paused for an
+// exponentially growing, jittered interval while the failures continue.
+type ThrottleOptions struct {
+	Concurrency int           // Queries in flight per server (0 = no cap)
+	QPS         float64       // Queries per second per server (0 = no cap)
+	Retries     int           // Retries of a failed query, each on the next server
+	BackoffBase time.Duration // Pause after the first failure in a row (0 = 100ms)
+	BackoffMax  time.Duration // Longest pause (0 = 10s)
+}
+
+// ServerStats counts what one server did during a throttled batch run
+type ServerStats struct {
+	Server    string  `json:"server" yaml:"server"`
+	Queries   int     `json:"queries" yaml:"queries"`   // Sent, retries included
+	Answered  int     `json:"answered" yaml:"answered"` // NOERROR or NXDOMAIN
+	ServFail  int     `json:"servfail" yaml:"servfail"`
+	Refused   int     `json:"refused" yaml:"refused"`
+	Timeouts  int     `json:"timeouts" yaml:"timeouts"`
+	Errors    int     `json:"errors" yaml:"errors"`         // Other failures, such as a closed port or an unexpected RCODE
+	Backoffs  int     `json:"backoffs" yaml:"backoffs"`     // Times the server was paused after failing
+	BackoffMS float64 `json:"backoff_ms" yaml:"backoff_ms"` // Total time paused
+	MeanMS    float64 `json:"mean_ms" yaml:"mean_ms"`       // Mean round trip of answered queries
+}
+
+// Throttled reports whether the server pushed back with SERVFAIL or REFUSED
+func (s ServerStats) Throttled() bool {
+	return s.ServFail > 0 || s.Refused > 0
+}
+
+// throttledServer holds the rate limit and backoff state of one server
+type throttledServer struct {
+	addr  string
+	slots chan struct{} // Concurrency cap (nil = none)
+
+	mu       sync.Mutex
+	next     time.Time // Earliest start of the next query, from the QPS cap and any backoff
+	failures int       // Failures in a row
+	stats    ServerStats
+	rttTotal time.Duration
+}
+
+// throttledClient spreads recursive queries across servers, honoring each
+// server's caps. It resolves PTR and forward lookups for a PTR audit.
+type throttledClient struct {
+	servers []*throttledServer
+	opts    ThrottleOptions
+	timeout time.Duration
+	turn    atomic.Uint64
+}
+
+func newThrottledClient(servers []string, timeout time.Duration, opts ThrottleOptions) *throttledClient {
+	if opts.BackoffBase <= 0 {
+		opts.BackoffBase = defaultBackoffBase
+	}
+	if opts.BackoffMax <= 0 {
+		opts.BackoffMax = defaultBackoffMax
+	}
+
+	client := &throttledClient{opts: opts, timeout: timeout}
+	for _, server := range servers {
+		s := &throttledServer{addr: serverAddress(server)}
+		s.stats.Server = s.addr
+		if opts.Concurrency > 0 {
+			s.slots = make(chan struct{}, opts.Concurrency)
+		}
+		client.servers = append(client.servers, s)
+	}
+	return client
+}
+
+// exchange sends one query to the next server in turn. A SERVFAIL, REFUSED,
+// or failed round trip is retried on the following server up to opts.Retries
+// times; the last response or error is returned.
+func (c *throttledClient) exchange(ctx context.Context, name string, qtype dnsmessage.Type) (*dnsmessage.Message, error) {
+	first := int(c.turn.Add(1) - 1)
+
+	var resp *dnsmessage.Message
+	var err error
+	for attempt := 0; attempt <= c.opts.Retries; attempt++ {
+		server := c.servers[(first+attempt)%len(c.servers)]
+		resp, err = server.exchange(ctx, name, qtype, c.timeout, c.opts)
+		if ctx.Err() != nil || !shouldRetry(resp, err) {
+			break
+		}
+	}
+	return resp, err
+}
+
+// shouldRetry reports whether an outcome is worth another server's opinion
+func shouldRetry(resp *dnsmessage.Message, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.RCode == dnsmessage.RCodeServerFailure || resp.RCode == dnsmessage.RCodeRefused
+}
+
+// exchange waits for a free slot and the server's next start time, then sends the query
+func (s *throttledServer) exchange(ctx context.Context, name string, qtype dnsmessage.Type, timeout time.Duration, opts ThrottleOptions) (*dnsmessage.Message, error) {
+	if s.slots != nil {
+		select {
+		case s.slots <- struct{}{}:
+			defer func() { <-s.slots }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if err := s.wait(ctx, opts.QPS); err != nil {
+		return nil, err
+	}
+
+	resp, rtt, err := throttledExchange(ctx, s.addr, name, qtype, timeout)
+	if errors.Is(err, context.Canceled) {
+		return nil, err
+	}
+	s.record(resp, rtt, err, opts)
+	return resp, err
+}
+
+// wait reserves the server's next start time and sleeps until it arrives
+func (s *throttledServer) wait(ctx context.Context, qps float64) error {
+	s.mu.Lock()
+	now := time.Now()
+	start := now
+	if s.next.After(now) {
+		start = s.next
+	}
+	if qps > 0 {
+		s.next = start.Add(time.Duration(float64(time.Second) / qps))
+	}
+	s.mu.Unlock()
+
+	delay := start.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// record counts the outcome and pauses the server while failures continue
+func (s *throttledServer) record(resp *dnsmessage.Message, rtt time.Duration, err error, opts ThrottleOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.Queries++
+	failed := true
+	switch {
+	case err != nil && (isTimeout(err) || errors.Is(err, context.DeadlineExceeded)):
+		s.stats.Timeouts++
+	case err != nil:
+		s.stats.Errors++
+	case resp.RCode == dnsmessage.RCodeSuccess || resp.RCode == dnsmessage.RCodeNameError:
+		s.stats.Answered++
+		s.rttTotal += rtt
+		failed = false
+	case resp.RCode == dnsmessage.RCodeServerFailure:
+		s.stats.ServFail++
+	case resp.RCode == dnsmessage.RCodeRefused:
+		s.stats.Refused++
+	default:
+		// NOTIMP and friends are a property of the query, not of load
+		s.stats.Errors++
+		failed = false
+	}
+	if !failed {
+		s.failures = 0
+		return
+	}
+
+	s.failures++
+	pause := backoffDelay(s.failures, opts)
+	if until := time.Now().Add(pause); until.After(s.next) {
+		s.next = until
+	}
+	s.stats.Backoffs++
+	s.stats.BackoffMS += durationMS(pause)
+}
+
+// backoffDelay doubles opts.BackoffBase for each failure in a row, up to
+// opts.BackoffMax, and picks a random point in the upper half so servers
+// throttling many clients at once do not see them all return together
+func backoffDelay(failures int, opts ThrottleOptions) time.Duration {
+	delay := opts.BackoffBase
+	for i := 1; i < failures && delay < opts.BackoffMax; i++ {
+		delay *= 2
+	}
+	delay = min(delay, opts.BackoffMax)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// stats returns the per-server counters in the order the servers were given
+func (c *throttledClient) stats() []ServerStats {
+	stats := make([]ServerStats, len(c.servers))
+	for i, s := range c.servers {
+		s.mu.Lock()
+		stats[i] = s.stats
+		if s.stats.Answered > 0 {
+			stats[i].MeanMS = durationMS(s.rttTotal / time.Duration(s.stats.Answered))
+		}
+		s.mu.Unlock()
+	}
+	return stats
+}
+
+// LookupAddr resolves the PTR records of addr, reporting a missing record
+// the way net.Resolver does
+func (c *throttledClient) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return nil, &net.DNSError{Err: "unrecognized address", Name: addr}
+	}
+	name := cidr.ReverseName(ip)
+
+	resp, err := c.exchange(ctx, name, dnsmessage.TypePTR)
+	if err != nil {
+		return nil, err
+	}
+	if err := rcodeError(resp, name); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, answer := range resp.Answers {
+		if ptr, ok := answer.Body.(*dnsmessage.PTRResource); ok {
+			names = append(names, ptr.PTR.String())
+		}
+	}
+	if len(names) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return names, nil
+}
+
+// LookupIP resolves host to addresses of the family network asks for
+// ("ip4", "ip6", or "ip" for both)
+func (c *throttledClient) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	var qtypes []dnsmessage.Type
+	if network != "ip6" {
+		qtypes = append(qtypes, dnsmessage.TypeA)
+	}
+	if network != "ip4" {
+		qtypes = append(qtypes, dnsmessage.TypeAAAA)
+	}
+
+	var ips []net.IP
+	for _, qtype := range qtypes {
+		resp, err := c.exchange(ctx, host, qtype)
+		if err != nil {
+			return nil, err
+		}
+		if err := rcodeError(resp, host); err != nil {
+			return nil, err
+		}
+		for _, answer := range resp.Answers {
+			switch rr := answer.Body.(type) {
+			case *dnsmessage.AResource:
+				ips = append(ips, net.IP(rr.A[:]))
+			case *dnsmessage.AAAAResource:
+				ips = append(ips, net.IP(rr.AAAA[:]))
+			}
+		}
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return ips, nil
+}
+
+// rcodeError turns a failed response into the error net.Resolver would return
+func rcodeError(resp *dnsmessage.Message, name string) error {
+	switch resp.RCode {
+	case dnsmessage.RCodeSuccess:
+		return nil
+	case dnsmessage.RCodeNameError:
+		return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	default:
+		return &net.DNSError{Err: "server answered " + rcodeName(resp.RCode), Name: name, IsTemporary: true}
+	}
+}
+
+// rcodeName returns the conventional mnemonic for an RCODE
+func rcodeName(rcode dnsmessage.RCode) string {
+	switch rcode {
+	case dnsmessage.RCodeServerFailure:
+		return "SERVFAIL"
+	case dnsmessage.RCodeRefused:
+		return "REFUSED"
+	case dnsmessage.RCodeNotImplemented:
+		return "NOTIMP"
+	case dnsmessage.RCodeFormatError:
+		return "FORMERR"
+	}
+	return rcode.String()
+}
@@ -0,0 +1,175 @@
+package dns
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func ptrRR(t *testing.T, owner, host string) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: mustName(t, owner), Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET},
+		Body:   &dnsmessage.PTRResource{PTR: mustName(t, host)},
+	}
+}
+
+func rcodeResponse(rcode dnsmessage.RCode) *dnsmessage.Message {
+	return &dnsmessage.Message{Header: dnsmessage.Header{Response: true, RCode: rcode}}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	opts := ThrottleOptions{BackoffBase: 100 * time.Millisecond, BackoffMax: time.Second}
+	for i := 0; i < 50; i++ {
+		if d := backoffDelay(1, opts); d < 50*time.Millisecond || d > 100*time.Millisecond {
+			t.Fatalf("first backoff %v outside [50ms, 100ms]", d)
+		}
+		if d := backoffDelay(3, opts); d < 200*time.Millisecond || d > 400*time.Millisecond {
+			t.Fatalf("third backoff %v outside [200ms, 400ms]", d)
+		}
+		if d := backoffDelay(40, opts); d < 500*time.Millisecond || d > time.Second {
+			t.Fatalf("capped backoff %v outside [500ms, 1s]", d)
+		}
+	}
+}
+
+func TestThrottledClientRetriesAndBacksOff(t *testing.T) {
+	original := throttledExchange
+	t.Cleanup(func() { throttledExchange = original })
+
+	throttledExchange = func(ctx context.Context, server, name string, qtype dnsmessage.Type, timeout time.Duration) (*dnsmessage.Message, time.Duration, error) {
+		switch server {
+		case "192.0.2.1:53":
+			return rcodeResponse(dnsmessage.RCodeServerFailure), time.Millisecond, nil
+		case "192.0.2.2:53":
+			return rcodeResponse(dnsmessage.RCodeRefused), time.Millisecond, nil
+		}
+		resp := rcodeResponse(dnsmessage.RCodeSuccess)
+		resp.Answers = []dnsmessage.Resource{ptrRR(t, name, "host.example.net")}
+		return resp, 4 * time.Millisecond, nil
+	}
+
+	client := newThrottledClient([]string{"192.0.2.1", "192.0.2.2", "192.0.2.3:5353"}, time.Second,
+		ThrottleOptions{Retries: 2, BackoffBase: time.Millisecond, BackoffMax: 2 * time.Millisecond})
+
+	names, err := client.LookupAddr(context.Background(), "198.51.100.7")
+	if err != nil || len(names) != 1 || names[0] != "host.example.net." {
+		t.Fatalf("LookupAddr = %v, %v; want the third server's answer", names, err)
+	}
+
+	stats := client.stats()
+	if stats[0].ServFail != 1 || stats[0].Backoffs != 1 || !stats[0].Throttled() {
+		t.Errorf("unexpected SERVFAIL server stats: %+v", stats[0])
+	}
+	if stats[1].Refused != 1 || stats[1].Backoffs != 1 {
+		t.Errorf("unexpected REFUSED server stats: %+v", stats[1])
+	}
+	if stats[2].Server != "192.0.2.3:5353" || stats[2].Answered != 1 || stats[2].MeanMS != 4 || stats[2].Throttled() {
+		t.Errorf("unexpected answering server stats: %+v", stats[2])
+	}
+
+	// Out of retries: the last failure is reported like net.Resolver would
+	client = newThrottledClient([]string{"192.0.2.1"}, time.Second, ThrottleOptions{Retries: 1, BackoffBase: time.Millisecond})
+	_, err = client.LookupAddr(context.Background(), "198.51.100.7")
+	if err == nil || !strings.Contains(err.Error(), "server answered SERVFAIL") {
+		t.Fatalf("expected SERVFAIL error, got %v", err)
+	}
+	if stats := client.stats(); stats[0].Queries != 2 || stats[0].Backoffs != 2 {
+		t.Fatalf("expected a retry with a growing backoff, got %+v", stats[0])
+	}
+}
+
+func TestThrottledClientCaps(t *testing.T) {
+	original := throttledExchange
+	t.Cleanup(func() { throttledExchange = original })
+
+	var inFlight, peak atomic.Int32
+	throttledExchange = func(ctx context.Context, server, name string, qtype dnsmessage.Type, timeout time.Duration) (*dnsmessage.Message, time.Duration, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return rcodeResponse(dnsmessage.RCodeNameError), 5 * time.Millisecond, nil
+	}
+
+	client := newThrottledClient([]string{"192.0.2.1"}, time.Second, ThrottleOptions{Concurrency: 2})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = client.LookupAddr(context.Background(), "198.51.100.7")
+		}()
+	}
+	wg.Wait()
+	if got := peak.Load(); got > 2 {
+		t.Fatalf("%d queries in flight, want at most 2", got)
+	}
+
+	client = newThrottledClient([]string{"192.0.2.1"}, time.Second, ThrottleOptions{QPS: 100})
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, _ = client.LookupAddr(context.Background(), "198.51.100.7")
+	}
+	// Five queries at 100 QPS need at least four 10ms gaps
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("5 queries took %v, faster than 100 QPS allows", elapsed)
+	}
+}
+
+func TestAuditPTRThrottledServers(t *testing.T) {
+	original := throttledExchange
+	t.Cleanup(func() { throttledExchange = original })
+
+	var failed atomic.Bool
+	throttledExchange = func(ctx context.Context, server, name string, qtype dnsmessage.Type, timeout time.Duration) (*dnsmessage.Message, time.Duration, error) {
+		if server == "192.0.2.53:53" && failed.CompareAndSwap(false, true) {
+			return rcodeResponse(dnsmessage.RCodeServerFailure), time.Millisecond, nil
+		}
+		switch {
+		case qtype == dnsmessage.TypePTR && name == "1.100.51.198.in-addr.arpa.":
+			resp := rcodeResponse(dnsmessage.RCodeSuccess)
+			resp.Answers = []dnsmessage.Resource{ptrRR(t, name, "mail.example.net")}
+			return resp, time.Millisecond, nil
+		case qtype == dnsmessage.TypeA && fqdn(name) == "mail.example.net.":
+			resp := rcodeResponse(dnsmessage.RCodeSuccess)
+			resp.Answers = []dnsmessage.Resource{aRR(t, name, "198.51.100.1")}
+			return resp, time.Millisecond, nil
+		case qtype == dnsmessage.TypeAAAA:
+			t.Errorf("IPv4 target should not need an AAAA lookup for %s", name)
+		}
+		return rcodeResponse(dnsmessage.RCodeNameError), time.Millisecond, nil
+	}
+
+	result, err := AuditPTR(context.Background(), "198.51.100.0/30", PTRAuditOptions{
+		Timeout:     time.Second,
+		Concurrency: 2,
+		Servers:     []string{"192.0.2.53", "192.0.2.54"},
+		Throttle:    ThrottleOptions{Retries: 1, BackoffBase: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("AuditPTR returned error: %v", err)
+	}
+
+	summary := result.Summary
+	if summary.WithPTR != 1 || summary.MissingPTR != 3 || summary.Errors != 0 || summary.FCrDNSFailed != 0 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if len(summary.Servers) != 2 || summary.Servers[0].ServFail != 1 || summary.Servers[0].Backoffs != 1 {
+		t.Fatalf("unexpected server stats: %+v", summary.Servers)
+	}
+	queries := summary.Servers[0].Queries + summary.Servers[1].Queries
+	// 4 PTR queries, 1 forward lookup, 1 retry
+	if queries != 6 {
+		t.Fatalf("sent %d queries, want 6", queries)
+	}
+}
@@ -0,0 +1,88 @@
+package dns
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultLowTTL is the TTL under which an answer is flagged as unusually low
+const DefaultLowTTL = 30 * time.Second
+
+// Kinds of TTL warning TTLMonitor reports
+const (
+	WarningLowTTL  = "low-ttl"  // The TTL is under the low threshold
+	WarningTTLDrop = "ttl-drop" // An authoritative TTL fell to half or less of the previous one
+)
+
+// TTLWarning flags TTL behavior that often comes before or during an
+// unplanned failover
+type TTLWarning struct {
+	Type     string `json:"type,omitempty" yaml:"type,omitempty"` // Record type, set by ALL lookups
+	Kind     string `json:"kind" yaml:"kind"`
+	TTL      uint32 `json:"ttl" yaml:"ttl"`
+	Previous uint32 `json:"previous,omitempty" yaml:"previous,omitempty"` // TTL before a drop
+	Message  string `json:"message" yaml:"message"`
+}
+
+// TTLMonitor checks the observations of one name for TTL warnings.
+// Authoritative TTLs are taken as they are. A caching resolver counts TTLs
+// down between queries, so for cached answers the monitor judges the TTL seen
+// when the resolver last refreshed its copy, and only authoritative answers
+// are checked for drops.
+type TTLMonitor struct {
+	Low time.Duration // TTLs under this are flagged (0 = DefaultLowTTL)
+
+	last      *Observation
+	refreshed uint32 // TTL of the cached answer when it was last refreshed
+}
+
+// Check records obs and returns its TTL warnings. Observations without
+// answers carry no TTL and are skipped.
+func (m *TTLMonitor) Check(obs *Observation) []TTLWarning {
+	if obs == nil || obs.Status != LookupStatusOK || len(obs.Answers) == 0 {
+		return nil
+	}
+	low := m.Low
+	if low <= 0 {
+		low = DefaultLowTTL
+	}
+	prev := m.last
+	m.last = obs
+
+	ttl := obs.TTLFloor
+	if !obs.Authoritative {
+		if prev == nil || prev.Authoritative || obs.TTLFloor > countdown(prev, obs.Time) {
+			// A TTL above the countdown means the resolver fetched a fresh copy
+			m.refreshed = obs.TTLFloor
+		}
+		ttl = max(m.refreshed, obs.TTLFloor)
+	}
+
+	var warnings []TTLWarning
+	if time.Duration(ttl)*time.Second < low {
+		warnings = append(warnings, TTLWarning{
+			Kind:    WarningLowTTL,
+			TTL:     ttl,
+			Message: fmt.Sprintf("ttl %ds is under %v", ttl, low),
+		})
+	}
+	if prev != nil && prev.Authoritative && obs.Authoritative && prev.TTLFloor > 0 && obs.TTLFloor <= prev.TTLFloor/2 {
+		warnings = append(warnings, TTLWarning{
+			Kind:     WarningTTLDrop,
+			TTL:      obs.TTLFloor,
+			Previous: prev.TTLFloor,
+			Message:  fmt.Sprintf("ttl dropped from %ds to %ds", prev.TTLFloor, obs.TTLFloor),
+		})
+	}
+	return warnings
+}
+
+// countdown is the TTL a cached copy of prev would have left at t, allowing
+// a second of rounding
+func countdown(prev *Observation, t time.Time) uint32 {
+	elapsed := uint32(max(t.Sub(prev.Time), 0) / time.Second)
+	if elapsed >= prev.TTLFloor {
+		return 1
+	}
+	return prev.TTLFloor - elapsed + 1
+}
@@ -0,0 +1,119 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestTTLMonitor(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	obs := func(seconds int, ttl uint32, authoritative bool) *Observation {
+		return &Observation{
+			Time:          start.Add(time.Duration(seconds) * time.Second),
+			Status:        LookupStatusOK,
+			Answers:       []string{"192.0.2.1"},
+			TTLFloor:      ttl,
+			Authoritative: authoritative,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		observations []*Observation
+		want         [][]string // Warning kinds per observation
+	}{
+		{
+			name:         "steady authoritative ttl",
+			observations: []*Observation{obs(0, 300, true), obs(30, 300, true)},
+			want:         [][]string{nil, nil},
+		},
+		{
+			name:         "authoritative ttl dropped for failover",
+			observations: []*Observation{obs(0, 300, true), obs(30, 200, true), obs(60, 20, true), obs(90, 20, true)},
+			want:         [][]string{nil, nil, {WarningLowTTL, WarningTTLDrop}, {WarningLowTTL}},
+		},
+		{
+			name:         "cached ttl counting down is judged by its refresh",
+			observations: []*Observation{obs(0, 300, false), obs(290, 10, false), obs(300, 300, false)},
+			want:         [][]string{nil, nil, nil},
+		},
+		{
+			name:         "cached copy refreshed with a low ttl",
+			observations: []*Observation{obs(0, 40, false), obs(30, 10, false), obs(45, 20, false)},
+			want:         [][]string{nil, nil, {WarningLowTTL}},
+		},
+		{
+			name:         "no answers carry no ttl",
+			observations: []*Observation{{Status: LookupStatusNXDomain, Answers: []string{}}},
+			want:         [][]string{nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var monitor TTLMonitor
+			for i, o := range tt.observations {
+				var kinds []string
+				for _, warning := range monitor.Check(o) {
+					kinds = append(kinds, warning.Kind)
+				}
+				if !slices.Equal(kinds, tt.want[i]) {
+					t.Fatalf("observation %d: warnings %v, want %v", i, kinds, tt.want[i])
+				}
+			}
+		})
+	}
+
+	monitor := TTLMonitor{Low: 5 * time.Minute}
+	warnings := monitor.Check(obs(0, 120, true))
+	if len(warnings) != 1 || warnings[0].TTL != 120 || warnings[0].Message != "ttl 120s is under 5m0s" {
+		t.Fatalf("unexpected warnings with a custom threshold: %+v", warnings)
+	}
+}
+
+func TestLookupCheckTTL(t *testing.T) {
+	originalResolver, originalExchange := resolverFactory, watchExchange
+	t.Cleanup(func() { resolverFactory, watchExchange = originalResolver, originalExchange })
+
+	resolverFactory = func(opts LookupOptions) dnsResolver {
+		return fakeDNSResolver{
+			lookupIPFunc: func(ctx context.Context, network, host string) ([]net.IP, error) {
+				return []net.IP{net.ParseIP("192.0.2.10")}, nil
+			},
+		}
+	}
+	var queries []dnsmessage.Type
+	watchExchange = func(ctx context.Context, server, name string, qtype dnsmessage.Type, timeout time.Duration) (*dnsmessage.Message, time.Duration, error) {
+		queries = append(queries, qtype)
+		return &dnsmessage.Message{
+			Header:  dnsmessage.Header{Response: true, Authoritative: true},
+			Answers: []dnsmessage.Resource{withTTL(aRR(t, "example.com", "192.0.2.10"), 15)},
+		}, time.Millisecond, nil
+	}
+
+	result, err := Lookup("example.com", LookupOptions{RecordType: RecordTypeA, Server: "192.0.2.53", Timeout: time.Second})
+	if err != nil || len(result.TTLWarnings) != 0 || len(queries) != 0 {
+		t.Fatalf("TTLs should only be queried with CheckTTL: %+v, %v, queries %v", result, err, queries)
+	}
+
+	result, err = Lookup("example.com", LookupOptions{RecordType: RecordTypeA, Server: "192.0.2.53", Timeout: time.Second, CheckTTL: true})
+	if err != nil {
+		t.Fatalf("Lookup returned error: %v", err)
+	}
+	if len(result.TTLWarnings) != 1 || result.TTLWarnings[0].Kind != WarningLowTTL || result.TTLWarnings[0].TTL != 15 {
+		t.Fatalf("unexpected TTL warnings: %+v", result.TTLWarnings)
+	}
+
+	output, err := result.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, `"kind": "low-ttl"`) {
+		t.Fatalf("expected ttl_warnings in JSON output: %s", output)
+	}
+}
@@ -0,0 +1,235 @@
+package dns
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Latency classes, from a cache hit to a resolver struggling to answer
+const (
+	LatencyFast     = "fast"      // Under 20ms, typically a cache hit
+	LatencyNormal   = "normal"    // Under 100ms
+	LatencySlow     = "slow"      // Under 500ms
+	LatencyVerySlow = "very-slow" // 500ms or more
+)
+
+// Kinds of change CompareObservations reports
+const (
+	ChangeAnswers = "answers"
+	ChangeTTL     = "ttl"
+	ChangeLatency = "latency"
+)
+
+// watchExchange sends the recursive query behind each observation
+var watchExchange dnsExchanger = exchangeRecursive
+
+// resolvConfPath is where SystemNameserver looks for the system resolver
+var resolvConfPath = "/etc/resolv.conf"
+
+// watchTypes maps the record types watch supports to their query types
+var watchTypes = map[string]dnsmessage.Type{
+	RecordTypeA:     dnsmessage.TypeA,
+	RecordTypeAAAA:  dnsmessage.TypeAAAA,
+	RecordTypeMX:    dnsmessage.TypeMX,
+	RecordTypeTXT:   dnsmessage.TypeTXT,
+	RecordTypeCNAME: dnsmessage.TypeCNAME,
+	RecordTypeNS:    dnsmessage.TypeNS,
+}
+
+// WatchOptions configures each observation of a watched name
+type WatchOptions struct {
+	RecordType string        // A, AAAA, MX, TXT, CNAME, or NS
+	Server     string        // Resolver to query (empty = SystemNameserver)
+	Timeout    time.Duration // Query timeout
+}
+
+// Observation is one answer to a watched query
+type Observation struct {
+	Time          time.Time
+	Domain        string
+	RecordType    string
+	Server        string
+	Status        string        // LookupStatusOK, LookupStatusNXDomain, or LookupStatusServFail
+	Answers       []string      // Record values of the queried type, sorted
+	TTLFloor      uint32        // Lowest TTL in the answer section, CNAMEs included
+	Authoritative bool          // Answered by the zone's own server, so TTLs do not count down
+	Latency       time.Duration // Query round-trip time
+	LatencyClass  string
+}
+
+// ObservationChange describes how an observation differs from the previous one
+type ObservationChange struct {
+	Kinds   []string // ChangeAnswers, ChangeTTL, ChangeLatency
+	Added   []string // Answers that appeared
+	Removed []string // Answers that disappeared
+}
+
+// Changed reports whether anything changed
+func (c ObservationChange) Changed() bool {
+	return len(c.Kinds) > 0
+}
+
+// Observe sends one recursive query for domain and records the answer set,
+// its TTL floor, and how long the resolver took. NXDOMAIN and SERVFAIL are
+// observations, not errors, so a watch can alert when a name disappears.
+func Observe(ctx context.Context, domain string, opts WatchOptions) (*Observation, error) {
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	if domain == "" {
+		return nil, NewDNSError("watch", domain, ErrEmptyDomain)
+	}
+	if err := CheckWatchType(opts.RecordType); err != nil {
+		return nil, NewDNSError("watch", domain, err)
+	}
+	recordType := strings.ToUpper(opts.RecordType)
+	qtype := watchTypes[recordType]
+
+	server := opts.Server
+	if server == "" {
+		var err error
+		if server, err = SystemNameserver(); err != nil {
+			return nil, NewDNSError("watch", domain, err)
+		}
+	}
+	server = serverAddress(server)
+
+	start := time.Now()
+	resp, rtt, err := watchExchange(ctx, server, domain, qtype, opts.Timeout)
+	if err != nil {
+		return nil, NewDNSError("watch", domain, err)
+	}
+
+	obs := &Observation{
+		Time:          start,
+		Domain:        domain,
+		RecordType:    recordType,
+		Server:        server,
+		Answers:       []string{},
+		Authoritative: resp.Authoritative,
+		Latency:       rtt,
+		LatencyClass:  LatencyClass(rtt),
+	}
+	switch resp.RCode {
+	case dnsmessage.RCodeSuccess:
+		obs.Status = LookupStatusOK
+	case dnsmessage.RCodeNameError:
+		obs.Status = LookupStatusNXDomain
+	case dnsmessage.RCodeServerFailure:
+		obs.Status = LookupStatusServFail
+	default:
+		return nil, NewDNSError("watch", domain, fmt.Errorf("server answered %s", resp.RCode))
+	}
+
+	for i, answer := range resp.Answers {
+		if i == 0 || answer.Header.TTL < obs.TTLFloor {
+			obs.TTLFloor = answer.Header.TTL
+		}
+		if answer.Header.Type == qtype {
+			obs.Answers = append(obs.Answers, answerValue(answer.Body))
+		}
+	}
+	slices.Sort(obs.Answers)
+	obs.Answers = slices.Compact(obs.Answers)
+	return obs, nil
+}
+
+// CheckWatchType rejects record types Observe cannot query, such as ALL
+func CheckWatchType(recordType string) error {
+	if _, ok := watchTypes[strings.ToUpper(recordType)]; !ok {
+		return errcode.Errorf(errcode.CLIUsage, "unsupported record type for watch: %s (use A, AAAA, MX, TXT, CNAME, or NS)", recordType)
+	}
+	return nil
+}
+
+// answerValue formats a record the way Lookup reports it
+func answerValue(body dnsmessage.ResourceBody) string {
+	switch rr := body.(type) {
+	case *dnsmessage.AResource:
+		return net.IP(rr.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		return net.IP(rr.AAAA[:]).String()
+	case *dnsmessage.MXResource:
+		return fmt.Sprintf("%d %s", rr.Pref, trimDot(rr.MX.String()))
+	case *dnsmessage.TXTResource:
+		return strings.Join(rr.TXT, "")
+	case *dnsmessage.CNAMEResource:
+		return trimDot(rr.CNAME.String())
+	case *dnsmessage.NSResource:
+		return trimDot(rr.NS.String())
+	}
+	return body.GoString()
+}
+
+// LatencyClass buckets a query time into one of the Latency classes
+func LatencyClass(d time.Duration) string {
+	switch {
+	case d < 20*time.Millisecond:
+		return LatencyFast
+	case d < 100*time.Millisecond:
+		return LatencyNormal
+	case d < 500*time.Millisecond:
+		return LatencySlow
+	default:
+		return LatencyVerySlow
+	}
+}
+
+// CompareObservations reports how cur differs from prev. TTLs from a caching
+// resolver count down between queries, so the TTL floor is only compared when
+// both answers are authoritative.
+func CompareObservations(prev, cur *Observation) ObservationChange {
+	var change ObservationChange
+	if prev == nil || cur == nil {
+		return change
+	}
+
+	for _, answer := range cur.Answers {
+		if !slices.Contains(prev.Answers, answer) {
+			change.Added = append(change.Added, answer)
+		}
+	}
+	for _, answer := range prev.Answers {
+		if !slices.Contains(cur.Answers, answer) {
+			change.Removed = append(change.Removed, answer)
+		}
+	}
+	if prev.Status != cur.Status || len(change.Added) > 0 || len(change.Removed) > 0 {
+		change.Kinds = append(change.Kinds, ChangeAnswers)
+	}
+	if prev.Authoritative && cur.Authoritative && prev.TTLFloor != cur.TTLFloor {
+		change.Kinds = append(change.Kinds, ChangeTTL)
+	}
+	if prev.LatencyClass != cur.LatencyClass {
+		change.Kinds = append(change.Kinds, ChangeLatency)
+	}
+	return change
+}
+
+// SystemNameserver returns the first nameserver in /etc/resolv.conf
+func SystemNameserver() (string, error) {
+	file, err := os.Open(resolvConfPath)
+	if err != nil {
+		return "", errcode.Wrap(errcode.CLIUsage, fmt.Errorf("no system nameserver found (%w); pass --server", err))
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return fields[1], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errcode.Errorf(errcode.CLIUsage, "no nameserver in %s; pass --server", resolvConfPath)
+}
@@ -0,0 +1,185 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func cnameRR(t *testing.T, owner, target string, ttl uint32) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: mustName(t, owner), Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET, TTL: ttl},
+		Body:   &dnsmessage.CNAMEResource{CNAME: mustName(t, target)},
+	}
+}
+
+func withTTL(rr dnsmessage.Resource, ttl uint32) dnsmessage.Resource {
+	rr.Header.TTL = ttl
+	return rr
+}
+
+func TestObserve(t *testing.T) {
+	original := watchExchange
+	t.Cleanup(func() { watchExchange = original })
+
+	var gotServer string
+	watchExchange = func(ctx context.Context, server, name string, qtype dnsmessage.Type, timeout time.Duration) (*dnsmessage.Message, time.Duration, error) {
+		gotServer = server
+		if name != "www.example.com" || qtype != dnsmessage.TypeA {
+			t.Fatalf("unexpected query %s %v", name, qtype)
+		}
+		return &dnsmessage.Message{
+			Header: dnsmessage.Header{Response: true, Authoritative: true},
+			Answers: []dnsmessage.Resource{
+				cnameRR(t, "www.example.com", "edge.example.net", 300),
+				withTTL(aRR(t, "edge.example.net", "192.0.2.20"), 60),
+				withTTL(aRR(t, "edge.example.net", "192.0.2.10"), 60),
+			},
+		}, 35 * time.Millisecond, nil
+	}
+
+	obs, err := Observe(context.Background(), "www.example.com.", WatchOptions{RecordType: "a", Server: "192.0.2.53", Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("Observe returned error: %v", err)
+	}
+	if gotServer != "192.0.2.53:53" {
+		t.Errorf("queried %q, want 192.0.2.53:53", gotServer)
+	}
+	if !slices.Equal(obs.Answers, []string{"192.0.2.10", "192.0.2.20"}) {
+		t.Errorf("Answers = %v", obs.Answers)
+	}
+	if obs.Status != LookupStatusOK || obs.TTLFloor != 60 || !obs.Authoritative || obs.RecordType != "A" {
+		t.Errorf("unexpected observation: %+v", obs)
+	}
+	if obs.LatencyClass != LatencyNormal {
+		t.Errorf("LatencyClass = %q, want %q", obs.LatencyClass, LatencyNormal)
+	}
+}
+
+func TestObserveNXDomainAndErrors(t *testing.T) {
+	original := watchExchange
+	t.Cleanup(func() { watchExchange = original })
+
+	watchExchange = func(ctx context.Context, server, name string, qtype dnsmessage.Type, timeout time.Duration) (*dnsmessage.Message, time.Duration, error) {
+		if name == "gone.example.com" {
+			return &dnsmessage.Message{Header: dnsmessage.Header{Response: true, RCode: dnsmessage.RCodeNameError}}, time.Millisecond, nil
+		}
+		return nil, 0, context.DeadlineExceeded
+	}
+
+	obs, err := Observe(context.Background(), "gone.example.com", WatchOptions{RecordType: "A", Server: "192.0.2.53"})
+	if err != nil {
+		t.Fatalf("NXDOMAIN should be an observation, got %v", err)
+	}
+	if obs.Status != LookupStatusNXDomain || len(obs.Answers) != 0 {
+		t.Errorf("unexpected observation: %+v", obs)
+	}
+
+	if _, err := Observe(context.Background(), "slow.example.com", WatchOptions{RecordType: "A", Server: "192.0.2.53"}); errcode.Of(err) != errcode.DNSTimeout {
+		t.Errorf("expected DNS005, got %v", err)
+	}
+	if _, err := Observe(context.Background(), "example.com", WatchOptions{RecordType: "ALL", Server: "192.0.2.53"}); errcode.Of(err) != errcode.CLIUsage {
+		t.Errorf("expected CLI002 for ALL, got %v", err)
+	}
+}
+
+func TestCompareObservations(t *testing.T) {
+	base := &Observation{Status: LookupStatusOK, Answers: []string{"192.0.2.1", "192.0.2.2"}, TTLFloor: 300, LatencyClass: LatencyFast}
+
+	tests := []struct {
+		name    string
+		prev    *Observation
+		cur     Observation
+		kinds   []string
+		added   []string
+		removed []string
+	}{
+		{name: "first observation", cur: *base},
+		{name: "unchanged", prev: base, cur: *base},
+		{
+			name:    "answer replaced",
+			prev:    base,
+			cur:     Observation{Status: LookupStatusOK, Answers: []string{"192.0.2.1", "192.0.2.3"}, TTLFloor: 300, LatencyClass: LatencyFast},
+			kinds:   []string{ChangeAnswers},
+			added:   []string{"192.0.2.3"},
+			removed: []string{"192.0.2.2"},
+		},
+		{
+			name:    "name disappeared",
+			prev:    base,
+			cur:     Observation{Status: LookupStatusNXDomain, Answers: []string{}, LatencyClass: LatencyFast},
+			kinds:   []string{ChangeAnswers},
+			removed: []string{"192.0.2.1", "192.0.2.2"},
+		},
+		{
+			name: "cached ttl counting down is not a change",
+			prev: base,
+			cur:  Observation{Status: LookupStatusOK, Answers: base.Answers, TTLFloor: 270, LatencyClass: LatencyFast},
+		},
+		{
+			name:  "authoritative ttl lowered and slower",
+			prev:  &Observation{Status: LookupStatusOK, Answers: base.Answers, TTLFloor: 300, Authoritative: true, LatencyClass: LatencyFast},
+			cur:   Observation{Status: LookupStatusOK, Answers: base.Answers, TTLFloor: 60, Authoritative: true, LatencyClass: LatencySlow},
+			kinds: []string{ChangeTTL, ChangeLatency},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := CompareObservations(tt.prev, &tt.cur)
+			if !slices.Equal(change.Kinds, tt.kinds) || !slices.Equal(change.Added, tt.added) || !slices.Equal(change.Removed, tt.removed) {
+				t.Fatalf("CompareObservations = %+v, want kinds %v added %v removed %v", change, tt.kinds, tt.added, tt.removed)
+			}
+			if change.Changed() != (len(tt.kinds) > 0) {
+				t.Errorf("Changed() = %v", change.Changed())
+			}
+		})
+	}
+}
+
+func TestLatencyClass(t *testing.T) {
+	tests := map[time.Duration]string{
+		time.Millisecond:        LatencyFast,
+		20 * time.Millisecond:   LatencyNormal,
+		99 * time.Millisecond:   LatencyNormal,
+		250 * time.Millisecond:  LatencySlow,
+		500 * time.Millisecond:  LatencyVerySlow,
+		3000 * time.Millisecond: LatencyVerySlow,
+	}
+	for d, want := range tests {
+		if got := LatencyClass(d); got != want {
+			t.Errorf("LatencyClass(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestSystemNameserver(t *testing.T) {
+	original := resolvConfPath
+	t.Cleanup(func() { resolvConfPath = original })
+
+	resolvConfPath = filepath.Join(t.TempDir(), "resolv.conf")
+	if err := os.WriteFile(resolvConfPath, []byte("# generated\nsearch corp.example\nnameserver 10.0.0.53\nnameserver 10.0.1.53\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := SystemNameserver(); err != nil || got != "10.0.0.53" {
+		t.Fatalf("SystemNameserver() = %q, %v", got, err)
+	}
+
+	if err := os.WriteFile(resolvConfPath, []byte("search corp.example\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SystemNameserver(); errcode.Of(err) != errcode.CLIUsage {
+		t.Fatalf("expected CLI002 without a nameserver line, got %v", err)
+	}
+
+	resolvConfPath = filepath.Join(t.TempDir(), "missing")
+	if _, err := SystemNameserver(); errcode.Of(err) != errcode.CLIUsage || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected CLI002 wrapping ErrNotExist, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package dns
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WireResponse is a DNS response exactly as it arrived from a server, kept for
+// analysing resolver quirks (case randomization, EDNS handling, padding) after
+// the fact. Responses that failed to parse are kept too.
+type WireResponse struct {
+	Server    string        // Address the query was sent to
+	Name      string        // Query name
+	Type      string        // Query type, e.g. TypeNS
+	Transport string        // udp or tcp
+	RTT       time.Duration // Time from sending the query to reading the response
+	Raw       []byte        // Response message without the TCP length prefix
+}
+
+// wireResponseOutput is the serialization-friendly version of WireResponse
+type wireResponseOutput struct {
+	Server    string `json:"server" yaml:"server"`
+	Name      string `json:"name" yaml:"name"`
+	Type      string `json:"type" yaml:"type"`
+	Transport string `json:"transport" yaml:"transport"`
+	RTTMS     int64  `json:"rtt_ms" yaml:"rtt_ms"`
+	Size      int    `json:"size" yaml:"size"`
+	Raw       string `json:"raw_base64" yaml:"raw_base64"`
+}
+
+func wireResponsesOutput(responses []WireResponse) []wireResponseOutput {
+	if len(responses) == 0 {
+		return nil
+	}
+	output := make([]wireResponseOutput, 0, len(responses))
+	for _, resp := range responses {
+		output = append(output, wireResponseOutput{
+			Server:    resp.Server,
+			Name:      resp.Name,
+			Type:      resp.Type,
+			Transport: resp.Transport,
+			RTTMS:     resp.RTT.Milliseconds(),
+			Size:      len(resp.Raw),
+			Raw:       base64.StdEncoding.EncodeToString(resp.Raw),
+		})
+	}
+	return output
+}
+
+// wireRecorder collects raw responses seen by exchangeDNS for one operation
+type wireRecorder struct {
+	mu        sync.Mutex
+	responses []WireResponse
+}
+
+type wireRecorderKey struct{}
+
+// withWireRecorder returns a context under which exchangeDNS records every raw response
+func withWireRecorder(ctx context.Context, recorder *wireRecorder) context.Context {
+	return context.WithValue(ctx, wireRecorderKey{}, recorder)
+}
+
+// recordWire stores resp if ctx carries a recorder
+func recordWire(ctx context.Context, resp WireResponse) {
+	recorder, ok := ctx.Value(wireRecorderKey{}).(*wireRecorder)
+	if !ok {
+		return
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.responses = append(recorder.responses, resp)
+}
+
+// Responses returns a copy of the recorded responses in arrival order
+func (r *wireRecorder) Responses() []WireResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]WireResponse(nil), r.responses...)
+}
+
+// WriteWireResponses saves each response to its own .bin file in dir, creating
+// dir if needed, and returns the paths written. Files are numbered in the order
+// the responses arrived.
+func WriteWireResponses(dir string, responses []WireResponse) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create wire dump directory: %w", err)
+	}
+
+	paths := make([]string, 0, len(responses))
+	for i, resp := range responses {
+		name := fmt.Sprintf("%03d-%s-%s-%s-%s.bin", i+1,
+			wireFileComponent(trimDot(resp.Name)),
+			wireFileComponent(strings.TrimPrefix(resp.Type, "Type")),
+			wireFileComponent(resp.Server),
+			resp.Transport)
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, resp.Raw, 0o644); err != nil {
+			return paths, fmt.Errorf("failed to write wire dump: %w", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// wireFileComponent replaces characters that are awkward in file names
+func wireFileComponent(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
@@ -0,0 +1,145 @@
+// Package enrich annotates addresses with their reverse DNS name and the
+// origin AS announcing them, so a path of bare IPs reads as a path through
+// networks.
+package enrich
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+)
+
+// Team Cymru's IP to ASN mapping service, queried over DNS TXT records
+const (
+	originZoneV4 = "origin.asn.cymru.com."
+	originZoneV6 = "origin6.asn.cymru.com."
+	asnZone      = "asn.cymru.com."
+)
+
+// Resolver is the part of net.Resolver the enricher uses
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Info is what is known about one address. Fields are empty when the lookup
+// failed or the address is not globally routed.
+type Info struct {
+	Name   string `json:"name,omitempty"`   // First reverse DNS name, without the trailing dot
+	ASN    uint32 `json:"asn,omitempty"`    // Origin AS of the announced prefix
+	Prefix string `json:"prefix,omitempty"` // Announced prefix covering the address
+	Holder string `json:"holder,omitempty"` // AS holder, e.g. "Cogent Communications"
+}
+
+// Enricher looks up Info, caching AS holders across addresses
+type Enricher struct {
+	Resolver Resolver // nil uses net.DefaultResolver
+
+	mu      sync.Mutex
+	holders map[uint32]string
+}
+
+// Lookup returns what it can find about addr. Enrichment is best effort: a
+// failed or timed out query leaves its fields empty rather than failing.
+func (e *Enricher) Lookup(ctx context.Context, addr netip.Addr) Info {
+	var info Info
+	addr = addr.Unmap()
+	if !addr.IsValid() {
+		return info
+	}
+
+	if names, err := e.resolver().LookupAddr(ctx, addr.String()); err == nil && len(names) > 0 {
+		info.Name = strings.TrimSuffix(names[0], ".")
+	}
+
+	if classified, err := cidr.Classify(addr.String()); err != nil || !classified.Global {
+		// Private, CGN, documentation, and other special-purpose space has no origin AS
+		return info
+	}
+	info.ASN, info.Prefix = e.origin(ctx, addr)
+	if info.ASN != 0 {
+		info.Holder = e.holder(ctx, info.ASN)
+	}
+	return info
+}
+
+// origin reads "ASN | prefix | CC | registry | date" from the origin zone.
+// Prefixes announced by several ASes list them space separated; the first wins.
+func (e *Enricher) origin(ctx context.Context, addr netip.Addr) (uint32, string) {
+	zone := originZoneV4
+	arpa := ".in-addr.arpa."
+	if addr.Is6() {
+		zone, arpa = originZoneV6, ".ip6.arpa."
+	}
+	name := strings.TrimSuffix(cidr.ReverseName(addr), arpa) + "." + zone
+
+	records, err := e.resolver().LookupTXT(ctx, name)
+	if err != nil || len(records) == 0 {
+		return 0, ""
+	}
+	fields := strings.Split(records[0], "|")
+	asns := strings.Fields(fields[0])
+	if len(asns) == 0 {
+		return 0, ""
+	}
+	asn, err := strconv.ParseUint(asns[0], 10, 32)
+	if err != nil {
+		return 0, ""
+	}
+	var prefix string
+	if len(fields) > 1 {
+		prefix = strings.TrimSpace(fields[1])
+	}
+	return uint32(asn), prefix
+}
+
+// holder reads "ASN | CC | registry | date | HANDLE - Holder name, CC" and
+// keeps the holder name
+func (e *Enricher) holder(ctx context.Context, asn uint32) string {
+	e.mu.Lock()
+	holder, ok := e.holders[asn]
+	e.mu.Unlock()
+	if ok {
+		return holder
+	}
+
+	records, err := e.resolver().LookupTXT(ctx, "AS"+strconv.FormatUint(uint64(asn), 10)+"."+asnZone)
+	if err != nil || len(records) == 0 {
+		return ""
+	}
+	fields := strings.Split(records[0], "|")
+	holder = HolderName(strings.TrimSpace(fields[len(fields)-1]))
+
+	e.mu.Lock()
+	if e.holders == nil {
+		e.holders = make(map[uint32]string)
+	}
+	e.holders[asn] = holder
+	e.mu.Unlock()
+	return holder
+}
+
+// HolderName shortens a registry holder such as "COGENT-174 - Cogent
+// Communications, US" to "Cogent Communications"
+func HolderName(raw string) string {
+	name := raw
+	if _, rest, ok := strings.Cut(raw, " - "); ok {
+		name = rest
+	}
+	if i := strings.LastIndex(name, ", "); i >= 0 && len(name)-i-2 == 2 {
+		name = name[:i]
+	}
+	return strings.TrimSpace(name)
+}
+
+func (e *Enricher) resolver() Resolver {
+	if e.Resolver == nil {
+		return net.DefaultResolver
+	}
+	return e.Resolver
+}
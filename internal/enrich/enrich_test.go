@@ -0,0 +1,92 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+type fakeResolver struct {
+	names   map[string][]string
+	txt     map[string][]string
+	queries int
+}
+
+func (f *fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	if names, ok := f.names[addr]; ok {
+		return names, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func (f *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	f.queries++
+	if records, ok := f.txt[name]; ok {
+		return records, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func TestLookup(t *testing.T) {
+	resolver := &fakeResolver{
+		names: map[string][]string{
+			"154.54.1.1": {"be2001.ccr41.lon13.atlas.cogentco.com."},
+			"10.0.0.1":   {"gw.lan."},
+		},
+		txt: map[string][]string{
+			"1.1.54.154.origin.asn.cymru.com.": {"174 | 154.54.0.0/16 | US | arin | 2005-02-15"},
+			"2.1.54.154.origin.asn.cymru.com.": {"174 | 154.54.0.0/16 | US | arin | 2005-02-15"},
+			"AS174.asn.cymru.com.":             {"174 | US | arin | 2002-09-06 | COGENT-174 - Cogent Communications, US"},
+			"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.3.3.3.1.0.0.2.origin6.asn.cymru.com.": {"3356 4436 | 2001:3338::/32 | US | arin | 2009-06-10"},
+			"AS3356.asn.cymru.com.": {"3356 | US | arin | 2000-03-10 | LEVEL3 - Level 3 Parent, LLC, US"},
+		},
+	}
+	enricher := &Enricher{Resolver: resolver}
+
+	info := enricher.Lookup(context.Background(), netip.MustParseAddr("154.54.1.1"))
+	want := Info{Name: "be2001.ccr41.lon13.atlas.cogentco.com", ASN: 174, Prefix: "154.54.0.0/16", Holder: "Cogent Communications"}
+	if info != want {
+		t.Fatalf("Lookup(154.54.1.1) = %+v, want %+v", info, want)
+	}
+
+	// The holder is cached, so a second address in the same AS costs one query
+	before := resolver.queries
+	if info := enricher.Lookup(context.Background(), netip.MustParseAddr("::ffff:154.54.1.2")); info.Holder != "Cogent Communications" || info.Name != "" {
+		t.Fatalf("Lookup(mapped) = %+v", info)
+	}
+	if resolver.queries-before != 1 {
+		t.Errorf("expected the holder to be cached, sent %d queries", resolver.queries-before)
+	}
+
+	info = enricher.Lookup(context.Background(), netip.MustParseAddr("2001:3338::1"))
+	if info.ASN != 3356 || info.Holder != "Level 3 Parent, LLC" || info.Prefix != "2001:3338::/32" {
+		t.Fatalf("Lookup(2001:3338::1) = %+v", info)
+	}
+
+	// Private space is named but never sent to the origin service
+	before = resolver.queries
+	if info := enricher.Lookup(context.Background(), netip.MustParseAddr("10.0.0.1")); info != (Info{Name: "gw.lan"}) {
+		t.Fatalf("Lookup(10.0.0.1) = %+v", info)
+	}
+	if resolver.queries != before {
+		t.Errorf("queried the origin service for a private address")
+	}
+
+	if info := enricher.Lookup(context.Background(), netip.MustParseAddr("8.8.8.8")); info != (Info{}) {
+		t.Fatalf("failed lookups should leave Info empty, got %+v", info)
+	}
+}
+
+func TestHolderName(t *testing.T) {
+	for raw, want := range map[string]string{
+		"COGENT-174 - Cogent Communications, US": "Cogent Communications",
+		"LEVEL3 - Level 3 Parent, LLC, US":       "Level 3 Parent, LLC",
+		"GOOGLE, US":                             "GOOGLE",
+		"Example Networks":                       "Example Networks",
+	} {
+		if got := HolderName(raw); got != want {
+			t.Errorf("HolderName(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
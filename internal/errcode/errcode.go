@@ -0,0 +1,158 @@
+// Package errcode assigns stable identifiers to user-facing errors so that
+// automation and documentation do not depend on message wording.
+package errcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Code is a stable identifier for a class of user-facing error. Codes are never
+// reused or renumbered; retired codes stay reserved.
+type Code string
+
+// Unknown is reported for errors that have not been classified
+const Unknown Code = "ERR000"
+
+// Command-line usage
+const (
+	CLIUnknownCommand     Code = "CLI001" // Unknown command or subcommand
+	CLIUsage              Code = "CLI002" // Invalid flag value or arguments
+	CLIUnsupportedFormat  Code = "CLI003" // Unsupported --format value
+	CLIDryRunUnsupported  Code = "CLI004" // --dry-run given to a command that cannot plan its traffic
+	CLIAuditLog           Code = "CLI005" // Audit log entry could not be written or read
+	CLIStore              Code = "CLI006" // Persistent state could not be read or written
+	CLITargetDiscovery    Code = "CLI007" // Service discovery could not list targets
+	CLISelfUpdate         Code = "CLI008" // Self-update could not fetch, verify, or install a release
+	CLIPolicy             Code = "CLI009" // Command, rate, or target forbidden by the configured policy
+	CLIDeprecated         Code = "CLI010" // Deprecated flag used; a warning, the command still runs
+	CLIDocumentationRange Code = "CLI011" // Target in a documentation or benchmarking range without --allow-doc-ranges
+	CLIOffline            Code = "CLI012" // Command or lookup needs the network while --offline is set
+	CLIAlerts             Code = "CLI013" // Alerts section of the config file is invalid
+)
+
+// CIDR calculations
+const (
+	CIDRInvalid          Code = "CIDR001" // Invalid CIDR notation or prefix length
+	CIDRInvalidIP        Code = "CIDR002" // Invalid IP address
+	CIDRTooLarge         Code = "CIDR003" // Range too large for the requested operation
+	CIDRInvalidParts     Code = "CIDR004" // Invalid number of parts for divide
+	CIDRInsufficientBits Code = "CIDR005" // Not enough host bits to divide the range
+	CIDRInvalidExpr      Code = "CIDR006" // Malformed set expression or unknown named set
+	CIDRExhausted        Code = "CIDR007" // Not enough free space for the requested subnets or samples
+)
+
+// DNS queries
+const (
+	DNSEmptyDomain Code = "DNS001" // Domain argument is empty
+	DNSEmptyIP     Code = "DNS002" // IP argument is empty
+	DNSInvalidIP   Code = "DNS003" // IP argument is not an address
+	DNSNXDomain    Code = "DNS004" // Domain does not exist
+	DNSTimeout     Code = "DNS005" // Query timed out
+	DNSQueryFailed Code = "DNS006" // Query failed for another reason
+)
+
+// Path MTU discovery
+const (
+	MTUUnsupportedProtocol Code = "MTU001" // Unknown --proto value, or a mode the protocol cannot run
+	MTURawICMPDisabled     Code = "MTU002" // ICMP mode requested from a minimal build
+	MTUPermissionDenied    Code = "MTU003" // Raw sockets need root or CAP_NET_RAW
+	MTUResolveFailed       Code = "MTU004" // Destination could not be resolved to a usable address
+	MTUNoWorkingSize       Code = "MTU010" // No probe size got through; path or service not answering
+	MTUTimeout             Code = "MTU011" // Discovery exceeded its time budget
+	MTUICMPFiltered        Code = "MTU014" // No ICMP responses at any size; ICMP is likely filtered
+	MTUPMTUDropped         Code = "MTU020" // Watch observed the path MTU decrease
+	MTUPeerFailed          Code = "MTU030" // Peer endpoint could not start or serve
+)
+
+// All lists every assigned code with a one-line description, in code order
+var All = []struct {
+	Code        Code
+	Description string
+}{
+	{Unknown, "Unclassified error"},
+	{CLIUnknownCommand, "Unknown command or subcommand"},
+	{CLIUsage, "Invalid flag value or arguments"},
+	{CLIUnsupportedFormat, "Unsupported --format value"},
+	{CLIDryRunUnsupported, "--dry-run given to a command that cannot plan its traffic"},
+	{CLIAuditLog, "Audit log entry could not be written or read"},
+	{CLIStore, "Persistent state could not be read or written"},
+	{CLITargetDiscovery, "Service discovery could not list targets"},
+	{CLISelfUpdate, "Self-update could not fetch, verify, or install a release"},
+	{CLIPolicy, "Command, rate, or target forbidden by the configured policy"},
+	{CLIDeprecated, "Deprecated flag used; a warning, the command still runs"},
+	{CLIDocumentationRange, "Target in a documentation or benchmarking range without --allow-doc-ranges"},
+	{CLIOffline, "Command or lookup needs the network while --offline is set"},
+	{CLIAlerts, "Alerts section of the config file is invalid"},
+	{CIDRInvalid, "Invalid CIDR notation or prefix length"},
+	{CIDRInvalidIP, "Invalid IP address"},
+	{CIDRTooLarge, "Range too large for the requested operation"},
+	{CIDRInvalidParts, "Invalid number of parts for divide"},
+	{CIDRInsufficientBits, "Not enough host bits to divide the range"},
+	{CIDRInvalidExpr, "Malformed set expression or unknown named set"},
+	{CIDRExhausted, "Not enough free space for the requested subnets or samples"},
+	{DNSEmptyDomain, "Domain argument is empty"},
+	{DNSEmptyIP, "IP argument is empty"},
+	{DNSInvalidIP, "IP argument is not an address"},
+	{DNSNXDomain, "Domain does not exist"},
+	{DNSTimeout, "Query timed out"},
+	{DNSQueryFailed, "Query failed for another reason"},
+	{MTUUnsupportedProtocol, "Unknown --proto value, or a mode the protocol cannot run"},
+	{MTURawICMPDisabled, "ICMP mode requested from a minimal build"},
+	{MTUPermissionDenied, "Raw sockets need root or CAP_NET_RAW"},
+	{MTUResolveFailed, "Destination could not be resolved to a usable address"},
+	{MTUNoWorkingSize, "No probe size got through; path or service not answering"},
+	{MTUTimeout, "Discovery exceeded its time budget"},
+	{MTUICMPFiltered, "No ICMP responses at any size; ICMP is likely filtered"},
+	{MTUPMTUDropped, "Watch observed the path MTU decrease"},
+	{MTUPeerFailed, "Peer endpoint could not start or serve"},
+}
+
+// Error attaches a Code to an underlying error without changing its message
+type Error struct {
+	Code Code
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Coder is implemented by domain error types that know their own code
+type Coder interface {
+	ErrorCode() Code
+}
+
+// Wrap attaches code to err. It returns nil when err is nil.
+func Wrap(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// Errorf formats an error and attaches code to it
+func Errorf(code Code, format string, args ...any) error {
+	return &Error{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// Of returns the code of the outermost classified error in err's chain, or
+// Unknown when nothing in the chain carries one.
+func Of(err error) Code {
+	for err != nil {
+		switch e := err.(type) {
+		case *Error:
+			return e.Code
+		case Coder:
+			if code := e.ErrorCode(); code != "" {
+				return code
+			}
+		}
+		err = errors.Unwrap(err)
+	}
+	return Unknown
+}
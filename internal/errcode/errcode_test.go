@@ -0,0 +1,73 @@
+package errcode
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+type coded struct{}
+
+func (coded) Error() string   { return "coded" }
+func (coded) ErrorCode() Code { return DNSTimeout }
+
+func TestOf(t *testing.T) {
+	base := errors.New("boom")
+
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{"nil", nil, Unknown},
+		{"plain", base, Unknown},
+		{"wrapped", Wrap(CIDRInvalid, base), CIDRInvalid},
+		{"wrapped twice keeps outermost", Wrap(CLIUsage, Wrap(CIDRInvalid, base)), CLIUsage},
+		{"through fmt", fmt.Errorf("context: %w", Wrap(MTUTimeout, base)), MTUTimeout},
+		{"coder", fmt.Errorf("context: %w", coded{}), DNSTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Of(tt.err); got != tt.want {
+				t.Fatalf("Of() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapPreservesMessageAndChain(t *testing.T) {
+	base := errors.New("boom")
+	err := Wrap(CIDRInvalid, base)
+
+	if err.Error() != "boom" {
+		t.Fatalf("Wrap changed the message: %q", err.Error())
+	}
+	if !errors.Is(err, base) {
+		t.Fatal("Wrap broke errors.Is")
+	}
+	if Wrap(CIDRInvalid, nil) != nil {
+		t.Fatal("Wrap(nil) should be nil")
+	}
+}
+
+func TestAllCodesUniqueAndDocumented(t *testing.T) {
+	doc, err := os.ReadFile("../../docs/ERROR_CODES.md")
+	if err != nil {
+		t.Fatalf("read error code reference: %v", err)
+	}
+
+	seen := map[Code]bool{}
+	for _, entry := range All {
+		if seen[entry.Code] {
+			t.Errorf("code %s assigned twice", entry.Code)
+		}
+		seen[entry.Code] = true
+
+		if !strings.Contains(string(doc), "`"+string(entry.Code)+"`") {
+			t.Errorf("code %s missing from docs/ERROR_CODES.md", entry.Code)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Package export writes monitoring sessions as time series, one row per
+// sample, in CSV or Parquet, so long sessions can be loaded straight into
+// pandas, Polars, or DuckDB without converting JSON lines first. Rows reach
+// the file as the session runs rather than when it ends.
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// Export formats accepted by Parse
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// ColumnType is the type of every value in a column
+type ColumnType int
+
+// Column types. Timestamps are UTC with millisecond precision.
+const (
+	String ColumnType = iota
+	Int64
+	Float64
+	Bool
+	Timestamp
+)
+
+// Column is one named, typed column of an export
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Writer appends rows to an export file
+type Writer interface {
+	// Write appends one row with a value per column: a string, int or int64,
+	// float64, bool, or time.Time to match the column type, or nil when the
+	// value is missing
+	Write(row ...any) error
+	// Close flushes any buffered rows and finishes the file
+	Close() error
+}
+
+// Parse reads an --export value, FORMAT:PATH such as parquet:session.parquet.
+// A bare path with a .csv or .parquet extension picks the format from it.
+func Parse(spec string) (format, path string, err error) {
+	format, path, ok := strings.Cut(spec, ":")
+	if !ok || (format != FormatCSV && format != FormatParquet) {
+		format, path = strings.TrimPrefix(filepath.Ext(spec), "."), spec
+	}
+	if format != FormatCSV && format != FormatParquet {
+		return "", "", errcode.Errorf(errcode.CLIUsage, "invalid export %q: expected csv:<PATH> or parquet:<PATH>", spec)
+	}
+	if path == "" {
+		return "", "", errcode.Errorf(errcode.CLIUsage, "invalid export %q: missing path", spec)
+	}
+	return format, path, nil
+}
+
+// Create parses spec as for Parse and creates the file, replacing any
+// existing one
+func Create(spec string, columns []Column) (Writer, error) {
+	format, path, err := Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("export: %w", err)
+	}
+
+	if format == FormatParquet {
+		return newParquetWriter(file, columns)
+	}
+	return newCSVWriter(file, columns)
+}
+
+// csvWriter writes a header row, then flushes every row as it is written so
+// the file can be followed with tail -f
+type csvWriter struct {
+	file    *os.File
+	csv     *csv.Writer
+	columns []Column
+}
+
+func newCSVWriter(file *os.File, columns []Column) (*csvWriter, error) {
+	w := &csvWriter{file: file, csv: csv.NewWriter(file), columns: columns}
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Name
+	}
+	if err := w.writeRecord(header); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *csvWriter) Write(row ...any) error {
+	if err := checkRow(w.columns, row); err != nil {
+		return err
+	}
+	record := make([]string, len(row))
+	for i, value := range row {
+		record[i] = formatCSV(value)
+	}
+	return w.writeRecord(record)
+}
+
+func (w *csvWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	return nil
+}
+
+func (w *csvWriter) writeRecord(record []string) error {
+	_ = w.csv.Write(record)
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	return nil
+}
+
+// formatCSV writes missing values as empty fields and timestamps in RFC 3339
+// UTC, which pandas parses without a format string
+func formatCSV(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format("2006-01-02T15:04:05.000Z07:00")
+	}
+	return fmt.Sprint(value)
+}
+
+// checkRow reports a row whose shape does not match the columns, which is a
+// programming error rather than bad input
+func checkRow(columns []Column, row []any) error {
+	if len(row) != len(columns) {
+		return fmt.Errorf("export: row has %d values for %d columns", len(row), len(columns))
+	}
+	for i, value := range row {
+		if value == nil {
+			continue
+		}
+		ok := false
+		switch value.(type) {
+		case string:
+			ok = columns[i].Type == String
+		case int, int64:
+			ok = columns[i].Type == Int64
+		case float64:
+			ok = columns[i].Type == Float64
+		case bool:
+			ok = columns[i].Type == Bool
+		case time.Time:
+			ok = columns[i].Type == Timestamp
+		}
+		if !ok {
+			return fmt.Errorf("export: value %v (%T) does not fit column %s", value, value, columns[i].Name)
+		}
+	}
+	return nil
+}
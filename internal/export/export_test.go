@@ -0,0 +1,213 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+var testColumns = []Column{
+	{Name: "time", Type: Timestamp},
+	{Name: "target", Type: String},
+	{Name: "pmtu", Type: Int64},
+	{Name: "rtt_ms", Type: Float64},
+	{Name: "ok", Type: Bool},
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec, format, path string
+	}{
+		{"parquet:session.parquet", FormatParquet, "session.parquet"},
+		{"csv:/tmp/out.txt", FormatCSV, "/tmp/out.txt"},
+		{"session.csv", FormatCSV, "session.csv"},
+		{"logs/run:1.parquet", FormatParquet, "logs/run:1.parquet"},
+	}
+	for _, tt := range tests {
+		format, path, err := Parse(tt.spec)
+		if err != nil || format != tt.format || path != tt.path {
+			t.Errorf("Parse(%q) = %q, %q, %v", tt.spec, format, path, err)
+		}
+	}
+
+	for _, spec := range []string{"session.json", "jsonl:out.jsonl", "csv:", ""} {
+		if _, _, err := Parse(spec); errcode.Of(err) != errcode.CLIUsage {
+			t.Errorf("Parse(%q) = %v, want CLI002", spec, err)
+		}
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.csv")
+	w, err := Create(path, testColumns)
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	at := time.Date(2026, 10, 18, 12, 0, 0, 250e6, time.FixedZone("CEST", 2*3600))
+	if err := w.Write(at, "example.com", 1500, 12.5, true); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+
+	// Rows are on disk before Close
+	data, _ := os.ReadFile(path)
+	want := "time,target,pmtu,rtt_ms,ok\n2026-10-18T10:00:00.250Z,example.com,1500,12.5,true\n"
+	if string(data) != want {
+		t.Fatalf("file before Close = %q, want %q", data, want)
+	}
+
+	if err := w.Write(at, "a,b", nil, nil, false); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if !strings.HasSuffix(string(data), "\"a,b\",,,false\n") {
+		t.Fatalf("missing values should be empty fields, got %q", data)
+	}
+
+	if err := w.Write("too", "few"); err == nil {
+		t.Fatal("expected an error for a short row")
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.parquet")
+	w, err := Create("parquet:"+path, testColumns)
+	if err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if rows := parquetRows(t, path); rows != 0 {
+		t.Fatalf("new file has %d rows", rows)
+	}
+
+	at := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		var pmtu any = int64(1500 - i)
+		if i == 2 {
+			pmtu = nil
+		}
+		if err := w.Write(at.Add(time.Duration(i)*time.Second), "example.com", pmtu, float64(i), i%2 == 0); err != nil {
+			t.Fatalf("Write returned error: %v", err)
+		}
+	}
+	if err := w.(*parquetWriter).flush(); err != nil {
+		t.Fatalf("flush returned error: %v", err)
+	}
+	// The footer is rewritten after each row group, so the file is readable mid-session
+	if rows := parquetRows(t, path); rows != 5 {
+		t.Fatalf("file after one row group has %d rows, want 5", rows)
+	}
+
+	if err := w.Write(at, "example.net", nil, nil, nil); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if rows := parquetRows(t, path); rows != 6 {
+		t.Fatalf("closed file has %d rows, want 6", rows)
+	}
+	if err := w.Write(at, "example.com", "1500", 0.0, true); err == nil {
+		t.Fatal("expected an error for a string in an int column")
+	}
+}
+
+func TestEncodeLevels(t *testing.T) {
+	got := encodeLevels([]bool{true, true, true, false, true})
+	want := []byte{3 << 1, 1, 1 << 1, 0, 1 << 1, 1}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("encodeLevels = %v, want %v", got, want)
+	}
+	if got := packBits([]bool{true, false, true, true, false, false, false, false, true}); !bytes.Equal(got, []byte{0b1101, 1}) {
+		t.Fatalf("packBits = %08b", got)
+	}
+}
+
+// parquetRows checks the magic bytes around a Parquet file and returns
+// num_rows from its footer
+func parquetRows(t *testing.T, path string) int64 {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, parquetMagic) || !bytes.HasSuffix(data, parquetMagic) {
+		t.Fatalf("missing PAR1 magic in %q", data)
+	}
+	length := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	meta := data[len(data)-8-length : len(data)-8]
+
+	// FileMetaData opens with version (field 1) and the schema list (field 2),
+	// then num_rows (field 3); skip to it
+	r := thriftReader{data: meta}
+	for {
+		header := r.byte()
+		if header>>4 == 1 && header&0x0f == thriftI64 {
+			return r.zigzag()
+		}
+		if header == 0 {
+			t.Fatal("num_rows not found in footer")
+		}
+		r.skip(header & 0x0f)
+	}
+}
+
+// thriftReader decodes just enough of the compact protocol for parquetRows
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) byte() byte {
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) skip(typ byte) {
+	switch typ {
+	case thriftTrue, thriftFalse:
+	case thriftI32, thriftI64:
+		r.uvarint()
+	case thriftBinary:
+		r.pos += int(r.uvarint())
+	case thriftList:
+		header := r.byte()
+		n := int(header >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		for range n {
+			r.skip(header & 0x0f)
+		}
+	case thriftStruct:
+		for {
+			header := r.byte()
+			if header == 0 {
+				return
+			}
+			if header>>4 == 0 {
+				r.zigzag()
+			}
+			r.skip(header & 0x0f)
+		}
+	}
+}
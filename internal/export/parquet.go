@@ -0,0 +1,300 @@
+package export
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+// Parquet enum values from parquet.thrift
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+
+	repetitionOptional = 1
+	encodingPlain      = 0
+	encodingRLE        = 3
+	codecUncompressed  = 0
+	pageTypeData       = 0
+)
+
+// Buffered rows are written out as a row group once there are this many, or
+// once the oldest has waited this long, whichever comes first
+const (
+	parquetRowGroupRows = 10000
+	parquetFlushEvery   = time.Minute
+)
+
+var parquetMagic = []byte("PAR1")
+
+// parquetWriter writes a Parquet file of nullable columns, plain-encoded and
+// uncompressed, one data page per column per row group. After every row
+// group it rewrites the footer, so the file is readable mid-session with at
+// most parquetFlushEvery of rows missing.
+type parquetWriter struct {
+	file      *os.File
+	columns   []Column
+	rows      [][]any
+	groups    []parquetRowGroup
+	numRows   int64
+	dataEnd   int64 // Where the footer starts
+	lastFlush time.Time
+}
+
+type parquetRowGroup struct {
+	chunks []parquetChunk
+	rows   int64
+	size   int64
+}
+
+type parquetChunk struct {
+	offset int64
+	size   int64
+	values int64
+}
+
+func newParquetWriter(file *os.File, columns []Column) (*parquetWriter, error) {
+	w := &parquetWriter{file: file, columns: columns, dataEnd: int64(len(parquetMagic)), lastFlush: time.Now()}
+	if _, err := file.Write(parquetMagic); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("export: %w", err)
+	}
+	if err := w.writeFooter(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *parquetWriter) Write(row ...any) error {
+	if err := checkRow(w.columns, row); err != nil {
+		return err
+	}
+	w.rows = append(w.rows, row)
+	if len(w.rows) >= parquetRowGroupRows || time.Since(w.lastFlush) >= parquetFlushEvery {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *parquetWriter) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.flush()
+	if closeErr := w.file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("export: %w", closeErr)
+	}
+	w.file = nil
+	return err
+}
+
+// flush writes the buffered rows as a row group over the old footer, then
+// writes a new footer after it
+func (w *parquetWriter) flush() error {
+	w.lastFlush = time.Now()
+	if len(w.rows) == 0 {
+		return nil
+	}
+
+	var data []byte
+	group := parquetRowGroup{rows: int64(len(w.rows))}
+	for i, column := range w.columns {
+		chunk := w.columnChunk(i, column)
+		group.chunks = append(group.chunks, parquetChunk{offset: w.dataEnd + int64(len(data)), size: int64(len(chunk)), values: group.rows})
+		group.size += int64(len(chunk))
+		data = append(data, chunk...)
+	}
+	if _, err := w.file.WriteAt(data, w.dataEnd); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	w.dataEnd += int64(len(data))
+	w.groups = append(w.groups, group)
+	w.numRows += group.rows
+	w.rows = w.rows[:0]
+	return w.writeFooter()
+}
+
+// columnChunk encodes column i of the buffered rows as one data page:
+// definition levels marking the missing values, then the present values
+func (w *parquetWriter) columnChunk(i int, column Column) []byte {
+	levels := make([]bool, len(w.rows))
+	var values []byte
+	var bools []bool
+	for r, row := range w.rows {
+		if row[i] == nil {
+			continue
+		}
+		levels[r] = true
+		switch v := row[i].(type) {
+		case string:
+			values = binary.LittleEndian.AppendUint32(values, uint32(len(v)))
+			values = append(values, v...)
+		case int:
+			values = binary.LittleEndian.AppendUint64(values, uint64(v))
+		case int64:
+			values = binary.LittleEndian.AppendUint64(values, uint64(v))
+		case float64:
+			values = binary.LittleEndian.AppendUint64(values, math.Float64bits(v))
+		case bool:
+			bools = append(bools, v)
+		case time.Time:
+			values = binary.LittleEndian.AppendUint64(values, uint64(v.UnixMilli()))
+		}
+	}
+	if column.Type == Bool {
+		values = packBits(bools)
+	}
+
+	encodedLevels := encodeLevels(levels)
+	page := binary.LittleEndian.AppendUint32(nil, uint32(len(encodedLevels)))
+	page = append(page, encodedLevels...)
+	page = append(page, values...)
+
+	header := newThriftWriter()
+	header.I32(1, pageTypeData)
+	header.I32(2, int32(len(page)))
+	header.I32(3, int32(len(page)))
+	header.Struct(5, func() {
+		header.I32(1, int32(len(w.rows)))
+		header.I32(2, encodingPlain)
+		header.I32(3, encodingRLE)
+		header.I32(4, encodingRLE)
+	})
+	header.End()
+	return append(header.Bytes(), page...)
+}
+
+// writeFooter writes the file metadata, its length, and the closing magic at
+// the end of the data
+func (w *parquetWriter) writeFooter() error {
+	meta := w.fileMetaData()
+	footer := binary.LittleEndian.AppendUint32(meta, uint32(len(meta)))
+	footer = append(footer, parquetMagic...)
+	if _, err := w.file.WriteAt(footer, w.dataEnd); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if err := w.file.Truncate(w.dataEnd + int64(len(footer))); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	return nil
+}
+
+func (w *parquetWriter) fileMetaData() []byte {
+	t := newThriftWriter()
+	t.I32(1, 1)
+	t.List(2, thriftStruct, len(w.columns)+1, func(i int) {
+		t.ElemStruct(func() {
+			if i == 0 {
+				t.String(4, "schema")
+				t.I32(5, int32(len(w.columns)))
+				return
+			}
+			w.schemaElement(t, w.columns[i-1])
+		})
+	})
+	t.I64(3, w.numRows)
+	t.List(4, thriftStruct, len(w.groups), func(g int) {
+		group := w.groups[g]
+		t.ElemStruct(func() {
+			t.List(1, thriftStruct, len(group.chunks), func(c int) {
+				chunk := group.chunks[c]
+				t.ElemStruct(func() {
+					t.I64(2, chunk.offset)
+					t.Struct(3, func() {
+						t.I32(1, physicalType(w.columns[c].Type))
+						t.List(2, thriftI32, 2, func(e int) { t.ElemI32([]int32{encodingPlain, encodingRLE}[e]) })
+						t.List(3, thriftBinary, 1, func(int) { t.ElemString(w.columns[c].Name) })
+						t.I32(4, codecUncompressed)
+						t.I64(5, chunk.values)
+						t.I64(6, chunk.size)
+						t.I64(7, chunk.size)
+						t.I64(9, chunk.offset)
+					})
+				})
+			})
+			t.I64(2, group.size)
+			t.I64(3, group.rows)
+		})
+	})
+	t.String(6, "cidrator")
+	t.End()
+	return t.Bytes()
+}
+
+// schemaElement describes one column, with both the legacy converted type
+// and the logical type so old and new readers agree on strings and times
+func (w *parquetWriter) schemaElement(t *thriftWriter, column Column) {
+	t.I32(1, physicalType(column.Type))
+	t.I32(3, repetitionOptional)
+	t.String(4, column.Name)
+	switch column.Type {
+	case String:
+		t.I32(6, convertedUTF8)
+		t.Struct(10, func() {
+			t.Struct(1, func() {}) // STRING
+		})
+	case Timestamp:
+		t.I32(6, convertedTimestampMillis)
+		t.Struct(10, func() {
+			t.Struct(8, func() { // TIMESTAMP
+				t.Bool(1, true) // isAdjustedToUTC
+				t.Struct(2, func() {
+					t.Struct(1, func() {}) // MILLIS
+				})
+			})
+		})
+	}
+}
+
+func physicalType(typ ColumnType) int32 {
+	switch typ {
+	case String:
+		return parquetByteArray
+	case Float64:
+		return parquetDouble
+	case Bool:
+		return parquetBoolean
+	}
+	return parquetInt64
+}
+
+// encodeLevels encodes definition levels of bit width 1 as runs of the
+// RLE/bit-packing hybrid: a varint run length shifted left one, then the
+// level in one byte
+func encodeLevels(levels []bool) []byte {
+	var out []byte
+	for start := 0; start < len(levels); {
+		end := start
+		for end < len(levels) && levels[end] == levels[start] {
+			end++
+		}
+		out = binary.AppendUvarint(out, uint64(end-start)<<1)
+		if levels[start] {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		start = end
+	}
+	return out
+}
+
+// packBits packs booleans one bit each, least significant bit first
+func packBits(bools []bool) []byte {
+	out := make([]byte, (len(bools)+7)/8)
+	for i, b := range bools {
+		if b {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
@@ -0,0 +1,126 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Thrift compact protocol type IDs, as used in field and list headers
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the Thrift compact protocol, which Parquet uses for
+// its page headers and footer. Only what the Parquet writer needs is here.
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16 // Last field ID of each open struct, innermost last
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{last: []int16{0}}
+}
+
+func (w *thriftWriter) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+// field writes a field header, as a delta from the previous field when it fits
+func (w *thriftWriter) field(id int16, typ byte) {
+	last := &w.last[len(w.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) I32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.varint(zigzag(int64(v)))
+}
+
+func (w *thriftWriter) I64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.varint(zigzag(v))
+}
+
+func (w *thriftWriter) Bool(id int16, v bool) {
+	if v {
+		w.field(id, thriftTrue)
+	} else {
+		w.field(id, thriftFalse)
+	}
+}
+
+func (w *thriftWriter) String(id int16, v string) {
+	w.field(id, thriftBinary)
+	w.str(v)
+}
+
+// Struct writes a struct field whose fields body writes
+func (w *thriftWriter) Struct(id int16, body func()) {
+	w.field(id, thriftStruct)
+	w.structBody(body)
+}
+
+// List writes a list field of n elements of typ; elem writes element i
+func (w *thriftWriter) List(id int16, typ byte, n int, elem func(i int)) {
+	w.field(id, thriftList)
+	if n < 15 {
+		w.buf.WriteByte(byte(n)<<4 | typ)
+	} else {
+		w.buf.WriteByte(0xf0 | typ)
+		w.varint(uint64(n))
+	}
+	for i := range n {
+		elem(i)
+	}
+}
+
+// The element writers below are for use inside List
+
+func (w *thriftWriter) ElemI32(v int32) {
+	w.varint(zigzag(int64(v)))
+}
+
+func (w *thriftWriter) ElemString(v string) {
+	w.str(v)
+}
+
+func (w *thriftWriter) ElemStruct(body func()) {
+	w.structBody(body)
+}
+
+// End finishes the top-level struct
+func (w *thriftWriter) End() {
+	w.buf.WriteByte(0)
+}
+
+func (w *thriftWriter) structBody(body func()) {
+	w.last = append(w.last, 0)
+	body()
+	w.buf.WriteByte(0)
+	w.last = w.last[:len(w.last)-1]
+}
+
+func (w *thriftWriter) str(v string) {
+	w.varint(uint64(len(v)))
+	w.buf.WriteString(v)
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	w.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
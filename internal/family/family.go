@@ -0,0 +1,126 @@
+// Package family decides which IP version to use when a target has both, and
+// which loopback address to bind. The default follows the host, so a machine
+// without IPv4 connectivity uses IPv6 without being told.
+package family
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// Preferences accepted by Configure
+const (
+	Auto = "auto" // IPv4, unless the host has an IPv6 route and no IPv4 one
+	IPv4 = "4"
+	IPv6 = "6"
+)
+
+// preference is the configured preference
+var preference = Auto
+
+// Probes of the routing table: connecting a UDP socket picks a route and
+// source address without sending anything. The documentation addresses are
+// only ever reached through a default route.
+const (
+	probeIPv4 = "192.0.2.1:9"
+	probeIPv6 = "[2001:db8::1]:9"
+)
+
+// hasRoute reports whether the host can send to addr over network; tests
+// replace it
+var hasRoute = func(network, addr string) bool {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// canBind reports whether the host can listen on addr over network; tests
+// replace it
+var canBind = func(network, addr string) bool {
+	conn, err := net.ListenPacket(network, addr)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// What auto and Loopback found, cached since it does not change within a run
+var (
+	detectOnce   sync.Once
+	detectedIPv6 bool
+	loopbackOnce sync.Once
+	loopback     string
+)
+
+// Configure sets the preference: auto, 4, or 6. Empty means auto.
+func Configure(p string) error {
+	switch p = strings.ToLower(strings.TrimSpace(p)); p {
+	case "", Auto:
+		preference = Auto
+	case IPv4, "ipv4":
+		preference = IPv4
+	case IPv6, "ipv6":
+		preference = IPv6
+	default:
+		return errcode.Errorf(errcode.CLIUsage, "invalid prefer-family %q: expected auto, 4, or 6", p)
+	}
+	return nil
+}
+
+// Preference returns the configured preference
+func Preference() string {
+	return preference
+}
+
+// PreferIPv6 reports whether IPv6 is preferred. Under auto that is when the
+// host can reach IPv6 destinations but not IPv4 ones.
+func PreferIPv6() bool {
+	switch preference {
+	case IPv4:
+		return false
+	case IPv6:
+		return true
+	}
+	detectOnce.Do(func() {
+		detectedIPv6 = !hasRoute("udp4", probeIPv4) && hasRoute("udp6", probeIPv6)
+	})
+	return detectedIPv6
+}
+
+// Pick returns the first address of the preferred version, or the first
+// address when there is none. addrs must not be empty.
+func Pick(addrs []netip.Addr) netip.Addr {
+	ipv6 := PreferIPv6()
+	for _, addr := range addrs {
+		if addr.Unmap().Is6() == ipv6 {
+			return addr.Unmap()
+		}
+	}
+	return addrs[0].Unmap()
+}
+
+// Loopback returns the loopback address to bind local listeners to:
+// 127.0.0.1, or ::1 on a host without IPv4 loopback
+func Loopback() string {
+	loopbackOnce.Do(func() {
+		loopback = "127.0.0.1"
+		if !canBind("udp4", "127.0.0.1:0") && canBind("udp6", "[::1]:0") {
+			loopback = "::1"
+		}
+	})
+	return loopback
+}
+
+// reset forgets what auto detected; tests use it after replacing the probes
+func reset() {
+	detectOnce = sync.Once{}
+	loopbackOnce = sync.Once{}
+}
@@ -0,0 +1,89 @@
+package family
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// stubHost makes the host look like it has the given IPv4 and IPv6
+// connectivity, with or without IPv4 loopback
+func stubHost(t *testing.T, ipv4, ipv6, loopback4 bool) {
+	t.Helper()
+	originalRoute, originalBind, originalPreference := hasRoute, canBind, preference
+	t.Cleanup(func() {
+		hasRoute, canBind, preference = originalRoute, originalBind, originalPreference
+		reset()
+	})
+	hasRoute = func(network, _ string) bool {
+		if network == "udp4" {
+			return ipv4
+		}
+		return ipv6
+	}
+	canBind = func(network, _ string) bool {
+		return network == "udp6" || loopback4
+	}
+	reset()
+}
+
+func TestPreferIPv6(t *testing.T) {
+	tests := []struct {
+		name       string
+		preference string
+		ipv4, ipv6 bool
+		want       bool
+	}{
+		{"auto dual stack", "", true, true, false},
+		{"auto IPv6-only", "auto", false, true, true},
+		{"auto IPv4-only", "auto", true, false, false},
+		{"auto offline", "auto", false, false, false},
+		{"forced 6 on dual stack", "6", true, true, true},
+		{"forced 4 on IPv6-only", "ipv4", false, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubHost(t, tt.ipv4, tt.ipv6, true)
+			if err := Configure(tt.preference); err != nil {
+				t.Fatalf("Configure(%q) returned error: %v", tt.preference, err)
+			}
+			if got := PreferIPv6(); got != tt.want {
+				t.Errorf("PreferIPv6() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if err := Configure("5"); errcode.Of(err) != errcode.CLIUsage {
+		t.Errorf("Configure(\"5\") = %v, want CLI001", err)
+	}
+}
+
+func TestPick(t *testing.T) {
+	addrs := []netip.Addr{netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("::ffff:192.0.2.1")}
+
+	stubHost(t, true, true, true)
+	if got := Pick(addrs); got != netip.MustParseAddr("192.0.2.1") {
+		t.Errorf("dual stack Pick = %s, want the unmapped IPv4 address", got)
+	}
+
+	stubHost(t, false, true, true)
+	if got := Pick(addrs); got != netip.MustParseAddr("2001:db8::1") {
+		t.Errorf("IPv6-only Pick = %s, want 2001:db8::1", got)
+	}
+	if got := Pick(addrs[1:]); got != netip.MustParseAddr("192.0.2.1") {
+		t.Errorf("IPv6-only Pick with no IPv6 address = %s, want 192.0.2.1", got)
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	stubHost(t, true, true, true)
+	if got := Loopback(); got != "127.0.0.1" {
+		t.Errorf("Loopback() = %s, want 127.0.0.1", got)
+	}
+
+	stubHost(t, false, true, false)
+	if got := Loopback(); got != "::1" {
+		t.Errorf("IPv6-only Loopback() = %s, want ::1", got)
+	}
+}
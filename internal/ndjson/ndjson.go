@@ -0,0 +1,123 @@
+// Package ndjson reads the JSON records cidrator commands print, so the output
+// of one command can supply the arguments of another without jq in between.
+package ndjson
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// DefaultFields are tried in order when no field is given. They are the names
+// cidrator's JSON output uses for what each record is about.
+var DefaultFields = []string{"target", "cidr", "ip", "domain", "address", "addr", "host"}
+
+// Values reads JSON records from r and yields the value of field in each.
+// Records are usually one per line, but any sequence of JSON values works,
+// and a top-level array is read as one record per element.
+//
+// field is a comma-separated list of names tried in order; the first one a
+// record has is used. A dotted name such as hops.addr reaches into nested
+// objects, and an array along the way yields a value for every element.
+// Strings and numbers are yielded as they are, and nulls are skipped. An
+// empty field tries DefaultFields.
+func Values(r io.Reader, field string) iter.Seq2[string, error] {
+	names := DefaultFields
+	if field != "" {
+		names = strings.Split(field, ",")
+	}
+	paths := make([][]string, len(names))
+	for i, name := range names {
+		paths[i] = strings.Split(strings.TrimSpace(name), ".")
+	}
+
+	return func(yield func(string, error) bool) {
+		decoder := json.NewDecoder(r)
+		decoder.UseNumber()
+		record := 0 // Records read so far; messages number them from 1
+		for {
+			var value any
+			if err := decoder.Decode(&value); errors.Is(err, io.EOF) {
+				return
+			} else if err != nil {
+				yield("", errcode.Errorf(errcode.CLIUsage, "record %d is not valid JSON: %w", record+1, err))
+				return
+			}
+
+			records := []any{value}
+			if list, ok := value.([]any); ok {
+				records = list
+			}
+			for _, r := range records {
+				record++
+				values, ok := lookupAny(r, paths)
+				if !ok {
+					yield("", errcode.Errorf(errcode.CLIUsage, "record %d has no %s", record, describeFields(names)))
+					return
+				}
+				for _, v := range values {
+					if !yield(v, nil) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+func describeFields(names []string) string {
+	if len(names) == 1 {
+		return "field " + names[0]
+	}
+	return "field among " + strings.Join(names, ", ")
+}
+
+// lookupAny returns the values at the first path record has
+func lookupAny(record any, paths [][]string) ([]string, bool) {
+	for _, path := range paths {
+		if values, ok := lookup(record, path); ok {
+			return values, true
+		}
+	}
+	return nil, false
+}
+
+// lookup returns the scalar values at path in v, fanning out over arrays
+func lookup(v any, path []string) ([]string, bool) {
+	switch v := v.(type) {
+	case []any:
+		var values []string
+		found := false
+		for _, element := range v {
+			if more, ok := lookup(element, path); ok {
+				values, found = append(values, more...), true
+			}
+		}
+		return values, found
+	case map[string]any:
+		if len(path) == 0 {
+			return nil, false
+		}
+		child, ok := v[path[0]]
+		if !ok {
+			return nil, false
+		}
+		return lookup(child, path[1:])
+	}
+	if len(path) > 0 {
+		return nil, false
+	}
+	switch v := v.(type) {
+	case nil:
+		return nil, true
+	case string:
+		return []string{v}, true
+	case json.Number:
+		return []string{v.String()}, true
+	}
+	return nil, false
+}
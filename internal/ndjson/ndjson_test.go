@@ -0,0 +1,62 @@
+package ndjson
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+func collect(input, field string) ([]string, error) {
+	var values []string
+	for value, err := range Values(strings.NewReader(input), field) {
+		if err != nil {
+			return values, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func TestValues(t *testing.T) {
+	tests := []struct {
+		name, input, field string
+		want               []string
+	}{
+		{"default fields", `{"ip":"10.0.0.1","index":0}` + "\n" + `{"cidr":"10.0.0.0/24"}` + "\n\n" + `{"target":"example.com","ip":"x"}`, "", []string{"10.0.0.1", "10.0.0.0/24", "example.com"}},
+		{"named field", `{"addr":"192.0.2.1","pmtu":1500}`, "pmtu", []string{"1500"}},
+		{"fallback names", `{"ip":"192.0.2.1"}` + "\n" + `{"host":"a.example"}`, "host, ip", []string{"192.0.2.1", "a.example"}},
+		{"nested and fanned out", `{"hops":[{"addr":"192.0.2.1"},{"addr":null},{"addr":"192.0.2.3"}]}`, "hops.addr", []string{"192.0.2.1", "192.0.2.3"}},
+		{"top-level array", `[{"ip":"192.0.2.1"},{"ip":"192.0.2.2"}]`, "ip", []string{"192.0.2.1", "192.0.2.2"}},
+		{"pretty-printed records", "{\n  \"ip\": \"192.0.2.1\"\n}\n{\n  \"ip\": \"192.0.2.2\"\n}\n", "", []string{"192.0.2.1", "192.0.2.2"}},
+	}
+	for _, tt := range tests {
+		got, err := collect(tt.input, tt.field)
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Values = %v, %v; want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestValuesErrors(t *testing.T) {
+	got, err := collect(`{"ip":"192.0.2.1"}`+"\n"+`{"pmtu":1500}`, "ip")
+	if errcode.Of(err) != errcode.CLIUsage || !strings.Contains(err.Error(), "record 2 has no field ip") || !slices.Equal(got, []string{"192.0.2.1"}) {
+		t.Errorf("missing field: got %v, %v", got, err)
+	}
+	// Both messages number records from 1, counting array elements
+	_, err = collect(`[{"ip":"192.0.2.1"},{"ip":"192.0.2.2"}] not json`, "")
+	if errcode.Of(err) != errcode.CLIUsage || !strings.HasPrefix(err.Error(), "record 3 is not valid JSON: ") {
+		t.Errorf("invalid JSON: got %v", err)
+	}
+	if _, err := collect("not json", ""); err == nil || !strings.HasPrefix(err.Error(), "record 1 is not valid JSON: ") {
+		t.Errorf("invalid first record: got %v", err)
+	}
+	if _, err := collect(`[{"ip":"192.0.2.1"},{"cidr":"10.0.0.0/8"}]`, "ip,host"); err == nil || err.Error() != "record 2 has no field among ip, host" {
+		t.Errorf("missing field in an array: got %v", err)
+	}
+	// An object is not a scalar argument
+	if _, err := collect(`{"ip":{"v4":"192.0.2.1"}}`, "ip"); err == nil {
+		t.Error("expected an error for an object-valued field")
+	}
+}
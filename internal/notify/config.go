@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// Notifier types of the alerts config
+const (
+	TypeStdout  = "stdout"
+	TypeStderr  = "stderr"
+	TypeSyslog  = "syslog"
+	TypeWebhook = "webhook"
+	TypeSlack   = "slack"
+	TypeEmail   = "email"
+	TypeExec    = "exec"
+)
+
+// Config is the alerts section of the config file: destinations every watch
+// command notifies besides those its flags name. The zero value adds none.
+type Config struct {
+	// Template renders text alerts; empty uses DefaultTemplate
+	Template  string           `mapstructure:"template"`
+	RateLimit RateLimit        `mapstructure:"rate_limit"`
+	Notifiers []NotifierConfig `mapstructure:"notifiers"`
+
+	// Source names where the config came from, for error messages
+	Source string `mapstructure:"-"`
+}
+
+// RateLimit caps the alerts for each watched target, across every notifier
+type RateLimit struct {
+	Interval time.Duration `mapstructure:"interval"` // 0 means no limit
+	Burst    int           `mapstructure:"burst"`    // Alerts per Interval; 0 means 1
+}
+
+// NotifierConfig is one alert destination. Type picks which of the other
+// fields apply.
+type NotifierConfig struct {
+	Type     string `mapstructure:"type"`
+	Template string `mapstructure:"template"` // Overrides Config.Template for this notifier
+
+	URL string `mapstructure:"url"` // webhook, slack
+
+	SMTP     string   `mapstructure:"smtp"` // email: server as host:port
+	Username string   `mapstructure:"username"`
+	Password string   `mapstructure:"password"`
+	From     string   `mapstructure:"from"`
+	To       []string `mapstructure:"to"`
+
+	Command []string      `mapstructure:"command"` // exec: program and argument templates
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// current is the alerts config in force
+var current Config
+
+// Configure validates c and puts it in force
+func Configure(c Config) error {
+	if _, err := ParseTemplate(c.Template); err != nil {
+		return errcode.Errorf(errcode.CLIAlerts, "alerts %s: template: %w", c.Source, err)
+	}
+	if c.RateLimit.Interval < 0 || c.RateLimit.Burst < 0 {
+		return errcode.Errorf(errcode.CLIAlerts, "alerts %s: rate_limit interval and burst must not be negative", c.Source)
+	}
+	for i, nc := range c.Notifiers {
+		if err := nc.validate(); err != nil {
+			return errcode.Errorf(errcode.CLIAlerts, "alerts %s: notifier %d (%s): %w", c.Source, i+1, nc.Type, err)
+		}
+	}
+	current = c
+	return nil
+}
+
+// Current returns the alerts config in force
+func Current() Config {
+	return current
+}
+
+// validate checks nc without connecting anywhere
+func (nc NotifierConfig) validate() error {
+	if nc.Type == TypeSyslog {
+		_, err := ParseTemplate(nc.Template)
+		return err
+	}
+	_, _, err := nc.open(nil)
+	return err
+}
+
+// open builds the notifier nc describes. text is the config-wide template,
+// used unless nc has its own. The closer is nil for notifiers that hold
+// nothing open.
+func (nc NotifierConfig) open(text *Template) (Notifier, func() error, error) {
+	if nc.Template != "" {
+		var err error
+		if text, err = ParseTemplate(nc.Template); err != nil {
+			return nil, nil, fmt.Errorf("template: %w", err)
+		}
+	}
+
+	switch nc.Type {
+	case TypeStdout:
+		return &Stream{W: os.Stdout, Template: text}, nil, nil
+	case TypeStderr:
+		return &Stream{W: os.Stderr, Template: text}, nil, nil
+	case TypeSyslog:
+		logger, err := NewSyslog("cidrator")
+		if err != nil {
+			return nil, nil, err
+		}
+		logger.Template = text
+		return logger, logger.Close, nil
+	case TypeWebhook:
+		webhook, err := NewWebhook(nc.URL)
+		return webhook, nil, err
+	case TypeSlack:
+		slack, err := NewSlack(nc.URL, text)
+		return slack, nil, err
+	case TypeEmail:
+		email, err := NewEmail(nc.SMTP, nc.Username, nc.Password, nc.From, nc.To, text)
+		return email, nil, err
+	case TypeExec:
+		script, err := NewExec(nc.Command, nc.Timeout)
+		return script, nil, err
+	case "":
+		return nil, nil, errors.New("type is required")
+	}
+	return nil, nil, fmt.Errorf("unknown type %q: use stdout, stderr, syslog, webhook, slack, email, or exec", nc.Type)
+}
@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+func TestConfigureValidates(t *testing.T) {
+	defer func() { current = Config{} }()
+
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{"empty", Config{}, ""},
+		{"every type", Config{Notifiers: []NotifierConfig{
+			{Type: TypeStdout},
+			{Type: TypeStderr, Template: "{{.Target}}"},
+			{Type: TypeSyslog},
+			{Type: TypeWebhook, URL: "https://hooks.example.com/alert"},
+			{Type: TypeSlack, URL: "https://hooks.slack.com/services/T/B/X"},
+			{Type: TypeEmail, SMTP: "smtp.example.com:587", From: "cidrator@example.com", To: []string{"ops@example.com"}},
+			{Type: TypeExec, Command: []string{"/bin/true", "{{.Summary}}"}},
+		}}, ""},
+		{"bad template", Config{Template: "{{.Target"}, "template"},
+		{"negative rate limit", Config{RateLimit: RateLimit{Interval: -time.Second}}, "must not be negative"},
+		{"missing type", Config{Notifiers: []NotifierConfig{{URL: "https://example.com"}}}, "type is required"},
+		{"unknown type", Config{Notifiers: []NotifierConfig{{Type: "pager"}}}, `unknown type "pager"`},
+		{"bad webhook", Config{Notifiers: []NotifierConfig{{Type: TypeWebhook, URL: "ftp://example.com"}}}, "invalid webhook URL"},
+		{"slack without url", Config{Notifiers: []NotifierConfig{{Type: TypeSlack}}}, "invalid webhook URL"},
+		{"email without recipients", Config{Notifiers: []NotifierConfig{{Type: TypeEmail, SMTP: "smtp.example.com:25", From: "a@example.com"}}}, "no recipients"},
+		{"email without port", Config{Notifiers: []NotifierConfig{{Type: TypeEmail, SMTP: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}}}}, "host:port"},
+		{"exec without command", Config{Notifiers: []NotifierConfig{{Type: TypeExec}}}, "command is empty"},
+		{"bad notifier template", Config{Notifiers: []NotifierConfig{{Type: TypeSyslog, Template: "{{"}}}, "notifier 1 (syslog)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Configure(tt.config)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Configure returned error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Configure = %v, want an error containing %q", err, tt.wantErr)
+			}
+			if errcode.Of(err) != errcode.CLIAlerts {
+				t.Fatalf("Configure error code = %s, want CLI013", errcode.Of(err))
+			}
+		})
+	}
+}
+
+func TestOpenAddsConfiguredNotifiers(t *testing.T) {
+	defer func() { current = Config{} }()
+
+	var flagHits, configHits atomic.Int32
+	flagServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { flagHits.Add(1) }))
+	defer flagServer.Close()
+	configServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { configHits.Add(1) }))
+	defer configServer.Close()
+
+	if err := Configure(Config{
+		RateLimit: RateLimit{Interval: time.Minute},
+		Notifiers: []NotifierConfig{{Type: TypeWebhook, URL: configServer.URL}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	notifiers, closeNotifiers, err := Open(flagServer.URL, false)
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer closeNotifiers()
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := range 3 {
+		event := Event{Time: start.Add(time.Duration(i) * time.Second), Source: "mtu watch", Target: "vpn.example.com"}
+		if err := notifiers.Notify(context.Background(), event); err != nil {
+			t.Fatalf("Notify returned error: %v", err)
+		}
+	}
+	// The rate limit covers the flag's webhook as well as the config's
+	if flagHits.Load() != 1 || configHits.Load() != 1 {
+		t.Fatalf("webhooks received %d and %d alerts, want one each", flagHits.Load(), configHits.Load())
+	}
+}
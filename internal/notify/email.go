@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// sendMail delivers a message over SMTP; tests replace it
+var sendMail = smtp.SendMail
+
+// Email sends each event as a plain-text mail: the rendered template as the
+// subject and first line, followed by the event's JSON. The server is asked
+// for STARTTLS whenever it offers it.
+type Email struct {
+	Addr     string // SMTP server as host:port
+	Auth     smtp.Auth
+	From     string
+	To       []string
+	Template *Template // Subject; nil uses DefaultTemplate
+}
+
+// NewEmail validates an SMTP destination. Username and password, when
+// given, are sent with PLAIN authentication, which net/smtp only allows over
+// TLS or to localhost.
+func NewEmail(addr, username, password, from string, to []string, subject *Template) (*Email, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return nil, fmt.Errorf("invalid SMTP server %q: expected host:port", addr)
+	}
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("invalid from address %q: %w", from, err)
+	}
+	if len(to) == 0 {
+		return nil, errors.New("no recipients")
+	}
+	for _, recipient := range to {
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", recipient, err)
+		}
+	}
+
+	e := &Email{Addr: addr, From: from, To: to, Template: subject}
+	if username != "" {
+		e.Auth = smtp.PlainAuth("", username, password, host)
+	}
+	return e, nil
+}
+
+// Notify sends the mail. The SMTP exchange cannot be canceled once started.
+func (e *Email) Notify(ctx context.Context, event Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	message, err := e.message(event)
+	if err != nil {
+		return err
+	}
+	if err := sendMail(e.Addr, e.Auth, e.From, e.To, message); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	return nil
+}
+
+// message builds the RFC 5322 message for event
+func (e *Email) message(event Event) ([]byte, error) {
+	subject, err := e.Template.Render(event)
+	if err != nil {
+		return nil, err
+	}
+	// A header ends at the first line break
+	subject = strings.Join(strings.Fields(subject), " ")
+	details, err := json.MarshalIndent(event, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(subject + "\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(string(details), "\n", "\r\n") + "\r\n")
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"context"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmailSendsEvent(t *testing.T) {
+	orig := sendMail
+	defer func() { sendMail = orig }()
+
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMessage string
+	var gotAuth smtp.Auth
+	sendMail = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotAuth, gotFrom, gotTo, gotMessage = addr, auth, from, to, string(msg)
+		return nil
+	}
+
+	subject, err := ParseTemplate("[cidrator] {{.Target}}: {{.Summary}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	email, err := NewEmail("smtp.example.com:587", "alerts", "secret", "cidrator@example.com", []string{"ops@example.com", "noc@example.com"}, subject)
+	if err != nil {
+		t.Fatalf("NewEmail returned error: %v", err)
+	}
+	event := Event{Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Source: "mtu watch", Target: "vpn.example.com", Summary: "PMTU dropped from 1500 to 1400"}
+	if err := email.Notify(context.Background(), event); err != nil {
+		t.Fatalf("Notify returned error: %v", err)
+	}
+
+	if gotAddr != "smtp.example.com:587" || gotFrom != "cidrator@example.com" || len(gotTo) != 2 || gotAuth == nil {
+		t.Fatalf("sent to %s from %s to %v (auth %v)", gotAddr, gotFrom, gotTo, gotAuth)
+	}
+	for _, want := range []string{
+		"Subject: [cidrator] vpn.example.com: PMTU dropped from 1500 to 1400\r\n",
+		"To: ops@example.com, noc@example.com\r\n",
+		"\r\n\r\n[cidrator] vpn.example.com: PMTU dropped from 1500 to 1400\r\n",
+		`"target": "vpn.example.com"`,
+	} {
+		if !strings.Contains(gotMessage, want) {
+			t.Errorf("message missing %q:\n%s", want, gotMessage)
+		}
+	}
+}
+
+func TestEmailSubjectIsOneEncodedLine(t *testing.T) {
+	subject, err := ParseTemplate("{{.Summary}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	email, err := NewEmail("localhost:25", "", "", "cidrator@example.com", []string{"ops@example.com"}, subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email.Auth != nil {
+		t.Fatal("expected no authentication without a username")
+	}
+	message, err := email.message(Event{Summary: "Ünicode\r\nBcc: evil@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _, _ := strings.Cut(string(message), "\r\n\r\n")
+	if strings.Contains(header, "\r\nBcc:") || !strings.Contains(header, "Subject: =?utf-8?q?") {
+		t.Fatalf("subject not folded into one encoded header:\n%s", header)
+	}
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// defaultExecTimeout bounds an alert script that does not set its own
+const defaultExecTimeout = 30 * time.Second
+
+// Exec runs a command for each event. The event is written to its stdin as
+// JSON, and the main fields are also set as CIDRATOR_ALERT_SOURCE,
+// CIDRATOR_ALERT_TARGET, CIDRATOR_ALERT_SUMMARY, and CIDRATOR_ALERT_TIME.
+// Every argument after the program is itself a template.
+type Exec struct {
+	Path    string
+	Args    []*Template
+	Timeout time.Duration // 0 uses 30s
+}
+
+// NewExec parses command, the program followed by its argument templates
+func NewExec(command []string, timeout time.Duration) (*Exec, error) {
+	if len(command) == 0 || command[0] == "" {
+		return nil, errors.New("command is empty")
+	}
+	e := &Exec{Path: command[0], Timeout: timeout}
+	for _, arg := range command[1:] {
+		tmpl, err := parseTemplate(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %w", arg, err)
+		}
+		e.Args = append(e.Args, tmpl)
+	}
+	return e, nil
+}
+
+// Notify runs the command and fails if it exits non-zero or outlives the
+// timeout, quoting what it wrote to stderr
+func (e *Exec) Notify(ctx context.Context, event Event) error {
+	args := make([]string, len(e.Args))
+	for i, tmpl := range e.Args {
+		arg, err := tmpl.Render(event)
+		if err != nil {
+			return err
+		}
+		args[i] = arg
+	}
+	stdin, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = defaultExecTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, e.Path, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Env = append(os.Environ(),
+		"CIDRATOR_ALERT_SOURCE="+event.Source,
+		"CIDRATOR_ALERT_TARGET="+event.Target,
+		"CIDRATOR_ALERT_SUMMARY="+event.Summary,
+		"CIDRATOR_ALERT_TIME="+event.Time.Format(time.RFC3339),
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	// A killed shell can leave children holding stderr open
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return fmt.Errorf("exec %s: %w: %s", e.Path, err, message)
+		}
+		return fmt.Errorf("exec %s: %w", e.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+//go:build unix
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecRunsCommand(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	script := `cat > "$1.json"; printf '%s|%s|%s' "$2" "$CIDRATOR_ALERT_TARGET" "$CIDRATOR_ALERT_SUMMARY" > "$1"`
+	notifier, err := NewExec([]string{"/bin/sh", "-c", script, "sh", out, "{{upper .Target}}"}, time.Second)
+	if err != nil {
+		t.Fatalf("NewExec returned error: %v", err)
+	}
+
+	event := Event{Source: "dns watch", Target: "example.com A", Summary: "answers changed"}
+	if err := notifier.Notify(context.Background(), event); err != nil {
+		t.Fatalf("Notify returned error: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "EXAMPLE.COM A|example.com A|answers changed" {
+		t.Fatalf("script saw %q", got)
+	}
+	var stdin Event
+	data, err := os.ReadFile(out + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &stdin); err != nil || stdin.Summary != event.Summary {
+		t.Fatalf("stdin = %s (%v), want the event as JSON", data, err)
+	}
+}
+
+func TestExecReportsFailure(t *testing.T) {
+	notifier, err := NewExec([]string{"/bin/sh", "-c", "echo pager down >&2; exit 3"}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := notifier.Notify(context.Background(), Event{}); err == nil || !strings.Contains(err.Error(), "pager down") {
+		t.Fatalf("Notify = %v, want the script's stderr", err)
+	}
+
+	slow, err := NewExec([]string{"/bin/sh", "-c", "sleep 5"}, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := slow.Notify(context.Background(), Event{}); err == nil || time.Since(start) > 3*time.Second {
+		t.Fatalf("Notify = %v after %v, want a prompt timeout", err, time.Since(start))
+	}
+}
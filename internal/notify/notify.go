@@ -0,0 +1,219 @@
+// Package notify delivers change notifications from watch commands to
+// webhooks, Slack, email, scripts, the terminal, and the local syslog. The
+// destinations a watch's flags name are joined by those in the alerts
+// section of the config file, so every watch command alerts the same way.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/pflag"
+)
+
+// Event is one change a watch noticed
+type Event struct {
+	Time    time.Time `json:"timestamp"`
+	Source  string    `json:"source"`  // Command that raised the event, such as "dns watch"
+	Target  string    `json:"target"`  // What is being watched
+	Summary string    `json:"summary"` // One line for humans and syslog
+	Details any       `json:"details,omitempty"`
+	// Suppressed counts the events for this target the rate limit held back
+	// since the last one delivered
+	Suppressed int `json:"suppressed,omitempty"`
+}
+
+// Notifier delivers events somewhere outside the terminal
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// Notifiers sends each event to every notifier in turn
+type Notifiers []Notifier
+
+// Notify delivers event everywhere, returning every delivery error
+func (n Notifiers) Notify(ctx context.Context, event Event) error {
+	var errs []error
+	for _, notifier := range n {
+		errs = append(errs, notifier.Notify(ctx, event))
+	}
+	return errors.Join(errs...)
+}
+
+// Retries of a webhook from NewWebhook: up to three, 1s, 2s, and 4s apart
+const (
+	defaultRetries = 3
+	defaultBackoff = time.Second
+)
+
+// OpenFlags is Open for a watch command that registers --webhook and --syslog
+func OpenFlags(flags *pflag.FlagSet) (Notifiers, func(), error) {
+	webhook, _ := flags.GetString("webhook")
+	useSyslog, _ := flags.GetBool("syslog")
+	return Open(webhook, useSyslog)
+}
+
+// Open builds the notifiers of a watch's --webhook and --syslog flags
+// together with those of the alerts config, behind its rate limit. Call the
+// returned function to close them when the watch ends.
+func Open(webhookURL string, useSyslog bool) (Notifiers, func(), error) {
+	var notifiers Notifiers
+	var closers []func() error
+	closeAll := func() {
+		for _, closer := range closers {
+			_ = closer()
+		}
+	}
+
+	cfg := Current()
+	text, err := ParseTemplate(cfg.Template)
+	if err != nil {
+		return nil, closeAll, err
+	}
+	if webhookURL != "" {
+		webhook, err := NewWebhook(webhookURL)
+		if err != nil {
+			return nil, closeAll, err
+		}
+		notifiers = append(notifiers, webhook)
+	}
+	if useSyslog {
+		logger, err := NewSyslog("cidrator")
+		if err != nil {
+			return nil, closeAll, err
+		}
+		logger.Template = text
+		notifiers = append(notifiers, logger)
+		closers = append(closers, logger.Close)
+	}
+	for i, nc := range cfg.Notifiers {
+		notifier, closer, err := nc.open(text)
+		if err != nil {
+			closeAll()
+			return nil, func() {}, errcode.Errorf(errcode.CLIAlerts, "alerts notifier %d (%s): %w", i+1, nc.Type, err)
+		}
+		notifiers = append(notifiers, notifier)
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+	}
+
+	if cfg.RateLimit.Interval > 0 && len(notifiers) > 0 {
+		notifiers = Notifiers{NewRateLimited(notifiers, cfg.RateLimit.Interval, cfg.RateLimit.Burst)}
+	}
+	return notifiers, closeAll, nil
+}
+
+// Webhook POSTs each event as a JSON object
+type Webhook struct {
+	URL    string
+	Client *http.Client // nil uses a client with a 10s timeout
+	// Encode builds the request body; nil posts the event as JSON
+	Encode func(Event) ([]byte, error)
+
+	// Retries is how many more times a failed delivery is attempted, waiting
+	// Backoff before the first retry and twice as long before each after it.
+	// Only connection failures, 429, and 5xx responses are retried.
+	Retries int
+	Backoff time.Duration
+}
+
+// NewWebhook validates a --webhook URL
+func NewWebhook(raw string) (*Webhook, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errcode.Errorf(errcode.CLIUsage, "invalid webhook URL %q: expected http:// or https://", raw)
+	}
+	return &Webhook{URL: raw, Retries: defaultRetries, Backoff: defaultBackoff}, nil
+}
+
+// NewSlack validates a Slack-compatible incoming webhook URL. Each event is
+// posted as {"text": ...} rendered by text, which Slack, Mattermost, and
+// Rocket.Chat all accept.
+func NewSlack(raw string, text *Template) (*Webhook, error) {
+	webhook, err := NewWebhook(raw)
+	if err != nil {
+		return nil, err
+	}
+	webhook.Encode = func(event Event) ([]byte, error) {
+		message, err := text.Render(event)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]string{"text": message})
+	}
+	return webhook, nil
+}
+
+// retryableError is a delivery failure worth trying again
+type retryableError struct{ err error }
+
+func (e retryableError) Error() string { return e.err.Error() }
+func (e retryableError) Unwrap() error { return e.err }
+
+// Notify posts event, retrying as configured, and fails once every attempt
+// has failed or a response other than 2xx, 429, or 5xx arrives
+func (w *Webhook) Notify(ctx context.Context, event Event) error {
+	encode := w.Encode
+	if encode == nil {
+		encode = func(event Event) ([]byte, error) { return json.Marshal(event) }
+	}
+	body, err := encode(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	backoff := w.Backoff
+	for attempt := 0; ; attempt++ {
+		err := w.post(ctx, body)
+		var retryable retryableError
+		if err == nil || !errors.As(err, &retryable) || attempt >= w.Retries || ctx.Err() != nil {
+			if err != nil && attempt > 0 {
+				return fmt.Errorf("%w (after %d attempts)", err, attempt+1)
+			}
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (after %d attempts)", err, attempt+1)
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// post makes one delivery attempt
+func (w *Webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return retryableError{fmt.Errorf("webhook: %w", err)}
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return retryableError{fmt.Errorf("webhook: %s returned %s", w.URL, resp.Status)}
+	}
+	return fmt.Errorf("webhook: %s returned %s", w.URL, resp.Status)
+}
@@ -0,0 +1,190 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/pflag"
+)
+
+func TestWebhookPostsEvent(t *testing.T) {
+	var got Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid body: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	webhook, err := NewWebhook(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := Event{Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Source: "dns watch", Target: "example.com A", Summary: "answers changed"}
+	if err := webhook.Notify(context.Background(), event); err != nil {
+		t.Fatalf("Notify returned error: %v", err)
+	}
+	if got.Summary != event.Summary || got.Target != event.Target || !got.Time.Equal(event.Time) {
+		t.Fatalf("webhook received %+v, want %+v", got, event)
+	}
+}
+
+func TestWebhookErrors(t *testing.T) {
+	for _, raw := range []string{"", "ftp://example.com", "http://", "::"} {
+		if _, err := NewWebhook(raw); errcode.Of(err) != errcode.CLIUsage {
+			t.Errorf("NewWebhook(%q) = %v, want CLI002", raw, err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusBadGateway)
+	}))
+	defer server.Close()
+	if err := (&Webhook{URL: server.URL}).Notify(context.Background(), Event{}); err == nil || !strings.Contains(err.Error(), "502") {
+		t.Fatalf("expected the status in the error, got %v", err)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		wantErr  string
+		attempts int
+	}{
+		{"recovers", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}, "", 3},
+		{"gives up", []int{http.StatusBadGateway}, "502 Bad Gateway (after 3 attempts)", 3},
+		{"client error is final", []int{http.StatusNotFound}, "404 Not Found", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statuses[min(attempts, len(tt.statuses)-1)])
+				attempts++
+			}))
+			defer server.Close()
+
+			webhook := &Webhook{URL: server.URL, Retries: 2, Backoff: time.Millisecond}
+			err := webhook.Notify(context.Background(), Event{})
+			if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.HasSuffix(err.Error(), tt.wantErr)) {
+				t.Errorf("Notify = %v, want %q", err, tt.wantErr)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("made %d attempts, want %d", attempts, tt.attempts)
+			}
+		})
+	}
+
+	webhook, _ := NewWebhook("https://hooks.example.com/alert")
+	if webhook.Retries != defaultRetries || webhook.Backoff != defaultBackoff {
+		t.Errorf("NewWebhook retries %d after %v, want the defaults", webhook.Retries, webhook.Backoff)
+	}
+}
+
+func TestWebhookStopsRetryingWhenCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := (&Webhook{URL: server.URL, Retries: 5, Backoff: time.Hour}).Notify(ctx, Event{})
+	if err == nil || time.Since(start) > 5*time.Second {
+		t.Fatalf("Notify = %v after %v, want a prompt failure", err, time.Since(start))
+	}
+}
+
+type notifierFunc func(ctx context.Context, event Event) error
+
+func (f notifierFunc) Notify(ctx context.Context, event Event) error { return f(ctx, event) }
+
+func TestOpenFlags(t *testing.T) {
+	flags := pflag.NewFlagSet("watch", pflag.ContinueOnError)
+	flags.String("webhook", "", "")
+	flags.Bool("syslog", false, "")
+	if err := flags.Parse([]string{"--webhook", "ftp://example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := OpenFlags(flags); errcode.Of(err) != errcode.CLIUsage {
+		t.Fatalf("OpenFlags = %v, want the webhook URL rejected", err)
+	}
+}
+
+func TestNotifiersDeliversEverywhere(t *testing.T) {
+	failed := errors.New("down")
+	var delivered int
+	notifiers := Notifiers{
+		notifierFunc(func(context.Context, Event) error { return failed }),
+		notifierFunc(func(context.Context, Event) error { delivered++; return nil }),
+	}
+	if err := notifiers.Notify(context.Background(), Event{}); !errors.Is(err, failed) {
+		t.Fatalf("expected the first notifier's error, got %v", err)
+	}
+	if delivered != 1 {
+		t.Fatalf("a failing notifier should not stop the others")
+	}
+}
+
+func TestSlackPostsText(t *testing.T) {
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid body: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	slack, err := NewSlack(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := Event{Source: "mtu watch", Target: "vpn.example.com", Summary: "PMTU dropped from 1500 to 1400", Suppressed: 2}
+	if err := slack.Notify(context.Background(), event); err != nil {
+		t.Fatalf("Notify returned error: %v", err)
+	}
+	if want := "mtu watch vpn.example.com: PMTU dropped from 1500 to 1400 (+2 suppressed)"; got["text"] != want || len(got) != 1 {
+		t.Fatalf("slack received %v, want text %q", got, want)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	event := Event{
+		Time:    time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Source:  "dns watch",
+		Target:  "example.com A",
+		Summary: "answers changed",
+		Details: map[string]int{"added": 1},
+	}
+	tmpl, err := ParseTemplate(`{{rfc3339 .Time}} {{upper .Source}} {{json .Details}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := tmpl.Render(event); err != nil || got != `2026-01-02T03:04:05Z DNS WATCH {"added":1}` {
+		t.Fatalf("Render = %q, %v", got, err)
+	}
+	if _, err := ParseTemplate("{{.Target"); err == nil {
+		t.Fatal("expected a parse error")
+	}
+
+	var out strings.Builder
+	if err := (&Stream{W: &out}).Notify(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "dns watch example.com A: answers changed\n" {
+		t.Fatalf("Stream wrote %q", out.String())
+	}
+}
@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimited passes at most Burst events per watched target to Notifier in
+// any Interval, so a flapping path pages once rather than every poll. Events
+// held back are counted into Suppressed on the next one that goes through.
+type RateLimited struct {
+	Notifier Notifier
+	Interval time.Duration
+	Burst    int // 0 means 1
+
+	mu         sync.Mutex
+	sent       map[string][]time.Time // Delivery times within the last Interval, per target
+	suppressed map[string]int
+}
+
+// NewRateLimited limits notifier to burst events per target per interval
+func NewRateLimited(notifier Notifier, interval time.Duration, burst int) *RateLimited {
+	return &RateLimited{Notifier: notifier, Interval: interval, Burst: burst}
+}
+
+// Notify delivers event unless its target has used up its burst, in which
+// case the event is dropped without error
+func (r *RateLimited) Notify(ctx context.Context, event Event) error {
+	if !r.allow(&event) {
+		return nil
+	}
+	return r.Notifier.Notify(ctx, event)
+}
+
+// allow records event against its target's budget, adding the count of
+// events suppressed since the last delivery when it may go out
+func (r *RateLimited) allow(event *Event) bool {
+	now := event.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	key := event.Source + "\x00" + event.Target
+	burst := max(r.Burst, 1)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.sent == nil {
+		r.sent = make(map[string][]time.Time)
+		r.suppressed = make(map[string]int)
+	}
+
+	recent := r.sent[key][:0]
+	for _, at := range r.sent[key] {
+		if now.Sub(at) < r.Interval {
+			recent = append(recent, at)
+		}
+	}
+	if len(recent) >= burst {
+		r.sent[key] = recent
+		r.suppressed[key]++
+		return false
+	}
+	r.sent[key] = append(recent, now)
+	event.Suppressed += r.suppressed[key]
+	delete(r.suppressed, key)
+	return true
+}
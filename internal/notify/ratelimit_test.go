@@ -0,0 +1,43 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimited(t *testing.T) {
+	var delivered []Event
+	limited := NewRateLimited(notifierFunc(func(ctx context.Context, event Event) error {
+		delivered = append(delivered, event)
+		return nil
+	}), time.Minute, 2)
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	send := func(target string, after time.Duration) {
+		t.Helper()
+		if err := limited.Notify(context.Background(), Event{Time: start.Add(after), Source: "mtu watch", Target: target}); err != nil {
+			t.Fatalf("Notify returned error: %v", err)
+		}
+	}
+	send("a", 0)
+	send("a", 10*time.Second)
+	send("a", 20*time.Second) // Over the burst
+	send("a", 30*time.Second) // Over the burst
+	send("b", 30*time.Second) // Another target has its own budget
+	send("a", 61*time.Second) // The first delivery has left the interval
+
+	var targets string
+	for _, event := range delivered {
+		targets += event.Target
+	}
+	if targets != "aaba" {
+		t.Fatalf("delivered %q, want aaba", targets)
+	}
+	if last := delivered[len(delivered)-1]; last.Suppressed != 2 {
+		t.Fatalf("Suppressed = %d, want 2", last.Suppressed)
+	}
+	if delivered[2].Suppressed != 0 {
+		t.Fatalf("target b inherited %d suppressed alerts", delivered[2].Suppressed)
+	}
+}
@@ -0,0 +1,29 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Stream writes each event as one rendered line, for the stdout and stderr
+// notifiers
+type Stream struct {
+	W        io.Writer
+	Template *Template // nil writes DefaultTemplate
+
+	mu sync.Mutex
+}
+
+// Notify writes the rendered event and a newline
+func (s *Stream) Notify(ctx context.Context, event Event) error {
+	line, err := s.Template.Render(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = fmt.Fprintln(s.W, line)
+	return err
+}
@@ -0,0 +1,29 @@
+//go:build !unix
+
+package notify
+
+import (
+	"context"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// Syslog is unavailable on this platform
+type Syslog struct {
+	Template *Template
+}
+
+// NewSyslog reports that syslog is not supported here
+func NewSyslog(tag string) (*Syslog, error) {
+	return nil, errcode.Errorf(errcode.CLIUsage, "syslog alerts are not supported on this platform")
+}
+
+// Notify never succeeds; NewSyslog does not return a usable Syslog
+func (s *Syslog) Notify(ctx context.Context, event Event) error {
+	return errcode.Errorf(errcode.CLIUsage, "syslog alerts are not supported on this platform")
+}
+
+// Close does nothing
+func (s *Syslog) Close() error {
+	return nil
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/syslog"
+)
+
+// Syslog writes each event's summary to the local syslog at warning level
+type Syslog struct {
+	Template *Template // nil logs DefaultTemplate
+	writer   *syslog.Writer
+}
+
+// NewSyslog connects to the local syslog daemon, tagging messages with tag
+func NewSyslog(tag string) (*Syslog, error) {
+	writer, err := syslog.New(syslog.LOG_WARNING|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return &Syslog{writer: writer}, nil
+}
+
+// Notify logs the event as rendered by the template
+func (s *Syslog) Notify(ctx context.Context, event Event) error {
+	line, err := s.Template.Render(event)
+	if err != nil {
+		return err
+	}
+	return s.writer.Warning(line)
+}
+
+// Close disconnects from syslog
+func (s *Syslog) Close() error {
+	return s.writer.Close()
+}
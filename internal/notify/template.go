@@ -0,0 +1,65 @@
+package notify
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultTemplate renders an event as one line, such as
+// "mtu watch vpn.example.com: PMTU dropped from 1500 to 1400"
+const DefaultTemplate = `{{.Source}} {{.Target}}: {{.Summary}}{{if .Suppressed}} (+{{.Suppressed}} suppressed){{end}}`
+
+// templateFuncs are available to alert templates besides the built-ins
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"rfc3339": func(t time.Time) string {
+		return t.Format(time.RFC3339)
+	},
+}
+
+// Template renders events as text for the notifiers that send a message
+// rather than the event's JSON. Templates use Go text/template syntax over
+// Event, so {{.Target}} or {{json .Details}}.
+type Template struct {
+	tmpl *template.Template
+}
+
+// ParseTemplate parses an alert template; empty text uses DefaultTemplate
+func ParseTemplate(text string) (*Template, error) {
+	if text == "" {
+		text = DefaultTemplate
+	}
+	return parseTemplate(text)
+}
+
+// parseTemplate parses text as it is, so empty text renders nothing
+func parseTemplate(text string) (*Template, error) {
+	tmpl, err := template.New("alert").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// defaultTemplate backs a nil *Template
+var defaultTemplate = template.Must(template.New("alert").Funcs(templateFuncs).Parse(DefaultTemplate))
+
+// Render renders event. A nil Template renders DefaultTemplate.
+func (t *Template) Render(event Event) (string, error) {
+	tmpl := defaultTemplate
+	if t != nil {
+		tmpl = t.tmpl
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, event); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
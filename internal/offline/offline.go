@@ -0,0 +1,28 @@
+// Package offline is the switch for air-gapped hosts where cidrator may only
+// compute. While it is on nothing may reach the network, and whatever would
+// have fails at once with CLI012 rather than waiting out a timeout.
+package offline
+
+import "github.com/euan-cowie/cidrator/internal/errcode"
+
+// enabled is whether offline mode is on
+var enabled bool
+
+// Configure turns offline mode on or off
+func Configure(on bool) {
+	enabled = on
+}
+
+// Enabled reports whether offline mode is on
+func Enabled() bool {
+	return enabled
+}
+
+// Check rejects action, such as "resolving example.com", while offline mode
+// is on
+func Check(action string) error {
+	if !enabled {
+		return nil
+	}
+	return errcode.Errorf(errcode.CLIOffline, "%s needs the network, which --offline turns off", action)
+}
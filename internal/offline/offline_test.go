@@ -0,0 +1,21 @@
+package offline
+
+import (
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+func TestCheck(t *testing.T) {
+	t.Cleanup(func() { Configure(false) })
+
+	if err := Check("resolving example.com"); err != nil || Enabled() {
+		t.Fatalf("Check while online = %v", err)
+	}
+
+	Configure(true)
+	err := Check("resolving example.com")
+	if !Enabled() || errcode.Of(err) != errcode.CLIOffline || err.Error() != "resolving example.com needs the network, which --offline turns off" {
+		t.Fatalf("Check while offline = %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+// Package policy restricts what a deployed cidrator may do, so admins can ship
+// the same binary to everyone and lock it down for some users: which commands
+// run, how fast probes may be sent, and which networks are off limits.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// AlwaysAllowed are commands every policy permits, since they neither probe
+// nor reveal anything
+var AlwaysAllowed = []string{"help", "version", "completion", "__complete", "__completeNoDesc"}
+
+// resolveTimeout bounds the lookup of each hostname target
+const resolveTimeout = 5 * time.Second
+
+// Policy is the restrictions in force. The zero value allows everything.
+type Policy struct {
+	// AllowedCommands lists command paths below the root, such as "cidr" or
+	// "mtu discover"; a group allows all of its subcommands. Empty allows all.
+	AllowedCommands []string `yaml:"allowed_commands" mapstructure:"allowed_commands"`
+	// MaxPPS caps every --pps and --qps rate; 0 means no cap
+	MaxPPS int `yaml:"max_pps" mapstructure:"max_pps"`
+	// ForbiddenTargetCIDRs lists networks no probe may be sent to
+	ForbiddenTargetCIDRs []string `yaml:"forbidden_target_cidrs" mapstructure:"forbidden_target_cidrs"`
+
+	// Source names where the policy came from, for error messages
+	Source string `yaml:"-" mapstructure:"-"`
+
+	forbidden *cidr.Set
+}
+
+// documentationRanges are the special-purpose blocks reserved for examples
+// and benchmarking. Nothing real answers there, so a probe sent to one almost
+// always comes from an example pasted without changing its address.
+var documentationRanges = sync.OnceValue(func() []cidr.SpecialPrefix {
+	var ranges []cidr.SpecialPrefix
+	for _, special := range cidr.SpecialPrefixes() {
+		if special.Class == cidr.ClassDocumentation || special.Class == cidr.ClassBenchmarking {
+			ranges = append(ranges, special)
+		}
+	}
+	return ranges
+})
+
+// current is the policy in force; the zero value allows everything
+var current Policy
+
+// resolved pins the addresses CheckHosts looked up for each hostname, so
+// probes dial what was checked rather than what a second lookup returns
+var resolved = struct {
+	sync.Mutex
+	hosts map[string][]netip.Addr
+}{hosts: make(map[string][]netip.Addr)}
+
+// lookupNetIP resolves hostname targets; tests replace it
+var lookupNetIP = func(ctx context.Context, host string) ([]netip.Addr, error) {
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+// Load reads a policy file: YAML with the same keys as the policy section of
+// the config file
+func Load(path string) (Policy, error) {
+	var p Policy
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, errcode.Errorf(errcode.CLIPolicy, "policy: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return p, errcode.Errorf(errcode.CLIPolicy, "policy %s: %w", path, err)
+	}
+	p.Source = path
+	return p, nil
+}
+
+// Configure validates p and puts it in force
+func Configure(p Policy) error {
+	if p.MaxPPS < 0 {
+		return errcode.Errorf(errcode.CLIPolicy, "policy %s: max_pps must not be negative, got %d", p.Source, p.MaxPPS)
+	}
+	forbidden, err := cidr.ParseSet(p.ForbiddenTargetCIDRs)
+	if err != nil {
+		return errcode.Errorf(errcode.CLIPolicy, "policy %s: forbidden_target_cidrs: %w", p.Source, err)
+	}
+	p.forbidden = forbidden
+	current = p
+	return nil
+}
+
+// Current returns the policy in force
+func Current() Policy {
+	return current
+}
+
+// Active reports whether the policy in force restricts anything
+func (p Policy) Active() bool {
+	return len(p.AllowedCommands) > 0 || p.MaxPPS > 0 || len(p.ForbiddenTargetCIDRs) > 0
+}
+
+// CheckCommand rejects a command path, such as "mtu discover", that the
+// policy does not allow
+func CheckCommand(path string) error {
+	if len(current.AllowedCommands) == 0 || path == "" || allowed(AlwaysAllowed, path) || allowed(current.AllowedCommands, path) {
+		return nil
+	}
+	return errcode.Errorf(errcode.CLIPolicy, "%q is not allowed by policy %s (allowed: %s)", path, current.Source, strings.Join(current.AllowedCommands, ", "))
+}
+
+// allowed reports whether path is one of the commands in list or below one
+func allowed(list []string, path string) bool {
+	return slices.ContainsFunc(list, func(command string) bool {
+		command = strings.Join(strings.Fields(command), " ")
+		return path == command || strings.HasPrefix(path, command+" ")
+	})
+}
+
+// Rate applies max_pps to a rate flag. When the user set it, it must be
+// positive and at most the cap; otherwise the default is lowered to the cap
+// if it exceeds it or means no limit. It returns the rate to use.
+func Rate(name string, value float64, changed bool) (float64, error) {
+	limit := float64(current.MaxPPS)
+	switch {
+	case current.MaxPPS == 0 || (value > 0 && value <= limit):
+		return value, nil
+	case changed && value <= 0:
+		return 0, errcode.Errorf(errcode.CLIPolicy, "--%s %g means no limit, but policy %s caps it at max_pps %d", name, value, current.Source, current.MaxPPS)
+	case changed:
+		return 0, errcode.Errorf(errcode.CLIPolicy, "--%s %g exceeds max_pps %d set by policy %s", name, value, current.MaxPPS, current.Source)
+	}
+	return limit, nil
+}
+
+// CheckPrefix rejects a CIDR or address that overlaps a forbidden network
+func CheckPrefix(s string) error {
+	if current.forbidden == nil || current.forbidden.IsEmpty() {
+		return nil
+	}
+	set, err := cidr.ParseSet([]string{s})
+	if err != nil {
+		// Not ours to reject; the command reports invalid input itself
+		return nil
+	}
+	if overlap := set.Intersect(current.forbidden); !overlap.IsEmpty() {
+		return errcode.Errorf(errcode.CLIPolicy, "%s overlaps %s, forbidden by policy %s", s, strings.Join(overlap.Strings(), ", "), current.Source)
+	}
+	return nil
+}
+
+// CheckHosts rejects probing any of hosts that is, or resolves to, an address
+// in a network forbidden by policy or, unless allowDocRanges, in a
+// documentation or benchmarking range. Ports are ignored. A hostname that
+// does not resolve passes, since it cannot be probed either. The addresses a
+// hostname resolved to are pinned for Resolved, so a name that resolves
+// elsewhere once checked (DNS rebinding) cannot redirect the probe.
+func CheckHosts(ctx context.Context, hosts []string, allowDocRanges bool) error {
+	checkPolicy := current.forbidden != nil && !current.forbidden.IsEmpty()
+	if !checkPolicy && allowDocRanges {
+		return nil
+	}
+	for _, host := range hosts {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		addrs := []netip.Addr{}
+		if addr, err := netip.ParseAddr(host); err == nil {
+			addrs = append(addrs, addr)
+		} else {
+			lookupCtx, cancel := context.WithTimeout(ctx, resolveTimeout)
+			addrs, err = lookupNetIP(lookupCtx, host)
+			cancel()
+			pin(host, addrs)
+			if err != nil {
+				continue
+			}
+		}
+		for _, addr := range addrs {
+			if err := checkAddr(host, addr.Unmap(), checkPolicy, allowDocRanges); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Resolved returns the addresses CheckHosts resolved host to, and whether it
+// checked host at all. Probers dial one of these instead of resolving host
+// again. No addresses means the lookup failed, and the probe should too.
+func Resolved(host string) ([]netip.Addr, bool) {
+	resolved.Lock()
+	defer resolved.Unlock()
+	addrs, ok := resolved.hosts[strings.ToLower(strings.TrimSuffix(host, "."))]
+	return slices.Clone(addrs), ok
+}
+
+func pin(host string, addrs []netip.Addr) {
+	unmapped := make([]netip.Addr, len(addrs))
+	for i, addr := range addrs {
+		unmapped[i] = addr.Unmap()
+	}
+	resolved.Lock()
+	defer resolved.Unlock()
+	resolved.hosts[strings.ToLower(strings.TrimSuffix(host, "."))] = unmapped
+}
+
+// CheckTargets is the target-validation step every packet-emitting command
+// runs before sending: it applies CheckHosts to hosts, honouring the
+// --allow-doc-ranges flag when flags has it. A nil ctx is treated as
+// context.Background.
+func CheckTargets(ctx context.Context, flags *pflag.FlagSet, hosts ...string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	allowDocRanges := false
+	if flags != nil {
+		allowDocRanges, _ = flags.GetBool("allow-doc-ranges")
+	}
+	return CheckHosts(ctx, hosts, allowDocRanges)
+}
+
+func checkAddr(host string, addr netip.Addr, checkPolicy, allowDocRanges bool) error {
+	if host != addr.String() {
+		host = fmt.Sprintf("%s (%s)", host, addr)
+	}
+	if checkPolicy && current.forbidden.Contains(addr) {
+		return errcode.Errorf(errcode.CLIPolicy, "target %s is in a network forbidden by policy %s", host, current.Source)
+	}
+	if allowDocRanges {
+		return nil
+	}
+	for _, special := range documentationRanges() {
+		if special.Prefix.Contains(addr) {
+			return errcode.Errorf(errcode.CLIDocumentationRange, "target %s is in %s, reserved for %s by %s; pass --allow-doc-ranges to probe it anyway",
+				host, special.Prefix, special.Class, special.RFC)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// use puts p in force for the rest of the test
+func use(t *testing.T, p Policy) {
+	t.Helper()
+	original := current
+	t.Cleanup(func() { current = original })
+	if err := Configure(p); err != nil {
+		t.Fatalf("Configure returned error: %v", err)
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	if err := CheckCommand("mtu discover"); err != nil {
+		t.Fatalf("the zero policy rejected a command: %v", err)
+	}
+
+	use(t, Policy{AllowedCommands: []string{"cidr", "dns", "mtu  interfaces"}, Source: "test.yaml"})
+	for path, want := range map[string]bool{
+		"cidr explain":        true,
+		"dns":                 true,
+		"mtu interfaces":      true,
+		"version":             true,
+		"help cidr":           true,
+		"":                    true,
+		"mtu discover":        false,
+		"mtu":                 false,
+		"cidrx":               false,
+		"fw wireguard-config": false,
+	} {
+		err := CheckCommand(path)
+		if (err == nil) != want {
+			t.Errorf("CheckCommand(%q) = %v, want allowed %v", path, err, want)
+		}
+		if err != nil && errcode.Of(err) != errcode.CLIPolicy {
+			t.Errorf("CheckCommand(%q): expected CLI009, got %v", path, err)
+		}
+	}
+}
+
+func TestRate(t *testing.T) {
+	use(t, Policy{MaxPPS: 5, Source: "test.yaml"})
+	tests := []struct {
+		value   float64
+		changed bool
+		want    float64
+		wantErr bool
+	}{
+		{10, false, 5, false},
+		{0, false, 5, false},
+		{2, false, 2, false},
+		{5, true, 5, false},
+		{6, true, 0, true},
+		{0, true, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := Rate("pps", tt.value, tt.changed)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Rate(%g, %v) = %g, %v; want %g, error %v", tt.value, tt.changed, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	if err := Configure(Policy{MaxPPS: -1}); errcode.Of(err) != errcode.CLIPolicy {
+		t.Errorf("expected CLI009 for a negative max_pps, got %v", err)
+	}
+	if err := Configure(Policy{ForbiddenTargetCIDRs: []string{"bogus"}}); errcode.Of(err) != errcode.CLIPolicy {
+		t.Errorf("expected CLI009 for a bad CIDR, got %v", err)
+	}
+}
+
+func TestCheckTargets(t *testing.T) {
+	use(t, Policy{ForbiddenTargetCIDRs: []string{"10.0.0.0/8", "2001:db8::/32"}, Source: "test.yaml"})
+
+	for prefix, want := range map[string]bool{
+		"192.168.0.0/24":  true,
+		"10.1.0.0/16":     false,
+		"8.0.0.0/6":       false,
+		"2001:db8:1::/48": false,
+		"not a prefix":    true,
+	} {
+		if err := CheckPrefix(prefix); (err == nil) != want {
+			t.Errorf("CheckPrefix(%q) = %v, want allowed %v", prefix, err, want)
+		}
+	}
+
+	original := lookupNetIP
+	t.Cleanup(func() { lookupNetIP = original })
+	lookupNetIP = func(_ context.Context, host string) ([]netip.Addr, error) {
+		switch host {
+		case "internal.example":
+			return []netip.Addr{netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("10.0.0.5")}, nil
+		case "public.example":
+			return []netip.Addr{netip.MustParseAddr("192.0.2.1")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	for _, tt := range []struct {
+		hosts []string
+		want  bool
+	}{
+		{[]string{"192.0.2.1", "public.example", "missing.example"}, true},
+		{[]string{"192.0.2.1", "10.2.3.4"}, false},
+		{[]string{"[2001:db8::1]:443"}, false},
+		{[]string{"::ffff:10.0.0.1"}, false},
+		{[]string{"internal.example"}, false},
+	} {
+		err := CheckHosts(context.Background(), tt.hosts, true)
+		if (err == nil) != tt.want {
+			t.Errorf("CheckHosts(%v) = %v, want allowed %v", tt.hosts, err, tt.want)
+		}
+		if err != nil && errcode.Of(err) != errcode.CLIPolicy {
+			t.Errorf("CheckHosts(%v): expected CLI009, got %v", tt.hosts, err)
+		}
+	}
+}
+
+func TestCheckHostsPinsResolvedAddresses(t *testing.T) {
+	use(t, Policy{ForbiddenTargetCIDRs: []string{"10.0.0.0/8"}, Source: "test.yaml"})
+	original := lookupNetIP
+	t.Cleanup(func() { lookupNetIP = original })
+	lookupNetIP = func(_ context.Context, host string) ([]netip.Addr, error) {
+		if host == "rebind.example" {
+			return []netip.Addr{netip.MustParseAddr("::ffff:192.0.2.1")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	if err := CheckHosts(context.Background(), []string{"rebind.example:443", "gone.example", "192.0.2.2"}, true); err != nil {
+		t.Fatalf("CheckHosts returned error: %v", err)
+	}
+	// A second lookup now lands in a forbidden network; the probe must not
+	lookupNetIP = func(context.Context, string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("10.0.0.1")}, nil
+	}
+
+	if addrs, ok := Resolved("Rebind.example."); !ok || len(addrs) != 1 || addrs[0] != netip.MustParseAddr("192.0.2.1") {
+		t.Errorf("Resolved(rebind.example) = %v, %v, want the checked 192.0.2.1", addrs, ok)
+	}
+	if addrs, ok := Resolved("gone.example"); !ok || len(addrs) != 0 {
+		t.Errorf("a failed lookup should pin no addresses, got %v, %v", addrs, ok)
+	}
+	if _, ok := Resolved("192.0.2.2"); ok {
+		t.Error("an address literal has nothing to pin")
+	}
+	if _, ok := Resolved("unchecked.example"); ok {
+		t.Error("a host that was never checked should not be pinned")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	data := "allowed_commands: [cidr, dns]\nmax_pps: 5\nforbidden_target_cidrs:\n  - 10.0.0.0/8\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if len(p.AllowedCommands) != 2 || p.MaxPPS != 5 || len(p.ForbiddenTargetCIDRs) != 1 || p.Source != path || !p.Active() {
+		t.Fatalf("Load = %+v", p)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); errcode.Of(err) != errcode.CLIPolicy {
+		t.Errorf("expected CLI009 for a missing file, got %v", err)
+	}
+}
+
+func TestCheckHostsDocumentationRanges(t *testing.T) {
+	original := lookupNetIP
+	t.Cleanup(func() { lookupNetIP = original })
+	lookupNetIP = func(_ context.Context, host string) ([]netip.Addr, error) {
+		if host == "docs.example" {
+			return []netip.Addr{netip.MustParseAddr("203.0.113.7")}, nil
+		}
+		return []netip.Addr{netip.MustParseAddr("93.184.215.14")}, nil
+	}
+
+	for host, want := range map[string]bool{
+		"example.com":           true,
+		"192.0.3.1":             true,
+		"2001:db9::1":           true,
+		"192.0.2.1":             false,
+		"198.51.100.20:443":     false,
+		"198.19.255.255":        false,
+		"[2001:db8::1]:443":     false,
+		"::ffff:203.0.113.1":    false,
+		"docs.example":          false,
+		"fw.example.com":        true,
+		"198.18.0.0":            false,
+		"2001:db8:ffff:ffff::1": false,
+		"3fff::1":               false,
+	} {
+		err := CheckHosts(context.Background(), []string{host}, false)
+		if (err == nil) != want {
+			t.Errorf("CheckHosts(%q) = %v, want allowed %v", host, err, want)
+		}
+		if err != nil && errcode.Of(err) != errcode.CLIDocumentationRange {
+			t.Errorf("CheckHosts(%q): expected CLI011, got %v", host, err)
+		}
+		if err := CheckHosts(context.Background(), []string{host}, true); err != nil {
+			t.Errorf("CheckHosts(%q) with doc ranges allowed = %v", host, err)
+		}
+	}
+
+	// Policy is checked first, so a forbidden documentation address reports CLI009
+	use(t, Policy{ForbiddenTargetCIDRs: []string{"192.0.2.0/24"}, Source: "test.yaml"})
+	if err := CheckHosts(context.Background(), []string{"192.0.2.1"}, false); errcode.Of(err) != errcode.CLIPolicy {
+		t.Errorf("expected CLI009 for a forbidden documentation address, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+// Package prefixset keeps named prefix sets in the state store so commands can
+// refer to them as @name instead of passing the same files around.
+package prefixset
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/store"
+)
+
+// Namespace is the store namespace that holds prefix sets
+const Namespace = "prefix-sets"
+
+// Summary describes one stored set
+type Summary struct {
+	Name     string    `json:"name"`
+	Prefixes int       `json:"prefixes"`
+	Updated  time.Time `json:"updated"`
+}
+
+// stored is the persisted form of a set: its minimal covering CIDRs
+type stored struct {
+	Prefixes []string `json:"prefixes"`
+}
+
+// openStore opens the configured store, replaced in tests
+var openStore = store.Open
+
+// ValidateName rejects names an @name reference could not spell
+func ValidateName(name string) error {
+	if !cidr.IsSetName(name) {
+		return errcode.Errorf(errcode.CLIUsage, "invalid set name %q: use letters, digits, '_' and '.'", name)
+	}
+	return nil
+}
+
+// Get loads the named set and reports whether it exists
+func Get(name string) (*cidr.Set, bool, error) {
+	s, err := openStore()
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = s.Close() }()
+
+	var value stored
+	found, err := s.Get(Namespace, name, &value)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	set, err := cidr.ParseSet(value.Prefixes)
+	if err != nil {
+		return nil, false, errcode.Errorf(errcode.CLIStore, "stored set @%s is corrupt: %w", name, err)
+	}
+	return set, true, nil
+}
+
+// Put stores set under name, replacing any existing set
+func Put(name string, set *cidr.Set) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	s, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = s.Close() }()
+	return s.Put(Namespace, name, stored{Prefixes: set.Strings()}, 0)
+}
+
+// Delete removes the named set and reports whether it existed
+func Delete(name string) (bool, error) {
+	s, err := openStore()
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = s.Close() }()
+	return s.Delete(Namespace, name)
+}
+
+// List summarizes every stored set, ordered by name
+func List() ([]Summary, error) {
+	s, err := openStore()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = s.Close() }()
+
+	records, err := s.List(Namespace)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]Summary, 0, len(records))
+	for _, record := range records {
+		var value stored
+		if err := json.Unmarshal(record.Value, &value); err != nil {
+			return nil, errcode.Errorf(errcode.CLIStore, "stored set @%s is corrupt: %w", record.Key, err)
+		}
+		summaries = append(summaries, Summary{Name: record.Key, Prefixes: len(value.Prefixes), Updated: record.Updated})
+	}
+	return summaries, nil
+}
+
+// Resolver looks up @name references: the file given for name in files wins,
+// then a stored set, then name.txt or name in dir
+func Resolver(files map[string]string, dir string) cidr.SetResolver {
+	return func(name string) (*cidr.Set, error) {
+		if path, ok := files[name]; ok {
+			set, found, err := readFile(name, path)
+			if err == nil && !found {
+				err = errcode.Errorf(errcode.CIDRInvalidExpr, "set @%s: no file at %s", name, path)
+			}
+			return set, err
+		}
+
+		set, found, err := Get(name)
+		if err != nil || found {
+			return set, err
+		}
+
+		for _, path := range []string{filepath.Join(dir, name+".txt"), filepath.Join(dir, name)} {
+			set, found, err := readFile(name, path)
+			if err != nil || found {
+				return set, err
+			}
+		}
+		return nil, errcode.Errorf(errcode.CIDRInvalidExpr, "unknown set @%s: not stored and no %s.txt in %s", name, name, dir)
+	}
+}
+
+// readFile reads a set file, reporting false if it does not exist
+func readFile(name, path string) (*cidr.Set, bool, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, errcode.Wrap(errcode.CIDRInvalidExpr, fmt.Errorf("set @%s: %w", name, err))
+	}
+	defer func() { _ = file.Close() }()
+
+	set, err := cidr.ReadSet(file)
+	if err != nil {
+		return nil, false, errcode.Wrap(errcode.Of(err), fmt.Errorf("set @%s (%s): %w", name, path, err))
+	}
+	return set, true, nil
+}
@@ -0,0 +1,103 @@
+package prefixset
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/store"
+)
+
+// useTempStore points the package at a fresh store for one test
+func useTempStore(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	original := openStore
+	t.Cleanup(func() { openStore = original })
+	openStore = func() (store.Store, error) { return store.OpenBackend(store.BackendJSON, dir) }
+}
+
+func mustParse(t *testing.T, entries ...string) *cidr.Set {
+	t.Helper()
+	set, err := cidr.ParseSet(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+func TestPutGetListDelete(t *testing.T) {
+	useTempStore(t)
+
+	if err := Put("corp", mustParse(t, "10.0.0.0/16", "10.1.0.0/16")); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+	if err := Put("lab", mustParse(t, "192.0.2.0/24")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Put("bad-name", mustParse(t, "192.0.2.0/24")); errcode.Of(err) != errcode.CLIUsage {
+		t.Fatalf("expected a usage error for a name @ cannot spell, got %v", err)
+	}
+
+	set, found, err := Get("corp")
+	if err != nil || !found || !slices.Equal(set.Strings(), []string{"10.0.0.0/15"}) {
+		t.Fatalf("Get = %v, %v, %v", set, found, err)
+	}
+	if _, found, err := Get("missing"); found || err != nil {
+		t.Fatalf("expected a missing set to be reported, got %v, %v", found, err)
+	}
+
+	summaries, err := List()
+	if err != nil || len(summaries) != 2 || summaries[0].Name != "corp" || summaries[0].Prefixes != 1 || summaries[1].Name != "lab" {
+		t.Fatalf("List = %+v, %v", summaries, err)
+	}
+
+	if deleted, err := Delete("corp"); !deleted || err != nil {
+		t.Fatalf("Delete = %v, %v", deleted, err)
+	}
+	if deleted, err := Delete("corp"); deleted || err != nil {
+		t.Fatalf("second Delete = %v, %v", deleted, err)
+	}
+}
+
+func TestResolverOrder(t *testing.T) {
+	useTempStore(t)
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	override := write("override.list", "192.0.2.0/24\n")
+	write("corp.txt", "198.51.100.0/24\n")
+	write("lab", "203.0.113.0/24\n")
+	if err := Put("corp", mustParse(t, "10.0.0.0/8")); err != nil {
+		t.Fatal(err)
+	}
+
+	resolve := Resolver(map[string]string{"pinned": override}, dir)
+	tests := map[string]string{
+		"pinned": "192.0.2.0/24", // --set file
+		"corp":   "10.0.0.0/8",   // Stored set beats corp.txt
+		"lab":    "203.0.113.0/24",
+	}
+	for name, want := range tests {
+		set, err := resolve(name)
+		if err != nil || !slices.Equal(set.Strings(), []string{want}) {
+			t.Errorf("resolve(%q) = %v, %v; want %s", name, set, err, want)
+		}
+	}
+
+	if _, err := resolve("missing"); errcode.Of(err) != errcode.CIDRInvalidExpr {
+		t.Fatalf("expected CIDR006 for an unknown set, got %v", err)
+	}
+	if _, err := Resolver(map[string]string{"gone": filepath.Join(dir, "nope")}, dir)("gone"); errcode.Of(err) != errcode.CIDRInvalidExpr {
+		t.Fatalf("expected CIDR006 for a missing --set file, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// binaryName is the executable inside every release archive
+const binaryName = "cidrator"
+
+// ArchiveName returns the name of the release archive for a platform, as the
+// GoReleaser name template writes it: cidrator_Linux_x86_64.tar.gz. goarm is
+// the ARM version for GOARCH=arm and ignored otherwise.
+func ArchiveName(goos, goarch, goarm string) string {
+	arch := goarch
+	switch goarch {
+	case "amd64":
+		arch = "x86_64"
+	case "386":
+		arch = "i386"
+	case "arm":
+		arch = "armv" + goarm
+	}
+	return fmt.Sprintf("%s_%s_%s.tar.gz", binaryName, strings.ToUpper(goos[:1])+goos[1:], arch)
+}
+
+// CurrentArchiveName returns the archive name for the running binary
+func CurrentArchiveName() string {
+	goarm := ""
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "GOARM" {
+				goarm = setting.Value
+			}
+		}
+	}
+	return ArchiveName(runtime.GOOS, runtime.GOARCH, goarm)
+}
+
+// Verify checks the signature over checksums, then that archive has the
+// SHA-256 checksums lists for name, and returns the trusted comment of the
+// signature
+func Verify(key PublicKey, checksums, signature, archive []byte, name string) (string, error) {
+	comment, err := key.Verify(checksums, signature)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ChecksumsAsset, err)
+	}
+
+	want, err := checksumFor(checksums, name)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(archive)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return "", errcode.Errorf(errcode.CLISelfUpdate, "%s: checksum %s does not match %s", name, got, want)
+	}
+	return comment, nil
+}
+
+// checksumFor finds name in a sha256sum listing
+func checksumFor(checksums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", errcode.Errorf(errcode.CLISelfUpdate, "%s does not list %s", ChecksumsAsset, name)
+}
+
+// ExtractBinary returns the cidrator executable from a release archive
+func ExtractBinary(archive []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, errcode.Wrap(errcode.CLISelfUpdate, fmt.Errorf("read archive: %w", err))
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, errcode.Errorf(errcode.CLISelfUpdate, "archive has no %s binary", binaryName)
+		}
+		if err != nil {
+			return nil, errcode.Wrap(errcode.CLISelfUpdate, fmt.Errorf("read archive: %w", err))
+		}
+		if header.Typeflag == tar.TypeReg && filepath.Base(header.Name) == binaryName {
+			data, err := io.ReadAll(io.LimitReader(tr, maxAssetSize))
+			if err != nil {
+				return nil, errcode.Wrap(errcode.CLISelfUpdate, fmt.Errorf("read archive: %w", err))
+			}
+			return data, nil
+		}
+	}
+}
+
+// Install replaces the executable at path with binary. The new binary is
+// written beside the old one and renamed over it, so path always holds a
+// complete executable. check then runs against the installed binary; if it
+// fails, the old binary is put back and the error returned.
+func Install(ctx context.Context, path string, binary []byte, check func(ctx context.Context, path string) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return errcode.Wrap(errcode.CLISelfUpdate, err)
+	}
+
+	dir, base := filepath.Split(path)
+	tmp, err := os.CreateTemp(dir, "."+base+".new-*")
+	if err != nil {
+		return errcode.Wrap(errcode.CLISelfUpdate, fmt.Errorf("cannot write to %s: %w", dir, err))
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(binary); err != nil {
+		_ = tmp.Close()
+		return errcode.Wrap(errcode.CLISelfUpdate, err)
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		_ = tmp.Close()
+		return errcode.Wrap(errcode.CLISelfUpdate, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errcode.Wrap(errcode.CLISelfUpdate, err)
+	}
+
+	backup := path + ".old"
+	if err := os.Rename(path, backup); err != nil {
+		return errcode.Wrap(errcode.CLISelfUpdate, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return rollback(path, backup, err)
+	}
+	if check != nil {
+		if err := check(ctx, path); err != nil {
+			return rollback(path, backup, fmt.Errorf("new binary failed its check: %w", err))
+		}
+	}
+	_ = os.Remove(backup)
+	return nil
+}
+
+// rollback moves backup back to path after a failed install
+func rollback(path, backup string, cause error) error {
+	if err := os.Rename(backup, path); err != nil {
+		return errcode.Wrap(errcode.CLISelfUpdate, fmt.Errorf("%w; restoring the previous binary also failed, it is at %s: %v", cause, backup, err))
+	}
+	return errcode.Wrap(errcode.CLISelfUpdate, fmt.Errorf("%w; the previous binary was restored", cause))
+}
@@ -0,0 +1,94 @@
+package selfupdate
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// minisignAlgorithm marks a key or a legacy signature over the message
+// itself. Prehashed signatures ("ED", minisign's default since 0.10) need
+// BLAKE2b, which the standard library lacks, so releases are signed with
+// minisign -l.
+var minisignAlgorithm = []byte("Ed")
+
+// PublicKey is a minisign Ed25519 public key
+type PublicKey struct {
+	ID  [8]byte
+	Key ed25519.PublicKey
+}
+
+// ParsePublicKey reads a minisign public key, either the contents of a .pub
+// file or just its base64 line
+func ParsePublicKey(text string) (PublicKey, error) {
+	var key PublicKey
+	lines := minisignLines(text)
+	if len(lines) == 2 && strings.HasPrefix(lines[0], "untrusted comment:") {
+		lines = lines[1:]
+	}
+	if len(lines) != 1 {
+		return key, errcode.Errorf(errcode.CLIUsage, "invalid minisign public key: expected one base64 line")
+	}
+	raw, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || !bytes.Equal(raw[:2], minisignAlgorithm) {
+		return key, errcode.Errorf(errcode.CLIUsage, "invalid minisign public key %q", lines[0])
+	}
+	copy(key.ID[:], raw[2:10])
+	key.Key = ed25519.PublicKey(raw[10:])
+	return key, nil
+}
+
+// Verify checks a minisign signature file over message: the signature of the
+// message itself, then the global signature binding the trusted comment to
+// it. It returns the trusted comment.
+func (k PublicKey) Verify(message, signature []byte) (string, error) {
+	lines := minisignLines(string(signature))
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "untrusted comment:") || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return "", errcode.Errorf(errcode.CLISelfUpdate, "invalid minisign signature: expected four lines")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(raw) != 2+8+ed25519.SignatureSize {
+		return "", errcode.Errorf(errcode.CLISelfUpdate, "invalid minisign signature")
+	}
+	switch {
+	case string(raw[:2]) == "ED":
+		return "", errcode.Errorf(errcode.CLISelfUpdate, "prehashed minisign signatures are not supported; sign with minisign -l")
+	case !bytes.Equal(raw[:2], minisignAlgorithm):
+		return "", errcode.Errorf(errcode.CLISelfUpdate, "invalid minisign signature algorithm %q", raw[:2])
+	case !bytes.Equal(raw[2:10], k.ID[:]):
+		return "", errcode.Errorf(errcode.CLISelfUpdate, "signature key ID %016X does not match public key %s", binary.LittleEndian.Uint64(raw[2:10]), k)
+	}
+	sig := raw[10:]
+	if !ed25519.Verify(k.Key, message, sig) {
+		return "", errcode.Errorf(errcode.CLISelfUpdate, "signature verification failed")
+	}
+
+	comment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || !ed25519.Verify(k.Key, append(bytes.Clone(sig), comment...), global) {
+		return "", errcode.Errorf(errcode.CLISelfUpdate, "trusted comment verification failed")
+	}
+	return comment, nil
+}
+
+// String returns the key ID as minisign prints it
+func (k PublicKey) String() string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(k.ID[:]))
+}
+
+// minisignLines splits minisign text into its non-empty lines
+func minisignLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
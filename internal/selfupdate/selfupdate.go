@@ -0,0 +1,240 @@
+// Package selfupdate finds the newest cidrator release on GitHub, verifies its
+// archive against the minisign-signed checksum file published with it, and
+// swaps the running binary for the new one, restoring the old binary when the
+// new one fails to start.
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// Release channels accepted by GitHub.Latest
+const (
+	ChannelStable = "stable" // The latest release that is not a prerelease
+	ChannelEdge   = "edge"   // The newest release, prereleases included
+)
+
+// Release assets that every release publishes alongside its archives
+const (
+	ChecksumsAsset = "checksums.txt"
+	SignatureAsset = "checksums.txt.minisig"
+)
+
+// DefaultRepo is the GitHub repository releases are published to
+const DefaultRepo = "euan-cowie/cidrator"
+
+// maxAssetSize bounds every download, so a misbehaving server cannot fill the
+// disk or memory
+const maxAssetSize = 256 << 20
+
+// Release is a published release and the download URL of each of its assets
+type Release struct {
+	Tag        string
+	Prerelease bool
+	Assets     map[string]string
+}
+
+// Version returns the release tag without its leading v, as `cidrator
+// version` prints it
+func (r *Release) Version() string {
+	return strings.TrimPrefix(r.Tag, "v")
+}
+
+// GitHub reads releases from the GitHub REST API
+type GitHub struct {
+	API    string       // API base URL; empty uses https://api.github.com
+	Repo   string       // owner/name; empty uses DefaultRepo
+	Client *http.Client // nil uses a client with a 60s timeout
+}
+
+type githubRelease struct {
+	TagName    string `json:"tag_name"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+	Assets     []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// Latest returns the newest release on channel
+func (g *GitHub) Latest(ctx context.Context, channel string) (*Release, error) {
+	var release githubRelease
+	switch channel {
+	case ChannelStable:
+		if err := g.getJSON(ctx, "/latest", &release); err != nil {
+			return nil, err
+		}
+	case ChannelEdge:
+		// Releases are listed newest first
+		var releases []githubRelease
+		if err := g.getJSON(ctx, "?per_page=20", &releases); err != nil {
+			return nil, err
+		}
+		for _, r := range releases {
+			if !r.Draft {
+				release = r
+				break
+			}
+		}
+		if release.TagName == "" {
+			return nil, errcode.Errorf(errcode.CLISelfUpdate, "%s has no published releases", g.repo())
+		}
+	default:
+		return nil, errcode.Errorf(errcode.CLIUsage, "invalid channel %q: expected %s or %s", channel, ChannelStable, ChannelEdge)
+	}
+
+	result := &Release{Tag: release.TagName, Prerelease: release.Prerelease, Assets: make(map[string]string)}
+	for _, asset := range release.Assets {
+		result.Assets[asset.Name] = asset.URL
+	}
+	return result, nil
+}
+
+// Download fetches the named asset of release
+func (g *GitHub) Download(ctx context.Context, release *Release, name string) ([]byte, error) {
+	url, ok := release.Assets[name]
+	if !ok {
+		return nil, errcode.Errorf(errcode.CLISelfUpdate, "release %s has no asset %s", release.Tag, name)
+	}
+	resp, err := g.get(ctx, url, "application/octet-stream")
+	if err != nil {
+		return nil, errcode.Wrap(errcode.CLISelfUpdate, fmt.Errorf("download %s: %w", name, err))
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAssetSize+1))
+	if err != nil {
+		return nil, errcode.Wrap(errcode.CLISelfUpdate, fmt.Errorf("download %s: %w", name, err))
+	}
+	if len(data) > maxAssetSize {
+		return nil, errcode.Errorf(errcode.CLISelfUpdate, "download %s: larger than %d MiB", name, maxAssetSize>>20)
+	}
+	return data, nil
+}
+
+// ReleasesURL returns the API endpoint Latest lists releases from
+func (g *GitHub) ReleasesURL() string {
+	api := g.API
+	if api == "" {
+		api = "https://api.github.com"
+	}
+	return strings.TrimSuffix(api, "/") + "/repos/" + g.repo() + "/releases"
+}
+
+func (g *GitHub) getJSON(ctx context.Context, path string, v any) error {
+	endpoint := g.ReleasesURL() + path
+
+	resp, err := g.get(ctx, endpoint, "application/vnd.github+json")
+	if err != nil {
+		return errcode.Wrap(errcode.CLISelfUpdate, fmt.Errorf("list releases of %s: %w", g.repo(), err))
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return errcode.Wrap(errcode.CLISelfUpdate, fmt.Errorf("list releases of %s: invalid response: %w", g.repo(), err))
+	}
+	return nil
+}
+
+// get sends a GET request and returns the response when it is a 2xx
+func (g *GitHub) get(ctx context.Context, url, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+
+	client := g.Client
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("returned %s", resp.Status)
+	}
+	return resp, nil
+}
+
+func (g *GitHub) repo() string {
+	if g.Repo == "" {
+		return DefaultRepo
+	}
+	return g.Repo
+}
+
+// Newer reports whether version a is newer than version b. Both are
+// MAJOR.MINOR.PATCH with an optional v prefix and -prerelease suffix; a
+// prerelease is older than the release it leads up to. Versions that do not
+// parse, such as the "dev" of a source build, are older than any that do.
+func Newer(a, b string) bool {
+	av, apre, aok := parseVersion(a)
+	bv, bpre, bok := parseVersion(b)
+	switch {
+	case !aok || !bok:
+		return aok && !bok
+	case av != bv:
+		for i := range av {
+			if av[i] != bv[i] {
+				return av[i] > bv[i]
+			}
+		}
+	case apre == "" || bpre == "":
+		return apre == "" && bpre != ""
+	}
+	return comparePrerelease(apre, bpre) > 0
+}
+
+func parseVersion(v string) (numbers [3]int, prerelease string, ok bool) {
+	v, _, _ = strings.Cut(strings.TrimPrefix(v, "v"), "+")
+	v, prerelease, _ = strings.Cut(v, "-")
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return numbers, "", false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return numbers, "", false
+		}
+		numbers[i] = n
+	}
+	return numbers, prerelease, true
+}
+
+// comparePrerelease orders dot-separated prerelease identifiers as semver
+// does: numeric identifiers numerically and below alphanumeric ones
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				return an - bn
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return len(as) - len(bs)
+}
@@ -0,0 +1,262 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// testKey signs like minisign -S -l with a fixed key ID
+type testKey struct {
+	id   [8]byte
+	priv ed25519.PrivateKey
+	pub  ed25519.PublicKey
+}
+
+func newTestKey(t *testing.T) testKey {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{id: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}, priv: priv, pub: pub}
+}
+
+func (k testKey) pubFile() string {
+	raw := append(append([]byte("Ed"), k.id[:]...), k.pub...)
+	return "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(raw) + "\n"
+}
+
+func (k testKey) sign(message []byte, comment string) []byte {
+	sig := ed25519.Sign(k.priv, message)
+	global := ed25519.Sign(k.priv, append(bytes.Clone(sig), comment...))
+	raw := append(append([]byte("Ed"), k.id[:]...), sig...)
+	return []byte(fmt.Sprintf("untrusted comment: signature\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(raw), comment, base64.StdEncoding.EncodeToString(global)))
+}
+
+func TestMinisign(t *testing.T) {
+	k := newTestKey(t)
+	key, err := ParsePublicKey(k.pubFile())
+	if err != nil {
+		t.Fatalf("ParsePublicKey returned error: %v", err)
+	}
+	if key.String() != "0807060504030201" {
+		t.Errorf("key ID = %s", key)
+	}
+	// The base64 line alone is accepted too
+	if _, err := ParsePublicKey(strings.Split(k.pubFile(), "\n")[1]); err != nil {
+		t.Errorf("ParsePublicKey(base64 line) returned error: %v", err)
+	}
+	if _, err := ParsePublicKey("not a key"); errcode.Of(err) != errcode.CLIUsage {
+		t.Errorf("expected CLI002 for a bad key, got %v", err)
+	}
+
+	message := []byte("abc  cidrator_Linux_x86_64.tar.gz\n")
+	sig := k.sign(message, "cidrator 1.2.3")
+	comment, err := key.Verify(message, sig)
+	if err != nil || comment != "cidrator 1.2.3" {
+		t.Fatalf("Verify = %q, %v", comment, err)
+	}
+
+	other := newTestKey(t)
+	tampered := bytes.Replace(sig, []byte("cidrator 1.2.3"), []byte("cidrator 9.9.9"), 1)
+	prehashed := bytes.Replace(sig, []byte(strings.Split(string(sig), "\n")[1][:2]), []byte("RU"), 1) // "RU" is base64 for "ED"
+	for name, tc := range map[string]struct {
+		message, sig []byte
+		key          PublicKey
+	}{
+		"changed message": {[]byte("abd"), sig, key},
+		"changed comment": {message, tampered, key},
+		"other key":       {message, other.sign(message, "x"), key},
+		"prehashed":       {message, prehashed, key},
+		"truncated":       {message, sig[:40], key},
+	} {
+		if _, err := tc.key.Verify(tc.message, tc.sig); errcode.Of(err) != errcode.CLISelfUpdate {
+			t.Errorf("%s: expected CLI008, got %v", name, err)
+		}
+	}
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1.2.4", "1.2.3", true},
+		{"v1.10.0", "1.9.9", true},
+		{"1.2.3", "1.2.3", false},
+		{"1.2.3", "1.2.4", false},
+		{"1.2.3", "1.2.3-rc.1", true},
+		{"1.2.3-rc.2", "1.2.3-rc.1", true},
+		{"1.2.3-rc.10", "1.2.3-rc.9", true},
+		{"1.2.3-rc.1", "1.2.3", false},
+		{"1.2.3-beta", "1.2.3-alpha", true},
+		{"0.0.1", "dev", true},
+		{"dev", "0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.a, tt.b); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestArchiveName(t *testing.T) {
+	tests := []struct{ goos, goarch, goarm, want string }{
+		{"linux", "amd64", "", "cidrator_Linux_x86_64.tar.gz"},
+		{"darwin", "arm64", "", "cidrator_Darwin_arm64.tar.gz"},
+		{"linux", "arm", "7", "cidrator_Linux_armv7.tar.gz"},
+		{"freebsd", "amd64", "", "cidrator_Freebsd_x86_64.tar.gz"},
+	}
+	for _, tt := range tests {
+		if got := ArchiveName(tt.goos, tt.goarch, tt.goarm); got != tt.want {
+			t.Errorf("ArchiveName(%s, %s, %s) = %s, want %s", tt.goos, tt.goarch, tt.goarm, got, tt.want)
+		}
+	}
+}
+
+// testArchive builds a release archive holding binary and a README
+func testArchive(t *testing.T, binary []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range map[string][]byte{"README.md": []byte("readme"), binaryName: binary} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestVerifyAndExtract(t *testing.T) {
+	k := newTestKey(t)
+	key, _ := ParsePublicKey(k.pubFile())
+	name := ArchiveName("linux", "amd64", "")
+	archive := testArchive(t, []byte("new binary"))
+	sum := sha256.Sum256(archive)
+	checksums := []byte(fmt.Sprintf("%s  cidrator_Darwin_arm64.tar.gz\n%s  %s\n", strings.Repeat("0", 64), hex.EncodeToString(sum[:]), name))
+	sig := k.sign(checksums, "cidrator 1.2.3")
+
+	if _, err := Verify(key, checksums, sig, archive, name); err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	binary, err := ExtractBinary(archive)
+	if err != nil || string(binary) != "new binary" {
+		t.Fatalf("ExtractBinary = %q, %v", binary, err)
+	}
+
+	if _, err := Verify(key, checksums, sig, append(archive, 0), name); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+	if _, err := Verify(key, checksums, sig, archive, "cidrator_Windows_x86_64.tar.gz"); errcode.Of(err) != errcode.CLISelfUpdate {
+		t.Errorf("expected CLI008 for an unlisted archive, got %v", err)
+	}
+	if _, err := ExtractBinary([]byte("not gzip")); errcode.Of(err) != errcode.CLISelfUpdate {
+		t.Errorf("expected CLI008 for a bad archive, got %v", err)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cidrator")
+	if err := os.WriteFile(path, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failing check puts the old binary back
+	failed := errors.New("exit status 1")
+	err := Install(context.Background(), path, []byte("broken"), func(_ context.Context, p string) error {
+		if data, _ := os.ReadFile(p); string(data) != "broken" {
+			t.Errorf("check ran against %q", data)
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) || errcode.Of(err) != errcode.CLISelfUpdate || !strings.Contains(err.Error(), "restored") {
+		t.Fatalf("Install with a failing check = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "old" {
+		t.Fatalf("binary after rollback = %q, want old", data)
+	}
+
+	if err := Install(context.Background(), path, []byte("new"), nil); err != nil {
+		t.Fatalf("Install returned error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new" || info.Mode().Perm() != 0o755 {
+		t.Fatalf("installed %q with mode %v", data, info.Mode())
+	}
+	// Neither the backup nor the temporary file is left behind
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Fatalf("directory holds %d entries after install", len(entries))
+	}
+}
+
+func TestGitHubLatest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/releases/latest":
+			_, _ = fmt.Fprintf(w, `{"tag_name":"v1.2.0","assets":[{"name":"checksums.txt","browser_download_url":"http://%s/dl/checksums.txt"}]}`, r.Host)
+		case "/repos/o/r/releases":
+			_, _ = fmt.Fprint(w, `[{"tag_name":"v1.3.0","draft":true},{"tag_name":"v1.3.0-rc.1","prerelease":true},{"tag_name":"v1.2.0"}]`)
+		case "/dl/checksums.txt":
+			_, _ = fmt.Fprint(w, "sums")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	gh := &GitHub{API: server.URL, Repo: "o/r"}
+
+	stable, err := gh.Latest(context.Background(), ChannelStable)
+	if err != nil || stable.Version() != "1.2.0" {
+		t.Fatalf("stable release = %+v, %v", stable, err)
+	}
+	data, err := gh.Download(context.Background(), stable, ChecksumsAsset)
+	if err != nil || string(data) != "sums" {
+		t.Fatalf("Download = %q, %v", data, err)
+	}
+	if _, err := gh.Download(context.Background(), stable, SignatureAsset); errcode.Of(err) != errcode.CLISelfUpdate {
+		t.Errorf("expected CLI008 for a missing asset, got %v", err)
+	}
+
+	// Edge skips drafts but takes prereleases
+	edge, err := gh.Latest(context.Background(), ChannelEdge)
+	if err != nil || edge.Tag != "v1.3.0-rc.1" || !edge.Prerelease {
+		t.Fatalf("edge release = %+v, %v", edge, err)
+	}
+
+	if _, err := (&GitHub{API: server.URL, Repo: "o/missing"}).Latest(context.Background(), ChannelStable); errcode.Of(err) != errcode.CLISelfUpdate {
+		t.Errorf("expected CLI008 for a 404, got %v", err)
+	}
+	if _, err := gh.Latest(context.Background(), "nightly"); errcode.Of(err) != errcode.CLIUsage {
+		t.Errorf("expected CLI002 for an unknown channel, got %v", err)
+	}
+}
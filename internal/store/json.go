@@ -0,0 +1,179 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// jsonStore keeps each namespace in <dir>/<namespace>.json. Writers take an
+// exclusive lock on <namespace>.lock so concurrent cidrator processes do not
+// lose each other's updates, and replace the file by rename so readers never
+// see a partial write.
+type jsonStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func openJSON(dir string) *jsonStore {
+	return &jsonStore{dir: dir}
+}
+
+func (s *jsonStore) path(namespace string) string {
+	return filepath.Join(s.dir, namespace+".json")
+}
+
+// withLock runs fn holding both the in-process and the cross-process lock
+func (s *jsonStore) withLock(namespace string, fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lock, err := os.OpenFile(filepath.Join(s.dir, namespace+".lock"), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return errcode.Errorf(errcode.CLIStore, "store %s: %w", namespace, err)
+	}
+	defer func() { _ = lock.Close() }()
+	if err := lockFile(lock); err != nil {
+		return errcode.Errorf(errcode.CLIStore, "store %s: lock: %w", namespace, err)
+	}
+	defer func() { _ = unlockFile(lock) }()
+	return fn()
+}
+
+// load reads every record in namespace, expired or not
+func (s *jsonStore) load(namespace string) (map[string]Record, error) {
+	data, err := os.ReadFile(s.path(namespace))
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]Record{}, nil
+	}
+	if err != nil {
+		return nil, errcode.Errorf(errcode.CLIStore, "store %s: %w", namespace, err)
+	}
+	records := map[string]Record{}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, errcode.Errorf(errcode.CLIStore, "store %s: %s is corrupt: %w", namespace, s.path(namespace), err)
+	}
+	return records, nil
+}
+
+// save drops expired records and atomically replaces the namespace file
+func (s *jsonStore) save(namespace string, records map[string]Record) error {
+	current := now()
+	for key, record := range records {
+		if record.expired(current) {
+			delete(records, key)
+		}
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return errcode.Errorf(errcode.CLIStore, "store %s: %w", namespace, err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "."+namespace+"-*.json")
+	if err != nil {
+		return errcode.Errorf(errcode.CLIStore, "store %s: %w", namespace, err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return errcode.Errorf(errcode.CLIStore, "store %s: %w", namespace, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errcode.Errorf(errcode.CLIStore, "store %s: %w", namespace, err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(namespace)); err != nil {
+		return errcode.Errorf(errcode.CLIStore, "store %s: %w", namespace, err)
+	}
+	return nil
+}
+
+func (s *jsonStore) Get(namespace, key string, v any) (bool, error) {
+	if err := validate(namespace, key); err != nil {
+		return false, err
+	}
+	var record Record
+	found := false
+	err := s.withLock(namespace, func() error {
+		records, err := s.load(namespace)
+		if err != nil {
+			return err
+		}
+		record, found = records[key]
+		return nil
+	})
+	if err != nil || !found || record.expired(now()) {
+		return false, err
+	}
+	return true, decode(record, v)
+}
+
+func (s *jsonStore) Put(namespace, key string, v any, ttl time.Duration) error {
+	if err := validate(namespace, key); err != nil {
+		return err
+	}
+	record, err := newRecord(key, v, ttl)
+	if err != nil {
+		return err
+	}
+	return s.withLock(namespace, func() error {
+		records, err := s.load(namespace)
+		if err != nil {
+			return err
+		}
+		records[key] = record
+		return s.save(namespace, records)
+	})
+}
+
+func (s *jsonStore) Delete(namespace, key string) (bool, error) {
+	if err := validate(namespace, key); err != nil {
+		return false, err
+	}
+	existed := false
+	err := s.withLock(namespace, func() error {
+		records, err := s.load(namespace)
+		if err != nil {
+			return err
+		}
+		record, ok := records[key]
+		if !ok {
+			return nil
+		}
+		existed = !record.expired(now())
+		delete(records, key)
+		return s.save(namespace, records)
+	})
+	return existed, err
+}
+
+func (s *jsonStore) List(namespace string) ([]Record, error) {
+	if err := validate(namespace, "-"); err != nil {
+		return nil, err
+	}
+	var list []Record
+	err := s.withLock(namespace, func() error {
+		records, err := s.load(namespace)
+		if err != nil {
+			return err
+		}
+		current := now()
+		for _, record := range records {
+			if !record.expired(current) {
+				list = append(list, record)
+			}
+		}
+		return nil
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list, err
+}
+
+func (s *jsonStore) Close() error {
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package store
+
+import "os"
+
+// lockFile is a no-op where the platform has no advisory locks; the
+// in-process mutex still serializes writers within one cidrator process
+func lockFile(*os.File) error {
+	return nil
+}
+
+func unlockFile(*os.File) error {
+	return nil
+}
@@ -0,0 +1,138 @@
+//go:build !minimal
+
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	_ "modernc.org/sqlite" // Pure Go driver, so builds stay cgo-free
+)
+
+// sqliteSupported reports whether this build includes the SQLite backend
+const sqliteSupported = true
+
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS records (
+	namespace TEXT NOT NULL,
+	key       TEXT NOT NULL,
+	value     BLOB NOT NULL,
+	updated   INTEGER NOT NULL,
+	expires   INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (namespace, key)
+)`
+
+// sqliteStore keeps every namespace in one database. SQLite's own locking
+// covers concurrent processes; busy_timeout makes writers wait rather than fail.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func openSQLite(path string) (Store, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, errcode.Errorf(errcode.CLIStore, "store %s: %w", path, err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		_ = db.Close()
+		return nil, errcode.Errorf(errcode.CLIStore, "store %s: %w", path, err)
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) Get(namespace, key string, v any) (bool, error) {
+	if err := validate(namespace, key); err != nil {
+		return false, err
+	}
+	record := Record{Key: key}
+	var updated, expires int64
+	err := s.db.QueryRow(`SELECT value, updated, expires FROM records WHERE namespace = ? AND key = ? AND (expires = 0 OR expires > ?)`,
+		namespace, key, now().UnixNano()).Scan(&record.Value, &updated, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errcode.Errorf(errcode.CLIStore, "store %s: %w", namespace, err)
+	}
+	return true, decode(record, v)
+}
+
+func (s *sqliteStore) Put(namespace, key string, v any, ttl time.Duration) error {
+	if err := validate(namespace, key); err != nil {
+		return err
+	}
+	record, err := newRecord(key, v, ttl)
+	if err != nil {
+		return err
+	}
+	var expires int64
+	if !record.Expires.IsZero() {
+		expires = record.Expires.UnixNano()
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errcode.Errorf(errcode.CLIStore, "store %s: %w", namespace, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(`DELETE FROM records WHERE namespace = ? AND expires != 0 AND expires <= ?`, namespace, record.Updated.UnixNano()); err != nil {
+		return errcode.Errorf(errcode.CLIStore, "store %s: %w", namespace, err)
+	}
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO records (namespace, key, value, updated, expires) VALUES (?, ?, ?, ?, ?)`,
+		namespace, key, []byte(record.Value), record.Updated.UnixNano(), expires); err != nil {
+		return errcode.Errorf(errcode.CLIStore, "store %s: %w", namespace, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return errcode.Errorf(errcode.CLIStore, "store %s: %w", namespace, err)
+	}
+	return nil
+}
+
+func (s *sqliteStore) Delete(namespace, key string) (bool, error) {
+	if err := validate(namespace, key); err != nil {
+		return false, err
+	}
+	result, err := s.db.Exec(`DELETE FROM records WHERE namespace = ? AND key = ? AND (expires = 0 OR expires > ?)`, namespace, key, now().UnixNano())
+	if err != nil {
+		return false, errcode.Errorf(errcode.CLIStore, "store %s: %w", namespace, err)
+	}
+	deleted, _ := result.RowsAffected()
+	return deleted > 0, nil
+}
+
+func (s *sqliteStore) List(namespace string) ([]Record, error) {
+	if err := validate(namespace, "-"); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT key, value, updated, expires FROM records WHERE namespace = ? AND (expires = 0 OR expires > ?) ORDER BY key`,
+		namespace, now().UnixNano())
+	if err != nil {
+		return nil, errcode.Errorf(errcode.CLIStore, "store %s: %w", namespace, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var list []Record
+	for rows.Next() {
+		var record Record
+		var value []byte
+		var updated, expires int64
+		if err := rows.Scan(&record.Key, &value, &updated, &expires); err != nil {
+			return nil, errcode.Errorf(errcode.CLIStore, "store %s: %w", namespace, err)
+		}
+		record.Value = value
+		record.Updated = time.Unix(0, updated).UTC()
+		if expires != 0 {
+			record.Expires = time.Unix(0, expires).UTC()
+		}
+		list = append(list, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errcode.Errorf(errcode.CLIStore, "store %s: %w", namespace, err)
+	}
+	return list, nil
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,13 @@
+//go:build minimal
+
+package store
+
+import "github.com/euan-cowie/cidrator/internal/errcode"
+
+// sqliteSupported is false in minimal builds, which leave out the SQLite driver
+const sqliteSupported = false
+
+// openSQLite is unavailable in minimal builds, which leave out the SQLite driver
+func openSQLite(string) (Store, error) {
+	return nil, errcode.Errorf(errcode.CLIUsage, "the %s store backend is not included in minimal builds; use %s", BackendSQLite, BackendJSON)
+}
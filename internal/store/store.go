@@ -0,0 +1,139 @@
+// Package store persists state that cidrator features keep between runs, such
+// as caches and named sets. Every feature goes through the same Store so state
+// location, locking, and expiry are handled in one place.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// Backends that Open understands
+const (
+	BackendJSON   = "json"   // One JSON file per namespace
+	BackendSQLite = "sqlite" // A single SQLite database
+)
+
+// Record is one stored value
+type Record struct {
+	Key     string          `json:"key"`
+	Value   json.RawMessage `json:"value"`
+	Updated time.Time       `json:"updated"`
+	Expires time.Time       `json:"expires,omitempty"` // Zero means the record is kept until deleted
+}
+
+// expired reports whether the record's retention has run out at now
+func (r Record) expired(now time.Time) bool {
+	return !r.Expires.IsZero() && !now.Before(r.Expires)
+}
+
+// Store is a namespaced key-value store. Namespaces separate features (for
+// example "pmtu-cache" and "prefix-sets"); keys are unique within a namespace.
+// Expired records are never returned and are removed on the next write.
+// Implementations are safe for concurrent use by goroutines and processes.
+type Store interface {
+	// Get decodes the value stored under key into v and reports whether it existed
+	Get(namespace, key string, v any) (bool, error)
+	// Put stores v under key. A ttl of 0 keeps the record until it is deleted.
+	Put(namespace, key string, v any, ttl time.Duration) error
+	// Delete removes key, reporting whether it existed
+	Delete(namespace, key string) (bool, error)
+	// List returns the live records in namespace ordered by key
+	List(namespace string) ([]Record, error)
+	// Close releases the store's resources
+	Close() error
+}
+
+// Configured location and backend; set from the config file and flags
+var (
+	configuredBackend = BackendJSON
+	configuredDir     string
+)
+
+// now is the clock used for expiry, replaced in tests
+var now = time.Now
+
+// Configure selects the backend and state directory used by Open. Empty
+// values keep the defaults.
+func Configure(backend, dir string) {
+	configuredBackend = BackendJSON
+	if backend != "" {
+		configuredBackend = backend
+	}
+	configuredDir = dir
+}
+
+// Dir returns the configured state directory, defaulting to a cidrator
+// directory under the user's configuration directory
+func Dir() (string, error) {
+	if configuredDir != "" {
+		return configuredDir, nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", errcode.Errorf(errcode.CLIStore, "state directory: %w (set state-dir in the config file or pass --state-dir)", err)
+	}
+	return filepath.Join(base, "cidrator"), nil
+}
+
+// Open opens the configured store, creating the state directory if needed
+func Open() (Store, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	return OpenBackend(configuredBackend, dir)
+}
+
+// OpenBackend opens a store of the given backend in dir
+func OpenBackend(backend, dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, errcode.Errorf(errcode.CLIStore, "state directory: %w", err)
+	}
+	switch backend {
+	case BackendJSON:
+		return openJSON(dir), nil
+	case BackendSQLite:
+		return openSQLite(filepath.Join(dir, "state.db"))
+	default:
+		return nil, errcode.Errorf(errcode.CLIUsage, "unsupported store backend %q (use %s or %s)", backend, BackendJSON, BackendSQLite)
+	}
+}
+
+// validate rejects names that cannot be used as file names or are empty
+func validate(namespace, key string) error {
+	if namespace == "" || strings.ContainsAny(namespace, `/\.`) {
+		return errcode.Errorf(errcode.CLIStore, "invalid store namespace %q", namespace)
+	}
+	if key == "" {
+		return errcode.Wrap(errcode.CLIStore, errors.New("store key must not be empty"))
+	}
+	return nil
+}
+
+// newRecord encodes v for storage
+func newRecord(key string, v any, ttl time.Duration) (Record, error) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return Record{}, errcode.Errorf(errcode.CLIStore, "store %s: %w", key, err)
+	}
+	record := Record{Key: key, Value: value, Updated: now().UTC()}
+	if ttl > 0 {
+		record.Expires = record.Updated.Add(ttl)
+	}
+	return record, nil
+}
+
+// decode unmarshals a stored value into v
+func decode(record Record, v any) error {
+	if err := json.Unmarshal(record.Value, v); err != nil {
+		return errcode.Errorf(errcode.CLIStore, "store %s: %w", record.Key, err)
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+type cachedPMTU struct {
+	PMTU int    `json:"pmtu"`
+	Via  string `json:"via"`
+}
+
+func openTestStore(t *testing.T, backend string) Store {
+	t.Helper()
+	s, err := OpenBackend(backend, t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenBackend(%s) returned error: %v", backend, err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// setClock pins the expiry clock for the duration of a test
+func setClock(t *testing.T, at *time.Time) {
+	t.Helper()
+	original := now
+	t.Cleanup(func() { now = original })
+	now = func() time.Time { return *at }
+}
+
+// testBackends lists the backends compiled into this build
+func testBackends() []string {
+	if !sqliteSupported {
+		return []string{BackendJSON}
+	}
+	return []string{BackendJSON, BackendSQLite}
+}
+
+func forEachBackend(t *testing.T, fn func(t *testing.T, s Store)) {
+	for _, backend := range testBackends() {
+		t.Run(backend, func(t *testing.T) {
+			fn(t, openTestStore(t, backend))
+		})
+	}
+}
+
+func TestPutGetDeleteList(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		if err := s.Put("pmtu-cache", "example.com", cachedPMTU{PMTU: 1400, Via: "icmp"}, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Put("pmtu-cache", "a.example.com", cachedPMTU{PMTU: 1500}, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Put("other", "example.com", cachedPMTU{PMTU: 9000}, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		var got cachedPMTU
+		if found, err := s.Get("pmtu-cache", "example.com", &got); err != nil || !found || got != (cachedPMTU{PMTU: 1400, Via: "icmp"}) {
+			t.Fatalf("Get = %+v, %v, %v", got, found, err)
+		}
+		if found, err := s.Get("pmtu-cache", "missing", &got); err != nil || found {
+			t.Fatalf("expected missing key, got found=%v err=%v", found, err)
+		}
+
+		records, err := s.List("pmtu-cache")
+		if err != nil || len(records) != 2 || records[0].Key != "a.example.com" || records[1].Key != "example.com" {
+			t.Fatalf("List = %+v, %v", records, err)
+		}
+
+		if deleted, err := s.Delete("pmtu-cache", "example.com"); err != nil || !deleted {
+			t.Fatalf("Delete = %v, %v", deleted, err)
+		}
+		if deleted, err := s.Delete("pmtu-cache", "example.com"); err != nil || deleted {
+			t.Fatalf("second Delete = %v, %v", deleted, err)
+		}
+		if found, _ := s.Get("other", "example.com", &got); !found || got.PMTU != 9000 {
+			t.Fatalf("namespaces should be independent, got %+v", got)
+		}
+	})
+}
+
+func TestExpiry(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := start
+	setClock(t, &clock)
+
+	forEachBackend(t, func(t *testing.T, s Store) {
+		clock = start
+		if err := s.Put("liveness", "10.0.0.1", true, time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Put("liveness", "10.0.0.2", true, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		var alive bool
+		if found, _ := s.Get("liveness", "10.0.0.1", &alive); !found {
+			t.Fatal("expected record before its TTL runs out")
+		}
+
+		clock = start.Add(2 * time.Minute)
+		if found, _ := s.Get("liveness", "10.0.0.1", &alive); found {
+			t.Fatal("expired record should not be returned")
+		}
+		if records, _ := s.List("liveness"); len(records) != 1 || records[0].Key != "10.0.0.2" {
+			t.Fatalf("List should skip expired records, got %+v", records)
+		}
+		if deleted, _ := s.Delete("liveness", "10.0.0.1"); deleted {
+			t.Fatal("deleting an expired record should report it missing")
+		}
+	})
+}
+
+func TestJSONStorePrunesExpiredOnWrite(t *testing.T) {
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	setClock(t, &clock)
+	dir := t.TempDir()
+	s := openJSON(dir)
+
+	if err := s.Put("liveness", "10.0.0.1", true, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	clock = clock.Add(time.Hour)
+	if err := s.Put("liveness", "10.0.0.2", true, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := s.load("liveness")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := records["10.0.0.1"]; ok || len(records) != 1 {
+		t.Fatalf("expired record should be removed from disk, got %+v", records)
+	}
+	if leftovers, _ := filepath.Glob(filepath.Join(dir, ".liveness-*")); len(leftovers) != 0 {
+		t.Fatalf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	for _, backend := range testBackends() {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			// Separate handles on the same directory stand in for separate processes
+			var wg sync.WaitGroup
+			for i := range 4 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					s, err := OpenBackend(backend, dir)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					defer func() { _ = s.Close() }()
+					for j := range 10 {
+						if err := s.Put("sets", fmt.Sprintf("w%d-%d", i, j), j, 0); err != nil {
+							t.Error(err)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			s, err := OpenBackend(backend, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = s.Close() }()
+			records, err := s.List("sets")
+			if err != nil || len(records) != 40 {
+				t.Fatalf("expected 40 records from concurrent writers, got %d (%v)", len(records), err)
+			}
+		})
+	}
+}
+
+func TestInvalidInput(t *testing.T) {
+	s := openTestStore(t, BackendJSON)
+	for _, namespace := range []string{"", "../etc", "a/b"} {
+		if err := s.Put(namespace, "k", 1, 0); errcode.Of(err) != errcode.CLIStore {
+			t.Errorf("Put(%q) = %v, want CLI006", namespace, err)
+		}
+	}
+	if err := s.Put("ok", "", 1, 0); errcode.Of(err) != errcode.CLIStore {
+		t.Errorf("empty key = %v, want CLI006", err)
+	}
+	if _, err := OpenBackend("redis", t.TempDir()); errcode.Of(err) != errcode.CLIUsage {
+		t.Errorf("unknown backend = %v, want CLI002", err)
+	}
+}
+
+func TestCorruptJSONStore(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "sets.json"), []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s := openJSON(dir)
+	var v int
+	if _, err := s.Get("sets", "k", &v); errcode.Of(err) != errcode.CLIStore {
+		t.Fatalf("expected CLI006 for a corrupt file, got %v", err)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	t.Cleanup(func() { Configure("", "") })
+
+	dir := t.TempDir()
+	Configure(BackendSQLite, dir)
+	s, err := Open()
+	if !sqliteSupported {
+		if errcode.Of(err) != errcode.CLIUsage {
+			t.Fatalf("expected minimal builds to reject the sqlite backend, got %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Close()
+	if _, err := os.Stat(filepath.Join(dir, "state.db")); err != nil {
+		t.Fatalf("expected sqlite database in configured dir: %v", err)
+	}
+	if got, _ := Dir(); got != dir {
+		t.Fatalf("Dir() = %q, want %q", got, dir)
+	}
+}
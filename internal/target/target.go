@@ -0,0 +1,242 @@
+// Package target reads whatever a user pastes where a command expects a CIDR
+// or an address. A bare IP stands for its /32 or /128, a start-end range for
+// the blocks covering it, and a hostname for the address it resolves to.
+// Strict mode turns the leniency off so only the expected form is accepted.
+package target
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/audit"
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/family"
+	"github.com/euan-cowie/cidrator/internal/offline"
+)
+
+// Kinds of input recognized by Classify
+const (
+	KindAddr     = "address"
+	KindPrefix   = "cidr"
+	KindRange    = "range"
+	KindHostname = "hostname"
+)
+
+// ResolveTimeout bounds each hostname lookup
+const ResolveTimeout = 5 * time.Second
+
+var (
+	strict bool
+	server string
+)
+
+// lookupNetIP resolves a hostname; tests replace it
+var lookupNetIP = func(ctx context.Context, host string) ([]netip.Addr, error) {
+	return newResolver(server).LookupNetIP(ctx, "ip", host)
+}
+
+// Configure sets whether input is strict and the DNS server (host or
+// host:port) hostnames are resolved through; an empty server means the
+// system resolver
+func Configure(strictInput bool, dnsServer string) {
+	strict = strictInput
+	server = dnsServer
+}
+
+// Strict reports whether lenient input is turned off
+func Strict() bool {
+	return strict
+}
+
+// Classify reports which kind of input s is without resolving anything
+func Classify(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case isPrefix(s):
+		return KindPrefix, nil
+	case isAddr(s):
+		return KindAddr, nil
+	case isRange(s):
+		return KindRange, nil
+	case isHostname(s):
+		return KindHostname, nil
+	}
+	return "", errcode.Errorf(errcode.CLIUsage, "%q is not an address, CIDR, range, or hostname", s)
+}
+
+// Prefix reads s where one CIDR is expected. A bare address becomes its /32
+// or /128, and a range becomes the one block it spans exactly.
+func Prefix(s string) (netip.Prefix, error) {
+	prefixes, err := Prefixes(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if len(prefixes) != 1 {
+		return netip.Prefix{}, errcode.Errorf(errcode.CIDRInvalid, "range %s is not a single CIDR block: it needs %d (%s)", strings.TrimSpace(s), len(prefixes), joinPrefixes(prefixes))
+	}
+	return prefixes[0], nil
+}
+
+// Prefixes reads s where a list of CIDRs is expected, expanding a range into
+// the fewest blocks that cover it
+func Prefixes(s string) ([]netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if prefix, err := netip.ParsePrefix(s); err == nil {
+		return []netip.Prefix{prefix}, nil
+	}
+
+	kind, err := Classify(s)
+	if err != nil || kind == KindHostname {
+		return nil, cidr.NewCIDRError("parse", s, cidr.ErrInvalidCIDR)
+	}
+	if strict {
+		return nil, errcode.Errorf(errcode.CIDRInvalid, "%q is not a CIDR and --strict-input is set", s)
+	}
+
+	if kind == KindAddr {
+		addr := netip.MustParseAddr(s).WithZone("")
+		return []netip.Prefix{netip.PrefixFrom(addr, addr.BitLen())}, nil
+	}
+	start, end, _ := strings.Cut(s, "-")
+	blocks, err := cidr.RangeToCIDRs(start, end)
+	if err != nil {
+		return nil, err
+	}
+	prefixes := make([]netip.Prefix, len(blocks))
+	for i, block := range blocks {
+		prefixes[i] = netip.MustParsePrefix(block)
+	}
+	return prefixes, nil
+}
+
+// Addr reads s where an address is expected. A hostname is resolved and its
+// first address used, preferring the version of --prefer-family, and a /32
+// or /128 is its address.
+func Addr(ctx context.Context, s string) (netip.Addr, error) {
+	s = strings.TrimSpace(s)
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr, nil
+	}
+
+	kind, err := Classify(s)
+	if err != nil || kind == KindRange {
+		return netip.Addr{}, cidr.NewValidationError("ip", s, cidr.ErrInvalidIP)
+	}
+	if strict {
+		return netip.Addr{}, errcode.Errorf(errcode.CIDRInvalidIP, "%q is not an address and --strict-input is set", s)
+	}
+
+	if kind == KindPrefix {
+		prefix := netip.MustParsePrefix(s)
+		if !prefix.IsSingleIP() {
+			return netip.Addr{}, errcode.Errorf(errcode.CIDRInvalidIP, "%s is a network of more than one address, not an address", s)
+		}
+		return prefix.Addr(), nil
+	}
+	return resolve(ctx, s)
+}
+
+// AuditLookup records, under command, the DNS lookup Addr sends for s in the
+// audit log. Input Addr reads without the network is not recorded.
+func AuditLookup(command, s string) error {
+	s = strings.TrimSpace(s)
+	if kind, _ := Classify(s); kind != KindHostname || strict || offline.Enabled() {
+		return nil
+	}
+	return audit.Record(audit.Entry{Command: command, Targets: []string{s}, Protocol: "dns"})
+}
+
+// resolve looks up a hostname's addresses and returns the first one of the
+// preferred IP version, or the first one when it has none of that version
+func resolve(ctx context.Context, host string) (netip.Addr, error) {
+	if err := offline.Check("resolving " + host); err != nil {
+		return netip.Addr{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, ResolveTimeout)
+	defer cancel()
+
+	addrs, err := lookupNetIP(ctx, host)
+	if err != nil {
+		var dnsErr *net.DNSError
+		switch {
+		case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+			return netip.Addr{}, errcode.Wrap(errcode.DNSNXDomain, fmt.Errorf("failed to resolve %s: %w", host, err))
+		case errors.As(err, &dnsErr) && dnsErr.IsTimeout, errors.Is(err, context.DeadlineExceeded):
+			return netip.Addr{}, errcode.Wrap(errcode.DNSTimeout, fmt.Errorf("failed to resolve %s: %w", host, err))
+		}
+		return netip.Addr{}, errcode.Wrap(errcode.DNSQueryFailed, fmt.Errorf("failed to resolve %s: %w", host, err))
+	}
+	if len(addrs) == 0 {
+		return netip.Addr{}, errcode.Errorf(errcode.DNSNXDomain, "failed to resolve %s: no addresses", host)
+	}
+	return family.Pick(addrs), nil
+}
+
+// newResolver returns the system resolver, or one that sends every query
+// to dnsServer
+func newResolver(dnsServer string) *net.Resolver {
+	if dnsServer == "" {
+		return net.DefaultResolver
+	}
+	if _, _, err := net.SplitHostPort(dnsServer); err != nil {
+		dnsServer = net.JoinHostPort(dnsServer, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, dnsServer)
+		},
+	}
+}
+
+func isPrefix(s string) bool {
+	_, err := netip.ParsePrefix(s)
+	return err == nil
+}
+
+func isAddr(s string) bool {
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
+
+// isRange matches start-end with an address on both sides. IPv6 addresses
+// have no hyphens, so the first one splits the two.
+func isRange(s string) bool {
+	start, end, ok := strings.Cut(s, "-")
+	return ok && isAddr(strings.TrimSpace(start)) && isAddr(strings.TrimSpace(end))
+}
+
+// isHostname matches dot-separated labels of letters, digits, and hyphens,
+// with an optional trailing dot
+func isHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func joinPrefixes(prefixes []netip.Prefix) string {
+	blocks := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		blocks[i] = prefix.String()
+	}
+	return strings.Join(blocks, ", ")
+}
@@ -0,0 +1,175 @@
+package target
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/internal/audit"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+	"github.com/euan-cowie/cidrator/internal/family"
+	"github.com/euan-cowie/cidrator/internal/offline"
+)
+
+func TestClassify(t *testing.T) {
+	tests := map[string]string{
+		"10.0.0.0/8":                KindPrefix,
+		"2001:db8::/48":             KindPrefix,
+		"192.0.2.1":                 KindAddr,
+		"fe80::1%eth0":              KindAddr,
+		"10.0.0.1-10.0.0.9":         KindRange,
+		"2001:db8:: - 2001:db8::ff": KindRange,
+		"example.com":               KindHostname,
+		"edge-1.example.net.":       KindHostname,
+		"localhost":                 KindHostname,
+	}
+	for input, want := range tests {
+		if kind, err := Classify(input); err != nil || kind != want {
+			t.Errorf("Classify(%q) = %q, %v, want %q", input, kind, err, want)
+		}
+	}
+
+	for _, input := range []string{"", "10.0.0.0/33", "-bad.example", "a..b", "two words"} {
+		if kind, err := Classify(input); errcode.Of(err) != errcode.CLIUsage {
+			t.Errorf("Classify(%q) = %q, %v, want CLI002", input, kind, err)
+		}
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	t.Cleanup(func() { Configure(false, "") })
+
+	tests := map[string]string{
+		"10.0.0.5/24":             "10.0.0.5/24",
+		"192.0.2.1":               "192.0.2.1/32",
+		"2001:db8::1":             "2001:db8::1/128",
+		"10.0.0.0-10.0.0.255":     "10.0.0.0/24",
+		" 10.0.1.0 - 10.0.1.127 ": "10.0.1.0/25",
+	}
+	for input, want := range tests {
+		prefix, err := Prefix(input)
+		if err != nil || prefix.String() != want {
+			t.Errorf("Prefix(%q) = %s, %v, want %s", input, prefix, err, want)
+		}
+	}
+
+	if _, err := Prefix("10.0.0.1-10.0.0.3"); errcode.Of(err) != errcode.CIDRInvalid {
+		t.Errorf("a range of several blocks should be CIDR001, got %v", err)
+	}
+	if _, err := Prefix("example.com"); errcode.Of(err) != errcode.CIDRInvalid {
+		t.Errorf("a hostname should be CIDR001, got %v", err)
+	}
+	prefixes, err := Prefixes("10.0.0.1-10.0.0.3")
+	if err != nil || len(prefixes) != 2 || prefixes[0].String() != "10.0.0.1/32" || prefixes[1].String() != "10.0.0.2/31" {
+		t.Errorf("Prefixes(range) = %v, %v", prefixes, err)
+	}
+
+	Configure(true, "")
+	if prefix, err := Prefix("10.0.0.0/8"); err != nil || prefix.String() != "10.0.0.0/8" {
+		t.Errorf("strict input should still accept a CIDR, got %s, %v", prefix, err)
+	}
+	for _, input := range []string{"192.0.2.1", "10.0.0.0-10.0.0.255"} {
+		if _, err := Prefix(input); errcode.Of(err) != errcode.CIDRInvalid {
+			t.Errorf("strict Prefix(%q) should be CIDR001, got %v", input, err)
+		}
+	}
+}
+
+func TestAddr(t *testing.T) {
+	originalLookup := lookupNetIP
+	t.Cleanup(func() {
+		lookupNetIP = originalLookup
+		Configure(false, "")
+		_ = family.Configure(family.Auto)
+	})
+	_ = family.Configure(family.IPv4)
+	lookupNetIP = func(ctx context.Context, host string) ([]netip.Addr, error) {
+		switch host {
+		case "dual.example":
+			return []netip.Addr{netip.MustParseAddr("2001:db8::5"), netip.MustParseAddr("::ffff:192.0.2.5")}, nil
+		case "v6.example":
+			return []netip.Addr{netip.MustParseAddr("2001:db8::6")}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	ctx := context.Background()
+
+	tests := map[string]string{
+		"192.0.2.1":       "192.0.2.1",
+		"192.0.2.1/32":    "192.0.2.1",
+		"2001:db8::1/128": "2001:db8::1",
+		"dual.example":    "192.0.2.5",
+		"v6.example":      "2001:db8::6",
+	}
+	for input, want := range tests {
+		addr, err := Addr(ctx, input)
+		if err != nil || addr.String() != want {
+			t.Errorf("Addr(%q) = %s, %v, want %s", input, addr, err, want)
+		}
+	}
+
+	errs := map[string]errcode.Code{
+		"10.0.0.0/24":       errcode.CIDRInvalidIP,
+		"10.0.0.1-10.0.0.2": errcode.CIDRInvalidIP,
+		"not an ip":         errcode.CIDRInvalidIP,
+		"missing.example":   errcode.DNSNXDomain,
+	}
+	for input, want := range errs {
+		if _, err := Addr(ctx, input); errcode.Of(err) != want {
+			t.Errorf("Addr(%q) error = %v, want %s", input, err, want)
+		}
+	}
+
+	// As on an IPv6-only host, where prefer-family auto picks IPv6
+	_ = family.Configure(family.IPv6)
+	if addr, err := Addr(ctx, "dual.example"); err != nil || addr.String() != "2001:db8::5" {
+		t.Errorf("Addr(dual.example) preferring IPv6 = %s, %v, want 2001:db8::5", addr, err)
+	}
+
+	Configure(true, "")
+	if _, err := Addr(ctx, "dual.example"); errcode.Of(err) != errcode.CIDRInvalidIP {
+		t.Errorf("strict input should not resolve hostnames, got %v", err)
+	}
+
+	Configure(false, "")
+	offline.Configure(true)
+	defer offline.Configure(false)
+	if _, err := Addr(ctx, "dual.example"); errcode.Of(err) != errcode.CLIOffline {
+		t.Errorf("offline mode should not resolve hostnames, got %v", err)
+	}
+	if addr, err := Addr(ctx, "192.0.2.1"); err != nil || addr.String() != "192.0.2.1" {
+		t.Errorf("offline Addr(192.0.2.1) = %s, %v", addr, err)
+	}
+}
+
+func TestAuditLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	audit.SetPath(path)
+	t.Cleanup(func() {
+		audit.SetPath("")
+		Configure(false, "")
+		offline.Configure(false)
+	})
+
+	for _, input := range []string{"192.0.2.1", "192.0.2.1/32", " edge.example ", "not a host"} {
+		if err := AuditLookup("cidrator cidr info", input); err != nil {
+			t.Fatalf("AuditLookup(%q) returned error: %v", input, err)
+		}
+	}
+	Configure(true, "")
+	_ = AuditLookup("cidrator cidr info", "strict.example")
+	Configure(false, "")
+	offline.Configure(true)
+	_ = AuditLookup("cidrator cidr info", "offline.example")
+
+	entries, err := audit.ReadAll(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Command != "cidrator cidr info" || entries[0].Protocol != "dns" ||
+		len(entries[0].Targets) != 1 || entries[0].Targets[0] != "edge.example" {
+		t.Fatalf("only the hostname lookup should be audited, got %+v", entries)
+	}
+}
@@ -0,0 +1,317 @@
+// Package targets lists watch targets from service discovery or a targets
+// file, so a long-running watch can follow a fleet that changes without a
+// restart.
+package targets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+// Source kinds accepted by Parse
+const (
+	KindPrometheusHTTPSD = "prometheus-http-sd"
+	KindConsul           = "consul"
+	KindFile             = "file"
+)
+
+// defaultConsulAddr is used when neither addr= nor CONSUL_HTTP_ADDR is set
+const defaultConsulAddr = "127.0.0.1:8500"
+
+// Source lists the current members of a fleet as host or host:port strings
+type Source interface {
+	Targets(ctx context.Context) ([]string, error)
+	String() string
+}
+
+// Parse reads a --targets-from value:
+//
+//	prometheus-http-sd:<URL>
+//	consul:service=<name>[,tag=<tag>][,dc=<datacenter>][,addr=<host:port or URL>]
+//	file:<PATH>
+func Parse(spec string) (Source, error) {
+	kind, rest, ok := strings.Cut(spec, ":")
+	if !ok {
+		return nil, errcode.Errorf(errcode.CLIUsage, "invalid target source %q: expected %s:<URL>, %s:service=<name>, or %s:<PATH>", spec, KindPrometheusHTTPSD, KindConsul, KindFile)
+	}
+
+	switch kind {
+	case KindPrometheusHTTPSD:
+		u, err := url.Parse(rest)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errcode.Errorf(errcode.CLIUsage, "invalid %s URL %q: expected http:// or https://", KindPrometheusHTTPSD, rest)
+		}
+		return &PrometheusHTTPSD{URL: rest}, nil
+	case KindConsul:
+		return parseConsul(rest)
+	case KindFile:
+		if rest == "" {
+			return nil, errcode.Errorf(errcode.CLIUsage, "invalid target source %q: expected %s:<PATH>", spec, KindFile)
+		}
+		return &File{Path: rest}, nil
+	default:
+		return nil, errcode.Errorf(errcode.CLIUsage, "unknown target source %q: expected %s, %s, or %s", kind, KindPrometheusHTTPSD, KindConsul, KindFile)
+	}
+}
+
+func parseConsul(options string) (*Consul, error) {
+	consul := &Consul{Addr: os.Getenv("CONSUL_HTTP_ADDR"), Token: os.Getenv("CONSUL_HTTP_TOKEN")}
+	for _, option := range strings.Split(options, ",") {
+		key, value, ok := strings.Cut(option, "=")
+		if !ok || value == "" {
+			return nil, errcode.Errorf(errcode.CLIUsage, "invalid consul option %q: expected key=value", option)
+		}
+		switch key {
+		case "service":
+			consul.Service = value
+		case "tag":
+			consul.Tag = value
+		case "dc":
+			consul.Datacenter = value
+		case "addr":
+			consul.Addr = value
+		default:
+			return nil, errcode.Errorf(errcode.CLIUsage, "unknown consul option %q: expected service, tag, dc, or addr", key)
+		}
+	}
+	if consul.Service == "" {
+		return nil, errcode.Errorf(errcode.CLIUsage, "consul target source needs service=<name>")
+	}
+	if consul.Addr == "" {
+		consul.Addr = defaultConsulAddr
+	}
+	if !strings.Contains(consul.Addr, "://") {
+		consul.Addr = "http://" + consul.Addr
+	}
+	return consul, nil
+}
+
+// PrometheusHTTPSD reads a Prometheus HTTP service discovery endpoint: a JSON
+// array of target groups, each with a "targets" list
+type PrometheusHTTPSD struct {
+	URL    string
+	Client *http.Client // nil uses a client with a 10s timeout
+}
+
+// Targets fetches the endpoint and flattens every group's targets
+func (p *PrometheusHTTPSD) Targets(ctx context.Context) ([]string, error) {
+	var groups []struct {
+		Targets []string          `json:"targets"`
+		Labels  map[string]string `json:"labels"`
+	}
+	if err := getJSON(ctx, p.Client, p.URL, nil, &groups); err != nil {
+		return nil, errcode.Wrap(errcode.CLITargetDiscovery, fmt.Errorf("%s: %w", p, err))
+	}
+
+	var targets []string
+	for _, group := range groups {
+		targets = append(targets, group.Targets...)
+	}
+	return targets, nil
+}
+
+func (p *PrometheusHTTPSD) String() string {
+	return KindPrometheusHTTPSD + ":" + p.URL
+}
+
+// Consul lists the passing instances of a service from the Consul health API
+type Consul struct {
+	Addr       string // Agent base URL
+	Service    string
+	Tag        string
+	Datacenter string
+	Token      string       // ACL token, from CONSUL_HTTP_TOKEN
+	Client     *http.Client // nil uses a client with a 10s timeout
+}
+
+// Targets returns host:port for every instance passing its health checks,
+// using the node address when the service does not register its own
+func (c *Consul) Targets(ctx context.Context) ([]string, error) {
+	header := http.Header{}
+	if c.Token != "" {
+		header.Set("X-Consul-Token", c.Token)
+	}
+
+	var entries []struct {
+		Node struct {
+			Address string `json:"Address"`
+		} `json:"Node"`
+		Service struct {
+			Address string `json:"Address"`
+			Port    int    `json:"Port"`
+		} `json:"Service"`
+	}
+	if err := getJSON(ctx, c.Client, c.Endpoint(), header, &entries); err != nil {
+		return nil, errcode.Wrap(errcode.CLITargetDiscovery, fmt.Errorf("%s: %w", c, err))
+	}
+
+	var targets []string
+	for _, entry := range entries {
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		if host == "" {
+			continue
+		}
+		if entry.Service.Port > 0 {
+			host = net.JoinHostPort(host, strconv.Itoa(entry.Service.Port))
+		}
+		targets = append(targets, host)
+	}
+	return targets, nil
+}
+
+// Endpoint returns the health API URL Targets fetches
+func (c *Consul) Endpoint() string {
+	query := url.Values{"passing": {"true"}}
+	if c.Tag != "" {
+		query.Set("tag", c.Tag)
+	}
+	if c.Datacenter != "" {
+		query.Set("dc", c.Datacenter)
+	}
+	return strings.TrimSuffix(c.Addr, "/") + "/v1/health/service/" + url.PathEscape(c.Service) + "?" + query.Encode()
+}
+
+func (c *Consul) String() string {
+	s := KindConsul + ":service=" + c.Service
+	if c.Tag != "" {
+		s += ",tag=" + c.Tag
+	}
+	if c.Datacenter != "" {
+		s += ",dc=" + c.Datacenter
+	}
+	return s
+}
+
+// File reads targets from a file, one per line. Blank lines and text after #
+// are ignored. The file is read again on every refresh, so editing it changes
+// the fleet.
+type File struct {
+	Path string
+}
+
+// Targets reads the file
+func (f *File) Targets(ctx context.Context) ([]string, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, errcode.Wrap(errcode.CLITargetDiscovery, fmt.Errorf("%s: %w", f, err))
+	}
+	var targets []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		if line = strings.TrimSpace(line); line != "" {
+			targets = append(targets, line)
+		}
+	}
+	return targets, nil
+}
+
+func (f *File) String() string {
+	return KindFile + ":" + f.Path
+}
+
+// Sources asks several sources in turn
+type Sources []Source
+
+// Targets concatenates every source's targets, failing if any source fails
+func (s Sources) Targets(ctx context.Context) ([]string, error) {
+	var targets []string
+	for _, source := range s {
+		found, err := source.Targets(ctx)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, found...)
+	}
+	return targets, nil
+}
+
+// Endpoints returns the URLs the sources fetch on every refresh, with any
+// password redacted; files are read locally and left out
+func (s Sources) Endpoints() []string {
+	var endpoints []string
+	for _, source := range s {
+		var endpoint string
+		switch source := source.(type) {
+		case *PrometheusHTTPSD:
+			endpoint = source.URL
+		case *Consul:
+			endpoint = source.Endpoint()
+		default:
+			continue
+		}
+		if u, err := url.Parse(endpoint); err == nil {
+			endpoint = u.Redacted()
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	return endpoints
+}
+
+func (s Sources) String() string {
+	names := make([]string, len(s))
+	for i, source := range s {
+		names[i] = source.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// Hosts drops the port from host:port targets and removes duplicates, keeping
+// the first-seen order
+func Hosts(targets []string) []string {
+	seen := make(map[string]bool, len(targets))
+	hosts := make([]string, 0, len(targets))
+	for _, target := range targets {
+		host := strings.TrimSpace(target)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// getJSON fetches endpoint and decodes a 2xx JSON body into v
+func getJSON(ctx context.Context, client *http.Client, endpoint string, header http.Header, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// Package units parses the human-friendly sizes, durations, and bit rates
+// accepted by command-line flags, such as --max 9k, --interval 5m, and
+// --rate 2mbps.
+//
+// The flag values it registers report the pflag types of the plain flags
+// they replace (int, duration, and float64), so commands keep reading them
+// with GetInt, GetDuration, and GetFloat64.
+package units
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/pflag"
+)
+
+// Size multipliers: SI for k, M, and G, binary for Ki, Mi, and Gi
+var sizeUnits = map[string]float64{
+	"": 1, "b": 1,
+	"k": 1e3, "kb": 1e3, "ki": 1 << 10, "kib": 1 << 10,
+	"m": 1e6, "mb": 1e6, "mi": 1 << 20, "mib": 1 << 20,
+	"g": 1e9, "gb": 1e9, "gi": 1 << 30, "gib": 1 << 30,
+}
+
+// Rate multipliers, all in bits per second
+var rateUnits = map[string]float64{
+	"": 1, "bps": 1, "bit": 1,
+	"k": 1e3, "kbps": 1e3, "kbit": 1e3,
+	"m": 1e6, "mbps": 1e6, "mbit": 1e6,
+	"g": 1e9, "gbps": 1e9, "gbit": 1e9,
+}
+
+// ParseSize parses a byte count: a plain number such as 1500, or one with
+// a unit such as 9k (9000), 1.5M, or 64KiB (65536). Units are case
+// insensitive and the result must be a whole number of bytes.
+func ParseSize(s string) (int, error) {
+	number, unit, err := splitUnit(s)
+	if err != nil {
+		return 0, err
+	}
+	multiplier, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q in %q: use bytes, k, M, or G (1000), or Ki, Mi, or Gi (1024)", unit, s)
+	}
+	size := number * multiplier
+	if size < 0 {
+		return 0, fmt.Errorf("size %q is negative", s)
+	}
+	if size > math.MaxInt32 {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	if size != math.Trunc(size) {
+		return 0, fmt.Errorf("size %q is not a whole number of bytes", s)
+	}
+	return int(size), nil
+}
+
+// ParseDuration parses a Go duration such as 1500ms, 5m, or 1h30m. A plain
+// number is taken as seconds, so 30 means 30s.
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+	seconds, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+		return 0, fmt.Errorf("invalid duration %q: use a number with a unit such as 1500ms, 30s, 5m, or 1h, or plain seconds", s)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// ParseRate parses a bit rate in bits per second: a plain number, or one
+// with a unit such as 500kbps, 2mbps, or 1.5gbit. Units are case insensitive
+// and always mean bits, not bytes.
+func ParseRate(s string) (float64, error) {
+	number, unit, err := splitUnit(s)
+	if err != nil {
+		return 0, err
+	}
+	unit = strings.TrimSuffix(unit, "/s")
+	multiplier, ok := rateUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown rate unit %q in %q: use bps, kbps, mbps, or gbps", unit, s)
+	}
+	if number < 0 {
+		return 0, fmt.Errorf("rate %q is negative", s)
+	}
+	return number * multiplier, nil
+}
+
+// splitUnit splits "1.5kb" into 1.5 and "kb"
+func splitUnit(s string) (float64, string, error) {
+	s = strings.TrimSpace(s)
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.' && r != '-' && r != '+'
+	})
+	if end < 0 {
+		end = len(s)
+	}
+	number, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil || math.IsInf(number, 0) {
+		return 0, "", fmt.Errorf("invalid number in %q", s)
+	}
+	return number, strings.ToLower(strings.TrimSpace(s[end:])), nil
+}
+
+// Size defines an int flag that also accepts units, such as --max 9k
+func Size(flags *pflag.FlagSet, name string, value int, usage string) {
+	v := sizeValue(value)
+	flags.Var(&v, name, usage)
+}
+
+// Duration defines a duration flag that also accepts plain seconds
+func Duration(flags *pflag.FlagSet, name string, value time.Duration, usage string) {
+	v := durationValue(value)
+	flags.Var(&v, name, usage)
+}
+
+// Rate defines a float64 flag of bits per second that accepts units, such
+// as --rate 2mbps
+func Rate(flags *pflag.FlagSet, name string, value float64, usage string) {
+	v := rateValue(value)
+	flags.Var(&v, name, usage)
+}
+
+type sizeValue int
+
+func (v *sizeValue) Set(s string) error {
+	size, err := ParseSize(s)
+	if err != nil {
+		return err
+	}
+	*v = sizeValue(size)
+	return nil
+}
+
+func (v *sizeValue) String() string { return strconv.Itoa(int(*v)) }
+func (v *sizeValue) Type() string   { return "int" }
+
+type durationValue time.Duration
+
+func (v *durationValue) Set(s string) error {
+	d, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+func (v *durationValue) Type() string   { return "duration" }
+
+type rateValue float64
+
+func (v *rateValue) Set(s string) error {
+	rate, err := ParseRate(s)
+	if err != nil {
+		return err
+	}
+	*v = rateValue(rate)
+	return nil
+}
+
+func (v *rateValue) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+func (v *rateValue) Type() string   { return "float64" }
@@ -0,0 +1,151 @@
+package units
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int
+		wantErr string
+	}{
+		{input: "1500", want: 1500},
+		{input: "9k", want: 9000},
+		{input: "9K", want: 9000},
+		{input: "9kB", want: 9000},
+		{input: "1.5k", want: 1500},
+		{input: "64KiB", want: 65536},
+		{input: "2Mi", want: 2 << 20},
+		{input: "1G", want: 1_000_000_000},
+		{input: "576B", want: 576},
+		{input: " 1280 ", want: 1280},
+		{input: "9x", wantErr: `unknown size unit "x"`},
+		{input: "k", wantErr: "invalid number"},
+		{input: "1.0005k", wantErr: "not a whole number of bytes"},
+		{input: "-1", wantErr: "negative"},
+		{input: "4G", wantErr: "too large"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSize(tt.input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseSize(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{input: "1500ms", want: 1500 * time.Millisecond},
+		{input: "5m", want: 5 * time.Minute},
+		{input: "1h30m", want: 90 * time.Minute},
+		{input: "30", want: 30 * time.Second},
+		{input: "0.5", want: 500 * time.Millisecond},
+		{input: "0", want: 0},
+		{input: "5 minutes", wantErr: true},
+		{input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDuration(tt.input)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "such as 1500ms") {
+					t.Fatalf("expected an invalid duration error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseDuration(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    float64
+		wantErr string
+	}{
+		{input: "64000", want: 64000},
+		{input: "500kbps", want: 500_000},
+		{input: "2mbps", want: 2_000_000},
+		{input: "2Mbps", want: 2_000_000},
+		{input: "2mbit", want: 2_000_000},
+		{input: "2Mbit/s", want: 2_000_000},
+		{input: "1.5gbps", want: 1_500_000_000},
+		{input: "2mph", wantErr: `unknown rate unit "mph"`},
+		{input: "-1mbps", wantErr: "negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseRate(tt.input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseRate(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlagsReadBackAsPlainTypes(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	Size(flags, "max", 9216, "")
+	Duration(flags, "timeout", 2*time.Second, "")
+	Rate(flags, "rate", 0, "")
+
+	if err := flags.Parse([]string{"--max", "9k", "--timeout", "1500ms", "--rate", "2mbps"}); err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	if got, err := flags.GetInt("max"); err != nil || got != 9000 {
+		t.Errorf("GetInt(max) = %d, %v", got, err)
+	}
+	if got, err := flags.GetDuration("timeout"); err != nil || got != 1500*time.Millisecond {
+		t.Errorf("GetDuration(timeout) = %v, %v", got, err)
+	}
+	if got, err := flags.GetFloat64("rate"); err != nil || got != 2_000_000 {
+		t.Errorf("GetFloat64(rate) = %v, %v", got, err)
+	}
+	if got := flags.Lookup("max").DefValue; got != "9216" {
+		t.Errorf("unexpected --max default in help: %q", got)
+	}
+
+	err := flags.Parse([]string{"--max", "nine"})
+	if err == nil || !strings.Contains(err.Error(), `invalid argument "nine" for "--max"`) {
+		t.Fatalf("expected a flag error naming --max, got %v", err)
+	}
+}
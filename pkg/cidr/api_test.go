@@ -0,0 +1,131 @@
+package cidr_test
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/pkg/cidr"
+)
+
+// exportedAPI lists the exported fields of typ, with their types and tags,
+// and the methods of *typ
+func exportedAPI(typ reflect.Type) []string {
+	var api []string
+	if typ.Kind() == reflect.Struct {
+		for i := range typ.NumField() {
+			if field := typ.Field(i); field.IsExported() {
+				api = append(api, fmt.Sprintf("%s %s `%s`", field.Name, field.Type, field.Tag))
+			}
+		}
+	}
+	ptr := reflect.PointerTo(typ)
+	for i := range ptr.NumMethod() {
+		method := ptr.Method(i)
+		api = append(api, fmt.Sprintf("%s %s", method.Name, method.Type))
+	}
+	return api
+}
+
+// TestExportedTypesAreStable pins the types this package aliases from
+// internal/cidr, so a change there cannot alter the public API unnoticed.
+// Adding a field or method is compatible: extend the list. Removing or
+// changing one needs a new major version.
+func TestExportedTypesAreStable(t *testing.T) {
+	for _, tt := range []struct {
+		value any
+		want  []string
+	}{
+		{cidr.NetworkInfo{}, []string{
+			"Prefix netip.Prefix ``",
+			"Network *net.IPNet ``",
+			"IP net.IP ``",
+			"BaseAddress net.IP ``",
+			"BroadcastAddr net.IP ``",
+			"FirstUsable net.IP ``",
+			"LastUsable net.IP ``",
+			"Netmask net.IP ``",
+			"HostMask net.IP ``",
+			"WildcardMask net.IP ``",
+			"HexNetmask string ``",
+			"BinaryPrefix string ``",
+			"PrefixLength int ``",
+			"HostBits int ``",
+			"TotalAddresses *big.Int ``",
+			"UsableAddresses *big.Int ``",
+			"IsIPv6 bool ``",
+			"ToJSON func(*cidr.NetworkInfo) (string, error)",
+			"ToOutput func(*cidr.NetworkInfo) *cidr.NetworkInfoOutput",
+			"ToYAML func(*cidr.NetworkInfo) (string, error)",
+		}},
+		{cidr.NetworkInfoOutput{}, []string{
+			"BaseAddress string `json:\"base_address\" yaml:\"base_address\"`",
+			"BroadcastAddr string `json:\"broadcast_address,omitempty\" yaml:\"broadcast_address,omitempty\"`",
+			"FirstUsable string `json:\"first_usable\" yaml:\"first_usable\"`",
+			"LastUsable string `json:\"last_usable\" yaml:\"last_usable\"`",
+			"Netmask string `json:\"netmask\" yaml:\"netmask\"`",
+			"HostMask string `json:\"host_mask,omitempty\" yaml:\"host_mask,omitempty\"`",
+			"WildcardMask string `json:\"wildcard_mask,omitempty\" yaml:\"wildcard_mask,omitempty\"`",
+			"HexNetmask string `json:\"hex_netmask\" yaml:\"hex_netmask\"`",
+			"BinaryPrefix string `json:\"binary_prefix\" yaml:\"binary_prefix\"`",
+			"PrefixLength int `json:\"prefix_length\" yaml:\"prefix_length\"`",
+			"HostBits int `json:\"host_bits\" yaml:\"host_bits\"`",
+			"TotalAddresses string `json:\"total_addresses\" yaml:\"total_addresses\"`",
+			"UsableAddresses string `json:\"usable_addresses\" yaml:\"usable_addresses\"`",
+			"IsIPv6 bool `json:\"is_ipv6\" yaml:\"is_ipv6\"`",
+		}},
+		{cidr.ExpansionOptions{}, []string{
+			"Limit int ``",
+			"ExcludeReserved bool ``",
+		}},
+		{cidr.ExpandResult{}, []string{
+			"IP string ``",
+			"Index uint64 ``",
+			"Err error ``",
+		}},
+		{cidr.DivisionOptions{}, []string{
+			"Parts int ``",
+			"Prefix int ``",
+		}},
+		{cidr.AddressInfo{}, []string{
+			"IP string `json:\"ip\" yaml:\"ip\"`",
+			"Version int `json:\"version\" yaml:\"version\"`",
+			"Classes []cidr.AddressClass `json:\"classes\" yaml:\"classes\"`",
+			"Global bool `json:\"global\" yaml:\"global\"`",
+			"EmbeddedIPv4 string `json:\"embedded_ipv4,omitempty\" yaml:\"embedded_ipv4,omitempty\"`",
+			"ReverseName string `json:\"reverse_name\" yaml:\"reverse_name\"`",
+			"ReverseZone string `json:\"reverse_zone\" yaml:\"reverse_zone\"`",
+			"Decimal string `json:\"decimal\" yaml:\"decimal\"`",
+			"Hex string `json:\"hex\" yaml:\"hex\"`",
+			"Binary string `json:\"binary\" yaml:\"binary\"`",
+			"ToJSON func(*cidr.AddressInfo) (string, error)",
+			"ToYAML func(*cidr.AddressInfo) (string, error)",
+		}},
+		{cidr.AddressClass{}, []string{
+			"Name string `json:\"name\" yaml:\"name\"`",
+			"Description string `json:\"description\" yaml:\"description\"`",
+			"Range string `json:\"range\" yaml:\"range\"`",
+			"RFC string `json:\"rfc\" yaml:\"rfc\"`",
+		}},
+		{cidr.CIDRError{}, []string{
+			"Op string ``",
+			"CIDR string ``",
+			"Err error ``",
+			"Error func(*cidr.CIDRError) string",
+			"Unwrap func(*cidr.CIDRError) error",
+		}},
+		{cidr.ValidationError{}, []string{
+			"Field string ``",
+			"Value string ``",
+			"Err error ``",
+			"Error func(*cidr.ValidationError) string",
+			"Unwrap func(*cidr.ValidationError) error",
+		}},
+	} {
+		typ := reflect.TypeOf(tt.value)
+		if got := exportedAPI(typ); !slices.Equal(got, tt.want) {
+			t.Errorf("exported API of %s changed:\ngot  %q\nwant %q", typ, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Package cidr is the public, importable form of cidrator's CIDR math for
+// IPv4 and IPv6: parsing and explaining ranges, containment and overlap
+// checks, expansion, division, aggregation, subtraction, and address
+// classification.
+//
+// This package follows semantic versioning: within a major version, its
+// exported identifiers keep their names, signatures, and documented
+// behavior. The packages under internal/ that implement it carry no such
+// promise and may change in any release.
+//
+// Errors carry the stable codes listed in docs/ERROR_CODES.md, which
+// ErrorCode returns, and the sentinel errors below can be matched with
+// errors.Is.
+package cidr
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/euan-cowie/cidrator/internal/cidr"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+type (
+	// NetworkInfo describes a CIDR range, as returned by ParseCIDR
+	NetworkInfo = cidr.NetworkInfo
+	// NetworkInfoOutput is NetworkInfo in string form for JSON and YAML
+	NetworkInfoOutput = cidr.NetworkInfoOutput
+	// ExpansionOptions configures Expand
+	ExpansionOptions = cidr.ExpansionOptions
+	// ExpandResult is one address, or an error, streamed by Expand
+	ExpandResult = cidr.ExpandResult
+	// DivisionOptions configures Divide
+	DivisionOptions = cidr.DivisionOptions
+	// AddressInfo describes a single address, as returned by Classify
+	AddressInfo = cidr.AddressInfo
+	// AddressClass is a special-purpose block an address belongs to
+	AddressClass = cidr.AddressClass
+	// CIDRError is returned for a CIDR that cannot be parsed or used
+	CIDRError = cidr.CIDRError
+	// ValidationError is returned for an invalid address or option
+	ValidationError = cidr.ValidationError
+)
+
+// Sentinel errors, matched with errors.Is
+var (
+	ErrInvalidCIDR      = cidr.ErrInvalidCIDR
+	ErrInvalidIP        = cidr.ErrInvalidIP
+	ErrTooLarge         = cidr.ErrTooLarge
+	ErrInvalidParts     = cidr.ErrInvalidParts
+	ErrInsufficientBits = cidr.ErrInsufficientBits
+	ErrInvalidPrefix    = cidr.ErrInvalidPrefix
+)
+
+// MaxDivideSubnets is the most subnets Divide returns at once by prefix length
+const MaxDivideSubnets = cidr.MaxDivideSubnets
+
+// ParseCIDR parses a CIDR and returns its base, broadcast, and usable
+// addresses, masks, and address counts. Host bits are ignored, so
+// 10.1.2.3/16 describes 10.1.0.0/16.
+func ParseCIDR(s string) (*NetworkInfo, error) {
+	return cidr.ParseCIDR(s)
+}
+
+// Contains reports whether ip is inside the CIDR range. IPv4-mapped IPv6
+// addresses match IPv4 ranges.
+func Contains(s, ip string) (bool, error) {
+	return cidr.Contains(s, ip)
+}
+
+// Overlaps reports whether two CIDR ranges share any address
+func Overlaps(a, b string) (bool, error) {
+	return cidr.Overlaps(a, b)
+}
+
+// Count returns the number of addresses in a CIDR range
+func Count(s string) (*big.Int, error) {
+	return cidr.Count(s)
+}
+
+// CountAssignable returns the number of addresses in a CIDR range outside the
+// reserved special-purpose blocks, such as loopback, link-local, multicast,
+// and documentation space, that cannot be assigned to hosts
+func CountAssignable(s string) (*big.Int, error) {
+	return cidr.CountAssignable(s)
+}
+
+// Expand streams every address in a CIDR range, in order and in constant
+// memory, skipping the blocks CountAssignable leaves out when
+// opts.ExcludeReserved is set. The channel is closed when the range ends,
+// opts.Limit addresses have been sent, an error is sent, or ctx is cancelled.
+func Expand(ctx context.Context, s string, opts ExpansionOptions) <-chan ExpandResult {
+	return cidr.Expand(ctx, s, opts)
+}
+
+// Divide splits a CIDR range into opts.Parts equal subnets, or into every
+// subnet of length opts.Prefix when that is set. Prefix divisions of more
+// than MaxDivideSubnets subnets fail with ErrTooLarge.
+func Divide(s string, opts DivisionOptions) ([]string, error) {
+	return cidr.Divide(s, opts)
+}
+
+// Aggregate merges CIDRs and addresses into the fewest CIDR blocks covering
+// exactly the same addresses, IPv4 first, each family in address order
+func Aggregate(prefixes []string) ([]string, error) {
+	return cidr.Aggregate(prefixes)
+}
+
+// Subtract removes every exclusion from base and returns the fewest CIDR
+// blocks covering what is left
+func Subtract(base string, excludes []string) ([]string, error) {
+	return cidr.Subtract(base, excludes)
+}
+
+// Classify reports which special-purpose blocks ip belongs to, whether it
+// is globally reachable, its reverse DNS names, and its numeric forms
+func Classify(ip string) (*AddressInfo, error) {
+	return cidr.Classify(ip)
+}
+
+// ErrorCode returns the stable code of an error from this package, such as
+// "CIDR001" for an invalid CIDR, or "ERR000" when it has none
+func ErrorCode(err error) string {
+	return string(errcode.Of(err))
+}
@@ -0,0 +1,55 @@
+package cidr_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/euan-cowie/cidrator/pkg/cidr"
+)
+
+func ExampleParseCIDR() {
+	info, err := cidr.ParseCIDR("192.168.1.0/24")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(info.FirstUsable, info.LastUsable, info.UsableAddresses)
+	// Output: 192.168.1.1 192.168.1.254 254
+}
+
+func ExampleExpand() {
+	for result := range cidr.Expand(context.Background(), "10.0.0.0/30", cidr.ExpansionOptions{}) {
+		if result.Err != nil {
+			panic(result.Err)
+		}
+		fmt.Println(result.IP)
+	}
+	// Output:
+	// 10.0.0.0
+	// 10.0.0.1
+	// 10.0.0.2
+	// 10.0.0.3
+}
+
+func ExampleDivide() {
+	subnets, err := cidr.Divide("10.0.0.0/24", cidr.DivisionOptions{Parts: 4})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(subnets)
+	// Output: [10.0.0.0/26 10.0.0.64/26 10.0.0.128/26 10.0.0.192/26]
+}
+
+func ExampleAggregate() {
+	prefixes, err := cidr.Aggregate([]string{"10.0.1.0/24", "10.0.0.0/24", "10.0.0.128/25"})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(prefixes)
+	// Output: [10.0.0.0/23]
+}
+
+func ExampleErrorCode() {
+	_, err := cidr.ParseCIDR("10.0.0.0/33")
+	fmt.Println(cidr.ErrorCode(err))
+	// Output: CIDR001
+}
@@ -0,0 +1,100 @@
+package dns_test
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/euan-cowie/cidrator/pkg/dns"
+)
+
+// exportedAPI lists the exported fields of typ, with their types and tags,
+// and the methods of *typ
+func exportedAPI(typ reflect.Type) []string {
+	var api []string
+	if typ.Kind() == reflect.Struct {
+		for i := range typ.NumField() {
+			if field := typ.Field(i); field.IsExported() {
+				api = append(api, fmt.Sprintf("%s %s `%s`", field.Name, field.Type, field.Tag))
+			}
+		}
+	}
+	ptr := reflect.PointerTo(typ)
+	for i := range ptr.NumMethod() {
+		method := ptr.Method(i)
+		api = append(api, fmt.Sprintf("%s %s", method.Name, method.Type))
+	}
+	return api
+}
+
+// TestExportedTypesAreStable pins the types this package aliases from
+// internal/dns, so a change there cannot alter the public API unnoticed.
+// Adding a field or method is compatible: extend the list. Removing or
+// changing one needs a new major version.
+func TestExportedTypesAreStable(t *testing.T) {
+	for _, tt := range []struct {
+		value any
+		want  []string
+	}{
+		{dns.LookupOptions{}, []string{
+			"RecordType string ``",
+			"Server string ``",
+			"Timeout time.Duration ``",
+			"PreferIPv6 bool ``",
+			"CheckTTL bool ``",
+			"LowTTL time.Duration ``",
+		}},
+		{dns.DNSResult{}, []string{
+			"Domain string `json:\"-\" yaml:\"-\"`",
+			"QueryType string `json:\"-\" yaml:\"-\"`",
+			"Records []dns.DNSRecord `json:\"-\" yaml:\"-\"`",
+			"QueryTime time.Duration `json:\"-\" yaml:\"-\"`",
+			"Server string `json:\"-\" yaml:\"-\"`",
+			"Statuses []dns.TypeStatus `json:\"-\" yaml:\"-\"`",
+			"TTLWarnings []dns.TTLWarning `json:\"-\" yaml:\"-\"`",
+			"FailedTypes func(*dns.DNSResult) []dns.TypeStatus",
+			"ToJSON func(*dns.DNSResult) (string, error)",
+			"ToYAML func(*dns.DNSResult) (string, error)",
+		}},
+		{dns.DNSRecord{}, []string{
+			"Type string `json:\"type\" yaml:\"type\"`",
+			"Value string `json:\"value\" yaml:\"value\"`",
+			"Priority int `json:\"priority,omitempty\" yaml:\"priority,omitempty\"`",
+		}},
+		{dns.TypeStatus{}, []string{
+			"Type string `json:\"type\" yaml:\"type\"`",
+			"Status string `json:\"status\" yaml:\"status\"`",
+			"Records int `json:\"records\" yaml:\"records\"`",
+			"Error string `json:\"error,omitempty\" yaml:\"error,omitempty\"`",
+			"Failed func(*dns.TypeStatus) bool",
+		}},
+		{dns.TTLWarning{}, []string{
+			"Type string `json:\"type,omitempty\" yaml:\"type,omitempty\"`",
+			"Kind string `json:\"kind\" yaml:\"kind\"`",
+			"TTL uint32 `json:\"ttl\" yaml:\"ttl\"`",
+			"Previous uint32 `json:\"previous,omitempty\" yaml:\"previous,omitempty\"`",
+			"Message string `json:\"message\" yaml:\"message\"`",
+		}},
+		{dns.ReverseResult{}, []string{
+			"IP string `json:\"-\" yaml:\"-\"`",
+			"Hostnames []string `json:\"-\" yaml:\"-\"`",
+			"QueryTime time.Duration `json:\"-\" yaml:\"-\"`",
+			"ToJSON func(*dns.ReverseResult) (string, error)",
+			"ToYAML func(*dns.ReverseResult) (string, error)",
+		}},
+		{dns.DNSError{}, []string{
+			"Operation string ``",
+			"Target string ``",
+			"Err error ``",
+			"Error func(*dns.DNSError) string",
+			"ErrorCode func(*dns.DNSError) errcode.Code",
+			"Unwrap func(*dns.DNSError) error",
+		}},
+	} {
+		typ := reflect.TypeOf(tt.value)
+		if got := exportedAPI(typ); !slices.Equal(got, tt.want) {
+			t.Errorf("exported API of %s changed:\ngot  %q\nwant %q", typ, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Package dns is the public, importable form of cidrator's DNS lookups:
+// forward queries for common record types through the system resolver or a
+// chosen server, and reverse (PTR) lookups.
+//
+// This package follows semantic versioning: within a major version, its
+// exported identifiers keep their names, signatures, and documented
+// behavior. The packages under internal/ that implement it carry no such
+// promise and may change in any release.
+//
+// Errors carry the stable codes listed in docs/ERROR_CODES.md, which
+// ErrorCode returns, and the sentinel errors below can be matched with
+// errors.Is.
+package dns
+
+import (
+	"time"
+
+	"github.com/euan-cowie/cidrator/internal/dns"
+	"github.com/euan-cowie/cidrator/internal/errcode"
+)
+
+type (
+	// LookupOptions configures Lookup
+	LookupOptions = dns.LookupOptions
+	// DNSResult holds the records returned by Lookup
+	DNSResult = dns.DNSResult
+	// DNSRecord is a single record in a DNSResult
+	DNSRecord = dns.DNSRecord
+	// TypeStatus is the outcome of one record type in an ALL lookup
+	TypeStatus = dns.TypeStatus
+	// TTLWarning flags a low or dropping TTL, reported with LookupOptions.CheckTTL
+	TTLWarning = dns.TTLWarning
+	// ReverseResult holds the names returned by ReverseLookup
+	ReverseResult = dns.ReverseResult
+	// DNSError is returned for a failed lookup
+	DNSError = dns.DNSError
+)
+
+// Record types Lookup can query
+const (
+	RecordTypeA     = dns.RecordTypeA
+	RecordTypeAAAA  = dns.RecordTypeAAAA
+	RecordTypeMX    = dns.RecordTypeMX
+	RecordTypeTXT   = dns.RecordTypeTXT
+	RecordTypeCNAME = dns.RecordTypeCNAME
+	RecordTypeNS    = dns.RecordTypeNS
+	RecordTypeALL   = dns.RecordTypeALL
+)
+
+// Per-type outcomes in TypeStatus.Status
+const (
+	LookupStatusOK       = dns.LookupStatusOK
+	LookupStatusNXDomain = dns.LookupStatusNXDomain
+	LookupStatusTimeout  = dns.LookupStatusTimeout
+	LookupStatusServFail = dns.LookupStatusServFail
+)
+
+// Sentinel errors, matched with errors.Is
+var (
+	ErrEmptyDomain = dns.ErrEmptyDomain
+	ErrEmptyIP     = dns.ErrEmptyIP
+	ErrInvalidIP   = dns.ErrInvalidIP
+	ErrNXDomain    = dns.ErrNXDomain
+	ErrTimeout     = dns.ErrTimeout
+)
+
+// DefaultLookupOptions returns options for an A lookup through the system
+// resolver with a five second timeout
+func DefaultLookupOptions() LookupOptions {
+	return dns.DefaultLookupOptions()
+}
+
+// Lookup queries domain for opts.RecordType. RecordTypeALL queries every
+// type and records how each went in DNSResult.Statuses, so an empty answer
+// can be told apart from a failed query.
+func Lookup(domain string, opts LookupOptions) (*DNSResult, error) {
+	return dns.Lookup(domain, opts)
+}
+
+// ReverseLookup returns the PTR names of an IP address
+func ReverseLookup(ip string, timeout time.Duration) (*ReverseResult, error) {
+	return dns.ReverseLookup(ip, timeout)
+}
+
+// ErrorCode returns the stable code of an error from this package, such as
+// "DNS004" for NXDOMAIN, or "ERR000" when it has none
+func ErrorCode(err error) string {
+	return string(errcode.Of(err))
+}
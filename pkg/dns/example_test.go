@@ -0,0 +1,38 @@
+package dns_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/euan-cowie/cidrator/pkg/dns"
+)
+
+func ExampleLookup() {
+	opts := dns.DefaultLookupOptions()
+	opts.RecordType = dns.RecordTypeMX
+	opts.Server = "1.1.1.1"
+
+	result, err := dns.Lookup("example.com", opts)
+	if err != nil {
+		fmt.Println(dns.ErrorCode(err), err)
+		return
+	}
+	for _, record := range result.Records {
+		fmt.Println(record.Priority, record.Value)
+	}
+}
+
+func ExampleReverseLookup() {
+	result, err := dns.ReverseLookup("8.8.8.8", 5*time.Second)
+	if err != nil {
+		fmt.Println(dns.ErrorCode(err), err)
+		return
+	}
+	fmt.Println(result.Hostnames)
+}
+
+func ExampleErrorCode() {
+	_, err := dns.Lookup("", dns.DefaultLookupOptions())
+	fmt.Println(dns.ErrorCode(err))
+	// Output: DNS001
+}
@@ -0,0 +1,34 @@
+#!/usr/bin/env bash
+
+# Runs every namespace lab against one binary and summarizes the results.
+# Each lab builds and tears down its own namespaces, so one failing lab does
+# not stop the rest. Set LABS to a space-separated subset to run fewer.
+
+set -uo pipefail
+
+SCRIPT_DIR=$(cd -- "$(dirname -- "${BASH_SOURCE[0]}")" && pwd)
+ROOT_DIR=$(cd -- "$SCRIPT_DIR/../.." && pwd)
+BIN_PATH=${1:-"$ROOT_DIR/bin/cidrator"}
+
+LABS=${LABS:-"mtu-namespaces mtu-hop-by-hop mtu-plpmtud-blackhole mtu-impairments"}
+
+passed=()
+failed=()
+for lab in $LABS; do
+	echo "=== $lab"
+	start=$SECONDS
+	if bash "$SCRIPT_DIR/$lab.sh" "$BIN_PATH"; then
+		passed+=("$lab")
+		echo "--- PASS: $lab ($((SECONDS - start))s)"
+	else
+		failed+=("$lab")
+		echo "--- FAIL: $lab ($((SECONDS - start))s)"
+	fi
+done
+
+echo
+echo "${#passed[@]} passed, ${#failed[@]} failed"
+if (( ${#failed[@]} > 0 )); then
+	printf '  %s\n' "${failed[@]}"
+	exit 1
+fi
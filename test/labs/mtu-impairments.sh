@@ -0,0 +1,274 @@
+#!/usr/bin/env bash
+
+set -euo pipefail
+
+SCRIPT_DIR=$(cd -- "$(dirname -- "${BASH_SOURCE[0]}")" && pwd)
+ROOT_DIR=$(cd -- "$SCRIPT_DIR/../.." && pwd)
+BIN_PATH=${1:-"$ROOT_DIR/bin/cidrator"}
+
+EXPECTED_PMTU=${EXPECTED_PMTU:-1400}
+PEER_PORT=${PEER_PORT:-4821}
+CLOSED_PORT=${CLOSED_PORT:-4822}
+PROBE_TIMEOUT=${PROBE_TIMEOUT:-300ms}
+LOSS_PERCENT=${LOSS_PERCENT:-10}
+DELAY_MS=${DELAY_MS:-20}
+RETRIES=${RETRIES:-4}
+
+CLIENT_NS="cidrator-imp-client-$$"
+ROUTER_NS="cidrator-imp-router-$$"
+PEER_NS="cidrator-imp-peer-$$"
+
+CLIENT_IF="imp-cl0"
+ROUTER_CLIENT_IF="imp-rtcl0"
+PEER_IF="imp-pr0"
+ROUTER_PEER_IF="imp-rtpr0"
+
+CLIENT_IP="10.50.0.2/24"
+CLIENT_ADDR="${CLIENT_IP%/*}"
+ROUTER_CLIENT_IP="10.50.0.1/24"
+ROUTER_CLIENT_ADDR="${ROUTER_CLIENT_IP%/*}"
+PEER_IP="10.60.0.2/24"
+PEER_ADDR="${PEER_IP%/*}"
+ROUTER_PEER_IP="10.60.0.1/24"
+ROUTER_PEER_ADDR="${ROUTER_PEER_IP%/*}"
+
+WORK_DIR=$(mktemp -d)
+PEER_LOG="$WORK_DIR/peer.log"
+LAST_OUTPUT=""
+PEER_PID=""
+
+require_command() {
+	if ! command -v "$1" >/dev/null 2>&1; then
+		echo "missing required command: $1" >&2
+		exit 1
+	fi
+}
+
+cleanup() {
+	if [[ -n "$PEER_PID" ]]; then
+		sudo kill "$PEER_PID" >/dev/null 2>&1 || true
+		wait "$PEER_PID" >/dev/null 2>&1 || true
+	fi
+
+	sudo ip netns del "$CLIENT_NS" >/dev/null 2>&1 || true
+	sudo ip netns del "$ROUTER_NS" >/dev/null 2>&1 || true
+	sudo ip netns del "$PEER_NS" >/dev/null 2>&1 || true
+	rm -rf "$WORK_DIR"
+}
+
+print_debug() {
+	echo "Impairments lab failed. Current peer log:" >&2
+	if [[ -f "$PEER_LOG" ]]; then
+		cat "$PEER_LOG" >&2 || true
+	fi
+	if [[ -n "$LAST_OUTPUT" && -f "$LAST_OUTPUT" ]]; then
+		echo "Last discovery output:" >&2
+		cat "$LAST_OUTPUT" >&2 || true
+	fi
+	echo "Client qdiscs:" >&2
+	sudo ip netns exec "$CLIENT_NS" tc -s qdisc show dev "$CLIENT_IF" >&2 || true
+}
+
+wait_for_peer() {
+	local attempts=0
+	while (( attempts < 50 )); do
+		if sudo ip netns exec "$PEER_NS" ss -ltnu | grep -q ":$PEER_PORT\\b"; then
+			return 0
+		fi
+		sleep 0.1
+		attempts=$((attempts + 1))
+	done
+
+	echo "peer endpoint did not start listening on port $PEER_PORT" >&2
+	return 1
+}
+
+# discover NAME ARGS... runs mtu discover from the client namespace with
+# JSON output into $WORK_DIR/NAME.json
+discover() {
+	local name=$1
+	shift
+	LAST_OUTPUT="$WORK_DIR/$name.json"
+
+	# Forget PMTUs learned by earlier runs, so each one measures the path
+	sudo ip netns exec "$CLIENT_NS" ip route flush cache
+	echo "Running $name discovery inside $CLIENT_NS..."
+	sudo ip netns exec "$CLIENT_NS" "$BIN_PATH" mtu discover "$PEER_ADDR" \
+		--min 576 \
+		--max 1600 \
+		--timeout "$PROBE_TIMEOUT" \
+		--pps 0 \
+		--format json \
+		--quiet \
+		"$@" >"$LAST_OUTPUT"
+}
+
+# check_result NAME PROTO [MIN_RTT_MS] asserts the PMTU, that the probe
+# history agrees with it, and optionally a floor under every round trip
+check_result() {
+	python3 - "$WORK_DIR/$1.json" "$2" "$PEER_ADDR" "$EXPECTED_PMTU" "${3:-0}" <<'PY'
+import json
+import sys
+
+path, expected_proto, expected_target, expected_pmtu, min_rtt = sys.argv[1:]
+expected_pmtu = int(expected_pmtu)
+min_rtt = float(min_rtt)
+
+with open(path, "r", encoding="utf-8") as handle:
+    data = json.load(handle)
+
+errors = []
+if data.get("target") != expected_target:
+    errors.append(f'target={data.get("target")} expected {expected_target}')
+if data.get("protocol") != expected_proto:
+    errors.append(f'protocol={data.get("protocol")} expected {expected_proto}')
+if data.get("pmtu") != expected_pmtu:
+    errors.append(f'pmtu={data.get("pmtu")} expected {expected_pmtu}')
+
+history = data.get("probe_history") or []
+answered = [entry["size"] for entry in history if entry.get("received", 0) > 0]
+if not answered or max(answered) != expected_pmtu:
+    errors.append(f"largest answered size in probe_history={max(answered, default=None)} expected {expected_pmtu}")
+if min_rtt and data.get("rtt_min_ms", 0) < min_rtt:
+    errors.append(f'rtt_min_ms={data.get("rtt_min_ms")} expected >= {min_rtt}')
+
+if errors:
+    raise SystemExit("; ".join(errors))
+PY
+}
+
+# drop_frag_needed discards the Fragmentation Needed errors the router
+# sends, turning the bottleneck into a PMTU black hole
+drop_frag_needed() {
+	echo "Dropping ICMP Fragmentation Needed in $ROUTER_NS..."
+	sudo ip netns exec "$ROUTER_NS" iptables -A OUTPUT -p icmp --icmp-type fragmentation-needed -j DROP
+}
+
+restore_frag_needed() {
+	sudo ip netns exec "$ROUTER_NS" iptables -D OUTPUT -p icmp --icmp-type fragmentation-needed -j DROP
+}
+
+add_netem() {
+	echo "Adding ${LOSS_PERCENT}% loss and ${DELAY_MS}ms delay on $CLIENT_IF..."
+	sudo ip netns exec "$CLIENT_NS" tc qdisc add dev "$CLIENT_IF" root netem \
+		delay "${DELAY_MS}ms" loss "${LOSS_PERCENT}%"
+}
+
+expect_port_unreachable() {
+	LAST_OUTPUT="$WORK_DIR/closed-port.log"
+	echo "Running UDP discovery against closed port $CLOSED_PORT and expecting failure..."
+	if sudo ip netns exec "$CLIENT_NS" "$BIN_PATH" mtu discover "$PEER_ADDR" \
+		--proto udp \
+		--port "$CLOSED_PORT" \
+		--min 576 \
+		--max 1600 \
+		--timeout "$PROBE_TIMEOUT" \
+		--quiet >"$LAST_OUTPUT" 2>&1; then
+		echo "UDP discovery against a closed port unexpectedly succeeded" >&2
+		return 1
+	fi
+
+	if ! grep -q "Port Unreachable" "$LAST_OUTPUT"; then
+		echo "UDP discovery against a closed port failed without naming the closed port" >&2
+		return 1
+	fi
+}
+
+trap print_debug ERR
+trap cleanup EXIT
+
+if [[ "$(uname -s)" != "Linux" ]]; then
+	echo "this lab requires Linux network namespaces" >&2
+	exit 1
+fi
+
+require_command sudo
+require_command ip
+require_command ss
+require_command ping
+require_command tc
+require_command iptables
+require_command python3
+
+if ! sudo -n true >/dev/null 2>&1; then
+	echo "this lab requires passwordless sudo" >&2
+	exit 1
+fi
+
+if [[ ! -x "$BIN_PATH" ]]; then
+	echo "binary not found or not executable: $BIN_PATH" >&2
+	exit 1
+fi
+
+echo "Creating MTU impairments lab..."
+sudo ip netns add "$CLIENT_NS"
+sudo ip netns add "$ROUTER_NS"
+sudo ip netns add "$PEER_NS"
+
+sudo ip link add "$CLIENT_IF" type veth peer name "$ROUTER_CLIENT_IF"
+sudo ip link add "$PEER_IF" type veth peer name "$ROUTER_PEER_IF"
+
+sudo ip link set "$CLIENT_IF" netns "$CLIENT_NS"
+sudo ip link set "$ROUTER_CLIENT_IF" netns "$ROUTER_NS"
+sudo ip link set "$PEER_IF" netns "$PEER_NS"
+sudo ip link set "$ROUTER_PEER_IF" netns "$ROUTER_NS"
+
+sudo ip -n "$CLIENT_NS" addr add "$CLIENT_IP" dev "$CLIENT_IF"
+sudo ip -n "$ROUTER_NS" addr add "$ROUTER_CLIENT_IP" dev "$ROUTER_CLIENT_IF"
+sudo ip -n "$PEER_NS" addr add "$PEER_IP" dev "$PEER_IF"
+sudo ip -n "$ROUTER_NS" addr add "$ROUTER_PEER_IP" dev "$ROUTER_PEER_IF"
+
+sudo ip -n "$CLIENT_NS" link set lo up
+sudo ip -n "$ROUTER_NS" link set lo up
+sudo ip -n "$PEER_NS" link set lo up
+
+sudo ip -n "$CLIENT_NS" link set "$CLIENT_IF" mtu 1500 up
+sudo ip -n "$ROUTER_NS" link set "$ROUTER_CLIENT_IF" mtu 1500 up
+sudo ip -n "$PEER_NS" link set "$PEER_IF" mtu 1500 up
+sudo ip -n "$ROUTER_NS" link set "$ROUTER_PEER_IF" mtu "$EXPECTED_PMTU" up
+
+sudo ip -n "$CLIENT_NS" route add "$PEER_ADDR/32" via "$ROUTER_CLIENT_ADDR"
+sudo ip -n "$PEER_NS" route add "$CLIENT_ADDR/32" via "$ROUTER_PEER_ADDR"
+sudo ip netns exec "$ROUTER_NS" sysctl -w net.ipv4.ip_forward=1 >/dev/null
+sudo ip netns exec "$ROUTER_NS" sysctl -w net.ipv4.icmp_ratelimit=0 >/dev/null
+sudo ip netns exec "$PEER_NS" sysctl -w net.ipv4.icmp_ratelimit=0 >/dev/null
+
+echo "Starting advanced peer endpoint in $PEER_NS..."
+sudo ip netns exec "$PEER_NS" "$BIN_PATH" mtu peer \
+	--listen "$PEER_ADDR" \
+	--allow-remote \
+	--proto udp,tcp \
+	--port "$PEER_PORT" \
+	--response-pps 0 >"$PEER_LOG" 2>&1 &
+PEER_PID=$!
+
+wait_for_peer
+
+echo "Checking baseline connectivity..."
+sudo ip netns exec "$CLIENT_NS" ping -c 1 -W 1 "$PEER_ADDR" >/dev/null
+
+# A clean path, measured three ways
+discover icmp --proto icmp
+check_result icmp icmp
+discover udp --proto udp --port "$PEER_PORT"
+check_result udp udp
+discover tcp --proto tcp --port "$PEER_PORT"
+check_result tcp tcp
+
+# Nothing listening: the peer's Port Unreachable must be reported as such
+expect_port_unreachable
+
+# A PMTU black hole: echoed probes still find the bottleneck
+drop_frag_needed
+discover udp-blackhole --proto udp --port "$PEER_PORT" --parallel 4
+check_result udp-blackhole udp
+restore_frag_needed
+
+# A lossy, slow link: resends keep loss from passing for a smaller PMTU
+add_netem
+discover udp-lossy --proto udp --port "$PEER_PORT" --retries "$RETRIES"
+check_result udp-lossy udp "$DELAY_MS"
+discover icmp-lossy --proto icmp --retries "$RETRIES"
+check_result icmp-lossy icmp "$DELAY_MS"
+
+echo "Impairments lab passed."